@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"docker-deploy-app/internal/models"
+)
+
+func newBackupCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "backup",
+		Aliases: []string{"backups"},
+		Short:   "Create, list and restore backups",
+	}
+
+	cmd.AddCommand(newBackupListCommand(opts), newBackupCreateCommand(opts), newBackupRestoreCommand(opts))
+	return cmd
+}
+
+func newBackupListCommand(opts *options) *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List backups",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var result struct {
+				Backups []models.Backup `json:"backups"`
+			}
+			if err := newAPIClient(opts).get("/backups", url.Values{"limit": {strconv.Itoa(limit)}}, &result); err != nil {
+				return err
+			}
+			if opts.output == "json" {
+				return printJSON(result.Backups)
+			}
+
+			rows := make([][]string, 0, len(result.Backups))
+			for _, b := range result.Backups {
+				rows = append(rows, []string{b.ID, b.Name, string(b.Type), string(b.Status),
+					formatSize(b.SizeBytes), formatTime(b.CreatedAt)})
+			}
+			return printTable([]string{"ID", "NAME", "TYPE", "STATUS", "SIZE", "CREATED"}, rows)
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 50, "Maximum number of backups")
+
+	return cmd
+}
+
+func newBackupCreateCommand(opts *options) *cobra.Command {
+	var name string
+	var stacks []string
+	var volumes, encrypt, wait bool
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Back up stacks, all of them unless --stack is given",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := newAPIClient(opts)
+
+			var deploymentIDs []string
+			for _, stack := range stacks {
+				id, err := resolveStack(client, stack)
+				if err != nil {
+					return err
+				}
+				deploymentIDs = append(deploymentIDs, id)
+			}
+			if name == "" {
+				name = "ddctl-" + time.Now().Format("20060102-150405")
+			}
+
+			req := map[string]interface{}{
+				"name":            name,
+				"type":            models.BackupTypeManual,
+				"include_volumes": volumes,
+				"encrypted":       encrypt,
+				"deployment_ids":  deploymentIDs,
+				"all_deployments": len(deploymentIDs) == 0,
+			}
+
+			var result struct {
+				ID     string `json:"id"`
+				Name   string `json:"name"`
+				TaskID string `json:"task_id"`
+			}
+			if err := client.post("/backups", req, &result); err != nil {
+				return err
+			}
+
+			return finishTask(opts, client, wait, timeout, result.TaskID, result,
+				fmt.Sprintf("backup %s (%s)", result.ID, result.Name))
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "Backup name")
+	cmd.Flags().StringArrayVar(&stacks, "stack", nil, "Stack to back up, by name or deployment ID (repeatable)")
+	cmd.Flags().BoolVar(&volumes, "volumes", false, "Include volume data")
+	cmd.Flags().BoolVar(&encrypt, "encrypt", false, "Encrypt the backup")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the backup to finish")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Minute, "How long --wait waits")
+
+	return cmd
+}
+
+func newBackupRestoreCommand(opts *options) *cobra.Command {
+	var stacks []string
+	var overwrite, volumes, test, wait bool
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "restore <backup-id>",
+		Short: "Restore a backup, all of its stacks unless --stack is given",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := newAPIClient(opts)
+
+			req := models.RestoreConfig{
+				BackupID:          args[0],
+				Selective:         len(stacks) > 0,
+				DeploymentIDs:     stacks,
+				OverwriteExisting: overwrite,
+				RestoreVolumes:    volumes,
+				TestRestore:       test,
+			}
+
+			var result struct {
+				BackupID string `json:"backup_id"`
+				TaskID   string `json:"task_id"`
+			}
+			if err := client.post("/backups/"+url.PathEscape(args[0])+"/restore", req, &result); err != nil {
+				return err
+			}
+
+			return finishTask(opts, client, wait, timeout, result.TaskID, result,
+				fmt.Sprintf("restore of backup %s", args[0]))
+		},
+	}
+	cmd.Flags().StringArrayVar(&stacks, "stack", nil, "Deployment ID to restore (repeatable)")
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite existing deployments")
+	cmd.Flags().BoolVar(&volumes, "volumes", false, "Restore volume data")
+	cmd.Flags().BoolVar(&test, "test", false, "Validate the restore without applying it")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the restore to finish")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Minute, "How long --wait waits")
+
+	return cmd
+}
+
+// finishTask reports a started background operation, waiting for its task first
+// when wait is set
+func finishTask(opts *options, client *apiClient, wait bool, timeout time.Duration, taskID string, result interface{}, what string) error {
+	if !wait {
+		if opts.output == "json" {
+			return printJSON(result)
+		}
+		fmt.Printf("Started %s\n", what)
+		return nil
+	}
+
+	task, err := waitForTask(client, taskID, timeout)
+	if err != nil {
+		return err
+	}
+	if opts.output == "json" {
+		return printJSON(task)
+	}
+	if task.State == models.TaskStateFailed {
+		return fmt.Errorf("%s failed: %s", what, task.Error)
+	}
+	fmt.Printf("Completed %s\n", what)
+	return nil
+}
+
+// formatSize formats a byte count for table output
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
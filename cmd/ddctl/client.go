@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiClient calls the REST API of a Docker Deploy server
+type apiClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// newAPIClient creates a client for the configured server
+func newAPIClient(opts *options) *apiClient {
+	return &apiClient{
+		baseURL:    strings.TrimRight(opts.server, "/") + "/api",
+		apiKey:     opts.apiKey,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// get decodes the JSON response of a GET request into out
+func (c *apiClient) get(path string, query url.Values, out interface{}) error {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.do(http.MethodGet, path, nil, out)
+}
+
+// post sends body as JSON and decodes the JSON response into out
+func (c *apiClient) post(path string, body, out interface{}) error {
+	return c.do(http.MethodPost, path, body, out)
+}
+
+// do sends a request and decodes its JSON response into out when out is not nil
+func (c *apiClient) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := c.newRequest(method, path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// stream copies the body of a GET request to w until the server closes it. Streams
+// are not subject to the client timeout
+func (c *apiClient) stream(path string, query url.Values, w io.Writer) error {
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	req, err := c.newRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+
+	client := *c.httpClient
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *apiClient) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// checkResponse turns an error status into an error carrying the server's message
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	message := strings.TrimSpace(string(data))
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		message = body.Error
+	}
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return fmt.Errorf("%s (HTTP %d)", message, resp.StatusCode)
+}
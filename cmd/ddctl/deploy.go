@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"docker-deploy-app/internal/models"
+)
+
+func newDeployCommand(opts *options) *cobra.Command {
+	var stackName, mode string
+	var values []string
+	var autoStart, remapPorts, wait bool
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "deploy <template-id>",
+		Short: "Deploy a template as a new stack",
+		Example: `  ddctl deploy nextcloud --name cloud --set ADMIN_USER=admin --set ADMIN_PASSWORD=secret
+  ddctl deploy whoami --name whoami --mode swarm --wait`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			env, err := parseSetValues(values)
+			if err != nil {
+				return err
+			}
+			if stackName == "" {
+				stackName = args[0]
+			}
+
+			req := models.DeploymentConfig{
+				TemplateID:  args[0],
+				StackName:   stackName,
+				Environment: env,
+				AutoStart:   autoStart,
+				DeployMode:  models.DeployMode(mode),
+				RemapPorts:  remapPorts,
+			}
+
+			var result struct {
+				ID        string `json:"id"`
+				StackName string `json:"stack_name"`
+				Status    string `json:"status"`
+				TaskID    string `json:"task_id"`
+			}
+			client := newAPIClient(opts)
+			if err := client.post("/deployments", req, &result); err != nil {
+				return err
+			}
+
+			if !wait {
+				if opts.output == "json" {
+					return printJSON(result)
+				}
+				fmt.Printf("Deployment %s of stack %s started\n", result.ID, result.StackName)
+				return nil
+			}
+
+			task, err := waitForTask(client, result.TaskID, timeout)
+			if err != nil {
+				return err
+			}
+			if opts.output == "json" {
+				return printJSON(task)
+			}
+			if task.State == models.TaskStateFailed {
+				return fmt.Errorf("deployment %s failed: %s", result.ID, task.Error)
+			}
+			fmt.Printf("Deployment %s of stack %s completed\n", result.ID, result.StackName)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&stackName, "name", "", "Stack name (defaults to the template ID)")
+	cmd.Flags().StringArrayVar(&values, "set", nil, "Set a template variable, as KEY=VALUE (repeatable)")
+	cmd.Flags().StringVar(&mode, "mode", string(models.DeployModeCompose), "Deploy mode: compose or swarm")
+	cmd.Flags().BoolVar(&autoStart, "auto-start", true, "Start the stack when the host boots")
+	cmd.Flags().BoolVar(&remapPorts, "remap-ports", false, "Remap host ports that are already in use")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the deployment to finish")
+	cmd.Flags().DurationVar(&timeout, "timeout", 10*time.Minute, "How long --wait waits")
+
+	return cmd
+}
+
+// parseSetValues parses KEY=VALUE pairs. A value of @path reads the value from a file
+func parseSetValues(values []string) (map[string]string, error) {
+	env := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid --set %q: expected KEY=VALUE", value)
+		}
+
+		if strings.HasPrefix(val, "@") {
+			data, err := os.ReadFile(val[1:])
+			if err != nil {
+				return nil, fmt.Errorf("failed to read value of %s: %w", key, err)
+			}
+			val = strings.TrimRight(string(data), "\r\n")
+		}
+		env[strings.TrimSpace(key)] = val
+	}
+	return env, nil
+}
+
+// waitForTask polls a background task until it finishes or timeout elapses
+func waitForTask(client *apiClient, taskID string, timeout time.Duration) (*models.Task, error) {
+	if taskID == "" {
+		return nil, fmt.Errorf("server did not return a task to wait for")
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var task models.Task
+		if err := client.get("/tasks/"+taskID, nil, &task); err != nil {
+			return nil, err
+		}
+		if task.IsFinished() {
+			return &task, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for task %s (%d%%: %s)", taskID, task.Progress, task.Message)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// options holds the global flags shared by all commands
+type options struct {
+	server string
+	apiKey string
+	output string
+}
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// newRootCommand builds the ddctl command tree
+func newRootCommand() *cobra.Command {
+	opts := &options{}
+
+	root := &cobra.Command{
+		Use:           "ddctl",
+		Short:         "Command line client for the Docker Deploy API",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if opts.output != "table" && opts.output != "json" {
+				return fmt.Errorf("output must be 'table' or 'json'")
+			}
+			return nil
+		},
+	}
+
+	root.PersistentFlags().StringVar(&opts.server, "server", getEnv("DDCTL_SERVER", "http://localhost:8080"), "API server URL (DDCTL_SERVER)")
+	root.PersistentFlags().StringVar(&opts.apiKey, "api-key", os.Getenv("DDCTL_API_KEY"), "API key (DDCTL_API_KEY)")
+	root.PersistentFlags().StringVarP(&opts.output, "output", "o", "table", "Output format: table or json")
+
+	root.AddCommand(
+		newTemplatesCommand(opts),
+		newDeployCommand(opts),
+		newStacksCommand(opts),
+		newBackupCommand(opts),
+	)
+
+	return root
+}
+
+// getEnv gets an environment variable with a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
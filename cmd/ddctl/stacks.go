@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// deploymentSummary is a deployment as returned by the deployment listing
+type deploymentSummary struct {
+	ID           string    `json:"id"`
+	StackName    string    `json:"stack_name"`
+	TemplateName string    `json:"template_name"`
+	Status       string    `json:"status"`
+	DeployMode   string    `json:"deploy_mode"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newStacksCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "stacks",
+		Aliases: []string{"stack"},
+		Short:   "Inspect deployed stacks",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List stacks",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			deployments, err := listDeployments(newAPIClient(opts))
+			if err != nil {
+				return err
+			}
+			if opts.output == "json" {
+				return printJSON(deployments)
+			}
+
+			rows := make([][]string, 0, len(deployments))
+			for _, d := range deployments {
+				rows = append(rows, []string{d.ID, d.StackName, d.TemplateName, d.Status, d.DeployMode, formatTime(d.CreatedAt)})
+			}
+			return printTable([]string{"ID", "STACK", "TEMPLATE", "STATUS", "MODE", "CREATED"}, rows)
+		},
+	}
+
+	var follow bool
+	var tail int
+	logs := &cobra.Command{
+		Use:   "logs <stack>",
+		Short: "Print the logs of a stack, by stack name or deployment ID",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := newAPIClient(opts)
+			id, err := resolveStack(client, args[0])
+			if err != nil {
+				return err
+			}
+
+			if follow {
+				return client.stream("/stacks/"+url.PathEscape(id)+"/logs/stream", nil, os.Stdout)
+			}
+			query := url.Values{"tail": {strconv.Itoa(tail)}}
+			return client.stream("/stacks/"+url.PathEscape(id)+"/logs", query, os.Stdout)
+		},
+	}
+	logs.Flags().BoolVarP(&follow, "follow", "f", false, "Follow log output")
+	logs.Flags().IntVar(&tail, "tail", 100, "Number of lines to show from the end of the logs")
+
+	cmd.AddCommand(list, logs)
+	return cmd
+}
+
+// listDeployments returns all deployments
+func listDeployments(client *apiClient) ([]deploymentSummary, error) {
+	var result struct {
+		Deployments []deploymentSummary `json:"deployments"`
+	}
+	err := client.get("/deployments", url.Values{"limit": {"1000"}}, &result)
+	return result.Deployments, err
+}
+
+// resolveStack returns the deployment ID of a stack given its name or ID
+func resolveStack(client *apiClient, nameOrID string) (string, error) {
+	deployments, err := listDeployments(client)
+	if err != nil {
+		return "", err
+	}
+	for _, d := range deployments {
+		if d.ID == nameOrID || d.StackName == nameOrID {
+			return d.ID, nil
+		}
+	}
+	return "", fmt.Errorf("stack %s not found", nameOrID)
+}
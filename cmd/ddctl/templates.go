@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"docker-deploy-app/internal/models"
+)
+
+// templateList is the response of the template listing endpoints
+type templateList struct {
+	Templates []models.Template `json:"templates"`
+}
+
+func newTemplatesCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "templates",
+		Aliases: []string{"template", "tpl"},
+		Short:   "Browse deployment templates",
+	}
+
+	var category string
+	var limit int
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List templates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{"limit": {strconv.Itoa(limit)}}
+			if category != "" {
+				query.Set("category", category)
+			}
+
+			var result templateList
+			if err := newAPIClient(opts).get("/templates", query, &result); err != nil {
+				return err
+			}
+			return printTemplates(opts, result.Templates)
+		},
+	}
+	list.Flags().StringVar(&category, "category", "", "Only list templates of this category")
+	list.Flags().IntVar(&limit, "limit", 50, "Maximum number of templates")
+
+	search := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search the template marketplace",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := url.Values{
+				"q":     {strings.Join(args, " ")},
+				"limit": {strconv.Itoa(limit)},
+			}
+			if category != "" {
+				query.Set("category", category)
+			}
+
+			var result templateList
+			if err := newAPIClient(opts).get("/marketplace/search", query, &result); err != nil {
+				return err
+			}
+			return printTemplates(opts, result.Templates)
+		},
+	}
+	search.Flags().StringVar(&category, "category", "", "Only search templates of this category")
+	search.Flags().IntVar(&limit, "limit", 20, "Maximum number of results")
+
+	cmd.AddCommand(list, search)
+	return cmd
+}
+
+func printTemplates(opts *options, templates []models.Template) error {
+	if opts.output == "json" {
+		return printJSON(templates)
+	}
+	if len(templates) == 0 {
+		fmt.Println("No templates found")
+		return nil
+	}
+
+	rows := make([][]string, 0, len(templates))
+	for _, t := range templates {
+		rows = append(rows, []string{t.ID, t.Name, t.Category, t.Version, truncate(t.Description, 60)})
+	}
+	return printTable([]string{"ID", "NAME", "CATEGORY", "VERSION", "DESCRIPTION"}, rows)
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,68 +15,263 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
 
+	"docker-deploy-app/internal/analytics"
 	"docker-deploy-app/internal/api"
+	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/backup"
+	"docker-deploy-app/internal/bus"
 	"docker-deploy-app/internal/config"
 	"docker-deploy-app/internal/database"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/grpcapi"
+	"docker-deploy-app/internal/logging"
+	"docker-deploy-app/internal/newt"
+	"docker-deploy-app/internal/settings"
+	"docker-deploy-app/internal/tasks"
+	"docker-deploy-app/internal/telemetry"
+	"docker-deploy-app/internal/webhooks"
+	"docker-deploy-app/web"
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_PATH"), "path to a YAML configuration file")
+	flag.Parse()
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fatal("Failed to load config", err)
+	}
+
+	// Configure structured logging
+	logCloser, err := logging.Setup(cfg.Logging)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		fatal("Failed to configure logging", err)
 	}
+	defer logCloser.Close()
+
+	// Connect the message bus before the components publishing on it are created
+	messageBus, err := bus.New(cfg.Bus)
+	if err != nil {
+		fatal("Failed to connect message bus", err)
+	}
+	defer messageBus.Close()
+	bus.SetDefault(messageBus)
 
 	// Initialize database
 	db, err := database.Init(cfg.Database.Path)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal("Failed to initialize database", err)
 	}
 	defer db.Close()
 
 	// Run migrations
 	if err := database.Migrate(db); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+		fatal("Failed to run migrations", err)
 	}
 
+	// Layer the settings edited through the admin API over the file and environment,
+	// before components copy them out of the configuration
+	settingsStore := settings.NewStore(db, cfg)
+	if err := settingsStore.Load(); err != nil {
+		fatal("Failed to load settings", err)
+	}
+
+	// Tasks still running belonged to the previous process and will never finish
+	if failed, err := tasks.NewTracker(db).FailInterrupted(); err != nil {
+		slog.Error("Failed to mark interrupted tasks", "error", err)
+	} else if failed > 0 {
+		slog.Warn("Marked interrupted tasks as failed", "tasks", failed)
+	}
+
+	// Prune deployment logs past their retention
+	logPruner := database.NewLogPruner(db, cfg.Logging.DeploymentLogs)
+	logPruner.Start()
+	defer logPruner.Stop()
+
 	// Initialize Docker client
 	dockerClient, err := client.NewClientWithOpts(client.FromEnv)
 	if err != nil {
-		log.Fatalf("Failed to create Docker client: %v", err)
+		fatal("Failed to create Docker client", err)
 	}
 	defer dockerClient.Close()
 
+	// Start image update checker
+	updateChecker := docker.NewUpdateChecker(db, dockerClient)
+	updateChecker.Start(time.Duration(cfg.Docker.UpdateCheckInterval) * time.Second)
+	defer updateChecker.Stop()
+
+	// Start automatic updates for deployments that opted in
+	composeTimeout := time.Duration(cfg.Docker.ComposeTimeout) * time.Second
+	autoUpdater := docker.NewAutoUpdater(db, dockerClient, updateChecker,
+		docker.NewComposeManager("./deployments", composeTimeout),
+		docker.NewSwarmManager(dockerClient, "./deployments", composeTimeout))
+	autoUpdater.SetBeforeUpgrade(backup.NewSafetyBackups(
+		backup.NewManager(db, dockerClient, cfg.Backup, "./deployments"), cfg.Backup.Safety).BeforeUpgrade)
+	if err := autoUpdater.Start(); err != nil {
+		fatal("Failed to start auto updater", err)
+	}
+	defer autoUpdater.Stop()
+
+	// Run the scheduled commands of stacks
+	cronRunner := docker.NewCronRunner(db, dockerClient)
+	if err := cronRunner.Start(); err != nil {
+		fatal("Failed to start stack cron runner", err)
+	}
+	defer cronRunner.Stop()
+
+	// Check the uptime probes of deployments
+	if cfg.Monitoring.Uptime.Enabled {
+		uptimeMonitor := docker.NewUptimeMonitor(db, dockerClient, cfg.Monitoring.Uptime)
+		uptimeMonitor.Start()
+		defer uptimeMonitor.Stop()
+	}
+
+	// Sample the resource usage of deployments for footprint estimates
+	if cfg.Monitoring.Footprint.Enabled {
+		footprintSampler := docker.NewFootprintSampler(db, dockerClient, cfg.Monitoring.Footprint)
+		footprintSampler.Start()
+		defer footprintSampler.Stop()
+	}
+
+	// Record tunnel state transitions of stacks with a newt sidecar
+	if cfg.Newt.Enabled {
+		tunnelMonitor := newt.NewStatusCollector(db, dockerClient)
+		tunnelMonitor.Start(time.Duration(cfg.Newt.StatusInterval) * time.Second)
+		defer tunnelMonitor.Stop()
+	}
+
+	// Alert when a filesystem crosses a disk usage threshold
+	if cfg.Monitoring.DiskCheckInterval > 0 {
+		storageMonitor := docker.NewStorageMonitor(db, docker.NewStorageReporter(dockerClient,
+			cfg.Backup.Storage.Path, cfg.Database.Path, cfg.Monitoring.DiskWarningPercent, cfg.Monitoring.DiskCriticalPercent),
+			time.Duration(cfg.Monitoring.DiskCheckInterval)*time.Second)
+		storageMonitor.Start()
+		defer storageMonitor.Stop()
+	}
+
+	// Record container lifecycle events of deployed stacks
+	monitor := docker.NewMonitor(dockerClient, db, cfg.Monitoring)
+	if err := monitor.Start(); err != nil {
+		fatal("Failed to start Docker monitor", err)
+	}
+	defer monitor.Stop()
+
+	// Deliver queued webhook events and report unhealthy stacks
+	webhookDispatcher := webhooks.NewDispatcher(db, cfg.Webhooks)
+	webhookDispatcher.Start()
+	defer webhookDispatcher.Stop()
+	settingsStore.OnChange(func(c *config.Config) {
+		webhookDispatcher.Configure(c.Webhooks)
+	})
+
+	if cfg.Webhooks.Enabled {
+		healthWatcher := webhooks.NewHealthWatcher(db, dockerClient)
+		healthWatcher.Start()
+		defer healthWatcher.Stop()
+	}
+
+	// Keep the rolling template counts behind the trending endpoint up to date
+	statsRefresher := analytics.NewStatsRefresher(db)
+	statsRefresher.Start()
+	defer statsRefresher.Stop()
+
+	// Send anonymous usage reports if opted in
+	telemetryReporter := telemetry.NewReporter(db, cfg.Telemetry)
+	telemetryReporter.Start()
+	defer telemetryReporter.Stop()
+
 	// Initialize router
 	r := chi.NewRouter()
 
 	// Add middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.RealIP)
 	r.Use(middleware.RequestID)
+	r.Use(middleware.RealIP)
+	r.Use(apiMiddleware.Logger(cfg.Logging.TracePropagation))
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.Compress(5)) // gzip or deflate for JSON, HTML, CSS and JavaScript
 
-	// CORS configuration
+	// CORS configuration, validated when it was loaded
 	if cfg.Server.CORS.Enabled {
-		r.Use(cors.Handler(cors.Options{
-			AllowedOrigins:   cfg.Server.CORS.Origins,
-			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-			ExposedHeaders:   []string{"Link"},
-			AllowCredentials: true,
-			MaxAge:           300,
-		}))
+		r.Use(apiMiddleware.CORS(cfg.Server.CORS))
 	}
 
 	// Setup API routes
 	apiHandler := api.NewHandler(db, dockerClient, cfg)
 	api.SetupRoutes(r, apiHandler)
 
-	// Serve static files
-	workDir, _ := os.Getwd()
-	filesDir := http.Dir(fmt.Sprintf("%s/web", workDir))
-	fileServer(r, "/", filesDir)
+	// Reload the configuration file on SIGHUP or through the admin API
+	reloader := config.NewReloader(*configPath, cfg)
+	reloader.OnReload(func(c *config.Config) {
+		logging.SetLevel(c.Logging.Level)
+		apiHandler.RateLimiter.Configure(c.Security.RateLimiting.Enabled, c.Security.RateLimiting.RequestsPerMinute)
+		if err := settingsStore.Rebase(c); err != nil {
+			slog.Error("Failed to apply settings over the reloaded configuration", "error", err)
+		}
+	})
+	apiHandler.Reloader = reloader
+	apiHandler.Settings = settingsStore
+	if *configPath != "" {
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		go func() {
+			for range hangup {
+				result, err := reloader.Reload()
+				if err != nil {
+					slog.Error("Failed to reload configuration", "error", err)
+					continue
+				}
+				slog.Info("Configuration reloaded", "applied", result.Applied, "ignored", result.Ignored)
+			}
+		}()
+	}
+
+	// Run backup schedules, shared with the schedule endpoints so edits take effect
+	backupScheduler := apiHandler.Backups.Scheduler()
+	if err := backupScheduler.Start(); err != nil {
+		fatal("Failed to start backup scheduler", err)
+	}
+	defer backupScheduler.Stop()
+
+	// Sample stats of the stacks being watched in the background
+	statsCache := apiHandler.Stacks.StatsCache()
+	statsCache.Start()
+	defer statsCache.Stop()
+
+	// Purge deleted deployments once their trash retention expires
+	if cfg.Trash.Enabled {
+		trash := apiHandler.Deployments.Trash()
+		trash.Start()
+		defer trash.Stop()
+	}
+
+	// Renew ACME certificates before they expire
+	if cfg.ACME.Enabled {
+		certificates := apiHandler.Certificates.Manager()
+		certificates.Start()
+		defer certificates.Stop()
+	}
+
+	// Serve the frontend, embedded unless a directory is configured
+	var webFiles fs.FS = web.Files
+	if cfg.Server.WebDir != "" {
+		webFiles = os.DirFS(cfg.Server.WebDir)
+	}
+	spa, err := newSPAHandler(webFiles, cfg.Server.WebDir == "")
+	if err != nil {
+		fatal("Failed to load web UI", err)
+	}
+	r.Handle("/*", spa)
+
+	// Serve the deployment, stack and log services to agents over gRPC
+	if cfg.Server.GRPC.Enabled {
+		grpcServer := grpcapi.NewServer(db, dockerClient, cfg)
+		if err := grpcServer.Start(); err != nil {
+			fatal("Failed to start gRPC server", err)
+		}
+		defer grpcServer.Stop()
+	}
 
 	// Create server
 	srv := &http.Server{
@@ -84,12 +281,14 @@ func main() {
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	// Shutdown does not wait for hijacked connections, so close WebSockets explicitly
+	srv.RegisterOnShutdown(apiHandler.Sockets.Shutdown)
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Starting server on %s", srv.Addr)
+		slog.Info("Starting server", "addr", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+			fatal("Server failed to start", err)
 		}
 	}()
 
@@ -97,31 +296,21 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	slog.Info("Shutting down server")
 
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		fatal("Server forced to shutdown", err)
 	}
 
-	log.Println("Server exited")
+	slog.Info("Server exited")
 }
 
-// fileServer sets up a file server for static assets
-func fileServer(r chi.Router, path string, root http.FileSystem) {
-	if path != "/" && path[len(path)-1] != '/' {
-		r.Get(path, http.RedirectHandler(path+"/", 301).ServeHTTP)
-		path += "/"
-	}
-	path += "*"
-
-	r.Get(path, func(w http.ResponseWriter, r *http.Request) {
-		rctx := chi.RouteContext(r.Context())
-		pathPrefix := strings.TrimSuffix(rctx.RoutePattern(), "/*")
-		fs := http.StripPrefix(pathPrefix, http.FileServer(root))
-		fs.ServeHTTP(w, r)
-	})
-}
\ No newline at end of file
+// fatal logs an error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
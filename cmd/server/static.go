@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// entryPoints are revalidated on every load, so a new release is picked up at
+// once. Other assets are not content-hashed, so they are cached briefly
+var entryPoints = map[string]bool{
+	"index.html":    true,
+	"sw.js":         true,
+	"manifest.json": true,
+}
+
+// spaHandler serves the frontend. Paths that are not files get index.html, so
+// deep links into the client-side router work after a reload
+type spaHandler struct {
+	files fs.FS
+	etags map[string]string // Content hashes of embedded files, which have no mod time
+}
+
+// newSPAHandler creates a handler serving files. Embedded files are hashed once
+// for their ETags; files on disk are revalidated by mod time
+func newSPAHandler(files fs.FS, embedded bool) (*spaHandler, error) {
+	h := &spaHandler{files: files, etags: make(map[string]string)}
+	if !embedded {
+		return h, nil
+	}
+
+	err := fs.WalkDir(files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		h.etags[name] = `"` + hex.EncodeToString(sum[:8]) + `"`
+		return nil
+	})
+	return h, err
+}
+
+func (h *spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+
+	// Unknown API paths are errors, never the app
+	if name == "api" || strings.HasPrefix(name, "api/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !h.isFile(name) {
+		// A missing asset is reported as such rather than answered with HTML
+		if path.Ext(name) != "" {
+			http.NotFound(w, r)
+			return
+		}
+		name = "index.html"
+	}
+
+	h.serveFile(w, r, name)
+}
+
+func (h *spaHandler) isFile(name string) bool {
+	info, err := fs.Stat(h.files, name)
+	return err == nil && !info.IsDir()
+}
+
+func (h *spaHandler) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	f, err := h.files.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, "Failed to read file", http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+
+	if etag, ok := h.etags[name]; ok {
+		w.Header().Set("ETag", etag)
+	}
+	if entryPoints[name] {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// ServeContent sets the content type from the extension and answers
+	// conditional and range requests
+	http.ServeContent(w, r, name, info.ModTime(), content)
+}
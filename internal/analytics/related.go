@@ -0,0 +1,152 @@
+package analytics
+
+import (
+	"sort"
+
+	"docker-deploy-app/internal/models"
+)
+
+// Weights of the signals scoring related templates. Tags say most about what a
+// template is; projects and users running or liking both say what goes together
+const (
+	sharedTagWeight    = 3.0
+	sameCategoryWeight = 2.0
+	coDeploymentWeight = 2.0
+	sharedUserWeight   = 1.0
+)
+
+// wellRated is the lowest rating counted as a user liking a template
+const wellRated = 4
+
+// RelatedTemplates suggests up to limit templates related to a template: sharing
+// tags or its category, deployed in the same projects, or rated well by the same
+// users. The best scored come first
+func (r *Recorder) RelatedTemplates(templateID string, limit int) ([]models.RelatedTemplate, error) {
+	related := make(map[string]*models.RelatedTemplate)
+	entry := func(id string) *models.RelatedTemplate {
+		if related[id] == nil {
+			related[id] = &models.RelatedTemplate{ID: id, SharedTags: []string{}}
+		}
+		return related[id]
+	}
+
+	rows, err := r.db.Query(`
+		SELECT other.template_id, other.tag
+		FROM template_tags own
+		JOIN template_tags other ON other.tag = own.tag AND other.template_id != own.template_id
+		WHERE own.template_id = $1`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id, tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		e := entry(id)
+		e.SharedTags = append(e.SharedTags, tag)
+	}
+	rows.Close()
+
+	rows, err = r.db.Query(`
+		SELECT other.template_id, COUNT(DISTINCT COALESCE(other.project_id, 'global'))
+		FROM deployments own
+		JOIN deployments other ON COALESCE(other.project_id, 'global') = COALESCE(own.project_id, 'global')
+		                       AND other.template_id != own.template_id
+		WHERE own.template_id = $1
+		GROUP BY other.template_id`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id string
+		var projects int
+		if err := rows.Scan(&id, &projects); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entry(id).CoDeployments = projects
+	}
+	rows.Close()
+
+	rows, err = r.db.Query(`
+		SELECT other.template_id, COUNT(DISTINCT other.user_id)
+		FROM template_ratings own
+		JOIN template_ratings other ON other.user_id = own.user_id AND other.template_id != own.template_id
+		WHERE own.template_id = $1 AND own.rating >= $2 AND other.rating >= $2
+		GROUP BY other.template_id`, templateID, wellRated)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id string
+		var users int
+		if err := rows.Scan(&id, &users); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entry(id).SharedUsers = users
+	}
+	rows.Close()
+
+	// Templates of the same category are candidates too, so a template without
+	// tags or history still gets suggestions
+	var category string
+	r.db.QueryRow("SELECT COALESCE(category, '') FROM templates WHERE id = $1", templateID).Scan(&category)
+
+	rows, err = r.db.Query(`
+		SELECT id, name, COALESCE(description, ''), COALESCE(icon, ''), COALESCE(category, ''), COALESCE(avg_rating, 0)
+		FROM templates
+		WHERE id != $1`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []models.RelatedTemplate{}
+	for rows.Next() {
+		var t models.RelatedTemplate
+		if err := rows.Scan(&t.ID, &t.Name, &t.Description, &t.Icon, &t.Category, &t.AvgRating); err != nil {
+			return nil, err
+		}
+		sameCategory := category != "" && t.Category == category
+		e := related[t.ID]
+		if e == nil && !sameCategory {
+			continue
+		}
+		if e != nil {
+			t.SharedTags, t.CoDeployments, t.SharedUsers = e.SharedTags, e.CoDeployments, e.SharedUsers
+		} else {
+			t.SharedTags = []string{}
+		}
+		t.SameCategory = sameCategory
+
+		t.Score = sharedTagWeight*float64(len(t.SharedTags)) +
+			coDeploymentWeight*float64(t.CoDeployments) +
+			sharedUserWeight*float64(t.SharedUsers)
+		if t.SameCategory {
+			t.Score += sameCategoryWeight
+		}
+		sort.Strings(t.SharedTags)
+		suggestions = append(suggestions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Ties go to the better rated template
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		if suggestions[i].AvgRating != suggestions[j].AvgRating {
+			return suggestions[i].AvgRating > suggestions[j].AvgRating
+		}
+		return suggestions[i].ID < suggestions[j].ID
+	})
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
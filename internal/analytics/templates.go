@@ -0,0 +1,182 @@
+package analytics
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"docker-deploy-app/internal/models"
+)
+
+// maxFailureReasons is the number of distinct failure reasons reported per template
+const maxFailureReasons = 10
+
+// Recorder records deployment outcomes and aggregates them into template statistics
+type Recorder struct {
+	db *sql.DB
+}
+
+// NewRecorder creates a new template analytics recorder
+func NewRecorder(db *sql.DB) *Recorder {
+	return &Recorder{db: db}
+}
+
+// RecordDeployment stores the outcome of a finished deployment. The failure reason
+// is the last error logged by the deployment, or deployErr when none was logged.
+// Recording is best effort and never fails the deployment
+func (r *Recorder) RecordDeployment(templateID, deploymentID string, duration time.Duration, deployErr error) {
+	var reason sql.NullString
+	if deployErr != nil {
+		err := r.db.QueryRow(`
+			SELECT message FROM deployment_logs
+			WHERE deployment_id = $1 AND log_level = 'error'
+			ORDER BY id DESC LIMIT 1`, deploymentID).Scan(&reason)
+		if err != nil || reason.String == "" {
+			reason = sql.NullString{String: deployErr.Error(), Valid: true}
+		}
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO template_deploy_outcomes (template_id, deployment_id, succeeded, duration_ms, failure_reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		templateID, deploymentID, deployErr == nil, duration.Milliseconds(), reason, time.Now(),
+	)
+	if err != nil {
+		slog.Error("Failed to record deployment outcome", "template_id", templateID,
+			"deployment_id", deploymentID, "error", err)
+	}
+}
+
+// anonymousInstaller stands for every deployment made without a signed-in user,
+// so an instance without authentication counts as one installer
+const anonymousInstaller = "instance"
+
+// RecordInstall counts a deployment of a template by a user, or by the instance
+// when userID is empty. Each installer counts once towards unique installs,
+// however often they redeploy. Recording is best effort
+func (r *Recorder) RecordInstall(templateID, userID string) {
+	installer := userID
+	if installer == "" {
+		installer = anonymousInstaller
+	}
+
+	now := time.Now()
+	_, err := r.db.Exec(`
+		INSERT INTO template_installs (template_id, installer, deploys, first_installed_at, last_installed_at)
+		VALUES ($1, $2, 1, $3, $3)
+		ON CONFLICT(template_id, installer) DO UPDATE SET
+			deploys = deploys + 1, last_installed_at = excluded.last_installed_at`,
+		templateID, installer, now,
+	)
+	if err != nil {
+		slog.Error("Failed to record template install", "template_id", templateID, "error", err)
+	}
+}
+
+// HasInstalled reports whether a user ever deployed a template, even if the
+// deployment was removed since
+func (r *Recorder) HasInstalled(templateID, userID string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+	var installed bool
+	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM template_installs WHERE template_id = $1 AND installer = $2)",
+		templateID, userID).Scan(&installed)
+	return installed, err
+}
+
+// TemplateStats returns the deployment statistics of a template, including its most
+// frequent failure reasons. A template without finished deployments has zero counts
+func (r *Recorder) TemplateStats(templateID string) (*models.TemplateStats, error) {
+	stats := &models.TemplateStats{
+		TemplateID:     templateID,
+		FailureReasons: []models.FailureReason{},
+	}
+
+	var avgDuration sql.NullFloat64
+	var succeeded sql.NullInt64
+	var lastDeployed sql.NullString
+	err := r.db.QueryRow(`
+		SELECT COUNT(*), SUM(CASE WHEN succeeded THEN 1 ELSE 0 END), AVG(duration_ms), MAX(created_at)
+		FROM template_deploy_outcomes
+		WHERE template_id = $1`, templateID).Scan(&stats.Deployments, &succeeded, &avgDuration, &lastDeployed)
+	if err != nil {
+		return nil, err
+	}
+	fill(stats, int(succeeded.Int64), avgDuration.Float64)
+	if t, ok := parseTime(lastDeployed.String); ok {
+		stats.LastDeployedAt = &t
+	}
+
+	rows, err := r.db.Query(`
+		SELECT failure_reason, COUNT(*), MAX(created_at)
+		FROM template_deploy_outcomes
+		WHERE template_id = $1 AND NOT succeeded AND failure_reason IS NOT NULL
+		GROUP BY failure_reason
+		ORDER BY COUNT(*) DESC, MAX(created_at) DESC
+		LIMIT $2`, templateID, maxFailureReasons)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reason models.FailureReason
+		var lastSeen string
+		if err := rows.Scan(&reason.Reason, &reason.Count, &lastSeen); err != nil {
+			return nil, err
+		}
+		reason.LastSeenAt, _ = parseTime(lastSeen)
+		stats.FailureReasons = append(stats.FailureReasons, reason)
+	}
+
+	return stats, rows.Err()
+}
+
+// AllTemplateStats returns the statistics of every template with finished
+// deployments keyed by template ID. Failure reasons are not included
+func (r *Recorder) AllTemplateStats() (map[string]*models.TemplateStats, error) {
+	rows, err := r.db.Query(`
+		SELECT template_id, COUNT(*), SUM(CASE WHEN succeeded THEN 1 ELSE 0 END), AVG(duration_ms)
+		FROM template_deploy_outcomes
+		GROUP BY template_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := make(map[string]*models.TemplateStats)
+	for rows.Next() {
+		stats := &models.TemplateStats{}
+		var succeeded int
+		var avgDuration float64
+		if err := rows.Scan(&stats.TemplateID, &stats.Deployments, &succeeded, &avgDuration); err != nil {
+			return nil, err
+		}
+		fill(stats, succeeded, avgDuration)
+		all[stats.TemplateID] = stats
+	}
+
+	return all, rows.Err()
+}
+
+// fill derives the success rate and average duration from the aggregated counts
+func fill(stats *models.TemplateStats, succeeded int, avgDurationMs float64) {
+	stats.Succeeded = succeeded
+	stats.Failed = stats.Deployments - succeeded
+	if stats.Deployments > 0 {
+		stats.SuccessRate = float64(succeeded) * 100 / float64(stats.Deployments)
+	}
+	stats.AvgDurationSeconds = avgDurationMs / 1000
+}
+
+// parseTime parses a timestamp returned by an SQLite aggregate, which loses the
+// column's datetime type
+func parseTime(value string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
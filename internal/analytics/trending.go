@@ -0,0 +1,99 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"docker-deploy-app/internal/maintenance"
+)
+
+// statsRefreshInterval is how often the rolling template counts are recomputed
+const statsRefreshInterval = 15 * time.Minute
+
+// StatsRefresher keeps the template_stats table of rolling 7 and 30 day deploy and
+// install counts up to date, for the trending endpoint to read
+type StatsRefresher struct {
+	db     *sql.DB
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewStatsRefresher creates a new template stats refresher
+func NewStatsRefresher(db *sql.DB) *StatsRefresher {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &StatsRefresher{
+		db:     db,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start refreshes the stats now and then periodically
+func (sr *StatsRefresher) Start() {
+	slog.Info("Starting template stats refresh", "interval", statsRefreshInterval)
+	go func() {
+		sr.refresh()
+
+		ticker := time.NewTicker(statsRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if maintenance.Active(sr.db) {
+					continue
+				}
+				sr.refresh()
+			case <-sr.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops refreshing
+func (sr *StatsRefresher) Stop() {
+	sr.cancel()
+}
+
+func (sr *StatsRefresher) refresh() {
+	if err := sr.Refresh(); err != nil {
+		slog.Error("Template stats refresh failed", "error", err)
+	}
+}
+
+// Refresh recomputes the rolling counts of every template. Deploys are finished
+// deployments, kept after a deployment is deleted; installs are distinct
+// installers who deployed the template within the window. The window bounds are
+// computed here rather than with SQL date functions, so the query stays portable
+func (sr *StatsRefresher) Refresh() error {
+	now := time.Now()
+	week, month := now.AddDate(0, 0, -7), now.AddDate(0, 0, -30)
+
+	tx, err := sr.db.BeginTx(sr.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM template_stats"); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO template_stats (template_id, deploys_7d, deploys_30d, installs_7d, installs_30d, refreshed_at)
+		SELECT t.id,
+		       (SELECT COUNT(*) FROM template_deploy_outcomes o WHERE o.template_id = t.id AND o.created_at > $1),
+		       (SELECT COUNT(*) FROM template_deploy_outcomes o WHERE o.template_id = t.id AND o.created_at > $2),
+		       (SELECT COUNT(*) FROM template_installs i WHERE i.template_id = t.id AND i.last_installed_at > $1),
+		       (SELECT COUNT(*) FROM template_installs i WHERE i.template_id = t.id AND i.last_installed_at > $2),
+		       $3
+		FROM templates t`,
+		week, month, now)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,496 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/logging"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/quotas"
+)
+
+// maxManifestSize bounds the size of an apply request body
+const maxManifestSize = 1 << 20
+
+// errVersionConflict reports that a deployment changed since its version was read
+var errVersionConflict = errors.New("deployment was modified concurrently")
+
+// existingDeployment is the current state of a deployment as compared by apply
+type existingDeployment struct {
+	ID         string
+	ProjectID  string
+	TemplateID string
+	Status     models.DeploymentStatus
+	DeployMode models.DeployMode
+	Config     map[string]interface{}
+	Version    int
+}
+
+// ETag returns the concurrency token of the deployment
+func (d *existingDeployment) ETag() string {
+	return (&models.Deployment{ID: d.ID, ResourceVersion: d.Version}).ETag()
+}
+
+// Apply converges the instance to a YAML or JSON manifest of deployments: missing
+// stacks are created, changed ones redeployed and, with prune: true, stacks absent
+// from the manifest deleted. With dry_run=true the plan is returned without changes
+func (h *DeploymentsHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxManifestSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxManifestSize {
+		http.Error(w, "Manifest too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	manifest, err := models.ParseManifest(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := manifest.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Check every deployment before changing anything
+	templates := make(map[string]*models.Template, len(manifest.Deployments))
+	for i := range manifest.Deployments {
+		req := &manifest.Deployments[i]
+		template, derr := h.prepareDeployment(req)
+		if derr != nil {
+			derr.message = fmt.Sprintf("%s: %s", req.StackName, derr.message)
+			derr.write(w)
+			return
+		}
+		templates[req.StackName] = template
+		req.RequestedBy = requestedBy(r)
+	}
+
+	existing, err := h.loadExistingDeployments()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	changes := planApply(manifest, existing)
+
+	// Every project the manifest creates in or changes stacks of needs the operator role
+	for i := range manifest.Deployments {
+		req := &manifest.Deployments[i]
+		if !h.canOperate(r, req.ProjectID) {
+			http.Error(w, fmt.Sprintf("%s: Insufficient permissions in project %s", req.StackName, req.ProjectID), http.StatusForbidden)
+			return
+		}
+	}
+	for _, change := range changes {
+		if current := existing[change.StackName]; current != nil && !h.canOperate(r, current.ProjectID) {
+			http.Error(w, fmt.Sprintf("%s: Insufficient permissions in project %s", change.StackName, current.ProjectID), http.StatusForbidden)
+			return
+		}
+	}
+
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	if !dryRun {
+		logger := logging.FromContext(r.Context())
+		desired := make(map[string]*models.DeploymentConfig, len(manifest.Deployments))
+		for i := range manifest.Deployments {
+			desired[manifest.Deployments[i].StackName] = &manifest.Deployments[i]
+		}
+
+		for _, change := range changes {
+			req := desired[change.StackName]
+			if err := h.applyChange(logger, change, req, templates[change.StackName], existing[change.StackName], requestedBy(r)); err != nil {
+				change.Error = err.Error()
+				logger.Error("Failed to apply deployment change", "stack", change.StackName, "action", change.Action, "error", err)
+			}
+		}
+	}
+
+	failed := 0
+	summary := make(map[models.ApplyAction]int)
+	for _, change := range changes {
+		summary[change.Action]++
+		if change.Error != "" {
+			failed++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dry_run": dryRun,
+		"success": failed == 0,
+		"summary": summary,
+		"changes": changes,
+	})
+}
+
+// planApply diffs the manifest against the existing deployments
+func planApply(manifest *models.Manifest, existing map[string]*existingDeployment) []*models.ApplyChange {
+	var changes []*models.ApplyChange
+	declared := make(map[string]bool)
+
+	for i := range manifest.Deployments {
+		req := &manifest.Deployments[i]
+		declared[req.StackName] = true
+
+		changes = append(changes, planDeployment(req, existing[req.StackName]))
+	}
+
+	if manifest.Prune {
+		var stale []string
+		for stackName := range existing {
+			if !declared[stackName] {
+				stale = append(stale, stackName)
+			}
+		}
+		sort.Strings(stale)
+
+		for _, stackName := range stale {
+			changes = append(changes, &models.ApplyChange{
+				StackName:    stackName,
+				DeploymentID: existing[stackName].ID,
+				Action:       models.ApplyActionDelete,
+			})
+		}
+	}
+
+	return changes
+}
+
+// planDeployment decides how to converge a deployment, current being nil when the
+// stack does not exist yet
+func planDeployment(req *models.DeploymentConfig, current *existingDeployment) *models.ApplyChange {
+	if current == nil {
+		return &models.ApplyChange{StackName: req.StackName, Action: models.ApplyActionCreate}
+	}
+
+	change := &models.ApplyChange{
+		StackName:    req.StackName,
+		DeploymentID: current.ID,
+		Fields:       diffDeployment(current, req),
+		Action:       models.ApplyActionUnchanged,
+	}
+	if current.DeployMode != req.DeployMode {
+		change.Action = models.ApplyActionReplace
+	} else if len(change.Fields) > 0 {
+		change.Action = models.ApplyActionUpdate
+	}
+	return change
+}
+
+// diffDeployment lists the fields of a deployment that differ from the request
+func diffDeployment(current *existingDeployment, req *models.DeploymentConfig) []string {
+	var fields []string
+	if current.TemplateID != req.TemplateID {
+		fields = append(fields, "template_id")
+	}
+	if current.DeployMode != req.DeployMode {
+		fields = append(fields, "deploy_mode")
+	}
+
+	// Round trip the desired configuration through JSON so it compares like the stored one
+	var desired map[string]interface{}
+	data, _ := json.Marshal(deploymentConfigMap(req))
+	json.Unmarshal(data, &desired)
+	desired["tunnel_provider"] = string(req.TunnelProvider)
+
+	keys := make(map[string]bool)
+	for key := range desired {
+		keys[key] = true
+	}
+	for key := range current.Config {
+		keys[key] = true
+	}
+
+	var changed []string
+	for key := range keys {
+		if !reflect.DeepEqual(desired[key], current.Config[key]) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+
+	return append(fields, changed...)
+}
+
+// applyTeardownOptions are how apply takes a stack down, to replace it in another
+// deploy mode or to prune it. Volumes are always kept: a manifest never deletes data
+func applyTeardownOptions(action models.ApplyAction) *models.DeploymentDeleteOptions {
+	if action == models.ApplyActionReplace {
+		return &models.DeploymentDeleteOptions{KeepFiles: true} // Rewritten by the redeploy
+	}
+	return &models.DeploymentDeleteOptions{}
+}
+
+// applyChange carries out one planned change. Pruned deployments are deleted as
+// DELETE /deployments/{id} does, through the trash when it is enabled
+func (h *DeploymentsHandler) applyChange(logger *slog.Logger, change *models.ApplyChange, req *models.DeploymentConfig, template *models.Template, current *existingDeployment, deletedBy string) error {
+	switch change.Action {
+	case models.ApplyActionCreate:
+		deployment, taskID, err := h.startDeployment(logger, req, template)
+		if err != nil {
+			return err
+		}
+		change.DeploymentID, change.TaskID = deployment.ID, taskID
+
+	case models.ApplyActionUpdate, models.ApplyActionReplace:
+		safetyBackup, err := h.safety.Before(current.ID, "redeploy")
+		if err != nil {
+			return err
+		}
+		if safetyBackup != nil {
+			change.BackupID = safetyBackup.ID
+		}
+
+		deployment, err := h.updateDeployment(current, req)
+		if err != nil {
+			return err
+		}
+		if change.Action == models.ApplyActionReplace {
+			if _, err := h.teardownStack(req.StackName, current.DeployMode, applyTeardownOptions(change.Action)); err != nil {
+				return err
+			}
+		}
+		h.addDeploymentLog(deployment.ID, "info", fmt.Sprintf("Configuration changed: %s", strings.Join(change.Fields, ", ")))
+		change.TaskID = h.launchDeployment(logger, deployment, template, req, "Redeploying "+deployment.StackName)
+
+	case models.ApplyActionDelete:
+		safetyBackup, err := h.safety.Before(current.ID, "delete")
+		if err != nil {
+			return err
+		}
+		if safetyBackup != nil {
+			change.BackupID = safetyBackup.ID
+		}
+
+		_, _, err = h.destroyDeployment(context.Background(), current.ID, change.StackName, current.DeployMode,
+			applyTeardownOptions(change.Action), deletedBy)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateDeployment stores the new configuration of an existing deployment, provided
+// it is still at the version current was read at, and bumps its version
+func (h *DeploymentsHandler) updateDeployment(current *existingDeployment, req *models.DeploymentConfig) (*models.Deployment, error) {
+	deployment := &models.Deployment{
+		ID:              current.ID,
+		TemplateID:      req.TemplateID,
+		StackName:       req.StackName,
+		Status:          models.StatusPending,
+		DeployMode:      req.DeployMode,
+		NewtInjected:    req.IncludeNewt,
+		TunnelProvider:  req.TunnelProvider,
+		Config:          deploymentConfigMap(req),
+		ResourceVersion: current.Version + 1,
+		UpdatedAt:       time.Now(),
+	}
+
+	configJSON, _ := deployment.MarshalConfig()
+	result, err := h.db.Exec(`
+		UPDATE deployments
+		SET template_id = $1, status = $2, deploy_mode = $3, config = $4, newt_injected = $5,
+		    tunnel_provider = $6, resource_version = $7, updated_at = $8
+		WHERE id = $9 AND COALESCE(resource_version, 1) = $10`,
+		deployment.TemplateID, deployment.Status, deployment.DeployMode, configJSON,
+		deployment.NewtInjected, deployment.TunnelProvider, deployment.ResourceVersion,
+		deployment.UpdatedAt, deployment.ID, current.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, errVersionConflict
+	}
+
+	return deployment, nil
+}
+
+// PutByName creates or updates the deployment of a stack. The request is idempotent:
+// an unchanged configuration is not redeployed. If-Match makes the update conditional
+// on the ETag last read, and If-None-Match: * makes it create-only
+func (h *DeploymentsHandler) PutByName(w http.ResponseWriter, r *http.Request) {
+	stackName := chi.URLParam(r, "stack")
+
+	var req models.DeploymentConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.StackName == "" {
+		req.StackName = stackName
+	}
+	if req.StackName != stackName {
+		http.Error(w, "Stack name in body does not match the URL", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	template, derr := h.prepareDeployment(&req)
+	if derr != nil {
+		derr.write(w)
+		return
+	}
+	req.RequestedBy = requestedBy(r)
+
+	current, err := h.getExistingDeployment(stackName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Both the stack's project and the one it is created in need the operator role
+	if !h.canOperate(r, req.ProjectID) || (current != nil && !h.canOperate(r, current.ProjectID)) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	etag := ""
+	if current != nil {
+		etag = current.ETag()
+	}
+	if !checkPreconditions(w, r, etag) {
+		return
+	}
+
+	change := planDeployment(&req, current)
+	err = h.applyChange(logging.FromContext(r.Context()), change, &req, template, current, req.RequestedBy)
+	if errors.Is(err, errVersionConflict) {
+		http.Error(w, "Precondition failed: deployment was modified concurrently", http.StatusPreconditionFailed)
+		return
+	}
+	var quotaErr *quotas.ExceededError
+	if errors.As(err, &quotaErr) {
+		http.Error(w, quotaErr.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to apply deployment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if updated, err := h.getExistingDeployment(stackName); err == nil && updated != nil {
+		etag = updated.ETag()
+	}
+
+	status := http.StatusOK
+	if change.Action == models.ApplyActionCreate {
+		status = http.StatusCreated
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         change.DeploymentID,
+		"stack_name": stackName,
+		"action":     change.Action,
+		"fields":     change.Fields,
+		"task_id":    change.TaskID,
+		"etag":       etag,
+	})
+}
+
+// checkPreconditions evaluates If-Match and If-None-Match against the current ETag of
+// a resource, empty when it does not exist. It writes 412 Precondition Failed and
+// returns false when the request must not proceed
+func checkPreconditions(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, etag) {
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.Error(w, "Precondition failed: resource has changed", http.StatusPreconditionFailed)
+		return false
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		w.Header().Set("ETag", etag)
+		http.Error(w, "Precondition failed: resource already exists", http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// etagMatches reports whether a comma separated If-Match/If-None-Match header lists
+// etag. * matches any existing resource
+func etagMatches(header, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// getExistingDeployment returns the deployment of a stack, or nil when there is none
+func (h *DeploymentsHandler) getExistingDeployment(stackName string) (*existingDeployment, error) {
+	existing, err := h.queryExistingDeployments("WHERE stack_name = $1", stackName)
+	if err != nil {
+		return nil, err
+	}
+	return existing[stackName], nil
+}
+
+// loadExistingDeployments returns all deployments keyed by stack name
+func (h *DeploymentsHandler) loadExistingDeployments() (map[string]*existingDeployment, error) {
+	return h.queryExistingDeployments("")
+}
+
+// queryExistingDeployments returns the deployments matching where keyed by stack name
+func (h *DeploymentsHandler) queryExistingDeployments(where string, args ...interface{}) (map[string]*existingDeployment, error) {
+	rows, err := h.db.Query(`
+		SELECT id, COALESCE(project_id, 'global'), template_id, stack_name, status, deploy_mode, config, COALESCE(tunnel_provider, 'newt'),
+		       COALESCE(resource_version, 1)
+		FROM deployments `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]*existingDeployment)
+	for rows.Next() {
+		var d existingDeployment
+		var stackName, configJSON, tunnelProvider string
+		if err := rows.Scan(&d.ID, &d.ProjectID, &d.TemplateID, &stackName, &d.Status, &d.DeployMode, &configJSON,
+			&tunnelProvider, &d.Version); err != nil {
+			return nil, err
+		}
+
+		deployment := models.Deployment{}
+		deployment.UnmarshalConfig(configJSON)
+		d.Config = deployment.Config
+		if d.Config == nil {
+			d.Config = make(map[string]interface{})
+		}
+		d.Config["tunnel_provider"] = tunnelProvider
+		if d.DeployMode == "" {
+			d.DeployMode = models.DeployModeCompose
+		}
+
+		existing[stackName] = &d
+	}
+	return existing, rows.Err()
+}
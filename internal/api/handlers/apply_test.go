@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"testing"
+
+	"docker-deploy-app/internal/models"
+)
+
+func TestApplyTeardownKeepsVolumes(t *testing.T) {
+	for _, action := range []models.ApplyAction{models.ApplyActionReplace, models.ApplyActionDelete} {
+		options := applyTeardownOptions(action)
+		if options.RemoveVolumes {
+			t.Errorf("%s removes volumes, want them kept", action)
+		}
+		if options.Permanent {
+			t.Errorf("%s bypasses the trash", action)
+		}
+	}
+	if !applyTeardownOptions(models.ApplyActionReplace).KeepFiles {
+		t.Errorf("replace removes the stack files it redeploys from")
+	}
+}
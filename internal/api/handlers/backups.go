@@ -3,31 +3,62 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
 	"os"
-	"path/filepath"
+	"path"
+	"strconv"
 	"time"
 
+	"github.com/docker/docker/client"
 	"github.com/go-chi/chi/v5"
+	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/backup"
 	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/docker"
 	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/quotas"
 )
 
 // BackupsHandler handles backup-related HTTP requests
 type BackupsHandler struct {
-	db     *sql.DB
-	config *config.Config
+	db        *sql.DB
+	config    *config.Config
+	manager   *backup.Manager
+	safety    *backup.SafetyBackups
+	scheduler *backup.Scheduler
+	quotas    *quotas.Enforcer
 }
 
 // NewBackupsHandler creates a new backups handler
-func NewBackupsHandler(db *sql.DB, config *config.Config) *BackupsHandler {
+func NewBackupsHandler(db *sql.DB, dockerClient *client.Client, config *config.Config) *BackupsHandler {
+	manager := backup.NewManager(db, dockerClient, config.Backup, "./deployments")
+	manager.SetCompose(docker.NewComposeManager("./deployments", time.Duration(config.Docker.ComposeTimeout)*time.Second))
+	safety := backup.NewSafetyBackups(manager, config.Backup.Safety)
+	manager.SetBeforeOverwrite(func(deploymentID string) error {
+		_, err := safety.Before(deploymentID, "restore")
+		return err
+	})
+
 	return &BackupsHandler{
-		db:     db,
-		config: config,
+		db:        db,
+		config:    config,
+		manager:   manager,
+		safety:    safety,
+		scheduler: backup.NewScheduler(db, manager),
+		quotas:    quotas.NewEnforcer(db, dockerClient, config.Quotas),
 	}
 }
 
+// Scheduler returns the scheduler running the backup schedules managed by this handler
+func (h *BackupsHandler) Scheduler() *backup.Scheduler {
+	return h.scheduler
+}
+
 // List returns all backups
 func (h *BackupsHandler) List(w http.ResponseWriter, r *http.Request) {
 	backupType := r.URL.Query().Get("type")
@@ -84,6 +115,9 @@ func (h *BackupsHandler) List(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			continue
 		}
+		if !h.canAccess(r, b.ID, "viewer") {
+			continue
+		}
 
 		if completedAt.Valid {
 			b.CompletedAt = &completedAt.Time
@@ -129,6 +163,7 @@ func (h *BackupsHandler) Create(w http.ResponseWriter, r *http.Request) {
 		Type            string   `json:"type"`
 		IncludeVolumes  bool     `json:"include_volumes"`
 		Encrypted       bool     `json:"encrypted"`
+		Passphrase      string   `json:"passphrase"` // For passphrase key storage
 		DeploymentIDs   []string `json:"deployment_ids"`
 		AllDeployments  bool     `json:"all_deployments"`
 	}
@@ -153,12 +188,21 @@ func (h *BackupsHandler) Create(w http.ResponseWriter, r *http.Request) {
 		}
 		defer rows.Close()
 
+		// Only the deployments the caller may back up are included
 		for rows.Next() {
 			var id string
 			rows.Scan(&id)
-			deploymentIDs = append(deploymentIDs, id)
+			if h.canOperate(r, id) {
+				deploymentIDs = append(deploymentIDs, id)
+			}
 		}
 	} else {
+		for _, id := range req.DeploymentIDs {
+			if !h.canOperate(r, id) {
+				http.Error(w, fmt.Sprintf("Insufficient permissions for deployment %s", id), http.StatusForbidden)
+				return
+			}
+		}
 		deploymentIDs = req.DeploymentIDs
 	}
 
@@ -167,49 +211,57 @@ func (h *BackupsHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate backup ID
-	backupID := fmt.Sprintf("backup_%d", time.Now().Unix())
+	if err := h.quotas.CheckBackup(r.Context(), deploymentIDs, requestedBy(r)); err != nil {
+		writeQuotaError(w, err)
+		return
+	}
 
-	// Create backup record
-	backup := &models.Backup{
-		ID:             backupID,
+	deployments := make([]models.DeploymentBackup, 0, len(deploymentIDs))
+	for _, deploymentID := range deploymentIDs {
+		var stackName string
+		err := h.db.QueryRow("SELECT stack_name FROM deployments WHERE id = $1", deploymentID).Scan(&stackName)
+		if err == sql.ErrNoRows {
+			http.Error(w, fmt.Sprintf("Deployment %s not found", deploymentID), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		deployments = append(deployments, models.DeploymentBackup{ID: deploymentID, StackName: stackName})
+	}
+
+	backupType := models.BackupType(req.Type)
+	if backupType == "" {
+		backupType = models.BackupTypeManual
+	}
+
+	// The manager writes the archive in the background, and only then marks the
+	// backup and its task completed and publishes backup.completed
+	b, taskID, err := h.manager.CreateBackup(&models.BackupConfig{
 		Name:           req.Name,
-		Type:           models.BackupType(req.Type),
-		Status:         models.BackupStatusCreating,
+		Type:           backupType,
 		IncludeVolumes: req.IncludeVolumes,
 		Encrypted:      req.Encrypted,
-		DeploymentIDs:  deploymentIDs,
-		CreatedAt:      time.Now(),
-	}
-
-	// Set storage path
-	backup.StoragePath = filepath.Join(h.config.Backup.Storage.Path, backupID+".tar.gz")
-
-	// Save to database
-	deploymentIDsJSON, _ := backup.MarshalDeploymentIDs()
-	_, err := h.db.Exec(`
-		INSERT INTO backups (id, name, type, status, size_bytes, include_volumes, encrypted,
-		                     storage_path, deployment_ids, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
-		backup.ID, backup.Name, backup.Type, backup.Status, backup.SizeBytes,
-		backup.IncludeVolumes, backup.Encrypted, backup.StoragePath,
-		deploymentIDsJSON, backup.CreatedAt,
-	)
-
+		Passphrase:     req.Passphrase,
+		Deployments:    deployments,
+	})
+	if errors.Is(err, backup.ErrEncryptionDisabled) || errors.Is(err, backup.ErrPassphraseRequired) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create backup: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Start backup process in background
-	go h.performBackup(backup)
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":      backup.ID,
-		"name":    backup.Name,
-		"status":  backup.Status,
+		"id":      b.ID,
+		"name":    b.Name,
+		"status":  b.Status,
+		"task_id": taskID,
 		"message": "Backup started",
 	})
 }
@@ -373,26 +425,67 @@ func (h *BackupsHandler) Restore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Start restore process in background
-	go h.performRestore(&req)
+	// Deployments the restore overwrites get a safety backup first, through the
+	// manager's overwrite hook
+	taskID, err := h.manager.RestoreBackup(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start restore: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Restore started",
+		"backup_id":   backupID,
+		"task_id":     taskID,
+		"selective":   req.Selective,
+		"test_mode":   req.TestRestore,
+		"stack_names": req.StackNames,
+	})
+}
+
+// RestoreFromURL restores an archive streamed from a URL, such as a presigned S3 URL
+func (h *BackupsHandler) RestoreFromURL(w http.ResponseWriter, r *http.Request) {
+	var req models.RemoteRestoreConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	taskID, err := h.manager.RestoreFromURL(&req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start restore: %v", err), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message":    "Restore started",
-		"backup_id":  backupID,
-		"selective":  req.Selective,
-		"test_mode":  req.TestRestore,
+		"message":   "Restore started",
+		"task_id":   taskID,
+		"selective": req.Selective,
+		"test_mode": req.TestRestore,
+		"verified":  req.Checksum != "",
 	})
 }
 
-// Download downloads a backup file
+// Download downloads a backup archive, resumable through Range requests, or with
+// ?deployment=<id> a tar of only that deployment
 func (h *BackupsHandler) Download(w http.ResponseWriter, r *http.Request) {
 	backupID := chi.URLParam(r, "id")
+	deploymentID := r.URL.Query().Get("deployment")
 
 	// Get backup info
-	var storagePath, name string
+	var storagePath, name, deploymentIDsJSON string
 	var status models.BackupStatus
-	err := h.db.QueryRow("SELECT storage_path, name, status FROM backups WHERE id = $1", backupID).Scan(&storagePath, &name, &status)
+	var encrypted bool
+	err := h.db.QueryRow("SELECT storage_path, name, status, encrypted, deployment_ids FROM backups WHERE id = $1", backupID).Scan(
+		&storagePath, &name, &status, &encrypted, &deploymentIDsJSON)
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Backup not found", http.StatusNotFound)
@@ -408,17 +501,152 @@ func (h *BackupsHandler) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(storagePath); os.IsNotExist(err) {
+	file, err := os.Open(storagePath)
+	if os.IsNotExist(err) {
 		http.Error(w, "Backup file not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to open backup: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to open backup: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Archives can be tens of GB, more than the server write timeout allows for
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	if deploymentID != "" {
+		h.downloadDeployment(w, storagePath, name, deploymentID, deploymentIDsJSON, encrypted)
+		return
+	}
 
-	// Serve the file
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.tar.gz\"", name))
+	// Archives never change once completed, so size and modification time identify
+	// the content for resumed (If-Range) and conditional requests
+	w.Header().Set("ETag", fmt.Sprintf("\"%s-%d-%d\"", backupID, stat.Size(), stat.ModTime().Unix()))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ".tar.gz"}))
 	w.Header().Set("Content-Type", "application/gzip")
-	
-	http.ServeFile(w, r, storagePath)
+
+	// ServeContent answers Range requests and sets Content-Length and Accept-Ranges
+	http.ServeContent(w, r, name+".tar.gz", stat.ModTime(), file)
+}
+
+// downloadDeployment streams a tar of a single deployment of the backup, unpacked
+// from the archive on the fly
+func (h *BackupsHandler) downloadDeployment(w http.ResponseWriter, storagePath, name, deploymentID, deploymentIDsJSON string, encrypted bool) {
+	if encrypted {
+		http.Error(w, "Deployment downloads are not available for encrypted backups", http.StatusBadRequest)
+		return
+	}
+
+	var deploymentIDs []string
+	json.Unmarshal([]byte(deploymentIDsJSON), &deploymentIDs)
+	found := false
+	for _, id := range deploymentIDs {
+		if id == deploymentID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		http.Error(w, "Deployment not found in backup", http.StatusNotFound)
+		return
+	}
+
+	// The size is unknown until streamed, so the response is neither resumable nor
+	// has a Content-Length
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + "_" + deploymentID + ".tar"}))
+	w.Header().Set("Content-Type", "application/x-tar")
+
+	if err := h.manager.StreamDeployment(storagePath, deploymentID, w); err != nil {
+		// Headers are already sent; the truncated tar is the only signal left
+		slog.Error("Failed to stream deployment from backup", "deployment_id", deploymentID, "error", err)
+	}
+}
+
+// Contents lists what a backup archive holds, its deployments with their compose
+// files and volumes with sizes, without restoring it. Backups encrypted with a
+// passphrase take it in the X-Backup-Passphrase header
+func (h *BackupsHandler) Contents(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.completedBackup(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	contents, err := h.manager.Contents(b, r.Header.Get("X-Backup-Passphrase"))
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(contents)
+}
+
+// File downloads a single file of a backup archive, such as one compose file,
+// given by its path in the archive as listed by Contents
+func (h *BackupsHandler) File(w http.ResponseWriter, r *http.Request) {
+	b, ok := h.completedBackup(w, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	file, header, err := h.manager.OpenFile(b, r.Header.Get("X-Backup-Passphrase"), chi.URLParam(r, "*"))
+	if err != nil {
+		writeArchiveError(w, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(header.Name)}))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(header.Size, 10))
+	w.Header().Set("Last-Modified", header.ModTime.UTC().Format(http.TimeFormat))
+
+	if _, err := io.Copy(w, file); err != nil {
+		slog.Error("Failed to stream file from backup", "backup_id", b.ID, "path", header.Name, "error", err)
+	}
+}
+
+// completedBackup loads a backup whose archive can be read, writing the error
+// response otherwise
+func (h *BackupsHandler) completedBackup(w http.ResponseWriter, backupID string) (*models.Backup, bool) {
+	b, err := h.manager.GetBackup(backupID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Backup not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if b.Status != models.BackupStatusCompleted {
+		http.Error(w, "Backup is not completed", http.StatusBadRequest)
+		return nil, false
+	}
+	return b, true
+}
+
+// writeArchiveError writes the response for a backup archive that could not be read
+func writeArchiveError(w http.ResponseWriter, err error) {
+	switch {
+	case os.IsNotExist(err):
+		http.Error(w, "Backup file not found", http.StatusNotFound)
+	case errors.Is(err, backup.ErrFileNotInBackup):
+		http.Error(w, "File not found in backup", http.StatusNotFound)
+	case errors.Is(err, backup.ErrPassphraseNeeded):
+		http.Error(w, "Backup is encrypted with a passphrase; set X-Backup-Passphrase", http.StatusBadRequest)
+	case errors.Is(err, backup.ErrBackupTampered), errors.Is(err, backup.ErrBackupTruncated):
+		http.Error(w, fmt.Sprintf("Failed to decrypt backup: %v", err), http.StatusBadRequest)
+	default:
+		http.Error(w, fmt.Sprintf("Failed to read backup: %v", err), http.StatusInternalServerError)
+	}
 }
 
 // Upload uploads a backup file
@@ -440,6 +668,12 @@ func (h *BackupsHandler) TestRestore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The route addresses no backup, so the role is checked against the request's
+	if !h.canAccess(r, req.BackupID, "operator") {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
 	// Perform test restore validation
 	result := h.validateRestore(&req)
 
@@ -452,8 +686,8 @@ func (h *BackupsHandler) TestRestore(w http.ResponseWriter, r *http.Request) {
 // ListSchedules returns all backup schedules
 func (h *BackupsHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
 	query := `
-		SELECT id, name, cron_expression, include_volumes, encrypt, enabled,
-		       last_run, next_run, created_at
+		SELECT id, name, cron_expression, timezone, include_volumes, encrypt, enabled,
+		       selector, last_run, next_run, created_at
 		FROM backup_schedules
 		ORDER BY created_at DESC`
 
@@ -467,16 +701,21 @@ func (h *BackupsHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
 	var schedules []models.BackupSchedule
 	for rows.Next() {
 		var s models.BackupSchedule
+		var timezone, selectorJSON sql.NullString
 		var lastRun, nextRun sql.NullTime
 
 		err := rows.Scan(
-			&s.ID, &s.Name, &s.CronExpression, &s.IncludeVolumes, &s.Encrypt,
-			&s.Enabled, &lastRun, &nextRun, &s.CreatedAt,
+			&s.ID, &s.Name, &s.CronExpression, &timezone, &s.IncludeVolumes, &s.Encrypt,
+			&s.Enabled, &selectorJSON, &lastRun, &nextRun, &s.CreatedAt,
 		)
 		if err != nil {
 			continue
 		}
 
+		s.Timezone = timezone.String
+		s.UnmarshalSelector(selectorJSON.String)
+		s.Describe()
+
 		if lastRun.Valid {
 			s.LastRun = &lastRun.Time
 		}
@@ -502,82 +741,117 @@ func (h *BackupsHandler) CreateSchedule(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if schedule.Name == "" || schedule.CronExpression == "" {
-		http.Error(w, "Name and cron expression required", http.StatusBadRequest)
+	if err := schedule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	schedule.CreatedAt = time.Now()
-	schedule.UpdateNextRun() // Calculate next run time
-
-	_, err := h.db.Exec(`
-		INSERT INTO backup_schedules (name, cron_expression, include_volumes, encrypt, enabled, next_run, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		schedule.Name, schedule.CronExpression, schedule.IncludeVolumes,
-		schedule.Encrypt, schedule.Enabled, schedule.NextRun, schedule.CreatedAt,
-	)
-
-	if err != nil {
+	if err := h.scheduler.AddSchedule(&schedule); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create schedule: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
+	schedule.Describe()
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Schedule created successfully",
+		"message":  "Schedule created successfully",
+		"schedule": schedule,
 	})
 }
 
-// UpdateSchedule updates a backup schedule
+// scheduleUpdate lists the schedule fields a client may change. Omitted fields keep
+// their current value
+type scheduleUpdate struct {
+	Name           *string                  `json:"name"`
+	CronExpression *string                  `json:"cron_expression"`
+	Timezone       *string                  `json:"timezone"`
+	IncludeVolumes *bool                    `json:"include_volumes"`
+	Encrypt        *bool                    `json:"encrypt"`
+	Enabled        *bool                    `json:"enabled"`
+	Selector       *models.ScheduleSelector `json:"selector"`
+}
+
+// apply copies the fields present in the update onto the schedule
+func (u *scheduleUpdate) apply(schedule *models.BackupSchedule) {
+	if u.Name != nil {
+		schedule.Name = *u.Name
+	}
+	if u.CronExpression != nil {
+		schedule.CronExpression = *u.CronExpression
+	}
+	if u.Timezone != nil {
+		schedule.Timezone = *u.Timezone
+	}
+	if u.IncludeVolumes != nil {
+		schedule.IncludeVolumes = *u.IncludeVolumes
+	}
+	if u.Encrypt != nil {
+		schedule.Encrypt = *u.Encrypt
+	}
+	if u.Enabled != nil {
+		schedule.Enabled = *u.Enabled
+	}
+	if u.Selector != nil {
+		schedule.Selector = *u.Selector
+	}
+}
+
+// UpdateSchedule updates a backup schedule and re-registers its cron job
 func (h *BackupsHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
-	scheduleID := chi.URLParam(r, "id")
-	
-	var updates map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	scheduleID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
 		return
 	}
 
-	// Build update query dynamically
-	setParts := []string{}
-	args := []interface{}{}
-	argCount := 0
-
-	for field, value := range updates {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("%s = $%d", field, argCount))
-		args = append(args, value)
+	var update scheduleUpdate
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&update); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request: %v", err), http.StatusBadRequest)
+		return
 	}
 
-	if len(setParts) == 0 {
-		http.Error(w, "No fields to update", http.StatusBadRequest)
+	schedule, err := h.scheduler.GetSchedule(scheduleID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	argCount++
-	query := fmt.Sprintf("UPDATE backup_schedules SET %s WHERE id = $%d", 
-		strings.Join(setParts, ", "), argCount)
-	args = append(args, scheduleID)
+	update.apply(schedule)
+	if err := schedule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	_, err := h.db.Exec(query, args...)
-	if err != nil {
+	if err := h.scheduler.UpdateSchedule(schedule); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update schedule: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	schedule.Describe()
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Schedule updated successfully",
+		"message":  "Schedule updated successfully",
+		"schedule": schedule,
 	})
 }
 
 // DeleteSchedule deletes a backup schedule
 func (h *BackupsHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
-	scheduleID := chi.URLParam(r, "id")
-
-	_, err := h.db.Exec("DELETE FROM backup_schedules WHERE id = $1", scheduleID)
+	scheduleID, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.scheduler.RemoveSchedule(scheduleID); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to delete schedule: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -590,40 +864,21 @@ func (h *BackupsHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request)
 
 // Helper functions
 
-func (h *BackupsHandler) performBackup(backup *models.Backup) {
-	// TODO: Implement actual backup logic:
-	// 1. Create backup directory
-	// 2. Export docker-compose files for each deployment
-	// 3. Backup volume data if requested
-	// 4. Create backup metadata
-	// 5. Create compressed archive
-	// 6. Encrypt if requested
-	// 7. Update database with final status and size
-
-	// Simulate backup process
-	time.Sleep(10 * time.Second)
-
-	// Update status to completed
-	now := time.Now()
-	h.db.Exec(`
-		UPDATE backups SET status = $1, size_bytes = $2, completed_at = $3 
-		WHERE id = $4`,
-		models.BackupStatusCompleted, 1024*1024*10, now, backup.ID, // 10MB simulated size
-	)
+// canAccess reports whether the caller has the role in every project of a backup.
+// Roles are only known when authentication is enabled
+func (h *BackupsHandler) canAccess(r *http.Request, backupID, role string) bool {
+	user := apiMiddleware.UserFromContext(r.Context())
+	if user == nil {
+		return true
+	}
+	allowed, err := apiMiddleware.HasBackupRole(h.db, user, backupID, role)
+	return err == nil && allowed
 }
 
-func (h *BackupsHandler) performRestore(config *models.RestoreConfig) {
-	// TODO: Implement actual restore logic:
-	// 1. Extract backup archive
-	// 2. Validate backup contents
-	// 3. Stop existing deployments if overwrite is enabled
-	// 4. Restore docker-compose files
-	// 5. Restore volume data if included
-	// 6. Deploy restored stacks
-	// 7. Verify deployment success
-
-	// Simulate restore process
-	time.Sleep(15 * time.Second)
+// canOperate reports whether the caller may back up a deployment
+func (h *BackupsHandler) canOperate(r *http.Request, deploymentID string) bool {
+	user := apiMiddleware.UserFromContext(r.Context())
+	return user == nil || apiMiddleware.HasDeploymentRole(h.db, user, deploymentID, "operator")
 }
 
 func (h *BackupsHandler) validateRestore(config *models.RestoreConfig) map[string]interface{} {
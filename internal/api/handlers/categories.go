@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+)
+
+// CategoriesHandler handles marketplace category management
+type CategoriesHandler struct {
+	db     *sql.DB
+	config *config.Config
+}
+
+// NewCategoriesHandler creates a new categories handler
+func NewCategoriesHandler(db *sql.DB, config *config.Config) *CategoriesHandler {
+	return &CategoriesHandler{
+		db:     db,
+		config: config,
+	}
+}
+
+// List returns the categories in marketplace order with their template counts
+func (h *CategoriesHandler) List(w http.ResponseWriter, r *http.Request) {
+	categories, err := listCategories(h.db)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"categories": categories,
+		"total":      len(categories),
+	})
+}
+
+// Create adds a category at the end of the marketplace order, unless a position is given
+func (h *CategoriesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var category models.Category
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := category.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE name = $1)", category.Name).Scan(&exists)
+	if exists {
+		http.Error(w, "Category already exists", http.StatusConflict)
+		return
+	}
+
+	if category.Position == 0 {
+		h.db.QueryRow("SELECT COALESCE(MAX(position) + 1, 0) FROM categories").Scan(&category.Position)
+	}
+	keywordsJSON, _ := json.Marshal(category.Keywords)
+	category.CreatedAt = time.Now()
+	category.UpdatedAt = category.CreatedAt
+
+	_, err := h.db.Exec(`
+		INSERT INTO categories (name, display_name, description, icon, keywords, position, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		category.Name, category.DisplayName, category.Description, category.Icon, string(keywordsJSON),
+		category.Position, category.CreatedAt, category.UpdatedAt)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create category: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"category": category,
+		"message":  "Category created successfully",
+	})
+}
+
+// Update changes a category's display name, description, icon, keywords and position.
+// The name is kept, since templates reference it
+func (h *CategoriesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var category models.Category
+	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	category.Name = chi.URLParam(r, "name")
+	if err := category.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	keywordsJSON, _ := json.Marshal(category.Keywords)
+	result, err := h.db.Exec(`
+		UPDATE categories
+		SET display_name = $1, description = $2, icon = $3, keywords = $4, position = $5, updated_at = $6
+		WHERE name = $7`,
+		category.DisplayName, category.Description, category.Icon, string(keywordsJSON), category.Position,
+		time.Now(), category.Name)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Category updated successfully",
+	})
+}
+
+// Reorder sets the marketplace order to the given category names. Categories
+// left out keep their relative order after the listed ones
+func (h *CategoriesHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Names []string `json:"names"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Names) == 0 {
+		http.Error(w, "Invalid JSON, expected the category names in order", http.StatusBadRequest)
+		return
+	}
+
+	categories, err := listCategories(h.db)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	known := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		known[c.Name] = true
+	}
+
+	order := make([]string, 0, len(categories))
+	listed := make(map[string]bool, len(req.Names))
+	for _, name := range req.Names {
+		if !known[name] {
+			http.Error(w, fmt.Sprintf("Category %q not found", name), http.StatusBadRequest)
+			return
+		}
+		if !listed[name] {
+			listed[name] = true
+			order = append(order, name)
+		}
+	}
+	for _, c := range categories {
+		if !listed[c.Name] {
+			order = append(order, c.Name)
+		}
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	for position, name := range order {
+		if _, err := tx.Exec("UPDATE categories SET position = $1, updated_at = $2 WHERE name = $3",
+			position, time.Now(), name); err != nil {
+			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"order":   order,
+		"message": "Categories reordered successfully",
+	})
+}
+
+// Reassign moves templates from a category to another: all of them, or the
+// template IDs given
+func (h *CategoriesHandler) Reassign(w http.ResponseWriter, r *http.Request) {
+	var req models.CategoryReassignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	moved, err := reassignTemplates(h.db, chi.URLParam(r, "name"), req.To, req.TemplateIDs)
+	switch err {
+	case nil:
+	case models.ErrCategoryNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case models.ErrCategoryReassignInvalid:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Templates reassigned", "from", chi.URLParam(r, "name"), "to", req.To, "templates", moved)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"moved":   moved,
+		"message": fmt.Sprintf("%d templates moved to %s", moved, req.To),
+	})
+}
+
+// Delete removes a category. Its templates must be moved first, or to the
+// category named by ?reassign_to= in the same request
+func (h *CategoriesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var exists bool
+	var templates int
+	h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE name = $1)", name).Scan(&exists)
+	if !exists {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+	h.db.QueryRow("SELECT COUNT(*) FROM templates WHERE category = $1", name).Scan(&templates)
+
+	if templates > 0 {
+		to := r.URL.Query().Get("reassign_to")
+		if to == "" {
+			http.Error(w, models.ErrCategoryInUse.Error(), http.StatusConflict)
+			return
+		}
+		if _, err := reassignTemplates(h.db, name, to, nil); err != nil {
+			if err == models.ErrCategoryNotFound || err == models.ErrCategoryReassignInvalid {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if _, err := h.db.Exec("DELETE FROM categories WHERE name = $1", name); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete category: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Category deleted successfully",
+	})
+}
+
+// listCategories returns every category in marketplace order with its template count
+func listCategories(db *sql.DB) ([]models.Category, error) {
+	rows, err := db.Query(`
+		SELECT c.name, c.display_name, COALESCE(c.description, ''), COALESCE(c.icon, ''),
+		       COALESCE(c.keywords, '[]'), c.position, c.created_at, c.updated_at,
+		       (SELECT COUNT(*) FROM templates t WHERE t.category = c.name)
+		FROM categories c
+		ORDER BY c.position, c.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var c models.Category
+		var keywordsJSON string
+		if err := rows.Scan(&c.Name, &c.DisplayName, &c.Description, &c.Icon, &keywordsJSON, &c.Position,
+			&c.CreatedAt, &c.UpdatedAt, &c.Templates); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(keywordsJSON), &c.Keywords)
+		if c.Keywords == nil {
+			c.Keywords = []string{}
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// reassignTemplates moves templates of category from to category to, returning
+// how many moved. With no template IDs all of the category's templates move
+func reassignTemplates(db *sql.DB, from, to string, templateIDs []string) (int64, error) {
+	if to == "" || to == from {
+		return 0, models.ErrCategoryReassignInvalid
+	}
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE name = $1)", to).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, models.ErrCategoryNotFound
+	}
+
+	now := time.Now()
+	if len(templateIDs) == 0 {
+		result, err := db.Exec("UPDATE templates SET category = $1, updated_at = $2 WHERE category = $3", to, now, from)
+		if err != nil {
+			return 0, err
+		}
+		return result.RowsAffected()
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var moved int64
+	for _, id := range templateIDs {
+		result, err := tx.Exec("UPDATE templates SET category = $1, updated_at = $2 WHERE id = $3 AND category = $4",
+			to, now, id, from)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		moved += n
+	}
+	return moved, tx.Commit()
+}
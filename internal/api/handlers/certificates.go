@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/certs"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/tasks"
+)
+
+// CertificatesHandler manages the ACME certificates served by TLS sidecars
+type CertificatesHandler struct {
+	db      *sql.DB
+	config  *config.Config
+	manager *certs.Manager
+	tasks   *tasks.Tracker
+}
+
+// NewCertificatesHandler creates a new certificates handler
+func NewCertificatesHandler(db *sql.DB, config *config.Config, manager *certs.Manager) *CertificatesHandler {
+	return &CertificatesHandler{
+		db:      db,
+		config:  config,
+		manager: manager,
+		tasks:   tasks.NewTracker(db),
+	}
+}
+
+// Manager returns the certificate manager, whose renewals the server runs
+func (h *CertificatesHandler) Manager() *certs.Manager {
+	return h.manager
+}
+
+// List returns all certificates, those expiring first at the top
+func (h *CertificatesHandler) List(w http.ResponseWriter, r *http.Request) {
+	certificates, err := h.manager.List()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"certificates": certificates,
+		"total":        len(certificates),
+		"enabled":      h.manager.Enabled(),
+	})
+}
+
+// Get returns a certificate
+func (h *CertificatesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	cert, err := h.manager.Get(chi.URLParam(r, "id"))
+	if err == models.ErrCertificateNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cert)
+}
+
+// Create requests a certificate for a list of domains. It is obtained in the
+// background; the returned task reports the outcome
+func (h *CertificatesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req models.CertificateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Challenge == "" {
+		req.Challenge = h.manager.DefaultChallenge()
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	cert, err := h.manager.Create(&req, requestedBy(r))
+	if errors.Is(err, certs.ErrACMEDisabled) || errors.Is(err, certs.ErrNoDNSProvider) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create certificate: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	taskID := h.issue(cert.ID, "Obtaining certificate")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"certificate": cert,
+		"task_id":     taskID,
+		"message":     "Certificate requested",
+	})
+}
+
+// Renew obtains a certificate again ahead of its scheduled renewal, or retries
+// one that failed
+func (h *CertificatesHandler) Renew(w http.ResponseWriter, r *http.Request) {
+	if !h.manager.Enabled() {
+		http.Error(w, certs.ErrACMEDisabled.Error(), http.StatusBadRequest)
+		return
+	}
+	cert, err := h.manager.Get(chi.URLParam(r, "id"))
+	if err == models.ErrCertificateNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	taskID := h.issue(cert.ID, "Renewing certificate")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"task_id": taskID,
+		"message": "Certificate renewal started",
+	})
+}
+
+// Delete removes a certificate no deployment serves
+func (h *CertificatesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	err := h.manager.Delete(chi.URLParam(r, "id"))
+	if err == models.ErrCertificateNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err == certs.ErrCertificateInUse {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete certificate: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Certificate deleted",
+	})
+}
+
+// issue obtains a certificate in the background, tracked as a task
+func (h *CertificatesHandler) issue(id, message string) string {
+	taskID := h.tasks.Start(models.TaskTypeCertificate, id, message)
+	go func() {
+		err := h.manager.Issue(id)
+		if err != nil {
+			slog.Error("Failed to obtain certificate", "certificate_id", id, "error", err)
+		}
+		h.tasks.Finish(taskID, err)
+	}()
+	return taskID
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/logging"
+	"docker-deploy-app/internal/models"
+)
+
+// Clone deploys a copy of a deployment under a new stack name, for example a
+// staging copy. The copy uses the same template, environment and settings, with
+// the request's environment overrides merged over them. Host ports of the source
+// still in use are reported as conflicts to change, unless remap_ports is set,
+// and with copy_volumes the data of its named volumes is copied
+func (h *DeploymentsHandler) Clone(w http.ResponseWriter, r *http.Request) {
+	sourceID := chi.URLParam(r, "id")
+
+	var req models.CloneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var config models.DeploymentConfig
+	var sourceStack, sourceProjectID, configJSON string
+	err := h.db.QueryRow(`
+		SELECT template_id, stack_name, COALESCE(project_id, 'global'), deploy_mode, COALESCE(tunnel_provider, 'newt'), config
+		FROM deployments WHERE id = $1`, sourceID).Scan(
+		&config.TemplateID, &sourceStack, &sourceProjectID, &config.DeployMode, &config.TunnelProvider, &configJSON,
+	)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// The stored configuration uses the keys of a deployment request
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid source configuration: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	config.ProjectID = sourceProjectID
+	if req.ProjectID != "" && req.ProjectID != sourceProjectID {
+		var projectExists bool
+		h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1)", req.ProjectID).Scan(&projectExists)
+		if !projectExists {
+			http.Error(w, models.ErrProjectNotFound.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The route only checks the source project
+		if user := apiMiddleware.UserFromContext(r.Context()); user != nil && !apiMiddleware.HasProjectRole(h.db, user, req.ProjectID, "operator") {
+			http.Error(w, "Insufficient permissions in target project", http.StatusForbidden)
+			return
+		}
+		config.ProjectID = req.ProjectID
+	}
+
+	config.StackName = req.StackName
+	config.OverrideExisting = false
+	if config.Environment == nil {
+		config.Environment = make(map[string]string)
+	}
+	for key, value := range req.Environment {
+		config.Environment[key] = value
+	}
+	if req.RemapPorts {
+		config.RemapPorts = true
+	}
+	if req.AutoStart != nil {
+		config.AutoStart = *req.AutoStart
+	}
+
+	if err := config.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	template, derr := h.prepareDeployment(&config)
+	if derr != nil {
+		derr.write(w)
+		return
+	}
+	config.RequestedBy = requestedBy(r)
+
+	var existingID string
+	err = h.db.QueryRow("SELECT id FROM deployments WHERE stack_name = $1", config.StackName).Scan(&existingID)
+	if err != sql.ErrNoRows {
+		http.Error(w, "Stack name already exists", http.StatusConflict)
+		return
+	}
+
+	// The source's own containers hold its host ports, so a copy conflicts with
+	// them unless the environment moves them or they are remapped
+	var conflicts []models.PortConflict
+	content, err := h.compose.ReadComposeFile(sourceStack)
+	if err != nil && !os.IsNotExist(err) {
+		http.Error(w, fmt.Sprintf("Failed to read source compose file: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		_, conflicts, err = h.ports.Check(config.StackName, content, config.Environment, config.RemapPorts)
+		var conflictErr *docker.PortConflictError
+		if errors.As(err, &conflictErr) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":     "Host ports of the source are in use: change them in environment or set remap_ports",
+				"conflicts": conflictErr.Conflicts,
+			})
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Port check failed: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Volumes are copied first so compose adopts them when the copy comes up,
+	// once quotas are known to allow the copy
+	volumes := []string{}
+	if req.CopyVolumes {
+		if err := h.quotas.CheckDeployment(r.Context(), config.ProjectID, config.RequestedBy); err != nil {
+			writeStartError(w, err)
+			return
+		}
+		volumes, err = h.backups.CloneVolumes(sourceID, config.StackName)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to copy volumes: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	deployment, taskID, err := h.startDeployment(logging.FromContext(r.Context()), &config, template)
+	if err != nil {
+		writeStartError(w, err)
+		return
+	}
+	slog.Info("Deployment cloned", "source_id", sourceID, "deployment_id", deployment.ID, "stack", deployment.StackName,
+		"volumes", len(volumes), "remapped_ports", len(conflicts))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", deployment.ETag())
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":             deployment.ID,
+		"stack_name":     deployment.StackName,
+		"project_id":     deployment.ProjectID,
+		"status":         deployment.Status,
+		"etag":           deployment.ETag(),
+		"task_id":        taskID,
+		"source_id":      sourceID,
+		"volumes":        volumes,
+		"remapped_ports": conflicts,
+		"warnings":       deployment.Warnings,
+		"message":        "Clone started",
+	})
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/models"
+)
+
+// ListProbes returns the uptime probes of a deployment with their status and
+// uptime over the last day
+func (h *DeploymentsHandler) ListProbes(w http.ResponseWriter, r *http.Request) {
+	deploymentID := chi.URLParam(r, "id")
+	probes, err := h.uptime.List(deploymentID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deployment_id": deploymentID,
+		"probes":        probes,
+		"total":         len(probes),
+	})
+}
+
+// CreateProbe adds an uptime probe to a deployment
+func (h *DeploymentsHandler) CreateProbe(w http.ResponseWriter, r *http.Request) {
+	deploymentID := chi.URLParam(r, "id")
+
+	var exists int
+	err := h.db.QueryRow("SELECT 1 FROM deployments WHERE id = $1", deploymentID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var req models.UptimeProbeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	probe := &models.UptimeProbe{DeploymentID: deploymentID, Enabled: true}
+	req.Apply(probe)
+	if err := h.uptime.Create(probe); err != nil {
+		writeProbeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(probe)
+}
+
+// UpdateProbe replaces the definition of an uptime probe
+func (h *DeploymentsHandler) UpdateProbe(w http.ResponseWriter, r *http.Request) {
+	probe, ok := h.uptimeProbe(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.UptimeProbeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	previousType, previousTarget := probe.Type, probe.Target
+	req.Apply(probe)
+	if err := h.uptime.Update(probe, probe.Type != previousType || probe.Target != previousTarget); err != nil {
+		writeProbeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(probe)
+}
+
+// DeleteProbe removes an uptime probe and its check history
+func (h *DeploymentsHandler) DeleteProbe(w http.ResponseWriter, r *http.Request) {
+	probe, ok := h.uptimeProbe(w, r)
+	if !ok {
+		return
+	}
+	if err := h.uptime.Delete(probe); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Uptime probe deleted",
+	})
+}
+
+// CheckProbe checks an uptime probe now and records the result
+func (h *DeploymentsHandler) CheckProbe(w http.ResponseWriter, r *http.Request) {
+	probe, ok := h.uptimeProbe(w, r)
+	if !ok {
+		return
+	}
+
+	var stackName string
+	h.db.QueryRow("SELECT stack_name FROM deployments WHERE id = $1", probe.DeploymentID).Scan(&stackName)
+
+	check := h.uptime.CheckNow(r.Context(), probe, stackName)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"probe": probe,
+		"check": check,
+	})
+}
+
+// GetProbeHistory returns the checks of an uptime probe within a window, given
+// as a duration such as 24h or a number of days such as 7d, with its uptime
+// and latency percentiles over the window
+func (h *DeploymentsHandler) GetProbeHistory(w http.ResponseWriter, r *http.Request) {
+	probe, ok := h.uptimeProbe(w, r)
+	if !ok {
+		return
+	}
+
+	windowName := r.URL.Query().Get("window")
+	if windowName == "" {
+		windowName = "24h"
+	}
+	window, err := parseWindow(windowName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.uptime.Stats(probe.ID, windowName, window)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	checks, err := h.uptime.History(probe.ID, window, getIntParam(r, "limit", 500))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"probe":  probe,
+		"uptime": stats,
+		"checks": checks,
+	})
+}
+
+// uptimeProbe loads the probe of the request path, writing the error response
+// if it cannot
+func (h *DeploymentsHandler) uptimeProbe(w http.ResponseWriter, r *http.Request) (*models.UptimeProbe, bool) {
+	probeID, err := strconv.ParseInt(chi.URLParam(r, "probe"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid probe ID", http.StatusBadRequest)
+		return nil, false
+	}
+	probe, err := h.uptime.Get(chi.URLParam(r, "id"), probeID)
+	if err == models.ErrProbeNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	return probe, true
+}
+
+// parseWindow parses a history window: a duration such as 12h, or days such
+// as 30d. Windows are at most 90 days
+func parseWindow(value string) (time.Duration, error) {
+	var window time.Duration
+	if strings.HasSuffix(value, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("Invalid window: use a duration such as 24h or days such as 7d")
+		}
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid window: use a duration such as 24h or days such as 7d")
+		}
+		window = parsed
+	}
+	if window <= 0 || window > 90*24*time.Hour {
+		return 0, fmt.Errorf("Invalid window: must be positive and at most 90 days")
+	}
+	return window, nil
+}
+
+// writeProbeError maps an uptime probe error to its status
+func writeProbeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrProbeNameRequired), errors.Is(err, models.ErrProbeTypeInvalid),
+		errors.Is(err, models.ErrProbeTargetInvalid), errors.Is(err, models.ErrProbeIntervalInvalid),
+		errors.Is(err, models.ErrProbeTimeoutInvalid), errors.Is(err, models.ErrProbeStatusInvalid),
+		errors.Is(err, models.ErrProbeThresholdInvalid):
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+	default:
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+	}
+}
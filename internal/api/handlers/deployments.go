@@ -1,18 +1,34 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/client"
 	"github.com/go-chi/chi/v5"
-	"github.com/gorilla/websocket"
+	"docker-deploy-app/internal/analytics"
+	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/api/sockets"
+	"docker-deploy-app/internal/backup"
+	"docker-deploy-app/internal/bus"
+	"docker-deploy-app/internal/certs"
 	"docker-deploy-app/internal/config"
 	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/github"
+	"docker-deploy-app/internal/logging"
+	"docker-deploy-app/internal/logstream"
 	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/quotas"
+	"docker-deploy-app/internal/tasks"
+	"docker-deploy-app/internal/webhooks"
 )
 
 // DeploymentsHandler handles deployment-related HTTP requests
@@ -21,31 +37,91 @@ type DeploymentsHandler struct {
 	dockerClient *client.Client
 	config       *config.Config
 	compose      *docker.ComposeManager
-	upgrader     websocket.Upgrader
+	swarm        *docker.SwarmManager
+	repos        *github.RepositoryService
+	updater      *docker.AutoUpdater
+	ports        *docker.PortChecker
+	tasks        *tasks.Tracker
+	webhooks     *webhooks.Publisher
+	analytics    *analytics.Recorder
+	backups      *backup.Manager
+	safety       *backup.SafetyBackups
+	sockets      *sockets.Manager
+	trash        *docker.Trash
+	quotas       *quotas.Enforcer
+	sbom         *docker.SBOMGenerator
+	logs         *logstream.Hub
+	resources    *docker.ResourceChecker
+	platform     *docker.HostPlatform
+	certificates *certs.Manager
+	hooks        *docker.HookRunner
+	uptime       *docker.UptimeMonitor
+	footprint    *docker.FootprintSampler
 }
 
+// Log WebSockets replay the 50 most recent logs unless the client asks to resume
+// after a sequence number, with {"since": n} as its first message or ?since=n.
+// Resuming replays at most replayLimit logs; sequence numbers tell of any gap
+const (
+	logBufferSize = 1000
+	replayLimit   = 500
+	replayWait    = time.Second
+)
+
 // NewDeploymentsHandler creates a new deployments handler
-func NewDeploymentsHandler(db *sql.DB, dockerClient *client.Client, config *config.Config) *DeploymentsHandler {
+func NewDeploymentsHandler(db *sql.DB, dockerClient *client.Client, config *config.Config, sockets *sockets.Manager, certificates *certs.Manager) *DeploymentsHandler {
+	compose := docker.NewComposeManager("./deployments", time.Duration(config.Docker.ComposeTimeout)*time.Second)
+	swarm := docker.NewSwarmManager(dockerClient, "./deployments", time.Duration(config.Docker.ComposeTimeout)*time.Second)
+	backups := backup.NewManager(db, dockerClient, config.Backup, "./deployments")
+	safety := backup.NewSafetyBackups(backups, config.Backup.Safety)
+	updater := docker.NewAutoUpdater(db, dockerClient, docker.NewUpdateChecker(db, dockerClient), compose, swarm)
+	updater.SetBeforeUpgrade(safety.BeforeUpgrade)
+
+	githubClient, err := github.ResolveClient(db, config)
+	if err != nil {
+		slog.Warn("GitHub credentials unavailable, using token", "error", err)
+		githubClient = github.NewClient(config.GitHub.Token)
+	}
+
 	return &DeploymentsHandler{
 		db:           db,
 		dockerClient: dockerClient,
 		config:       config,
-		compose:      docker.NewComposeManager("./deployments", time.Duration(config.Docker.ComposeTimeout)*time.Second),
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true }, // Allow all origins for demo
-		},
+		compose:      compose,
+		swarm:        swarm,
+		repos:        github.NewRepositoryService(githubClient, db),
+		updater:      updater,
+		ports:        docker.NewPortChecker(dockerClient),
+		tasks:        tasks.NewTracker(db),
+		webhooks:     webhooks.NewPublisher(db),
+		analytics:    analytics.NewRecorder(db),
+		backups:      backups,
+		safety:       safety,
+		sockets:      sockets,
+		trash:        docker.NewTrash(db, dockerClient, "./deployments", config.Trash.RetentionDays),
+		quotas:       quotas.NewEnforcer(db, dockerClient, config.Quotas),
+		sbom:         docker.NewSBOMGenerator(dockerClient),
+		logs:         logstream.NewHub(logBufferSize, bus.Default()),
+		resources:    docker.NewResourceChecker(dockerClient, "./deployments"),
+		platform:     docker.NewHostPlatform(dockerClient),
+		certificates: certificates,
+		hooks:        docker.NewHookRunner(dockerClient),
+		uptime:       docker.NewUptimeMonitor(db, dockerClient, config.Monitoring.Uptime),
+		footprint:    docker.NewFootprintSampler(db, dockerClient, config.Monitoring.Footprint),
 	}
 }
 
 // List returns all deployments
 func (h *DeploymentsHandler) List(w http.ResponseWriter, r *http.Request) {
 	status := r.URL.Query().Get("status")
+	project := r.URL.Query().Get("project")
 	limit := getIntParam(r, "limit", 50)
 	offset := getIntParam(r, "offset", 0)
 
 	query := `
-		SELECT d.id, d.template_id, d.stack_name, d.status, d.config, d.newt_injected,
-		       d.tunnel_url, d.created_at, d.updated_at, t.name as template_name
+		SELECT d.id, d.template_id, d.stack_name, COALESCE(d.project_id, 'global'), d.status, d.deploy_mode, d.config,
+		       d.newt_injected, COALESCE(d.tunnel_url, ''), COALESCE(d.resource_version, 1), d.created_at, d.updated_at,
+		       t.name as template_name
 		FROM deployments d
 		LEFT JOIN templates t ON d.template_id = t.id
 		WHERE 1=1`
@@ -59,6 +135,12 @@ func (h *DeploymentsHandler) List(w http.ResponseWriter, r *http.Request) {
 		args = append(args, status)
 	}
 
+	if project != "" {
+		argCount++
+		query += fmt.Sprintf(" AND COALESCE(d.project_id, 'global') = $%d", argCount)
+		args = append(args, project)
+	}
+
 	query += " ORDER BY d.created_at DESC"
 	argCount++
 	query += fmt.Sprintf(" LIMIT $%d", argCount)
@@ -81,8 +163,8 @@ func (h *DeploymentsHandler) List(w http.ResponseWriter, r *http.Request) {
 		var configJSON, templateName string
 
 		err := rows.Scan(
-			&d.ID, &d.TemplateID, &d.StackName, &d.Status, &configJSON,
-			&d.NewtInjected, &d.TunnelURL, &d.CreatedAt, &d.UpdatedAt, &templateName,
+			&d.ID, &d.TemplateID, &d.StackName, &d.ProjectID, &d.Status, &d.DeployMode, &configJSON,
+			&d.NewtInjected, &d.TunnelURL, &d.ResourceVersion, &d.CreatedAt, &d.UpdatedAt, &templateName,
 		)
 		if err != nil {
 			continue
@@ -95,10 +177,13 @@ func (h *DeploymentsHandler) List(w http.ResponseWriter, r *http.Request) {
 			"template_id":   d.TemplateID,
 			"template_name": templateName,
 			"stack_name":    d.StackName,
+			"project_id":    d.ProjectID,
 			"status":        d.Status,
+			"deploy_mode":   d.DeployMode,
 			"config":        d.Config,
 			"newt_injected": d.NewtInjected,
 			"tunnel_url":    d.TunnelURL,
+			"etag":          d.ETag(),
 			"created_at":    d.CreatedAt,
 			"updated_at":    d.UpdatedAt,
 			"is_running":    d.IsRunning(),
@@ -134,89 +219,57 @@ func (h *DeploymentsHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if template exists
-	var template models.Template
-	var tagsJSON, variablesJSON, newtConfigJSON string
-	err := h.db.QueryRow(`
-		SELECT id, name, description, requires_newt, variables, newt_config
-		FROM templates WHERE id = $1`, req.TemplateID).Scan(
-		&template.ID, &template.Name, &template.Description,
-		&template.RequiresNewt, &variablesJSON, &newtConfigJSON,
-	)
-
-	if err == sql.ErrNoRows {
-		http.Error(w, "Template not found", http.StatusNotFound)
+	var projectExists bool
+	h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1)", req.ProjectID).Scan(&projectExists)
+	if !projectExists {
+		http.Error(w, models.ErrProjectNotFound.Error(), http.StatusBadRequest)
 		return
 	}
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+
+	// The route addresses no project, so the role is checked against the request's
+	if !h.canOperate(r, req.ProjectID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
-	template.UnmarshalVariables(variablesJSON)
-	template.UnmarshalNewtConfig(newtConfigJSON)
+	template, derr := h.prepareDeployment(&req)
+	if derr != nil {
+		derr.write(w)
+		return
+	}
+	req.RequestedBy = requestedBy(r)
 
 	// Check if stack name is unique
 	var existingID string
-	err = h.db.QueryRow("SELECT id FROM deployments WHERE stack_name = $1", req.StackName).Scan(&existingID)
+	err := h.db.QueryRow("SELECT id FROM deployments WHERE stack_name = $1", req.StackName).Scan(&existingID)
 	if err != sql.ErrNoRows {
 		http.Error(w, "Stack name already exists", http.StatusConflict)
 		return
 	}
 
-	// Generate deployment ID
-	deploymentID := fmt.Sprintf("deploy_%d", time.Now().Unix())
-
-	// Create deployment record
-	deployment := &models.Deployment{
-		ID:           deploymentID,
-		TemplateID:   req.TemplateID,
-		StackName:    req.StackName,
-		Status:       models.StatusPending,
-		NewtInjected: req.IncludeNewt,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
-	}
-
-	// Set configuration
-	deployment.Config = map[string]interface{}{
-		"environment":  req.Environment,
-		"auto_start":   req.AutoStart,
-		"include_newt": req.IncludeNewt,
-	}
-
-	if req.NewtConfig != nil {
-		deployment.Config["newt_config"] = req.NewtConfig
-	}
-
-	// Save to database
-	configJSON, _ := deployment.MarshalConfig()
-	_, err = h.db.Exec(`
-		INSERT INTO deployments (id, template_id, stack_name, status, config, newt_injected, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-		deployment.ID, deployment.TemplateID, deployment.StackName, deployment.Status,
-		configJSON, deployment.NewtInjected, deployment.CreatedAt, deployment.UpdatedAt,
-	)
-
+	deployment, taskID, err := h.startDeployment(logging.FromContext(r.Context()), &req, template)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create deployment: %v", err), http.StatusInternalServerError)
+		writeStartError(w, err)
 		return
 	}
 
-	// Start deployment process in background
-	go h.performDeployment(deployment, &template, &req)
-
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", deployment.ETag())
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"id":         deployment.ID,
-		"stack_name": deployment.StackName,
-		"status":     deployment.Status,
-		"message":    "Deployment started",
+		"id":          deployment.ID,
+		"stack_name":  deployment.StackName,
+		"status":      deployment.Status,
+		"deploy_mode": deployment.DeployMode,
+		"etag":        deployment.ETag(),
+		"task_id":     taskID,
+		"warnings":    deployment.Warnings,
+		"message":     "Deployment started",
 	})
 }
 
-// Get returns a specific deployment
+// Get returns a specific deployment. Its ETag header is the concurrency token to
+// send as If-Match when updating it with PUT /deployments/name/{stack}
 func (h *DeploymentsHandler) Get(w http.ResponseWriter, r *http.Request) {
 	deploymentID := chi.URLParam(r, "id")
 	if deploymentID == "" {
@@ -224,19 +277,32 @@ func (h *DeploymentsHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.writeDeployment(w, "d.id = $1", deploymentID)
+}
+
+// GetByName returns the deployment of a stack
+func (h *DeploymentsHandler) GetByName(w http.ResponseWriter, r *http.Request) {
+	h.writeDeployment(w, "d.stack_name = $1", chi.URLParam(r, "stack"))
+}
+
+// writeDeployment writes the deployment matching where as the response
+func (h *DeploymentsHandler) writeDeployment(w http.ResponseWriter, where string, arg string) {
 	var d models.Deployment
 	var configJSON, templateName string
 
 	query := `
-		SELECT d.id, d.template_id, d.stack_name, d.status, d.config, d.newt_injected,
-		       d.tunnel_url, d.created_at, d.updated_at, t.name as template_name
+		SELECT d.id, d.template_id, d.stack_name, COALESCE(d.project_id, 'global'), d.status, d.deploy_mode, d.config,
+		       d.newt_injected, COALESCE(d.tunnel_url, ''), COALESCE(d.update_policy, 'pinned'), COALESCE(d.update_schedule, ''),
+		       COALESCE(d.tunnel_provider, 'newt'), COALESCE(d.resource_version, 1),
+		       d.created_at, d.updated_at, t.name as template_name
 		FROM deployments d
 		LEFT JOIN templates t ON d.template_id = t.id
-		WHERE d.id = $1`
+		WHERE ` + where
 
-	err := h.db.QueryRow(query, deploymentID).Scan(
-		&d.ID, &d.TemplateID, &d.StackName, &d.Status, &configJSON,
-		&d.NewtInjected, &d.TunnelURL, &d.CreatedAt, &d.UpdatedAt, &templateName,
+	err := h.db.QueryRow(query, arg).Scan(
+		&d.ID, &d.TemplateID, &d.StackName, &d.ProjectID, &d.Status, &d.DeployMode, &configJSON,
+		&d.NewtInjected, &d.TunnelURL, &d.UpdatePolicy, &d.UpdateSchedule,
+		&d.TunnelProvider, &d.ResourceVersion, &d.CreatedAt, &d.UpdatedAt, &templateName,
 	)
 
 	if err == sql.ErrNoRows {
@@ -255,10 +321,16 @@ func (h *DeploymentsHandler) Get(w http.ResponseWriter, r *http.Request) {
 		"template_id":   d.TemplateID,
 		"template_name": templateName,
 		"stack_name":    d.StackName,
+		"project_id":    d.ProjectID,
 		"status":        d.Status,
+		"deploy_mode":   d.DeployMode,
+		"update_policy": d.UpdatePolicy,
+		"update_schedule": d.UpdateSchedule,
 		"config":        d.Config,
 		"newt_injected": d.NewtInjected,
+		"tunnel_provider": d.TunnelProvider,
 		"tunnel_url":    d.TunnelURL,
+		"etag":          d.ETag(),
 		"created_at":    d.CreatedAt,
 		"updated_at":    d.UpdatedAt,
 		"is_running":    d.IsRunning(),
@@ -266,11 +338,34 @@ func (h *DeploymentsHandler) Get(w http.ResponseWriter, r *http.Request) {
 		"can_stop":      d.CanStop(),
 	}
 
+	// Backups are shown on the detail page but never fail it
+	if backups, err := h.deploymentBackups(d.ID, 10); err == nil {
+		response["backups"] = backups
+	}
+
+	// Uptime probes are shown on the detail page but never fail it
+	if probes, err := h.uptime.List(d.ID); err == nil {
+		response["probes"] = probes
+	}
+
+	// So is the estimated footprint over the last week
+	if h.config.Monitoring.Footprint.Enabled {
+		if window, err := parseWindow(footprintWindow); err == nil {
+			if footprint, err := h.footprint.Estimate(d.ID, footprintWindow, window); err == nil {
+				response["footprint"] = footprint
+			}
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", d.ETag())
 	json.NewEncoder(w).Encode(response)
 }
 
-// Delete removes a deployment
+// Delete removes a deployment with its containers and networks. Volumes and images
+// are kept unless remove_volumes or remove_images is set, and the deployment
+// directory is removed unless keep_files is set; the options are read from the
+// JSON body or the query. The response lists what was actually removed
 func (h *DeploymentsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	deploymentID := chi.URLParam(r, "id")
 	if deploymentID == "" {
@@ -278,10 +373,16 @@ func (h *DeploymentsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	options, err := parseDeleteOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get deployment info
 	var stackName string
-	var status models.DeploymentStatus
-	err := h.db.QueryRow("SELECT stack_name, status FROM deployments WHERE id = $1", deploymentID).Scan(&stackName, &status)
+	var deployMode models.DeployMode
+	err = h.db.QueryRow("SELECT stack_name, deploy_mode FROM deployments WHERE id = $1", deploymentID).Scan(&stackName, &deployMode)
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Deployment not found", http.StatusNotFound)
@@ -292,64 +393,225 @@ func (h *DeploymentsHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Stop and remove the stack if it's running
-	if status == models.StatusRunning {
-		if err := h.compose.Down(stackName, true); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to stop stack: %v", err), http.StatusInternalServerError)
-			return
-		}
+	summary, item, err := h.destroyDeployment(r.Context(), deploymentID, stackName, deployMode, options, requestedBy(r))
+	if errors.Is(err, errDestroyHookFailed) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
 	}
-
-	// Remove from database
-	_, err = h.db.Exec("DELETE FROM deployments WHERE id = $1", deploymentID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to delete deployment: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Also delete logs
-	h.db.Exec("DELETE FROM deployment_logs WHERE deployment_id = $1", deploymentID)
-
 	w.Header().Set("Content-Type", "application/json")
+	if item != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Deployment moved to trash",
+			"removed": summary,
+			"trash":   item,
+		})
+		return
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message": "Deployment deleted successfully",
+		"removed": summary,
 	})
 }
 
-// GetLogs returns deployment logs
+// errDestroyHookFailed reports that a pre-destroy hook failed, so the deployment
+// was kept
+var errDestroyHookFailed = errors.New("Pre-destroy hook failed")
+
+// destroyDeployment runs the destroy hooks of a deployment, takes its stack down
+// as the options select and moves it to the trash. Without the trash, or when the
+// deletion is permanent, the deployment is deleted instead and the returned trash
+// item is nil
+func (h *DeploymentsHandler) destroyDeployment(ctx context.Context, deploymentID, stackName string, deployMode models.DeployMode, options *models.DeploymentDeleteOptions, deletedBy string) (*models.DeploymentDeleteSummary, *models.TrashedDeployment, error) {
+	// Trashed deployments keep their volumes and files until they are purged
+	trashed := h.config.Trash.Enabled && !options.Permanent
+	if trashed {
+		options.RemoveVolumes, options.RemoveImages, options.KeepFiles = false, false, true
+	}
+
+	if !options.SkipHooks {
+		if err := h.runDestroyHooks(ctx, deploymentID, stackName); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", errDestroyHookFailed, err)
+		}
+	}
+
+	// Stop and remove the stack, whatever its status, so its volumes can go too
+	summary, err := h.teardownStack(stackName, deployMode, options)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to delete deployment: %w", err)
+	}
+
+	if trashed {
+		item, err := h.trash.Add(deploymentID, deletedBy, summary.KeptVolumes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to move deployment to trash: %w", err)
+		}
+
+		h.sockets.CloseTopic(deploymentID, "deployment deleted")
+		slog.Info("Deployment moved to trash", "deployment_id", deploymentID, "stack", stackName, "expires_at", item.ExpiresAt)
+		return summary, item, nil
+	}
+
+	// Remove from database
+	if _, err := h.db.Exec("DELETE FROM deployments WHERE id = $1", deploymentID); err != nil {
+		return nil, nil, fmt.Errorf("Failed to delete deployment: %w", err)
+	}
+
+	// Also delete logs
+	h.db.Exec("DELETE FROM deployment_logs WHERE deployment_id = $1", deploymentID)
+	h.logs.Remove(deploymentID)
+	h.sockets.CloseTopic(deploymentID, "deployment deleted")
+	slog.Info("Deployment deleted", "deployment_id", deploymentID, "stack", stackName,
+		"volumes_removed", len(summary.Volumes), "volumes_kept", len(summary.KeptVolumes), "files_removed", summary.FilesRemoved)
+	return summary, nil, nil
+}
+
+// parseDeleteOptions reads deployment delete options from the JSON body, if
+// any, then from query parameters, which take precedence
+func parseDeleteOptions(r *http.Request) (*models.DeploymentDeleteOptions, error) {
+	options := &models.DeploymentDeleteOptions{}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(options); err != nil {
+			return nil, fmt.Errorf("Invalid JSON")
+		}
+	}
+
+	for name, target := range map[string]*bool{
+		"remove_volumes": &options.RemoveVolumes,
+		"remove_images":  &options.RemoveImages,
+		"keep_files":     &options.KeepFiles,
+		"permanent":      &options.Permanent,
+		"skip_hooks":     &options.SkipHooks,
+	} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s: expected true or false", name)
+		}
+		*target = parsed
+	}
+	return options, nil
+}
+
+// teardownStack removes a deployment's stack as the options select and reports
+// what was removed, comparing the stack's resources before and after. Failing
+// to remove volumes or images is reported in the summary, not as an error
+func (h *DeploymentsHandler) teardownStack(stackName string, deployMode models.DeployMode, options *models.DeploymentDeleteOptions) (*models.DeploymentDeleteSummary, error) {
+	ctx := context.Background()
+	before, err := docker.ListStackResources(ctx, h.dockerClient, stackName, deployMode)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	if deployMode == models.DeployModeSwarm {
+		if len(before.Containers) > 0 || len(before.Networks) > 0 {
+			if err := h.swarm.Remove(stackName); err != nil {
+				return nil, fmt.Errorf("failed to remove swarm stack: %w", err)
+			}
+		}
+		errs = docker.RemoveSwarmLeftovers(ctx, h.dockerClient, before, options.RemoveVolumes, options.RemoveImages)
+	} else if err := h.compose.Remove(stackName, options.RemoveVolumes, options.RemoveImages); err != nil {
+		return nil, fmt.Errorf("failed to stop stack: %w", err)
+	}
+
+	after, err := docker.ListStackResources(ctx, h.dockerClient, stackName, deployMode)
+	if err != nil {
+		return nil, err
+	}
+	summary := before.Removed(ctx, h.dockerClient, after)
+	summary.Errors = errs
+
+	if !options.KeepFiles {
+		if err := h.compose.RemoveFiles(stackName); err != nil {
+			summary.Errors = append(summary.Errors, fmt.Sprintf("files: %v", err))
+		} else {
+			summary.FilesRemoved = true
+		}
+	}
+	return summary, nil
+}
+
+// GetLogs returns deployment logs filtered by level=, q= (with regex=true for a
+// regular expression), since= and until=. With format=txt or format=json all
+// matching logs are streamed as a download in chronological order
 func (h *DeploymentsHandler) GetLogs(w http.ResponseWriter, r *http.Request) {
 	deploymentID := chi.URLParam(r, "id")
-	limit := getIntParam(r, "limit", 100)
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "txt" && format != "json" {
+		http.Error(w, "Format must be 'txt' or 'json'", http.StatusBadRequest)
+		return
+	}
 
-	query := `
-		SELECT log_level, message, timestamp
-		FROM deployment_logs 
-		WHERE deployment_id = $1
-		ORDER BY timestamp DESC
-		LIMIT $2`
+	filter, err := parseLogFilter(r, format == "")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	rows, err := h.db.Query(query, deploymentID, limit)
+	query, args := buildLogQuery(deploymentID, filter, format == "")
+	rows, err := h.db.Query(query, args...)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
 
-	var logs []models.DeploymentLog
-	for rows.Next() {
-		var log models.DeploymentLog
-		err := rows.Scan(&log.LogLevel, &log.Message, &log.Timestamp)
-		if err != nil {
-			continue
+	// Regular expressions are matched here, so the limit is applied while reading
+	next := func() (*models.DeploymentLog, bool) {
+		for rows.Next() {
+			var log models.DeploymentLog
+			if err := rows.Scan(&log.ID, &log.LogLevel, &log.Message, &log.Timestamp); err != nil {
+				continue
+			}
+			if filter.MatchMessage(log.Message) {
+				log.DeploymentID = deploymentID
+				return &log, true
+			}
 		}
-		log.DeploymentID = deploymentID
-		logs = append(logs, log)
+		return nil, false
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"logs": logs,
-	})
+	filename := fmt.Sprintf("%s-logs-%s", deploymentID, time.Now().Format("20060102-150405"))
+	switch format {
+	case "txt":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".txt"))
+		for log, ok := next(); ok; log, ok = next() {
+			fmt.Fprintf(w, "%s [%s] %s\n", log.Timestamp.Format(time.RFC3339), log.LogLevel, log.Message)
+		}
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		encoder := json.NewEncoder(w)
+		fmt.Fprint(w, "[")
+		first := true
+		for log, ok := next(); ok; log, ok = next() {
+			if !first {
+				fmt.Fprint(w, ",")
+			}
+			first = false
+			encoder.Encode(log)
+		}
+		fmt.Fprint(w, "]")
+	default:
+		logs := []models.DeploymentLog{}
+		for log, ok := next(); ok && (filter.Limit == 0 || len(logs) < filter.Limit); log, ok = next() {
+			logs = append(logs, *log)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"logs": logs,
+		})
+	}
 }
 
 // StreamLogs streams deployment logs via HTTP (for non-WebSocket clients)
@@ -391,33 +653,44 @@ func (h *DeploymentsHandler) WebSocketLogs(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Upgrade to WebSocket
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+	// Upgrade to WebSocket; refusals are answered by the manager
+	conn, err := h.sockets.Upgrade(w, r, deploymentID)
 	if err != nil {
-		http.Error(w, "Failed to upgrade to WebSocket", http.StatusBadRequest)
 		return
 	}
 	defer conn.Close()
 
-	// Send recent logs first
-	logs, _ := h.getRecentLogs(deploymentID, 50)
-	for _, log := range logs {
-		message := map[string]interface{}{
-			"timestamp": log.Timestamp,
-			"level":     log.LogLevel,
-			"message":   log.Message,
+	// Subscribe before replaying, so no log written in between is missed
+	updates, unsubscribe := h.logs.Subscribe(deploymentID)
+	defer unsubscribe()
+
+	lastID, limit := 0, 50
+	if since, err := strconv.Atoi(r.URL.Query().Get("since")); err == nil {
+		lastID, limit = since, replayLimit
+	} else {
+		select {
+		case message := <-conn.Messages():
+			var request struct {
+				Since *int `json:"since"`
+			}
+			if json.Unmarshal(message, &request) == nil && request.Since != nil {
+				lastID, limit = *request.Since, replayLimit
+			}
+		case <-time.After(replayWait):
+		case <-conn.Context().Done():
+			return
 		}
-		conn.WriteJSON(message)
 	}
 
-	// Keep connection alive and send new logs
-	// This is a simplified implementation - in production you'd use a proper pub/sub system
+	// Replay, then send what is written since whenever the hub signals
 	for {
-		time.Sleep(1 * time.Second)
-		// Check for new logs (this is inefficient, use pub/sub in production)
-		newLogs, _ := h.getRecentLogs(deploymentID, 5)
-		for _, log := range newLogs {
+		logs, err := h.logsAfter(deploymentID, lastID, limit)
+		if err != nil {
+			slog.Warn("Failed to read deployment logs for WebSocket", "deployment_id", deploymentID, "error", err)
+		}
+		for _, log := range logs {
 			message := map[string]interface{}{
+				"sequence":  log.ID,
 				"timestamp": log.Timestamp,
 				"level":     log.LogLevel,
 				"message":   log.Message,
@@ -425,6 +698,14 @@ func (h *DeploymentsHandler) WebSocketLogs(w http.ResponseWriter, r *http.Reques
 			if err := conn.WriteJSON(message); err != nil {
 				return // Connection closed
 			}
+			lastID = log.ID
+		}
+		limit = replayLimit
+
+		select {
+		case <-updates:
+		case <-conn.Context().Done():
+			return
 		}
 	}
 }
@@ -435,7 +716,7 @@ func (h *DeploymentsHandler) GetTunnelInfo(w http.ResponseWriter, r *http.Reques
 
 	var tunnelURL string
 	var newt_injected bool
-	err := h.db.QueryRow("SELECT tunnel_url, newt_injected FROM deployments WHERE id = $1", deploymentID).Scan(&tunnelURL, &newt_injected)
+	err := h.db.QueryRow("SELECT COALESCE(tunnel_url, ''), newt_injected FROM deployments WHERE id = $1", deploymentID).Scan(&tunnelURL, &newt_injected)
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Deployment not found", http.StatusNotFound)
@@ -457,52 +738,876 @@ func (h *DeploymentsHandler) GetTunnelInfo(w http.ResponseWriter, r *http.Reques
 	json.NewEncoder(w).Encode(response)
 }
 
-// CreateBackup creates a backup of the deployment
+// CreateBackup creates a backup of the deployment's record, compose and .env files
+// and, optionally, its volumes. The body is optional
 func (h *DeploymentsHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "Deployment backup not implemented", http.StatusNotImplemented)
+	deploymentID := chi.URLParam(r, "id")
+
+	var req struct {
+		Name           string `json:"name"`
+		IncludeVolumes bool   `json:"include_volumes"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var stackName string
+	err := h.db.QueryRow("SELECT stack_name FROM deployments WHERE id = $1", deploymentID).Scan(&stackName)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = fmt.Sprintf("%s-%s", stackName, time.Now().Format("20060102-150405"))
+	}
+
+	if err := h.quotas.CheckBackup(r.Context(), []string{deploymentID}, requestedBy(r)); err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+
+	b, taskID, err := h.backups.CreateBackup(&models.BackupConfig{
+		Name:           req.Name,
+		Type:           models.BackupTypeManual,
+		IncludeVolumes: req.IncludeVolumes,
+		Deployments:    []models.DeploymentBackup{{ID: deploymentID, StackName: stackName}},
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create backup: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":            b.ID,
+		"name":          b.Name,
+		"status":        b.Status,
+		"deployment_id": deploymentID,
+		"task_id":       taskID,
+		"message":       "Backup started",
+	})
+}
+
+// deploymentBackups returns the most recent backups that include a deployment
+func (h *DeploymentsHandler) deploymentBackups(deploymentID string, limit int) ([]models.Backup, error) {
+	rows, err := h.db.Query(`
+		SELECT id, name, type, status, size_bytes, include_volumes, created_at, completed_at
+		FROM backups
+		WHERE EXISTS (SELECT 1 FROM json_each(backups.deployment_ids) WHERE value = $1)
+		ORDER BY created_at DESC
+		LIMIT $2`, deploymentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	backups := []models.Backup{}
+	for rows.Next() {
+		var b models.Backup
+		var completedAt sql.NullTime
+		if err := rows.Scan(&b.ID, &b.Name, &b.Type, &b.Status, &b.SizeBytes, &b.IncludeVolumes,
+			&b.CreatedAt, &completedAt); err != nil {
+			return nil, err
+		}
+		if completedAt.Valid {
+			b.CompletedAt = &completedAt.Time
+		}
+		b.DeploymentIDs = []string{deploymentID}
+		backups = append(backups, b)
+	}
+	return backups, rows.Err()
+}
+
+// SetUpdatePolicy configures automatic image updates for a deployment
+func (h *DeploymentsHandler) SetUpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	deploymentID := chi.URLParam(r, "id")
+
+	var req models.UpdatePolicyConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Schedule != "" {
+		if err := docker.ValidateSchedule(req.Schedule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE deployments SET update_policy = $1, update_schedule = $2, updated_at = $3,
+		       resource_version = COALESCE(resource_version, 1) + 1
+		WHERE id = $4`, req.Policy, req.Schedule, time.Now(), deploymentID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Update policy saved successfully",
+		"policy":   req.Policy,
+		"schedule": req.Schedule,
+	})
+}
+
+// GetRevisions returns the upgrade history of a deployment
+func (h *DeploymentsHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	deploymentID := chi.URLParam(r, "id")
+
+	revisions, err := h.updater.GetRevisions(deploymentID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"revisions": revisions,
+		"total":     len(revisions),
+	})
+}
+
+// CheckPorts reports host ports of a compose file that are already in use, optionally
+// returning the file with conflicting ports remapped
+func (h *DeploymentsHandler) CheckPorts(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		StackName   string            `json:"stack_name"`
+		Compose     string            `json:"compose"`
+		Environment map[string]string `json:"environment"`
+		Remap       bool              `json:"remap"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	content, conflicts, err := h.ports.Check(req.StackName, []byte(req.Compose), req.Environment, req.Remap)
+	var conflictErr *docker.PortConflictError
+	if err != nil && !errors.As(err, &conflictErr) {
+		http.Error(w, fmt.Sprintf("Port check failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"ok":        err == nil,
+		"conflicts": conflicts,
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	} else if len(conflicts) > 0 {
+		response["compose"] = string(content)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
 }
 
-// performDeployment handles the actual deployment process
-func (h *DeploymentsHandler) performDeployment(deployment *models.Deployment, template *models.Template, config *models.DeploymentConfig) {
+// performDeployment handles the actual deployment process. logger carries the
+// request ID of the request that created the deployment and taskID is the task
+// reporting its progress
+func (h *DeploymentsHandler) performDeployment(logger *slog.Logger, taskID string, deployment *models.Deployment, template *models.Template, config *models.DeploymentConfig) {
+	logger = logger.With("deployment_id", deployment.ID, "stack", deployment.StackName)
+	logger.Info("Starting deployment", "mode", deployment.DeployMode)
+	startedAt := time.Now()
+
 	// Update status to deploying
 	h.updateDeploymentStatus(deployment.ID, models.StatusDeploying)
 	h.addDeploymentLog(deployment.ID, "info", "Starting deployment process")
+	if deployment.IsSwarm() {
+		h.addDeploymentLog(deployment.ID, "info", "Deploying as swarm stack")
+	}
+
+	if len(template.ConfigFiles) > 0 {
+		if err := h.compose.WriteConfigFiles(deployment.StackName, template.ConfigFiles, configFileVars(template, config.Environment)); err != nil {
+			h.failDeployment(logger, taskID, deployment, startedAt, err)
+			return
+		}
+		h.addDeploymentLog(deployment.ID, "info", fmt.Sprintf("Rendered %d config files", len(template.ConfigFiles)))
+	}
+
+	hooks := append(append([]models.LifecycleHook{}, template.Hooks...), config.Hooks...)
+	if err := h.runDeployHooks(deployment, models.HookPreDeploy, hooks, config.Environment); err != nil {
+		h.failDeployment(logger, taskID, deployment, startedAt, err)
+		return
+	}
 
-	// TODO: Implement actual deployment logic:
-	// 1. Fetch docker-compose.yml from GitHub
-	// 2. Inject Newt service if needed
-	// 3. Create environment file
-	// 4. Deploy using docker compose
-	// 5. Monitor deployment status
-	// 6. Update database with final status
+	h.tasks.Progress(taskID, 10, "Starting services")
+	if err := h.deployStack(deployment, template, config); err != nil {
+		h.failDeployment(logger, taskID, deployment, startedAt, err)
+		return
+	}
 
-	// Simulate deployment process
-	time.Sleep(5 * time.Second)
+	if err := h.runDeployHooks(deployment, models.HookPostDeploy, hooks, config.Environment); err != nil {
+		h.failDeployment(logger, taskID, deployment, startedAt, err)
+		return
+	}
 
-	// For now, just mark as successful
 	h.updateDeploymentStatus(deployment.ID, models.StatusRunning)
 	h.addDeploymentLog(deployment.ID, "info", "Deployment completed successfully")
+	h.tasks.Finish(taskID, nil)
+	h.analytics.RecordDeployment(deployment.TemplateID, deployment.ID, time.Since(startedAt), nil)
+	logger.Info("Deployment completed")
+}
+
+// deployStack generates the compose file of a deployment from its template and
+// brings the stack up, with docker compose or as a swarm stack
+func (h *DeploymentsHandler) deployStack(deployment *models.Deployment, template *models.Template, config *models.DeploymentConfig) error {
+	content, err := h.composeContent(template.ID, deployment.StackName)
+	if err != nil {
+		return err
+	}
+
+	options := docker.StackComposeOptions{
+		StackName: deployment.StackName,
+		Network:   config.Network,
+		Proxy:     config.Proxy,
+		Variables: config.Environment,
+	}
+	if config.IncludeNewt {
+		if options.Tunnel, err = docker.NewTunnelProvider(config.TunnelProvider, config.NewtConfig, config.TunnelConfig); err != nil {
+			return err
+		}
+	}
+	if content, err = docker.GenerateStackCompose(content, options); err != nil {
+		return err
+	}
+	// Another network may have taken the subnet since the request was accepted
+	if config.Network != nil && config.Network.Subnet != "" {
+		if err := docker.CheckSubnet(context.Background(), h.dockerClient, deployment.StackName, config.Network.Subnet); err != nil {
+			return err
+		}
+	}
+
+	if deployment.IsSwarm() {
+		// docker stack deploy reads no .env file, so variables are substituted now
+		if content, err = docker.Interpolate(content, config.Environment); err != nil {
+			return err
+		}
+		if err := h.compose.WriteStackCompose(deployment.StackName, content); err != nil {
+			return err
+		}
+		return h.swarm.Deploy(deployment.StackName)
+	}
 
-	// Set tunnel URL if newt is injected
-	if deployment.NewtInjected {
-		tunnelURL := fmt.Sprintf("https://%s.tunnel.example.com", deployment.StackName)
-		h.updateTunnelURL(deployment.ID, tunnelURL)
+	if err := h.compose.WriteStackCompose(deployment.StackName, content); err != nil {
+		return err
+	}
+	deployOptions := docker.DeployOptions{
+		StackName:  deployment.StackName,
+		EnvVars:    config.Environment,
+		Detached:   true,
+		PullImages: true,
+		Ports:      h.ports,
+		RemapPorts: config.RemapPorts,
 	}
+	if template.Resources != nil {
+		deployOptions.GPU = template.Resources.GPU
+	}
+	if config.TLS != nil {
+		certDir, err := h.certificates.Dir(config.TLS.CertificateID)
+		if err != nil {
+			return fmt.Errorf("failed to locate certificate %s: %w", config.TLS.CertificateID, err)
+		}
+		deployOptions.TLS = config.TLS
+		deployOptions.TLSCertDir = certDir
+	}
+	return h.compose.Deploy(deployOptions)
+}
+
+// composeContent fetches the compose file of a template. Templates without a
+// repository, such as imported ones, fall back to the file the stack was last
+// deployed from
+func (h *DeploymentsHandler) composeContent(templateID, stackName string) ([]byte, error) {
+	content, err := h.repos.GetDockerComposeContent(templateID)
+	if err == nil {
+		return content, nil
+	}
+	if deployed, readErr := h.compose.ReadComposeFile(stackName); readErr == nil {
+		return deployed, nil
+	}
+	return nil, fmt.Errorf("failed to fetch compose file: %w", err)
+}
+
+// configFileVars returns the variables config files are rendered with: the
+// environment of a deployment, with template variables left unset as empty
+func configFileVars(template *models.Template, env map[string]string) map[string]string {
+	vars := make(map[string]string, len(env)+len(template.Variables))
+	for _, variable := range template.Variables {
+		vars[variable.Name] = ""
+	}
+	for key, value := range env {
+		vars[key] = value
+	}
+	return vars
+}
+
+// runDeployHooks runs the lifecycle hooks of a phase for a deployment, streaming
+// their output to its logs
+func (h *DeploymentsHandler) runDeployHooks(deployment *models.Deployment, phase models.HookPhase, hooks []models.LifecycleHook, env map[string]string) error {
+	if len(models.HooksForPhase(hooks, phase)) == 0 {
+		return nil
+	}
+	h.addDeploymentLog(deployment.ID, "info", fmt.Sprintf("Running %s hooks", phase))
+	return h.hooks.RunPhase(context.Background(), deployment.StackName, phase, hooks, env, func(hook models.LifecycleHook, line string) {
+		h.addDeploymentLog(deployment.ID, "info", fmt.Sprintf("[%s] %s", hook.DisplayName(), line))
+	})
+}
+
+// failDeployment marks a deployment that could not be brought up as failed
+func (h *DeploymentsHandler) failDeployment(logger *slog.Logger, taskID string, deployment *models.Deployment, startedAt time.Time, err error) {
+	h.updateDeploymentStatus(deployment.ID, models.StatusFailed)
+	h.addDeploymentLog(deployment.ID, "error", fmt.Sprintf("Deployment failed: %v", err))
+	h.tasks.Finish(taskID, err)
+	h.analytics.RecordDeployment(deployment.TemplateID, deployment.ID, time.Since(startedAt), err)
+	logger.Error("Deployment failed", "error", err)
+}
+
+// runDestroyHooks runs the pre_destroy hooks of a deployment, those of its
+// template then its own, before its stack is torn down
+func (h *DeploymentsHandler) runDestroyHooks(ctx context.Context, deploymentID, stackName string) error {
+	var templateID, configJSON, hooksJSON string
+	err := h.db.QueryRow(`
+		SELECT COALESCE(d.template_id, ''), COALESCE(d.config, ''), COALESCE(t.lifecycle_hooks, '')
+		FROM deployments d
+		LEFT JOIN templates t ON t.id = d.template_id
+		WHERE d.id = $1`, deploymentID).Scan(&templateID, &configJSON, &hooksJSON)
+	if err != nil {
+		return err
+	}
+
+	template := models.Template{}
+	if err := template.UnmarshalHooks(hooksJSON); err != nil {
+		return fmt.Errorf("invalid lifecycle hooks of template %s: %w", templateID, err)
+	}
+	config := models.DeploymentConfig{}
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+			return fmt.Errorf("invalid deployment config: %w", err)
+		}
+	}
+
+	hooks := append(template.Hooks, config.Hooks...)
+	return h.hooks.RunPhase(ctx, stackName, models.HookPreDestroy, hooks, config.Environment, func(hook models.LifecycleHook, line string) {
+		h.addDeploymentLog(deploymentID, "info", fmt.Sprintf("[%s] %s", hook.DisplayName(), line))
+	})
 }
 
 // Helper functions
+
+// parseLogFilter reads deployment log filters from query parameters. since and until
+// accept RFC 3339 times or durations before now such as 15m or 24h
+func parseLogFilter(r *http.Request, paged bool) (*models.DeploymentLogFilter, error) {
+	params := r.URL.Query()
+	filter := &models.DeploymentLogFilter{
+		Query: params.Get("q"),
+		Regex: params.Get("regex") == "true",
+		Limit: getIntParam(r, "limit", 0),
+	}
+	if paged {
+		filter.Limit = getIntParam(r, "limit", 100)
+	}
+
+	if levels := params.Get("level"); levels != "" {
+		filter.Levels = strings.Split(levels, ",")
+	}
+
+	for name, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := params.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			ago, durationErr := time.ParseDuration(value)
+			if durationErr != nil {
+				return nil, fmt.Errorf("invalid %s: use an RFC 3339 time or a duration such as 1h", name)
+			}
+			t = time.Now().Add(-ago)
+		}
+		*target = &t
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// buildLogQuery builds a deployment log query served by the (deployment_id, timestamp)
+// index. Substring searches are done in SQL; regular expressions are matched by the
+// caller, so the limit is only pushed down without one
+func buildLogQuery(deploymentID string, filter *models.DeploymentLogFilter, newestFirst bool) (string, []interface{}) {
+	query := `
+		SELECT id, log_level, message, timestamp
+		FROM deployment_logs
+		WHERE deployment_id = $1`
+	args := []interface{}{deploymentID}
+
+	if len(filter.Levels) > 0 {
+		placeholders := make([]string, len(filter.Levels))
+		for i, level := range filter.Levels {
+			args = append(args, level)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		query += fmt.Sprintf(" AND log_level IN (%s)", strings.Join(placeholders, ", "))
+	}
+
+	// Timestamps are written in local time, so bounds are compared in local time too
+	if filter.Since != nil {
+		args = append(args, filter.Since.Local())
+		query += fmt.Sprintf(" AND timestamp >= $%d", len(args))
+	}
+	if filter.Until != nil {
+		args = append(args, filter.Until.Local())
+		query += fmt.Sprintf(" AND timestamp <= $%d", len(args))
+	}
+
+	if filter.Query != "" && !filter.Regex {
+		args = append(args, strings.ToLower(filter.Query))
+		query += fmt.Sprintf(" AND instr(lower(message), $%d) > 0", len(args))
+	}
+
+	if newestFirst {
+		query += " ORDER BY timestamp DESC, id DESC"
+	} else {
+		query += " ORDER BY timestamp ASC, id ASC"
+	}
+
+	if filter.Limit > 0 && !filter.Regex {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return query, args
+}
+
+// deploymentError is a rejected deployment request and the status to report it with
+type deploymentError struct {
+	status  int
+	message string
+	fields  []models.VariableError
+}
+
+func (e *deploymentError) Error() string {
+	return e.message
+}
+
+// write reports the error as an HTTP response
+func (e *deploymentError) write(w http.ResponseWriter) {
+	if len(e.fields) == 0 {
+		http.Error(w, e.message, e.status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  e.message,
+		"fields": e.fields,
+	})
+}
+
+// prepareDeployment checks a validated deployment request against the tunnel provider
+// and swarm configuration, loads its template and fills in environment defaults
+func (h *DeploymentsHandler) prepareDeployment(req *models.DeploymentConfig) (*models.Template, *deploymentError) {
+	if req.IncludeNewt {
+		provider, err := docker.NewTunnelProvider(req.TunnelProvider, req.NewtConfig, req.TunnelConfig)
+		if err == nil {
+			err = provider.Validate()
+		}
+		if err != nil {
+			return nil, &deploymentError{status: http.StatusBadRequest, message: fmt.Sprintf("Validation error: %v", err)}
+		}
+	}
+
+	if req.DeployMode == models.DeployModeSwarm {
+		if !h.config.Docker.SwarmEnabled {
+			return nil, &deploymentError{status: http.StatusBadRequest, message: "Swarm mode is disabled"}
+		}
+		active, err := h.swarm.IsSwarmActive()
+		if err != nil {
+			return nil, &deploymentError{status: http.StatusInternalServerError, message: fmt.Sprintf("Failed to check swarm status: %v", err)}
+		}
+		if !active {
+			return nil, &deploymentError{status: http.StatusBadRequest, message: "Docker engine is not a swarm manager"}
+		}
+	}
+
+	// Check if template exists
+	var template models.Template
+	var variablesJSON, newtConfigJSON, resourcesJSON, architecturesJSON, hooksJSON, configFilesJSON string
+	err := h.db.QueryRow(`
+		SELECT id, name, description, requires_newt, variables, newt_config, COALESCE(resources, ''),
+		       COALESCE(architectures, ''), COALESCE(lifecycle_hooks, ''), COALESCE(config_files, '')
+		FROM templates WHERE id = $1`, req.TemplateID).Scan(
+		&template.ID, &template.Name, &template.Description,
+		&template.RequiresNewt, &variablesJSON, &newtConfigJSON, &resourcesJSON, &architecturesJSON, &hooksJSON,
+		&configFilesJSON,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, &deploymentError{status: http.StatusNotFound, message: "Template not found"}
+	}
+	if err != nil {
+		return nil, &deploymentError{status: http.StatusInternalServerError, message: fmt.Sprintf("Database error: %v", err)}
+	}
+
+	template.UnmarshalVariables(variablesJSON)
+	template.UnmarshalNewtConfig(newtConfigJSON)
+	template.UnmarshalResources(resourcesJSON)
+	template.UnmarshalArchitectures(architecturesJSON)
+	template.UnmarshalHooks(hooksJSON)
+	template.UnmarshalConfigFiles(configFilesJSON)
+
+	// Images not published for the host's architecture fail to pull or crash on start
+	if arch := h.platform.Architecture(context.Background()); !req.IgnoreArchitecture && !template.SupportsArchitecture(arch) {
+		supported := strings.Join(template.Architectures, ", ")
+		if supported == "" {
+			supported = "none"
+		}
+		return nil, &deploymentError{
+			status: http.StatusUnprocessableEntity,
+			message: fmt.Sprintf("Template images do not run on the host's %s architecture (published for %s); set ignore_architecture to deploy anyway",
+				arch, supported),
+		}
+	}
+
+	if req.Environment == nil {
+		req.Environment = make(map[string]string)
+	}
+	if fieldErrors := template.ValidateEnvironment(req.Environment); len(fieldErrors) > 0 {
+		return nil, &deploymentError{
+			status:  http.StatusUnprocessableEntity,
+			message: "Invalid environment values",
+			fields:  fieldErrors,
+		}
+	}
+
+	// Render config files now so a bad template fails the request, not the deploy
+	for _, file := range template.ConfigFiles {
+		if _, err := docker.RenderConfigFile(file, configFileVars(&template, req.Environment)); err != nil {
+			return nil, &deploymentError{status: http.StatusUnprocessableEntity, message: err.Error()}
+		}
+	}
+
+	if req.TLS != nil {
+		if derr := h.checkTLS(req); derr != nil {
+			return nil, derr
+		}
+	}
+	if req.Network != nil {
+		if derr := h.checkNetworkAllowList(req.Network); derr != nil {
+			return nil, derr
+		}
+		if req.Network.Subnet != "" {
+			err := docker.CheckSubnet(context.Background(), h.dockerClient, req.StackName, req.Network.Subnet)
+			if errors.Is(err, docker.ErrSubnetInUse) {
+				return nil, &deploymentError{status: http.StatusConflict, message: err.Error()}
+			}
+			if err != nil {
+				return nil, &deploymentError{status: http.StatusInternalServerError, message: err.Error()}
+			}
+		}
+	}
+	if !req.RemapPorts && req.DeployMode != models.DeployModeSwarm {
+		if derr := h.checkHostPorts(req); derr != nil {
+			return nil, derr
+		}
+	}
+
+	return &template, nil
+}
+
+// checkHostPorts rejects a deployment whose compose file publishes host ports
+// already in use. They are checked again right before the stack is brought up
+func (h *DeploymentsHandler) checkHostPorts(req *models.DeploymentConfig) *deploymentError {
+	content, err := h.composeContent(req.TemplateID, req.StackName)
+	if err != nil {
+		return &deploymentError{status: http.StatusBadGateway, message: err.Error()}
+	}
+
+	_, _, err = h.ports.Check(req.StackName, content, req.Environment, false)
+	var conflictErr *docker.PortConflictError
+	if errors.As(err, &conflictErr) {
+		return &deploymentError{status: http.StatusConflict, message: err.Error()}
+	}
+	if err != nil {
+		return &deploymentError{status: http.StatusBadRequest, message: fmt.Sprintf("Port check failed: %v", err)}
+	}
+	return nil
+}
+
+// checkNetworkAllowList checks that the stacks a deployment is allowed to reach
+// are isolated, as only those have a network to join
+func (h *DeploymentsHandler) checkNetworkAllowList(policy *models.NetworkPolicy) *deploymentError {
+	for _, stack := range policy.Allow {
+		var isolated bool
+		err := h.db.QueryRow(`
+			SELECT EXISTS(SELECT 1 FROM deployments
+			              WHERE stack_name = $1 AND json_valid(config)
+			                AND json_extract(config, '$.network.isolation') = $2)`,
+			stack, models.NetworkIsolated).Scan(&isolated)
+		if err != nil {
+			return &deploymentError{status: http.StatusInternalServerError, message: fmt.Sprintf("Database error: %v", err)}
+		}
+		if !isolated {
+			return &deploymentError{
+				status:  http.StatusUnprocessableEntity,
+				message: fmt.Sprintf("Stack %s is not an isolated deployment; only isolated stacks can be allowed", stack),
+			}
+		}
+	}
+	return nil
+}
+
+// checkTLS checks that the certificate of a TLS sidecar is issued and covers the
+// hostnames of its routes. Hostnames still referencing variables once the
+// environment is applied are checked when deploying
+func (h *DeploymentsHandler) checkTLS(req *models.DeploymentConfig) *deploymentError {
+	if req.DeployMode == models.DeployModeSwarm {
+		return &deploymentError{status: http.StatusBadRequest, message: "TLS sidecars are only supported for compose deployments"}
+	}
+
+	cert, err := h.certificates.Get(req.TLS.CertificateID)
+	if err == models.ErrCertificateNotFound {
+		return &deploymentError{status: http.StatusBadRequest, message: fmt.Sprintf("Validation error: %v", err)}
+	}
+	if err != nil {
+		return &deploymentError{status: http.StatusInternalServerError, message: fmt.Sprintf("Database error: %v", err)}
+	}
+	if !cert.Valid() {
+		return &deploymentError{status: http.StatusUnprocessableEntity, message: models.ErrCertificateNotIssued.Error()}
+	}
+
+	for _, route := range req.TLS.Routes {
+		hostname, err := docker.Interpolate([]byte(route.Hostname), req.Environment)
+		if err != nil || strings.Contains(string(hostname), "$") {
+			continue
+		}
+		if !cert.Covers(strings.TrimSpace(string(hostname))) {
+			return &deploymentError{
+				status:  http.StatusUnprocessableEntity,
+				message: fmt.Sprintf("Certificate %s does not cover %s", cert.ID, hostname),
+			}
+		}
+	}
+	return nil
+}
+
+// deploymentConfigMap builds the stored configuration of a deployment request
+func deploymentConfigMap(req *models.DeploymentConfig) map[string]interface{} {
+	config := map[string]interface{}{
+		"environment":  req.Environment,
+		"auto_start":   req.AutoStart,
+		"include_newt": req.IncludeNewt,
+		"remap_ports":  req.RemapPorts,
+	}
+
+	if req.NewtConfig != nil {
+		config["newt_config"] = req.NewtConfig
+	}
+	if req.TunnelConfig != nil {
+		config["tunnel_config"] = req.TunnelConfig
+	}
+	if req.Proxy != nil {
+		config["proxy"] = req.Proxy
+	}
+	if req.TLS != nil {
+		config["tls"] = req.TLS
+	}
+	if req.Network != nil {
+		config["network"] = req.Network
+	}
+	if len(req.Hooks) > 0 {
+		config["hooks"] = req.Hooks
+	}
+	return config
+}
+
+// requestedBy returns the ID of the signed-in user of a request, empty without
+// authentication
+func requestedBy(r *http.Request) string {
+	if user := apiMiddleware.UserFromContext(r.Context()); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+// canOperate reports whether the caller of a request may change the deployments
+// of a project. Roles are only known when authentication is enabled
+func (h *DeploymentsHandler) canOperate(r *http.Request, projectID string) bool {
+	user := apiMiddleware.UserFromContext(r.Context())
+	return user == nil || apiMiddleware.HasProjectRole(h.db, user, projectID, "operator")
+}
+
+// startDeployment records a new deployment and starts deploying it in the background
+func (h *DeploymentsHandler) startDeployment(logger *slog.Logger, req *models.DeploymentConfig, template *models.Template) (*models.Deployment, string, error) {
+	if err := h.quotas.CheckDeployment(context.Background(), req.ProjectID, req.RequestedBy); err != nil {
+		return nil, "", err
+	}
+
+	// Generate deployment ID
+	deploymentID := fmt.Sprintf("deploy_%d", time.Now().UnixNano())
+
+	// Create deployment record
+	deployment := &models.Deployment{
+		ID:             deploymentID,
+		TemplateID:     req.TemplateID,
+		StackName:      req.StackName,
+		ProjectID:      req.ProjectID,
+		Status:         models.StatusPending,
+		DeployMode:     req.DeployMode,
+		NewtInjected:   req.IncludeNewt,
+		TunnelProvider: req.TunnelProvider,
+		Config:         deploymentConfigMap(req),
+		ResourceVersion: 1,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+
+	// Save to database
+	configJSON, _ := deployment.MarshalConfig()
+	_, err := h.db.Exec(`
+		INSERT INTO deployments (id, template_id, stack_name, project_id, status, deploy_mode, config, newt_injected,
+		                         tunnel_provider, resource_version, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		deployment.ID, deployment.TemplateID, deployment.StackName, deployment.ProjectID, deployment.Status, deployment.DeployMode,
+		configJSON, deployment.NewtInjected, deployment.TunnelProvider, deployment.ResourceVersion, req.RequestedBy,
+		deployment.CreatedAt, deployment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	h.analytics.RecordInstall(deployment.TemplateID, req.RequestedBy)
+
+	// A host short of the template's requirements may still run it; warn only
+	deployment.Warnings = h.resources.Check(context.Background(), template.Resources)
+	for _, warning := range deployment.Warnings {
+		h.addDeploymentLog(deployment.ID, "warn", warning)
+	}
+
+	h.webhooks.Publish(models.WebhookEventDeploymentCreated, map[string]interface{}{
+		"deployment_id": deployment.ID,
+		"stack_name":    deployment.StackName,
+		"project_id":    deployment.ProjectID,
+		"template_id":   deployment.TemplateID,
+		"deploy_mode":   deployment.DeployMode,
+	})
+
+	taskID := h.launchDeployment(logger, deployment, template, req, "Deploying "+deployment.StackName)
+	return deployment, taskID, nil
+}
+
+// writeStartError reports a deployment that could not be started, rejecting it
+// when a quota is exceeded
+func writeStartError(w http.ResponseWriter, err error) {
+	var quotaErr *quotas.ExceededError
+	if errors.As(err, &quotaErr) {
+		http.Error(w, quotaErr.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, fmt.Sprintf("Failed to create deployment: %v", err), http.StatusInternalServerError)
+}
+
+// launchDeployment starts deploying a recorded deployment in the background and
+// returns the ID of the task tracking it
+func (h *DeploymentsHandler) launchDeployment(logger *slog.Logger, deployment *models.Deployment, template *models.Template, req *models.DeploymentConfig, message string) string {
+	taskID := h.tasks.Start(models.TaskTypeDeployment, deployment.ID, message)
+	go h.performDeployment(logger, taskID, deployment, template, req)
+	return taskID
+}
+
 func (h *DeploymentsHandler) updateDeploymentStatus(deploymentID string, status models.DeploymentStatus) {
 	h.db.Exec("UPDATE deployments SET status = $1, updated_at = $2 WHERE id = $3",
 		status, time.Now(), deploymentID)
+
+	if status == models.StatusFailed {
+		var stackName string
+		h.db.QueryRow("SELECT stack_name FROM deployments WHERE id = $1", deploymentID).Scan(&stackName)
+		h.webhooks.Publish(models.WebhookEventDeploymentFailed, map[string]interface{}{
+			"deployment_id": deploymentID,
+			"stack_name":    stackName,
+		})
+	}
 }
 
 func (h *DeploymentsHandler) addDeploymentLog(deploymentID, level, message string) {
-	h.db.Exec("INSERT INTO deployment_logs (deployment_id, log_level, message, timestamp) VALUES ($1, $2, $3, $4)",
-		deploymentID, level, message, time.Now())
+	now := time.Now()
+	result, err := h.db.Exec("INSERT INTO deployment_logs (deployment_id, log_level, message, timestamp) VALUES ($1, $2, $3, $4)",
+		deploymentID, level, message, now)
+	if err != nil {
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return
+	}
+	h.logs.Publish(models.DeploymentLog{
+		ID:           int(id),
+		DeploymentID: deploymentID,
+		LogLevel:     level,
+		Message:      message,
+		Timestamp:    now,
+	})
 }
 
-func (h *DeploymentsHandler) updateTunnelURL(deploymentID, tunnelURL string) {
-	h.db.Exec("UPDATE deployments SET tunnel_url = $1 WHERE id = $2", tunnelURL, deploymentID)
+// logsAfter returns the logs written after the log with ID afterID, from the
+// hub's buffer when it reaches back far enough
+func (h *DeploymentsHandler) logsAfter(deploymentID string, afterID, limit int) ([]models.DeploymentLog, error) {
+	if logs, ok := h.logs.After(deploymentID, afterID, limit); ok {
+		return logs, nil
+	}
+	return h.getLogsAfter(deploymentID, afterID, limit)
+}
+
+// getLogsAfter returns the logs of a deployment written after the log with ID
+// afterID, oldest first. With no ID the newest limit logs are returned
+func (h *DeploymentsHandler) getLogsAfter(deploymentID string, afterID, limit int) ([]models.DeploymentLog, error) {
+	query := `
+		SELECT id, log_level, message, timestamp FROM (
+			SELECT id, log_level, message, timestamp
+			FROM deployment_logs
+			WHERE deployment_id = $1 AND id > $2
+			ORDER BY id DESC
+			LIMIT $3
+		) ORDER BY id`
+
+	rows, err := h.db.Query(query, deploymentID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []models.DeploymentLog
+	for rows.Next() {
+		var log models.DeploymentLog
+		if err := rows.Scan(&log.ID, &log.LogLevel, &log.Message, &log.Timestamp); err != nil {
+			return nil, err
+		}
+		log.DeploymentID = deploymentID
+		logs = append(logs, log)
+	}
+
+	return logs, rows.Err()
 }
 
 func (h *DeploymentsHandler) getRecentLogs(deploymentID string, limit int) ([]models.DeploymentLog, error) {
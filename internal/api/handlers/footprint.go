@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// footprintWindow is the window footprints are estimated over unless ?window=
+// sets another
+const footprintWindow = "7d"
+
+// GetFootprint estimates the monthly CPU, memory and energy footprint of a
+// deployment from its resource samples over a window, 7 days unless ?window=
+// sets another
+func (h *DeploymentsHandler) GetFootprint(w http.ResponseWriter, r *http.Request) {
+	deploymentID := chi.URLParam(r, "id")
+
+	var stackName string
+	err := h.db.QueryRow("SELECT stack_name FROM deployments WHERE id = $1", deploymentID).Scan(&stackName)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	name, window, ok := footprintWindowParam(w, r)
+	if !ok {
+		return
+	}
+	footprint, err := h.footprint.Estimate(deploymentID, name, window)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	footprint.StackName = stackName
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(footprint)
+}
+
+// GetFootprint estimates the monthly footprint of the deployments of a project,
+// with that of each deployment
+func (h *ProjectsHandler) GetFootprint(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+	_, err := h.getProject(projectID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	name, window, ok := footprintWindowParam(w, r)
+	if !ok {
+		return
+	}
+	footprint, err := h.footprint.EstimateProject(projectID, name, window)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(footprint)
+}
+
+// footprintWindowParam reads the ?window= of a footprint request, writing the
+// error response if it is invalid
+func footprintWindowParam(w http.ResponseWriter, r *http.Request) (string, time.Duration, bool) {
+	name := r.URL.Query().Get("window")
+	if name == "" {
+		name = footprintWindow
+	}
+	window, err := parseWindow(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", 0, false
+	}
+	return name, window, true
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/github"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/moderation"
+)
+
+// GitHubHandler handles GitHub integration HTTP requests
+type GitHubHandler struct {
+	db          *sql.DB
+	config      *config.Config
+	credentials *github.CredentialStore
+
+	mu     sync.RWMutex
+	client *github.Client
+	syncer *github.SyncService
+}
+
+// NewGitHubHandler creates a new GitHub handler
+func NewGitHubHandler(db *sql.DB, config *config.Config) *GitHubHandler {
+	client, err := github.ResolveClient(db, config)
+	if err != nil {
+		slog.Warn("GitHub credentials unavailable, using token", "error", err)
+		client = github.NewClient(config.GitHub.Token)
+	}
+
+	return &GitHubHandler{
+		db:          db,
+		config:      config,
+		credentials: github.NewCredentialStore(db, config.Security),
+		client:      client,
+		syncer:      newSyncService(client, db, config),
+	}
+}
+
+// newSyncService creates a sync service that checks template descriptions
+// against the content policy after each sync
+func newSyncService(client *github.Client, db *sql.DB, config *config.Config) *github.SyncService {
+	syncer := github.NewSyncService(client, db)
+	syncer.SetMediaDir(mediaDir(config))
+	syncer.SetAfterSync(moderation.NewModerator(db, config.Marketplace).ScanTemplates)
+	return syncer
+}
+
+// Connect validates a personal access token, stores it encrypted and starts periodic sync
+func (h *GitHubHandler) Connect(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Token = strings.TrimSpace(req.Token)
+	if req.Token == "" {
+		http.Error(w, "Token required", http.StatusBadRequest)
+		return
+	}
+
+	client := github.NewClient(req.Token)
+	user, err := client.GetUser()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid GitHub token: %v", err), http.StatusUnauthorized)
+		return
+	}
+	scopes := client.Scopes()
+
+	if err := h.credentials.Save(req.Token, user, scopes); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to store token: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	h.useClient(client)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "GitHub account connected successfully",
+		"connected": true,
+		"user":      user,
+		"scopes":    scopes,
+	})
+}
+
+// Disconnect removes the stored token and stops periodic sync
+func (h *GitHubHandler) Disconnect(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.syncer.StopPeriodicSync()
+	h.mu.Unlock()
+
+	if err := h.credentials.Delete(); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Fall back to GitHub App or GITHUB_TOKEN credentials if configured
+	client, err := github.ResolveClient(h.db, h.config)
+	if err != nil {
+		client = github.NewClient(h.config.GitHub.Token)
+	}
+	h.mu.Lock()
+	h.client = client
+	h.syncer = newSyncService(client, h.db, h.config)
+	h.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":   "GitHub account disconnected successfully",
+		"connected": false,
+	})
+}
+
+// Status reports which credentials are in use, the connected account and sync state
+func (h *GitHubHandler) Status(w http.ResponseWriter, r *http.Request) {
+	_, creds, err := h.credentials.Load()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load credentials: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	h.mu.RLock()
+	client, syncer := h.client, h.syncer
+	h.mu.RUnlock()
+
+	source := "none"
+	switch {
+	case client.IsApp():
+		source = "app"
+	case creds != nil:
+		source = "connected"
+	case h.config.GitHub.Token != "":
+		source = "env"
+	}
+
+	status := map[string]interface{}{
+		"connected":    source != "none",
+		"source":       source,
+		"account":      creds,
+		"sync_running": syncer.IsRunning(),
+	}
+	if source != "none" {
+		if err := client.ValidateToken(); err != nil {
+			status["error"] = err.Error()
+		}
+		status["rate_limit"] = client.RateLimit()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// useClient switches to a newly connected client and restarts periodic sync with it
+func (h *GitHubHandler) useClient(client *github.Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.syncer.StopPeriodicSync()
+	h.client = client
+	h.syncer = newSyncService(client, h.db, h.config)
+	if h.config.GitHub.SyncInterval > 0 {
+		h.syncer.StartPeriodicSync(time.Duration(h.config.GitHub.SyncInterval) * time.Second)
+	}
+}
+
+// ListRepositories lists repositories of the connected account
+func (h *GitHubHandler) ListRepositories(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Repository listing not implemented", http.StatusNotImplemented)
+}
+
+// HandleWebhook handles GitHub webhook deliveries
+func (h *GitHubHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "GitHub webhooks not implemented", http.StatusNotImplemented)
+}
+
+// SyncRepositories synchronizes templates from the discovery sources
+func (h *GitHubHandler) SyncRepositories(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "Repository sync not implemented", http.StatusNotImplemented)
+}
+
+// ListSources returns the template discovery sources
+func (h *GitHubHandler) ListSources(w http.ResponseWriter, r *http.Request) {
+	sources, err := github.LoadSources(h.db, false)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sources": sources,
+	})
+}
+
+// CreateSource adds a template discovery source
+func (h *GitHubHandler) CreateSource(w http.ResponseWriter, r *http.Request) {
+	source := models.GitHubSource{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&source); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := source.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	include, exclude, err := source.MarshalPatterns()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode patterns: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	source.CreatedAt = time.Now()
+	result, err := h.db.Exec(`
+		INSERT INTO github_sources (type, value, include_patterns, exclude_patterns, enabled, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		source.Type, source.Value, include, exclude, source.Enabled, source.CreatedAt,
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create source: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if id, err := result.LastInsertId(); err == nil {
+		source.ID = int(id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(source)
+}
+
+// UpdateSource replaces a template discovery source
+func (h *GitHubHandler) UpdateSource(w http.ResponseWriter, r *http.Request) {
+	sourceID := chi.URLParam(r, "id")
+
+	var source models.GitHubSource
+	if err := json.NewDecoder(r.Body).Decode(&source); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := source.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	include, exclude, err := source.MarshalPatterns()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode patterns: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE github_sources
+		SET type = $1, value = $2, include_patterns = $3, exclude_patterns = $4, enabled = $5
+		WHERE id = $6`,
+		source.Type, source.Value, include, exclude, source.Enabled, sourceID,
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update source: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Source not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Source updated successfully",
+	})
+}
+
+// DeleteSource removes a template discovery source
+func (h *GitHubHandler) DeleteSource(w http.ResponseWriter, r *http.Request) {
+	sourceID := chi.URLParam(r, "id")
+
+	_, err := h.db.Exec("DELETE FROM github_sources WHERE id = $1", sourceID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete source: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Source deleted successfully",
+	})
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/migration"
+	"docker-deploy-app/internal/models"
+)
+
+// MigrationHandler handles moving a whole instance to another host
+type MigrationHandler struct {
+	db        *sql.DB
+	config    *config.Config
+	migrator   *migration.Migrator
+	portainer  *migration.PortainerImporter
+	composeDir *migration.ComposeDirImporter
+}
+
+// NewMigrationHandler creates a new migration handler
+func NewMigrationHandler(db *sql.DB, dockerClient *client.Client, config *config.Config) *MigrationHandler {
+	workDir := filepath.Join(config.Backup.Storage.Path, "migration")
+
+	return &MigrationHandler{
+		db:         db,
+		config:     config,
+		migrator:   migration.NewMigrator(db, dockerClient, "./deployments", workDir),
+		portainer:  migration.NewPortainerImporter(db, dockerClient, "./deployments"),
+		composeDir: migration.NewComposeDirImporter(db, dockerClient, "./deployments"),
+	}
+}
+
+// Export streams a migration bundle of this instance. Volume data is included
+// with ?volumes=true
+func (h *MigrationHandler) Export(w http.ResponseWriter, r *http.Request) {
+	includeVolumes := r.URL.Query().Get("volumes") == "true"
+
+	// Bundles with volumes can take far longer than the server write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"migration_%s.tar.gz\"", time.Now().Format("20060102_150405")))
+	w.Header().Set("Content-Type", "application/gzip")
+
+	if err := h.migrator.Export(w, includeVolumes); err != nil {
+		// Headers are already sent; the truncated bundle fails to import
+		slog.Error("Failed to export migration bundle", "error", err)
+	}
+}
+
+// Import rehydrates this instance from a migration bundle sent as the request body
+func (h *MigrationHandler) Import(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	result, err := h.migrator.Import(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Import failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("Imported migration bundle",
+		"deployments", len(result.Deployments), "volumes", len(result.Volumes), "skipped", len(result.Skipped))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Migration bundle imported; redeploy stacks to start them on this host",
+		"result":  result,
+	})
+}
+
+// ImportPortainer imports the stacks and app templates of a Portainer
+// instance, read from its API or sent as exported JSON. A bare array is taken
+// as Portainer's stack list
+func (h *MigrationHandler) ImportPortainer(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var req models.PortainerImportRequest
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &req.Stacks)
+	} else {
+		err = json.Unmarshal(body, &req)
+	}
+	if err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.portainer.Import(r.Context(), &req, requestedBy(r))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Import failed: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	slog.Info("Imported from Portainer", "source", req.URL, "templates", len(result.Templates),
+		"deployments", len(result.Deployments), "skipped", len(result.Skipped), "dry_run", req.DryRun)
+
+	message := "Portainer stacks and templates imported; redeploy stacks to manage them from here"
+	if req.DryRun {
+		message = "Dry run; nothing was imported"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"result":  result,
+	})
+}
+
+// ImportComposeDir adopts the compose projects in the subdirectories of a host
+// folder as deployments, without restarting their running services
+func (h *MigrationHandler) ImportComposeDir(w http.ResponseWriter, r *http.Request) {
+	var req models.ComposeDirImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.composeDir.Import(r.Context(), &req, requestedBy(r))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Import failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	slog.Info("Imported compose folder", "path", req.Path,
+		"deployments", len(result.Deployments), "skipped", len(result.Skipped), "dry_run", req.DryRun)
+
+	message := "Compose projects adopted; running services were left as they are"
+	if req.DryRun {
+		message = "Dry run; nothing was adopted"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"result":  result,
+	})
+}
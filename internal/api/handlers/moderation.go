@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/moderation"
+)
+
+// ModerationHandler handles the queue of reviews and template descriptions
+// breaking the content policy
+type ModerationHandler struct {
+	db        *sql.DB
+	config    *config.Config
+	moderator *moderation.Moderator
+}
+
+// NewModerationHandler creates a new moderation handler
+func NewModerationHandler(db *sql.DB, config *config.Config) *ModerationHandler {
+	return &ModerationHandler{
+		db:        db,
+		config:    config,
+		moderator: moderation.NewModerator(db, config.Marketplace),
+	}
+}
+
+// List returns the moderation queue, pending items unless the status query
+// parameter asks for approved, rejected or all items
+func (h *ModerationHandler) List(w http.ResponseWriter, r *http.Request) {
+	status := models.ModerationStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "":
+		status = models.ModerationPending
+	case "all":
+		status = ""
+	case models.ModerationPending, models.ModerationApproved, models.ModerationRejected:
+	default:
+		http.Error(w, "Validation error: status must be pending, approved, rejected or all", http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.moderator.List(status, getIntParam(r, "limit", 100))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items": items,
+		"total": len(items),
+	})
+}
+
+// Approve publishes a queued review, or keeps a queued template description
+func (h *ModerationHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, true)
+}
+
+// Reject hides a queued review, or clears a queued template description
+func (h *ModerationHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.decide(w, r, false)
+}
+
+func (h *ModerationHandler) decide(w http.ResponseWriter, r *http.Request, approve bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, models.ErrModerationItemNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	item, err := h.moderator.Decide(id, approve, requestedBy(r))
+	switch err {
+	case nil:
+	case models.ErrModerationItemNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case models.ErrModerationDecided:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"item":    item,
+		"message": fmt.Sprintf("Item %s", item.Status),
+	})
+}
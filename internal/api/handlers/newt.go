@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/newt"
+)
+
+// NewtHandler handles Newt tunnel configuration requests
+type NewtHandler struct {
+	db        *sql.DB
+	config    *config.Config
+	validator *newt.Validator
+	compose   *docker.ComposeManager
+	swarm     *docker.SwarmManager
+}
+
+// NewNewtHandler creates a new newt handler
+func NewNewtHandler(db *sql.DB, dockerClient *client.Client, config *config.Config) *NewtHandler {
+	return &NewtHandler{
+		db:        db,
+		config:    config,
+		validator: newt.NewValidator(10 * time.Second),
+		compose:   docker.NewComposeManager("./deployments", time.Duration(config.Docker.ComposeTimeout)*time.Second),
+		swarm:     docker.NewSwarmManager(dockerClient, "./deployments", time.Duration(config.Docker.ComposeTimeout)*time.Second),
+	}
+}
+
+// GetConfig returns the active Newt configuration with the secret masked
+func (h *NewtHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	nc, err := h.getActiveConfig()
+	if err == sql.ErrNoRows {
+		http.Error(w, "Newt is not configured", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", newtConfigETag(nc.ID))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":          nc.ID,
+		"endpoint":    nc.Endpoint,
+		"newt_id":     nc.NewtID,
+		"newt_secret": "********",
+		"is_active":   nc.IsActive,
+		"etag":        newtConfigETag(nc.ID),
+		"created_at":  nc.CreatedAt,
+	})
+}
+
+// UpdateConfig stores a new Newt configuration and makes it active
+func (h *NewtHandler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
+	var nc models.NewtConfig
+	if err := json.NewDecoder(r.Body).Decode(&nc); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := nc.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := h.activateConfig(&nc, 0)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", newtConfigETag(int(id)))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      id,
+		"etag":    newtConfigETag(int(id)),
+		"message": "Newt configuration saved successfully",
+	})
+}
+
+// PutConfig idempotently sets the active Newt configuration: resubmitting the active
+// configuration changes nothing. If-Match makes the change conditional on the ETag
+// last read, and If-None-Match: * makes it succeed only when Newt is not configured
+func (h *NewtHandler) PutConfig(w http.ResponseWriter, r *http.Request) {
+	var nc models.NewtConfig
+	if err := json.NewDecoder(r.Body).Decode(&nc); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := nc.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, err := h.getActiveConfig()
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	etag := ""
+	if current != nil {
+		etag = newtConfigETag(current.ID)
+	}
+	if !checkPreconditions(w, r, etag) {
+		return
+	}
+
+	status, message := http.StatusOK, "Newt configuration unchanged"
+	id := int64(0)
+	switch {
+	case current != nil && current.Endpoint == nc.Endpoint && current.NewtID == nc.NewtID && current.Secret == nc.Secret:
+		id = int64(current.ID)
+	default:
+		expectedID := 0
+		if current != nil {
+			expectedID = current.ID
+		} else {
+			status = http.StatusCreated
+		}
+
+		id, err = h.activateConfig(&nc, expectedID)
+		if err == errVersionConflict {
+			http.Error(w, "Precondition failed: Newt configuration was modified concurrently", http.StatusPreconditionFailed)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		message = "Newt configuration saved successfully"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", newtConfigETag(int(id)))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      id,
+		"etag":    newtConfigETag(int(id)),
+		"message": message,
+	})
+}
+
+// activateConfig stores a Newt configuration as the active one. A non-zero
+// expectedID makes the swap fail with errVersionConflict unless that
+// configuration is still the active one
+func (h *NewtHandler) activateConfig(nc *models.NewtConfig, expectedID int) (int64, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if expectedID != 0 {
+		result, err := tx.Exec("UPDATE newt_configs SET is_active = 0 WHERE id = $1 AND is_active = 1", expectedID)
+		if err != nil {
+			return 0, err
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return 0, errVersionConflict
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE newt_configs SET is_active = 0"); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO newt_configs (endpoint, newt_id, newt_secret, is_active, created_at)
+		VALUES ($1, $2, $3, 1, $4)`,
+		nc.Endpoint, nc.NewtID, nc.Secret, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// newtConfigETag returns the concurrency token of a Newt configuration. Every
+// change stores a new row, so the row ID identifies the version
+func newtConfigETag(id int) string {
+	return fmt.Sprintf(`"newt.%d"`, id)
+}
+
+// RotateSecret validates a new secret, stores it and rolls it out to running stacks
+func (h *NewtHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	var req models.NewtSecretRotation
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Secret == "" {
+		http.Error(w, "Newt secret is required", http.StatusBadRequest)
+		return
+	}
+
+	active, err := h.getActiveConfig()
+	if err == sql.ErrNoRows {
+		http.Error(w, "Newt is not configured", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Only store a secret Pangolin accepts, so running tunnels are never broken by a typo
+	candidate := *active
+	candidate.Secret = req.Secret
+	validation := h.validator.Validate(&candidate)
+	if !validation.Authenticated {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":      "New secret was not accepted by the endpoint",
+			"validation": validation,
+		})
+		return
+	}
+
+	// Store the rotated secret as a new version so the configuration's ETag changes
+	id, err := h.activateConfig(&candidate, active.ID)
+	if err == errVersionConflict {
+		http.Error(w, "Newt configuration was modified concurrently", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	rollout, err := h.rolloutSecret(active.NewtID, req.Secret)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", newtConfigETag(int(id)))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Newt secret rotated successfully",
+		"etag":    newtConfigETag(int(id)),
+		"rollout": rollout,
+	})
+}
+
+// ValidateConfig tests a Newt configuration against its Pangolin endpoint
+func (h *NewtHandler) ValidateConfig(w http.ResponseWriter, r *http.Request) {
+	var nc models.NewtConfig
+	if err := json.NewDecoder(r.Body).Decode(&nc); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result := h.validator.Validate(&nc)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetStatus returns whether Newt is configured
+func (h *NewtHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
+	nc, err := h.getActiveConfig()
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"enabled":    h.config.Newt.Enabled,
+		"configured": err == nil && nc.IsConfigured(),
+	}
+	if err == nil {
+		response["endpoint"] = nc.Endpoint
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// TestConnection tests the active Newt configuration against its Pangolin endpoint
+func (h *NewtHandler) TestConnection(w http.ResponseWriter, r *http.Request) {
+	nc, err := h.getActiveConfig()
+	if err == sql.ErrNoRows {
+		http.Error(w, "Newt is not configured", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	result := h.validator.Validate(nc)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// rolloutSecret writes the new secret into every running stack using the given Newt ID
+// and recreates only its newt service
+func (h *NewtHandler) rolloutSecret(newtID, secret string) ([]models.NewtRolloutStatus, error) {
+	rows, err := h.db.Query(`
+		SELECT id, stack_name, deploy_mode FROM deployments
+		WHERE newt_injected = 1 AND status = 'running'`)
+	if err != nil {
+		return nil, err
+	}
+
+	var deployments []models.Deployment
+	for rows.Next() {
+		var d models.Deployment
+		if err := rows.Scan(&d.ID, &d.StackName, &d.DeployMode); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deployments = append(deployments, d)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rollout := []models.NewtRolloutStatus{}
+	for _, d := range deployments {
+		status := models.NewtRolloutStatus{
+			DeploymentID: d.ID,
+			StackName:    d.StackName,
+			Status:       models.RolloutStatusUpdated,
+		}
+
+		env, err := h.compose.GetServiceEnv(d.StackName, "newt")
+		if err == nil && env["NEWT_ID"] != newtID {
+			status.Status = models.RolloutStatusSkipped
+			status.Error = "stack uses a different Newt ID"
+			rollout = append(rollout, status)
+			continue
+		}
+
+		if err == nil {
+			err = h.compose.SetServiceEnv(d.StackName, "newt", "NEWT_SECRET", secret)
+		}
+		if err == nil {
+			if d.IsSwarm() {
+				err = h.swarm.Deploy(d.StackName)
+			} else {
+				err = h.compose.Recreate(d.StackName, "newt")
+			}
+		}
+		if err != nil {
+			status.Status = models.RolloutStatusFailed
+			status.Error = err.Error()
+		}
+
+		rollout = append(rollout, status)
+	}
+
+	return rollout, nil
+}
+
+// getActiveConfig loads the active Newt configuration
+func (h *NewtHandler) getActiveConfig() (*models.NewtConfig, error) {
+	var nc models.NewtConfig
+	err := h.db.QueryRow(`
+		SELECT id, endpoint, newt_id, newt_secret, is_active, created_at
+		FROM newt_configs
+		WHERE is_active = 1
+		ORDER BY created_at DESC LIMIT 1`).Scan(
+		&nc.ID, &nc.Endpoint, &nc.NewtID, &nc.Secret, &nc.IsActive, &nc.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &nc, nil
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/github"
+	"docker-deploy-app/internal/models"
+)
+
+const (
+	// overviewListSize is the number of recent failures and backups in the overview
+	overviewListSize = 5
+	// overviewTimeout bounds the Docker calls of an overview request
+	overviewTimeout = 10 * time.Second
+)
+
+// OverviewHandler serves the aggregate dashboard payload
+type OverviewHandler struct {
+	db           *sql.DB
+	dockerClient *client.Client
+	config       *config.Config
+	storage      *docker.StorageReporter
+}
+
+// NewOverviewHandler creates a new overview handler
+func NewOverviewHandler(db *sql.DB, dockerClient *client.Client, config *config.Config) *OverviewHandler {
+	return &OverviewHandler{
+		db:           db,
+		dockerClient: dockerClient,
+		config:       config,
+		storage: docker.NewStorageReporter(dockerClient, config.Backup.Storage.Path, config.Database.Path,
+			config.Monitoring.DiskWarningPercent, config.Monitoring.DiskCriticalPercent),
+	}
+}
+
+// Get returns everything the dashboard shows in one payload. Sections are collected
+// concurrently; a failing section is reported in errors instead of failing the request
+func (h *OverviewHandler) Get(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), overviewTimeout)
+	defer cancel()
+
+	overview := &models.Overview{
+		UnhealthyStacks: []models.UnhealthyStack{},
+		RecentFailures:  []models.DeploymentFailure{},
+		LatestBackups:   []models.Backup{},
+		GeneratedAt:     time.Now(),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	collect := func(section string, fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(); err != nil {
+				mu.Lock()
+				if overview.Errors == nil {
+					overview.Errors = make(map[string]string)
+				}
+				overview.Errors[section] = err.Error()
+				mu.Unlock()
+			}
+		}()
+	}
+
+	collect("deployments", func() (err error) {
+		overview.Deployments, err = h.deploymentCounts()
+		return err
+	})
+	collect("unhealthy_stacks", func() (err error) {
+		overview.UnhealthyStacks, err = h.unhealthyStacks(ctx)
+		return err
+	})
+	collect("recent_failures", func() (err error) {
+		overview.RecentFailures, err = h.recentFailures()
+		return err
+	})
+	collect("latest_backups", func() (err error) {
+		overview.LatestBackups, err = h.latestBackups()
+		return err
+	})
+	collect("tunnels", func() (err error) {
+		overview.Tunnels, err = h.tunnelSummary()
+		return err
+	})
+	collect("disk", func() (err error) {
+		overview.Disk, err = h.storage.Report(ctx)
+		return err
+	})
+	collect("last_sync", func() error {
+		result, err := github.LastSyncResult(h.db)
+		if err != nil && !strings.Contains(err.Error(), "no such table") {
+			return err
+		}
+		if result != nil {
+			overview.LastSync = result
+		}
+		return nil
+	})
+
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(overview)
+}
+
+// deploymentCounts counts deployments by status
+func (h *OverviewHandler) deploymentCounts() (models.DeploymentCounts, error) {
+	counts := models.DeploymentCounts{ByStatus: make(map[models.DeploymentStatus]int)}
+
+	rows, err := h.db.Query("SELECT status, COUNT(*) FROM deployments GROUP BY status")
+	if err != nil {
+		return counts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status models.DeploymentStatus
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return counts, err
+		}
+		counts.ByStatus[status] = count
+		counts.Total += count
+	}
+	return counts, rows.Err()
+}
+
+// unhealthyStacks groups containers failing their health check by managed stack
+func (h *OverviewHandler) unhealthyStacks(ctx context.Context) ([]models.UnhealthyStack, error) {
+	containers, err := h.dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("health", "unhealthy")),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byStack := make(map[string]*models.UnhealthyStack)
+	stacks := []models.UnhealthyStack{}
+	var order []string
+	for _, container := range containers {
+		stackName := container.Labels["com.docker.compose.project"]
+		if stackName == "" {
+			stackName = container.Labels["com.docker.stack.namespace"]
+		}
+		if stackName == "" {
+			continue
+		}
+
+		stack, ok := byStack[stackName]
+		if !ok {
+			var deploymentID string
+			if err := h.db.QueryRow("SELECT id FROM deployments WHERE stack_name = $1", stackName).Scan(&deploymentID); err != nil {
+				continue // Not managed by this instance
+			}
+			stack = &models.UnhealthyStack{DeploymentID: deploymentID, StackName: stackName}
+			byStack[stackName] = stack
+			order = append(order, stackName)
+		}
+
+		name := container.ID[:12]
+		if len(container.Names) > 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+		stack.Containers = append(stack.Containers, name)
+	}
+
+	for _, stackName := range order {
+		stacks = append(stacks, *byStack[stackName])
+	}
+	return stacks, nil
+}
+
+// recentFailures returns the most recently failed deployments with the error of
+// their last failed task
+func (h *OverviewHandler) recentFailures() ([]models.DeploymentFailure, error) {
+	rows, err := h.db.Query(`
+		SELECT d.id, d.stack_name, d.template_id, d.updated_at,
+		       (SELECT t.error FROM tasks t
+		        WHERE t.resource_id = d.id AND t.state = 'failed'
+		        ORDER BY t.created_at DESC LIMIT 1)
+		FROM deployments d
+		WHERE d.status = $1
+		ORDER BY d.updated_at DESC
+		LIMIT $2`, models.StatusFailed, overviewListSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failures := []models.DeploymentFailure{}
+	for rows.Next() {
+		var f models.DeploymentFailure
+		var errMsg sql.NullString
+		if err := rows.Scan(&f.DeploymentID, &f.StackName, &f.TemplateID, &f.FailedAt, &errMsg); err != nil {
+			return nil, err
+		}
+		f.Error = errMsg.String
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
+
+// latestBackups returns the most recently created backups
+func (h *OverviewHandler) latestBackups() ([]models.Backup, error) {
+	rows, err := h.db.Query(`
+		SELECT id, name, type, status, size_bytes, deployment_ids, created_at, completed_at
+		FROM backups
+		ORDER BY created_at DESC
+		LIMIT $1`, overviewListSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	backups := []models.Backup{}
+	for rows.Next() {
+		var b models.Backup
+		var deploymentIDs sql.NullString
+		var completedAt sql.NullTime
+		if err := rows.Scan(&b.ID, &b.Name, &b.Type, &b.Status, &b.SizeBytes, &deploymentIDs,
+			&b.CreatedAt, &completedAt); err != nil {
+			return nil, err
+		}
+		if deploymentIDs.Valid {
+			json.Unmarshal([]byte(deploymentIDs.String), &b.DeploymentIDs)
+		}
+		if completedAt.Valid {
+			b.CompletedAt = &completedAt.Time
+		}
+		backups = append(backups, b)
+	}
+	return backups, rows.Err()
+}
+
+// tunnelSummary counts deployments with Newt injected by their last recorded tunnel
+// state. Deployments without recorded events are counted as unknown
+func (h *OverviewHandler) tunnelSummary() (models.TunnelSummary, error) {
+	summary := models.TunnelSummary{
+		Enabled:      h.config.Newt.Enabled,
+		ByState:      make(map[string]int),
+		Disconnected: []string{},
+	}
+
+	rows, err := h.db.Query(`
+		SELECT d.stack_name,
+		       (SELECT e.state FROM newt_tunnel_events e
+		        WHERE e.deployment_id = d.id
+		        ORDER BY e.created_at DESC, e.id DESC LIMIT 1)
+		FROM deployments d
+		WHERE d.newt_injected = 1
+		ORDER BY d.stack_name`)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stackName string
+		var state sql.NullString
+		if err := rows.Scan(&stackName, &state); err != nil {
+			return summary, err
+		}
+
+		current := "unknown"
+		if state.Valid {
+			current = state.String
+		}
+		summary.Total++
+		summary.ByState[current]++
+		if current != "connected" {
+			summary.Disconnected = append(summary.Disconnected, stackName)
+		}
+	}
+	return summary, rows.Err()
+}
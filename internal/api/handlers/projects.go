@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/backup"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/migration"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/quotas"
+)
+
+// ProjectsHandler handles project-related HTTP requests
+type ProjectsHandler struct {
+	db      *sql.DB
+	config  *config.Config
+	backups   *backup.Manager
+	quotas    *quotas.Enforcer
+	footprint *docker.FootprintSampler
+	exporter  *migration.ComposeExporter
+}
+
+// NewProjectsHandler creates a new projects handler
+func NewProjectsHandler(db *sql.DB, dockerClient *client.Client, config *config.Config) *ProjectsHandler {
+	return &ProjectsHandler{
+		db:      db,
+		config:  config,
+		backups: backup.NewManager(db, dockerClient, config.Backup, "./deployments"),
+		quotas:    quotas.NewEnforcer(db, dockerClient, config.Quotas),
+		footprint: docker.NewFootprintSampler(db, dockerClient, config.Monitoring.Footprint),
+		exporter:  migration.NewComposeExporter(db, "./deployments"),
+	}
+}
+
+// List returns all projects with their deployment counts
+func (h *ProjectsHandler) List(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`
+		SELECT p.id, p.name, COALESCE(p.description, ''), p.created_at,
+		       (SELECT COUNT(*) FROM deployments d WHERE COALESCE(d.project_id, 'global') = p.id)
+		FROM projects p
+		ORDER BY p.id = 'global' DESC, p.name`)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	projects := []models.Project{}
+	for rows.Next() {
+		var p models.Project
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.CreatedAt, &p.Deployments); err != nil {
+			continue
+		}
+		projects = append(projects, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"projects": projects,
+		"total":    len(projects),
+	})
+}
+
+// Get returns a specific project
+func (h *ProjectsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	project, err := h.getProject(chi.URLParam(r, "id"))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+// Create creates a new project
+func (h *ProjectsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var project models.Project
+	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := project.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1)", project.ID).Scan(&exists)
+	if exists {
+		http.Error(w, "Project already exists", http.StatusConflict)
+		return
+	}
+
+	project.CreatedAt = time.Now()
+	_, err := h.db.Exec("INSERT INTO projects (id, name, description, created_at) VALUES ($1, $2, $3, $4)",
+		project.ID, project.Name, project.Description, project.CreatedAt)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create project: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"project": project,
+		"message": "Project created successfully",
+	})
+}
+
+// Update changes a project's name and description
+func (h *ProjectsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(w, models.ErrProjectNameRequired.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.Exec("UPDATE projects SET name = $1, description = $2 WHERE id = $3",
+		req.Name, req.Description, projectID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Project updated successfully",
+	})
+}
+
+// Delete removes an empty project
+func (h *ProjectsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+	if projectID == models.DefaultProjectID {
+		http.Error(w, "The global project cannot be deleted", http.StatusBadRequest)
+		return
+	}
+
+	var deployments int
+	h.db.QueryRow("SELECT COUNT(*) FROM deployments WHERE project_id = $1", projectID).Scan(&deployments)
+	if deployments > 0 {
+		http.Error(w, fmt.Sprintf("Project still has %d deployments", deployments), http.StatusConflict)
+		return
+	}
+
+	result, err := h.db.Exec("DELETE FROM projects WHERE id = $1", projectID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Project deleted successfully",
+	})
+}
+
+// ListMembers returns the users with a role in a project
+func (h *ProjectsHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	rows, err := h.db.Query(`
+		SELECT m.project_id, m.user_id, COALESCE(u.username, ''), m.role, m.created_at
+		FROM project_members m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.project_id = $1
+		ORDER BY u.username`, chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	members := []models.ProjectMember{}
+	for rows.Next() {
+		var m models.ProjectMember
+		if err := rows.Scan(&m.ProjectID, &m.UserID, &m.Username, &m.Role, &m.CreatedAt); err != nil {
+			continue
+		}
+		members = append(members, m)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"members": members,
+	})
+}
+
+// SetMember gives a user a role in a project
+func (h *ProjectsHandler) SetMember(w http.ResponseWriter, r *http.Request) {
+	member := models.ProjectMember{
+		ProjectID: chi.URLParam(r, "id"),
+		UserID:    chi.URLParam(r, "userID"),
+	}
+
+	var req struct {
+		Role models.UserRole `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	member.Role = req.Role
+
+	if err := member.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.getProject(member.ProjectID); err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	var userExists bool
+	h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", member.UserID).Scan(&userExists)
+	if !userExists {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	_, err := h.db.Exec(`
+		INSERT INTO project_members (project_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT (project_id, user_id) DO UPDATE SET role = excluded.role`,
+		member.ProjectID, member.UserID, member.Role)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"member":  member,
+		"message": "Project member saved successfully",
+	})
+}
+
+// RemoveMember removes a user's role in a project, restoring their global role there
+func (h *ProjectsHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	result, err := h.db.Exec("DELETE FROM project_members WHERE project_id = $1 AND user_id = $2",
+		chi.URLParam(r, "id"), chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, "Project member not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Project member removed successfully",
+	})
+}
+
+// Backup backs up every deployment of a project into a single backup
+func (h *ProjectsHandler) Backup(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	var req struct {
+		Name           string `json:"name"`
+		IncludeVolumes bool   `json:"include_volumes"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := h.getProject(projectID); err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := h.db.Query("SELECT id, stack_name FROM deployments WHERE COALESCE(project_id, 'global') = $1", projectID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var deployments []models.DeploymentBackup
+	for rows.Next() {
+		var d models.DeploymentBackup
+		if err := rows.Scan(&d.ID, &d.StackName); err == nil {
+			deployments = append(deployments, d)
+		}
+	}
+	rows.Close()
+
+	if len(deployments) == 0 {
+		http.Error(w, "Project has no deployments", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = fmt.Sprintf("project-%s-%s", projectID, time.Now().Format("20060102-150405"))
+	}
+
+	deploymentIDs := make([]string, len(deployments))
+	for i, d := range deployments {
+		deploymentIDs[i] = d.ID
+	}
+	if err := h.quotas.CheckBackup(r.Context(), deploymentIDs, requestedBy(r)); err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+
+	b, taskID, err := h.backups.CreateBackup(&models.BackupConfig{
+		Name:           req.Name,
+		Type:           models.BackupTypeManual,
+		IncludeVolumes: req.IncludeVolumes,
+		Deployments:    deployments,
+		Metadata:       map[string]interface{}{"project_id": projectID},
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create backup: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":          b.ID,
+		"name":        b.Name,
+		"status":      b.Status,
+		"project_id":  projectID,
+		"deployments": len(deployments),
+		"task_id":     taskID,
+		"message":     "Backup started",
+	})
+}
+
+// SetUpdatePolicy sets the automatic update policy of every deployment of a project
+func (h *ProjectsHandler) SetUpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	var req models.UpdatePolicyConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Schedule != "" {
+		if err := docker.ValidateSchedule(req.Schedule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := h.getProject(projectID); err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE deployments SET update_policy = $1, update_schedule = $2, updated_at = $3,
+		       resource_version = COALESCE(resource_version, 1) + 1
+		WHERE COALESCE(project_id, 'global') = $4`, req.Policy, req.Schedule, time.Now(), projectID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	updated, _ := result.RowsAffected()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Update policy saved successfully",
+		"policy":      req.Policy,
+		"schedule":    req.Schedule,
+		"deployments": updated,
+	})
+}
+
+// Export downloads the deployments of a project as a zip of plain compose
+// projects with a README on starting them. Secret values are included with
+// ?secrets=true
+func (h *ProjectsHandler) Export(w http.ResponseWriter, r *http.Request) {
+	project, err := h.getProject(chi.URLParam(r, "id"))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	withSecrets := r.URL.Query().Get("secrets") == "true"
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s_compose_%s.zip\"", project.ID, time.Now().Format("20060102_150405")))
+	w.Header().Set("Content-Type", "application/zip")
+
+	if err := h.exporter.Export(w, project, withSecrets); err != nil {
+		// Headers are already sent; the truncated zip fails to open
+		slog.Error("Failed to export project", "project_id", project.ID, "error", err)
+		return
+	}
+	slog.Info("Exported project as compose bundle", "project_id", project.ID, "secrets", withSecrets, "requested_by", requestedBy(r))
+}
+
+// getProject returns a project with its deployment count
+func (h *ProjectsHandler) getProject(projectID string) (*models.Project, error) {
+	var p models.Project
+	err := h.db.QueryRow(`
+		SELECT p.id, p.name, COALESCE(p.description, ''), p.created_at,
+		       (SELECT COUNT(*) FROM deployments d WHERE COALESCE(d.project_id, 'global') = p.id)
+		FROM projects p WHERE p.id = $1`, projectID).Scan(&p.ID, &p.Name, &p.Description, &p.CreatedAt, &p.Deployments)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/logging"
+	"docker-deploy-app/internal/models"
+)
+
+// Promote deploys a copy of a deployment into another project, for example from
+// dev to prod. The copy uses the same template, environment and newt settings,
+// with the request's environment overrides merged over them
+func (h *DeploymentsHandler) Promote(w http.ResponseWriter, r *http.Request) {
+	sourceID := chi.URLParam(r, "id")
+
+	var req models.PromotionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var config models.DeploymentConfig
+	var sourceProjectID, configJSON string
+	err := h.db.QueryRow(`
+		SELECT template_id, COALESCE(project_id, 'global'), deploy_mode, COALESCE(tunnel_provider, 'newt'), config
+		FROM deployments WHERE id = $1`, sourceID).Scan(
+		&config.TemplateID, &sourceProjectID, &config.DeployMode, &config.TunnelProvider, &configJSON,
+	)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// The stored configuration uses the keys of a deployment request
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid source configuration: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var projectExists bool
+	h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1)", req.ProjectID).Scan(&projectExists)
+	if !projectExists {
+		http.Error(w, models.ErrProjectNotFound.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The route only checks the source project; deploying into the target needs
+	// the same role there
+	user := apiMiddleware.UserFromContext(r.Context())
+	if user != nil && !apiMiddleware.HasProjectRole(h.db, user, req.ProjectID, "operator") {
+		http.Error(w, "Insufficient permissions in target project", http.StatusForbidden)
+		return
+	}
+
+	config.ProjectID = req.ProjectID
+	config.StackName = req.StackName
+	config.OverrideExisting = false
+	if config.Environment == nil {
+		config.Environment = make(map[string]string)
+	}
+	overriddenKeys := make([]string, 0, len(req.Environment))
+	for key, value := range req.Environment {
+		config.Environment[key] = value
+		overriddenKeys = append(overriddenKeys, key)
+	}
+	sort.Strings(overriddenKeys)
+	if req.NewtConfig != nil {
+		config.NewtConfig = req.NewtConfig
+	}
+	if req.AutoStart != nil {
+		config.AutoStart = *req.AutoStart
+	}
+
+	if err := config.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	template, derr := h.prepareDeployment(&config)
+	if derr != nil {
+		derr.write(w)
+		return
+	}
+	config.RequestedBy = requestedBy(r)
+
+	var existingID string
+	err = h.db.QueryRow("SELECT id FROM deployments WHERE stack_name = $1", config.StackName).Scan(&existingID)
+	if err != sql.ErrNoRows {
+		http.Error(w, "Stack name already exists", http.StatusConflict)
+		return
+	}
+
+	deployment, taskID, err := h.startDeployment(logging.FromContext(r.Context()), &config, template)
+	if err != nil {
+		writeStartError(w, err)
+		return
+	}
+
+	promotion := models.Promotion{
+		SourceDeploymentID: sourceID,
+		TargetDeploymentID: deployment.ID,
+		SourceProjectID:    sourceProjectID,
+		TargetProjectID:    deployment.ProjectID,
+		TemplateID:         deployment.TemplateID,
+		OverriddenKeys:     overriddenKeys,
+		CreatedAt:          deployment.CreatedAt,
+	}
+	h.db.QueryRow("SELECT COALESCE(version, '') FROM templates WHERE id = $1", deployment.TemplateID).Scan(&promotion.TemplateVersion)
+	if user != nil {
+		promotion.PromotedBy = user.Username
+	}
+
+	// Only the keys overridden are recorded; their values may be secrets
+	keysJSON, _ := json.Marshal(promotion.OverriddenKeys)
+	result, err := h.db.Exec(`
+		INSERT INTO deployment_promotions (source_deployment_id, target_deployment_id, source_project_id,
+		                                   target_project_id, template_id, template_version, overridden_keys,
+		                                   promoted_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		promotion.SourceDeploymentID, promotion.TargetDeploymentID, promotion.SourceProjectID,
+		promotion.TargetProjectID, promotion.TemplateID, promotion.TemplateVersion, string(keysJSON),
+		promotion.PromotedBy, promotion.CreatedAt,
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		promotion.ID = int(id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", deployment.ETag())
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":         deployment.ID,
+		"stack_name": deployment.StackName,
+		"project_id": deployment.ProjectID,
+		"status":     deployment.Status,
+		"etag":       deployment.ETag(),
+		"task_id":    taskID,
+		"promotion":  promotion,
+		"message":    "Promotion started",
+	})
+}
+
+// GetPromotions returns the promotion lineage of a deployment: the deployment it
+// was promoted from and the deployments promoted from it
+func (h *DeploymentsHandler) GetPromotions(w http.ResponseWriter, r *http.Request) {
+	deploymentID := chi.URLParam(r, "id")
+
+	rows, err := h.db.Query(`
+		SELECT id, source_deployment_id, target_deployment_id, source_project_id, target_project_id,
+		       template_id, COALESCE(template_version, ''), COALESCE(overridden_keys, '[]'),
+		       COALESCE(promoted_by, ''), created_at
+		FROM deployment_promotions
+		WHERE source_deployment_id = $1 OR target_deployment_id = $1
+		ORDER BY created_at DESC`, deploymentID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	promotions := []models.Promotion{}
+	for rows.Next() {
+		var p models.Promotion
+		var keysJSON string
+		if err := rows.Scan(&p.ID, &p.SourceDeploymentID, &p.TargetDeploymentID, &p.SourceProjectID,
+			&p.TargetProjectID, &p.TemplateID, &p.TemplateVersion, &keysJSON, &p.PromotedBy, &p.CreatedAt); err != nil {
+			continue
+		}
+		json.Unmarshal([]byte(keysJSON), &p.OverriddenKeys)
+		promotions = append(promotions, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"promotions": promotions,
+		"total":      len(promotions),
+	})
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/github"
+	"docker-deploy-app/internal/models"
+)
+
+// PublisherKeysHandler manages the keys trusted to sign publisher template manifests
+type PublisherKeysHandler struct {
+	db     *sql.DB
+	config *config.Config
+}
+
+// NewPublisherKeysHandler creates a new publisher keys handler
+func NewPublisherKeysHandler(db *sql.DB, config *config.Config) *PublisherKeysHandler {
+	return &PublisherKeysHandler{
+		db:     db,
+		config: config,
+	}
+}
+
+// List returns the trusted keys, of one publisher when the publisher query
+// parameter is given
+func (h *PublisherKeysHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT k.id, k.publisher_id, k.key_type, k.public_key, k.fingerprint,
+		       COALESCE(k.description, ''), COALESCE(k.created_by, ''), k.created_at,
+		       (SELECT COUNT(*) FROM templates t WHERE t.verified_key_id = k.id)
+		FROM publisher_keys k`
+	var args []interface{}
+	if publisher := r.URL.Query().Get("publisher"); publisher != "" {
+		query += " WHERE k.publisher_id = $1"
+		args = append(args, strings.ToLower(publisher))
+	}
+	query += " ORDER BY k.publisher_id, k.created_at"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	keys := []map[string]interface{}{}
+	for rows.Next() {
+		var key models.PublisherKey
+		var templates int
+		if err := rows.Scan(&key.ID, &key.PublisherID, &key.KeyType, &key.PublicKey, &key.Fingerprint,
+			&key.Description, &key.CreatedBy, &key.CreatedAt, &templates); err != nil {
+			continue
+		}
+		keys = append(keys, map[string]interface{}{
+			"key":                key,
+			"verified_templates": templates,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys":  keys,
+		"total": len(keys),
+	})
+}
+
+// Create trusts a key to sign a publisher's template manifests. Templates are
+// verified with it on the next sync
+func (h *PublisherKeysHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var key models.PublisherKey
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := key.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+	fingerprint, err := github.ParsePublisherKey(key.KeyType, key.PublicKey)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM publisher_keys WHERE publisher_id = $1 AND fingerprint = $2)",
+		key.PublisherID, fingerprint).Scan(&exists)
+	if exists {
+		http.Error(w, models.ErrPublisherKeyExists.Error(), http.StatusConflict)
+		return
+	}
+
+	key.ID = fmt.Sprintf("key_%d", time.Now().UnixNano())
+	key.Fingerprint = fingerprint
+	key.CreatedBy = requestedBy(r)
+	key.CreatedAt = time.Now()
+
+	_, err = h.db.Exec(`
+		INSERT INTO publisher_keys (id, publisher_id, key_type, public_key, fingerprint, description, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		key.ID, key.PublisherID, key.KeyType, key.PublicKey, key.Fingerprint, key.Description, key.CreatedBy, key.CreatedAt)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to add key: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":     key,
+		"message": "Publisher key added; templates are verified on the next sync",
+	})
+}
+
+// Delete revokes a key. The templates it verified lose their verified status
+// right away instead of at the next sync
+func (h *PublisherKeysHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	keyID := chi.URLParam(r, "id")
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM publisher_keys WHERE id = $1", keyID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		http.Error(w, models.ErrPublisherKeyNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	result, err = tx.Exec(`
+		UPDATE templates SET is_verified = false, verified_key_id = NULL, updated_at = $1
+		WHERE verified_key_id = $2`, time.Now(), keyID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	unverified, _ := result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":              "Publisher key revoked",
+		"unverified_templates": unverified,
+	})
+}
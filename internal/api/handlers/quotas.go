@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/docker/docker/client"
+	"github.com/go-chi/chi/v5"
+	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/quotas"
+)
+
+// QuotasHandler handles quota-related HTTP requests
+type QuotasHandler struct {
+	db     *sql.DB
+	config *config.Config
+	quotas *quotas.Enforcer
+}
+
+// NewQuotasHandler creates a new quotas handler
+func NewQuotasHandler(db *sql.DB, dockerClient *client.Client, config *config.Config) *QuotasHandler {
+	return &QuotasHandler{
+		db:     db,
+		config: config,
+		quotas: quotas.NewEnforcer(db, dockerClient, config.Quotas),
+	}
+}
+
+// GetMine returns the signed-in user's consumption against their quota
+func (h *QuotasHandler) GetMine(w http.ResponseWriter, r *http.Request) {
+	user := apiMiddleware.UserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "User quotas require authentication", http.StatusBadRequest)
+		return
+	}
+	h.writeUsage(w, r, models.QuotaScopeUser, user.ID)
+}
+
+// GetProject returns a project's consumption against its quota
+func (h *QuotasHandler) GetProject(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+	if !h.exists("projects", projectID) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	h.writeUsage(w, r, models.QuotaScopeProject, projectID)
+}
+
+// SetProject sets the limits of a project's quota
+func (h *QuotasHandler) SetProject(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+	if !h.exists("projects", projectID) {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	h.setLimits(w, r, models.QuotaScopeProject, projectID)
+}
+
+// ResetProject returns a project's quota to the configured defaults
+func (h *QuotasHandler) ResetProject(w http.ResponseWriter, r *http.Request) {
+	h.resetLimits(w, r, models.QuotaScopeProject, chi.URLParam(r, "id"))
+}
+
+// GetUser returns a user's consumption against their quota
+func (h *QuotasHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	if !h.exists("users", userID) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	h.writeUsage(w, r, models.QuotaScopeUser, userID)
+}
+
+// SetUser sets the limits of a user's quota
+func (h *QuotasHandler) SetUser(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	if !h.exists("users", userID) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	h.setLimits(w, r, models.QuotaScopeUser, userID)
+}
+
+// ResetUser returns a user's quota to the configured defaults
+func (h *QuotasHandler) ResetUser(w http.ResponseWriter, r *http.Request) {
+	h.resetLimits(w, r, models.QuotaScopeUser, chi.URLParam(r, "id"))
+}
+
+func (h *QuotasHandler) writeUsage(w http.ResponseWriter, r *http.Request, scope models.QuotaScope, id string) {
+	usage, err := h.quotas.Usage(r.Context(), scope, id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to measure usage: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+func (h *QuotasHandler) setLimits(w http.ResponseWriter, r *http.Request, scope models.QuotaScope, id string) {
+	var update models.QuotaUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := update.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.quotas.SetLimits(scope, id, &update); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.writeUsage(w, r, scope, id)
+}
+
+func (h *QuotasHandler) resetLimits(w http.ResponseWriter, r *http.Request, scope models.QuotaScope, id string) {
+	if err := h.quotas.ResetLimits(scope, id); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	h.writeUsage(w, r, scope, id)
+}
+
+// exists reports whether a project or user exists
+func (h *QuotasHandler) exists(table, id string) bool {
+	var exists bool
+	h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM "+table+" WHERE id = $1)", id).Scan(&exists)
+	return exists
+}
+
+// writeQuotaError reports a failed quota check, rejecting the request when a
+// quota is exceeded
+func writeQuotaError(w http.ResponseWriter, err error) {
+	var quotaErr *quotas.ExceededError
+	if errors.As(err, &quotaErr) {
+		http.Error(w, quotaErr.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/models"
+)
+
+// GetSBOM returns a software bill of materials for the images a deployment runs.
+// The format query parameter selects cyclonedx (default) or spdx; packages=false
+// leaves out the image packages, which are only listed when syft is installed
+func (h *DeploymentsHandler) GetSBOM(w http.ResponseWriter, r *http.Request) {
+	deploymentID := chi.URLParam(r, "id")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = docker.SBOMFormatCycloneDX
+	}
+	if format != docker.SBOMFormatCycloneDX && format != docker.SBOMFormatSPDX {
+		http.Error(w, fmt.Sprintf("Validation error: format must be %s or %s", docker.SBOMFormatCycloneDX, docker.SBOMFormatSPDX), http.StatusBadRequest)
+		return
+	}
+	packages := r.URL.Query().Get("packages") != "false"
+
+	var stackName string
+	var mode models.DeployMode
+	err := h.db.QueryRow("SELECT stack_name, deploy_mode FROM deployments WHERE id = $1", deploymentID).Scan(&stackName, &mode)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Deployment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	sbom, err := h.sbom.Generate(r.Context(), stackName, mode, format, packages)
+	if err == models.ErrNoStackContainers {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate SBOM: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", stackName+"."+format+".json"))
+	json.NewEncoder(w).Encode(sbom)
+}
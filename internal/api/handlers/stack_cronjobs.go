@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/models"
+)
+
+// ListCronJobs returns the cron jobs of a stack
+func (h *StacksHandler) ListCronJobs(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	jobs, err := h.cronjobs.List(stackID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"stack_id": stackID,
+		"cronjobs": jobs,
+		"total":    len(jobs),
+	})
+}
+
+// CreateCronJob adds a cron job running a command in a service of a stack
+func (h *StacksHandler) CreateCronJob(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	stackName := h.getStackName(stackID)
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	var req models.CronJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	job := &models.CronJob{
+		DeploymentID:   stackID,
+		Enabled:        true,
+		AlertOnFailure: true,
+		CreatedBy:      requestedBy(r),
+	}
+	req.Apply(job)
+	if err := h.checkCronJobService(stackID, stackName, job); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.cronjobs.Create(job); err != nil {
+		writeCronJobError(w, err)
+		return
+	}
+
+	slog.Info("Cron job created", "stack", stackName, "job", job.Name, "schedule", job.CronSpec(), "user", job.CreatedBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(job)
+}
+
+// UpdateCronJob replaces the definition of a cron job
+func (h *StacksHandler) UpdateCronJob(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	job, ok := h.cronJob(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CronJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Apply(job)
+	if err := h.checkCronJobService(stackID, h.getStackName(stackID), job); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.cronjobs.Update(job); err != nil {
+		writeCronJobError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// DeleteCronJob removes a cron job and its run history
+func (h *StacksHandler) DeleteCronJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.cronJob(w, r)
+	if !ok {
+		return
+	}
+	if err := h.cronjobs.Delete(job); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Cron job deleted",
+	})
+}
+
+// RunCronJob runs a cron job now, in the background. The returned run is
+// completed in the job's history
+func (h *StacksHandler) RunCronJob(w http.ResponseWriter, r *http.Request) {
+	stackName := h.getStackName(chi.URLParam(r, "id"))
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+	job, ok := h.cronJob(w, r)
+	if !ok {
+		return
+	}
+
+	run, err := h.cronjobs.StartRun(job, stackName, models.CronTriggerManual)
+	if err != nil {
+		writeCronJobError(w, err)
+		return
+	}
+
+	slog.Info("Cron job started manually", "stack", stackName, "job", job.Name, "run_id", run.ID, "user", requestedBy(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"run":     run,
+		"message": "Cron job started",
+	})
+}
+
+// ListCronJobRuns returns the run history of a cron job, newest first
+func (h *StacksHandler) ListCronJobRuns(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.cronJob(w, r)
+	if !ok {
+		return
+	}
+
+	runs, err := h.cronjobs.Runs(job.ID, getIntParam(r, "limit", 20))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job":   job,
+		"runs":  runs,
+		"total": len(runs),
+	})
+}
+
+// cronJob loads the cron job of the request path, writing the error response
+// if it cannot
+func (h *StacksHandler) cronJob(w http.ResponseWriter, r *http.Request) (*models.CronJob, bool) {
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "job"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid cron job ID", http.StatusBadRequest)
+		return nil, false
+	}
+	job, err := h.cronjobs.Get(chi.URLParam(r, "id"), jobID)
+	if err == models.ErrCronJobNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	return job, true
+}
+
+// checkCronJobService checks that the service of a job is one of the stack's.
+// Stacks whose services cannot be listed are not checked
+func (h *StacksHandler) checkCronJobService(stackID, stackName string, job *models.CronJob) error {
+	services, err := h.getServices(stackName, h.getDeployMode(stackID))
+	if err != nil || len(services) == 0 {
+		return nil
+	}
+	for _, service := range services {
+		if service.Name == job.Service {
+			return nil
+		}
+	}
+	return fmt.Errorf("stack has no service %q", job.Service)
+}
+
+// writeCronJobError maps a cron job error to its status
+func writeCronJobError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrCronJobRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, models.ErrCronJobNameRequired), errors.Is(err, models.ErrCronJobServiceRequired),
+		errors.Is(err, models.ErrCronJobCommandRequired), errors.Is(err, models.ErrCronJobTimeoutInvalid),
+		errors.Is(err, models.ErrScheduleCronRequired), errors.Is(err, models.ErrScheduleCronInvalid),
+		errors.Is(err, models.ErrScheduleTimezoneInvalid):
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+	default:
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/models"
+)
+
+// GetDependencies returns the stacks a stack depends on and the stacks depending on it
+func (h *StacksHandler) GetDependencies(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+
+	var projectID string
+	err := h.db.QueryRow("SELECT COALESCE(project_id, 'global') FROM deployments WHERE id = $1", stackID).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	dependsOn, err := h.queryIDs("SELECT depends_on_id FROM stack_dependencies WHERE deployment_id = $1 ORDER BY depends_on_id", stackID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	dependents, err := h.queryIDs("SELECT deployment_id FROM stack_dependencies WHERE depends_on_id = $1 ORDER BY deployment_id", stackID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deployment_id": stackID,
+		"project_id":    projectID,
+		"depends_on":    dependsOn,
+		"dependents":    dependents,
+	})
+}
+
+// SetDependencies replaces the stacks a stack depends on and recomputes the start
+// order of its project. Dependencies must be stacks of the same project and may
+// not form a cycle
+func (h *StacksHandler) SetDependencies(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+
+	var req struct {
+		DependsOn []string `json:"depends_on"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var projectID string
+	err := h.db.QueryRow("SELECT COALESCE(project_id, 'global') FROM deployments WHERE id = $1", stackID).Scan(&projectID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	seen := make(map[string]bool)
+	dependsOn := []string{}
+	for _, dep := range req.DependsOn {
+		if seen[dep] {
+			continue
+		}
+		seen[dep] = true
+
+		if dep == stackID {
+			http.Error(w, "A stack cannot depend on itself", http.StatusBadRequest)
+			return
+		}
+		var depProjectID string
+		err := h.db.QueryRow("SELECT COALESCE(project_id, 'global') FROM deployments WHERE id = $1", dep).Scan(&depProjectID)
+		if err == sql.ErrNoRows {
+			http.Error(w, fmt.Sprintf("Stack %s not found", dep), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if depProjectID != projectID {
+			http.Error(w, fmt.Sprintf("Stack %s belongs to another project", dep), http.StatusBadRequest)
+			return
+		}
+		dependsOn = append(dependsOn, dep)
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM stack_dependencies WHERE deployment_id = $1", stackID); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	for _, dep := range dependsOn {
+		if _, err := tx.Exec("INSERT INTO stack_dependencies (deployment_id, depends_on_id) VALUES ($1, $2)", stackID, dep); err != nil {
+			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	order, err := stackOrder(tx, projectID)
+	if err == models.ErrStackDependencyCycle {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deployment_id": stackID,
+		"project_id":    projectID,
+		"depends_on":    dependsOn,
+		"stack_order":   order,
+		"message":       "Stack dependencies updated",
+	})
+}
+
+// StartAll starts every stack of a project, each after the stacks it depends on.
+// Stacks whose dependencies failed to start are skipped
+func (h *StacksHandler) StartAll(w http.ResponseWriter, r *http.Request) {
+	h.runAll(w, r, true)
+}
+
+// StopAll stops every stack of a project, each before the stacks it depends on.
+// Stacks with dependents that failed to stop are skipped
+func (h *StacksHandler) StopAll(w http.ResponseWriter, r *http.Request) {
+	h.runAll(w, r, false)
+}
+
+func (h *StacksHandler) runAll(w http.ResponseWriter, r *http.Request, start bool) {
+	projectID := r.URL.Query().Get("project")
+	if projectID == "" {
+		projectID = models.DefaultProjectID
+	}
+
+	var projectExists bool
+	h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1)", projectID).Scan(&projectExists)
+	if !projectExists {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	order, err := stackOrder(tx, projectID)
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if err == models.ErrStackDependencyCycle {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Stopping walks the order backwards, so a stack is blocked by its dependents
+	// rather than its dependencies
+	blockers := make(map[string][]string)
+	rows, err := h.db.Query("SELECT deployment_id, depends_on_id FROM stack_dependencies")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	for rows.Next() {
+		var id, dep string
+		if err := rows.Scan(&id, &dep); err != nil {
+			continue
+		}
+		if start {
+			blockers[id] = append(blockers[id], dep)
+		} else {
+			blockers[dep] = append(blockers[dep], id)
+		}
+	}
+	rows.Close()
+
+	if !start {
+		for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+			order[i], order[j] = order[j], order[i]
+		}
+	}
+
+	results := make([]models.StackOperationResult, 0, len(order))
+	failed := make(map[string]bool)
+	succeeded := 0
+	for _, stackID := range order {
+		result := models.StackOperationResult{DeploymentID: stackID, StackName: h.getStackName(stackID)}
+
+		for _, blocker := range blockers[stackID] {
+			if failed[blocker] {
+				result.Status = "skipped"
+				result.Error = fmt.Sprintf("stack %s did not complete", blocker)
+				break
+			}
+		}
+
+		if result.Status == "" {
+			if err := h.setStackRunning(stackID, result.StackName, start); err != nil {
+				result.Status = "failed"
+				result.Error = err.Error()
+			} else if start {
+				result.Status = "started"
+			} else {
+				result.Status = "stopped"
+			}
+		}
+
+		if result.Status == "started" || result.Status == "stopped" {
+			succeeded++
+		} else {
+			failed[stackID] = true
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"project_id": projectID,
+		"order":      order,
+		"results":    results,
+		"succeeded":  succeeded,
+		"failed":     len(results) - succeeded,
+	})
+}
+
+// setStackRunning starts or stops a stack and records its new status
+func (h *StacksHandler) setStackRunning(stackID, stackName string, running bool) error {
+	var err error
+	if h.getDeployMode(stackID) == models.DeployModeSwarm {
+		if running {
+			err = h.swarm.Start(stackName)
+		} else {
+			err = h.swarm.Stop(stackName)
+		}
+	} else if running {
+		err = h.compose.Start(stackName)
+	} else {
+		err = h.compose.Stop(stackName)
+	}
+	if err != nil {
+		return err
+	}
+
+	if running {
+		h.updateDeploymentStatus(stackID, models.StatusRunning)
+	} else {
+		h.updateDeploymentStatus(stackID, models.StatusStopped)
+	}
+	return nil
+}
+
+// stackOrder computes the start order of a project's stacks and stores it on the
+// project. The stored order breaks ties, so stacks only move when their
+// dependencies require it; stacks new to the project follow by creation time
+func stackOrder(tx *sql.Tx, projectID string) ([]string, error) {
+	var storedJSON string
+	if err := tx.QueryRow("SELECT COALESCE(stack_order, '[]') FROM projects WHERE id = $1", projectID).Scan(&storedJSON); err != nil {
+		return nil, err
+	}
+	var stored []string
+	json.Unmarshal([]byte(storedJSON), &stored)
+
+	rows, err := tx.Query("SELECT id FROM deployments WHERE COALESCE(project_id, 'global') = $1 ORDER BY created_at", projectID)
+	if err != nil {
+		return nil, err
+	}
+	inProject := make(map[string]bool)
+	var created []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			inProject[id] = true
+			created = append(created, id)
+		}
+	}
+	rows.Close()
+
+	stacks := make([]string, 0, len(created))
+	listed := make(map[string]bool)
+	for _, id := range append(stored, created...) {
+		if inProject[id] && !listed[id] {
+			listed[id] = true
+			stacks = append(stacks, id)
+		}
+	}
+
+	dependsOn := make(map[string][]string)
+	rows, err = tx.Query(`
+		SELECT s.deployment_id, s.depends_on_id
+		FROM stack_dependencies s
+		JOIN deployments d ON d.id = s.deployment_id
+		WHERE COALESCE(d.project_id, 'global') = $1`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id, dep string
+		if err := rows.Scan(&id, &dep); err == nil {
+			dependsOn[id] = append(dependsOn[id], dep)
+		}
+	}
+	rows.Close()
+
+	order, err := models.OrderStacks(stacks, dependsOn)
+	if err != nil {
+		return nil, err
+	}
+
+	orderJSON, _ := json.Marshal(order)
+	if _, err := tx.Exec("UPDATE projects SET stack_order = $1 WHERE id = $2", string(orderJSON), projectID); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// queryIDs returns the single string column of a query's rows
+func (h *StacksHandler) queryIDs(query string, args ...interface{}) ([]string, error) {
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/models"
+)
+
+// ListVolumes returns the named volumes of a stack that can be browsed
+func (h *StacksHandler) ListVolumes(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	stackName := h.getStackName(stackID)
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	volumes, err := h.volumes.Volumes(r.Context(), stackName)
+	if err != nil {
+		writeVolumeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"stack_id": stackID,
+		"volumes":  volumes,
+		"writable": h.volumes.Writable(),
+	})
+}
+
+// ListVolumeFiles lists a directory of a stack volume, given by the path query
+// parameter relative to the volume root
+func (h *StacksHandler) ListVolumeFiles(w http.ResponseWriter, r *http.Request) {
+	stackName := h.getStackName(chi.URLParam(r, "id"))
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	listing, err := h.volumes.List(r.Context(), stackName, chi.URLParam(r, "volume"), r.URL.Query().Get("path"))
+	if err != nil {
+		writeVolumeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listing)
+}
+
+// DownloadVolumeFile streams a regular file of a stack volume
+func (h *StacksHandler) DownloadVolumeFile(w http.ResponseWriter, r *http.Request) {
+	stackName := h.getStackName(chi.URLParam(r, "id"))
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	volumeName := chi.URLParam(r, "volume")
+	filePath := r.URL.Query().Get("path")
+	started := false
+	err := h.volumes.Download(r.Context(), stackName, volumeName, filePath, func(name string, size int64) io.Writer {
+		started = true
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		return w
+	})
+	if err != nil && started {
+		// Headers are already sent; the short body is the only signal left
+		slog.Error("Failed to stream volume file", "stack", stackName, "volume", volumeName, "path", filePath, "error", err)
+		return
+	}
+	if err != nil {
+		writeVolumeError(w, err)
+	}
+}
+
+// UploadVolumeFile writes the request body to a file of a stack volume, given
+// by the path query parameter. The volume browser must allow writes
+func (h *StacksHandler) UploadVolumeFile(w http.ResponseWriter, r *http.Request) {
+	stackName := h.getStackName(chi.URLParam(r, "id"))
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+	if r.ContentLength > h.volumes.MaxFileSize() {
+		writeVolumeError(w, docker.ErrVolumeFileTooLarge)
+		return
+	}
+
+	volumeName := chi.URLParam(r, "volume")
+	file, err := h.volumes.Upload(r.Context(), stackName, volumeName, r.URL.Query().Get("path"), r.Body)
+	if err != nil {
+		writeVolumeError(w, err)
+		return
+	}
+
+	slog.Info("Uploaded file to volume", "stack", stackName, "volume", volumeName, "path", file.Path, "size", file.Size, "user", requestedBy(r))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"file":    file,
+		"message": "File uploaded",
+	})
+}
+
+// writeVolumeError maps a volume browser or snapshot error to its status
+func writeVolumeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, docker.ErrVolumeBrowserDisabled), errors.Is(err, docker.ErrVolumeSnapshotsDisabled),
+		errors.Is(err, docker.ErrVolumeNotFound), errors.Is(err, docker.ErrVolumePathNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, docker.ErrVolumeReadOnly):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, docker.ErrVolumePathInvalid), errors.Is(err, docker.ErrVolumeNotDirectory),
+		errors.Is(err, docker.ErrVolumeNotFile):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, docker.ErrVolumeInUse), errors.Is(err, docker.ErrVolumeExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, docker.ErrVolumeFileTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, fmt.Sprintf("Volume error: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// ListVolumeSnapshots returns the snapshots of a stack volume, newest first
+func (h *StacksHandler) ListVolumeSnapshots(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	volumeName := chi.URLParam(r, "volume")
+
+	snapshots, err := h.snapshots.List(stackID, volumeName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"volume":    volumeName,
+		"snapshots": snapshots,
+		"total":     len(snapshots),
+	})
+}
+
+// SnapshotVolume archives a stack volume into snapshot storage. The archive is
+// written in the background; the returned task reports the outcome
+func (h *StacksHandler) SnapshotVolume(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	stackName := h.getStackName(stackID)
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	var req models.VolumeSnapshotRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	volumeName := chi.URLParam(r, "volume")
+	if err := h.snapshots.Check(r.Context(), stackName, volumeName); err != nil {
+		writeVolumeError(w, err)
+		return
+	}
+
+	user := requestedBy(r)
+	taskID := h.tasks.Start(models.TaskTypeVolume, stackID, fmt.Sprintf("Snapshotting volume %s", volumeName))
+	go func() {
+		snapshot, err := h.snapshots.Create(context.Background(), stackID, stackName, volumeName, req.Note, user)
+		if err != nil {
+			slog.Error("Failed to snapshot volume", "stack", stackName, "volume", volumeName, "error", err)
+		} else {
+			slog.Info("Volume snapshot created", "stack", stackName, "volume", volumeName, "snapshot_id", snapshot.ID, "size", snapshot.SizeBytes)
+		}
+		h.tasks.Finish(taskID, err)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"task_id": taskID,
+		"message": "Volume snapshot started",
+	})
+}
+
+// RestoreVolumeSnapshot replaces the content of a stack volume with one of its
+// snapshots. Containers using the volume must be stopped first
+func (h *StacksHandler) RestoreVolumeSnapshot(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	stackName := h.getStackName(stackID)
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	snapshot, ok := h.volumeSnapshot(w, r)
+	if !ok {
+		return
+	}
+	if err := h.snapshots.CheckRestore(r.Context(), stackName, snapshot.VolumeName); err != nil {
+		writeVolumeError(w, err)
+		return
+	}
+
+	taskID := h.tasks.Start(models.TaskTypeVolume, stackID, fmt.Sprintf("Restoring volume %s", snapshot.VolumeName))
+	go func() {
+		err := h.snapshots.Restore(context.Background(), stackName, snapshot)
+		if err != nil {
+			slog.Error("Failed to restore volume snapshot", "stack", stackName, "snapshot_id", snapshot.ID, "error", err)
+		} else {
+			slog.Info("Volume snapshot restored", "stack", stackName, "volume", snapshot.VolumeName, "snapshot_id", snapshot.ID)
+		}
+		h.tasks.Finish(taskID, err)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"task_id": taskID,
+		"message": "Volume restore started",
+	})
+}
+
+// DeleteVolumeSnapshot removes a snapshot of a stack volume
+func (h *StacksHandler) DeleteVolumeSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, ok := h.volumeSnapshot(w, r)
+	if !ok {
+		return
+	}
+	if err := h.snapshots.Delete(snapshot); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete snapshot: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Volume snapshot deleted",
+	})
+}
+
+// CloneVolume copies a stack volume into a new volume of the stack, in the
+// background
+func (h *StacksHandler) CloneVolume(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	stackName := h.getStackName(stackID)
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	var req models.VolumeCloneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Validation error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	volumeName := chi.URLParam(r, "volume")
+	if err := h.snapshots.CheckClone(r.Context(), stackName, volumeName, req.Name); err != nil {
+		writeVolumeError(w, err)
+		return
+	}
+
+	taskID := h.tasks.Start(models.TaskTypeVolume, stackID, fmt.Sprintf("Cloning volume %s to %s", volumeName, req.Name))
+	go func() {
+		_, err := h.snapshots.Clone(context.Background(), stackName, volumeName, req.Name)
+		if err != nil {
+			slog.Error("Failed to clone volume", "stack", stackName, "volume", volumeName, "target", req.Name, "error", err)
+		}
+		h.tasks.Finish(taskID, err)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"task_id": taskID,
+		"volume":  req.Name,
+		"message": "Volume clone started",
+	})
+}
+
+// volumeSnapshot loads the snapshot of the request path, writing the error
+// response if it cannot
+func (h *StacksHandler) volumeSnapshot(w http.ResponseWriter, r *http.Request) (*models.VolumeSnapshot, bool) {
+	snapshot, err := h.snapshots.Get(chi.URLParam(r, "id"), chi.URLParam(r, "volume"), chi.URLParam(r, "snapshot"))
+	if err == models.ErrVolumeSnapshotNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	return snapshot, true
+}
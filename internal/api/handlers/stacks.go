@@ -1,18 +1,31 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/go-chi/chi/v5"
-	"github.com/gorilla/websocket"
+	"docker-deploy-app/internal/api/sockets"
+	"docker-deploy-app/internal/backup"
 	"docker-deploy-app/internal/config"
 	"docker-deploy-app/internal/docker"
 	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/newt"
+	"docker-deploy-app/internal/tasks"
 )
 
 // StacksHandler handles stack-related HTTP requests
@@ -21,30 +34,60 @@ type StacksHandler struct {
 	dockerClient *client.Client
 	config       *config.Config
 	compose      *docker.ComposeManager
-	upgrader     websocket.Upgrader
+	swarm        *docker.SwarmManager
+	updates      *docker.UpdateChecker
+	updater      *docker.AutoUpdater
+	newtStatus   *newt.StatusCollector
+	containers   *docker.Client
+	stats        *docker.StatsCache
+	sockets      *sockets.Manager
+	volumes      *docker.VolumeBrowser
+	snapshots    *docker.VolumeSnapshots
+	tasks        *tasks.Tracker
+	cronjobs     *docker.CronRunner
 }
 
+// statsTimeout bounds sampling the resource usage of one container
+const statsTimeout = 5 * time.Second
+
 // NewStacksHandler creates a new stacks handler
-func NewStacksHandler(db *sql.DB, dockerClient *client.Client, config *config.Config) *StacksHandler {
+func NewStacksHandler(db *sql.DB, dockerClient *client.Client, config *config.Config, sockets *sockets.Manager) *StacksHandler {
+	compose := docker.NewComposeManager("./deployments", time.Duration(config.Docker.ComposeTimeout)*time.Second)
+	swarm := docker.NewSwarmManager(dockerClient, "./deployments", time.Duration(config.Docker.ComposeTimeout)*time.Second)
+	updates := docker.NewUpdateChecker(db, dockerClient)
+	updater := docker.NewAutoUpdater(db, dockerClient, updates, compose, swarm)
+	safety := backup.NewSafetyBackups(backup.NewManager(db, dockerClient, config.Backup, "./deployments"), config.Backup.Safety)
+	updater.SetBeforeUpgrade(safety.BeforeUpgrade)
+	containers := docker.WrapClient(dockerClient)
+
 	return &StacksHandler{
 		db:           db,
 		dockerClient: dockerClient,
 		config:       config,
-		compose:      docker.NewComposeManager("./deployments", time.Duration(config.Docker.ComposeTimeout)*time.Second),
-		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
-		},
+		compose:      compose,
+		swarm:        swarm,
+		updates:      updates,
+		updater:      updater,
+		newtStatus:   newt.NewStatusCollector(db, dockerClient),
+		containers:   containers,
+		stats:        docker.NewStatsCache(containers, time.Duration(config.Monitoring.StatsInterval)*time.Second),
+		sockets:      sockets,
+		volumes:      docker.NewVolumeBrowser(dockerClient, config.Docker.VolumeBrowser),
+		snapshots:    docker.NewVolumeSnapshots(db, dockerClient, config.Docker.VolumeSnapshots, config.Docker.VolumeBrowser.HelperImage),
+		tasks:        tasks.NewTracker(db),
+		cronjobs:     docker.NewCronRunner(db, dockerClient),
 	}
 }
 
 // List returns all running stacks
 func (h *StacksHandler) List(w http.ResponseWriter, r *http.Request) {
 	status := r.URL.Query().Get("status")
+	project := r.URL.Query().Get("project")
 	limit := getIntParam(r, "limit", 50)
 
 	query := `
-		SELECT d.id, d.stack_name, d.status, d.newt_injected, d.tunnel_url, 
-		       d.created_at, t.name as template_name
+		SELECT d.id, d.stack_name, COALESCE(d.project_id, 'global'), d.status, d.deploy_mode, d.newt_injected,
+		       d.tunnel_url, d.created_at, t.name as template_name
 		FROM deployments d
 		LEFT JOIN templates t ON d.template_id = t.id
 		WHERE 1=1`
@@ -58,6 +101,12 @@ func (h *StacksHandler) List(w http.ResponseWriter, r *http.Request) {
 		args = append(args, status)
 	}
 
+	if project != "" {
+		argCount++
+		query += fmt.Sprintf(" AND COALESCE(d.project_id, 'global') = $%d", argCount)
+		args = append(args, project)
+	}
+
 	query += " ORDER BY d.created_at DESC"
 	argCount++
 	query += fmt.Sprintf(" LIMIT $%d", argCount)
@@ -72,25 +121,28 @@ func (h *StacksHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	var stacks []map[string]interface{}
 	for rows.Next() {
-		var deploymentID, stackName, status, templateName string
+		var deploymentID, stackName, projectID, status, templateName string
+		var deployMode models.DeployMode
 		var newtInjected bool
 		var tunnelURL sql.NullString
 		var createdAt time.Time
 
-		err := rows.Scan(&deploymentID, &stackName, &status, &newtInjected, 
+		err := rows.Scan(&deploymentID, &stackName, &projectID, &status, &deployMode, &newtInjected,
 			&tunnelURL, &createdAt, &templateName)
 		if err != nil {
 			continue
 		}
 
 		// Get stack details from Docker
-		stackStatus, _ := h.compose.GetStackStatus(stackName)
-		services, _ := h.compose.GetServices(stackName)
+		stackStatus, _ := h.getStackStatus(stackName, deployMode)
+		services, _ := h.getServices(stackName, deployMode)
 
 		stack := map[string]interface{}{
 			"id":            deploymentID,
 			"name":          stackName,
+			"project_id":    projectID,
 			"status":        stackStatus,
+			"deploy_mode":   deployMode,
 			"template_name": templateName,
 			"services":      len(services),
 			"running_services": h.countRunningServices(services),
@@ -114,14 +166,15 @@ func (h *StacksHandler) Get(w http.ResponseWriter, r *http.Request) {
 	stackID := chi.URLParam(r, "id")
 
 	var stackName, templateName string
+	var deployMode models.DeployMode
 	var newtInjected bool
 	var tunnelURL sql.NullString
 
 	err := h.db.QueryRow(`
-		SELECT d.stack_name, d.newt_injected, d.tunnel_url, t.name
+		SELECT d.stack_name, d.deploy_mode, d.newt_injected, d.tunnel_url, t.name
 		FROM deployments d
 		LEFT JOIN templates t ON d.template_id = t.id
-		WHERE d.id = $1`, stackID).Scan(&stackName, &newtInjected, &tunnelURL, &templateName)
+		WHERE d.id = $1`, stackID).Scan(&stackName, &deployMode, &newtInjected, &tunnelURL, &templateName)
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Stack not found", http.StatusNotFound)
@@ -133,19 +186,23 @@ func (h *StacksHandler) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get services from Docker
-	services, _ := h.compose.GetServices(stackName)
-	status, _ := h.compose.GetStackStatus(stackName)
+	services, _ := h.getServices(stackName, deployMode)
+	status, _ := h.getStackStatus(stackName, deployMode)
+	loops, _ := h.listRestartLoops(stackID, false)
 
 	response := map[string]interface{}{
 		"id":            stackID,
 		"name":          stackName,
 		"status":        status,
+		"deploy_mode":   deployMode,
 		"template_name": templateName,
 		"newt_injected": newtInjected,
 		"tunnel_url":    tunnelURL.String,
 		"services":      services,
 		"service_count": len(services),
 		"running_services": h.countRunningServices(services),
+		"degraded":      len(loops) > 0,
+		"restart_loops": loops,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -161,12 +218,11 @@ func (h *StacksHandler) Start(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.compose.Start(stackName); err != nil {
+	if err := h.setStackRunning(stackID, stackName, true); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to start stack: %v", err), http.StatusInternalServerError)
 		return
 	}
-
-	h.updateDeploymentStatus(stackID, models.StatusRunning)
+	docker.ClearRestartLoops(h.db, stackID) // Started again on purpose, so no longer degraded
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -183,13 +239,11 @@ func (h *StacksHandler) Stop(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.compose.Stop(stackName); err != nil {
+	if err := h.setStackRunning(stackID, stackName, false); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to stop stack: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	h.updateDeploymentStatus(stackID, models.StatusStopped)
-
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message": "Stack stopped successfully",
@@ -209,6 +263,7 @@ func (h *StacksHandler) Restart(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Failed to restart stack: %v", err), http.StatusInternalServerError)
 		return
 	}
+	docker.ClearRestartLoops(h.db, stackID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -265,6 +320,164 @@ func (h *StacksHandler) StreamLogs(w http.ResponseWriter, r *http.Request) {
 	cmd.Run()
 }
 
+// GetServiceLogs returns the logs of one service's containers, streaming them with
+// follow=true. tail takes a line count or "all" and since an RFC 3339 time or a
+// duration before now such as 10m. With several replicas each line is prefixed
+// with its container's short ID, unless container= picks one
+func (h *StacksHandler) GetServiceLogs(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	service := chi.URLParam(r, "service")
+	stackName := h.getStackName(stackID)
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	options := types.ContainerLogsOptions{
+		Tail:       "100",
+		Follow:     r.URL.Query().Get("follow") == "true",
+		Timestamps: r.URL.Query().Get("timestamps") == "true",
+	}
+	if tail := r.URL.Query().Get("tail"); tail != "" {
+		if _, err := strconv.Atoi(tail); err != nil && tail != "all" {
+			http.Error(w, "Invalid tail: use a number of lines or all", http.StatusBadRequest)
+			return
+		}
+		options.Tail = tail
+	}
+	if value := r.URL.Query().Get("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			ago, durationErr := time.ParseDuration(value)
+			if durationErr != nil {
+				http.Error(w, "Invalid since: use an RFC 3339 time or a duration such as 10m", http.StatusBadRequest)
+				return
+			}
+			since = time.Now().Add(-ago)
+		}
+		options.Since = strconv.FormatInt(since.Unix(), 10)
+	}
+
+	label, serviceLabel := "com.docker.compose.project", "com.docker.compose.service"
+	if h.getDeployMode(stackID) == models.DeployModeSwarm {
+		label, serviceLabel = "com.docker.stack.namespace", "com.docker.swarm.service.name"
+	}
+	all, err := h.containers.GetContainersByLabel(label, stackName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Docker error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	var containers []string
+	for _, c := range all {
+		if c.Labels[serviceLabel] != service {
+			continue
+		}
+		if id := r.URL.Query().Get("container"); id != "" && !strings.HasPrefix(c.ID, id) {
+			continue
+		}
+		containers = append(containers, c.ID)
+	}
+	if len(containers) == 0 {
+		http.Error(w, "Service has no containers", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if options.Follow {
+		w.Header().Set("Connection", "keep-alive")
+	}
+
+	// Containers are read concurrently so followed replicas interleave; lines are
+	// written whole, one at a time
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, id := range containers {
+		prefix := ""
+		if len(containers) > 1 {
+			prefix = shortID(id) + " | "
+		}
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			out := &logLineWriter{w: w, mu: &mu, prefix: prefix}
+			if err := h.copyContainerLogs(r.Context(), id, options, out); err != nil && r.Context().Err() == nil {
+				out.Write([]byte(fmt.Sprintf("error reading logs of %s: %v\n", shortID(id), err)))
+			}
+			out.flush()
+		}(id)
+	}
+	wg.Wait()
+}
+
+// copyContainerLogs writes the logs of a container to out, demultiplexing stdout
+// and stderr unless the container has a TTY
+func (h *StacksHandler) copyContainerLogs(ctx context.Context, containerID string, options types.ContainerLogsOptions, out io.Writer) error {
+	tty, err := h.containers.HasTTY(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	logs, err := h.containers.GetContainerLogsContext(ctx, containerID, options)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+
+	if tty {
+		_, err = io.Copy(out, logs)
+	} else {
+		_, err = stdcopy.StdCopy(out, out, logs)
+	}
+	return err
+}
+
+// logLineWriter writes complete log lines to a shared response, prefixed and
+// flushed one at a time so concurrent containers never split each other's lines
+type logLineWriter struct {
+	w       http.ResponseWriter
+	mu      *sync.Mutex
+	prefix  string
+	partial []byte
+}
+
+func (lw *logLineWriter) Write(p []byte) (int, error) {
+	lw.partial = append(lw.partial, p...)
+	for {
+		i := bytes.IndexByte(lw.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := lw.writeLine(lw.partial[:i+1]); err != nil {
+			return 0, err
+		}
+		lw.partial = lw.partial[i+1:]
+	}
+}
+
+// flush writes a trailing line without newline, at the end of the logs
+func (lw *logLineWriter) flush() {
+	if len(lw.partial) > 0 {
+		lw.writeLine(append(lw.partial, '\n'))
+		lw.partial = nil
+	}
+}
+
+func (lw *logLineWriter) writeLine(line []byte) error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if _, err := io.WriteString(lw.w, lw.prefix); err != nil {
+		return err
+	}
+	if _, err := lw.w.Write(line); err != nil {
+		return err
+	}
+	if f, ok := lw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
 // WebSocketLogs handles WebSocket connections for real-time logs
 func (h *StacksHandler) WebSocketLogs(w http.ResponseWriter, r *http.Request) {
 	stackID := chi.URLParam(r, "id")
@@ -274,18 +487,42 @@ func (h *StacksHandler) WebSocketLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+	// Refusals are answered by the manager
+	conn, err := h.sockets.Upgrade(w, r, stackID)
 	if err != nil {
 		return
 	}
 	defer conn.Close()
 
-	// Stream logs via WebSocket
-	for {
-		time.Sleep(1 * time.Second)
+	cmd, err := h.compose.Logs(stackName, true, 50)
+	if err != nil {
+		conn.WriteJSON(map[string]interface{}{"timestamp": time.Now(), "error": err.Error()})
+		return
+	}
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		conn.WriteJSON(map[string]interface{}{"timestamp": time.Now(), "error": err.Error()})
+		return
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		conn.WriteJSON(map[string]interface{}{"timestamp": time.Now(), "error": err.Error()})
+		return
+	}
+	defer cmd.Wait()
+
+	// Stop following once the connection is gone
+	go func() {
+		<-conn.Context().Done()
+		cmd.Process.Kill()
+	}()
+
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
 		message := map[string]interface{}{
 			"timestamp": time.Now(),
-			"message":   "Log streaming not fully implemented",
+			"message":   scanner.Text(),
 		}
 		if err := conn.WriteJSON(message); err != nil {
 			return
@@ -293,7 +530,74 @@ func (h *StacksHandler) WebSocketLogs(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// GetStats returns resource usage statistics
+// Summary returns the state of every stack for the stacks list from one container
+// listing, instead of a compose call per stack. It takes the filters of List
+func (h *StacksHandler) Summary(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	project := r.URL.Query().Get("project")
+
+	query := `
+		SELECT d.id, d.stack_name, COALESCE(d.project_id, 'global'), d.deploy_mode, d.newt_injected,
+		       COALESCE(d.tunnel_url, ''), d.created_at, COALESCE(t.name, ''),
+		       EXISTS(SELECT 1 FROM restart_loops l WHERE l.deployment_id = d.id AND l.cleared_at IS NULL)
+		FROM deployments d
+		LEFT JOIN templates t ON d.template_id = t.id
+		WHERE 1=1`
+	args := []interface{}{}
+	argCount := 0
+
+	if project != "" {
+		argCount++
+		query += fmt.Sprintf(" AND COALESCE(d.project_id, 'global') = $%d", argCount)
+		args = append(args, project)
+	}
+	query += " ORDER BY d.created_at DESC"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	containers, err := h.containers.SummarizeStacks(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Docker error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	stacks := []models.StackSummary{}
+	for rows.Next() {
+		var stack models.StackSummary
+		if err := rows.Scan(&stack.ID, &stack.Name, &stack.ProjectID, &stack.DeployMode, &stack.NewtInjected,
+			&stack.TunnelURL, &stack.CreatedAt, &stack.TemplateName, &stack.Degraded); err != nil {
+			continue
+		}
+
+		stack.Status = models.StackStatusStopped
+		if c, ok := containers[stack.Name]; ok {
+			stack.Status = c.Status
+			stack.Services, stack.RunningServices = c.Services, c.RunningServices
+			stack.Containers, stack.RunningContainers, stack.Unhealthy = c.Containers, c.RunningContainers, c.Unhealthy
+		}
+		if status != "" && string(stack.Status) != status {
+			continue
+		}
+		stacks = append(stacks, stack)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"stacks": stacks,
+		"total":  len(stacks),
+	})
+}
+
+// GetStats returns the resource usage of a stack's running containers, summed
+// per service and for the whole stack. Samples come from the stats cache, and
+// age tells how old the oldest one is; with ?fresh=true containers are sampled
+// directly. A container that does not answer within statsTimeout is reported in
+// errors and left out
 func (h *StacksHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	stackID := chi.URLParam(r, "id")
 	stackName := h.getStackName(stackID)
@@ -302,19 +606,114 @@ func (h *StacksHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	services, _ := h.compose.GetServices(stackName)
-	
-	stats := map[string]interface{}{
-		"stack_name":       stackName,
-		"total_services":   len(services),
-		"running_services": h.countRunningServices(services),
-		"updated_at":       time.Now(),
+	deployMode := h.getDeployMode(stackID)
+	services, _ := h.getServices(stackName, deployMode)
+
+	label, serviceLabel := "com.docker.compose.project", "com.docker.compose.service"
+	if deployMode == models.DeployModeSwarm {
+		label, serviceLabel = "com.docker.stack.namespace", "com.docker.swarm.service.name"
+	}
+	containers, err := h.containers.GetContainersByLabel(label, stackName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Docker error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	fresh := r.URL.Query().Get("fresh") == "true"
+	type sample struct {
+		service string
+		stats   *models.ServiceStats
+		err     error
+	}
+	var samples []*sample
+	var wg sync.WaitGroup
+	for _, c := range containers {
+		if c.State != "running" {
+			continue
+		}
+		s := &sample{service: c.Labels[serviceLabel]}
+		samples = append(samples, s)
+
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if fresh {
+				ctx, cancel := context.WithTimeout(r.Context(), statsTimeout)
+				defer cancel()
+				s.stats, s.err = h.containers.GetContainerStatsContext(ctx, id)
+			} else {
+				s.stats, s.err = h.stats.Get(r.Context(), id)
+			}
+			if s.err != nil {
+				s.err = fmt.Errorf("%s: %w", shortID(id), s.err)
+			}
+		}(c.ID)
+	}
+	wg.Wait()
+
+	stats := models.StackStats{
+		StackName:       stackName,
+		TotalServices:   len(services),
+		RunningServices: h.countRunningServices(services),
+		Services:        []models.ServiceStats{},
+		UpdatedAt:       time.Now(),
+	}
+	byService := make(map[string]*models.ServiceStats)
+	var names []string
+	for _, s := range samples {
+		if s.err != nil {
+			stats.Errors = append(stats.Errors, s.err.Error())
+			continue
+		}
+		service := byService[s.service]
+		if service == nil {
+			service = &models.ServiceStats{Name: s.service}
+			byService[s.service] = service
+			names = append(names, s.service)
+		}
+		service.Add(s.stats)
+
+		stats.Containers++
+		stats.CPUUsage += s.stats.CPUUsage
+		stats.MemoryUsage += s.stats.MemoryUsage
+		stats.MemoryLimit += s.stats.MemoryLimit
+		stats.NetworkRx += s.stats.NetworkRx
+		stats.NetworkTx += s.stats.NetworkTx
+		stats.BlockRead += s.stats.BlockRead
+		stats.BlockWrite += s.stats.BlockWrite
+		stats.PIDs += s.stats.PIDs
+		if stats.SampledAt.IsZero() || s.stats.UpdatedAt.Before(stats.SampledAt) {
+			stats.SampledAt = s.stats.UpdatedAt
+		}
+	}
+	if !stats.SampledAt.IsZero() {
+		stats.Age = time.Since(stats.SampledAt).Seconds()
+	}
+	if stats.MemoryLimit > 0 {
+		stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		stats.Services = append(stats.Services, *byService[name])
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
 
+// StatsCache returns the cache serving stack stats, to be started with the server
+func (h *StacksHandler) StatsCache() *docker.StatsCache {
+	return h.stats
+}
+
+// shortID returns the short form of a container ID, as docker ps shows it
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
 // GetNewtStatus returns Newt tunnel status
 func (h *StacksHandler) GetNewtStatus(w http.ResponseWriter, r *http.Request) {
 	stackID := chi.URLParam(r, "id")
@@ -332,30 +731,248 @@ func (h *StacksHandler) GetNewtStatus(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
 		"newt_injected": newtInjected,
 		"tunnel_url":    tunnelURL.String,
-		"tunnel_active": tunnelURL.String != "",
+		"tunnel_active": false,
 		"status":        "unknown",
 	}
 
 	if newtInjected {
-		// Check if newt container is running
-		services, _ := h.compose.GetServices(stackName)
-		for _, service := range services {
-			if service.Name == "newt" {
-				response["status"] = service.Status
-				break
-			}
+		status, err := h.newtStatus.Collect(stackID, stackName)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to collect newt status: %v", err), http.StatusInternalServerError)
+			return
 		}
+		status.TunnelURL = tunnelURL.String
+
+		response["status"] = status.Status
+		response["tunnel_active"] = status.TunnelActive
+		response["newt"] = status
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetNewtEvents returns recorded tunnel state transitions for a stack
+func (h *StacksHandler) GetNewtEvents(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	limit := getIntParam(r, "limit", 50)
+
+	events, err := h.newtStatus.GetEvents(stackID, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": events,
+		"total":  len(events),
+	})
+}
+
+// GetEvents returns the recorded container events of a stack, newest first. since
+// accepts an RFC 3339 time or a duration before now such as 24h; service and
+// action narrow the events to one service or action
+func (h *StacksHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	if h.getStackName(stackID) == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	query := `
+		SELECT id, deployment_id, COALESCE(service_name, ''), container_id, action, exit_code,
+		       COALESCE(image, ''), created_at
+		FROM stack_events
+		WHERE deployment_id = $1`
+	args := []interface{}{stackID}
+
+	if value := r.URL.Query().Get("since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			ago, durationErr := time.ParseDuration(value)
+			if durationErr != nil {
+				http.Error(w, "Invalid since: use an RFC 3339 time or a duration such as 1h", http.StatusBadRequest)
+				return
+			}
+			since = time.Now().Add(-ago)
+		}
+		args = append(args, since)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if service := r.URL.Query().Get("service"); service != "" {
+		args = append(args, service)
+		query += fmt.Sprintf(" AND service_name = $%d", len(args))
+	}
+	if action := r.URL.Query().Get("action"); action != "" {
+		args = append(args, action)
+		query += fmt.Sprintf(" AND action = $%d", len(args))
+	}
+	args = append(args, getIntParam(r, "limit", 100))
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	events := []models.StackEvent{}
+	for rows.Next() {
+		var event models.StackEvent
+		var exitCode sql.NullInt64
+		if err := rows.Scan(&event.ID, &event.DeploymentID, &event.ServiceName, &event.ContainerID, &event.Action,
+			&exitCode, &event.Image, &event.CreatedAt); err != nil {
+			continue
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			event.ExitCode = &code
+		}
+		events = append(events, event)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": events,
+		"total":  len(events),
+	})
+}
+
+// GetRestartLoops returns the restart loops of a stack's services. Cleared loops
+// are included with ?all=true
+func (h *StacksHandler) GetRestartLoops(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	if h.getStackName(stackID) == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	loops, err := h.listRestartLoops(stackID, r.URL.Query().Get("all") == "true")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	degraded := false
+	for _, loop := range loops {
+		degraded = degraded || loop.ClearedAt == nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"restart_loops": loops,
+		"degraded":      degraded,
+		"total":         len(loops),
+	})
+}
+
+// ClearRestartLoops acknowledges the restart loops of a stack, ending its degraded
+// state. Services stopped for looping stay stopped until the stack is started
+func (h *StacksHandler) ClearRestartLoops(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	if h.getStackName(stackID) == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	cleared, err := docker.ClearRestartLoops(h.db, stackID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cleared": cleared,
+		"message": "Restart loops cleared",
+	})
+}
+
 // Export exports stack configuration
 func (h *StacksHandler) Export(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Stack export not implemented", http.StatusNotImplemented)
 }
 
+// GetUpdates returns image update status for a stack
+func (h *StacksHandler) GetUpdates(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	stackName := h.getStackName(stackID)
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	var updates []models.ImageUpdate
+	var err error
+	if r.URL.Query().Get("refresh") == "true" {
+		updates, err = h.updates.CheckStack(&models.Deployment{
+			ID:         stackID,
+			StackName:  stackName,
+			DeployMode: h.getDeployMode(stackID),
+		})
+	} else {
+		updates, err = h.updates.GetUpdates(stackID)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to check updates: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	available := 0
+	for _, update := range updates {
+		if update.UpdateAvailable {
+			available++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"stack_id":          stackID,
+		"updates":           updates,
+		"updates_available": available,
+	})
+}
+
+// Upgrade pulls new images and recreates only the services that changed,
+// rolling back if they do not come up healthy
+func (h *StacksHandler) Upgrade(w http.ResponseWriter, r *http.Request) {
+	stackID := chi.URLParam(r, "id")
+	stackName := h.getStackName(stackID)
+	if stackName == "" {
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	deployment := &models.Deployment{
+		ID:         stackID,
+		StackName:  stackName,
+		DeployMode: h.getDeployMode(stackID),
+	}
+
+	revision, err := h.updater.Apply(deployment, models.UpdatePolicyAny, "manual")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to upgrade stack: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if revision == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":  "Stack is up to date",
+			"upgraded": []string{},
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Stack upgraded successfully",
+		"upgraded": revision.Services,
+		"revision": revision.Revision,
+	})
+}
+
 // Helper functions
 func (h *StacksHandler) getStackName(stackID string) string {
 	var stackName string
@@ -363,6 +980,28 @@ func (h *StacksHandler) getStackName(stackID string) string {
 	return stackName
 }
 
+func (h *StacksHandler) getDeployMode(stackID string) models.DeployMode {
+	var deployMode models.DeployMode
+	h.db.QueryRow("SELECT deploy_mode FROM deployments WHERE id = $1", stackID).Scan(&deployMode)
+	return deployMode
+}
+
+// getServices returns stack services from compose or the swarm API depending on deploy mode
+func (h *StacksHandler) getServices(stackName string, deployMode models.DeployMode) ([]models.StackService, error) {
+	if deployMode == models.DeployModeSwarm {
+		return h.swarm.GetServices(stackName)
+	}
+	return h.compose.GetServices(stackName)
+}
+
+// getStackStatus returns stack status from compose or the swarm API depending on deploy mode
+func (h *StacksHandler) getStackStatus(stackName string, deployMode models.DeployMode) (models.StackStatus, error) {
+	if deployMode == models.DeployModeSwarm {
+		return h.swarm.GetStackStatus(stackName)
+	}
+	return h.compose.GetStackStatus(stackName)
+}
+
 func (h *StacksHandler) updateDeploymentStatus(deploymentID string, status models.DeploymentStatus) {
 	h.db.Exec("UPDATE deployments SET status = $1, updated_at = $2 WHERE id = $3",
 		status, time.Now(), deploymentID)
@@ -376,4 +1015,38 @@ func (h *StacksHandler) countRunningServices(services []models.StackService) int
 		}
 	}
 	return count
-}
\ No newline at end of file
+}
+
+// listRestartLoops returns the restart loops of a deployment, newest first,
+// including cleared ones when all is set
+func (h *StacksHandler) listRestartLoops(deploymentID string, all bool) ([]models.RestartLoop, error) {
+	query := `
+		SELECT id, deployment_id, service_name, restarts, window_minutes, stopped, detected_at, cleared_at
+		FROM restart_loops
+		WHERE deployment_id = $1`
+	if !all {
+		query += " AND cleared_at IS NULL"
+	}
+	query += " ORDER BY detected_at DESC"
+
+	rows, err := h.db.Query(query, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	loops := []models.RestartLoop{}
+	for rows.Next() {
+		var loop models.RestartLoop
+		var clearedAt sql.NullTime
+		if err := rows.Scan(&loop.ID, &loop.DeploymentID, &loop.ServiceName, &loop.Restarts, &loop.Window,
+			&loop.Stopped, &loop.DetectedAt, &clearedAt); err != nil {
+			return nil, err
+		}
+		if clearedAt.Valid {
+			loop.ClearedAt = &clearedAt.Time
+		}
+		loops = append(loops, loop)
+	}
+	return loops, rows.Err()
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/tasks"
+)
+
+// TasksHandler serves the background task activity feed
+type TasksHandler struct {
+	db     *sql.DB
+	config *config.Config
+	tasks  *tasks.Tracker
+}
+
+// NewTasksHandler creates a new tasks handler
+func NewTasksHandler(db *sql.DB, config *config.Config) *TasksHandler {
+	return &TasksHandler{
+		db:     db,
+		config: config,
+		tasks:  tasks.NewTracker(db),
+	}
+}
+
+// List returns recent tasks, newest first
+func (h *TasksHandler) List(w http.ResponseWriter, r *http.Request) {
+	opts := tasks.ListOptions{
+		Type:       r.URL.Query().Get("type"),
+		State:      r.URL.Query().Get("state"),
+		ResourceID: r.URL.Query().Get("resource_id"),
+		Limit:      getIntParam(r, "limit", 50),
+		Offset:     getIntParam(r, "offset", 0),
+	}
+
+	if opts.State != "" && !models.IsValidTaskState(opts.State) {
+		http.Error(w, fmt.Sprintf("Invalid task state: %s", opts.State), http.StatusBadRequest)
+		return
+	}
+	if opts.Limit > 500 {
+		opts.Limit = 500
+	}
+
+	list, err := h.tasks.List(opts)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	visible := []*models.Task{}
+	for _, task := range list {
+		if h.visible(r, task) {
+			visible = append(visible, task)
+		}
+	}
+	list = visible
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tasks":  list,
+		"limit":  opts.Limit,
+		"offset": opts.Offset,
+	})
+}
+
+// Get returns a specific task
+func (h *TasksHandler) Get(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "id")
+
+	task, err := h.tasks.Get(taskID)
+	if err == nil && !h.visible(r, task) {
+		err = sql.ErrNoRows // Tasks of other projects are not disclosed
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+// visible reports whether the caller may view a task. Tasks of a deployment or a
+// backup need the viewer role in its projects; others, such as template syncs, are
+// not tied to a project
+func (h *TasksHandler) visible(r *http.Request, task *models.Task) bool {
+	user := apiMiddleware.UserFromContext(r.Context())
+	if user == nil || task.ResourceID == "" {
+		return true
+	}
+
+	switch task.Type {
+	case models.TaskTypeDeployment, models.TaskTypeVolume:
+		return apiMiddleware.HasDeploymentRole(h.db, user, task.ResourceID, "viewer")
+	case models.TaskTypeBackup, models.TaskTypeRestore:
+		allowed, err := apiMiddleware.HasBackupRole(h.db, user, task.ResourceID, "viewer")
+		return err == nil && allowed
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/github"
+	"docker-deploy-app/internal/models"
+)
+
+// templateVersions returns the synced versions of a template, newest first, and
+// its current version. ok is false when the template does not exist
+func (h *TemplatesHandler) templateVersions(id string, withNotes bool) (versions []models.TemplateVersion, current string, ok bool, err error) {
+	err = h.db.QueryRow("SELECT COALESCE(version, '') FROM templates WHERE id = $1", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return nil, "", false, nil
+	}
+	if err != nil {
+		return nil, "", false, err
+	}
+	current = github.NormalizeVersion(current)
+
+	rows, err := h.db.Query(`
+		SELECT version, tag_name, COALESCE(name, ''), COALESCE(notes, ''), COALESCE(url, ''), prerelease, published_at
+		FROM template_versions WHERE template_id = $1 ORDER BY position`, id)
+	if err != nil {
+		return nil, "", false, err
+	}
+	defer rows.Close()
+
+	versions = []models.TemplateVersion{}
+	for rows.Next() {
+		var v models.TemplateVersion
+		var publishedAt sql.NullTime
+		if err := rows.Scan(&v.Version, &v.TagName, &v.Name, &v.Notes, &v.URL, &v.Prerelease, &publishedAt); err != nil {
+			return nil, "", false, err
+		}
+		if publishedAt.Valid {
+			v.PublishedAt = &publishedAt.Time
+		}
+		if !withNotes {
+			v.Notes = ""
+		}
+		v.IsCurrent = v.Version == current
+		versions = append(versions, v)
+	}
+	return versions, current, true, rows.Err()
+}
+
+// GetChangelog returns the release notes of a template's versions, newest first,
+// as synced from the GitHub releases of its repository. since, the version a
+// deployment runs, limits them to the newer versions, to review before upgrading.
+// A since version that is unknown, such as one older than the synced releases,
+// returns them all
+func (h *TemplatesHandler) GetChangelog(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	versions, current, ok, err := h.templateVersions(id, true)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	since := r.URL.Query().Get("since")
+	if since != "" {
+		since = github.NormalizeVersion(since)
+		for i, v := range versions {
+			if v.Version == since {
+				versions = versions[:i]
+				break
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"template_id":     id,
+		"current_version": current,
+		"since":           since,
+		"versions":        versions,
+	})
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// maxIconSize bounds uploaded and proxied icons
+	maxIconSize = 512 * 1024
+	// remoteIconTTL is how long a proxied icon is served before it is fetched again
+	remoteIconTTL = 24 * time.Hour
+)
+
+// iconTypes are the image types accepted for icons, as detected from their
+// content. SVG is left out: it can carry scripts
+var iconTypes = map[string]bool{
+	"image/png":    true,
+	"image/jpeg":   true,
+	"image/gif":    true,
+	"image/webp":   true,
+	"image/x-icon": true,
+}
+
+// iconClient fetches remote icons. It refuses private and loopback addresses, so a
+// template cannot make the server probe its own network
+var iconClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+					ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+					return fmt.Errorf("icon host %s is not a public address", host)
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+}
+
+// GetIcon serves the icon image of a template: the uploaded one, or else a cached
+// copy of the remote icon URL of its .template.json. Emoji icons have no image
+func (h *TemplatesHandler) GetIcon(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var icon string
+	err := h.db.QueryRow("SELECT icon FROM templates WHERE id = $1", id).Scan(&icon)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if path, ok := h.uploadedIconPath(id); ok {
+		if _, err := os.Stat(path); err == nil {
+			serveIcon(w, r, path)
+			return
+		}
+	}
+
+	if !strings.HasPrefix(icon, "https://") && !strings.HasPrefix(icon, "http://") {
+		http.Error(w, "Template has no icon image", http.StatusNotFound)
+		return
+	}
+
+	path, err := h.remoteIcon(r.Context(), icon)
+	if err != nil {
+		slog.Warn("Failed to fetch template icon", "template_id", id, "url", icon, "error", err)
+		http.Error(w, fmt.Sprintf("Failed to fetch icon: %v", err), http.StatusBadGateway)
+		return
+	}
+	serveIcon(w, r, path)
+}
+
+// UploadIcon stores an icon image for a template, sent as the "icon" field of a
+// multipart form. It takes precedence over the icon of .template.json
+func (h *TemplatesHandler) UploadIcon(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var exists bool
+	if err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM templates WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	path, ok := h.uploadedIconPath(id)
+	if !exists || !ok {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxIconSize+64*1024)
+	file, _, err := r.FormFile("icon")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid upload, expected an image in the icon field of a form of at most %d KB: %v",
+			maxIconSize/1024, err), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, contentType, err := readIcon(file)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid icon: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := writeIcon(path, data); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to store icon: %v", err), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Template icon uploaded", "template_id", id, "type", contentType, "size", len(data))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      "Icon uploaded",
+		"content_type": contentType,
+		"size":         len(data),
+	})
+}
+
+// DeleteIcon removes the uploaded icon of a template, restoring its .template.json icon
+func (h *TemplatesHandler) DeleteIcon(w http.ResponseWriter, r *http.Request) {
+	path, ok := h.uploadedIconPath(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "Template has no uploaded icon", http.StatusNotFound)
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			http.Error(w, "Template has no uploaded icon", http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to delete icon: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Icon deleted",
+	})
+}
+
+// iconDir is where icons are stored, next to the database: data/icons by default
+func (h *TemplatesHandler) iconDir() string {
+	return filepath.Join(filepath.Dir(h.config.Database.Path), "icons")
+}
+
+// uploadedIconPath returns the file of a template's uploaded icon. Template IDs are
+// derived from repository names; any that would leave the directory is refused
+func (h *TemplatesHandler) uploadedIconPath(id string) (string, bool) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", false
+	}
+	return filepath.Join(h.iconDir(), id), true
+}
+
+// remoteIcon returns the cached copy of a remote icon, fetching it when it is
+// missing or older than remoteIconTTL. The cache directory starts with a dot, so
+// it cannot collide with a template ID. A stale copy is served when the fetch fails
+func (h *TemplatesHandler) remoteIcon(ctx context.Context, url string) (string, error) {
+	sum := sha256.Sum256([]byte(url))
+	path := filepath.Join(h.iconDir(), ".cache", hex.EncodeToString(sum[:16]))
+
+	info, statErr := os.Stat(path)
+	if statErr == nil && time.Since(info.ModTime()) < remoteIconTTL {
+		return path, nil
+	}
+
+	err := fetchIcon(ctx, url, path)
+	if err != nil && statErr == nil {
+		slog.Warn("Serving stale template icon", "url", url, "error", err)
+		return path, nil
+	}
+	return path, err
+}
+
+func fetchIcon(ctx context.Context, url, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "docker-deploy-icons/1.0")
+
+	resp, err := iconClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("icon server responded with %s", resp.Status)
+	}
+
+	data, _, err := readIcon(resp.Body)
+	if err != nil {
+		return err
+	}
+	return writeIcon(path, data)
+}
+
+// readIcon reads an icon image, checking its size and detected type
+func readIcon(r io.Reader) ([]byte, string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxIconSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) == 0 {
+		return nil, "", fmt.Errorf("empty image")
+	}
+	if len(data) > maxIconSize {
+		return nil, "", fmt.Errorf("larger than %d KB", maxIconSize/1024)
+	}
+	contentType := http.DetectContentType(data)
+	if !iconTypes[contentType] {
+		return nil, "", fmt.Errorf("unsupported type %s, expected PNG, JPEG, GIF, WebP or ICO", contentType)
+	}
+	return data, contentType, nil
+}
+
+// writeIcon replaces an icon file atomically, so it is never served half written
+func writeIcon(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".icon-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// serveIcon sends an icon file with its detected type. The type is forced and
+// sniffing disabled, so a file is never rendered as anything but an image
+func serveIcon(w http.ResponseWriter, r *http.Request, path string) {
+	file, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "Icon not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "Icon not found", http.StatusNotFound)
+		return
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, "Icon not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(head[:n]))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", info.ModTime(), file)
+}
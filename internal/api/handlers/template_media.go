@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+)
+
+// mediaDir is where template media galleries are cached, next to the database:
+// data/media by default
+func mediaDir(config *config.Config) string {
+	return filepath.Join(filepath.Dir(config.Database.Path), "media")
+}
+
+// GetMedia lists the media gallery of a template, in the order of its
+// .template.json, with the URL each cached image is served from
+func (h *TemplatesHandler) GetMedia(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var exists bool
+	if err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM templates WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT position, source, COALESCE(caption, ''), file, content_type, size_bytes, synced_at
+		FROM template_media WHERE template_id = $1 ORDER BY position`, id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	media := []models.TemplateMedia{}
+	for rows.Next() {
+		var m models.TemplateMedia
+		if err := rows.Scan(&m.Position, &m.Source, &m.Caption, &m.File, &m.ContentType, &m.SizeBytes, &m.SyncedAt); err != nil {
+			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		m.URL = fmt.Sprintf("/api/templates/%s/media/%d", id, m.Position)
+		media = append(media, m)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"template_id": id,
+		"media":       media,
+	})
+}
+
+// GetMediaFile serves a cached image of a template's media gallery by position.
+// The recorded type is forced and sniffing disabled, as for icons
+func (h *TemplatesHandler) GetMediaFile(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var file, contentType string
+	err := h.db.QueryRow("SELECT file, content_type FROM template_media WHERE template_id = $1 AND position = $2",
+		id, chi.URLParam(r, "position")).Scan(&file, &contentType)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Template IDs are derived from repository names; any that would leave the
+	// directory is refused
+	if id != filepath.Base(id) || file != filepath.Base(file) {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(mediaDir(h.config), id, file))
+	if err != nil {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
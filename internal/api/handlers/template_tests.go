@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/models"
+)
+
+// Test test deploys the current version of a template in the background: it is
+// deployed into a throwaway stack with generated values, waited on until its
+// services are healthy and torn down. A pass badges the version as verified
+// working
+func (h *TemplatesHandler) Test(w http.ResponseWriter, r *http.Request) {
+	templateID := chi.URLParam(r, "id")
+
+	var t models.Template
+	var variablesJSON string
+	err := h.db.QueryRow("SELECT id, version, variables FROM templates WHERE id = $1", templateID).Scan(
+		&t.ID, &t.Version, &variablesJSON,
+	)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	t.UnmarshalVariables(variablesJSON)
+
+	compose, err := h.repos.GetDockerComposeContent(templateID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch compose file: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	// Every variable compose needs, as a deploy wizard would ask for them
+	discovered := [][]models.TemplateVariable{}
+	if envExample, err := h.repos.GetTemplateFile(templateID, ".env.example"); err == nil {
+		discovered = append(discovered, docker.ParseEnvExample(envExample))
+	}
+	discovered = append(discovered, docker.ExtractVariables(compose))
+	variables := docker.MergeVariables(t.Variables, discovered...)
+
+	test, err := h.tester.Start(t.ID, t.Version, requestedBy(r))
+	if err == models.ErrTemplateTestRunning {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	taskID := h.tasks.Start(models.TaskTypeTemplateTest, t.ID, fmt.Sprintf("Testing %s %s", t.ID, t.Version))
+	go func() {
+		err := h.tester.Run(test, compose, variables)
+		if err != nil {
+			slog.Error("Template test failed", "template_id", t.ID, "version", t.Version, "error", err)
+		}
+		h.tasks.Finish(taskID, err)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"test":    test,
+		"task_id": taskID,
+		"message": "Template test started",
+	})
+}
+
+// ListTests returns the latest tests of a template, newest first, and whether
+// its current version is verified working
+func (h *TemplatesHandler) ListTests(w http.ResponseWriter, r *http.Request) {
+	templateID := chi.URLParam(r, "id")
+
+	var t models.Template
+	err := h.db.QueryRow("SELECT version, COALESCE(tested_version, '') FROM templates WHERE id = $1", templateID).Scan(
+		&t.Version, &t.TestedVersion,
+	)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	t.MarkVerifiedWorking()
+
+	tests, err := h.tester.List(templateID, getIntParam(r, "limit", 20))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"template_id":      templateID,
+		"version":          t.Version,
+		"tested_version":   t.TestedVersion,
+		"verified_working": t.VerifiedWorking,
+		"tests":            tests,
+	})
+}
+
+// GetTest returns a test of a template with the logs captured before teardown
+func (h *TemplatesHandler) GetTest(w http.ResponseWriter, r *http.Request) {
+	testID, err := strconv.ParseInt(chi.URLParam(r, "test"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid test ID", http.StatusBadRequest)
+		return
+	}
+
+	test, err := h.tester.Get(chi.URLParam(r, "id"), testID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Template test not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(test)
+}
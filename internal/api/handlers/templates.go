@@ -4,26 +4,65 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/docker/docker/client"
 	"github.com/go-chi/chi/v5"
+	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/analytics"
 	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/github"
 	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/moderation"
+	"docker-deploy-app/internal/tasks"
 )
 
 // TemplatesHandler handles template-related HTTP requests
 type TemplatesHandler struct {
-	db     *sql.DB
-	config *config.Config
+	db        *sql.DB
+	config    *config.Config
+	repos     *github.RepositoryService
+	syncer    *github.SyncService
+	planner   *docker.Planner
+	resources *docker.ResourceChecker
+	platform  *docker.HostPlatform
+	stats     *analytics.Recorder
+	moderator *moderation.Moderator
+	tester    *docker.TemplateTester
+	tasks     *tasks.Tracker
 }
 
 // NewTemplatesHandler creates a new templates handler
-func NewTemplatesHandler(db *sql.DB, config *config.Config) *TemplatesHandler {
+func NewTemplatesHandler(db *sql.DB, dockerClient *client.Client, config *config.Config) *TemplatesHandler {
+	githubClient, err := github.ResolveClient(db, config)
+	if err != nil {
+		slog.Warn("GitHub credentials unavailable, using token", "error", err)
+		githubClient = github.NewClient(config.GitHub.Token)
+	}
+
+	repos := github.NewRepositoryService(githubClient, db)
+	repos.SetMediaDir(mediaDir(config))
+
 	return &TemplatesHandler{
-		db:     db,
-		config: config,
+		db:        db,
+		config:    config,
+		repos:     repos,
+		syncer:    newSyncService(githubClient, db, config),
+		planner:   docker.NewPlanner(dockerClient),
+		resources: docker.NewResourceChecker(dockerClient, "./deployments"),
+		platform:  docker.NewHostPlatform(dockerClient),
+		stats:     analytics.NewRecorder(db),
+		moderator: moderation.NewModerator(db, config.Marketplace),
+		tester: docker.NewTemplateTester(db, dockerClient, filepath.Join(os.TempDir(), "template-tests"),
+			time.Duration(config.Docker.ComposeTimeout)*time.Second, time.Duration(config.Marketplace.TestTimeout)*time.Second),
+		tasks: tasks.NewTracker(db),
 	}
 }
 
@@ -37,7 +76,8 @@ func (h *TemplatesHandler) List(w http.ResponseWriter, r *http.Request) {
 	query := `
 		SELECT id, name, description, icon, category, tags, repo_url, branch, path, version,
 		       variables, requires_newt, newt_config, publisher_id, is_verified,
-		       download_count, avg_rating, total_ratings, created_at, updated_at
+		       download_count, unique_installs, avg_rating, total_ratings, COALESCE(architectures, ''),
+		       created_at, updated_at
 		FROM templates WHERE 1=1`
 	
 	args := []interface{}{}
@@ -55,7 +95,14 @@ func (h *TemplatesHandler) List(w http.ResponseWriter, r *http.Request) {
 		args = append(args, true)
 	}
 
-	query += " ORDER BY avg_rating DESC, download_count DESC"
+	query, args, argCount = filterTags(query, args, argCount, getTagsParam(r))
+
+	arch := h.platform.Architecture(r.Context())
+	if r.URL.Query().Get("compatible") == "true" {
+		query, args, argCount = filterArchitecture(query, args, argCount, arch)
+	}
+
+	query += " ORDER BY avg_rating DESC, unique_installs DESC"
 	argCount++
 	query += fmt.Sprintf(" LIMIT $%d", argCount)
 	args = append(args, limit)
@@ -74,13 +121,14 @@ func (h *TemplatesHandler) List(w http.ResponseWriter, r *http.Request) {
 	var templates []models.Template
 	for rows.Next() {
 		var t models.Template
-		var tagsJSON, variablesJSON, newtConfigJSON string
+		var tagsJSON, variablesJSON, newtConfigJSON, architecturesJSON string
 		
 		err := rows.Scan(
 			&t.ID, &t.Name, &t.Description, &t.Icon, &t.Category, &tagsJSON,
 			&t.RepoURL, &t.Branch, &t.Path, &t.Version, &variablesJSON,
 			&t.RequiresNewt, &newtConfigJSON, &t.PublisherID, &t.IsVerified,
-			&t.DownloadCount, &t.AvgRating, &t.TotalRatings, &t.CreatedAt, &t.UpdatedAt,
+			&t.DownloadCount, &t.UniqueInstalls, &t.AvgRating, &t.TotalRatings, &architecturesJSON,
+			&t.CreatedAt, &t.UpdatedAt,
 		)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Scan error: %v", err), http.StatusInternalServerError)
@@ -91,6 +139,8 @@ func (h *TemplatesHandler) List(w http.ResponseWriter, r *http.Request) {
 		t.UnmarshalTags(tagsJSON)
 		t.UnmarshalVariables(variablesJSON)
 		t.UnmarshalNewtConfig(newtConfigJSON)
+		t.UnmarshalArchitectures(architecturesJSON)
+		t.MarkCompatible(arch)
 
 		templates = append(templates, t)
 	}
@@ -115,19 +165,23 @@ func (h *TemplatesHandler) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var t models.Template
-	var tagsJSON, variablesJSON, newtConfigJSON string
+	var tagsJSON, variablesJSON, newtConfigJSON, resourcesJSON, architecturesJSON, backupHooksJSON, hooksJSON, configFilesJSON string
 
 	query := `
 		SELECT id, name, description, icon, category, tags, repo_url, branch, path, version,
 		       variables, requires_newt, newt_config, publisher_id, is_verified,
-		       download_count, avg_rating, total_ratings, created_at, updated_at
+		       download_count, unique_installs, avg_rating, total_ratings, COALESCE(security_badge, 'unscanned'),
+		       COALESCE(license, ''), COALESCE(resources, ''), COALESCE(architectures, ''), COALESCE(backup_hooks, ''),
+		       COALESCE(lifecycle_hooks, ''), COALESCE(config_files, ''), COALESCE(tested_version, ''), created_at, updated_at
 		FROM templates WHERE id = $1`
 
 	err := h.db.QueryRow(query, templateID).Scan(
 		&t.ID, &t.Name, &t.Description, &t.Icon, &t.Category, &tagsJSON,
 		&t.RepoURL, &t.Branch, &t.Path, &t.Version, &variablesJSON,
 		&t.RequiresNewt, &newtConfigJSON, &t.PublisherID, &t.IsVerified,
-		&t.DownloadCount, &t.AvgRating, &t.TotalRatings, &t.CreatedAt, &t.UpdatedAt,
+		&t.DownloadCount, &t.UniqueInstalls, &t.AvgRating, &t.TotalRatings, &t.SecurityBadge,
+		&t.License, &resourcesJSON, &architecturesJSON, &backupHooksJSON, &hooksJSON, &configFilesJSON,
+		&t.TestedVersion, &t.CreatedAt, &t.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -143,6 +197,13 @@ func (h *TemplatesHandler) Get(w http.ResponseWriter, r *http.Request) {
 	t.UnmarshalTags(tagsJSON)
 	t.UnmarshalVariables(variablesJSON)
 	t.UnmarshalNewtConfig(newtConfigJSON)
+	t.UnmarshalResources(resourcesJSON)
+	t.UnmarshalArchitectures(architecturesJSON)
+	t.UnmarshalBackupHooks(backupHooksJSON)
+	t.UnmarshalHooks(hooksJSON)
+	t.UnmarshalConfigFiles(configFilesJSON)
+	t.MarkCompatible(h.platform.Architecture(r.Context()))
+	t.MarkVerifiedWorking()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(t)
@@ -156,7 +217,8 @@ func (h *TemplatesHandler) ListMarketplaceTemplates(w http.ResponseWriter, r *ht
 	
 	query := `
 		SELECT id, name, description, icon, category, tags, requires_newt, is_verified,
-		       download_count, avg_rating, total_ratings
+		       download_count, unique_installs, avg_rating, total_ratings, COALESCE(security_badge, 'unscanned'),
+		       COALESCE(architectures, '')
 		FROM templates 
 		WHERE total_ratings >= $1 AND avg_rating >= $2`
 	
@@ -169,6 +231,13 @@ func (h *TemplatesHandler) ListMarketplaceTemplates(w http.ResponseWriter, r *ht
 		args = append(args, category)
 	}
 
+	query, args, argCount = filterTags(query, args, argCount, getTagsParam(r))
+
+	arch := h.platform.Architecture(r.Context())
+	if r.URL.Query().Get("compatible") == "true" {
+		query, args, argCount = filterArchitecture(query, args, argCount, arch)
+	}
+
 	query += " ORDER BY avg_rating DESC, total_ratings DESC"
 	argCount++
 	query += fmt.Sprintf(" LIMIT $%d", argCount)
@@ -181,20 +250,30 @@ func (h *TemplatesHandler) ListMarketplaceTemplates(w http.ResponseWriter, r *ht
 	}
 	defer rows.Close()
 
+	// Deploy statistics only flag templates, so the listing survives without them
+	stats, err := h.stats.AllTemplateStats()
+	if err != nil {
+		slog.Warn("Failed to load template stats", "error", err)
+		stats = map[string]*models.TemplateStats{}
+	}
+
 	var templates []map[string]interface{}
 	for rows.Next() {
 		var t models.Template
-		var tagsJSON string
+		var tagsJSON, architecturesJSON string
 		
 		err := rows.Scan(
 			&t.ID, &t.Name, &t.Description, &t.Icon, &t.Category, &tagsJSON,
-			&t.RequiresNewt, &t.IsVerified, &t.DownloadCount, &t.AvgRating, &t.TotalRatings,
+			&t.RequiresNewt, &t.IsVerified, &t.DownloadCount, &t.UniqueInstalls, &t.AvgRating, &t.TotalRatings,
+			&t.SecurityBadge, &architecturesJSON,
 		)
 		if err != nil {
 			continue
 		}
 
 		t.UnmarshalTags(tagsJSON)
+		t.UnmarshalArchitectures(architecturesJSON)
+		t.MarkCompatible(arch)
 
 		template := map[string]interface{}{
 			"id":            t.ID,
@@ -206,9 +285,20 @@ func (h *TemplatesHandler) ListMarketplaceTemplates(w http.ResponseWriter, r *ht
 			"requires_newt": t.RequiresNewt,
 			"is_verified":   t.IsVerified,
 			"download_count": t.DownloadCount,
+			"unique_installs": t.UniqueInstalls,
 			"avg_rating":    t.AvgRating,
 			"total_ratings": t.TotalRatings,
 			"is_popular":    t.IsPopular(),
+			"is_flaky":      false,
+			"security_badge": t.SecurityBadge,
+		}
+		if t.Compatible != nil {
+			template["architectures"] = t.Architectures
+			template["compatible"] = *t.Compatible
+		}
+		if ts, ok := stats[t.ID]; ok {
+			template["success_rate"] = ts.SuccessRate
+			template["is_flaky"] = ts.IsFlaky(h.config.Marketplace.FlakyMinDeployments, h.config.Marketplace.FlakySuccessPercent)
 		}
 
 		templates = append(templates, template)
@@ -225,10 +315,10 @@ func (h *TemplatesHandler) ListMarketplaceTemplates(w http.ResponseWriter, r *ht
 func (h *TemplatesHandler) GetFeaturedTemplates(w http.ResponseWriter, r *http.Request) {
 	query := `
 		SELECT id, name, description, icon, category, tags, requires_newt, is_verified,
-		       download_count, avg_rating, total_ratings
+		       download_count, unique_installs, avg_rating, total_ratings, COALESCE(security_badge, 'unscanned')
 		FROM templates 
 		WHERE is_verified = true AND avg_rating >= 4.5 AND total_ratings >= 10
-		ORDER BY avg_rating DESC, download_count DESC
+		ORDER BY avg_rating DESC, unique_installs DESC
 		LIMIT $1`
 
 	rows, err := h.db.Query(query, h.config.Marketplace.FeaturedTemplateCount)
@@ -245,7 +335,8 @@ func (h *TemplatesHandler) GetFeaturedTemplates(w http.ResponseWriter, r *http.R
 		
 		err := rows.Scan(
 			&t.ID, &t.Name, &t.Description, &t.Icon, &t.Category, &tagsJSON,
-			&t.RequiresNewt, &t.IsVerified, &t.DownloadCount, &t.AvgRating, &t.TotalRatings,
+			&t.RequiresNewt, &t.IsVerified, &t.DownloadCount, &t.UniqueInstalls, &t.AvgRating, &t.TotalRatings,
+			&t.SecurityBadge,
 		)
 		if err != nil {
 			continue
@@ -261,23 +352,31 @@ func (h *TemplatesHandler) GetFeaturedTemplates(w http.ResponseWriter, r *http.R
 	})
 }
 
-// GetTrendingTemplates returns trending templates based on recent activity
+// GetTrendingTemplates returns trending templates based on recent activity, read
+// from the rolling counts of the stats job. days selects the 7 or 30 day window
 func (h *TemplatesHandler) GetTrendingTemplates(w http.ResponseWriter, r *http.Request) {
 	days := getIntParam(r, "days", 7)
 	limit := getIntParam(r, "limit", 10)
 
-	query := `
+	// Installs count each user or instance once, so repeated redeploys of one
+	// installer do not make a template trend
+	installs, deploys := "s.installs_7d", "s.deploys_7d"
+	if days > 7 {
+		days, installs, deploys = 30, "s.installs_30d", "s.deploys_30d"
+	} else {
+		days = 7
+	}
+
+	query := fmt.Sprintf(`
 		SELECT t.id, t.name, t.description, t.icon, t.category, t.tags, t.requires_newt,
-		       t.is_verified, t.download_count, t.avg_rating, t.total_ratings,
-		       COUNT(d.id) as recent_deploys
+		       t.is_verified, t.download_count, t.unique_installs, t.avg_rating, t.total_ratings,
+		       COALESCE(%[1]s, 0) as recent_installs, COALESCE(%[2]s, 0) as recent_deploys
 		FROM templates t
-		LEFT JOIN deployments d ON t.id = d.template_id 
-		    AND d.created_at > datetime('now', '-' || $1 || ' days')
-		GROUP BY t.id
-		ORDER BY recent_deploys DESC, t.download_count DESC
-		LIMIT $2`
+		LEFT JOIN template_stats s ON s.template_id = t.id
+		ORDER BY recent_installs DESC, recent_deploys DESC, t.unique_installs DESC
+		LIMIT $1`, installs, deploys)
 
-	rows, err := h.db.Query(query, days, limit)
+	rows, err := h.db.Query(query, limit)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
 		return
@@ -288,12 +387,12 @@ func (h *TemplatesHandler) GetTrendingTemplates(w http.ResponseWriter, r *http.R
 	for rows.Next() {
 		var t models.Template
 		var tagsJSON string
-		var recentDeploys int
+		var recentInstalls, recentDeploys int
 		
 		err := rows.Scan(
 			&t.ID, &t.Name, &t.Description, &t.Icon, &t.Category, &tagsJSON,
-			&t.RequiresNewt, &t.IsVerified, &t.DownloadCount, &t.AvgRating,
-			&t.TotalRatings, &recentDeploys,
+			&t.RequiresNewt, &t.IsVerified, &t.DownloadCount, &t.UniqueInstalls, &t.AvgRating,
+			&t.TotalRatings, &recentInstalls, &recentDeploys,
 		)
 		if err != nil {
 			continue
@@ -311,8 +410,10 @@ func (h *TemplatesHandler) GetTrendingTemplates(w http.ResponseWriter, r *http.R
 			"requires_newt":   t.RequiresNewt,
 			"is_verified":     t.IsVerified,
 			"download_count":  t.DownloadCount,
+			"unique_installs": t.UniqueInstalls,
 			"avg_rating":      t.AvgRating,
 			"total_ratings":   t.TotalRatings,
+			"recent_installs": recentInstalls,
 			"recent_deploys":  recentDeploys,
 		}
 
@@ -322,6 +423,7 @@ func (h *TemplatesHandler) GetTrendingTemplates(w http.ResponseWriter, r *http.R
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"templates": templates,
+		"days":      days,
 	})
 }
 
@@ -331,7 +433,7 @@ func (h *TemplatesHandler) GetTopRatedTemplates(w http.ResponseWriter, r *http.R
 
 	query := `
 		SELECT id, name, description, icon, category, tags, requires_newt, is_verified,
-		       download_count, avg_rating, total_ratings
+		       download_count, unique_installs, avg_rating, total_ratings
 		FROM templates 
 		WHERE total_ratings >= $1
 		ORDER BY avg_rating DESC, total_ratings DESC
@@ -351,7 +453,7 @@ func (h *TemplatesHandler) GetTopRatedTemplates(w http.ResponseWriter, r *http.R
 		
 		err := rows.Scan(
 			&t.ID, &t.Name, &t.Description, &t.Icon, &t.Category, &tagsJSON,
-			&t.RequiresNewt, &t.IsVerified, &t.DownloadCount, &t.AvgRating, &t.TotalRatings,
+			&t.RequiresNewt, &t.IsVerified, &t.DownloadCount, &t.UniqueInstalls, &t.AvgRating, &t.TotalRatings,
 		)
 		if err != nil {
 			continue
@@ -367,16 +469,18 @@ func (h *TemplatesHandler) GetTopRatedTemplates(w http.ResponseWriter, r *http.R
 	})
 }
 
-// GetCategories returns all available template categories
+// GetCategories returns the marketplace categories in display order, for the sidebar
 func (h *TemplatesHandler) GetCategories(w http.ResponseWriter, r *http.Request) {
-	categories := h.config.Marketplace.Categories
-	
-	// Get count for each category
-	categoryStats := make(map[string]int)
+	categories, err := listCategories(h.db)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Template count for each category, by name
+	categoryStats := make(map[string]int, len(categories))
 	for _, category := range categories {
-		var count int
-		h.db.QueryRow("SELECT COUNT(*) FROM templates WHERE category = $1", category).Scan(&count)
-		categoryStats[category] = count
+		categoryStats[category.Name] = category.Templates
 	}
 
 	response := map[string]interface{}{
@@ -388,6 +492,56 @@ func (h *TemplatesHandler) GetCategories(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetTags returns template tags with the number of templates carrying each, most
+// used first, for a tag cloud. ?category= limits the count to one category
+func (h *TemplatesHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	category := r.URL.Query().Get("category")
+	limit := getIntParam(r, "limit", 50)
+
+	query := `
+		SELECT tt.tag, COUNT(*) AS uses
+		FROM template_tags tt
+		JOIN templates t ON t.id = tt.template_id`
+	args := []interface{}{}
+	argCount := 0
+
+	if category != "" {
+		argCount++
+		query += fmt.Sprintf(" WHERE t.category = $%d", argCount)
+		args = append(args, category)
+	}
+
+	query += " GROUP BY tt.tag ORDER BY uses DESC, tt.tag"
+	argCount++
+	query += fmt.Sprintf(" LIMIT $%d", argCount)
+	args = append(args, limit)
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	type tagCount struct {
+		Tag   string `json:"tag"`
+		Count int    `json:"count"`
+	}
+	tags := []tagCount{}
+	for rows.Next() {
+		var tc tagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			continue
+		}
+		tags = append(tags, tc)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tags": tags,
+	})
+}
+
 // SearchTemplates searches templates by name, description, or tags
 func (h *TemplatesHandler) SearchTemplates(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
@@ -401,9 +555,10 @@ func (h *TemplatesHandler) SearchTemplates(w http.ResponseWriter, r *http.Reques
 
 	searchQuery := `
 		SELECT id, name, description, icon, category, tags, requires_newt, is_verified,
-		       download_count, avg_rating, total_ratings
+		       download_count, unique_installs, avg_rating, total_ratings, COALESCE(architectures, '')
 		FROM templates 
-		WHERE (name LIKE $1 OR description LIKE $1 OR tags LIKE $1)`
+		WHERE (name LIKE $1 OR description LIKE $1
+		       OR id IN (SELECT template_id FROM template_tags WHERE tag LIKE $1))`
 
 	args := []interface{}{"%" + query + "%"}
 	argCount := 1
@@ -414,7 +569,12 @@ func (h *TemplatesHandler) SearchTemplates(w http.ResponseWriter, r *http.Reques
 		args = append(args, category)
 	}
 
-	searchQuery += " ORDER BY avg_rating DESC, download_count DESC"
+	arch := h.platform.Architecture(r.Context())
+	if r.URL.Query().Get("compatible") == "true" {
+		searchQuery, args, argCount = filterArchitecture(searchQuery, args, argCount, arch)
+	}
+
+	searchQuery += " ORDER BY avg_rating DESC, unique_installs DESC"
 	argCount++
 	searchQuery += fmt.Sprintf(" LIMIT $%d", argCount)
 	args = append(args, limit)
@@ -429,17 +589,20 @@ func (h *TemplatesHandler) SearchTemplates(w http.ResponseWriter, r *http.Reques
 	var templates []models.Template
 	for rows.Next() {
 		var t models.Template
-		var tagsJSON string
+		var tagsJSON, architecturesJSON string
 		
 		err := rows.Scan(
 			&t.ID, &t.Name, &t.Description, &t.Icon, &t.Category, &tagsJSON,
-			&t.RequiresNewt, &t.IsVerified, &t.DownloadCount, &t.AvgRating, &t.TotalRatings,
+			&t.RequiresNewt, &t.IsVerified, &t.DownloadCount, &t.UniqueInstalls, &t.AvgRating, &t.TotalRatings,
+			&architecturesJSON,
 		)
 		if err != nil {
 			continue
 		}
 
 		t.UnmarshalTags(tagsJSON)
+		t.UnmarshalArchitectures(architecturesJSON)
+		t.MarkCompatible(arch)
 		templates = append(templates, t)
 	}
 
@@ -451,6 +614,73 @@ func (h *TemplatesHandler) SearchTemplates(w http.ResponseWriter, r *http.Reques
 	})
 }
 
+// DeployPlan returns the variables, newt requirements, images, ports and volumes of a
+// template so a deploy wizard can be rendered from a single request
+func (h *TemplatesHandler) DeployPlan(w http.ResponseWriter, r *http.Request) {
+	templateID := chi.URLParam(r, "id")
+
+	var t models.Template
+	var variablesJSON, newtConfigJSON, resourcesJSON, architecturesJSON string
+	err := h.db.QueryRow(`
+		SELECT id, variables, requires_newt, newt_config, COALESCE(resources, ''), COALESCE(architectures, '')
+		FROM templates WHERE id = $1`, templateID).Scan(
+		&t.ID, &variablesJSON, &t.RequiresNewt, &newtConfigJSON, &resourcesJSON, &architecturesJSON,
+	)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	t.UnmarshalVariables(variablesJSON)
+	t.UnmarshalNewtConfig(newtConfigJSON)
+	t.UnmarshalResources(resourcesJSON)
+	t.UnmarshalArchitectures(architecturesJSON)
+
+	compose, err := h.repos.GetDockerComposeContent(templateID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to fetch compose file: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	plan := &models.DeployPlan{
+		TemplateID:   t.ID,
+		RequiresNewt: t.RequiresNewt,
+		NewtConfig:   t.NewtConfig,
+		Resources:    t.Resources,
+		Warnings:     []string{},
+	}
+	plan.Warnings = append(plan.Warnings, h.resources.Check(r.Context(), t.Resources)...)
+	if arch := h.platform.Architecture(r.Context()); !t.SupportsArchitecture(arch) {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("template images are not published for the host's %s architecture; deploying requires ignore_architecture", arch))
+	}
+
+	discovered := [][]models.TemplateVariable{}
+	if envExample, err := h.repos.GetTemplateFile(templateID, ".env.example"); err == nil {
+		discovered = append(discovered, docker.ParseEnvExample(envExample))
+	}
+	discovered = append(discovered, docker.ExtractVariables(compose))
+	plan.Variables = docker.MergeVariables(t.Variables, discovered...)
+
+	// Plan against defaults, as the wizard would submit them untouched
+	env := make(map[string]string)
+	for _, variable := range plan.Variables {
+		if variable.DefaultValue != "" {
+			env[variable.Name] = variable.DefaultValue
+		}
+	}
+
+	if err := h.planner.Plan(r.URL.Query().Get("stack_name"), compose, env, plan); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to plan deployment: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
 // Preview returns a preview of the docker-compose.yml with newt injected
 func (h *TemplatesHandler) Preview(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Template preview not implemented", http.StatusNotImplemented)
@@ -461,28 +691,143 @@ func (h *TemplatesHandler) Validate(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Template validation not implemented", http.StatusNotImplemented)
 }
 
-// GetVersions returns version history for a template
+// GetVersions lists the released versions of a template, newest first, marking
+// the one its marketplace entry describes. Release notes are served by GetChangelog
 func (h *TemplatesHandler) GetVersions(w http.ResponseWriter, r *http.Request) {
 	templateID := chi.URLParam(r, "id")
-	
-	// For now, return current version only
-	// In a full implementation, this would track version history
-	response := map[string]interface{}{
+
+	versions, current, ok, err := h.templateVersions(templateID, false)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"template_id":     templateID,
+		"current_version": current,
+		"versions":        versions,
+	})
+}
+
+// Stats returns deploy success rate, average duration and the most frequent failure
+// reasons of a template
+func (h *TemplatesHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	templateID := chi.URLParam(r, "id")
+
+	var exists bool
+	if err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM templates WHERE id = $1)", templateID).Scan(&exists); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	stats, err := h.stats.TemplateStats(templateID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	stats.Flaky = stats.IsFlaky(h.config.Marketplace.FlakyMinDeployments, h.config.Marketplace.FlakySuccessPercent)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// Security returns the security scans of a template's synced versions, newest
+// first, with the badge shown in the marketplace. version selects one version
+func (h *TemplatesHandler) Security(w http.ResponseWriter, r *http.Request) {
+	templateID := chi.URLParam(r, "id")
+
+	var badge models.SecurityBadge
+	err := h.db.QueryRow("SELECT COALESCE(security_badge, 'unscanned') FROM templates WHERE id = $1", templateID).Scan(&badge)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	query := `
+		SELECT template_id, version, badge, COALESCE(findings, '[]'), scanned_at
+		FROM template_security_scans
+		WHERE template_id = $1`
+	args := []interface{}{templateID}
+	if version := r.URL.Query().Get("version"); version != "" {
+		query += " AND version = $2"
+		args = append(args, version)
+	}
+	query += " ORDER BY scanned_at DESC"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	scans := []models.SecurityScan{}
+	for rows.Next() {
+		var scan models.SecurityScan
+		var findingsJSON string
+		if err := rows.Scan(&scan.TemplateID, &scan.Version, &scan.Badge, &findingsJSON, &scan.ScannedAt); err != nil {
+			continue
+		}
+		json.Unmarshal([]byte(findingsJSON), &scan.Findings)
+		if scan.Findings == nil {
+			scan.Findings = []models.SecurityFinding{}
+		}
+		scans = append(scans, scan)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
 		"template_id": templateID,
-		"versions": []map[string]interface{}{
-			{
-				"version":    "1.0.0",
-				"created_at": "2024-01-01T00:00:00Z",
-				"is_current": true,
-			},
-		},
+		"badge":       badge,
+		"scans":       scans,
+	})
+}
+
+// Related suggests templates related to a template, by shared tags and category
+// and by what the same projects deploy and the same users rate well
+func (h *TemplatesHandler) Related(w http.ResponseWriter, r *http.Request) {
+	templateID := chi.URLParam(r, "id")
+	limit := getIntParam(r, "limit", 6)
+
+	var exists bool
+	if err := h.db.QueryRow("SELECT EXISTS(SELECT 1 FROM templates WHERE id = $1)", templateID).Scan(&exists); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	related, err := h.stats.RelatedTemplates(templateID, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"template_id": templateID,
+		"related":     related,
+	})
 }
 
-// Rate submits a rating for a template
+// Rate submits a rating for a template. A review breaking the content policy is
+// queued for moderation and, with review moderation on, hidden until approved.
+// The signed-in user rates, so ratings cannot be made in another user's name
 func (h *TemplatesHandler) Rate(w http.ResponseWriter, r *http.Request) {
 	templateID := chi.URLParam(r, "id")
 	
@@ -501,34 +846,72 @@ func (h *TemplatesHandler) Rate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Rating must be between 1 and 5", http.StatusBadRequest)
 		return
 	}
+	if user := apiMiddleware.UserFromContext(r.Context()); user != nil {
+		req.UserID = user.ID
+	}
+
+	if h.config.Marketplace.RequireDeploymentToRate {
+		installed, err := h.stats.HasInstalled(templateID, req.UserID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !installed {
+			http.Error(w, "Only users who deployed this template can rate it", http.StatusForbidden)
+			return
+		}
+	}
+
+	status, violations := h.moderator.ReviewStatus(req.Review)
 
 	// Insert or update rating
 	_, err := h.db.Exec(`
 		INSERT OR REPLACE INTO template_ratings 
-		(template_id, user_id, rating, review, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, datetime('now'), datetime('now'))`,
-		templateID, req.UserID, req.Rating, req.Review)
+		(template_id, user_id, rating, review, moderation_status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, datetime('now'), datetime('now'))`,
+		templateID, req.UserID, req.Rating, req.Review, status)
 
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	if len(violations) > 0 {
+		if _, err := h.moderator.Enqueue(models.ModerationReview, templateID, req.UserID, req.Review, violations); err != nil {
+			slog.Error("Failed to queue review for moderation", "template_id", templateID, "error", err)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
+	if status == models.ModerationPending {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":    "Rating submitted; the review is held for moderation",
+			"violations": violations,
+		})
+		return
+	}
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message": "Rating submitted successfully",
 	})
 }
 
-// GetReviews returns reviews for a template
+// GetReviews returns reviews for a template, marking those by users who deployed
+// it. With verified=true only those are returned
 func (h *TemplatesHandler) GetReviews(w http.ResponseWriter, r *http.Request) {
 	templateID := chi.URLParam(r, "id")
 	limit := getIntParam(r, "limit", 10)
 	
 	query := `
-		SELECT id, user_id, rating, review, helpful_count, created_at
-		FROM template_ratings 
-		WHERE template_id = $1 AND review != ''
+		SELECT id, user_id, rating, review, helpful_count, created_at,
+		       EXISTS(SELECT 1 FROM template_installs i WHERE i.template_id = r.template_id AND i.installer = r.user_id)
+		FROM template_ratings r
+		WHERE template_id = $1 AND review != '' AND moderation_status = 'approved'`
+	if r.URL.Query().Get("verified") == "true" {
+		query += `
+		  AND EXISTS(SELECT 1 FROM template_installs i WHERE i.template_id = r.template_id AND i.installer = r.user_id)`
+	}
+	query += `
 		ORDER BY helpful_count DESC, created_at DESC
 		LIMIT $2`
 
@@ -544,7 +927,7 @@ func (h *TemplatesHandler) GetReviews(w http.ResponseWriter, r *http.Request) {
 		var review models.TemplateRating
 		err := rows.Scan(
 			&review.ID, &review.UserID, &review.Rating, &review.Review,
-			&review.HelpfulCount, &review.CreatedAt,
+			&review.HelpfulCount, &review.CreatedAt, &review.VerifiedDeployment,
 		)
 		if err != nil {
 			continue
@@ -569,7 +952,64 @@ func (h *TemplatesHandler) Sync(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "Template sync not implemented", http.StatusNotImplemented)
 }
 
+// SyncStatus returns the GitHub sync state, resume checkpoint and remaining API quota
+func (h *TemplatesHandler) SyncStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := h.syncer.Status()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
 // Helper functions
+// getTagsParam returns the tags of a tags= filter, given as repeated parameters,
+// comma separated, or both
+func getTagsParam(r *http.Request) []string {
+	var tags []string
+	for _, value := range r.URL.Query()["tags"] {
+		tags = append(tags, strings.Split(value, ",")...)
+	}
+	return models.NormalizeTags(tags)
+}
+
+// filterTags restricts a template query to templates having every one of tags
+func filterTags(query string, args []interface{}, argCount int, tags []string) (string, []interface{}, int) {
+	if len(tags) == 0 {
+		return query, args, argCount
+	}
+
+	placeholders := make([]string, len(tags))
+	for i, tag := range tags {
+		argCount++
+		placeholders[i] = fmt.Sprintf("$%d", argCount)
+		args = append(args, tag)
+	}
+	argCount++
+	query += fmt.Sprintf(` AND id IN (
+		SELECT template_id FROM template_tags WHERE tag IN (%s)
+		GROUP BY template_id HAVING COUNT(*) = $%d)`, strings.Join(placeholders, ", "), argCount)
+	args = append(args, len(tags))
+	return query, args, argCount
+}
+
+// filterArchitecture restricts a template query to templates that run on arch,
+// keeping those whose architectures are unknown. An unknown host filters nothing
+func filterArchitecture(query string, args []interface{}, argCount int, arch string) (string, []interface{}, int) {
+	if arch == "" {
+		return query, args, argCount
+	}
+
+	argCount++
+	query += fmt.Sprintf(` AND (COALESCE(architectures, '') = '' OR EXISTS (
+		SELECT 1 FROM json_each(architectures)
+		WHERE value = $%[1]d OR value LIKE $%[1]d || '/%%' OR $%[1]d LIKE value || '/%%'))`, argCount)
+	args = append(args, arch)
+	return query, args, argCount
+}
+
 func getIntParam(r *http.Request, param string, defaultValue int) int {
 	value := r.URL.Query().Get(param)
 	if value == "" {
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/models"
+)
+
+// ListTrash returns the deleted deployments that can still be restored
+func (h *DeploymentsHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	items, err := h.trash.List()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deployments":    items,
+		"retention_days": h.config.Trash.RetentionDays,
+	})
+}
+
+// RestoreTrash restores a deleted deployment, stopped. With start=true its stack
+// is brought back up from the restored compose files and volumes
+func (h *DeploymentsHandler) RestoreTrash(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	start := false
+	if value := r.URL.Query().Get("start"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "Invalid start: expected true or false", http.StatusBadRequest)
+			return
+		}
+		start = parsed
+	}
+
+	// A restored deployment counts against quotas like a new one
+	if item, err := h.trash.Get(id); err == nil {
+		projectID := item.ProjectID
+		if projectID == "" {
+			projectID = models.DefaultProjectID
+		}
+		if err := h.quotas.CheckDeployment(r.Context(), projectID, requestedBy(r)); err != nil {
+			writeQuotaError(w, err)
+			return
+		}
+	}
+
+	item, err := h.trash.Restore(id)
+	if err == models.ErrTrashNotFound {
+		http.Error(w, "Deployment not found in trash", http.StatusNotFound)
+		return
+	}
+	if err == models.ErrStackNameTaken {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to restore deployment: %v", err), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Deployment restored from trash", "deployment_id", item.ID, "stack", item.StackName)
+
+	status := models.StatusStopped
+	if start {
+		if err := h.startRestored(item); err != nil {
+			http.Error(w, fmt.Sprintf("Deployment restored but failed to start: %v", err), http.StatusInternalServerError)
+			return
+		}
+		status = models.StatusRunning
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Deployment restored",
+		"id":         item.ID,
+		"stack_name": item.StackName,
+		"status":     status,
+	})
+}
+
+// startRestored brings up a restored deployment's stack and records it running
+func (h *DeploymentsHandler) startRestored(item *models.TrashedDeployment) error {
+	var err error
+	if item.DeployMode == models.DeployModeSwarm {
+		err = h.swarm.Deploy(item.StackName)
+	} else {
+		err = h.compose.Up(item.StackName)
+	}
+	if err != nil {
+		h.db.Exec("UPDATE deployments SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", models.StatusFailed, item.ID)
+		return err
+	}
+
+	_, err = h.db.Exec("UPDATE deployments SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", models.StatusRunning, item.ID)
+	return err
+}
+
+// PurgeTrash permanently deletes a deployment from the trash, with its volumes
+// and compose files
+func (h *DeploymentsHandler) PurgeTrash(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	item, err := h.trash.Purge(id)
+	if err == models.ErrTrashNotFound {
+		http.Error(w, "Deployment not found in trash", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to purge deployment: %v", err), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Deployment purged from trash", "deployment_id", item.ID, "stack", item.StackName, "volumes", len(item.Volumes))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Deployment purged",
+		"id":      item.ID,
+		"volumes": item.Volumes,
+	})
+}
+
+// Trash returns the deployment trash, whose expired deployments are purged by
+// the server
+func (h *DeploymentsHandler) Trash() *docker.Trash {
+	return h.trash
+}
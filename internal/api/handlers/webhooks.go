@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/webhooks"
+)
+
+// WebhooksHandler handles outgoing webhook configuration and delivery log requests
+type WebhooksHandler struct {
+	db        *sql.DB
+	config    *config.Config
+	publisher *webhooks.Publisher
+}
+
+// NewWebhooksHandler creates a new webhooks handler
+func NewWebhooksHandler(db *sql.DB, config *config.Config) *WebhooksHandler {
+	return &WebhooksHandler{
+		db:        db,
+		config:    config,
+		publisher: webhooks.NewPublisher(db),
+	}
+}
+
+// List returns all webhooks with their secrets omitted
+func (h *WebhooksHandler) List(w http.ResponseWriter, r *http.Request) {
+	hooks, err := webhooks.LoadWebhooks(h.db, false)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	for _, hook := range hooks {
+		hook.Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhooks": hooks,
+		"events":   models.WebhookEvents,
+	})
+}
+
+// Create adds a webhook. When no secret is given one is generated; the secret is
+// only returned by this request
+func (h *WebhooksHandler) Create(w http.ResponseWriter, r *http.Request) {
+	hook := models.Webhook{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if hook.Secret == "" {
+		hook.Secret = generateWebhookSecret()
+	}
+	if err := hook.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, _ := hook.MarshalEvents()
+	quietHours, _ := hook.MarshalQuietHours()
+	hook.CreatedAt = time.Now()
+	result, err := h.db.Exec(`
+		INSERT INTO webhooks (url, secret, events, enabled, quiet_hours, group_alerts, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		hook.URL, hook.Secret, events, hook.Enabled, quietHours, hook.GroupAlerts, hook.CreatedAt,
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if id, err := result.LastInsertId(); err == nil {
+		hook.ID = int(id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hook)
+}
+
+// Update replaces a webhook. An empty secret keeps the current one, and quiet
+// hours left out are removed
+func (h *WebhooksHandler) Update(w http.ResponseWriter, r *http.Request) {
+	hookID := chi.URLParam(r, "id")
+
+	var hook models.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if hook.Secret == "" {
+		err := h.db.QueryRow("SELECT secret FROM webhooks WHERE id = $1", hookID).Scan(&hook.Secret)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := hook.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, _ := hook.MarshalEvents()
+	quietHours, _ := hook.MarshalQuietHours()
+	result, err := h.db.Exec(`
+		UPDATE webhooks SET url = $1, secret = $2, events = $3, enabled = $4, quiet_hours = $5, group_alerts = $6
+		WHERE id = $7`,
+		hook.URL, hook.Secret, events, hook.Enabled, quietHours, hook.GroupAlerts, hookID,
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Webhook updated successfully",
+	})
+}
+
+// Delete removes a webhook and its delivery log
+func (h *WebhooksHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	hookID := chi.URLParam(r, "id")
+
+	if _, err := h.db.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = $1", hookID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if _, err := h.db.Exec("DELETE FROM webhooks WHERE id = $1", hookID); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete webhook: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Webhook deleted successfully",
+	})
+}
+
+// Ping queues a ping event to a webhook so its receiver can be tested
+func (h *WebhooksHandler) Ping(w http.ResponseWriter, r *http.Request) {
+	hookID := chi.URLParam(r, "id")
+
+	var hook models.Webhook
+	var events string
+	err := h.db.QueryRow("SELECT id, url, secret, events, enabled FROM webhooks WHERE id = $1", hookID).Scan(
+		&hook.ID, &hook.URL, &hook.Secret, &events, &hook.Enabled,
+	)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	hook.UnmarshalEvents(events)
+
+	deliveryID, err := h.publisher.Ping(&hook)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to queue ping: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Ping queued",
+		"delivery_id": deliveryID,
+	})
+}
+
+// ListDeliveries returns the delivery log of a webhook, newest first, optionally
+// filtered by status=
+func (h *WebhooksHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	hookID := chi.URLParam(r, "id")
+	status := r.URL.Query().Get("status")
+	limit := getIntParam(r, "limit", 50)
+	offset := getIntParam(r, "offset", 0)
+
+	query := `
+		SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at,
+		       response_status, error, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1`
+	args := []interface{}{hookID}
+
+	if status != "" {
+		query += " AND status = $2"
+		args = append(args, status)
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload string
+		var errMsg sql.NullString
+		var nextAttempt, deliveredAt sql.NullTime
+
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &nextAttempt,
+			&d.ResponseStatus, &errMsg, &d.CreatedAt, &deliveredAt)
+		if err != nil {
+			continue
+		}
+
+		d.Payload = json.RawMessage(payload)
+		d.Error = errMsg.String
+		if nextAttempt.Valid {
+			d.NextAttemptAt = &nextAttempt.Time
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries": deliveries,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// Redeliver queues a delivery to be sent again with a fresh set of attempts
+func (h *WebhooksHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	deliveryID := chi.URLParam(r, "id")
+
+	result, err := h.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = 0, next_attempt_at = $2, error = ''
+		WHERE id = $3`,
+		models.WebhookDeliveryPending, time.Now(), deliveryID,
+	)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Delivery queued",
+	})
+}
+
+// generateWebhookSecret returns a random signing secret
+func generateWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
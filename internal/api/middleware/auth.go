@@ -59,6 +59,12 @@ func RequireRole(role string) func(http.Handler) http.Handler {
 	}
 }
 
+// AuthenticateRequest returns the user of a request's API key or session, as the
+// Authentication middleware does, for servers outside the HTTP router
+func AuthenticateRequest(r *http.Request, db *sql.DB, apiKey string) *models.User {
+	return authenticateRequest(r, db, apiKey)
+}
+
 func authenticateRequest(r *http.Request, db *sql.DB, systemAPIKey string) *models.User {
 	// Try API key authentication first
 	apiKey := extractAPIKey(r)
@@ -183,6 +189,12 @@ func getUserByID(db *sql.DB, userID string) *models.User {
 	return &user
 }
 
+// UserFromContext returns the authenticated user of a request, nil when
+// authentication is disabled
+func UserFromContext(ctx context.Context) *models.User {
+	return getUserFromContext(ctx)
+}
+
 func getUserFromContext(ctx context.Context) *models.User {
 	user, ok := ctx.Value(UserKey).(*models.User)
 	if !ok {
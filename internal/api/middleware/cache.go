@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// CacheControl sets the Cache-Control header of a route's responses, replacing
+// any default set by earlier middleware
+func CacheControl(value string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", value)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ETag gives successful GET responses an ETag hashed from their body and answers
+// 304 Not Modified when it matches If-None-Match. The body is buffered, so it is
+// for bounded JSON responses, not streams. The tag is weak because the body may
+// be compressed on the way out
+func ETag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		buf := &bufferedResponse{header: w.Header(), statusCode: http.StatusOK}
+		next.ServeHTTP(buf, r)
+
+		if buf.statusCode != http.StatusOK || w.Header().Get("ETag") != "" {
+			w.WriteHeader(buf.statusCode)
+			w.Write(buf.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(buf.body.Bytes())
+		etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+		w.Header().Set("ETag", etag)
+
+		if etagListed(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Del("Content-Type")
+			w.Header().Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.body.Bytes())
+	})
+}
+
+// bufferedResponse holds a response until its ETag is known. Headers are written
+// straight to the real response, which has not been sent yet
+type bufferedResponse struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	b.statusCode = code
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+// etagListed reports whether an If-None-Match header lists etag, comparing weakly
+// as RFC 9110 requires for If-None-Match
+func etagListed(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
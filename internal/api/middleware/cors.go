@@ -2,61 +2,74 @@ package middleware
 
 import (
 	"net/http"
-	"strings"
-)
-
-// CORS middleware handles Cross-Origin Resource Sharing
-func CORS(origins []string, allowCredentials bool) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			origin := r.Header.Get("Origin")
-			
-			// Check if origin is allowed
-			if isOriginAllowed(origin, origins) {
-				w.Header().Set("Access-Control-Allow-Origin", origin)
-			}
 
-			// Set other CORS headers
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-			w.Header().Set("Access-Control-Allow-Headers", "Accept, Authorization, Content-Type, X-CSRF-Token, X-API-Key")
-			w.Header().Set("Access-Control-Expose-Headers", "Link, X-Total-Count")
-			w.Header().Set("Access-Control-Max-Age", "300")
+	"github.com/go-chi/cors"
 
-			if allowCredentials {
-				w.Header().Set("Access-Control-Allow-Credentials", "true")
-			}
+	"docker-deploy-app/internal/config"
+)
 
-			// Handle preflight requests
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusNoContent)
-				return
-			}
+// CORSPolicy is the effective Cross-Origin Resource Sharing policy, as the system
+// info endpoint reports it
+type CORSPolicy struct {
+	Enabled          bool     `json:"enabled"`
+	Origins          []string `json:"origins"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	MaxAge           int      `json:"max_age"`
+}
 
-			next.ServeHTTP(w, r)
-		})
+// EffectiveCORS returns the policy applied for a CORS configuration
+func EffectiveCORS(cfg config.CORSConfig) CORSPolicy {
+	origins := cfg.Origins
+	if origins == nil {
+		origins = []string{}
+	}
+	return CORSPolicy{
+		Enabled:          cfg.Enabled,
+		Origins:          origins,
+		AllowCredentials: cfg.AllowCredentials,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-API-Key", "If-Match", "If-None-Match"},
+		ExposedHeaders:   []string{"Link", "ETag", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining"},
+		MaxAge:           300,
 	}
 }
 
-func isOriginAllowed(origin string, allowedOrigins []string) bool {
-	if len(allowedOrigins) == 0 {
-		return false
+// CORS handles Cross-Origin Resource Sharing for a validated configuration.
+// Origins are matched as exact origins, wildcard subdomains or regular
+// expressions; with "*" alone any origin is allowed without credentials
+func CORS(cfg config.CORSConfig) func(http.Handler) http.Handler {
+	policy := EffectiveCORS(cfg)
+	options := cors.Options{
+		AllowedMethods:   policy.AllowedMethods,
+		AllowedHeaders:   policy.AllowedHeaders,
+		ExposedHeaders:   policy.ExposedHeaders,
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           policy.MaxAge,
 	}
 
-	for _, allowed := range allowedOrigins {
-		if allowed == "*" {
-			return true
+	var patterns []*config.OriginPattern
+	for _, origin := range cfg.Origins {
+		pattern, err := config.ParseOrigin(origin)
+		if err != nil {
+			continue // Rejected when the configuration was validated
 		}
-		if allowed == origin {
-			return true
+		if pattern.Any() {
+			options.AllowedOrigins = []string{"*"}
+			return cors.Handler(options)
 		}
-		// Support wildcard subdomains
-		if strings.HasPrefix(allowed, "*.") {
-			domain := strings.TrimPrefix(allowed, "*.")
-			if strings.HasSuffix(origin, domain) {
+		patterns = append(patterns, pattern)
+	}
+
+	options.AllowOriginFunc = func(r *http.Request, origin string) bool {
+		for _, pattern := range patterns {
+			if pattern.Match(origin) {
 				return true
 			}
 		}
+		return false
 	}
-
-	return false
-}
\ No newline at end of file
+	return cors.Handler(options)
+}
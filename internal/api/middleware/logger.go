@@ -1,9 +1,15 @@
 package middleware
 
 import (
-	"log"
+	"bufio"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"docker-deploy-app/internal/logging"
 )
 
 // responseWriter wraps http.ResponseWriter to capture status code
@@ -27,32 +33,67 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
-// Logger middleware logs HTTP requests
-func Logger(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// Flush implements http.Flusher for streamed responses
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
 
-		// Wrap the response writer
-		wrapped := &responseWriter{
-			ResponseWriter: w,
-			statusCode:     0,
-		}
+// Hijack implements http.Hijacker for WebSocket upgrades
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	if rw.statusCode == 0 {
+		rw.statusCode = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
 
-		// Process the request
-		next.ServeHTTP(wrapped, r)
+// Logger middleware logs HTTP requests. Handlers get a logger carrying the chi
+// request ID from logging.FromContext; with trace propagation enabled the W3C
+// traceparent header is continued and its trace ID is logged as well
+func Logger(tracePropagation bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
 
-		// Log the request
-		duration := time.Since(start)
-		log.Printf(
-			"%s %s %d %d bytes %v %s",
-			r.Method,
-			r.URL.Path,
-			wrapped.statusCode,
-			wrapped.written,
-			duration,
-			getClientIP(r),
-		)
-	})
+			logger := slog.Default().With("request_id", middleware.GetReqID(r.Context()))
+			ctx := r.Context()
+			if tracePropagation {
+				trace := logging.StartSpan(r)
+				logger = logger.With("trace_id", trace.TraceID, "span_id", trace.SpanID)
+				ctx = logging.WithTrace(ctx, trace)
+				w.Header().Set(logging.TraceparentHeader, trace.Traceparent())
+			}
+			ctx = logging.WithLogger(ctx, logger)
+
+			// Wrap the response writer
+			wrapped := &responseWriter{
+				ResponseWriter: w,
+				statusCode:     0,
+			}
+
+			// Process the request
+			next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+			// Log the request
+			level := slog.LevelInfo
+			if wrapped.statusCode >= 500 {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(ctx, level, "HTTP request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", wrapped.statusCode),
+				slog.Int64("bytes", wrapped.written),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+			)
+		})
+	}
 }
 
 // JSONContentType middleware sets JSON content type for API responses
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"docker-deploy-app/internal/maintenance"
+)
+
+// maintenancePath is exempt from maintenance mode, so it can be ended
+const maintenancePath = "/api/admin/system/maintenance"
+
+// graphQLPath only serves queries, even when they are POSTed
+const graphQLPath = "/api/graphql"
+
+// Maintenance refuses mutating requests with 503 while maintenance mode is on.
+// Reads keep working, so the UI can show the banner and the state of stacks
+func Maintenance(db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if strings.HasPrefix(r.URL.Path, maintenancePath) || r.URL.Path == graphQLPath || !maintenance.Active(db) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			state, err := maintenance.Get(db)
+			if err != nil {
+				// Refuse without the banner rather than let the change through
+				http.Error(w, "Service under maintenance", http.StatusServiceUnavailable)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "300")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":       "maintenance",
+				"message":     state.Message,
+				"maintenance": state,
+			})
+		})
+	}
+}
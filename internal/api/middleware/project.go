@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"docker-deploy-app/internal/models"
+)
+
+// RequireDeploymentRole checks the user's role in the project of the deployment
+// named by the {id} URL parameter. A project membership replaces the user's global
+// role within the project, so a viewer can operate their team's stacks and an
+// operator can be limited to viewing another team's. Global admins keep admin
+// everywhere
+func RequireDeploymentRole(db *sql.DB, role string) func(http.Handler) http.Handler {
+	return requireProjectRole(db, role, func(r *http.Request) (string, bool) {
+		var projectID string
+		err := db.QueryRow("SELECT COALESCE(project_id, 'global') FROM deployments WHERE id = $1",
+			chi.URLParam(r, "id")).Scan(&projectID)
+		return projectID, err == nil
+	})
+}
+
+// RequireProjectRole checks the user's role in the project named by the {id} URL
+// parameter, as RequireDeploymentRole does
+func RequireProjectRole(db *sql.DB, role string) func(http.Handler) http.Handler {
+	return requireProjectRole(db, role, func(r *http.Request) (string, bool) {
+		return chi.URLParam(r, "id"), true
+	})
+}
+
+// RequireBackupRole checks the user's role in every project with a deployment in
+// the backup named by the {id} URL parameter, as RequireDeploymentRole does.
+// Deployments deleted since the backup was taken count as the default project's
+func RequireBackupRole(db *sql.DB, role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getUserFromContext(r.Context())
+			if user == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			allowed, err := HasBackupRole(db, user, chi.URLParam(r, "id"), role)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			// Unknown backups are reported by the handler
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// HasBackupRole reports whether the user has at least the role in every project
+// with a deployment in the backup
+func HasBackupRole(db *sql.DB, user *models.User, backupID, role string) (bool, error) {
+	projects, err := backupProjects(db, backupID)
+	if err != nil {
+		return false, err
+	}
+	for _, projectID := range projects {
+		if !hasRole(projectUser(db, user, projectID), role) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// backupProjects returns the projects of the deployments in a backup
+func backupProjects(db *sql.DB, backupID string) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT COALESCE(d.project_id, 'global')
+		FROM backups b
+		JOIN json_each(b.deployment_ids) j
+		LEFT JOIN deployments d ON d.id = j.value
+		WHERE b.id = $1`, backupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var projects []string
+	for rows.Next() {
+		var projectID string
+		if err := rows.Scan(&projectID); err != nil {
+			return nil, err
+		}
+		projects = append(projects, projectID)
+	}
+	return projects, rows.Err()
+}
+
+// RequireQueryProjectRole checks the user's role in the project named by the
+// project query parameter, the default project when it is absent. It guards
+// routes that act on every stack of a project
+func RequireQueryProjectRole(db *sql.DB, role string) func(http.Handler) http.Handler {
+	return requireProjectRole(db, role, func(r *http.Request) (string, bool) {
+		projectID := r.URL.Query().Get("project")
+		if projectID == "" {
+			projectID = models.DefaultProjectID
+		}
+		return projectID, true
+	})
+}
+
+func requireProjectRole(db *sql.DB, role string, projectOf func(r *http.Request) (string, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getUserFromContext(r.Context())
+			if user == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			projectID, ok := projectOf(r)
+			if !ok {
+				// Unknown resources are reported by the handler
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !hasRole(projectUser(db, user, projectID), role) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// projectUser returns the user with the role they have in the project
+func projectUser(db *sql.DB, user *models.User, projectID string) *models.User {
+	if user.IsAdmin() {
+		return user
+	}
+
+	var role models.UserRole
+	err := db.QueryRow("SELECT role FROM project_members WHERE project_id = $1 AND user_id = $2",
+		projectID, user.ID).Scan(&role)
+	if err != nil {
+		return user
+	}
+
+	scoped := *user
+	scoped.Role = role
+	return &scoped
+}
+
+// HasProjectRole reports whether the user has at least the role in the project
+func HasProjectRole(db *sql.DB, user *models.User, projectID, role string) bool {
+	return hasRole(projectUser(db, user, projectID), role)
+}
+
+// HasDeploymentRole reports whether the user has at least the role in the project
+// of the deployment. Deployments that no longer exist count as the default project's
+func HasDeploymentRole(db *sql.DB, user *models.User, deploymentID, role string) bool {
+	projectID := models.DefaultProjectID
+	db.QueryRow("SELECT COALESCE(project_id, 'global') FROM deployments WHERE id = $1", deploymentID).Scan(&projectID)
+	return hasRole(projectUser(db, user, projectID), role)
+}
@@ -1,8 +1,16 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"runtime"
+	"sort"
+	"time"
 
 	"github.com/docker/docker/client"
 	"github.com/go-chi/chi/v5"
@@ -10,7 +18,15 @@ import (
 
 	"docker-deploy-app/internal/api/handlers"
 	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/api/sockets"
+	"docker-deploy-app/internal/certs"
 	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/graph"
+	"docker-deploy-app/internal/maintenance"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/settings"
+	"docker-deploy-app/internal/telemetry"
 )
 
 // Handler holds all dependencies for API handlers
@@ -18,144 +34,368 @@ type Handler struct {
 	DB           *sql.DB
 	DockerClient *client.Client
 	Config       *config.Config
+	Telemetry    *telemetry.Reporter
+	StartedAt    time.Time
+	RateLimiter  *apiMiddleware.RateLimiter
+	Reloader     *config.Reloader // Set by the server when a configuration file is used
+	Settings     *settings.Store  // Set by the server after loading the stored settings
+	Sockets      *sockets.Manager
 	
 	// Individual handlers
-	Templates   *handlers.TemplatesHandler
-	Deployments *handlers.DeploymentsHandler
-	Stacks      *handlers.StacksHandler
-	Backups     *handlers.BackupsHandler
-	Newt        *handlers.NewtHandler
-	GitHub      *handlers.GitHubHandler
+	Templates     *handlers.TemplatesHandler
+	Deployments   *handlers.DeploymentsHandler
+	Stacks        *handlers.StacksHandler
+	Backups       *handlers.BackupsHandler
+	Newt          *handlers.NewtHandler
+	GitHub        *handlers.GitHubHandler
+	Tasks         *handlers.TasksHandler
+	Webhooks      *handlers.WebhooksHandler
+	Overview      *handlers.OverviewHandler
+	Migration     *handlers.MigrationHandler
+	Projects      *handlers.ProjectsHandler
+	Categories    *handlers.CategoriesHandler
+	Quotas        *handlers.QuotasHandler
+	PublisherKeys *handlers.PublisherKeysHandler
+	Moderation    *handlers.ModerationHandler
+	Certificates  *handlers.CertificatesHandler
+	GraphQL       http.Handler
 }
 
 // NewHandler creates a new API handler with all dependencies
 func NewHandler(db *sql.DB, dockerClient *client.Client, cfg *config.Config) *Handler {
+	socketManager := sockets.NewManager(cfg.Server.WebSocket)
+	certManager := certs.NewManager(db, dockerClient, cfg.ACME)
+
 	return &Handler{
-		DB:           db,
-		DockerClient: dockerClient,
-		Config:       cfg,
-		Templates:    handlers.NewTemplatesHandler(db, cfg),
-		Deployments:  handlers.NewDeploymentsHandler(db, dockerClient, cfg),
-		Stacks:       handlers.NewStacksHandler(db, dockerClient, cfg),
-		Backups:      handlers.NewBackupsHandler(db, cfg),
-		Newt:         handlers.NewNewtHandler(db, cfg),
-		GitHub:       handlers.NewGitHubHandler(db, cfg),
+		DB:            db,
+		DockerClient:  dockerClient,
+		Config:        cfg,
+		Telemetry:     telemetry.NewReporter(db, cfg.Telemetry),
+		StartedAt:     time.Now(),
+		RateLimiter:   apiMiddleware.NewRateLimiter(cfg.Security.RateLimiting.Enabled, cfg.Security.RateLimiting.RequestsPerMinute),
+		Sockets:       socketManager,
+		Templates:     handlers.NewTemplatesHandler(db, dockerClient, cfg),
+		Deployments:   handlers.NewDeploymentsHandler(db, dockerClient, cfg, socketManager, certManager),
+		Stacks:        handlers.NewStacksHandler(db, dockerClient, cfg, socketManager),
+		Backups:       handlers.NewBackupsHandler(db, dockerClient, cfg),
+		Newt:          handlers.NewNewtHandler(db, dockerClient, cfg),
+		GitHub:        handlers.NewGitHubHandler(db, cfg),
+		Tasks:         handlers.NewTasksHandler(db, cfg),
+		Webhooks:      handlers.NewWebhooksHandler(db, cfg),
+		Overview:      handlers.NewOverviewHandler(db, dockerClient, cfg),
+		Migration:     handlers.NewMigrationHandler(db, dockerClient, cfg),
+		Projects:      handlers.NewProjectsHandler(db, dockerClient, cfg),
+		Categories:    handlers.NewCategoriesHandler(db, cfg),
+		Quotas:        handlers.NewQuotasHandler(db, dockerClient, cfg),
+		PublisherKeys: handlers.NewPublisherKeysHandler(db, cfg),
+		Moderation:    handlers.NewModerationHandler(db, cfg),
+		Certificates:  handlers.NewCertificatesHandler(db, cfg, certManager),
+		GraphQL:       graph.NewHandler(db, dockerClient, cfg),
 	}
 }
 
 // SetupRoutes configures all API routes
 func SetupRoutes(r chi.Router, h *Handler) {
+	// Probes for orchestrators, outside /api so they need no auth and are never
+	// refused for maintenance
+	r.Get("/healthz", h.handleLiveness)
+	r.Get("/readyz", h.handleReadiness)
+
+	// HTTP-01 challenges of certificate orders, for port 80 of the certificate's
+	// domains to be forwarded here
+	if h.Config.ACME.Enabled && h.Config.ACME.HTTPAddress == "" {
+		r.Handle("/.well-known/acme-challenge/{token}", h.Certificates.Manager().ChallengeHandler())
+	}
+
 	// API middleware
 	r.Route("/api", func(r chi.Router) {
 		// Common middleware for all API routes
 		r.Use(middleware.Timeout(60 * time.Second))
 		r.Use(apiMiddleware.JSONContentType)
+		r.Use(apiMiddleware.CacheControl("no-store")) // Routes below relax it where safe
 		
-		// Rate limiting if enabled
-		if h.Config.Security.RateLimiting.Enabled {
-			r.Use(apiMiddleware.RateLimit(h.Config.Security.RateLimiting.RequestsPerMinute))
-		}
+		// Rate limiting, a no-op unless enabled; the limit is reloadable
+		r.Use(h.RateLimiter.Middleware)
 
 		// Authentication middleware if enabled
 		if h.Config.Security.AuthEnabled {
 			r.Use(apiMiddleware.Authentication(h.DB, h.Config.Security.APIKey))
 		}
 
-		// Health check endpoint (no auth required)
-		r.Get("/health", h.handleHealth)
+		// Refuse changes during maintenance windows
+		r.Use(apiMiddleware.Maintenance(h.DB))
+
+		// Health check endpoint (no auth required), same as /readyz
+		r.Get("/health", h.handleReadiness)
+
+		// Maintenance banner
+		r.Get("/maintenance", h.handleMaintenanceStatus)
+
+		// Dashboard overview
+		r.Get("/overview", h.Overview.Get)
+
+		// Read-only GraphQL view of templates, deployments, stacks, backups and
+		// events, for screens joining several of them
+		r.Handle("/graphql", h.GraphQL)
+
+		// Consumption of the signed-in user against their quota
+		r.Get("/quota", h.Quotas.GetMine)
+
+		// Telemetry preview
+		r.Get("/telemetry/preview", h.handleTelemetryPreview)
+
+		// What the Docker host offers deployments: CPUs, memory, disk, architecture,
+		// swarm and GPUs
+		r.Get("/capabilities", h.handleCapabilities)
 
 		// Template Marketplace routes
 		r.Route("/marketplace", func(r chi.Router) {
+			r.Use(apiMiddleware.CacheControl("private, max-age=300"), apiMiddleware.ETag)
 			r.Get("/templates", h.Templates.ListMarketplaceTemplates)
 			r.Get("/featured", h.Templates.GetFeaturedTemplates)
 			r.Get("/trending", h.Templates.GetTrendingTemplates)
 			r.Get("/top-rated", h.Templates.GetTopRatedTemplates)
 			r.Get("/categories", h.Templates.GetCategories)
+			r.Get("/tags", h.Templates.GetTags)
 			r.Get("/search", h.Templates.SearchTemplates)
 		})
 
 		// Templates routes
 		r.Route("/templates", func(r chi.Router) {
+			r.Use(apiMiddleware.CacheControl("private, no-cache"), apiMiddleware.ETag)
 			r.Get("/", h.Templates.List)
 			r.Get("/{id}", h.Templates.Get)
 			r.Get("/{id}/preview", h.Templates.Preview)
+			r.Get("/{id}/deploy-plan", h.Templates.DeployPlan)
 			r.Post("/{id}/validate", h.Templates.Validate)
 			r.Get("/{id}/versions", h.Templates.GetVersions)
+			r.Get("/{id}/changelog", h.Templates.GetChangelog)
+			r.Get("/{id}/stats", h.Templates.Stats)
+			r.Get("/{id}/related", h.Templates.Related)
+			r.Get("/{id}/security", h.Templates.Security)
+			r.Get("/{id}/tests", h.Templates.ListTests)
+			r.Get("/{id}/tests/{test}", h.Templates.GetTest)
+			r.With(h.globalRole("admin")).Post("/{id}/test", h.Templates.Test)
 			r.Post("/{id}/rate", h.Templates.Rate)
 			r.Get("/{id}/reviews", h.Templates.GetReviews)
 			r.Post("/{id}/review", h.Templates.SubmitReview)
+			r.With(apiMiddleware.CacheControl("public, max-age=3600")).Get("/{id}/icon", h.Templates.GetIcon)
+			r.With(h.globalRole("admin")).Post("/{id}/icon", h.Templates.UploadIcon)
+			r.With(h.globalRole("admin")).Delete("/{id}/icon", h.Templates.DeleteIcon)
+			r.Get("/{id}/media", h.Templates.GetMedia)
+			r.With(apiMiddleware.CacheControl("public, max-age=3600")).Get("/{id}/media/{position}", h.Templates.GetMediaFile)
 			r.Post("/sync", h.Templates.Sync)
+			r.Get("/sync/status", h.Templates.SyncStatus)
 		})
 
 		// Deployments routes
 		r.Route("/deployments", func(r chi.Router) {
 			r.Get("/", h.Deployments.List)
 			r.Post("/", h.Deployments.Create)
-			r.Get("/{id}", h.Deployments.Get)
-			r.Delete("/{id}", h.Deployments.Delete)
-			r.Get("/{id}/logs", h.Deployments.GetLogs)
-			r.Get("/{id}/logs/stream", h.Deployments.StreamLogs)
-			r.Get("/{id}/tunnel", h.Deployments.GetTunnelInfo)
-			r.Post("/{id}/backup", h.Deployments.CreateBackup)
+			r.Post("/check-ports", h.Deployments.CheckPorts)
+			r.Get("/name/{stack}", h.Deployments.GetByName)
+			r.Put("/name/{stack}", h.Deployments.PutByName)
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.globalRole("operator"))
+				r.Get("/trash", h.Deployments.ListTrash)
+				r.Post("/trash/{id}/restore", h.Deployments.RestoreTrash)
+				r.Delete("/trash/{id}", h.Deployments.PurgeTrash)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.deploymentRole("viewer"))
+				r.Get("/{id}", h.Deployments.Get)
+				r.Get("/{id}/logs", h.Deployments.GetLogs)
+				r.Get("/{id}/logs/stream", h.Deployments.StreamLogs)
+				r.Get("/{id}/tunnel", h.Deployments.GetTunnelInfo)
+				r.Get("/{id}/revisions", h.Deployments.GetRevisions)
+				r.Get("/{id}/promotions", h.Deployments.GetPromotions)
+				r.Get("/{id}/sbom", h.Deployments.GetSBOM)
+				r.Get("/{id}/probes", h.Deployments.ListProbes)
+				r.Get("/{id}/probes/{probe}/history", h.Deployments.GetProbeHistory)
+				r.Get("/{id}/footprint", h.Deployments.GetFootprint)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(h.deploymentRole("operator"))
+				r.Delete("/{id}", h.Deployments.Delete)
+				r.Post("/{id}/backup", h.Deployments.CreateBackup)
+				r.Put("/{id}/update-policy", h.Deployments.SetUpdatePolicy)
+				r.Post("/{id}/promote", h.Deployments.Promote)
+				r.Post("/{id}/clone", h.Deployments.Clone)
+				r.Post("/{id}/probes", h.Deployments.CreateProbe)
+				r.Put("/{id}/probes/{probe}", h.Deployments.UpdateProbe)
+				r.Delete("/{id}/probes/{probe}", h.Deployments.DeleteProbe)
+				r.Post("/{id}/probes/{probe}/check", h.Deployments.CheckProbe)
+			})
 		})
 
 		// Stacks routes
 		r.Route("/stacks", func(r chi.Router) {
 			r.Get("/", h.Stacks.List)
-			r.Get("/{id}", h.Stacks.Get)
-			r.Post("/{id}/start", h.Stacks.Start)
-			r.Post("/{id}/stop", h.Stacks.Stop)
-			r.Post("/{id}/restart", h.Stacks.Restart)
-			r.Get("/{id}/logs", h.Stacks.GetLogs)
-			r.Get("/{id}/logs/stream", h.Stacks.StreamLogs)
-			r.Get("/{id}/stats", h.Stacks.GetStats)
-			r.Get("/{id}/newt-status", h.Stacks.GetNewtStatus)
-			r.Post("/{id}/export", h.Stacks.Export)
+			r.Get("/summary", h.Stacks.Summary)
+			r.With(h.queryProjectRole("operator")).Post("/start-all", h.Stacks.StartAll)
+			r.With(h.queryProjectRole("operator")).Post("/stop-all", h.Stacks.StopAll)
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.deploymentRole("viewer"))
+				r.Get("/{id}", h.Stacks.Get)
+				r.Get("/{id}/logs", h.Stacks.GetLogs)
+				r.Get("/{id}/logs/stream", h.Stacks.StreamLogs)
+				r.Get("/{id}/services/{service}/logs", h.Stacks.GetServiceLogs)
+				r.Get("/{id}/stats", h.Stacks.GetStats)
+				r.Get("/{id}/newt-status", h.Stacks.GetNewtStatus)
+				r.Get("/{id}/newt-events", h.Stacks.GetNewtEvents)
+				r.Get("/{id}/events", h.Stacks.GetEvents)
+				r.Get("/{id}/restart-loops", h.Stacks.GetRestartLoops)
+				r.Get("/{id}/updates", h.Stacks.GetUpdates)
+				r.Post("/{id}/export", h.Stacks.Export)
+				r.Get("/{id}/dependencies", h.Stacks.GetDependencies)
+				r.Get("/{id}/volumes", h.Stacks.ListVolumes)
+				r.Get("/{id}/volumes/{volume}/files", h.Stacks.ListVolumeFiles)
+				r.Get("/{id}/volumes/{volume}/download", h.Stacks.DownloadVolumeFile)
+				r.Get("/{id}/volumes/{volume}/snapshots", h.Stacks.ListVolumeSnapshots)
+				r.Get("/{id}/cronjobs", h.Stacks.ListCronJobs)
+				r.Get("/{id}/cronjobs/{job}/runs", h.Stacks.ListCronJobRuns)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(h.deploymentRole("operator"))
+				r.Post("/{id}/start", h.Stacks.Start)
+				r.Post("/{id}/stop", h.Stacks.Stop)
+				r.Post("/{id}/restart", h.Stacks.Restart)
+				r.Post("/{id}/upgrade", h.Stacks.Upgrade)
+				r.Put("/{id}/dependencies", h.Stacks.SetDependencies)
+				r.Delete("/{id}/restart-loops", h.Stacks.ClearRestartLoops)
+				r.Put("/{id}/volumes/{volume}/files", h.Stacks.UploadVolumeFile)
+				r.Post("/{id}/volumes/{volume}/snapshot", h.Stacks.SnapshotVolume)
+				r.Post("/{id}/volumes/{volume}/snapshots/{snapshot}/restore", h.Stacks.RestoreVolumeSnapshot)
+				r.Delete("/{id}/volumes/{volume}/snapshots/{snapshot}", h.Stacks.DeleteVolumeSnapshot)
+				r.Post("/{id}/volumes/{volume}/clone", h.Stacks.CloneVolume)
+				r.Post("/{id}/cronjobs", h.Stacks.CreateCronJob)
+				r.Put("/{id}/cronjobs/{job}", h.Stacks.UpdateCronJob)
+				r.Delete("/{id}/cronjobs/{job}", h.Stacks.DeleteCronJob)
+				r.Post("/{id}/cronjobs/{job}/run", h.Stacks.RunCronJob)
+			})
+		})
+
+		// Project routes
+		r.Route("/projects", func(r chi.Router) {
+			r.Get("/", h.Projects.List)
+			r.Get("/{id}", h.Projects.Get)
+			r.Get("/{id}/members", h.Projects.ListMembers)
+			r.With(h.projectRole("viewer")).Get("/{id}/quota", h.Quotas.GetProject)
+			r.With(h.projectRole("viewer")).Get("/{id}/footprint", h.Projects.GetFootprint)
+			r.With(h.projectRole("operator")).Get("/{id}/export", h.Projects.Export)
+			r.With(h.globalRole("admin")).Put("/{id}/quota", h.Quotas.SetProject)
+			r.With(h.globalRole("admin")).Delete("/{id}/quota", h.Quotas.ResetProject)
+			r.With(h.globalRole("admin")).Post("/", h.Projects.Create)
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.projectRole("admin"))
+				r.Put("/{id}", h.Projects.Update)
+				r.Delete("/{id}", h.Projects.Delete)
+				r.Put("/{id}/members/{userID}", h.Projects.SetMember)
+				r.Delete("/{id}/members/{userID}", h.Projects.RemoveMember)
+			})
+
+			// Background jobs over every deployment of the project
+			r.Group(func(r chi.Router) {
+				r.Use(h.projectRole("operator"))
+				r.Post("/{id}/backup", h.Projects.Backup)
+				r.Put("/{id}/update-policy", h.Projects.SetUpdatePolicy)
+			})
 		})
 
 		// Backups & Restore routes
 		r.Route("/backups", func(r chi.Router) {
+			// List only returns, and Create only takes, deployments of the caller's projects
 			r.Get("/", h.Backups.List)
 			r.Post("/", h.Backups.Create)
-			r.Get("/{id}", h.Backups.Get)
-			r.Delete("/{id}", h.Backups.Delete)
-			r.Post("/{id}/restore", h.Backups.Restore)
-			r.Get("/{id}/download", h.Backups.Download)
-			r.Post("/upload", h.Backups.Upload)
+			r.With(h.backupRole("viewer")).Get("/{id}", h.Backups.Get)
+			r.With(h.backupRole("operator")).Delete("/{id}", h.Backups.Delete)
+			r.With(h.backupRole("operator")).Post("/{id}/restore", h.Backups.Restore)
+			r.With(h.backupRole("operator")).Get("/{id}/download", h.Backups.Download)
+			r.With(h.backupRole("operator")).Get("/{id}/contents", h.Backups.Contents)
+			r.With(h.backupRole("operator")).Get("/{id}/files/*", h.Backups.File)
+			r.With(h.globalRole("operator")).Post("/upload", h.Backups.Upload)
+			r.With(h.globalRole("admin")).Post("/restore-from-url", h.Backups.RestoreFromURL)
 			r.Post("/test-restore", h.Backups.TestRestore)
 			
-			// Backup schedules
+			// Backup schedules select deployments across projects
 			r.Route("/schedules", func(r chi.Router) {
 				r.Get("/", h.Backups.ListSchedules)
-				r.Post("/", h.Backups.CreateSchedule)
-				r.Put("/{id}", h.Backups.UpdateSchedule)
-				r.Delete("/{id}", h.Backups.DeleteSchedule)
+				r.Group(func(r chi.Router) {
+					r.Use(h.globalRole("admin"))
+					r.Post("/", h.Backups.CreateSchedule)
+					r.Put("/{id}", h.Backups.UpdateSchedule)
+					r.Delete("/{id}", h.Backups.DeleteSchedule)
+				})
 			})
 		})
 
 		// Newt configuration routes
 		r.Route("/newt", func(r chi.Router) {
 			r.Get("/config", h.Newt.GetConfig)
-			r.Post("/config", h.Newt.UpdateConfig)
-			r.Post("/validate", h.Newt.ValidateConfig)
 			r.Get("/status", h.Newt.GetStatus)
-			r.Post("/test-connection", h.Newt.TestConnection)
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.globalRole("admin"))
+				r.Post("/config", h.Newt.UpdateConfig)
+				r.Put("/config", h.Newt.PutConfig)
+				r.Post("/config/rotate", h.Newt.RotateSecret)
+				r.Post("/validate", h.Newt.ValidateConfig)
+				r.Post("/test-connection", h.Newt.TestConnection)
+			})
 		})
 
 		// GitHub integration routes
 		r.Route("/github", func(r chi.Router) {
-			r.Post("/connect", h.GitHub.Connect)
+			r.Get("/status", h.GitHub.Status)
 			r.Get("/repos", h.GitHub.ListRepositories)
 			r.Post("/webhook", h.GitHub.HandleWebhook)
-			r.Post("/sync", h.GitHub.SyncRepositories)
+			r.Get("/sources", h.GitHub.ListSources)
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.globalRole("admin"))
+				r.Post("/sync", h.GitHub.SyncRepositories)
+				r.Post("/connect", h.GitHub.Connect)
+				r.Post("/disconnect", h.GitHub.Disconnect)
+				r.Post("/sources", h.GitHub.CreateSource)
+				r.Put("/sources/{id}", h.GitHub.UpdateSource)
+				r.Delete("/sources/{id}", h.GitHub.DeleteSource)
+			})
+		})
+
+		// Declarative deployments
+		r.Post("/apply", h.Deployments.Apply)
+
+		// Adopt a folder of compose projects on the host
+		r.With(h.globalRole("admin")).Post("/import/compose-dir", h.Migration.ImportComposeDir)
+
+		// Background task routes, limited to the tasks of the caller's projects
+		r.Route("/tasks", func(r chi.Router) {
+			r.Get("/", h.Tasks.List)
+			r.Get("/{id}", h.Tasks.Get)
+		})
+
+		// Outgoing webhook routes
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(h.globalRole("admin"))
+			r.Get("/", h.Webhooks.List)
+			r.Post("/", h.Webhooks.Create)
+			r.Put("/{id}", h.Webhooks.Update)
+			r.Delete("/{id}", h.Webhooks.Delete)
+			r.Post("/{id}/ping", h.Webhooks.Ping)
+			r.Get("/{id}/deliveries", h.Webhooks.ListDeliveries)
+			r.Post("/deliveries/{id}/redeliver", h.Webhooks.Redeliver)
 		})
 
 		// WebSocket endpoints
 		r.Route("/ws", func(r chi.Router) {
 			// Remove rate limiting for WebSocket connections
 			r.Use(apiMiddleware.RemoveRateLimit)
-			r.Get("/deployments/{id}/logs", h.Deployments.WebSocketLogs)
-			r.Get("/stacks/{id}/logs", h.Stacks.WebSocketLogs)
+			r.With(h.deploymentRole("viewer")).Get("/deployments/{id}/logs", h.Deployments.WebSocketLogs)
+			r.With(h.deploymentRole("viewer")).Get("/stacks/{id}/logs", h.Stacks.WebSocketLogs)
 			r.Get("/system/events", h.handleSystemEvents)
 		})
 
@@ -169,42 +409,196 @@ func SetupRoutes(r chi.Router, h *Handler) {
 				r.Get("/{id}", h.handleGetUser)
 				r.Put("/{id}", h.handleUpdateUser)
 				r.Delete("/{id}", h.handleDeleteUser)
+				r.Get("/{id}/quota", h.Quotas.GetUser)
+				r.Put("/{id}/quota", h.Quotas.SetUser)
+				r.Delete("/{id}/quota", h.Quotas.ResetUser)
 			})
 			
 			r.Route("/system", func(r chi.Router) {
 				r.Get("/info", h.handleSystemInfo)
 				r.Get("/stats", h.handleSystemStats)
 				r.Post("/cleanup", h.handleSystemCleanup)
+				r.Get("/storage", h.handleSystemStorage)
+				r.Get("/maintenance", h.handleMaintenanceStatus)
+				r.Post("/maintenance", h.handleMaintenanceStart)
+				r.Post("/maintenance/resume", h.handleMaintenanceResume)
+			})
+
+			r.Post("/config/reload", h.handleConfigReload)
+
+			r.Get("/settings", h.handleGetSettings)
+			r.Put("/settings", h.handleUpdateSettings)
+			r.Delete("/settings/{section}", h.handleResetSettings)
+
+			r.Route("/categories", func(r chi.Router) {
+				r.Get("/", h.Categories.List)
+				r.Post("/", h.Categories.Create)
+				r.Put("/order", h.Categories.Reorder)
+				r.Put("/{name}", h.Categories.Update)
+				r.Delete("/{name}", h.Categories.Delete)
+				r.Post("/{name}/reassign", h.Categories.Reassign)
+			})
+
+			r.Route("/publisher-keys", func(r chi.Router) {
+				r.Get("/", h.PublisherKeys.List)
+				r.Post("/", h.PublisherKeys.Create)
+				r.Delete("/{id}", h.PublisherKeys.Delete)
+			})
+
+			r.Route("/certificates", func(r chi.Router) {
+				r.Get("/", h.Certificates.List)
+				r.Post("/", h.Certificates.Create)
+				r.Get("/{id}", h.Certificates.Get)
+				r.Post("/{id}/renew", h.Certificates.Renew)
+				r.Delete("/{id}", h.Certificates.Delete)
+			})
+
+			r.Route("/moderation", func(r chi.Router) {
+				r.Get("/", h.Moderation.List)
+				r.Post("/{id}/approve", h.Moderation.Approve)
+				r.Post("/{id}/reject", h.Moderation.Reject)
+			})
+
+			r.Route("/migrate", func(r chi.Router) {
+				r.Get("/export", h.Migration.Export)
+				r.Post("/import", h.Migration.Import)
+				r.Post("/portainer", h.Migration.ImportPortainer)
 			})
 		})
 	})
 }
 
-// handleHealth returns system health status
-func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
-	// Check database connection
-	if err := h.DB.Ping(); err != nil {
-		http.Error(w, "Database connection failed", http.StatusServiceUnavailable)
-		return
+// globalRole enforces a user's global role. Roles are only known when
+// authentication is enabled
+func (h *Handler) globalRole(role string) func(http.Handler) http.Handler {
+	if !h.Config.Security.AuthEnabled {
+		return passThrough
 	}
+	return apiMiddleware.RequireRole(role)
+}
 
-	// Check Docker connection
-	if _, err := h.DockerClient.Ping(r.Context()); err != nil {
-		http.Error(w, "Docker connection failed", http.StatusServiceUnavailable)
-		return
+// deploymentRole enforces a role in the project of the deployment or stack a route
+// addresses
+func (h *Handler) deploymentRole(role string) func(http.Handler) http.Handler {
+	if !h.Config.Security.AuthEnabled {
+		return passThrough
+	}
+	return apiMiddleware.RequireDeploymentRole(h.DB, role)
+}
+
+// projectRole enforces a role in the project a route addresses
+func (h *Handler) projectRole(role string) func(http.Handler) http.Handler {
+	if !h.Config.Security.AuthEnabled {
+		return passThrough
+	}
+	return apiMiddleware.RequireProjectRole(h.DB, role)
+}
+
+// backupRole enforces a role in the projects of the deployments in the backup a
+// route addresses
+func (h *Handler) backupRole(role string) func(http.Handler) http.Handler {
+	if !h.Config.Security.AuthEnabled {
+		return passThrough
+	}
+	return apiMiddleware.RequireBackupRole(h.DB, role)
+}
+
+// queryProjectRole enforces a role in the project named by the project query
+// parameter
+func (h *Handler) queryProjectRole(role string) func(http.Handler) http.Handler {
+	if !h.Config.Security.AuthEnabled {
+		return passThrough
 	}
+	return apiMiddleware.RequireQueryProjectRole(h.DB, role)
+}
+
+func passThrough(next http.Handler) http.Handler {
+	return next
+}
 
-	response := map[string]interface{}{
-		"status":    "healthy",
+// readinessTimeout bounds each dependency check of a readiness probe
+const readinessTimeout = 2 * time.Second
+
+// handleLiveness reports that the process is up and serving. It checks no
+// dependencies, so a brief Docker or database outage never gets the process killed
+func (h *Handler) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "alive",
 		"timestamp": time.Now().Unix(),
-		"services": map[string]string{
-			"database": "healthy",
-			"docker":   "healthy",
-		},
+		"uptime":    time.Since(h.StartedAt).Round(time.Second).String(),
+	})
+}
+
+// handleReadiness reports whether the instance can serve requests. Without the
+// database it cannot and answers 503; without Docker or the backup scheduler it
+// still serves, degraded
+func (h *Handler) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	report := &models.ReadinessReport{
+		Status:    models.HealthStatusHealthy,
+		Timestamp: time.Now().Unix(),
+	}
+
+	report.Add("database", checkDependency(r, models.HealthStatusUnhealthy, h.DB.PingContext))
+	report.Add("docker", checkDependency(r, models.HealthStatusDegraded, func(ctx context.Context) error {
+		_, err := h.DockerClient.Ping(ctx)
+		return err
+	}))
+
+	scheduler := models.DependencyHealth{Status: models.HealthStatusHealthy}
+	if !h.Backups.Scheduler().Running() {
+		scheduler.Status = models.HealthStatusDegraded
+		scheduler.Error = "backup scheduler is not running"
+	}
+	report.Add("scheduler", scheduler)
+
+	if report.Dependencies["database"].Status == models.HealthStatusHealthy {
+		report.Maintenance = maintenance.Active(h.DB)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == models.HealthStatusUnhealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// checkDependency runs a dependency check bounded by readinessTimeout, reporting
+// failure with the given status
+func checkDependency(r *http.Request, failure models.HealthStatus, check func(ctx context.Context) error) models.DependencyHealth {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	health := models.DependencyHealth{
+		Status:    models.HealthStatusHealthy,
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		health.Status = failure
+		health.Error = err.Error()
+	}
+	return health
+}
+
+// handleTelemetryPreview returns the telemetry report exactly as it would be sent,
+// whether or not telemetry is enabled
+func (h *Handler) handleTelemetryPreview(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Telemetry.Collect()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to collect telemetry: %v", err), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":  h.Telemetry.Enabled(),
+		"endpoint": h.Config.Telemetry.Endpoint,
+		"interval": h.Config.Telemetry.Interval,
+		"report":   report,
+	})
 }
 
 // handleSystemEvents handles WebSocket connections for system events
@@ -248,6 +642,7 @@ func (h *Handler) handleSystemInfo(w http.ResponseWriter, r *http.Request) {
 		"os":          runtime.GOOS,
 		"arch":        runtime.GOARCH,
 		"started_at":  time.Now().Format(time.RFC3339),
+		"cors":        apiMiddleware.EffectiveCORS(h.Config.Server.CORS),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -273,7 +668,225 @@ func (h *Handler) handleSystemStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
-// handleSystemCleanup performs system cleanup (admin only)
+// handleCapabilities reports the resources of the Docker host and whether it can
+// give containers GPUs
+func (h *Handler) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	host := docker.NewResourceChecker(h.DockerClient, "./deployments").Host(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(host)
+}
+
+// handleSystemStorage reports disk usage and capacity (admin only)
+func (h *Handler) handleSystemStorage(w http.ResponseWriter, r *http.Request) {
+	reporter := docker.NewStorageReporter(h.DockerClient, h.Config.Backup.Storage.Path, h.Config.Database.Path,
+		h.Config.Monitoring.DiskWarningPercent, h.Config.Monitoring.DiskCriticalPercent)
+
+	report, err := reporter.Report(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to collect storage usage: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// handleSystemCleanup removes orphaned Docker resources (admin only)
 func (h *Handler) handleSystemCleanup(w http.ResponseWriter, r *http.Request) {
-	http.Error(w, "System cleanup not implemented", http.StatusNotImplemented)
-}
\ No newline at end of file
+	var options models.CleanupOptions
+	if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := options.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cleaner := docker.NewCleaner(h.DB, h.DockerClient, h.Config.Docker.DefaultNetwork)
+	report, err := cleaner.Cleanup(&options)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Cleanup failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// maxDrainWait bounds how long starting maintenance waits for running tasks, below
+// the API request timeout. Clients poll the status for the rest
+const maxDrainWait = 50 * time.Second
+
+// handleMaintenanceStatus returns the maintenance state, for the UI banner
+func (h *Handler) handleMaintenanceStatus(w http.ResponseWriter, r *http.Request) {
+	state, err := maintenance.Get(h.DB)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// handleMaintenanceStart puts the instance into maintenance mode (admin only).
+// Scheduled backups, sync loops and auto-updates pause, and mutating requests are
+// refused. With drain_timeout it waits up to that many seconds for running tasks
+// to finish
+func (h *Handler) handleMaintenanceStart(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Message      string `json:"message"`
+		DrainTimeout int    `json:"drain_timeout"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var startedBy string
+	if user := apiMiddleware.UserFromContext(r.Context()); user != nil {
+		startedBy = user.Username
+	}
+
+	state, err := maintenance.Enable(h.DB, req.Message, startedBy)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to start maintenance: %v", err), http.StatusInternalServerError)
+		return
+	}
+	slog.Warn("Maintenance mode started", "by", startedBy, "running_tasks", state.RunningTasks)
+
+	if req.DrainTimeout > 0 {
+		wait := time.Duration(req.DrainTimeout) * time.Second
+		if wait > maxDrainWait {
+			wait = maxDrainWait
+		}
+		if state, err = maintenance.Drain(h.DB, wait); err != nil {
+			http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// handleMaintenanceResume ends maintenance mode (admin only)
+func (h *Handler) handleMaintenanceResume(w http.ResponseWriter, r *http.Request) {
+	if err := maintenance.Disable(h.DB); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to end maintenance: %v", err), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Maintenance mode ended")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Maintenance ended",
+	})
+}
+
+// handleConfigReload re-reads the configuration file and applies its reloadable
+// sections (admin only)
+func (h *Handler) handleConfigReload(w http.ResponseWriter, r *http.Request) {
+	if h.Reloader == nil || h.Reloader.Path() == "" {
+		http.Error(w, "No configuration file in use", http.StatusConflict)
+		return
+	}
+
+	result, err := h.Reloader.Reload()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Reload failed: %v", err), http.StatusBadRequest)
+		return
+	}
+	slog.Info("Configuration reloaded", "applied", result.Applied, "ignored", result.Ignored)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Configuration reloaded",
+		"result":  result,
+	})
+}
+
+// handleGetSettings returns the runtime-editable configuration sections with the
+// values stored over the file and environment (admin only)
+func (h *Handler) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	sections, err := h.Settings.Get()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read settings: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sections": sections,
+	})
+}
+
+// handleUpdateSettings stores changes to runtime-editable sections, keyed by
+// section name. They take effect immediately, no restart needed (admin only)
+func (h *Handler) handleUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var changes map[string]map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(changes) == 0 {
+		http.Error(w, "No settings to change", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Settings.Update(changes); err != nil {
+		if errors.Is(err, settings.ErrUnknownSection) || errors.Is(err, settings.ErrInvalidSettings) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to save settings: %v", err), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Settings updated", "sections", sortedKeys(changes))
+
+	sections, err := h.Settings.Get()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read settings: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Settings updated",
+		"sections": sections,
+	})
+}
+
+// handleResetSettings removes the stored values of a section, restoring the file
+// and environment configuration (admin only)
+func (h *Handler) handleResetSettings(w http.ResponseWriter, r *http.Request) {
+	section := chi.URLParam(r, "section")
+	if err := h.Settings.Reset(section); err != nil {
+		if errors.Is(err, settings.ErrUnknownSection) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to reset settings: %v", err), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Settings reset", "section", section)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Settings reset",
+	})
+}
+
+func sortedKeys(changes map[string]map[string]interface{}) []string {
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,297 @@
+package sockets
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/config"
+)
+
+// maxMessageSize bounds the messages read from clients, which send control frames
+// and small requests such as where to resume a replay
+const maxMessageSize = 4096
+
+// ErrLimitReached reports that a connection was refused for exceeding a limit
+var ErrLimitReached = fmt.Errorf("too many WebSocket connections")
+
+// Manager upgrades and tracks WebSocket connections. It keeps them alive with
+// pings, drops clients that stop answering, enforces connection limits per user
+// and overall, and closes connections when their topic goes away or the server
+// shuts down
+type Manager struct {
+	upgrader websocket.Upgrader
+	config   config.WebSocketConfig
+	conns    map[*Conn]struct{}
+	byUser   map[string]int
+	closed   bool
+	mu       sync.Mutex
+}
+
+// Conn is a managed WebSocket connection about one topic, such as a deployment ID
+type Conn struct {
+	ws       *websocket.Conn
+	manager  *Manager
+	user     string
+	topic    string
+	messages chan []byte
+	ctx      context.Context
+	cancel   context.CancelFunc
+	writeMu  sync.Mutex
+	once     sync.Once
+}
+
+// NewManager creates a WebSocket connection manager
+func NewManager(cfg config.WebSocketConfig) *Manager {
+	return &Manager{
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		config: cfg,
+		conns:  make(map[*Conn]struct{}),
+		byUser: make(map[string]int),
+	}
+}
+
+// Upgrade upgrades a request to a WebSocket connection about a topic. When a
+// limit is reached the request is refused with 429 and ErrLimitReached returned.
+// The connection lives until the client leaves, Close is called, its topic is
+// closed or the manager shuts down; its context tells when
+func (m *Manager) Upgrade(w http.ResponseWriter, r *http.Request, topic string) (*Conn, error) {
+	user := requestUser(r)
+
+	m.mu.Lock()
+	switch {
+	case m.closed:
+		m.mu.Unlock()
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return nil, fmt.Errorf("connection manager is shut down")
+	case m.config.MaxConnections > 0 && len(m.conns) >= m.config.MaxConnections,
+		m.config.MaxPerUser > 0 && m.byUser[user] >= m.config.MaxPerUser:
+		m.mu.Unlock()
+		http.Error(w, ErrLimitReached.Error(), http.StatusTooManyRequests)
+		return nil, ErrLimitReached
+	}
+	// Reserve the slot before upgrading, so concurrent upgrades cannot exceed it
+	m.byUser[user]++
+	m.mu.Unlock()
+
+	ws, err := m.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		m.mu.Lock()
+		m.release(user)
+		m.mu.Unlock()
+		return nil, err
+	}
+
+	// Not derived from the request, whose context ends with the API timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Conn{ws: ws, manager: m, user: user, topic: topic, messages: make(chan []byte, 1), ctx: ctx, cancel: cancel}
+
+	m.mu.Lock()
+	if m.closed {
+		m.release(user)
+		m.mu.Unlock()
+		c.closeWith(websocket.CloseGoingAway, "server shutting down")
+		return nil, fmt.Errorf("connection manager is shut down")
+	}
+	m.conns[c] = struct{}{}
+	m.mu.Unlock()
+
+	ws.SetReadLimit(maxMessageSize)
+	ws.SetReadDeadline(time.Now().Add(m.pongWait()))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(m.pongWait()))
+	})
+	go c.readLoop()
+	go c.pingLoop()
+
+	return c, nil
+}
+
+// CloseTopic closes the connections about a topic, such as a deleted deployment
+func (m *Manager) CloseTopic(topic, reason string) {
+	for _, c := range m.connections(func(c *Conn) bool { return c.topic == topic }) {
+		c.closeWith(websocket.CloseNormalClosure, reason)
+	}
+}
+
+// Shutdown closes every connection and refuses new ones
+func (m *Manager) Shutdown() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+
+	conns := m.connections(func(*Conn) bool { return true })
+	if len(conns) > 0 {
+		slog.Info("Closing WebSocket connections", "connections", len(conns))
+	}
+	for _, c := range conns {
+		c.closeWith(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+// Count returns the number of open connections
+func (m *Manager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.conns)
+}
+
+func (m *Manager) connections(match func(*Conn) bool) []*Conn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var conns []*Conn
+	for c := range m.conns {
+		if match(c) {
+			conns = append(conns, c)
+		}
+	}
+	return conns
+}
+
+// release frees a user's connection slot; the caller holds the lock
+func (m *Manager) release(user string) {
+	if m.byUser[user]--; m.byUser[user] <= 0 {
+		delete(m.byUser, user)
+	}
+}
+
+func (m *Manager) pingInterval() time.Duration {
+	if m.config.PingInterval <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(m.config.PingInterval) * time.Second
+}
+
+// pongWait is how long a client may stay silent: two missed pings
+func (m *Manager) pongWait() time.Duration {
+	return 2*m.pingInterval() + m.writeTimeout()
+}
+
+func (m *Manager) writeTimeout() time.Duration {
+	if m.config.WriteTimeout <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(m.config.WriteTimeout) * time.Second
+}
+
+// Context is done once the connection is closed, for whatever reason
+func (c *Conn) Context() context.Context {
+	return c.ctx
+}
+
+// Messages delivers the text messages the client sends. Messages arriving while
+// one is still unread are dropped
+func (c *Conn) Messages() <-chan []byte {
+	return c.messages
+}
+
+// WriteJSON sends a message, closing the connection when it cannot be written in time
+func (c *Conn) WriteJSON(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	c.ws.SetWriteDeadline(time.Now().Add(c.manager.writeTimeout()))
+	if err := c.ws.WriteJSON(v); err != nil {
+		go c.Close()
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection normally
+func (c *Conn) Close() {
+	c.closeWith(websocket.CloseNormalClosure, "")
+}
+
+// closeWith sends a close frame, closes the connection and releases its slot, once
+func (c *Conn) closeWith(code int, reason string) {
+	c.once.Do(func() {
+		c.cancel()
+
+		c.writeMu.Lock()
+		c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason),
+			time.Now().Add(c.manager.writeTimeout()))
+		c.ws.Close()
+		c.writeMu.Unlock()
+
+		m := c.manager
+		m.mu.Lock()
+		if _, ok := m.conns[c]; ok {
+			delete(m.conns, c)
+			m.release(c.user)
+		}
+		m.mu.Unlock()
+	})
+}
+
+// readLoop consumes client frames so pongs and close frames are processed, hands
+// text messages to Messages, and closes the connection when the client leaves or
+// stops answering pings
+func (c *Conn) readLoop() {
+	defer c.Close()
+	for {
+		messageType, reader, err := c.ws.NextReader()
+		if err != nil {
+			return
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return
+		}
+		select {
+		case c.messages <- data:
+		default:
+		}
+	}
+}
+
+// pingLoop pings the client every ping interval
+func (c *Conn) pingLoop() {
+	ticker := time.NewTicker(c.manager.pingInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.writeMu.Lock()
+			err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.manager.writeTimeout()))
+			c.writeMu.Unlock()
+			if err != nil {
+				c.Close()
+				return
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// requestUser identifies whose connection a request opens: the authenticated
+// user, or the client address when authentication is disabled
+func requestUser(r *http.Request) string {
+	if user := apiMiddleware.UserFromContext(r.Context()); user != nil {
+		return "user:" + user.ID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "addr:" + host
+}
@@ -0,0 +1,181 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"docker-deploy-app/internal/models"
+)
+
+// maxIndexFileSize bounds the JSON files of an archive read while indexing it
+const maxIndexFileSize = 1 << 20
+
+var ErrFileNotInBackup = fmt.Errorf("file not found in backup")
+
+// Contents reads the index of a backup archive: its metadata and, for every
+// deployment, its files and the volumes with their sizes. Only the archive
+// headers and small JSON files are read, but the whole archive is decompressed
+func (m *Manager) Contents(backup *models.Backup, passphrase string) (*models.BackupContents, error) {
+	archive, err := m.openArchive(backup, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	gzipReader, err := gzip.NewReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+
+	contents := &models.BackupContents{BackupID: backup.ID, Deployments: []models.DeploymentContents{}}
+	deployments := make(map[string]*models.DeploymentContents)
+	volumes := make(map[string]map[string]*models.VolumeContents) // By deployment, then volume name
+	var order []string
+
+	deployment := func(id string) *models.DeploymentContents {
+		if d, ok := deployments[id]; ok {
+			return d
+		}
+		d := &models.DeploymentContents{ID: id, Files: []models.BackupFile{}, Volumes: []models.VolumeContents{}, Dumps: []models.BackupFile{}}
+		deployments[id] = d
+		volumes[id] = make(map[string]*models.VolumeContents)
+		order = append(order, id)
+		return d
+	}
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := archivePath(header.Name)
+		regular := header.Typeflag == tar.TypeReg
+		if regular {
+			contents.SizeBytes += header.Size
+		}
+
+		if name == "metadata.json" {
+			var metadata models.BackupMetadata
+			if err := json.NewDecoder(io.LimitReader(tarReader, maxIndexFileSize)).Decode(&metadata); err == nil {
+				contents.Metadata = &metadata
+			}
+			continue
+		}
+
+		// deployments/<id>/deployment.json, volumes.json, files/<name>, dumps/<name> or volumes/<volume>/...
+		parts := strings.Split(name, "/")
+		if len(parts) < 2 || parts[0] != "deployments" {
+			continue
+		}
+		d := deployment(parts[1])
+
+		switch {
+		case len(parts) == 3 && parts[2] == "deployment.json":
+			var info struct {
+				StackName  string `json:"stack_name"`
+				TemplateID string `json:"template_id"`
+			}
+			if err := json.NewDecoder(io.LimitReader(tarReader, maxIndexFileSize)).Decode(&info); err == nil {
+				d.StackName, d.TemplateID = info.StackName, info.TemplateID
+			}
+		case len(parts) == 3 && parts[2] == "volumes.json":
+			var backedUp []models.VolumeBackup
+			if err := json.NewDecoder(io.LimitReader(tarReader, maxIndexFileSize)).Decode(&backedUp); err == nil {
+				for _, vol := range backedUp {
+					v := volumeContents(volumes[d.ID], vol.Name)
+					v.Driver = vol.Driver
+				}
+			}
+		case len(parts) == 4 && parts[2] == "files" && regular:
+			d.Files = append(d.Files, models.BackupFile{Path: name, SizeBytes: header.Size})
+		case len(parts) == 4 && parts[2] == "dumps" && regular:
+			d.Dumps = append(d.Dumps, models.BackupFile{Path: name, SizeBytes: header.Size})
+		case len(parts) >= 4 && parts[2] == "volumes":
+			v := volumeContents(volumes[d.ID], parts[3])
+			if regular {
+				v.Files++
+				v.SizeBytes += header.Size
+			}
+		}
+	}
+
+	for _, id := range order {
+		d := deployments[id]
+		for _, v := range volumes[id] {
+			d.Volumes = append(d.Volumes, *v)
+		}
+		sort.Slice(d.Volumes, func(i, j int) bool { return d.Volumes[i].Name < d.Volumes[j].Name })
+		contents.Deployments = append(contents.Deployments, *d)
+	}
+	return contents, nil
+}
+
+// OpenFile opens a single regular file of a backup archive, given by its path in
+// the archive. The returned header carries its size and modification time
+func (m *Manager) OpenFile(backup *models.Backup, passphrase, name string) (io.ReadCloser, *tar.Header, error) {
+	name = archivePath(name)
+	if name == "" {
+		return nil, nil, ErrFileNotInBackup
+	}
+
+	archive, err := m.openArchive(backup, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gzipReader, err := gzip.NewReader(archive)
+	if err != nil {
+		archive.Close()
+		return nil, nil, err
+	}
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			archive.Close()
+			return nil, nil, err
+		}
+
+		if header.Typeflag == tar.TypeReg && archivePath(header.Name) == name {
+			return struct {
+				io.Reader
+				io.Closer
+			}{tarReader, archive}, header, nil
+		}
+	}
+
+	archive.Close()
+	return nil, nil, ErrFileNotInBackup
+}
+
+// archivePath normalizes a path of an archive entry to a clean, slash-separated
+// path relative to the archive root. The root itself is empty
+func archivePath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
+
+// volumeContents returns the entry for a volume, adding it if needed
+func volumeContents(volumes map[string]*models.VolumeContents, name string) *models.VolumeContents {
+	v, ok := volumes[name]
+	if !ok {
+		v = &models.VolumeContents{Name: name}
+		volumes[name] = v
+	}
+	return v
+}
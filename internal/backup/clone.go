@@ -0,0 +1,103 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/volume"
+	"docker-deploy-app/internal/models"
+)
+
+// CloneVolumes copies the data of a deployment's named volumes into new volumes of
+// targetStack. The data goes through a temporary backup of the deployment, taken and
+// extracted like any other. The volumes carry compose labels so compose adopts them
+// when the target stack comes up. It returns the names of the volumes created
+func (m *Manager) CloneVolumes(deploymentID, targetStack string) ([]string, error) {
+	var stackName string
+	if err := m.db.QueryRow("SELECT stack_name FROM deployments WHERE id = $1", deploymentID).Scan(&stackName); err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	backup, err := m.createBackupNow(&models.BackupConfig{
+		Name:           fmt.Sprintf("%s-clone-%s-%s", stackName, targetStack, time.Now().Format("20060102-150405")),
+		Type:           models.BackupTypeAuto,
+		IncludeVolumes: true,
+		Deployments:    []models.DeploymentBackup{{ID: deploymentID, StackName: stackName}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up volumes: %w", err)
+	}
+	defer m.DeleteBackup(backup.ID)
+
+	restoreDir := filepath.Join(m.storagePath, "restore", backup.ID)
+	defer os.RemoveAll(restoreDir)
+	if err := m.extractArchive(backup, "", restoreDir); err != nil {
+		return nil, fmt.Errorf("failed to extract archive: %w", err)
+	}
+
+	deploymentDir := filepath.Join(restoreDir, "deployments", deploymentID)
+	var volumes []models.VolumeBackup
+	if err := m.loadJSON(filepath.Join(deploymentDir, "volumes.json"), &volumes); err != nil {
+		return nil, fmt.Errorf("failed to read volumes: %w", err)
+	}
+
+	ctx := context.Background()
+	created := make([]string, 0, len(volumes))
+	for _, vol := range volumes {
+		name, err := m.cloneVolume(ctx, vol, stackName, targetStack, filepath.Join(deploymentDir, vol.DataPath))
+		if err != nil {
+			m.removeVolumes(ctx, created)
+			return nil, fmt.Errorf("failed to copy volume %s: %w", vol.Name, err)
+		}
+		created = append(created, name)
+	}
+
+	slog.Info("Cloned volumes", "source", stackName, "target", targetStack, "volumes", len(created))
+	return created, nil
+}
+
+// cloneVolume creates the target stack's counterpart of a backed up volume and
+// copies its data in from dataDir
+func (m *Manager) cloneVolume(ctx context.Context, vol models.VolumeBackup, sourceStack, targetStack, dataDir string) (string, error) {
+	// The compose volume key names the volume within its project
+	key := strings.TrimPrefix(vol.Name, sourceStack+"_")
+	if source, err := m.dockerClient.VolumeInspect(ctx, vol.Name); err == nil && source.Labels["com.docker.compose.volume"] != "" {
+		key = source.Labels["com.docker.compose.volume"]
+	}
+
+	target, err := m.dockerClient.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   targetStack + "_" + key,
+		Driver: vol.Driver,
+		Labels: map[string]string{
+			"com.docker.compose.project": targetStack,
+			"com.docker.compose.volume":  key,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Empty volumes have no data in the archive
+	if _, err := os.Stat(dataDir); os.IsNotExist(err) {
+		return target.Name, nil
+	}
+	if _, err := copyDir(dataDir, target.Mountpoint); err != nil {
+		m.removeVolumes(ctx, []string{target.Name})
+		return "", err
+	}
+	return target.Name, nil
+}
+
+// removeVolumes removes volumes created for a clone that failed
+func (m *Manager) removeVolumes(ctx context.Context, names []string) {
+	for _, name := range names {
+		if err := m.dockerClient.VolumeRemove(ctx, name, true); err != nil {
+			slog.Warn("Failed to remove cloned volume", "volume", name, "error", err)
+		}
+	}
+}
@@ -5,11 +5,53 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted backups are an envelope: a header, then the data in chunks sealed
+// with AES-256-GCM. Each chunk's nonce is the header's random prefix, the chunk
+// number and a final-chunk flag, and the header is authenticated with every
+// chunk, so chunks cannot be altered, reordered, dropped or truncated unnoticed.
+// Backups written before the envelope, an IV followed by AES-CFB data without
+// authentication, are still decrypted with their stored keys
+const (
+	envelopeMagic   = "DDBK"
+	envelopeVersion = 2 // The unversioned CFB format counts as version 1
+
+	chunkSize  = 64 * 1024
+	prefixSize = 7
+	headerSize = len(envelopeMagic) + 1 + 1 + 3 + saltSize + 4 + prefixSize
+	saltSize   = 16
+	maxChunks  = 1<<32 - 1
+	finalChunk = 1
+	keySize    = 32
+)
+
+// Key derivations recorded in the header
+const (
+	kdfNone   byte = 0 // The key is used as is
+	kdfScrypt byte = 1 // The key is derived from a passphrase with scrypt
+)
+
+// scrypt parameters for new passphrase-based backups, N = 2^15. The header
+// records them, so they can be raised without breaking older backups
+const (
+	scryptLogN = 15
+	scryptR    = 8
+	scryptP    = 1
+)
+
+var (
+	ErrBackupTampered   = fmt.Errorf("backup is corrupted or was modified, or the key is wrong")
+	ErrBackupTruncated  = fmt.Errorf("backup is truncated")
+	ErrPassphraseNeeded = fmt.Errorf("backup is encrypted with a passphrase")
 )
 
 // EncryptionManager handles backup encryption and decryption
@@ -24,129 +66,330 @@ func NewEncryptionManager(keyStorage string) *EncryptionManager {
 	}
 }
 
+// envelopeHeader is the versioned metadata at the start of an encrypted backup
+type envelopeHeader struct {
+	kdf       byte
+	logN      byte
+	r         byte
+	p         byte
+	salt      [saltSize]byte
+	chunkSize uint32
+	prefix    [prefixSize]byte
+}
+
+func (h *envelopeHeader) marshal() []byte {
+	buf := make([]byte, 0, headerSize)
+	buf = append(buf, envelopeMagic...)
+	buf = append(buf, envelopeVersion, h.kdf, h.logN, h.r, h.p)
+	buf = append(buf, h.salt[:]...)
+	buf = binary.BigEndian.AppendUint32(buf, h.chunkSize)
+	return append(buf, h.prefix[:]...)
+}
+
+// parseHeader reads the header following the magic bytes
+func parseHeader(data []byte) (*envelopeHeader, error) {
+	if data[len(envelopeMagic)] != envelopeVersion {
+		return nil, fmt.Errorf("unsupported backup encryption version %d", data[len(envelopeMagic)])
+	}
+	rest := data[len(envelopeMagic)+1:]
+
+	h := &envelopeHeader{kdf: rest[0], logN: rest[1], r: rest[2], p: rest[3]}
+	rest = rest[4:]
+	copy(h.salt[:], rest)
+	h.chunkSize = binary.BigEndian.Uint32(rest[saltSize:])
+	copy(h.prefix[:], rest[saltSize+4:])
+
+	if h.chunkSize == 0 || h.chunkSize > 16*1024*1024 {
+		return nil, fmt.Errorf("invalid backup chunk size %d", h.chunkSize)
+	}
+	if h.kdf != kdfNone && h.kdf != kdfScrypt {
+		return nil, fmt.Errorf("unsupported backup key derivation %d", h.kdf)
+	}
+	return h, nil
+}
+
+// key returns the AES key for the header: the given key, or the passphrase
+// stretched with the header's scrypt parameters
+func (h *envelopeHeader) key(key []byte, passphrase string) ([]byte, error) {
+	if h.kdf == kdfNone {
+		if len(key) == 0 {
+			return nil, fmt.Errorf("backup is encrypted with a key, not a passphrase")
+		}
+		return key, nil
+	}
+	if passphrase == "" {
+		return nil, ErrPassphraseNeeded
+	}
+	if h.logN > 20 {
+		return nil, fmt.Errorf("scrypt cost 2^%d is too high", h.logN)
+	}
+	return scrypt.Key([]byte(passphrase), h.salt[:], 1<<h.logN, int(h.r), int(h.p), keySize)
+}
+
+// nonce returns the nonce of a chunk
+func (h *envelopeHeader) nonce(counter uint32, final bool) []byte {
+	nonce := make([]byte, 0, 12)
+	nonce = append(nonce, h.prefix[:]...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if final {
+		return append(nonce, finalChunk)
+	}
+	return append(nonce, 0)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != keySize {
+		return nil, fmt.Errorf("backup keys must be %d bytes, got %d", keySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 // EncryptedReader wraps an io.Reader to provide encryption
 type EncryptedReader struct {
-	reader io.Reader
-	cipher cipher.Stream
+	reader  io.Reader
+	aead    cipher.AEAD
+	header  *envelopeHeader
+	ad      []byte
+	counter uint32
+	plain   []byte
+	pending []byte
+	done    bool
 }
 
-// NewEncryptedReader creates a new encrypted reader
+// NewEncryptedReader encrypts with a 32-byte key, such as one from StoreKey
 func NewEncryptedReader(reader io.Reader, key []byte) (*EncryptedReader, error) {
-	block, err := aes.NewCipher(key)
+	return newEncryptedReader(reader, &envelopeHeader{kdf: kdfNone}, key)
+}
+
+// NewPassphraseEncryptedReader encrypts with a key derived from a passphrase
+// with scrypt and a random salt, both recorded in the header
+func NewPassphraseEncryptedReader(reader io.Reader, passphrase string) (*EncryptedReader, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase is empty")
+	}
+	header := &envelopeHeader{kdf: kdfScrypt, logN: scryptLogN, r: scryptR, p: scryptP}
+	if _, err := io.ReadFull(rand.Reader, header.salt[:]); err != nil {
+		return nil, err
+	}
+	key, err := header.key(nil, passphrase)
 	if err != nil {
 		return nil, err
 	}
+	return newEncryptedReader(reader, header, key)
+}
 
-	// Generate random IV
-	iv := make([]byte, aes.BlockSize)
-	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+func newEncryptedReader(reader io.Reader, header *envelopeHeader, key []byte) (*EncryptedReader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header.chunkSize = chunkSize
+	if _, err := io.ReadFull(rand.Reader, header.prefix[:]); err != nil {
 		return nil, err
 	}
 
-	stream := cipher.NewCFBEncrypter(block, iv)
+	ad := header.marshal()
+	return &EncryptedReader{
+		reader:  reader,
+		aead:    aead,
+		header:  header,
+		ad:      ad,
+		plain:   make([]byte, chunkSize),
+		pending: append([]byte(nil), ad...),
+	}, nil
+}
 
-	// Create a reader that first returns the IV, then encrypted data
-	ivReader := &EncryptedReader{
-		reader: io.MultiReader(
-			&singleUseReader{data: iv},
-			&streamReader{reader: reader, stream: stream},
-		),
+// Read implements io.Reader
+func (er *EncryptedReader) Read(p []byte) (int, error) {
+	for len(er.pending) == 0 {
+		if er.done {
+			return 0, io.EOF
+		}
+		if err := er.sealChunk(); err != nil {
+			return 0, err
+		}
 	}
 
-	return ivReader, nil
+	n := copy(p, er.pending)
+	er.pending = er.pending[n:]
+	return n, nil
 }
 
-// Read implements io.Reader
-func (er *EncryptedReader) Read(p []byte) (n int, err error) {
-	return er.reader.Read(p)
+// sealChunk encrypts the next chunk. A short chunk is the final one; data ending
+// on a chunk boundary is followed by an empty final chunk
+func (er *EncryptedReader) sealChunk() error {
+	n, err := io.ReadFull(er.reader, er.plain)
+	final := false
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		final = true
+	default:
+		return err
+	}
+	if er.counter == maxChunks {
+		return fmt.Errorf("backup is too large to encrypt")
+	}
+
+	er.pending = er.aead.Seal(er.pending[:0], er.header.nonce(er.counter, final), er.plain[:n], er.ad)
+	er.counter++
+	er.done = final
+	return nil
 }
 
 // DecryptedReader wraps an io.Reader to provide decryption
 type DecryptedReader struct {
-	reader io.Reader
-	cipher cipher.Stream
-	ivRead bool
-	key    []byte
+	reader     io.Reader
+	key        []byte
+	passphrase string
+	started    bool
+	legacy     cipher.Stream
+	aead       cipher.AEAD
+	header     *envelopeHeader
+	ad         []byte
+	counter    uint32
+	sealed     []byte
+	pending    []byte
+	done       bool
 }
 
-// NewDecryptedReader creates a new decrypted reader
+// NewDecryptedReader decrypts a backup encrypted with a key, in the envelope or
+// the legacy CFB format
 func NewDecryptedReader(reader io.Reader, key []byte) (*DecryptedReader, error) {
 	return &DecryptedReader{
 		reader: reader,
-		ivRead: false,
 		key:    key,
 	}, nil
 }
 
-// Read implements io.Reader
-func (dr *DecryptedReader) Read(p []byte) (n int, err error) {
-	if !dr.ivRead {
-		// Read IV first
-		iv := make([]byte, aes.BlockSize)
-		if _, err := io.ReadFull(dr.reader, iv); err != nil {
-			return 0, err
-		}
+// NewPassphraseDecryptedReader decrypts a backup encrypted with a passphrase
+func NewPassphraseDecryptedReader(reader io.Reader, passphrase string) (*DecryptedReader, error) {
+	return &DecryptedReader{
+		reader:     reader,
+		passphrase: passphrase,
+	}, nil
+}
 
-		// Create cipher
-		block, err := aes.NewCipher(dr.key)
-		if err != nil {
+// Read implements io.Reader. Data is only returned once its chunk authenticated
+func (dr *DecryptedReader) Read(p []byte) (int, error) {
+	if !dr.started {
+		if err := dr.start(); err != nil {
 			return 0, err
 		}
+	}
 
-		dr.cipher = cipher.NewCFBDecrypter(block, iv)
-		dr.ivRead = true
+	if dr.legacy != nil {
+		n, err := dr.reader.Read(p)
+		if n > 0 {
+			dr.legacy.XORKeyStream(p[:n], p[:n])
+		}
+		return n, err
 	}
 
-	// Read encrypted data
-	encrypted := make([]byte, len(p))
-	n, err = dr.reader.Read(encrypted)
-	if n > 0 {
-		dr.cipher.XORKeyStream(p[:n], encrypted[:n])
+	for len(dr.pending) == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		if err := dr.openChunk(); err != nil {
+			return 0, err
+		}
 	}
 
-	return n, err
+	n := copy(p, dr.pending)
+	dr.pending = dr.pending[n:]
+	return n, nil
 }
 
-// singleUseReader reads data once
-type singleUseReader struct {
-	data []byte
-	pos  int
-}
+// start reads the header, or the IV of a legacy backup
+func (dr *DecryptedReader) start() error {
+	dr.started = true
 
-func (r *singleUseReader) Read(p []byte) (n int, err error) {
-	if r.pos >= len(r.data) {
-		return 0, io.EOF
+	prefix := make([]byte, len(envelopeMagic))
+	if _, err := io.ReadFull(dr.reader, prefix); err != nil {
+		return ErrBackupTruncated
 	}
 
-	n = copy(p, r.data[r.pos:])
-	r.pos += n
-	return n, nil
-}
+	if string(prefix) != envelopeMagic {
+		// Legacy backup: the bytes read begin its IV
+		if dr.key == nil {
+			return fmt.Errorf("backup predates passphrase encryption, decrypt it with its stored key")
+		}
+		iv := make([]byte, aes.BlockSize)
+		copy(iv, prefix)
+		if _, err := io.ReadFull(dr.reader, iv[len(prefix):]); err != nil {
+			return ErrBackupTruncated
+		}
+		block, err := aes.NewCipher(dr.key)
+		if err != nil {
+			return err
+		}
+		dr.legacy = cipher.NewCFBDecrypter(block, iv)
+		return nil
+	}
 
-// streamReader encrypts data on the fly
-type streamReader struct {
-	reader io.Reader
-	stream cipher.Stream
+	data := make([]byte, headerSize)
+	copy(data, prefix)
+	if _, err := io.ReadFull(dr.reader, data[len(prefix):]); err != nil {
+		return ErrBackupTruncated
+	}
+	header, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+	key, err := header.key(dr.key, dr.passphrase)
+	if err != nil {
+		return err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	dr.header, dr.aead, dr.ad = header, aead, data
+	dr.sealed = make([]byte, int(header.chunkSize)+aead.Overhead())
+	return nil
 }
 
-func (sr *streamReader) Read(p []byte) (n int, err error) {
-	n, err = sr.reader.Read(p)
-	if n > 0 {
-		sr.stream.XORKeyStream(p[:n], p[:n])
+// openChunk authenticates and decrypts the next chunk. Only a chunk shorter than
+// a full one can be final
+func (dr *DecryptedReader) openChunk() error {
+	n, err := io.ReadFull(dr.reader, dr.sealed)
+	final := false
+	switch err {
+	case nil:
+	case io.ErrUnexpectedEOF:
+		final = true
+	case io.EOF:
+		return ErrBackupTruncated
+	default:
+		return err
 	}
-	return n, err
+
+	plain, err := dr.aead.Open(dr.pending[:0], dr.header.nonce(dr.counter, final), dr.sealed[:n], dr.ad)
+	if err != nil {
+		return ErrBackupTampered
+	}
+	dr.pending = plain
+	dr.counter++
+	dr.done = final
+	return nil
 }
 
-// GenerateKey generates an encryption key from a password
-func (em *EncryptionManager) GenerateKey(password string, salt []byte) []byte {
+// GenerateKey derives a 32-byte encryption key from a password with scrypt. A
+// nil salt is replaced with a random one, which must then be kept to derive the
+// key again; passphrase readers keep it in the header instead
+func (em *EncryptionManager) GenerateKey(password string, salt []byte) ([]byte, error) {
 	if salt == nil {
-		salt = make([]byte, 32)
-		rand.Read(salt)
+		salt = make([]byte, saltSize)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, err
+		}
 	}
-	// Simple key derivation using SHA256
-	combined := append([]byte(password), salt...)
-	hash := sha256.Sum256(combined)
-	return hash[:]
+	return scrypt.Key([]byte(password), salt, 1<<scryptLogN, scryptR, scryptP, keySize)
 }
 
 // EncryptFile encrypts a file
@@ -176,7 +419,8 @@ func (em *EncryptionManager) EncryptFile(srcPath, dstPath string, key []byte) er
 	return nil
 }
 
-// DecryptFile decrypts a file
+// DecryptFile decrypts a file. Nothing of a backup failing authentication is
+// kept: the partly written destination is removed
 func (em *EncryptionManager) DecryptFile(srcPath, dstPath string, key []byte) error {
 	srcFile, err := os.Open(srcPath)
 	if err != nil {
@@ -197,12 +441,35 @@ func (em *EncryptionManager) DecryptFile(srcPath, dstPath string, key []byte) er
 
 	_, err = io.Copy(dstFile, decryptedReader)
 	if err != nil {
+		dstFile.Close()
+		os.Remove(dstPath)
 		return fmt.Errorf("failed to decrypt file: %w", err)
 	}
 
 	return nil
 }
 
+// VerifyKey verifies that a key decrypts a backup by authenticating its first
+// chunk. Legacy backups carry nothing to check a key against, so any key passes
+func (em *EncryptionManager) VerifyKey(backupPath string, key []byte) error {
+	file, err := os.Open(backupPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decryptedReader, err := NewDecryptedReader(file, key)
+	if err != nil {
+		return err
+	}
+
+	// The first read opens the first chunk
+	if _, err := decryptedReader.Read(make([]byte, 1)); err != nil && err != io.EOF {
+		return fmt.Errorf("key verification failed: %w", err)
+	}
+	return nil
+}
+
 // StoreKey stores an encryption key securely
 func (em *EncryptionManager) StoreKey(backupID string, key []byte) error {
 	keyDir := filepath.Join(em.keyStorage, "keys")
@@ -257,38 +524,6 @@ func (em *EncryptionManager) DeleteKey(backupID string) error {
 	return nil
 }
 
-// VerifyKey verifies that a key can decrypt a backup
-func (em *EncryptionManager) VerifyKey(backupPath string, key []byte) error {
-	// Try to read the first few bytes of the backup and decrypt them
-	file, err := os.Open(backupPath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Read first 1KB for verification
-	testData := make([]byte, 1024)
-	n, err := file.Read(testData)
-	if err != nil && err != io.EOF {
-		return err
-	}
-
-	// Try to decrypt
-	decryptedReader, err := NewDecryptedReader(&singleUseReader{data: testData[:n]}, key)
-	if err != nil {
-		return err
-	}
-
-	// Read a bit to see if decryption works
-	testDecrypt := make([]byte, 100)
-	_, err = decryptedReader.Read(testDecrypt)
-	if err != nil && err != io.EOF {
-		return fmt.Errorf("key verification failed: %w", err)
-	}
-
-	return nil
-}
-
 // CreateBackupSignature creates a signature for backup integrity verification
 func (em *EncryptionManager) CreateBackupSignature(backupPath string) (string, error) {
 	file, err := os.Open(backupPath)
@@ -318,9 +553,3 @@ func (em *EncryptionManager) VerifyBackupSignature(backupPath, expectedSignature
 
 	return nil
 }
-
-// Helper function to generate a key (simplified)
-func generateKey(password string) []byte {
-	hash := sha256.Sum256([]byte(password))
-	return hash[:]
-}
\ No newline at end of file
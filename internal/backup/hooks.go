@@ -0,0 +1,236 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/pkg/stdcopy"
+	"docker-deploy-app/internal/models"
+)
+
+const (
+	// hookTimeout bounds a single dump or restore command
+	hookTimeout = 30 * time.Minute
+
+	// hookReadyTimeout bounds waiting for a restored service to come up before
+	// its dump is loaded
+	hookReadyTimeout = 2 * time.Minute
+)
+
+// templateBackupHooks returns the backup hooks the template of a deployment
+// declares. Deployments without a template have none
+func (m *Manager) templateBackupHooks(templateID string) ([]models.BackupHook, error) {
+	if templateID == "" {
+		return nil, nil
+	}
+	var hooksJSON string
+	err := m.db.QueryRow("SELECT COALESCE(backup_hooks, '') FROM templates WHERE id = $1", templateID).Scan(&hooksJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	template := models.Template{}
+	if err := template.UnmarshalBackupHooks(hooksJSON); err != nil {
+		return nil, fmt.Errorf("invalid backup hooks of template %s: %w", templateID, err)
+	}
+	return template.BackupHooks, nil
+}
+
+// runDumpHooks runs the dump command of each hook in its service's container,
+// saving the dumps under dumps/ of the deployment directory and listing them in
+// dumps.json. A hook whose service is not running is skipped, as the files of a
+// stopped service copy consistently. It returns the compose volumes covered by
+// the dumps taken, whose files need not be copied
+func (m *Manager) runDumpHooks(stackName, deploymentDir string, hooks []models.BackupHook) (map[string]bool, error) {
+	covered := make(map[string]bool)
+	if len(hooks) == 0 {
+		return covered, nil
+	}
+
+	var dumps []models.DumpBackup
+	for _, hook := range hooks {
+		containerID, err := m.serviceContainer(stackName, hook.Service)
+		if err != nil {
+			return nil, err
+		}
+		if containerID == "" {
+			slog.Info("Skipping backup hook of a service that is not running", "stack", stackName, "service", hook.Service)
+			continue
+		}
+
+		dataPath := filepath.Join("dumps", hook.DumpName()+".dump")
+		size, err := m.dump(containerID, hook.Dump, filepath.Join(deploymentDir, dataPath))
+		if err != nil {
+			return nil, fmt.Errorf("backup hook %s failed: %w", hook.DumpName(), err)
+		}
+
+		dumps = append(dumps, models.DumpBackup{
+			Name:      hook.DumpName(),
+			Service:   hook.Service,
+			DataPath:  dataPath,
+			SizeBytes: size,
+			Restore:   hook.Restore,
+		})
+		for _, vol := range hook.Volumes {
+			covered[vol] = true
+		}
+	}
+
+	if err := m.saveJSON(filepath.Join(deploymentDir, "dumps.json"), dumps); err != nil {
+		return nil, err
+	}
+	return covered, nil
+}
+
+// dump runs a dump command in a container, writing its standard output to path
+func (m *Manager) dump(containerID, command, path string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if err := m.exec(containerID, command, nil, file); err != nil {
+		return 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// runRestoreHooks loads the dumps of a restored deployment back into its
+// services, once they are up, with the restore command saved with each dump.
+// Dumps without one are left in the archive for a manual restore
+func (m *Manager) runRestoreHooks(stackName, deploymentDir string) error {
+	var dumps []models.DumpBackup
+	if err := m.loadJSON(filepath.Join(deploymentDir, "dumps.json"), &dumps); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read dumps: %w", err)
+	}
+
+	for _, dump := range dumps {
+		if dump.Restore == "" {
+			slog.Warn("Dump has no restore command; restore it manually", "stack", stackName, "dump", dump.Name)
+			continue
+		}
+		containerID, err := m.waitForService(stackName, dump.Service)
+		if err != nil {
+			return fmt.Errorf("failed to restore dump %s: %w", dump.Name, err)
+		}
+
+		file, err := os.Open(filepath.Join(deploymentDir, dump.DataPath))
+		if err != nil {
+			return fmt.Errorf("failed to open dump %s: %w", dump.Name, err)
+		}
+		err = m.exec(containerID, dump.Restore, file, io.Discard)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to restore dump %s: %w", dump.Name, err)
+		}
+		slog.Info("Restored dump", "stack", stackName, "service", dump.Service, "dump", dump.Name)
+	}
+	return nil
+}
+
+// exec runs a command with sh in a container, streaming stdin to it when set
+// and its standard output to stdout. A non-zero exit fails with its stderr
+func (m *Manager) exec(containerID, command string, stdin io.Reader, stdout io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+
+	created, err := m.dockerClient.ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		Cmd:          []string{"sh", "-c", command},
+		AttachStdin:  stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	resp, err := m.dockerClient.ContainerExecAttach(ctx, created.ID, types.ExecStartCheck{})
+	if err != nil {
+		return fmt.Errorf("failed to start exec: %w", err)
+	}
+	defer resp.Close()
+
+	if stdin != nil {
+		go func() {
+			io.Copy(resp.Conn, stdin)
+			resp.CloseWrite()
+		}()
+	}
+
+	var stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(stdout, &stderr, resp.Reader); err != nil {
+		return fmt.Errorf("failed to read exec output: %w", err)
+	}
+
+	inspect, err := m.dockerClient.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("command exited with code %d: %s", inspect.ExitCode, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// serviceContainer returns the ID of a running container of a compose service,
+// or "" if none runs
+func (m *Manager) serviceContainer(stackName, service string) (string, error) {
+	containers, err := m.dockerClient.ContainerList(context.Background(), types.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", "com.docker.compose.project="+stackName),
+			filters.Arg("label", "com.docker.compose.service="+service),
+		),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list containers: %w", err)
+	}
+	if len(containers) == 0 {
+		return "", nil
+	}
+	return containers[0].ID, nil
+}
+
+// waitForService waits for a container of a compose service to run and, if it
+// has a health check, to be healthy, returning its ID
+func (m *Manager) waitForService(stackName, service string) (string, error) {
+	deadline := time.Now().Add(hookReadyTimeout)
+	for {
+		containerID, err := m.serviceContainer(stackName, service)
+		if err != nil {
+			return "", err
+		}
+		if containerID != "" {
+			inspect, err := m.dockerClient.ContainerInspect(context.Background(), containerID)
+			if err == nil && (inspect.State.Health == nil || inspect.State.Health.Status == types.Healthy) {
+				return containerID, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("service %s did not become ready within %s", service, hookReadyTimeout)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
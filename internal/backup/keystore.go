@@ -0,0 +1,271 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"docker-deploy-app/internal/config"
+)
+
+// Key storages of encrypted backups, recorded with each backup
+const (
+	KeyStorageLocal      = "local"
+	KeyStorageKMS        = "kms"
+	KeyStorageVault      = "vault"
+	KeyStoragePassphrase = "passphrase"
+)
+
+var (
+	ErrEncryptionDisabled = fmt.Errorf("backup encryption is disabled")
+	ErrPassphraseRequired = fmt.Errorf("a passphrase is required to encrypt backups with passphrase key storage")
+	ErrUnknownKeyStorage  = fmt.Errorf("unknown key storage")
+)
+
+// KeyStore keeps the keys of encrypted backups. A new key comes with metadata,
+// recorded with the backup, that the store needs to recover the key later
+type KeyStore interface {
+	NewKey(ctx context.Context, backupID string) (key []byte, metadata string, err error)
+	Key(ctx context.Context, backupID, metadata string) ([]byte, error)
+	Delete(ctx context.Context, backupID, metadata string) error
+}
+
+// NewKeyStore returns the store for keys kept in storage. Passphrase key storage
+// keeps no keys and has no store
+func NewKeyStore(storage string, cfg config.EncryptionConfig) (KeyStore, error) {
+	switch storage {
+	case KeyStorageLocal, "": // Backups from before key storage was recorded
+		return &localKeyStore{keys: NewEncryptionManager(cfg.KeyPath)}, nil
+	case KeyStorageKMS:
+		return &kmsKeyStore{keyID: cfg.KMS.KeyID, region: cfg.KMS.Region}, nil
+	case KeyStorageVault:
+		return &vaultKeyStore{
+			address: strings.TrimRight(cfg.Vault.Address, "/"),
+			token:   cfg.Vault.Token,
+			mount:   cfg.Vault.Mount,
+			keyName: cfg.Vault.KeyName,
+			client:  &http.Client{Timeout: 30 * time.Second},
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownKeyStorage, storage)
+}
+
+// newDataKey returns a random key for a backup
+func newDataKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// localKeyStore keeps keys as files on disk, one per backup
+type localKeyStore struct {
+	keys *EncryptionManager
+}
+
+func (s *localKeyStore) NewKey(ctx context.Context, backupID string) ([]byte, string, error) {
+	key, err := newDataKey()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.keys.StoreKey(backupID, key); err != nil {
+		return nil, "", err
+	}
+	return key, "", nil
+}
+
+func (s *localKeyStore) Key(ctx context.Context, backupID, metadata string) ([]byte, error) {
+	return s.keys.RetrieveKey(backupID)
+}
+
+func (s *localKeyStore) Delete(ctx context.Context, backupID, metadata string) error {
+	return s.keys.DeleteKey(backupID)
+}
+
+// kmsKeyMetadata is recorded with backups whose key is wrapped by KMS
+type kmsKeyMetadata struct {
+	KeyID      string `json:"key_id"`
+	Ciphertext []byte `json:"ciphertext"` // The wrapped data key
+}
+
+// kmsKeyStore generates a data key per backup with AWS KMS and keeps only the
+// wrapped key, in the backup record. The backup ID is bound to it as encryption
+// context, so a wrapped key cannot be moved to another backup
+type kmsKeyStore struct {
+	keyID  string
+	region string
+}
+
+func (s *kmsKeyStore) client(ctx context.Context) (*kms.Client, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if s.region != "" {
+		opts = append(opts, awsconfig.WithRegion(s.region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return kms.NewFromConfig(awsCfg), nil
+}
+
+func (s *kmsKeyStore) NewKey(ctx context.Context, backupID string) ([]byte, string, error) {
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	out, err := client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:             aws.String(s.keyID),
+		KeySpec:           kmstypes.DataKeySpecAes256,
+		EncryptionContext: map[string]string{"backup_id": backupID},
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate KMS data key: %w", err)
+	}
+
+	metadata, err := json.Marshal(kmsKeyMetadata{KeyID: aws.ToString(out.KeyId), Ciphertext: out.CiphertextBlob})
+	if err != nil {
+		return nil, "", err
+	}
+	return out.Plaintext, string(metadata), nil
+}
+
+func (s *kmsKeyStore) Key(ctx context.Context, backupID, metadata string) ([]byte, error) {
+	var meta kmsKeyMetadata
+	if err := json.Unmarshal([]byte(metadata), &meta); err != nil {
+		return nil, fmt.Errorf("invalid KMS key metadata: %w", err)
+	}
+
+	client, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob:    meta.Ciphertext,
+		KeyId:             aws.String(meta.KeyID),
+		EncryptionContext: map[string]string{"backup_id": backupID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt KMS data key: %w", err)
+	}
+	return out.Plaintext, nil
+}
+
+// Delete does nothing: the wrapped key goes with the backup record
+func (s *kmsKeyStore) Delete(ctx context.Context, backupID, metadata string) error {
+	return nil
+}
+
+// vaultKeyMetadata is recorded with backups whose key is wrapped by Vault
+type vaultKeyMetadata struct {
+	Mount      string `json:"mount"`
+	KeyName    string `json:"key_name"`
+	Ciphertext string `json:"ciphertext"` // The wrapped data key, vault:v<n>:...
+}
+
+// vaultKeyStore generates a data key per backup with the transit secrets engine
+// of HashiCorp Vault and keeps only the wrapped key, in the backup record
+type vaultKeyStore struct {
+	address string
+	token   string
+	mount   string
+	keyName string
+	client  *http.Client
+}
+
+func (s *vaultKeyStore) NewKey(ctx context.Context, backupID string) ([]byte, string, error) {
+	var out struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	path := fmt.Sprintf("%s/datakey/plaintext/%s", s.mount, s.keyName)
+	if err := s.call(ctx, path, map[string]interface{}{"bits": keySize * 8}, &out); err != nil {
+		return nil, "", fmt.Errorf("failed to generate Vault data key: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(out.Plaintext)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid Vault data key: %w", err)
+	}
+
+	metadata, err := json.Marshal(vaultKeyMetadata{Mount: s.mount, KeyName: s.keyName, Ciphertext: out.Ciphertext})
+	if err != nil {
+		return nil, "", err
+	}
+	return key, string(metadata), nil
+}
+
+func (s *vaultKeyStore) Key(ctx context.Context, backupID, metadata string) ([]byte, error) {
+	var meta vaultKeyMetadata
+	if err := json.Unmarshal([]byte(metadata), &meta); err != nil {
+		return nil, fmt.Errorf("invalid Vault key metadata: %w", err)
+	}
+
+	var out struct {
+		Plaintext string `json:"plaintext"`
+	}
+	path := fmt.Sprintf("%s/decrypt/%s", meta.Mount, meta.KeyName)
+	if err := s.call(ctx, path, map[string]interface{}{"ciphertext": meta.Ciphertext}, &out); err != nil {
+		return nil, fmt.Errorf("failed to decrypt Vault data key: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(out.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Vault data key: %w", err)
+	}
+	return key, nil
+}
+
+// Delete does nothing: the wrapped key goes with the backup record
+func (s *vaultKeyStore) Delete(ctx context.Context, backupID, metadata string) error {
+	return nil
+}
+
+// call posts body to a Vault API path and decodes the data of the response into out
+func (s *vaultKeyStore) call(ctx context.Context, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.address+"/v1/"+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil && resp.StatusCode < 300 {
+		return fmt.Errorf("invalid Vault response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.Join(result.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned %d", resp.StatusCode)
+	}
+	return json.Unmarshal(result.Data, out)
+}
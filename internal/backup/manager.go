@@ -3,36 +3,113 @@ package backup
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/docker"
 	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/tasks"
+	"docker-deploy-app/internal/webhooks"
 )
 
+// deploymentFiles are the files of a deployment's project directory included in
+// its backup
+var deploymentFiles = []string{
+	"docker-compose.yml",
+	"docker-compose.yaml",
+	"docker-compose.override.yml",
+	"docker-compose.override.yaml",
+	".env",
+}
+
 // Manager handles backup and restore operations
 type Manager struct {
-	db           *sql.DB
-	dockerClient *client.Client
-	storagePath  string
+	db             *sql.DB
+	dockerClient   *client.Client
+	storagePath    string
+	deploymentsDir string // Holds the project directory of every stack
+	encryption     config.EncryptionConfig
+	ports          *docker.PortChecker
+	compose        *docker.ComposeManager // Brings up deployments restored under a new stack name, when set
+	tasks          *tasks.Tracker
+	webhooks       *webhooks.Publisher
+
+	// beforeOverwrite runs before a restore replaces an existing deployment
+	beforeOverwrite func(deploymentID string) error
 }
 
 // NewManager creates a new backup manager
-func NewManager(db *sql.DB, dockerClient *client.Client, storagePath string) *Manager {
+func NewManager(db *sql.DB, dockerClient *client.Client, cfg config.BackupConfig, deploymentsDir string) *Manager {
 	return &Manager{
-		db:           db,
-		dockerClient: dockerClient,
-		storagePath:  storagePath,
+		db:             db,
+		dockerClient:   dockerClient,
+		storagePath:    cfg.Storage.Path,
+		deploymentsDir: deploymentsDir,
+		encryption:     cfg.Encryption,
+		ports:          docker.NewPortChecker(dockerClient),
+		tasks:          tasks.NewTracker(db),
+		webhooks:       webhooks.NewPublisher(db),
+	}
+}
+
+// SetBeforeOverwrite sets a hook run before a restore replaces an existing
+// deployment. An error from it fails the restore
+func (m *Manager) SetBeforeOverwrite(fn func(deploymentID string) error) {
+	m.beforeOverwrite = fn
+}
+
+// SetCompose sets the compose manager bringing up deployments restored under a
+// new stack name. Without it they are recorded stopped
+func (m *Manager) SetCompose(compose *docker.ComposeManager) {
+	m.compose = compose
+}
+
+// CreateBackup creates a new backup in the background and returns it with the ID of
+// the task tracking it
+func (m *Manager) CreateBackup(config *models.BackupConfig) (*models.Backup, string, error) {
+	backup, taskID, err := m.startBackup(config)
+	if err != nil {
+		return nil, "", err
 	}
+
+	go m.performBackup(taskID, backup, config)
+	return backup, taskID, nil
 }
 
-// CreateBackup creates a new backup
-func (m *Manager) CreateBackup(config *models.BackupConfig) (*models.Backup, error) {
+// createBackupNow creates a backup and waits for it to complete
+func (m *Manager) createBackupNow(config *models.BackupConfig) (*models.Backup, error) {
+	backup, taskID, err := m.startBackup(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.performBackup(taskID, backup, config); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// startBackup records a new backup and the task tracking it
+func (m *Manager) startBackup(config *models.BackupConfig) (*models.Backup, string, error) {
+	if config.Encrypted && !m.encryption.Enabled {
+		return nil, "", ErrEncryptionDisabled
+	}
+	if config.Encrypted && m.encryption.KeyStorage == KeyStoragePassphrase && m.passphrase(config.Passphrase) == "" {
+		return nil, "", ErrPassphraseRequired
+	}
+
 	backup := &models.Backup{
 		ID:             generateBackupID(),
 		Name:           config.Name,
@@ -47,42 +124,43 @@ func (m *Manager) CreateBackup(config *models.BackupConfig) (*models.Backup, err
 	// Create backup directory
 	backupDir := filepath.Join(m.storagePath, backup.ID)
 	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+		return nil, "", fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
 	// Save initial backup record
 	if err := m.saveBackupRecord(backup); err != nil {
-		return nil, fmt.Errorf("failed to save backup record: %w", err)
+		return nil, "", fmt.Errorf("failed to save backup record: %w", err)
 	}
 
-	// Start backup process
-	go m.performBackup(backup, config)
-
-	return backup, nil
+	taskID := m.tasks.Start(models.TaskTypeBackup, backup.ID, "Creating backup")
+	return backup, taskID, nil
 }
 
-// RestoreBackup restores from a backup
-func (m *Manager) RestoreBackup(config *models.RestoreConfig) error {
+// RestoreBackup restores from a backup in the background and returns the ID of
+// the task tracking it
+func (m *Manager) RestoreBackup(config *models.RestoreConfig) (string, error) {
 	backup, err := m.getBackup(config.BackupID)
 	if err != nil {
-		return fmt.Errorf("failed to get backup: %w", err)
+		return "", fmt.Errorf("failed to get backup: %w", err)
 	}
 
 	if backup.Status != models.BackupStatusCompleted {
-		return fmt.Errorf("backup is not completed")
+		return "", fmt.Errorf("backup is not completed")
 	}
 
 	// Start restore process
-	go m.performRestore(backup, config)
+	taskID := m.tasks.Start(models.TaskTypeRestore, backup.ID, "Restoring backup")
+	go m.performRestore(taskID, backup, config)
 
-	return nil
+	return taskID, nil
 }
 
 // ListBackups returns all backups
 func (m *Manager) ListBackups() ([]*models.Backup, error) {
 	query := `
 		SELECT id, name, type, status, size_bytes, include_volumes, encrypted,
-		       storage_path, deployment_ids, created_at, completed_at
+		       storage_path, deployment_ids, created_at, completed_at,
+		       COALESCE(key_storage, ''), COALESCE(key_metadata, '')
 		FROM backups ORDER BY created_at DESC`
 
 	rows, err := m.db.Query(query)
@@ -120,6 +198,15 @@ func (m *Manager) DeleteBackup(backupID string) error {
 		os.Remove(backup.StoragePath)
 	}
 
+	// Remove its key, if it is kept apart from the backup record
+	if backup.Encrypted && backup.KeyStorage != KeyStoragePassphrase {
+		if store, err := NewKeyStore(backup.KeyStorage, m.encryption); err == nil {
+			if err := store.Delete(context.Background(), backup.ID, backup.KeyMetadata); err != nil {
+				slog.Warn("Failed to delete backup key", "backup_id", backupID, "error", err)
+			}
+		}
+	}
+
 	// Remove backup directory
 	backupDir := filepath.Join(m.storagePath, backupID)
 	os.RemoveAll(backupDir)
@@ -130,17 +217,24 @@ func (m *Manager) DeleteBackup(backupID string) error {
 }
 
 // performBackup executes the backup process
-func (m *Manager) performBackup(backup *models.Backup, config *models.BackupConfig) {
-	defer m.updateBackupStatus(backup.ID, models.BackupStatusCompleted)
-
+func (m *Manager) performBackup(taskID string, backup *models.Backup, config *models.BackupConfig) error {
 	backupDir := filepath.Join(m.storagePath, backup.ID)
 
+	fail := func(err error) error {
+		m.updateBackupStatus(backup.ID, models.BackupStatusFailed)
+		m.tasks.Finish(taskID, err)
+		return err
+	}
+
 	// Create deployments backup
-	for _, deploymentID := range backup.DeploymentIDs {
-		if err := m.backupDeployment(deploymentID, backupDir); err != nil {
-			m.updateBackupStatus(backup.ID, models.BackupStatusFailed)
-			return
+	volumeCount := 0
+	for i, deploymentID := range backup.DeploymentIDs {
+		m.tasks.Progress(taskID, i*80/len(backup.DeploymentIDs), fmt.Sprintf("Backing up deployment %s", deploymentID))
+		volumes, err := m.backupDeployment(deploymentID, backupDir, backup.IncludeVolumes)
+		if err != nil {
+			return fail(fmt.Errorf("failed to back up deployment %s: %w", deploymentID, err))
 		}
+		volumeCount += volumes
 	}
 
 	// Create metadata file
@@ -149,58 +243,117 @@ func (m *Manager) performBackup(backup *models.Backup, config *models.BackupConf
 		CreatedAt:       backup.CreatedAt,
 		AppVersion:      "1.0.0",
 		DeploymentCount: len(backup.DeploymentIDs),
-		VolumeCount:     0,
+		VolumeCount:     volumeCount,
+		Extra:           config.Metadata,
 	}
 
 	if err := m.saveMetadata(backupDir, metadata); err != nil {
-		m.updateBackupStatus(backup.ID, models.BackupStatusFailed)
-		return
+		return fail(fmt.Errorf("failed to save metadata: %w", err))
 	}
 
 	// Create archive
+	m.tasks.Progress(taskID, 80, "Creating archive")
 	archivePath := filepath.Join(m.storagePath, backup.ID+".tar.gz")
 	size, err := m.createArchive(backupDir, archivePath)
 	if err != nil {
-		m.updateBackupStatus(backup.ID, models.BackupStatusFailed)
-		return
+		return fail(fmt.Errorf("failed to create archive: %w", err))
 	}
 
-	// Update backup record
+	if backup.Encrypted {
+		m.tasks.Progress(taskID, 90, "Encrypting archive")
+		archivePath, size, err = m.encryptArchive(backup, archivePath, config.Passphrase)
+		if err != nil {
+			return fail(fmt.Errorf("failed to encrypt archive: %w", err))
+		}
+	}
+
+	// Clean up temporary directory
+	os.RemoveAll(backupDir)
+
+	// Update backup record. The backup only counts as completed once its archive
+	// is written and recorded
 	backup.StoragePath = archivePath
 	backup.SizeBytes = size
+	backup.Status = models.BackupStatusCompleted
 	now := time.Now()
 	backup.CompletedAt = &now
 
-	m.updateBackupRecord(backup)
+	if err := m.updateBackupRecord(backup); err != nil {
+		backup.Status = models.BackupStatusFailed
+		return fail(fmt.Errorf("failed to record backup: %w", err))
+	}
+	m.tasks.Finish(taskID, nil)
 
-	// Clean up temporary directory
-	os.RemoveAll(backupDir)
+	m.webhooks.Publish(models.WebhookEventBackupCompleted, map[string]interface{}{
+		"backup_id":      backup.ID,
+		"name":           backup.Name,
+		"deployment_ids": backup.DeploymentIDs,
+		"size_bytes":     backup.SizeBytes,
+	})
+	return nil
 }
 
 // performRestore executes the restore process
-func (m *Manager) performRestore(backup *models.Backup, config *models.RestoreConfig) {
+func (m *Manager) performRestore(taskID string, backup *models.Backup, config *models.RestoreConfig) {
 	restoreDir := filepath.Join(m.storagePath, "restore", backup.ID)
 	defer os.RemoveAll(restoreDir)
 
 	// Extract archive
-	if err := m.extractArchive(backup.StoragePath, restoreDir); err != nil {
+	m.tasks.Progress(taskID, 0, "Extracting archive")
+	if err := m.extractArchive(backup, config.Passphrase, restoreDir); err != nil {
+		m.tasks.Finish(taskID, fmt.Errorf("failed to extract archive: %w", err))
 		return
 	}
 
-	// Restore deployments
-	for _, deploymentID := range backup.DeploymentIDs {
+	m.tasks.Finish(taskID, m.restoreDeployments(taskID, restoreDir, backup.DeploymentIDs, 20, config))
+}
+
+// restoreDeployments restores the deployments of an extracted archive, reporting
+// progress from startProgress on
+func (m *Manager) restoreDeployments(taskID, restoreDir string, deploymentIDs []string, startProgress int, config *models.RestoreConfig) error {
+	for i, deploymentID := range deploymentIDs {
 		if config.Selective && !config.HasDeployment(deploymentID) {
 			continue
 		}
+		if config.TestRestore {
+			continue
+		}
+
+		progress := startProgress + i*(100-startProgress)/len(deploymentIDs)
+		info, err := m.loadDeploymentInfo(restoreDir, deploymentID)
+		if err != nil {
+			return fmt.Errorf("failed to read deployment %s: %w", deploymentID, err)
+		}
+		if targetStack, renamed := config.TargetStack(info.StackName); renamed {
+			m.tasks.Progress(taskID, progress, fmt.Sprintf("Restoring %s as %s", info.StackName, targetStack))
+			if _, err := m.restoreAs(restoreDir, info, targetStack, config); err != nil {
+				return fmt.Errorf("failed to restore %s as %s: %w", info.StackName, targetStack, err)
+			}
+			continue
+		}
 
-		if !config.TestRestore {
-			m.restoreDeployment(deploymentID, restoreDir)
+		if config.OverwriteExisting && m.beforeOverwrite != nil {
+			var exists bool
+			m.db.QueryRow("SELECT EXISTS(SELECT 1 FROM deployments WHERE id = $1)", deploymentID).Scan(&exists)
+			if exists {
+				if err := m.beforeOverwrite(deploymentID); err != nil {
+					return err
+				}
+			}
 		}
+
+		m.tasks.Progress(taskID, progress, fmt.Sprintf("Restoring deployment %s", deploymentID))
+		m.restoreDeployment(deploymentID, restoreDir)
 	}
+
+	return nil
 }
 
-// backupDeployment backs up a single deployment
-func (m *Manager) backupDeployment(deploymentID, backupDir string) error {
+// backupDeployment backs up a single deployment: its record, the compose and .env
+// files of its project directory and, if includeVolumes, its data: the dumps of
+// the backup hooks its template declares and the files of its named volumes
+// that no dump covers. It returns the number of volumes backed up
+func (m *Manager) backupDeployment(deploymentID, backupDir string, includeVolumes bool) (int, error) {
 	// Get deployment info
 	var stackName, templateID, configJSON string
 	err := m.db.QueryRow(`
@@ -209,12 +362,12 @@ func (m *Manager) backupDeployment(deploymentID, backupDir string) error {
 		deploymentID).Scan(&stackName, &templateID, &configJSON)
 
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	deploymentDir := filepath.Join(backupDir, "deployments", deploymentID)
-	if err := os.MkdirAll(deploymentDir, 0755); err != nil {
-		return err
+	if err := os.MkdirAll(filepath.Join(deploymentDir, "files"), 0755); err != nil {
+		return 0, err
 	}
 
 	// Save deployment info
@@ -225,7 +378,72 @@ func (m *Manager) backupDeployment(deploymentID, backupDir string) error {
 		"config":      configJSON,
 	}
 
-	return m.saveJSON(filepath.Join(deploymentDir, "deployment.json"), deploymentInfo)
+	if err := m.saveJSON(filepath.Join(deploymentDir, "deployment.json"), deploymentInfo); err != nil {
+		return 0, err
+	}
+
+	// Copy the project files the stack was deployed from
+	projectDir := filepath.Join(m.deploymentsDir, stackName)
+	for _, name := range deploymentFiles {
+		src := filepath.Join(projectDir, name)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyFile(src, filepath.Join(deploymentDir, "files", name)); err != nil {
+			return 0, fmt.Errorf("failed to copy %s: %w", name, err)
+		}
+	}
+
+	if !includeVolumes {
+		return 0, nil
+	}
+
+	hooks, err := m.templateBackupHooks(templateID)
+	if err != nil {
+		return 0, err
+	}
+	covered, err := m.runDumpHooks(stackName, deploymentDir, hooks)
+	if err != nil {
+		return 0, err
+	}
+	return m.backupVolumes(stackName, deploymentDir, covered)
+}
+
+// backupVolumes copies the data of the named volumes of a compose project,
+// skipping the compose volumes in skip. Volume mount points must be readable by
+// this process
+func (m *Manager) backupVolumes(stackName, deploymentDir string, skip map[string]bool) (int, error) {
+	list, err := m.dockerClient.VolumeList(context.Background(), volume.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", "com.docker.compose.project="+stackName)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	var volumes []models.VolumeBackup
+	for _, vol := range list.Volumes {
+		if skip[vol.Labels["com.docker.compose.volume"]] {
+			continue
+		}
+		dataPath := filepath.Join("volumes", vol.Name)
+		size, err := copyDir(vol.Mountpoint, filepath.Join(deploymentDir, dataPath))
+		if err != nil {
+			return 0, fmt.Errorf("failed to copy volume %s: %w", vol.Name, err)
+		}
+
+		volumes = append(volumes, models.VolumeBackup{
+			Name:       vol.Name,
+			Driver:     vol.Driver,
+			MountPoint: vol.Mountpoint,
+			DataPath:   dataPath,
+			SizeBytes:  size,
+		})
+	}
+
+	if err := m.saveJSON(filepath.Join(deploymentDir, "volumes.json"), volumes); err != nil {
+		return 0, err
+	}
+	return len(volumes), nil
 }
 
 // restoreDeployment restores a single deployment
@@ -241,6 +459,7 @@ func (m *Manager) restoreDeployment(deploymentID, restoreDir string) error {
 	// 1. Create new deployment record
 	// 2. Restore docker-compose files
 	// 3. Deploy stack
+	// 4. Load the dumps of backup hooks with runRestoreHooks once it is up
 
 	return nil
 }
@@ -298,8 +517,19 @@ func (m *Manager) createArchive(sourceDir, archivePath string) (int64, error) {
 		return 0, err
 	}
 
-	// Get file size
-	stat, err := file.Stat()
+	// Flush the archive before it is measured: an archive that failed to write
+	// must fail the backup rather than be recorded truncated
+	if err := tarWriter.Close(); err != nil {
+		return 0, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, err
+	}
+
+	stat, err := os.Stat(archivePath)
 	if err != nil {
 		return 0, err
 	}
@@ -307,15 +537,119 @@ func (m *Manager) createArchive(sourceDir, archivePath string) (int64, error) {
 	return stat.Size(), nil
 }
 
-// extractArchive extracts a compressed archive
-func (m *Manager) extractArchive(archivePath, destDir string) error {
-	file, err := os.Open(archivePath)
+// extractArchive extracts the archive of a backup, decrypting it if needed
+func (m *Manager) extractArchive(backup *models.Backup, passphrase, destDir string) error {
+	archive, err := m.openArchive(backup, passphrase)
 	if err != nil {
 		return err
 	}
+	defer archive.Close()
+
+	return m.extractStream(archive, destDir)
+}
+
+// openArchive opens the archive of a backup, decrypting it with the key from its
+// key storage, or with passphrase for passphrase-only backups
+func (m *Manager) openArchive(backup *models.Backup, passphrase string) (io.ReadCloser, error) {
+	file, err := os.Open(backup.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+	if !backup.Encrypted {
+		return file, nil
+	}
+
+	var decrypted io.Reader
+	if backup.KeyStorage == KeyStoragePassphrase {
+		decrypted, err = NewPassphraseDecryptedReader(file, m.passphrase(passphrase))
+	} else {
+		var key []byte
+		key, err = m.backupKey(backup)
+		if err == nil {
+			decrypted, err = NewDecryptedReader(file, key)
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{decrypted, file}, nil
+}
+
+// backupKey recovers the key of a backup from the key storage it was encrypted with
+func (m *Manager) backupKey(backup *models.Backup) ([]byte, error) {
+	store, err := NewKeyStore(backup.KeyStorage, m.encryption)
+	if err != nil {
+		return nil, err
+	}
+	return store.Key(context.Background(), backup.ID, backup.KeyMetadata)
+}
+
+// encryptArchive encrypts the archive of a backup with a new key from the
+// configured key storage, or with a key derived from passphrase, and replaces
+// the plain archive. It returns the path and size of the encrypted archive
+func (m *Manager) encryptArchive(backup *models.Backup, archivePath, passphrase string) (string, int64, error) {
+	plain, err := os.Open(archivePath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(archivePath)
+	defer plain.Close()
+
+	var encrypted io.Reader
+	storage, metadata := m.encryption.KeyStorage, ""
+	if storage == KeyStoragePassphrase {
+		encrypted, err = NewPassphraseEncryptedReader(plain, m.passphrase(passphrase))
+	} else {
+		var store KeyStore
+		var key []byte
+		if store, err = NewKeyStore(storage, m.encryption); err != nil {
+			return "", 0, err
+		}
+		if key, metadata, err = store.NewKey(context.Background(), backup.ID); err != nil {
+			return "", 0, err
+		}
+		encrypted, err = NewEncryptedReader(plain, key)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	encryptedPath := archivePath + ".enc"
+	file, err := os.Create(encryptedPath)
+	if err != nil {
+		return "", 0, err
+	}
 	defer file.Close()
 
-	gzipReader, err := gzip.NewReader(file)
+	size, err := io.Copy(file, encrypted)
+	if err != nil {
+		file.Close()
+		os.Remove(encryptedPath)
+		return "", 0, err
+	}
+
+	backup.KeyStorage = storage
+	backup.KeyMetadata = metadata
+	return encryptedPath, size, nil
+}
+
+// passphrase returns the passphrase given with a backup or restore, or the
+// configured one
+func (m *Manager) passphrase(given string) string {
+	if given != "" {
+		return given
+	}
+	return m.encryption.Passphrase
+}
+
+// extractStream extracts a compressed archive read from r
+func (m *Manager) extractStream(r io.Reader, destDir string) error {
+	gzipReader, err := gzip.NewReader(r)
 	if err != nil {
 		return err
 	}
@@ -332,7 +666,11 @@ func (m *Manager) extractArchive(archivePath, destDir string) error {
 			return err
 		}
 
+		// Archives may come from outside; never write beyond destDir
 		path := filepath.Join(destDir, header.Name)
+		if path != filepath.Clean(destDir) && !strings.HasPrefix(path, filepath.Clean(destDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("archive entry %q is outside the archive root", header.Name)
+		}
 
 		switch header.Typeflag {
 		case tar.TypeDir:
@@ -360,6 +698,50 @@ func (m *Manager) extractArchive(archivePath, destDir string) error {
 	return nil
 }
 
+// StreamDeployment writes an uncompressed tar of a single deployment's entries of
+// the archive, with the backup metadata, to w without unpacking the archive to disk
+func (m *Manager) StreamDeployment(archivePath, deploymentID string, w io.Writer) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	tarWriter := tar.NewWriter(w)
+
+	prefix := filepath.ToSlash(filepath.Join("deployments", deploymentID))
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(header.Name)
+		if name != "metadata.json" && name != prefix && !strings.HasPrefix(name, prefix+"/") {
+			continue
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tarWriter, tarReader); err != nil {
+			return err
+		}
+	}
+
+	return tarWriter.Close()
+}
+
 // Helper functions
 func (m *Manager) saveBackupRecord(backup *models.Backup) error {
 	deploymentIDsJSON, _ := backup.MarshalDeploymentIDs()
@@ -377,10 +759,12 @@ func (m *Manager) updateBackupRecord(backup *models.Backup) error {
 	deploymentIDsJSON, _ := backup.MarshalDeploymentIDs()
 	_, err := m.db.Exec(`
 		UPDATE backups SET status = $1, size_bytes = $2, storage_path = $3, 
-		                   deployment_ids = $4, completed_at = $5
-		WHERE id = $6`,
+		                   deployment_ids = $4, completed_at = $5, key_storage = $6,
+		                   key_metadata = $7
+		WHERE id = $8`,
 		backup.Status, backup.SizeBytes, backup.StoragePath,
-		deploymentIDsJSON, backup.CompletedAt, backup.ID)
+		deploymentIDsJSON, backup.CompletedAt, backup.KeyStorage,
+		backup.KeyMetadata, backup.ID)
 	return err
 }
 
@@ -397,7 +781,8 @@ func (m *Manager) updateBackupStatus(backupID string, status models.BackupStatus
 func (m *Manager) getBackup(backupID string) (*models.Backup, error) {
 	query := `
 		SELECT id, name, type, status, size_bytes, include_volumes, encrypted,
-		       storage_path, deployment_ids, created_at, completed_at
+		       storage_path, deployment_ids, created_at, completed_at,
+		       COALESCE(key_storage, ''), COALESCE(key_metadata, '')
 		FROM backups WHERE id = $1`
 
 	row := m.db.QueryRow(query, backupID)
@@ -414,7 +799,8 @@ func (m *Manager) scanBackup(scanner interface {
 	err := scanner.Scan(
 		&backup.ID, &backup.Name, &backup.Type, &backup.Status, &backup.SizeBytes,
 		&backup.IncludeVolumes, &backup.Encrypted, &backup.StoragePath,
-		&deploymentIDsJSON, &backup.CreatedAt, &completedAt)
+		&deploymentIDsJSON, &backup.CreatedAt, &completedAt,
+		&backup.KeyStorage, &backup.KeyMetadata)
 
 	if err != nil {
 		return nil, err
@@ -453,7 +839,7 @@ func (m *Manager) saveMetadata(backupDir string, metadata *models.BackupMetadata
 }
 
 func generateBackupID() string {
-	return fmt.Sprintf("backup_%d", time.Now().Unix())
+	return fmt.Sprintf("backup_%d", time.Now().UnixNano())
 }
 
 func getDeploymentIDsFromConfig(config *models.BackupConfig) []string {
@@ -462,4 +848,54 @@ func getDeploymentIDsFromConfig(config *models.BackupConfig) []string {
 		deploymentIDs = append(deploymentIDs, deployment.ID)
 	}
 	return deploymentIDs
-}
\ No newline at end of file
+}
+
+// copyFile copies a regular file, keeping its permissions
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	dest, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	_, err = io.Copy(dest, source)
+	return err
+}
+
+// copyDir recursively copies the directories and regular files of src to dst and
+// returns the number of bytes copied. Symlinks and special files are skipped
+func copyDir(src, dst string) (int64, error) {
+	var size int64
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relPath)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode().IsRegular():
+			size += info.Size()
+			return copyFile(path, target)
+		}
+		return nil
+	})
+	return size, err
+}
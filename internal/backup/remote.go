@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"docker-deploy-app/internal/models"
+)
+
+// remoteClient fetches archives for remote restores. Archives can be tens of GB, so
+// only the wait for the response headers is bounded
+var remoteClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
+
+// RestoreFromURL restores an archive streamed from a URL in the background and
+// returns the ID of the task tracking it. The archive is extracted as it arrives
+// rather than stored first
+func (m *Manager) RestoreFromURL(config *models.RemoteRestoreConfig) (string, error) {
+	if err := config.Validate(); err != nil {
+		return "", err
+	}
+
+	taskID := m.tasks.Start(models.TaskTypeRestore, "", "Restoring backup from URL")
+	go m.performRemoteRestore(taskID, config)
+
+	return taskID, nil
+}
+
+func (m *Manager) performRemoteRestore(taskID string, config *models.RemoteRestoreConfig) {
+	restoreDir := filepath.Join(m.storagePath, "restore", "remote-"+taskID)
+	defer os.RemoveAll(restoreDir)
+
+	m.tasks.Progress(taskID, 0, "Downloading archive")
+	resp, err := remoteClient.Get(config.URL)
+	if err != nil {
+		m.tasks.Finish(taskID, fmt.Errorf("failed to download archive: %w", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		m.tasks.Finish(taskID, fmt.Errorf("failed to download archive: %s", resp.Status))
+		return
+	}
+
+	// Download and extraction share the first 70% of the progress
+	hash := sha256.New()
+	body := io.TeeReader(&progressReader{
+		reader: resp.Body,
+		total:  resp.ContentLength,
+		report: func(percent int, read int64) {
+			m.tasks.Progress(taskID, percent*70/100, fmt.Sprintf("Extracting archive (%d MB)", read/(1024*1024)))
+		},
+	}, hash)
+
+	if err := m.extractStream(body, restoreDir); err != nil {
+		m.tasks.Finish(taskID, fmt.Errorf("failed to extract archive: %w", err))
+		return
+	}
+
+	// The checksum covers the whole archive, including anything after the tar end
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		m.tasks.Finish(taskID, fmt.Errorf("failed to download archive: %w", err))
+		return
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if config.Checksum != "" && !strings.EqualFold(checksum, config.Checksum) {
+		m.tasks.Finish(taskID, fmt.Errorf("checksum mismatch: expected %s, got %s", config.Checksum, checksum))
+		return
+	}
+
+	entries, err := os.ReadDir(filepath.Join(restoreDir, "deployments"))
+	if err != nil {
+		m.tasks.Finish(taskID, fmt.Errorf("archive contains no deployments: %w", err))
+		return
+	}
+	var deploymentIDs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			deploymentIDs = append(deploymentIDs, entry.Name())
+		}
+	}
+
+	m.tasks.Finish(taskID, m.restoreDeployments(taskID, restoreDir, deploymentIDs, 70, &config.RestoreConfig))
+}
+
+// progressReader reports whole-percent progress of reading a stream of known
+// length. When the length is unknown it reports every 64 MB at 0%
+type progressReader struct {
+	reader   io.Reader
+	total    int64
+	read     int64
+	reported int64
+	report   func(percent int, read int64)
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	p.read += int64(n)
+
+	if p.total > 0 {
+		if percent := p.read * 100 / p.total; percent > p.reported {
+			p.reported = percent
+			p.report(int(percent), p.read)
+		}
+	} else if p.read-p.reported >= 64*1024*1024 {
+		p.reported = p.read
+		p.report(0, p.read)
+	}
+
+	return n, err
+}
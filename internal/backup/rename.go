@@ -0,0 +1,164 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"docker-deploy-app/internal/models"
+)
+
+// deploymentInfo is the deployment record saved in a backup archive
+type deploymentInfo struct {
+	ID         string `json:"id"`
+	StackName  string `json:"stack_name"`
+	TemplateID string `json:"template_id"`
+	Config     string `json:"config"` // JSON, with the keys of a deployment request
+}
+
+// loadDeploymentInfo reads the record of a deployment of an extracted archive
+func (m *Manager) loadDeploymentInfo(restoreDir, deploymentID string) (*deploymentInfo, error) {
+	var info deploymentInfo
+	if err := m.loadJSON(filepath.Join(restoreDir, "deployments", deploymentID, "deployment.json"), &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// restoreAs restores a deployment of an extracted archive under a new stack name,
+// alongside the original. Its project files go to the new stack's directory with
+// host ports still in use moved to free ones and, with RestoreVolumes, its named
+// volumes are recreated under the new stack name with their data. The copy is
+// recorded as a new deployment and brought up when a compose manager is set,
+// after which the dumps of backup hooks are loaded into it. Volumes given a fixed name in the compose file are not renamed by compose and
+// stay shared with the original. It returns the ID of the new deployment
+func (m *Manager) restoreAs(restoreDir string, info *deploymentInfo, targetStack string, config *models.RestoreConfig) (string, error) {
+	var taken bool
+	m.db.QueryRow("SELECT EXISTS(SELECT 1 FROM deployments WHERE stack_name = $1)", targetStack).Scan(&taken)
+	projectDir := filepath.Join(m.deploymentsDir, targetStack)
+	if _, err := os.Stat(projectDir); err == nil {
+		taken = true
+	}
+	if taken {
+		return "", fmt.Errorf("%w: %s", models.ErrStackNameTaken, targetStack)
+	}
+
+	// The stored configuration names the original stack
+	deploymentConfig := map[string]interface{}{}
+	var env struct {
+		Environment map[string]string `json:"environment"`
+	}
+	if info.Config != "" {
+		if err := json.Unmarshal([]byte(info.Config), &deploymentConfig); err != nil {
+			return "", fmt.Errorf("invalid deployment configuration: %w", err)
+		}
+		json.Unmarshal([]byte(info.Config), &env)
+	}
+	deploymentConfig["stack_name"] = targetStack
+	configJSON, err := json.Marshal(deploymentConfig)
+	if err != nil {
+		return "", err
+	}
+
+	deploymentDir := filepath.Join(restoreDir, "deployments", info.ID)
+	if _, err := copyDir(filepath.Join(deploymentDir, "files"), projectDir); err != nil {
+		os.RemoveAll(projectDir)
+		return "", fmt.Errorf("failed to restore project files: %w", err)
+	}
+
+	conflicts, err := m.remapPorts(projectDir, targetStack, env.Environment)
+	if err != nil {
+		os.RemoveAll(projectDir)
+		return "", fmt.Errorf("failed to remap ports: %w", err)
+	}
+
+	ctx := context.Background()
+	var volumes []string
+	if config.RestoreVolumes {
+		var backedUp []models.VolumeBackup
+		if err := m.loadJSON(filepath.Join(deploymentDir, "volumes.json"), &backedUp); err != nil && !os.IsNotExist(err) {
+			os.RemoveAll(projectDir)
+			return "", fmt.Errorf("failed to read volumes: %w", err)
+		}
+		for _, vol := range backedUp {
+			name, err := m.cloneVolume(ctx, vol, info.StackName, targetStack, filepath.Join(deploymentDir, vol.DataPath))
+			if err != nil {
+				m.removeVolumes(ctx, volumes)
+				os.RemoveAll(projectDir)
+				return "", fmt.Errorf("failed to restore volume %s: %w", vol.Name, err)
+			}
+			volumes = append(volumes, name)
+		}
+	}
+
+	// The copy joins the project of the original while it still exists
+	projectID := "global"
+	m.db.QueryRow("SELECT COALESCE(project_id, 'global') FROM deployments WHERE id = $1", info.ID).Scan(&projectID)
+
+	deploymentID := fmt.Sprintf("deploy_%d", time.Now().UnixNano())
+	now := time.Now()
+	_, err = m.db.Exec(`
+		INSERT INTO deployments (id, template_id, stack_name, project_id, status, config, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		deploymentID, info.TemplateID, targetStack, projectID, models.StatusStopped, string(configJSON), now, now)
+	if err != nil {
+		m.removeVolumes(ctx, volumes)
+		os.RemoveAll(projectDir)
+		return "", fmt.Errorf("failed to save deployment: %w", err)
+	}
+
+	slog.Info("Restored deployment under a new stack name", "source", info.StackName, "stack", targetStack,
+		"deployment_id", deploymentID, "volumes", len(volumes), "remapped_ports", len(conflicts))
+
+	if m.compose == nil {
+		return deploymentID, nil
+	}
+	status := models.StatusRunning
+	err = m.compose.Up(targetStack)
+	if err != nil {
+		status = models.StatusFailed
+	}
+	m.db.Exec("UPDATE deployments SET status = $1, updated_at = $2 WHERE id = $3", status, time.Now(), deploymentID)
+	if err != nil {
+		return deploymentID, fmt.Errorf("failed to start %s: %w", targetStack, err)
+	}
+
+	// Dumped services start with empty volumes and are loaded from their dumps
+	if config.RestoreVolumes {
+		if err := m.runRestoreHooks(targetStack, deploymentDir); err != nil {
+			return deploymentID, err
+		}
+	}
+	return deploymentID, nil
+}
+
+// remapPorts moves host ports of a restored compose file that are in use, such as
+// those of the original stack, to free ones
+func (m *Manager) remapPorts(projectDir, stackName string, env map[string]string) ([]models.PortConflict, error) {
+	for _, name := range []string{"docker-compose.yml", "docker-compose.yaml"} {
+		path := filepath.Join(projectDir, name)
+		content, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		updated, conflicts, err := m.ports.Check(stackName, content, env, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(conflicts) > 0 {
+			if err := os.WriteFile(path, updated, 0644); err != nil {
+				return nil, err
+			}
+		}
+		return conflicts, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+)
+
+// SafetyBackups takes an automatic backup of a deployment before an operation that
+// may destroy its data, such as an upgrade, a redeploy or an overwriting restore.
+// Only the most recent safety backups of each deployment are kept
+type SafetyBackups struct {
+	manager *Manager
+	config  config.SafetyBackupConfig
+}
+
+// NewSafetyBackups creates a new safety backup taker
+func NewSafetyBackups(manager *Manager, cfg config.SafetyBackupConfig) *SafetyBackups {
+	return &SafetyBackups{manager: manager, config: cfg}
+}
+
+// Before backs up a deployment ahead of operation and waits for the backup to
+// complete. It returns nil without a backup when safety backups are disabled. Callers
+// should abort the operation when an error is returned
+func (sb *SafetyBackups) Before(deploymentID, operation string) (*models.Backup, error) {
+	if sb == nil || !sb.config.Enabled {
+		return nil, nil
+	}
+
+	var stackName string
+	if err := sb.manager.db.QueryRow("SELECT stack_name FROM deployments WHERE id = $1", deploymentID).Scan(&stackName); err != nil {
+		return nil, fmt.Errorf("failed to get deployment: %w", err)
+	}
+
+	backup, err := sb.manager.createBackupNow(&models.BackupConfig{
+		Name:           fmt.Sprintf("%s-pre-%s-%s", stackName, operation, time.Now().Format("20060102-150405")),
+		Type:           models.BackupTypeAuto,
+		IncludeVolumes: sb.config.IncludeVolumes,
+		Deployments:    []models.DeploymentBackup{{ID: deploymentID, StackName: stackName}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("safety backup before %s failed: %w", operation, err)
+	}
+	slog.Info("Created safety backup", "stack", stackName, "operation", operation, "backup_id", backup.ID)
+
+	if err := sb.prune(deploymentID); err != nil {
+		slog.Warn("Failed to prune safety backups", "stack", stackName, "error", err)
+	}
+	return backup, nil
+}
+
+// BeforeUpgrade backs up a deployment ahead of an image upgrade. It matches the
+// hook of docker.AutoUpdater.SetBeforeUpgrade
+func (sb *SafetyBackups) BeforeUpgrade(deployment *models.Deployment) error {
+	_, err := sb.Before(deployment.ID, "upgrade")
+	return err
+}
+
+// prune deletes the safety backups of a deployment beyond the newest Keep and those
+// older than MaxAgeDays. The newest safety backup is always kept
+func (sb *SafetyBackups) prune(deploymentID string) error {
+	rows, err := sb.manager.db.Query(`
+		SELECT id, created_at FROM backups
+		WHERE type = $1 AND status != $2
+		  AND EXISTS (SELECT 1 FROM json_each(backups.deployment_ids) WHERE value = $3)
+		ORDER BY created_at DESC`,
+		models.BackupTypeAuto, models.BackupStatusCreating, deploymentID)
+	if err != nil {
+		return err
+	}
+
+	var expired []string
+	cutoff := time.Now().AddDate(0, 0, -sb.config.MaxAgeDays)
+	for i := 0; rows.Next(); i++ {
+		var id string
+		var createdAt time.Time
+		if err := rows.Scan(&id, &createdAt); err != nil {
+			rows.Close()
+			return err
+		}
+
+		if i == 0 {
+			continue
+		}
+		if (sb.config.Keep > 0 && i >= sb.config.Keep) || (sb.config.MaxAgeDays > 0 && createdAt.Before(cutoff)) {
+			expired = append(expired, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range expired {
+		if err := sb.manager.DeleteBackup(id); err != nil {
+			return fmt.Errorf("failed to delete backup %s: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -1,12 +1,16 @@
 package backup
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
 	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/robfig/cron/v3"
+	"docker-deploy-app/internal/maintenance"
 	"docker-deploy-app/internal/models"
 )
 
@@ -16,6 +20,8 @@ type Scheduler struct {
 	manager *Manager
 	cron    *cron.Cron
 	jobs    map[int]cron.EntryID
+	running bool
+	mu      sync.Mutex // Guards jobs, changed by API requests and on start
 }
 
 // NewScheduler creates a new backup scheduler
@@ -36,29 +42,50 @@ func (s *Scheduler) Start() error {
 	}
 
 	s.cron.Start()
-	log.Println("Backup scheduler started")
+	s.mu.Lock()
+	s.running = true
+	s.mu.Unlock()
+	slog.Info("Backup scheduler started")
 	return nil
 }
 
 // Stop stops the scheduler
 func (s *Scheduler) Stop() {
 	s.cron.Stop()
-	log.Println("Backup scheduler stopped")
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+	slog.Info("Backup scheduler stopped")
+}
+
+// Running reports whether the scheduler has been started and not stopped
+func (s *Scheduler) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
 }
 
 // AddSchedule adds a new backup schedule
 func (s *Scheduler) AddSchedule(schedule *models.BackupSchedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+
 	// Calculate next run time
 	if err := schedule.UpdateNextRun(); err != nil {
 		return err
 	}
+	selectorJSON, err := schedule.MarshalSelector()
+	if err != nil {
+		return err
+	}
 
 	// Save to database
 	result, err := s.db.Exec(`
-		INSERT INTO backup_schedules (name, cron_expression, include_volumes, encrypt, enabled, next_run, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		schedule.Name, schedule.CronExpression, schedule.IncludeVolumes,
-		schedule.Encrypt, schedule.Enabled, schedule.NextRun, schedule.CreatedAt)
+		INSERT INTO backup_schedules (name, cron_expression, timezone, include_volumes, encrypt, enabled, selector, next_run, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		schedule.Name, schedule.CronExpression, schedule.Timezone, schedule.IncludeVolumes,
+		schedule.Encrypt, schedule.Enabled, selectorJSON, schedule.NextRun, schedule.CreatedAt)
 
 	if err != nil {
 		return err
@@ -81,25 +108,30 @@ func (s *Scheduler) AddSchedule(schedule *models.BackupSchedule) error {
 
 // UpdateSchedule updates an existing schedule
 func (s *Scheduler) UpdateSchedule(schedule *models.BackupSchedule) error {
-	// Remove existing cron job
-	if entryID, exists := s.jobs[schedule.ID]; exists {
-		s.cron.Remove(entryID)
-		delete(s.jobs, schedule.ID)
+	if err := schedule.Validate(); err != nil {
+		return err
 	}
 
+	// Remove existing cron job
+	s.removeCronJob(schedule.ID)
+
 	// Update next run time
 	if err := schedule.UpdateNextRun(); err != nil {
 		return err
 	}
+	selectorJSON, err := schedule.MarshalSelector()
+	if err != nil {
+		return err
+	}
 
 	// Update database
-	_, err := s.db.Exec(`
+	_, err = s.db.Exec(`
 		UPDATE backup_schedules 
-		SET name = $1, cron_expression = $2, include_volumes = $3, encrypt = $4, 
-		    enabled = $5, next_run = $6
-		WHERE id = $7`,
-		schedule.Name, schedule.CronExpression, schedule.IncludeVolumes,
-		schedule.Encrypt, schedule.Enabled, schedule.NextRun, schedule.ID)
+		SET name = $1, cron_expression = $2, timezone = $3, include_volumes = $4, encrypt = $5, 
+		    enabled = $6, selector = $7, next_run = $8
+		WHERE id = $9`,
+		schedule.Name, schedule.CronExpression, schedule.Timezone, schedule.IncludeVolumes,
+		schedule.Encrypt, schedule.Enabled, selectorJSON, schedule.NextRun, schedule.ID)
 
 	if err != nil {
 		return err
@@ -116,10 +148,7 @@ func (s *Scheduler) UpdateSchedule(schedule *models.BackupSchedule) error {
 // RemoveSchedule removes a backup schedule
 func (s *Scheduler) RemoveSchedule(scheduleID int) error {
 	// Remove cron job
-	if entryID, exists := s.jobs[scheduleID]; exists {
-		s.cron.Remove(entryID)
-		delete(s.jobs, scheduleID)
-	}
+	s.removeCronJob(scheduleID)
 
 	// Remove from database
 	_, err := s.db.Exec("DELETE FROM backup_schedules WHERE id = $1", scheduleID)
@@ -129,8 +158,8 @@ func (s *Scheduler) RemoveSchedule(scheduleID int) error {
 // GetSchedules returns all backup schedules
 func (s *Scheduler) GetSchedules() ([]*models.BackupSchedule, error) {
 	query := `
-		SELECT id, name, cron_expression, include_volumes, encrypt, enabled,
-		       last_run, next_run, created_at
+		SELECT id, name, cron_expression, timezone, include_volumes, encrypt, enabled,
+		       selector, last_run, next_run, created_at
 		FROM backup_schedules ORDER BY created_at DESC`
 
 	rows, err := s.db.Query(query)
@@ -151,6 +180,16 @@ func (s *Scheduler) GetSchedules() ([]*models.BackupSchedule, error) {
 	return schedules, nil
 }
 
+// GetSchedule returns a single backup schedule
+func (s *Scheduler) GetSchedule(scheduleID int) (*models.BackupSchedule, error) {
+	row := s.db.QueryRow(`
+		SELECT id, name, cron_expression, timezone, include_volumes, encrypt, enabled,
+		       selector, last_run, next_run, created_at
+		FROM backup_schedules WHERE id = $1`, scheduleID)
+
+	return s.scanSchedule(row)
+}
+
 // loadSchedules loads all schedules from database and adds them to cron
 func (s *Scheduler) loadSchedules() error {
 	schedules, err := s.GetSchedules()
@@ -161,7 +200,7 @@ func (s *Scheduler) loadSchedules() error {
 	for _, schedule := range schedules {
 		if schedule.Enabled {
 			if err := s.addCronJob(schedule); err != nil {
-				log.Printf("Failed to add cron job for schedule %d: %v", schedule.ID, err)
+				slog.Error("Failed to add cron job", "schedule_id", schedule.ID, "error", err)
 			}
 		}
 	}
@@ -171,7 +210,7 @@ func (s *Scheduler) loadSchedules() error {
 
 // addCronJob adds a schedule to the cron scheduler
 func (s *Scheduler) addCronJob(schedule *models.BackupSchedule) error {
-	entryID, err := s.cron.AddFunc(schedule.CronExpression, func() {
+	entryID, err := s.cron.AddFunc(schedule.CronSpec(), func() {
 		s.executeScheduledBackup(schedule)
 	})
 
@@ -179,23 +218,41 @@ func (s *Scheduler) addCronJob(schedule *models.BackupSchedule) error {
 		return err
 	}
 
+	s.mu.Lock()
 	s.jobs[schedule.ID] = entryID
+	s.mu.Unlock()
 	return nil
 }
 
+// removeCronJob removes a schedule from the cron scheduler if registered
+func (s *Scheduler) removeCronJob(scheduleID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entryID, exists := s.jobs[scheduleID]; exists {
+		s.cron.Remove(entryID)
+		delete(s.jobs, scheduleID)
+	}
+}
+
 // executeScheduledBackup executes a scheduled backup
 func (s *Scheduler) executeScheduledBackup(schedule *models.BackupSchedule) {
-	log.Printf("Executing scheduled backup: %s", schedule.Name)
+	if maintenance.Active(s.db) {
+		slog.Info("Skipping scheduled backup during maintenance", "schedule", schedule.Name)
+		return
+	}
+
+	slog.Info("Executing scheduled backup", "schedule", schedule.Name)
 
-	// Get all active deployments
-	deploymentIDs, err := s.getActiveDeployments()
+	// Evaluate the selector against the deployments running now
+	deploymentIDs, err := s.selectDeployments(&schedule.Selector)
 	if err != nil {
-		log.Printf("Failed to get active deployments: %v", err)
+		slog.Error("Failed to select deployments", "schedule", schedule.Name, "error", err)
 		return
 	}
 
 	if len(deploymentIDs) == 0 {
-		log.Printf("No active deployments to backup")
+		slog.Info("No active deployments match the schedule", "schedule", schedule.Name)
 		return
 	}
 
@@ -206,19 +263,26 @@ func (s *Scheduler) executeScheduledBackup(schedule *models.BackupSchedule) {
 		IncludeVolumes: schedule.IncludeVolumes,
 		Encrypted:      schedule.Encrypt,
 		Deployments:    s.createDeploymentBackups(deploymentIDs),
+		Metadata: map[string]interface{}{
+			"schedule_id":         schedule.ID,
+			"selector":            schedule.Selector,
+			"matched_deployments": deploymentIDs,
+		},
 	}
 
 	// Create backup
-	backup, err := s.manager.CreateBackup(config)
+	backup, _, err := s.manager.CreateBackup(config)
 	if err != nil {
-		log.Printf("Failed to create scheduled backup: %v", err)
+		slog.Error("Failed to create scheduled backup", "error", err)
 		return
 	}
 
 	// Update schedule last run time
 	now := time.Now()
 	schedule.LastRun = &now
-	schedule.UpdateNextRun()
+	if err := schedule.UpdateNextRun(); err != nil {
+		slog.Error("Failed to calculate next run", "schedule", schedule.Name, "error", err)
+	}
 
 	s.db.Exec(`
 		UPDATE backup_schedules 
@@ -226,30 +290,98 @@ func (s *Scheduler) executeScheduledBackup(schedule *models.BackupSchedule) {
 		WHERE id = $3`,
 		schedule.LastRun, schedule.NextRun, schedule.ID)
 
-	log.Printf("Scheduled backup created: %s (ID: %s)", backup.Name, backup.ID)
+	slog.Info("Scheduled backup created", "name", backup.Name, "backup_id", backup.ID)
 }
 
-// getActiveDeployments returns all active deployment IDs
-func (s *Scheduler) getActiveDeployments() ([]string, error) {
-	query := "SELECT id FROM deployments WHERE status = 'running'"
+// selectDeployments returns the IDs of running deployments matched by the selector
+func (s *Scheduler) selectDeployments(selector *models.ScheduleSelector) ([]string, error) {
+	query := `
+		SELECT d.id, d.stack_name, COALESCE(d.project_id, 'global'), COALESCE(t.category, '')
+		FROM deployments d
+		LEFT JOIN templates t ON t.id = d.template_id
+		WHERE d.status = 'running'`
 	rows, err := s.db.Query(query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var deploymentIDs []string
+	type candidate struct {
+		id, stackName, projectID, category string
+	}
+	var candidates []candidate
 	for rows.Next() {
-		var id string
-		if err := rows.Scan(&id); err != nil {
+		var c candidate
+		if err := rows.Scan(&c.id, &c.stackName, &c.projectID, &c.category); err != nil {
 			continue
 		}
-		deploymentIDs = append(deploymentIDs, id)
+		candidates = append(candidates, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if selector == nil || selector.IsEmpty() {
+		deploymentIDs := make([]string, 0, len(candidates))
+		for _, c := range candidates {
+			deploymentIDs = append(deploymentIDs, c.id)
+		}
+		return deploymentIDs, nil
+	}
+
+	// Label expressions are evaluated against the containers of each stack
+	var labeledStacks map[string]bool
+	if len(selector.Labels) > 0 {
+		labeledStacks, err = s.stacksMatchingLabels(selector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var deploymentIDs []string
+	for _, c := range candidates {
+		if contains(selector.DeploymentIDs, c.id) ||
+			contains(selector.Projects, c.projectID) ||
+			(c.category != "" && contains(selector.Categories, c.category)) ||
+			labeledStacks[c.stackName] {
+			deploymentIDs = append(deploymentIDs, c.id)
+		}
 	}
 
 	return deploymentIDs, nil
 }
 
+// stacksMatchingLabels returns the stacks having a container whose labels satisfy
+// every label expression of the selector
+func (s *Scheduler) stacksMatchingLabels(selector *models.ScheduleSelector) (map[string]bool, error) {
+	containers, err := s.manager.dockerClient.ContainerList(context.Background(), types.ContainerListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	stacks := make(map[string]bool)
+	for _, container := range containers {
+		stackName := container.Labels["com.docker.compose.project"]
+		if stackName == "" {
+			stackName = container.Labels["com.docker.stack.namespace"]
+		}
+		if stackName != "" && selector.MatchesLabels(container.Labels) {
+			stacks[stackName] = true
+		}
+	}
+
+	return stacks, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // createDeploymentBackups creates deployment backup entries
 func (s *Scheduler) createDeploymentBackups(deploymentIDs []string) []models.DeploymentBackup {
 	var deployments []models.DeploymentBackup
@@ -278,17 +410,24 @@ func (s *Scheduler) scanSchedule(scanner interface {
 	Scan(dest ...interface{}) error
 }) (*models.BackupSchedule, error) {
 	var schedule models.BackupSchedule
+	var timezone, selectorJSON sql.NullString
 	var lastRun, nextRun sql.NullTime
 
 	err := scanner.Scan(
-		&schedule.ID, &schedule.Name, &schedule.CronExpression,
+		&schedule.ID, &schedule.Name, &schedule.CronExpression, &timezone,
 		&schedule.IncludeVolumes, &schedule.Encrypt, &schedule.Enabled,
-		&lastRun, &nextRun, &schedule.CreatedAt)
+		&selectorJSON, &lastRun, &nextRun, &schedule.CreatedAt)
 
 	if err != nil {
 		return nil, err
 	}
 
+	schedule.Timezone = timezone.String
+	if err := schedule.UnmarshalSelector(selectorJSON.String); err != nil {
+		return nil, err
+	}
+	schedule.Describe()
+
 	if lastRun.Valid {
 		schedule.LastRun = &lastRun.Time
 	}
@@ -3,6 +3,7 @@ package backup
 import (
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 
@@ -274,7 +275,7 @@ func (sm *StorageManager) CleanupOrphanedFiles(validBackupIDs []string) error {
 		if !validIDs[file] {
 			path := filepath.Join(sm.config.LocalPath, file)
 			if err := sm.Delete(path); err != nil {
-				fmt.Printf("Failed to delete orphaned file %s: %v\n", file, err)
+				slog.Error("Failed to delete orphaned file", "file", file, "error", err)
 			}
 		}
 	}
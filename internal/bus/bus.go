@@ -0,0 +1,65 @@
+// Package bus carries messages between the components that write deployment
+// logs, stack events and notifications and the ones pushing them to clients.
+// The in-memory bus reaches one process; the Redis bus reaches every replica
+package bus
+
+import (
+	"fmt"
+	"sync"
+
+	"docker-deploy-app/internal/config"
+)
+
+// Topics carried on the bus
+const (
+	TopicDeploymentLogs = "deployment-logs"
+	TopicStackEvents    = "stack-events"
+	TopicNotifications  = "notifications"
+)
+
+// subscriberBuffer is how many messages a subscriber may fall behind by before
+// messages are dropped for it
+const subscriberBuffer = 256
+
+// Bus publishes messages to every subscriber of a topic, including those in
+// the publishing process. Delivery is best effort: a subscriber too slow to keep
+// up misses messages rather than block publishers
+type Bus interface {
+	Publish(topic string, payload []byte) error
+	// Subscribe returns the messages published on a topic from now on, and a
+	// function ending the subscription, after which the channel is closed
+	Subscribe(topic string) (<-chan []byte, func())
+	Close() error
+}
+
+// New creates the bus selected by the configuration
+func New(cfg config.BusConfig) (Bus, error) {
+	switch cfg.Backend {
+	case "", "memory":
+		return NewMemory(), nil
+	case "redis":
+		return NewRedis(cfg.Redis)
+	default:
+		return nil, fmt.Errorf("unknown bus backend %q", cfg.Backend)
+	}
+}
+
+var (
+	defaultBus Bus = NewMemory()
+	defaultMu  sync.RWMutex
+)
+
+// Default returns the process-wide bus, in memory unless SetDefault replaced it
+func Default() Bus {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultBus
+}
+
+// SetDefault replaces the process-wide bus. Call it at startup, before the
+// components using the bus are created
+func SetDefault(b Bus) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultBus = b
+}
@@ -0,0 +1,68 @@
+package bus
+
+import "sync"
+
+// Memory is a bus within one process
+type Memory struct {
+	subscribers map[string]map[chan []byte]struct{}
+	mu          sync.RWMutex
+}
+
+// NewMemory creates an in-memory bus
+func NewMemory() *Memory {
+	return &Memory{subscribers: make(map[string]map[chan []byte]struct{})}
+}
+
+// Publish hands a message to the subscribers of a topic without waiting for them
+func (m *Memory) Publish(topic string, payload []byte) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for ch := range m.subscribers[topic] {
+		select {
+		case ch <- payload:
+		default:
+			// Subscriber is full, skip it
+		}
+	}
+	return nil
+}
+
+// Subscribe returns the messages published on a topic from now on
+func (m *Memory) Subscribe(topic string) (<-chan []byte, func()) {
+	ch := make(chan []byte, subscriberBuffer)
+
+	m.mu.Lock()
+	if m.subscribers[topic] == nil {
+		m.subscribers[topic] = make(map[chan []byte]struct{})
+	}
+	m.subscribers[topic][ch] = struct{}{}
+	m.mu.Unlock()
+
+	return ch, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.subscribers[topic][ch]; !ok {
+			return // Already ended, or the bus closed
+		}
+		delete(m.subscribers[topic], ch)
+		if len(m.subscribers[topic]) == 0 {
+			delete(m.subscribers, topic)
+		}
+		close(ch)
+	}
+}
+
+// Close ends every subscription
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for topic, subscribers := range m.subscribers {
+		for ch := range subscribers {
+			close(ch)
+		}
+		delete(m.subscribers, topic)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"docker-deploy-app/internal/config"
+)
+
+// redisBlock bounds each blocking stream read, so ended subscriptions are noticed
+const redisBlock = 5 * time.Second
+
+// Redis is a bus shared by replicas through Redis Streams. Each topic is a
+// stream capped at about StreamMaxLen entries; subscribers read the entries
+// added after they subscribed
+type Redis struct {
+	client *redis.Client
+	config config.RedisConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewRedis connects a bus to Redis
+func NewRedis(cfg config.RedisConfig) (*Redis, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.Address,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis at %s: %w", cfg.Address, err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	return &Redis{
+		client: client,
+		config: cfg,
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Publish appends a message to the topic's stream
+func (r *Redis) Publish(topic string, payload []byte) error {
+	return r.client.XAdd(r.ctx, &redis.XAddArgs{
+		Stream: r.stream(topic),
+		MaxLen: r.config.StreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"payload": payload},
+	}).Err()
+}
+
+// Subscribe reads the topic's stream from its current end
+func (r *Redis) Subscribe(topic string) (<-chan []byte, func()) {
+	ch := make(chan []byte, subscriberBuffer)
+	ctx, cancel := context.WithCancel(r.ctx)
+
+	go func() {
+		defer close(ch)
+
+		lastID := "$"
+		for {
+			streams, err := r.client.XRead(ctx, &redis.XReadArgs{
+				Streams: []string{r.stream(topic), lastID},
+				Count:   100,
+				Block:   redisBlock,
+			}).Result()
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, redis.Nil) {
+				continue // Nothing new within the block time
+			}
+			if err != nil {
+				slog.Warn("Failed to read from bus", "topic", topic, "error", err)
+				select {
+				case <-time.After(time.Second):
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			for _, stream := range streams {
+				for _, message := range stream.Messages {
+					lastID = message.ID
+					payload, _ := message.Values["payload"].(string)
+					select {
+					case ch <- []byte(payload):
+					default:
+						// Subscriber is full, skip it
+					}
+				}
+			}
+		}
+	}()
+
+	return ch, cancel
+}
+
+// Close ends every subscription and disconnects
+func (r *Redis) Close() error {
+	r.cancel()
+	return r.client.Close()
+}
+
+func (r *Redis) stream(topic string) string {
+	return r.config.KeyPrefix + topic
+}
@@ -0,0 +1,75 @@
+package certs
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/registration"
+)
+
+// account is the ACME account certificates are ordered with. Its key and
+// registration are kept in the storage path, so renewals reuse the account
+type account struct {
+	Email        string                 `json:"email"`
+	Registration *registration.Resource `json:"registration"`
+	key          crypto.PrivateKey
+}
+
+func (a *account) GetEmail() string                        { return a.Email }
+func (a *account) GetRegistration() *registration.Resource { return a.Registration }
+func (a *account) GetPrivateKey() crypto.PrivateKey        { return a.key }
+
+// loadAccount returns the stored account, creating its key on first use. A
+// registration made with another email is dropped so the account registers again
+func (m *Manager) loadAccount() (*account, error) {
+	acct := &account{Email: m.config.Email}
+	keyPath := filepath.Join(m.config.StoragePath, "account.key")
+
+	keyPEM, err := os.ReadFile(keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := certcrypto.GeneratePrivateKey(certcrypto.EC256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate ACME account key: %w", err)
+		}
+		if err := os.MkdirAll(m.config.StoragePath, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create certificate storage: %w", err)
+		}
+		if err := os.WriteFile(keyPath, certcrypto.PEMEncode(key), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write ACME account key: %w", err)
+		}
+		acct.key = key
+		return acct, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ACME account key: %w", err)
+	}
+	if acct.key, err = certcrypto.ParsePEMPrivateKey(keyPEM); err != nil {
+		return nil, fmt.Errorf("failed to parse ACME account key: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.config.StoragePath, "account.json"))
+	if err == nil {
+		var saved account
+		if json.Unmarshal(data, &saved) == nil && saved.Email == acct.Email {
+			acct.Registration = saved.Registration
+		}
+	}
+	return acct, nil
+}
+
+// saveAccount stores the registration of an account
+func (m *Manager) saveAccount(acct *account) error {
+	data, err := json.MarshalIndent(acct, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(m.config.StoragePath, "account.json"), data, 0600); err != nil {
+		return fmt.Errorf("failed to write ACME account: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package certs
+
+import (
+	"net/http"
+	"path"
+	"sync"
+)
+
+// httpChallenges answers HTTP-01 challenges from this server. lego presents the
+// key authorization of a token while an order is validated, and the CA fetches
+// it from /.well-known/acme-challenge/<token> on port 80 of the domain
+type httpChallenges struct {
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+func newHTTPChallenges() *httpChallenges {
+	return &httpChallenges{tokens: make(map[string]string)}
+}
+
+// Present makes a token answerable until it is cleaned up
+func (c *httpChallenges) Present(domain, token, keyAuth string) error {
+	c.mu.Lock()
+	c.tokens[token] = keyAuth
+	c.mu.Unlock()
+	return nil
+}
+
+// CleanUp forgets a token once its challenge is validated or abandoned
+func (c *httpChallenges) CleanUp(domain, token, keyAuth string) error {
+	c.mu.Lock()
+	delete(c.tokens, token)
+	c.mu.Unlock()
+	return nil
+}
+
+// ServeHTTP answers a challenge request with the key authorization of its token
+func (c *httpChallenges) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.RLock()
+	keyAuth, ok := c.tokens[path.Base(r.URL.Path)]
+	c.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(keyAuth))
+}
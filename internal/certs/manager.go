@@ -0,0 +1,424 @@
+package certs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge/http01"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/providers/dns"
+	"github.com/go-acme/lego/v4/registration"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/maintenance"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/webhooks"
+)
+
+const (
+	// renewalInterval is how often certificates are checked for renewal and expiry
+	renewalInterval = 6 * time.Hour
+	// alertInterval is the least time between two expiry alerts of a certificate
+	alertInterval = 24 * time.Hour
+)
+
+// Certificate manager errors
+var (
+	ErrACMEDisabled     = fmt.Errorf("ACME is not enabled")
+	ErrNoDNSProvider    = fmt.Errorf("dns-01 challenges need acme.dns_provider to be configured")
+	ErrCertificateInUse = fmt.Errorf("certificate is used by a deployment")
+)
+
+// Manager obtains certificates over ACME, keeps them under the storage path and
+// renews them before they expire. Sidecars serving a renewed certificate are
+// signalled to reload it. Orders run one at a time, as they share the account
+type Manager struct {
+	db       *sql.DB
+	client   *client.Client
+	config   config.ACMEConfig
+	webhooks *webhooks.Publisher
+	http01   *httpChallenges
+	mu       sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewManager creates a new certificate manager
+func NewManager(db *sql.DB, dockerClient *client.Client, cfg config.ACMEConfig) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Manager{
+		db:       db,
+		client:   dockerClient,
+		config:   cfg,
+		webhooks: webhooks.NewPublisher(db),
+		http01:   newHTTPChallenges(),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Enabled returns true if certificates can be obtained
+func (m *Manager) Enabled() bool {
+	return m.config.Enabled
+}
+
+// DefaultChallenge returns the challenge used when a request names none
+func (m *Manager) DefaultChallenge() string {
+	return m.config.Challenge
+}
+
+// ChallengeHandler answers HTTP-01 challenges on /.well-known/acme-challenge/
+func (m *Manager) ChallengeHandler() http.Handler {
+	return m.http01
+}
+
+// Start begins renewing certificates and alerting on those about to expire
+func (m *Manager) Start() {
+	go func() {
+		ticker := time.NewTicker(renewalInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if maintenance.Active(m.db) {
+					continue
+				}
+				m.checkCertificates()
+			case <-m.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops renewing
+func (m *Manager) Stop() {
+	m.cancel()
+}
+
+// Create records a pending certificate, to be obtained with Issue
+func (m *Manager) Create(req *models.CertificateRequest, createdBy string) (*models.Certificate, error) {
+	if !m.config.Enabled {
+		return nil, ErrACMEDisabled
+	}
+	if req.Challenge == models.ChallengeDNS01 && m.config.DNSProvider == "" {
+		return nil, ErrNoDNSProvider
+	}
+
+	now := time.Now()
+	cert := &models.Certificate{
+		ID:        fmt.Sprintf("cert_%d", now.UnixNano()),
+		Domains:   req.Domains,
+		Challenge: req.Challenge,
+		Status:    models.CertificatePending,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	domainsJSON, err := cert.MarshalDomains()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = m.db.Exec(`
+		INSERT INTO certificates (id, domains, challenge, status, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)`,
+		cert.ID, domainsJSON, cert.Challenge, cert.Status, cert.CreatedBy, now)
+	if err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+const certificateColumns = `id, domains, challenge, status, COALESCE(issuer, ''), not_before, not_after,
+	COALESCE(last_error, ''), renewed_at, alerted_at, COALESCE(created_by, ''), created_at, updated_at`
+
+// scanCertificate reads a row selected with certificateColumns
+func scanCertificate(row interface{ Scan(...interface{}) error }) (*models.Certificate, error) {
+	var cert models.Certificate
+	var domainsJSON string
+	if err := row.Scan(&cert.ID, &domainsJSON, &cert.Challenge, &cert.Status, &cert.Issuer, &cert.NotBefore, &cert.NotAfter,
+		&cert.LastError, &cert.RenewedAt, &cert.AlertedAt, &cert.CreatedBy, &cert.CreatedAt, &cert.UpdatedAt); err != nil {
+		return nil, err
+	}
+	cert.UnmarshalDomains(domainsJSON)
+	return &cert, nil
+}
+
+// List returns all certificates, those expiring first at the top
+func (m *Manager) List() ([]*models.Certificate, error) {
+	rows, err := m.db.Query("SELECT " + certificateColumns + " FROM certificates ORDER BY not_after IS NULL, not_after, created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	certificates := []*models.Certificate{}
+	for rows.Next() {
+		cert, err := scanCertificate(rows)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, cert)
+	}
+	return certificates, rows.Err()
+}
+
+// Get returns a certificate
+func (m *Manager) Get(id string) (*models.Certificate, error) {
+	cert, err := scanCertificate(m.db.QueryRow("SELECT "+certificateColumns+" FROM certificates WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrCertificateNotFound
+	}
+	return cert, err
+}
+
+// Delete removes a certificate and its files. Certificates still served by a
+// deployment are kept
+func (m *Manager) Delete(id string) error {
+	var used int
+	if err := m.db.QueryRow(`
+		SELECT COUNT(*) FROM deployments
+		WHERE json_valid(config) AND json_extract(config, '$.tls.certificate_id') = $1`, id).Scan(&used); err != nil {
+		return err
+	}
+	if used > 0 {
+		return ErrCertificateInUse
+	}
+
+	result, err := m.db.Exec("DELETE FROM certificates WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return models.ErrCertificateNotFound
+	}
+	return os.RemoveAll(filepath.Join(m.config.StoragePath, id))
+}
+
+// Dir returns the absolute directory of a certificate's files, as mounted into
+// the sidecars serving it
+func (m *Manager) Dir(id string) (string, error) {
+	return filepath.Abs(filepath.Join(m.config.StoragePath, id))
+}
+
+// Issue obtains a certificate, or renews it, and reloads the sidecars serving
+// it. Failures are recorded on the certificate and published as webhooks
+func (m *Manager) Issue(id string) error {
+	if !m.config.Enabled {
+		return ErrACMEDisabled
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cert, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+
+	resource, err := m.obtain(cert)
+	if err == nil {
+		err = m.store(cert, resource)
+	}
+	if err != nil {
+		m.recordFailure(cert, err)
+		return err
+	}
+
+	m.reloadSidecars(cert.ID)
+	return nil
+}
+
+// obtain orders a certificate for the domains of cert, registering the account
+// on first use
+func (m *Manager) obtain(cert *models.Certificate) (*certificate.Resource, error) {
+	acct, err := m.loadAccount()
+	if err != nil {
+		return nil, err
+	}
+
+	legoConfig := lego.NewConfig(acct)
+	legoConfig.CADirURL = m.config.DirectoryURL
+	legoConfig.Certificate.KeyType = certcrypto.EC256
+	acmeClient, err := lego.NewClient(legoConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ACME client: %w", err)
+	}
+	if err := m.setChallenge(acmeClient, cert.Challenge); err != nil {
+		return nil, err
+	}
+
+	if acct.Registration == nil {
+		reg, err := acmeClient.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to register ACME account: %w", err)
+		}
+		acct.Registration = reg
+		if err := m.saveAccount(acct); err != nil {
+			return nil, err
+		}
+	}
+
+	resource, err := acmeClient.Certificate.Obtain(certificate.ObtainRequest{Domains: cert.Domains, Bundle: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain certificate: %w", err)
+	}
+	return resource, nil
+}
+
+// setChallenge configures how domain control is proven for an order
+func (m *Manager) setChallenge(acmeClient *lego.Client, challenge string) error {
+	if challenge == models.ChallengeDNS01 {
+		if m.config.DNSProvider == "" {
+			return ErrNoDNSProvider
+		}
+		provider, err := dns.NewDNSChallengeProviderByName(m.config.DNSProvider)
+		if err != nil {
+			return fmt.Errorf("failed to set up DNS provider %s: %w", m.config.DNSProvider, err)
+		}
+		return acmeClient.Challenge.SetDNS01Provider(provider)
+	}
+
+	if m.config.HTTPAddress != "" {
+		host, port, err := net.SplitHostPort(m.config.HTTPAddress)
+		if err != nil {
+			return fmt.Errorf("invalid acme.http_address: %w", err)
+		}
+		return acmeClient.Challenge.SetHTTP01Provider(http01.NewProviderServer(host, port))
+	}
+	return acmeClient.Challenge.SetHTTP01Provider(m.http01)
+}
+
+// store writes the files of an issued certificate and records its validity.
+// Files are replaced by renaming, so a sidecar never reads a half-written one
+func (m *Manager) store(cert *models.Certificate, resource *certificate.Resource) error {
+	parsed, err := certcrypto.ParsePEMCertificate(resource.Certificate)
+	if err != nil {
+		return fmt.Errorf("failed to parse issued certificate: %w", err)
+	}
+
+	dir := filepath.Join(m.config.StoragePath, cert.ID)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+	for name, data := range map[string][]byte{docker.TLSKeyFile: resource.PrivateKey, docker.TLSCertFile: resource.Certificate} {
+		tmp := filepath.Join(dir, name+".tmp")
+		if err := os.WriteFile(tmp, data, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	now := time.Now()
+	_, err = m.db.Exec(`
+		UPDATE certificates
+		SET status = $1, issuer = $2, not_before = $3, not_after = $4, last_error = '',
+		    renewed_at = $5, alerted_at = NULL, updated_at = $5
+		WHERE id = $6`,
+		models.CertificateIssued, parsed.Issuer.CommonName, parsed.NotBefore, parsed.NotAfter, now, cert.ID)
+	return err
+}
+
+// recordFailure marks a certificate as failed and notifies webhooks
+func (m *Manager) recordFailure(cert *models.Certificate, issueErr error) {
+	if _, err := m.db.Exec(`
+		UPDATE certificates SET status = $1, last_error = $2, updated_at = $3 WHERE id = $4`,
+		models.CertificateFailed, issueErr.Error(), time.Now(), cert.ID); err != nil {
+		slog.Error("Failed to record certificate failure", "certificate_id", cert.ID, "error", err)
+	}
+
+	m.webhooks.Publish(models.WebhookEventCertificateFailed, map[string]interface{}{
+		"certificate_id": cert.ID,
+		"domains":        cert.Domains,
+		"not_after":      cert.NotAfter,
+		"error":          issueErr.Error(),
+	})
+}
+
+// reloadSidecars signals the sidecars serving a certificate to load it again;
+// nginx re-reads its configuration and certificates on SIGHUP
+func (m *Manager) reloadSidecars(id string) {
+	if m.client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	containers, err := m.client.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", docker.TLSCertificateLabel+"="+id)),
+	})
+	if err != nil {
+		slog.Warn("Failed to list TLS sidecars", "certificate_id", id, "error", err)
+		return
+	}
+	for _, c := range containers {
+		if err := m.client.ContainerKill(ctx, c.ID, "HUP"); err != nil {
+			slog.Warn("Failed to reload TLS sidecar", "certificate_id", id, "container", c.ID, "error", err)
+		}
+	}
+}
+
+// checkCertificates renews the certificates due for renewal, and alerts on those
+// that failed to renew as their expiry draws near
+func (m *Manager) checkCertificates() {
+	certificates, err := m.List()
+	if err != nil {
+		slog.Error("Failed to list certificates", "error", err)
+		return
+	}
+
+	renewBefore := time.Duration(m.config.RenewBeforeDays) * 24 * time.Hour
+	alertBefore := time.Duration(m.config.AlertBeforeDays) * 24 * time.Hour
+	for _, cert := range certificates {
+		// Certificates never issued are obtained on request only
+		if !cert.ExpiresWithin(renewBefore) {
+			continue
+		}
+
+		err := m.Issue(cert.ID)
+		if err == nil {
+			slog.Info("Renewed certificate", "certificate_id", cert.ID, "domains", strings.Join(cert.Domains, ","))
+			continue
+		}
+		slog.Error("Failed to renew certificate", "certificate_id", cert.ID, "error", err)
+
+		if alertBefore > 0 && cert.ExpiresWithin(alertBefore) && (cert.AlertedAt == nil || time.Since(*cert.AlertedAt) >= alertInterval) {
+			m.alert(cert)
+		}
+	}
+}
+
+// alert warns that a certificate expires soon
+func (m *Manager) alert(cert *models.Certificate) {
+	daysLeft := int(time.Until(*cert.NotAfter).Hours() / 24)
+	slog.Warn("Certificate expires soon", "certificate_id", cert.ID, "domains", strings.Join(cert.Domains, ","), "days_left", daysLeft)
+
+	m.webhooks.Publish(models.WebhookEventCertificateExpiring, map[string]interface{}{
+		"certificate_id": cert.ID,
+		"domains":        cert.Domains,
+		"not_after":      cert.NotAfter,
+		"days_left":      daysLeft,
+	})
+	if _, err := m.db.Exec("UPDATE certificates SET alerted_at = $1 WHERE id = $2", time.Now(), cert.ID); err != nil {
+		slog.Error("Failed to record certificate alert", "certificate_id", cert.ID, "error", err)
+	}
+}
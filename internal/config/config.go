@@ -1,9 +1,13 @@
 package config
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Config holds the application configuration
@@ -18,31 +22,82 @@ type Config struct {
 	Templates   TemplatesConfig   `yaml:"templates"`
 	Logging     LoggingConfig     `yaml:"logging"`
 	Security    SecurityConfig    `yaml:"security"`
+	Monitoring  MonitoringConfig  `yaml:"monitoring"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Telemetry   TelemetryConfig   `yaml:"telemetry"`
+	Trash       TrashConfig       `yaml:"trash"`
+	Quotas      QuotaConfig       `yaml:"quotas"`
+	Bus         BusConfig         `yaml:"bus"`
+	ACME        ACMEConfig        `yaml:"acme"`
 }
 
 type ServerConfig struct {
-	Port int        `yaml:"port"`
-	Host string     `yaml:"host"`
-	CORS CORSConfig `yaml:"cors"`
+	Port      int             `yaml:"port"`
+	Host      string          `yaml:"host"`
+	CORS      CORSConfig      `yaml:"cors"`
+	WebSocket WebSocketConfig `yaml:"websocket"`
+	GRPC      GRPCConfig      `yaml:"grpc"`
+	WebDir    string          `yaml:"web_dir"` // Serves the frontend from disk instead of the binary, for development
+}
+
+// GRPCConfig serves the deployment, stack and log services over gRPC on a port
+// of their own, for agents and automation clients. It needs a binary built with
+// the grpc tag
+type GRPCConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Port    int  `yaml:"port"`
+}
+
+// WebSocketConfig limits and keeps alive WebSocket connections. A limit of 0 is unlimited
+type WebSocketConfig struct {
+	MaxConnections int `yaml:"max_connections"`
+	MaxPerUser     int `yaml:"max_per_user"`  // Per authenticated user, or per client address without authentication
+	PingInterval   int `yaml:"ping_interval"` // Seconds; clients missing two pings are dropped
+	WriteTimeout   int `yaml:"write_timeout"` // Seconds
 }
 
 type CORSConfig struct {
-	Enabled bool     `yaml:"enabled"`
-	Origins []string `yaml:"origins"`
+	Enabled          bool     `yaml:"enabled"`
+	Origins          []string `yaml:"origins"`           // See OriginPattern for the forms accepted
+	AllowCredentials bool     `yaml:"allow_credentials"` // Not allowed with the "*" origin
 }
 
 type DockerConfig struct {
 	Socket         string `yaml:"socket"`
 	ComposeTimeout int    `yaml:"compose_timeout"`
 	DefaultNetwork string `yaml:"default_network"`
+	SwarmEnabled   bool   `yaml:"swarm_enabled"`
+	// UpdateCheckInterval is the number of seconds between image update checks (0 disables)
+	UpdateCheckInterval int                   `yaml:"update_check_interval"`
+	VolumeBrowser       VolumeBrowserConfig   `yaml:"volume_browser"`
+	VolumeSnapshots     VolumeSnapshotsConfig `yaml:"volume_snapshots"`
+}
+
+// VolumeBrowserConfig lets users list and download the files of stack volumes
+// through a short-lived helper container, and upload files when AllowWrite is set
+type VolumeBrowserConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	AllowWrite    bool   `yaml:"allow_write"`
+	HelperImage   string `yaml:"helper_image"`     // Needs sh and stat, as in busybox
+	MaxFileSizeMB int    `yaml:"max_file_size_mb"` // Largest file downloaded or uploaded
+}
+
+// VolumeSnapshotsConfig controls quick snapshots of single stack volumes, kept
+// apart from full backups. Their helper containers run the volume browser's
+// helper image
+type VolumeSnapshotsConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	StoragePath  string `yaml:"storage_path"`
+	MaxPerVolume int    `yaml:"max_per_volume"` // Oldest snapshots beyond this are removed; 0 keeps all
 }
 
 type NewtConfig struct {
-	Enabled       bool              `yaml:"enabled"`
-	AutoInject    bool              `yaml:"auto_inject"`
-	DefaultImage  string            `yaml:"default_image"`
-	Validation    ValidationConfig  `yaml:"validation"`
-	DefaultConfig DefaultNewtConfig `yaml:"default_config"`
+	Enabled        bool              `yaml:"enabled"`
+	AutoInject     bool              `yaml:"auto_inject"`
+	DefaultImage   string            `yaml:"default_image"`
+	StatusInterval int               `yaml:"status_interval"` // Seconds between tunnel status checks, 0 disables
+	Validation     ValidationConfig  `yaml:"validation"`
+	DefaultConfig  DefaultNewtConfig `yaml:"default_config"`
 }
 
 type ValidationConfig struct {
@@ -60,9 +115,31 @@ type MarketplaceConfig struct {
 	Enabled               bool     `yaml:"enabled"`
 	MinRatingsForDisplay  int      `yaml:"min_ratings_for_display"`
 	FeaturedTemplateCount int      `yaml:"featured_template_count"`
-	Categories            []string `yaml:"categories"`
+	Categories            []string `yaml:"categories"` // Unused: categories are managed through /api/admin/categories
 	AllowAnonymousRatings bool     `yaml:"allow_anonymous_ratings"`
-	ReviewModeration      bool     `yaml:"review_moderation"`
+	ReviewModeration      bool     `yaml:"review_moderation"` // Hide reviews breaking the content policy until approved
+	// Only users who deployed a template may rate it. Reviews of deployers are
+	// badged as verified either way
+	RequireDeploymentToRate bool `yaml:"require_deployment_to_rate"`
+	// A template is flagged flaky once it has FlakyMinDeployments finished
+	// deployments and a success rate below FlakySuccessPercent
+	FlakyMinDeployments int `yaml:"flaky_min_deployments"`
+	FlakySuccessPercent int `yaml:"flaky_success_percent"`
+	// Seconds a template test waits for its services to come up healthy
+	TestTimeout int `yaml:"test_timeout"`
+
+	ContentPolicy ContentPolicyConfig `yaml:"content_policy"`
+}
+
+// ContentPolicyConfig is the filter run on submitted reviews and synced template
+// descriptions. Text breaking it goes to the moderation queue. A limit of 0 is
+// not checked
+type ContentPolicyConfig struct {
+	Enabled              bool     `yaml:"enabled"`
+	BannedWords          []string `yaml:"banned_words"` // Matched as whole words, ignoring case
+	MaxLinks             int      `yaml:"max_links"`
+	MaxReviewLength      int      `yaml:"max_review_length"`      // Characters
+	MaxDescriptionLength int      `yaml:"max_description_length"` // Characters
 }
 
 type BackupConfig struct {
@@ -71,6 +148,7 @@ type BackupConfig struct {
 	Retention  RetentionConfig     `yaml:"retention"`
 	Encryption EncryptionConfig    `yaml:"encryption"`
 	Schedules  SchedulesConfig     `yaml:"schedules"`
+	Safety     SafetyBackupConfig  `yaml:"safety"`
 }
 
 type BackupStorageConfig struct {
@@ -92,9 +170,41 @@ type RetentionConfig struct {
 	Monthly int `yaml:"monthly"`
 }
 
+// SafetyBackupConfig controls the automatic backups taken before upgrades, redeploys
+// and restores that overwrite a deployment
+type SafetyBackupConfig struct {
+	Enabled        bool `yaml:"enabled"`
+	IncludeVolumes bool `yaml:"include_volumes"`
+	Keep           int  `yaml:"keep"`         // Safety backups kept per deployment
+	MaxAgeDays     int  `yaml:"max_age_days"` // Older ones are pruned, except the newest
+}
+
+// EncryptionConfig chooses where the keys of encrypted backups are kept: files under
+// KeyPath (local), data keys wrapped by AWS KMS (kms) or by a Vault transit key
+// (vault), or nowhere, deriving each key from a passphrase (passphrase). Backups
+// record their key storage, so changing it keeps older backups restorable
 type EncryptionConfig struct {
-	Enabled    bool   `yaml:"enabled"`
-	KeyStorage string `yaml:"key_storage"`
+	Enabled    bool        `yaml:"enabled"`
+	KeyStorage string      `yaml:"key_storage"`
+	KeyPath    string      `yaml:"key_path"`
+	Passphrase string      `yaml:"passphrase"` // Used when a backup or restore gives none
+	KMS        KMSConfig   `yaml:"kms"`
+	Vault      VaultConfig `yaml:"vault"`
+}
+
+// KMSConfig selects the AWS KMS key wrapping backup keys. Credentials come from
+// the default AWS chain
+type KMSConfig struct {
+	KeyID  string `yaml:"key_id"` // Key ID, ARN or alias
+	Region string `yaml:"region"`
+}
+
+// VaultConfig selects the Vault transit key wrapping backup keys
+type VaultConfig struct {
+	Address string `yaml:"address"`
+	Token   string `yaml:"token"`
+	Mount   string `yaml:"mount"`
+	KeyName string `yaml:"key_name"`
 }
 
 type SchedulesConfig struct {
@@ -110,9 +220,13 @@ type ScheduleConfig struct {
 }
 
 type GitHubConfig struct {
-	Token         string `yaml:"token"`
-	WebhookSecret string `yaml:"webhook_secret"`
-	SyncInterval  int    `yaml:"sync_interval"`
+	Token             string `yaml:"token"`
+	WebhookSecret     string `yaml:"webhook_secret"`
+	SyncInterval      int    `yaml:"sync_interval"`
+	AppID             int64  `yaml:"app_id"`
+	InstallationID    int64  `yaml:"installation_id"`
+	AppPrivateKey     string `yaml:"app_private_key"`
+	AppPrivateKeyPath string `yaml:"app_private_key_path"`
 }
 
 type DatabaseConfig struct {
@@ -130,9 +244,18 @@ type TemplatesConfig struct {
 }
 
 type LoggingConfig struct {
-	Level  string `yaml:"level"`
-	Format string `yaml:"format"`
-	Output string `yaml:"output"`
+	Level            string             `yaml:"level"`
+	Format           string             `yaml:"format"`
+	Output           string             `yaml:"output"`
+	TracePropagation bool               `yaml:"trace_propagation"`
+	DeploymentLogs   LogRetentionConfig `yaml:"deployment_logs"`
+}
+
+type LogRetentionConfig struct {
+	RetentionDays        int    `yaml:"retention_days"`
+	MaxRowsPerDeployment int    `yaml:"max_rows_per_deployment"`
+	PruneInterval        int    `yaml:"prune_interval"`
+	ArchivePath          string `yaml:"archive_path"`
 }
 
 type SecurityConfig struct {
@@ -140,6 +263,7 @@ type SecurityConfig struct {
 	APIKey         string          `yaml:"api_key"`
 	SessionTimeout int             `yaml:"session_timeout"`
 	EncryptSecrets bool            `yaml:"encrypt_secrets"`
+	SecretKey      string          `yaml:"secret_key"`
 	RateLimiting   RateLimitConfig `yaml:"rate_limiting"`
 }
 
@@ -148,147 +272,575 @@ type RateLimitConfig struct {
 	RequestsPerMinute int  `yaml:"requests_per_minute"`
 }
 
-// Load loads configuration from environment variables with defaults
-func Load() (*Config, error) {
-	config := &Config{
+type MonitoringConfig struct {
+	DiskWarningPercent  int               `yaml:"disk_warning_percent"`
+	DiskCriticalPercent int               `yaml:"disk_critical_percent"`
+	DiskCheckInterval   int               `yaml:"disk_check_interval"`  // Seconds between threshold checks, 0 disables
+	EventRetentionDays  int               `yaml:"event_retention_days"` // Container events kept per stack, 0 keeps them all
+	MaxEventsPerStack   int               `yaml:"max_events_per_stack"`
+	StatsInterval       int               `yaml:"stats_interval"` // Seconds between cached stats samples, 0 samples per request
+	RestartLoop         RestartLoopConfig `yaml:"restart_loop"`
+	Uptime              UptimeConfig      `yaml:"uptime"`
+	Footprint           FootprintConfig   `yaml:"footprint"`
+}
+
+// RestartLoopConfig flags services whose containers die more than MaxRestarts
+// times within Window minutes
+type RestartLoopConfig struct {
+	Enabled     bool `yaml:"enabled"`
+	MaxRestarts int  `yaml:"max_restarts"`
+	Window      int  `yaml:"window"`       // Minutes
+	StopService bool `yaml:"stop_service"` // Stop the crashing service's containers once flagged
+}
+
+// UptimeConfig runs the uptime probes of deployments
+type UptimeConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	RetentionDays int  `yaml:"retention_days"` // Check history kept per probe
+	MaxConcurrent int  `yaml:"max_concurrent"` // Checks run at once
+}
+
+// FootprintConfig samples the resource usage of running deployments, to
+// estimate their monthly footprint. Energy is only estimated with WattsPerCore
+type FootprintConfig struct {
+	Enabled        bool `yaml:"enabled"`
+	SampleInterval int  `yaml:"sample_interval"` // Seconds between samples
+	RetentionDays  int  `yaml:"retention_days"`
+	WattsPerCore   int  `yaml:"watts_per_core"` // Power drawn by one fully used CPU core
+}
+
+type WebhooksConfig struct {
+	Enabled      bool `yaml:"enabled"`
+	PollInterval int  `yaml:"poll_interval"` // Seconds between delivery runs
+	MaxAttempts  int  `yaml:"max_attempts"`
+	Timeout      int  `yaml:"timeout"` // Seconds per delivery attempt
+	// Alerts that may resolve themselves, such as an unhealthy stack, are held
+	// for FlapWindow seconds and dropped if they recover meanwhile. An alert of
+	// the same subject as one sent less than DedupWindow seconds before is
+	// dropped. Zero disables either
+	FlapWindow  int `yaml:"flap_window"`
+	DedupWindow int `yaml:"dedup_window"`
+}
+
+// TelemetryConfig controls anonymous usage reporting, which is off unless enabled
+type TelemetryConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Endpoint string `yaml:"endpoint"`
+	Interval int    `yaml:"interval"` // Seconds between reports
+}
+
+// TrashConfig keeps deleted deployments, with their compose files and volumes,
+// restorable for RetentionDays before they are purged
+type TrashConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	RetentionDays int  `yaml:"retention_days"`
+}
+
+// ACMEConfig obtains TLS certificates for services exposed directly on host ports
+// rather than through a tunnel. HTTP-01 challenges are answered on
+// /.well-known/acme-challenge/ of this server unless HTTPAddress runs a listener
+// of its own; DNS-01 providers are configured through their lego environment
+// variables, such as CLOUDFLARE_DNS_API_TOKEN
+type ACMEConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	Email           string `yaml:"email"`
+	DirectoryURL    string `yaml:"directory_url"`
+	StoragePath     string `yaml:"storage_path"`
+	Challenge       string `yaml:"challenge"`         // Default challenge: http-01 or dns-01
+	HTTPAddress     string `yaml:"http_address"`      // Such as :80
+	DNSProvider     string `yaml:"dns_provider"`      // lego provider name, such as cloudflare or route53
+	RenewBeforeDays int    `yaml:"renew_before_days"` // Certificates are renewed this close to expiry
+	AlertBeforeDays int    `yaml:"alert_before_days"` // Expiry alerts start this close to expiry; 0 disables them
+}
+
+// BusConfig selects how deployment logs, stack events and notifications reach
+// their subscribers. The memory backend only reaches the same process, so run
+// more than one replica on redis
+type BusConfig struct {
+	Backend string      `yaml:"backend"` // memory or redis
+	Redis   RedisConfig `yaml:"redis"`
+}
+
+// RedisConfig connects the bus to Redis, where each topic is a stream
+type RedisConfig struct {
+	Address      string `yaml:"address"`
+	Password     string `yaml:"password"`
+	DB           int    `yaml:"db"`
+	KeyPrefix    string `yaml:"key_prefix"`     // Prepended to stream names
+	StreamMaxLen int64  `yaml:"stream_max_len"` // Entries kept per stream, approximately
+}
+
+// QuotaConfig limits what each project and each user may consume. Limits set
+// for a project or user through the API replace these defaults
+type QuotaConfig struct {
+	Enabled bool        `yaml:"enabled"`
+	Project QuotaLimits `yaml:"project"`
+	User    QuotaLimits `yaml:"user"`
+}
+
+// QuotaLimits are the limits of a quota; 0 is unlimited
+type QuotaLimits struct {
+	MaxDeployments     int `yaml:"max_deployments"`
+	MaxMemoryMB        int `yaml:"max_memory_mb"`         // Sum of the memory limits of running containers
+	MaxBackupStorageMB int `yaml:"max_backup_storage_mb"` // Size of completed backups
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
 		Server: ServerConfig{
-			Port: getEnvInt("SERVER_PORT", 8080),
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
+			Port: 8080,
+			Host: "0.0.0.0",
 			CORS: CORSConfig{
-				Enabled: getEnvBool("CORS_ENABLED", true),
-				Origins: getEnvSlice("CORS_ORIGINS", []string{"*"}),
+				Enabled: true,
+				Origins: []string{"*"},
+			},
+			WebSocket: WebSocketConfig{
+				MaxConnections: 500,
+				MaxPerUser:     10,
+				PingInterval:   30,
+				WriteTimeout:   10,
+			},
+			GRPC: GRPCConfig{
+				Port: 9090,
 			},
 		},
 		Docker: DockerConfig{
-			Socket:         getEnv("DOCKER_SOCKET", "/var/run/docker.sock"),
-			ComposeTimeout: getEnvInt("DOCKER_COMPOSE_TIMEOUT", 300),
-			DefaultNetwork: getEnv("DOCKER_DEFAULT_NETWORK", "app_network"),
+			Socket:              "/var/run/docker.sock",
+			ComposeTimeout:      300,
+			DefaultNetwork:      "app_network",
+			SwarmEnabled:        false,
+			UpdateCheckInterval: 21600,
+			VolumeBrowser: VolumeBrowserConfig{
+				Enabled:       true,
+				AllowWrite:    false,
+				HelperImage:   "busybox:1.36",
+				MaxFileSizeMB: 100,
+			},
+			VolumeSnapshots: VolumeSnapshotsConfig{
+				Enabled:      true,
+				StoragePath:  "./data/snapshots",
+				MaxPerVolume: 10,
+			},
 		},
 		Newt: NewtConfig{
-			Enabled:      getEnvBool("NEWT_ENABLED", true),
-			AutoInject:   getEnvBool("NEWT_AUTO_INJECT", true),
-			DefaultImage: getEnv("NEWT_DEFAULT_IMAGE", "fosrl/newt:latest"),
+			Enabled:        true,
+			AutoInject:     true,
+			DefaultImage:   "fosrl/newt:latest",
+			StatusInterval: 60,
 			Validation: ValidationConfig{
-				Enforce:            getEnvBool("NEWT_VALIDATION_ENFORCE", true),
-				RequireHealthCheck: getEnvBool("NEWT_REQUIRE_HEALTH_CHECK", true),
+				Enforce:            true,
+				RequireHealthCheck: true,
 			},
 			DefaultConfig: DefaultNewtConfig{
-				LogLevel:     getEnv("NEWT_LOG_LEVEL", "INFO"),
-				HealthFile:   getEnv("NEWT_HEALTH_FILE", "/tmp/healthy"),
-				DockerSocket: getEnv("NEWT_DOCKER_SOCKET", "/var/run/docker.sock"),
+				LogLevel:     "INFO",
+				HealthFile:   "/tmp/healthy",
+				DockerSocket: "/var/run/docker.sock",
 			},
 		},
 		Marketplace: MarketplaceConfig{
-			Enabled:               getEnvBool("MARKETPLACE_ENABLED", true),
-			MinRatingsForDisplay:  getEnvInt("MARKETPLACE_MIN_RATINGS", 5),
-			FeaturedTemplateCount: getEnvInt("MARKETPLACE_FEATURED_COUNT", 10),
-			Categories: getEnvSlice("MARKETPLACE_CATEGORIES", []string{
+			Enabled:               true,
+			MinRatingsForDisplay:  5,
+			FeaturedTemplateCount: 10,
+			Categories: []string{
 				"web", "database", "monitoring", "networking", "development", "ai-ml", "security", "analytics",
-			}),
-			AllowAnonymousRatings: getEnvBool("MARKETPLACE_ALLOW_ANONYMOUS_RATINGS", false),
-			ReviewModeration:      getEnvBool("MARKETPLACE_REVIEW_MODERATION", true),
+			},
+			AllowAnonymousRatings: false,
+			ReviewModeration:      true,
+			FlakyMinDeployments:   5,
+			FlakySuccessPercent:   80,
+			TestTimeout:           300,
+			ContentPolicy: ContentPolicyConfig{
+				Enabled:              true,
+				MaxLinks:             2,
+				MaxReviewLength:      2000,
+				MaxDescriptionLength: 5000,
+			},
 		},
 		Backup: BackupConfig{
-			Enabled: getEnvBool("BACKUP_ENABLED", true),
+			Enabled: true,
 			Storage: BackupStorageConfig{
-				Type: getEnv("BACKUP_STORAGE_TYPE", "local"),
-				Path: getEnv("BACKUP_STORAGE_PATH", "./backups"),
+				Type: "local",
+				Path: "./backups",
 				S3: S3Config{
-					Bucket:    getEnv("S3_BACKUP_BUCKET", ""),
-					Region:    getEnv("S3_REGION", ""),
-					AccessKey: getEnv("S3_ACCESS_KEY", ""),
-					SecretKey: getEnv("S3_SECRET_KEY", ""),
+					Bucket:    "",
+					Region:    "",
+					AccessKey: "",
+					SecretKey: "",
 				},
 			},
 			Retention: RetentionConfig{
-				Daily:   getEnvInt("BACKUP_RETENTION_DAILY", 7),
-				Weekly:  getEnvInt("BACKUP_RETENTION_WEEKLY", 4),
-				Monthly: getEnvInt("BACKUP_RETENTION_MONTHLY", 12),
+				Daily:   7,
+				Weekly:  4,
+				Monthly: 12,
+			},
+			Safety: SafetyBackupConfig{
+				Enabled:        true,
+				IncludeVolumes: true,
+				Keep:           3,
+				MaxAgeDays:     7,
 			},
 			Encryption: EncryptionConfig{
-				Enabled:    getEnvBool("BACKUP_ENCRYPTION_ENABLED", true),
-				KeyStorage: getEnv("BACKUP_KEY_STORAGE", "local"),
+				Enabled:    true,
+				KeyStorage: "local",
+				KeyPath:    "./data/backup-keys",
+				Vault: VaultConfig{
+					Mount: "transit",
+				},
 			},
 			Schedules: SchedulesConfig{
 				Daily: ScheduleConfig{
-					Enabled:        getEnvBool("BACKUP_DAILY_ENABLED", true),
-					Time:           getEnv("BACKUP_DAILY_TIME", "02:00"),
-					IncludeVolumes: getEnvBool("BACKUP_DAILY_INCLUDE_VOLUMES", false),
+					Enabled:        true,
+					Time:           "02:00",
+					IncludeVolumes: false,
 				},
 				Weekly: ScheduleConfig{
-					Enabled:        getEnvBool("BACKUP_WEEKLY_ENABLED", true),
-					Day:            getEnv("BACKUP_WEEKLY_DAY", "sunday"),
-					Time:           getEnv("BACKUP_WEEKLY_TIME", "03:00"),
-					IncludeVolumes: getEnvBool("BACKUP_WEEKLY_INCLUDE_VOLUMES", true),
+					Enabled:        true,
+					Day:            "sunday",
+					Time:           "03:00",
+					IncludeVolumes: true,
 				},
 			},
 		},
 		GitHub: GitHubConfig{
-			Token:         getEnv("GITHUB_TOKEN", ""),
-			WebhookSecret: getEnv("GITHUB_WEBHOOK_SECRET", ""),
-			SyncInterval:  getEnvInt("GITHUB_SYNC_INTERVAL", 3600),
+			Token:             "",
+			WebhookSecret:     "",
+			SyncInterval:      3600,
+			AppID:             0,
+			InstallationID:    0,
+			AppPrivateKey:     "",
+			AppPrivateKeyPath: "",
 		},
 		Database: DatabaseConfig{
-			Type:           getEnv("DATABASE_TYPE", "sqlite"),
-			Path:           getEnv("DATABASE_PATH", "./data/app.db"),
-			BackupEnabled:  getEnvBool("DATABASE_BACKUP_ENABLED", true),
-			BackupInterval: getEnvInt("DATABASE_BACKUP_INTERVAL", 3600),
+			Type:           "sqlite",
+			Path:           "./data/app.db",
+			BackupEnabled:  true,
+			BackupInterval: 3600,
 		},
 		Templates: TemplatesConfig{
-			RepoURL:              getEnv("TEMPLATES_REPO_URL", ""),
-			Branch:               getEnv("TEMPLATES_BRANCH", "main"),
-			CacheDuration:        getEnvInt("TEMPLATES_CACHE_DURATION", 300),
-			AutoVerifyPublishers: getEnvSlice("TEMPLATES_AUTO_VERIFY_PUBLISHERS", []string{}),
+			RepoURL:              "",
+			Branch:               "main",
+			CacheDuration:        300,
+			AutoVerifyPublishers: []string{},
 		},
 		Logging: LoggingConfig{
-			Level:  getEnv("LOG_LEVEL", "info"),
-			Format: getEnv("LOG_FORMAT", "json"),
-			Output: getEnv("LOG_OUTPUT", "stdout"),
+			Level:            "info",
+			Format:           "json",
+			Output:           "stdout",
+			TracePropagation: false,
+			DeploymentLogs: LogRetentionConfig{
+				RetentionDays:        30,
+				MaxRowsPerDeployment: 10000,
+				PruneInterval:        3600,
+				ArchivePath:          "./data/log-archive",
+			},
 		},
 		Security: SecurityConfig{
-			AuthEnabled:    getEnvBool("AUTH_ENABLED", false),
-			APIKey:         getEnv("API_KEY", ""),
-			SessionTimeout: getEnvInt("SESSION_TIMEOUT", 3600),
-			EncryptSecrets: getEnvBool("ENCRYPT_SECRETS", true),
+			AuthEnabled:    false,
+			APIKey:         "",
+			SessionTimeout: 3600,
+			EncryptSecrets: true,
+			SecretKey:      "",
 			RateLimiting: RateLimitConfig{
-				Enabled:           getEnvBool("RATE_LIMITING_ENABLED", true),
-				RequestsPerMinute: getEnvInt("RATE_LIMITING_RPM", 60),
+				Enabled:           true,
+				RequestsPerMinute: 60,
+			},
+		},
+		Monitoring: MonitoringConfig{
+			DiskWarningPercent:  80,
+			DiskCriticalPercent: 90,
+			DiskCheckInterval:   300,
+			EventRetentionDays:  14,
+			MaxEventsPerStack:   1000,
+			StatsInterval:       10,
+			RestartLoop: RestartLoopConfig{
+				Enabled:     true,
+				MaxRestarts: 5,
+				Window:      10,
+				StopService: false,
+			},
+			Uptime: UptimeConfig{
+				Enabled:       true,
+				RetentionDays: 30,
+				MaxConcurrent: 10,
+			},
+			Footprint: FootprintConfig{
+				Enabled:        true,
+				SampleInterval: 300,
+				RetentionDays:  30,
+				WattsPerCore:   0,
 			},
 		},
+		Webhooks: WebhooksConfig{
+			Enabled:      true,
+			PollInterval: 10,
+			MaxAttempts:  8,
+			Timeout:      10,
+			FlapWindow:   120,
+			DedupWindow:  900,
+		},
+		Telemetry: TelemetryConfig{
+			Enabled:  false,
+			Endpoint: "https://telemetry.docker-deploy.app/v1/report",
+			Interval: 86400,
+		},
+		Trash: TrashConfig{
+			Enabled:       true,
+			RetentionDays: 7,
+		},
+		ACME: ACMEConfig{
+			Enabled:         false,
+			DirectoryURL:    "https://acme-v02.api.letsencrypt.org/directory",
+			StoragePath:     "./data/certs",
+			Challenge:       "http-01",
+			RenewBeforeDays: 30,
+			AlertBeforeDays: 14,
+		},
+		Bus: BusConfig{
+			Backend: "memory",
+			Redis: RedisConfig{
+				Address:      "localhost:6379",
+				KeyPrefix:    "docker-deploy:",
+				StreamMaxLen: 10000,
+			},
+		},
+	}
+}
+
+// Load loads the configuration: the built-in defaults, overridden by the YAML
+// file at path when one is given, overridden in turn by environment variables.
+// The result is validated
+func Load(path string) (*Config, error) {
+	config := Default()
+
+	if path != "" {
+		if err := loadFile(path, config); err != nil {
+			return nil, err
+		}
 	}
+	applyEnv(config)
+	config.Server.CORS.normalize()
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
-// Helper functions for environment variable parsing
-func getEnv(key, defaultValue string) string {
+// loadFile decodes a YAML configuration file over config. Unknown keys are
+// errors, so a misspelled setting is not silently ignored
+func loadFile(path string, config *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides config with the environment variables that are set
+func applyEnv(config *Config) {
+	envInt(&config.Server.Port, "SERVER_PORT")
+	envString(&config.Server.Host, "SERVER_HOST")
+	envInt(&config.Server.WebSocket.MaxConnections, "WEBSOCKET_MAX_CONNECTIONS")
+	envInt(&config.Server.WebSocket.MaxPerUser, "WEBSOCKET_MAX_PER_USER")
+	envInt(&config.Server.WebSocket.PingInterval, "WEBSOCKET_PING_INTERVAL")
+	envInt(&config.Server.WebSocket.WriteTimeout, "WEBSOCKET_WRITE_TIMEOUT")
+	envBool(&config.Server.GRPC.Enabled, "GRPC_ENABLED")
+	envInt(&config.Server.GRPC.Port, "GRPC_PORT")
+	envBool(&config.Server.CORS.Enabled, "CORS_ENABLED")
+	envSlice(&config.Server.CORS.Origins, "CORS_ORIGINS")
+	envBool(&config.Server.CORS.AllowCredentials, "CORS_ALLOW_CREDENTIALS")
+	envString(&config.Server.WebDir, "WEB_DIR")
+	envString(&config.Docker.Socket, "DOCKER_SOCKET")
+	envInt(&config.Docker.ComposeTimeout, "DOCKER_COMPOSE_TIMEOUT")
+	envString(&config.Docker.DefaultNetwork, "DOCKER_DEFAULT_NETWORK")
+	envBool(&config.Docker.SwarmEnabled, "DOCKER_SWARM_ENABLED")
+	envInt(&config.Docker.UpdateCheckInterval, "DOCKER_UPDATE_CHECK_INTERVAL")
+	envBool(&config.Docker.VolumeBrowser.Enabled, "VOLUME_BROWSER_ENABLED")
+	envBool(&config.Docker.VolumeBrowser.AllowWrite, "VOLUME_BROWSER_ALLOW_WRITE")
+	envString(&config.Docker.VolumeBrowser.HelperImage, "VOLUME_BROWSER_HELPER_IMAGE")
+	envInt(&config.Docker.VolumeBrowser.MaxFileSizeMB, "VOLUME_BROWSER_MAX_FILE_SIZE_MB")
+	envBool(&config.Docker.VolumeSnapshots.Enabled, "VOLUME_SNAPSHOTS_ENABLED")
+	envString(&config.Docker.VolumeSnapshots.StoragePath, "VOLUME_SNAPSHOTS_STORAGE_PATH")
+	envInt(&config.Docker.VolumeSnapshots.MaxPerVolume, "VOLUME_SNAPSHOTS_MAX_PER_VOLUME")
+	envBool(&config.Newt.Enabled, "NEWT_ENABLED")
+	envBool(&config.Newt.AutoInject, "NEWT_AUTO_INJECT")
+	envString(&config.Newt.DefaultImage, "NEWT_DEFAULT_IMAGE")
+	envInt(&config.Newt.StatusInterval, "NEWT_STATUS_INTERVAL")
+	envBool(&config.Newt.Validation.Enforce, "NEWT_VALIDATION_ENFORCE")
+	envBool(&config.Newt.Validation.RequireHealthCheck, "NEWT_REQUIRE_HEALTH_CHECK")
+	envString(&config.Newt.DefaultConfig.LogLevel, "NEWT_LOG_LEVEL")
+	envString(&config.Newt.DefaultConfig.HealthFile, "NEWT_HEALTH_FILE")
+	envString(&config.Newt.DefaultConfig.DockerSocket, "NEWT_DOCKER_SOCKET")
+	envBool(&config.Marketplace.Enabled, "MARKETPLACE_ENABLED")
+	envInt(&config.Marketplace.MinRatingsForDisplay, "MARKETPLACE_MIN_RATINGS")
+	envInt(&config.Marketplace.FeaturedTemplateCount, "MARKETPLACE_FEATURED_COUNT")
+	envSlice(&config.Marketplace.Categories, "MARKETPLACE_CATEGORIES")
+	envBool(&config.Marketplace.AllowAnonymousRatings, "MARKETPLACE_ALLOW_ANONYMOUS_RATINGS")
+	envBool(&config.Marketplace.ReviewModeration, "MARKETPLACE_REVIEW_MODERATION")
+	envBool(&config.Marketplace.RequireDeploymentToRate, "MARKETPLACE_REQUIRE_DEPLOYMENT_TO_RATE")
+	envInt(&config.Marketplace.FlakyMinDeployments, "MARKETPLACE_FLAKY_MIN_DEPLOYMENTS")
+	envInt(&config.Marketplace.FlakySuccessPercent, "MARKETPLACE_FLAKY_SUCCESS_PERCENT")
+	envInt(&config.Marketplace.TestTimeout, "MARKETPLACE_TEST_TIMEOUT")
+	envBool(&config.Marketplace.ContentPolicy.Enabled, "MARKETPLACE_CONTENT_POLICY_ENABLED")
+	envSlice(&config.Marketplace.ContentPolicy.BannedWords, "MARKETPLACE_BANNED_WORDS")
+	envInt(&config.Marketplace.ContentPolicy.MaxLinks, "MARKETPLACE_MAX_LINKS")
+	envInt(&config.Marketplace.ContentPolicy.MaxReviewLength, "MARKETPLACE_MAX_REVIEW_LENGTH")
+	envInt(&config.Marketplace.ContentPolicy.MaxDescriptionLength, "MARKETPLACE_MAX_DESCRIPTION_LENGTH")
+	envBool(&config.Backup.Enabled, "BACKUP_ENABLED")
+	envString(&config.Backup.Storage.Type, "BACKUP_STORAGE_TYPE")
+	envString(&config.Backup.Storage.Path, "BACKUP_STORAGE_PATH")
+	envString(&config.Backup.Storage.S3.Bucket, "S3_BACKUP_BUCKET")
+	envString(&config.Backup.Storage.S3.Region, "S3_REGION")
+	envString(&config.Backup.Storage.S3.AccessKey, "S3_ACCESS_KEY")
+	envString(&config.Backup.Storage.S3.SecretKey, "S3_SECRET_KEY")
+	envInt(&config.Backup.Retention.Daily, "BACKUP_RETENTION_DAILY")
+	envInt(&config.Backup.Retention.Weekly, "BACKUP_RETENTION_WEEKLY")
+	envInt(&config.Backup.Retention.Monthly, "BACKUP_RETENTION_MONTHLY")
+	envBool(&config.Backup.Safety.Enabled, "BACKUP_SAFETY_ENABLED")
+	envBool(&config.Backup.Safety.IncludeVolumes, "BACKUP_SAFETY_INCLUDE_VOLUMES")
+	envInt(&config.Backup.Safety.Keep, "BACKUP_SAFETY_KEEP")
+	envInt(&config.Backup.Safety.MaxAgeDays, "BACKUP_SAFETY_MAX_AGE_DAYS")
+	envBool(&config.Backup.Encryption.Enabled, "BACKUP_ENCRYPTION_ENABLED")
+	envString(&config.Backup.Encryption.KeyStorage, "BACKUP_KEY_STORAGE")
+	envString(&config.Backup.Encryption.KeyPath, "BACKUP_KEY_PATH")
+	envString(&config.Backup.Encryption.Passphrase, "BACKUP_PASSPHRASE")
+	envString(&config.Backup.Encryption.KMS.KeyID, "BACKUP_KMS_KEY_ID")
+	envString(&config.Backup.Encryption.KMS.Region, "BACKUP_KMS_REGION")
+	envString(&config.Backup.Encryption.Vault.Address, "VAULT_ADDR")
+	envString(&config.Backup.Encryption.Vault.Token, "VAULT_TOKEN")
+	envString(&config.Backup.Encryption.Vault.Mount, "BACKUP_VAULT_MOUNT")
+	envString(&config.Backup.Encryption.Vault.KeyName, "BACKUP_VAULT_KEY_NAME")
+	envBool(&config.Backup.Schedules.Daily.Enabled, "BACKUP_DAILY_ENABLED")
+	envString(&config.Backup.Schedules.Daily.Time, "BACKUP_DAILY_TIME")
+	envBool(&config.Backup.Schedules.Daily.IncludeVolumes, "BACKUP_DAILY_INCLUDE_VOLUMES")
+	envBool(&config.Backup.Schedules.Weekly.Enabled, "BACKUP_WEEKLY_ENABLED")
+	envString(&config.Backup.Schedules.Weekly.Day, "BACKUP_WEEKLY_DAY")
+	envString(&config.Backup.Schedules.Weekly.Time, "BACKUP_WEEKLY_TIME")
+	envBool(&config.Backup.Schedules.Weekly.IncludeVolumes, "BACKUP_WEEKLY_INCLUDE_VOLUMES")
+	envString(&config.GitHub.Token, "GITHUB_TOKEN")
+	envString(&config.GitHub.WebhookSecret, "GITHUB_WEBHOOK_SECRET")
+	envInt(&config.GitHub.SyncInterval, "GITHUB_SYNC_INTERVAL")
+	envInt64(&config.GitHub.AppID, "GITHUB_APP_ID")
+	envInt64(&config.GitHub.InstallationID, "GITHUB_APP_INSTALLATION_ID")
+	envString(&config.GitHub.AppPrivateKey, "GITHUB_APP_PRIVATE_KEY")
+	envString(&config.GitHub.AppPrivateKeyPath, "GITHUB_APP_PRIVATE_KEY_PATH")
+	envString(&config.Database.Type, "DATABASE_TYPE")
+	envString(&config.Database.Path, "DATABASE_PATH")
+	envBool(&config.Database.BackupEnabled, "DATABASE_BACKUP_ENABLED")
+	envInt(&config.Database.BackupInterval, "DATABASE_BACKUP_INTERVAL")
+	envString(&config.Templates.RepoURL, "TEMPLATES_REPO_URL")
+	envString(&config.Templates.Branch, "TEMPLATES_BRANCH")
+	envInt(&config.Templates.CacheDuration, "TEMPLATES_CACHE_DURATION")
+	envSlice(&config.Templates.AutoVerifyPublishers, "TEMPLATES_AUTO_VERIFY_PUBLISHERS")
+	envString(&config.Logging.Level, "LOG_LEVEL")
+	envString(&config.Logging.Format, "LOG_FORMAT")
+	envString(&config.Logging.Output, "LOG_OUTPUT")
+	envBool(&config.Logging.TracePropagation, "LOG_TRACE_PROPAGATION")
+	envInt(&config.Logging.DeploymentLogs.RetentionDays, "DEPLOYMENT_LOG_RETENTION_DAYS")
+	envInt(&config.Logging.DeploymentLogs.MaxRowsPerDeployment, "DEPLOYMENT_LOG_MAX_ROWS")
+	envInt(&config.Logging.DeploymentLogs.PruneInterval, "DEPLOYMENT_LOG_PRUNE_INTERVAL")
+	envString(&config.Logging.DeploymentLogs.ArchivePath, "DEPLOYMENT_LOG_ARCHIVE_PATH")
+	envBool(&config.Security.AuthEnabled, "AUTH_ENABLED")
+	envString(&config.Security.APIKey, "API_KEY")
+	envInt(&config.Security.SessionTimeout, "SESSION_TIMEOUT")
+	envBool(&config.Security.EncryptSecrets, "ENCRYPT_SECRETS")
+	envString(&config.Security.SecretKey, "SECRET_KEY")
+	envBool(&config.Security.RateLimiting.Enabled, "RATE_LIMITING_ENABLED")
+	envInt(&config.Security.RateLimiting.RequestsPerMinute, "RATE_LIMITING_RPM")
+	envInt(&config.Monitoring.DiskWarningPercent, "MONITORING_DISK_WARNING_PERCENT")
+	envInt(&config.Monitoring.DiskCriticalPercent, "MONITORING_DISK_CRITICAL_PERCENT")
+	envInt(&config.Monitoring.DiskCheckInterval, "MONITORING_DISK_CHECK_INTERVAL")
+	envInt(&config.Monitoring.EventRetentionDays, "MONITORING_EVENT_RETENTION_DAYS")
+	envInt(&config.Monitoring.MaxEventsPerStack, "MONITORING_MAX_EVENTS_PER_STACK")
+	envInt(&config.Monitoring.StatsInterval, "MONITORING_STATS_INTERVAL")
+	envBool(&config.Monitoring.RestartLoop.Enabled, "MONITORING_RESTART_LOOP_ENABLED")
+	envInt(&config.Monitoring.RestartLoop.MaxRestarts, "MONITORING_RESTART_LOOP_MAX_RESTARTS")
+	envInt(&config.Monitoring.RestartLoop.Window, "MONITORING_RESTART_LOOP_WINDOW")
+	envBool(&config.Monitoring.RestartLoop.StopService, "MONITORING_RESTART_LOOP_STOP_SERVICE")
+	envBool(&config.Monitoring.Uptime.Enabled, "MONITORING_UPTIME_ENABLED")
+	envInt(&config.Monitoring.Uptime.RetentionDays, "MONITORING_UPTIME_RETENTION_DAYS")
+	envInt(&config.Monitoring.Uptime.MaxConcurrent, "MONITORING_UPTIME_MAX_CONCURRENT")
+	envBool(&config.Monitoring.Footprint.Enabled, "MONITORING_FOOTPRINT_ENABLED")
+	envInt(&config.Monitoring.Footprint.SampleInterval, "MONITORING_FOOTPRINT_SAMPLE_INTERVAL")
+	envInt(&config.Monitoring.Footprint.RetentionDays, "MONITORING_FOOTPRINT_RETENTION_DAYS")
+	envInt(&config.Monitoring.Footprint.WattsPerCore, "MONITORING_FOOTPRINT_WATTS_PER_CORE")
+	envBool(&config.Webhooks.Enabled, "WEBHOOKS_ENABLED")
+	envInt(&config.Webhooks.PollInterval, "WEBHOOKS_POLL_INTERVAL")
+	envInt(&config.Webhooks.MaxAttempts, "WEBHOOKS_MAX_ATTEMPTS")
+	envInt(&config.Webhooks.Timeout, "WEBHOOKS_TIMEOUT")
+	envInt(&config.Webhooks.FlapWindow, "WEBHOOKS_FLAP_WINDOW")
+	envInt(&config.Webhooks.DedupWindow, "WEBHOOKS_DEDUP_WINDOW")
+	envBool(&config.Telemetry.Enabled, "TELEMETRY_ENABLED")
+	envString(&config.Telemetry.Endpoint, "TELEMETRY_ENDPOINT")
+	envInt(&config.Telemetry.Interval, "TELEMETRY_INTERVAL")
+	envBool(&config.Trash.Enabled, "TRASH_ENABLED")
+	envInt(&config.Trash.RetentionDays, "TRASH_RETENTION_DAYS")
+	envBool(&config.Quotas.Enabled, "QUOTAS_ENABLED")
+	envInt(&config.Quotas.Project.MaxDeployments, "QUOTAS_PROJECT_MAX_DEPLOYMENTS")
+	envInt(&config.Quotas.Project.MaxMemoryMB, "QUOTAS_PROJECT_MAX_MEMORY_MB")
+	envInt(&config.Quotas.Project.MaxBackupStorageMB, "QUOTAS_PROJECT_MAX_BACKUP_STORAGE_MB")
+	envInt(&config.Quotas.User.MaxDeployments, "QUOTAS_USER_MAX_DEPLOYMENTS")
+	envInt(&config.Quotas.User.MaxMemoryMB, "QUOTAS_USER_MAX_MEMORY_MB")
+	envInt(&config.Quotas.User.MaxBackupStorageMB, "QUOTAS_USER_MAX_BACKUP_STORAGE_MB")
+	envString(&config.Bus.Backend, "BUS_BACKEND")
+	envString(&config.Bus.Redis.Address, "REDIS_ADDRESS")
+	envString(&config.Bus.Redis.Password, "REDIS_PASSWORD")
+	envInt(&config.Bus.Redis.DB, "REDIS_DB")
+	envString(&config.Bus.Redis.KeyPrefix, "REDIS_KEY_PREFIX")
+	envInt64(&config.Bus.Redis.StreamMaxLen, "REDIS_STREAM_MAX_LEN")
+	envBool(&config.ACME.Enabled, "ACME_ENABLED")
+	envString(&config.ACME.Email, "ACME_EMAIL")
+	envString(&config.ACME.DirectoryURL, "ACME_DIRECTORY_URL")
+	envString(&config.ACME.StoragePath, "ACME_STORAGE_PATH")
+	envString(&config.ACME.Challenge, "ACME_CHALLENGE")
+	envString(&config.ACME.HTTPAddress, "ACME_HTTP_ADDRESS")
+	envString(&config.ACME.DNSProvider, "ACME_DNS_PROVIDER")
+	envInt(&config.ACME.RenewBeforeDays, "ACME_RENEW_BEFORE_DAYS")
+	envInt(&config.ACME.AlertBeforeDays, "ACME_ALERT_BEFORE_DAYS")
+}
+
+// Helper functions for environment variable parsing. Unset variables and values
+// that do not parse leave the setting unchanged
+func envString(target *string, key string) {
 	if value := os.Getenv(key); value != "" {
-		return value
+		*target = value
 	}
-	return defaultValue
 }
 
-func getEnvInt(key string, defaultValue int) int {
+func envInt(target *int, key string) {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+			*target = intValue
+		}
+	}
+}
+
+func envInt64(target *int64, key string) {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+			*target = intValue
 		}
 	}
-	return defaultValue
 }
 
-func getEnvBool(key string, defaultValue bool) bool {
+func envBool(target *bool, key string) {
 	if value := os.Getenv(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
-			return boolValue
+			*target = boolValue
 		}
 	}
-	return defaultValue
 }
 
-func getEnvSlice(key string, defaultValue []string) []string {
+func envSlice(target *[]string, key string) {
 	if value := os.Getenv(key); value != "" {
-		return strings.Split(value, ",")
+		*target = strings.Split(value, ",")
 	}
-	return defaultValue
-}
\ No newline at end of file
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// regexPrefix marks an allowed origin given as a regular expression
+const regexPrefix = "regex:"
+
+// OriginPattern is an allowed CORS origin. It is "*" for any origin, an exact
+// origin such as https://app.example.com, a wildcard subdomain such as
+// https://*.example.com, or a regular expression prefixed with "regex:" that must
+// match the whole origin
+type OriginPattern struct {
+	raw    string
+	scheme string         // Wildcard subdomains only, empty for any scheme
+	suffix string         // Wildcard subdomains only, the host after "*" with its port
+	re     *regexp.Regexp // Regular expressions only
+}
+
+// ParseOrigin parses an allowed origin of the configuration
+func ParseOrigin(pattern string) (*OriginPattern, error) {
+	p := &OriginPattern{raw: pattern}
+
+	switch {
+	case pattern == "*":
+		return p, nil
+
+	case strings.HasPrefix(pattern, regexPrefix):
+		expr := strings.TrimPrefix(pattern, regexPrefix)
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %v", err)
+		}
+		p.re = re
+		return p, nil
+
+	case strings.Contains(pattern, "*"):
+		rest := pattern
+		if i := strings.Index(rest, "://"); i >= 0 {
+			p.scheme, rest = rest[:i], rest[i+3:]
+			if p.scheme != "http" && p.scheme != "https" {
+				return nil, fmt.Errorf("scheme must be http or https")
+			}
+		}
+		if !strings.HasPrefix(rest, "*.") || strings.Count(rest, "*") > 1 {
+			return nil, fmt.Errorf(`a wildcard must be a whole leading label, as in https://*.example.com`)
+		}
+		p.suffix = rest[1:]
+		if strings.Count(p.suffix, ".") < 2 || strings.ContainsAny(p.suffix, "/?#") {
+			return nil, fmt.Errorf(`a wildcard must cover subdomains of a domain, as in https://*.example.com`)
+		}
+		return p, nil
+
+	default:
+		u, err := url.Parse(pattern)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("must be an origin as scheme://host[:port]")
+		}
+		if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return nil, fmt.Errorf("must be an origin without path, query or credentials")
+		}
+		return p, nil
+	}
+}
+
+// Any reports whether the pattern allows every origin
+func (p *OriginPattern) Any() bool {
+	return p.raw == "*"
+}
+
+// Match reports whether the pattern allows an origin sent by a browser
+func (p *OriginPattern) Match(origin string) bool {
+	origin = strings.ToLower(origin)
+	switch {
+	case p.Any():
+		return true
+	case p.re != nil:
+		return p.re.MatchString(origin)
+	case p.suffix != "":
+		rest := origin
+		if i := strings.Index(rest, "://"); i >= 0 {
+			if p.scheme != "" && rest[:i] != p.scheme {
+				return false
+			}
+			rest = rest[i+3:]
+		}
+		// The wildcard stands for at least one label, not the bare domain
+		return len(rest) > len(p.suffix) && strings.HasSuffix(rest, p.suffix)
+	default:
+		return origin == p.raw
+	}
+}
+
+// String returns the pattern as configured
+func (p *OriginPattern) String() string {
+	return p.raw
+}
+
+// normalize trims the configured origins to the form browsers send: lowercase,
+// without a trailing slash, each once. Regular expressions are kept as written
+func (c *CORSConfig) normalize() {
+	seen := make(map[string]bool, len(c.Origins))
+	origins := make([]string, 0, len(c.Origins))
+	for _, origin := range c.Origins {
+		origin = strings.TrimSpace(origin)
+		if !strings.HasPrefix(origin, regexPrefix) {
+			origin = strings.TrimRight(strings.ToLower(origin), "/")
+		}
+		if origin == "" || seen[origin] {
+			continue
+		}
+		seen[origin] = true
+		origins = append(origins, origin)
+	}
+	c.Origins = origins
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Reloader re-reads the configuration file while the server runs. Only the
+// reloadable sections take effect: the log level, rate limiting and marketplace
+// settings. Changes to anything else need a restart
+type Reloader struct {
+	path   string
+	config *Config
+	hooks  []func(*Config)
+	mu     sync.Mutex
+}
+
+// ReloadResult reports what a reload changed
+type ReloadResult struct {
+	Applied []string `json:"applied"`           // Reloadable settings that changed
+	Ignored []string `json:"ignored,omitempty"` // Sections that changed but need a restart
+}
+
+// NewReloader creates a reloader applying changes of the file at path to config,
+// the configuration the server runs with
+func NewReloader(path string, config *Config) *Reloader {
+	return &Reloader{path: path, config: config}
+}
+
+// Path returns the configuration file, empty when only environment variables are used
+func (r *Reloader) Path() string {
+	return r.path
+}
+
+// OnReload registers a hook run after every reload that changed a setting, for
+// components that copied a setting when they were created
+func (r *Reloader) OnReload(hook func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = append(r.hooks, hook)
+}
+
+// Reload loads the configuration again and applies its reloadable sections. An
+// invalid file changes nothing
+func (r *Reloader) Reload() (*ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := Load(r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReloadResult{Applied: []string{}}
+	if r.config.Logging.Level != next.Logging.Level {
+		result.Applied = append(result.Applied, "logging.level")
+	}
+	if r.config.Security.RateLimiting != next.Security.RateLimiting {
+		result.Applied = append(result.Applied, "security.rate_limiting")
+	}
+	if !reflect.DeepEqual(r.config.Marketplace, next.Marketplace) {
+		result.Applied = append(result.Applied, "marketplace")
+	}
+
+	// Compare the rest with the reloadable sections taken over, so only
+	// changes that were not applied are reported
+	rest := *next
+	rest.Logging.Level = r.config.Logging.Level
+	rest.Security.RateLimiting = r.config.Security.RateLimiting
+	rest.Marketplace = r.config.Marketplace
+	result.Ignored = changedSections(r.config, &rest)
+
+	// Handlers read these sections on every request, so they are updated in place
+	r.config.Logging.Level = next.Logging.Level
+	r.config.Security.RateLimiting = next.Security.RateLimiting
+	r.config.Marketplace = next.Marketplace
+
+	if len(result.Applied) > 0 {
+		for _, hook := range r.hooks {
+			hook(r.config)
+		}
+	}
+	return result, nil
+}
+
+// changedSections returns the YAML names of the top-level sections that differ
+func changedSections(current, next *Config) []string {
+	var changed []string
+	cv, nv := reflect.ValueOf(current).Elem(), reflect.ValueOf(next).Elem()
+	for i := 0; i < cv.NumField(); i++ {
+		if !reflect.DeepEqual(cv.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, sectionName(cv.Type().Field(i)))
+		}
+	}
+	return changed
+}
+
+func sectionName(field reflect.StructField) string {
+	if tag := field.Tag.Get("yaml"); tag != "" {
+		return tag
+	}
+	return field.Name
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every invalid setting of a configuration, named by their
+// YAML path, so all of them can be fixed in one go
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate checks the configuration for values the server cannot run with
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.check(c.Server.Port >= 1 && c.Server.Port <= 65535, "server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	if c.Server.CORS.Enabled {
+		v.cors(c.Server.CORS)
+	}
+	v.check(c.Server.WebSocket.MaxConnections >= 0, "server.websocket.max_connections", "must not be negative, got %d", c.Server.WebSocket.MaxConnections)
+	v.check(c.Server.WebSocket.MaxPerUser >= 0, "server.websocket.max_per_user", "must not be negative, got %d", c.Server.WebSocket.MaxPerUser)
+	v.check(c.Server.WebSocket.PingInterval > 0, "server.websocket.ping_interval", "must be a positive number of seconds, got %d", c.Server.WebSocket.PingInterval)
+	v.check(c.Server.WebSocket.WriteTimeout > 0, "server.websocket.write_timeout", "must be a positive number of seconds, got %d", c.Server.WebSocket.WriteTimeout)
+	if c.Server.GRPC.Enabled {
+		v.check(c.Server.GRPC.Port >= 1 && c.Server.GRPC.Port <= 65535, "server.grpc.port", "must be between 1 and 65535, got %d", c.Server.GRPC.Port)
+		v.check(c.Server.GRPC.Port != c.Server.Port, "server.grpc.port", "must differ from server.port")
+	}
+	v.check(c.Docker.ComposeTimeout > 0, "docker.compose_timeout", "must be a positive number of seconds, got %d", c.Docker.ComposeTimeout)
+	v.check(c.Docker.UpdateCheckInterval >= 0, "docker.update_check_interval", "must not be negative, got %d", c.Docker.UpdateCheckInterval)
+	if browser := c.Docker.VolumeBrowser; browser.Enabled {
+		v.check(browser.HelperImage != "", "docker.volume_browser.helper_image", "is required when the volume browser is enabled")
+		v.check(browser.MaxFileSizeMB > 0, "docker.volume_browser.max_file_size_mb", "must be positive, got %d", browser.MaxFileSizeMB)
+	}
+	if snapshots := c.Docker.VolumeSnapshots; snapshots.Enabled {
+		v.check(c.Docker.VolumeBrowser.HelperImage != "", "docker.volume_browser.helper_image", "is required when volume snapshots are enabled")
+		v.check(snapshots.StoragePath != "", "docker.volume_snapshots.storage_path", "is required when volume snapshots are enabled")
+		v.check(snapshots.MaxPerVolume >= 0, "docker.volume_snapshots.max_per_volume", "must not be negative, got %d", snapshots.MaxPerVolume)
+	}
+
+	v.check(c.Marketplace.MinRatingsForDisplay >= 0, "marketplace.min_ratings_for_display", "must not be negative, got %d", c.Marketplace.MinRatingsForDisplay)
+	v.check(c.Marketplace.FeaturedTemplateCount >= 0, "marketplace.featured_template_count", "must not be negative, got %d", c.Marketplace.FeaturedTemplateCount)
+	v.check(c.Marketplace.FlakySuccessPercent >= 0 && c.Marketplace.FlakySuccessPercent <= 100,
+		"marketplace.flaky_success_percent", "must be between 0 and 100, got %d", c.Marketplace.FlakySuccessPercent)
+	v.check(c.Marketplace.TestTimeout > 0, "marketplace.test_timeout", "must be positive, got %d", c.Marketplace.TestTimeout)
+	if policy := c.Marketplace.ContentPolicy; policy.Enabled {
+		v.check(policy.MaxLinks >= 0, "marketplace.content_policy.max_links", "must not be negative, got %d", policy.MaxLinks)
+		v.check(policy.MaxReviewLength >= 0, "marketplace.content_policy.max_review_length", "must not be negative, got %d", policy.MaxReviewLength)
+		v.check(policy.MaxDescriptionLength >= 0, "marketplace.content_policy.max_description_length",
+			"must not be negative, got %d", policy.MaxDescriptionLength)
+	}
+
+	v.oneOf(c.Backup.Storage.Type, "backup.storage.type", "local", "s3")
+	if c.Backup.Storage.Type == "s3" {
+		v.check(c.Backup.Storage.S3.Bucket != "", "backup.storage.s3.bucket", "is required with s3 storage")
+	}
+	v.check(c.Backup.Storage.Type != "local" || c.Backup.Storage.Path != "", "backup.storage.path", "is required with local storage")
+	if encryption := c.Backup.Encryption; encryption.Enabled {
+		v.oneOf(encryption.KeyStorage, "backup.encryption.key_storage", "local", "kms", "vault", "passphrase")
+		switch encryption.KeyStorage {
+		case "local":
+			v.check(encryption.KeyPath != "", "backup.encryption.key_path", "is required with local key storage")
+		case "kms":
+			v.check(encryption.KMS.KeyID != "", "backup.encryption.kms.key_id", "is required with kms key storage")
+		case "vault":
+			v.check(encryption.Vault.Address != "", "backup.encryption.vault.address", "is required with vault key storage")
+			v.check(encryption.Vault.Mount != "", "backup.encryption.vault.mount", "is required with vault key storage")
+			v.check(encryption.Vault.KeyName != "", "backup.encryption.vault.key_name", "is required with vault key storage")
+		}
+	}
+	v.clock(c.Backup.Schedules.Daily.Time, "backup.schedules.daily.time")
+	v.clock(c.Backup.Schedules.Weekly.Time, "backup.schedules.weekly.time")
+	if c.Backup.Schedules.Weekly.Enabled {
+		v.oneOf(strings.ToLower(c.Backup.Schedules.Weekly.Day), "backup.schedules.weekly.day",
+			"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday")
+	}
+
+	v.check(c.GitHub.SyncInterval >= 0, "github.sync_interval", "must not be negative, got %d", c.GitHub.SyncInterval)
+	v.check(c.GitHub.AppID == 0 || c.GitHub.InstallationID != 0, "github.installation_id", "is required with github.app_id")
+
+	v.oneOf(c.Database.Type, "database.type", "sqlite")
+	v.check(c.Database.Path != "", "database.path", "is required")
+
+	v.oneOf(strings.ToLower(c.Logging.Level), "logging.level", "debug", "info", "warn", "warning", "error")
+	v.oneOf(strings.ToLower(c.Logging.Format), "logging.format", "json", "text")
+
+	v.check(c.Security.SessionTimeout > 0, "security.session_timeout", "must be a positive number of seconds, got %d", c.Security.SessionTimeout)
+	if c.Security.RateLimiting.Enabled {
+		v.check(c.Security.RateLimiting.RequestsPerMinute > 0, "security.rate_limiting.requests_per_minute",
+			"must be positive when rate limiting is enabled, got %d", c.Security.RateLimiting.RequestsPerMinute)
+	}
+
+	v.check(c.Monitoring.DiskWarningPercent > 0 && c.Monitoring.DiskWarningPercent <= 100,
+		"monitoring.disk_warning_percent", "must be between 1 and 100, got %d", c.Monitoring.DiskWarningPercent)
+	v.check(c.Monitoring.DiskCriticalPercent >= c.Monitoring.DiskWarningPercent && c.Monitoring.DiskCriticalPercent <= 100,
+		"monitoring.disk_critical_percent", "must be between disk_warning_percent and 100, got %d", c.Monitoring.DiskCriticalPercent)
+	v.check(c.Monitoring.EventRetentionDays >= 0, "monitoring.event_retention_days", "must not be negative, got %d", c.Monitoring.EventRetentionDays)
+	v.check(c.Monitoring.MaxEventsPerStack >= 0, "monitoring.max_events_per_stack", "must not be negative, got %d", c.Monitoring.MaxEventsPerStack)
+	v.check(c.Monitoring.StatsInterval >= 0, "monitoring.stats_interval", "must not be negative, got %d", c.Monitoring.StatsInterval)
+	if c.Monitoring.RestartLoop.Enabled {
+		v.check(c.Monitoring.RestartLoop.MaxRestarts > 0, "monitoring.restart_loop.max_restarts",
+			"must be positive when restart loop detection is enabled, got %d", c.Monitoring.RestartLoop.MaxRestarts)
+		v.check(c.Monitoring.RestartLoop.Window > 0, "monitoring.restart_loop.window",
+			"must be a positive number of minutes, got %d", c.Monitoring.RestartLoop.Window)
+	}
+	if c.Monitoring.Uptime.Enabled {
+		v.check(c.Monitoring.Uptime.RetentionDays > 0, "monitoring.uptime.retention_days",
+			"must be a positive number of days, got %d", c.Monitoring.Uptime.RetentionDays)
+		v.check(c.Monitoring.Uptime.MaxConcurrent > 0, "monitoring.uptime.max_concurrent",
+			"must be positive, got %d", c.Monitoring.Uptime.MaxConcurrent)
+	}
+	if c.Monitoring.Footprint.Enabled {
+		v.check(c.Monitoring.Footprint.SampleInterval >= 10, "monitoring.footprint.sample_interval",
+			"must be at least 10 seconds, got %d", c.Monitoring.Footprint.SampleInterval)
+		v.check(c.Monitoring.Footprint.RetentionDays > 0, "monitoring.footprint.retention_days",
+			"must be a positive number of days, got %d", c.Monitoring.Footprint.RetentionDays)
+	}
+	v.check(c.Monitoring.Footprint.WattsPerCore >= 0, "monitoring.footprint.watts_per_core",
+		"must not be negative, got %d", c.Monitoring.Footprint.WattsPerCore)
+
+	if c.Webhooks.Enabled {
+		v.check(c.Webhooks.PollInterval > 0, "webhooks.poll_interval", "must be a positive number of seconds, got %d", c.Webhooks.PollInterval)
+		v.check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts", "must be positive, got %d", c.Webhooks.MaxAttempts)
+		v.check(c.Webhooks.FlapWindow >= 0, "webhooks.flap_window", "must not be negative, got %d", c.Webhooks.FlapWindow)
+		v.check(c.Webhooks.DedupWindow >= 0, "webhooks.dedup_window", "must not be negative, got %d", c.Webhooks.DedupWindow)
+	}
+	if c.Trash.Enabled {
+		v.check(c.Trash.RetentionDays > 0, "trash.retention_days", "must be a positive number of days, got %d", c.Trash.RetentionDays)
+	}
+	v.oneOf(c.Bus.Backend, "bus.backend", "memory", "redis")
+	if c.Bus.Backend == "redis" {
+		v.check(c.Bus.Redis.Address != "", "bus.redis.address", "is required with the redis backend")
+		v.check(c.Bus.Redis.StreamMaxLen > 0, "bus.redis.stream_max_len", "must be positive, got %d", c.Bus.Redis.StreamMaxLen)
+	}
+	v.quotaLimits(c.Quotas.Project, "quotas.project")
+	v.quotaLimits(c.Quotas.User, "quotas.user")
+	if acme := c.ACME; acme.Enabled {
+		v.check(acme.Email != "", "acme.email", "is required when ACME is enabled")
+		v.check(acme.DirectoryURL != "", "acme.directory_url", "is required when ACME is enabled")
+		v.check(acme.StoragePath != "", "acme.storage_path", "is required when ACME is enabled")
+		v.oneOf(acme.Challenge, "acme.challenge", "http-01", "dns-01")
+		if acme.Challenge == "dns-01" {
+			v.check(acme.DNSProvider != "", "acme.dns_provider", "is required with the dns-01 challenge")
+		}
+		v.check(acme.RenewBeforeDays > 0, "acme.renew_before_days", "must be a positive number of days, got %d", acme.RenewBeforeDays)
+		v.check(acme.AlertBeforeDays >= 0 && acme.AlertBeforeDays < acme.RenewBeforeDays, "acme.alert_before_days",
+			"must be between 0 and renew_before_days, got %d", acme.AlertBeforeDays)
+	}
+	if c.Telemetry.Enabled {
+		v.check(c.Telemetry.Endpoint != "", "telemetry.endpoint", "is required when telemetry is enabled")
+		v.check(c.Telemetry.Interval > 0, "telemetry.interval", "must be a positive number of seconds, got %d", c.Telemetry.Interval)
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validator collects the problems found by Validate
+type validator struct {
+	problems []string
+}
+
+func (v *validator) check(ok bool, key, format string, args ...interface{}) {
+	if !ok {
+		v.problems = append(v.problems, key+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) oneOf(value, key string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.problems = append(v.problems, fmt.Sprintf("%s: must be one of %s, got %q", key, strings.Join(allowed, ", "), value))
+}
+
+func (v *validator) clock(value, key string) {
+	if _, err := time.Parse("15:04", value); err != nil {
+		v.problems = append(v.problems, fmt.Sprintf("%s: must be a time as HH:MM, got %q", key, value))
+	}
+}
+
+// quotaLimits checks the limits of a quota, 0 being unlimited
+func (v *validator) quotaLimits(limits QuotaLimits, key string) {
+	v.check(limits.MaxDeployments >= 0, key+".max_deployments", "must not be negative, got %d", limits.MaxDeployments)
+	v.check(limits.MaxMemoryMB >= 0, key+".max_memory_mb", "must not be negative, got %d", limits.MaxMemoryMB)
+	v.check(limits.MaxBackupStorageMB >= 0, key+".max_backup_storage_mb", "must not be negative, got %d", limits.MaxBackupStorageMB)
+}
+
+// cors checks every allowed origin. Browsers refuse credentials with a wildcard
+// origin, and reflecting any origin instead would let every site act as the user
+func (v *validator) cors(c CORSConfig) {
+	v.check(len(c.Origins) > 0, "server.cors.origins", "must list at least one origin when CORS is enabled")
+	for _, origin := range c.Origins {
+		pattern, err := ParseOrigin(origin)
+		if err != nil {
+			v.problems = append(v.problems, fmt.Sprintf("server.cors.origins: %q: %v", origin, err))
+			continue
+		}
+		v.check(!pattern.Any() || !c.AllowCredentials, "server.cors.allow_credentials",
+			`must be false when origins include "*"; list the origins that need credentials instead`)
+	}
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"docker-deploy-app/internal/config"
+)
+
+// LogPruner enforces deployment log retention by age and by row count per
+// deployment, exporting rows to gzipped JSON lines archives before deleting them
+type LogPruner struct {
+	db     *sql.DB
+	config config.LogRetentionConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// archivedLog is a deployment log row as written to an archive
+type archivedLog struct {
+	ID        int64     `json:"id"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewLogPruner creates a new deployment log pruner
+func NewLogPruner(db *sql.DB, cfg config.LogRetentionConfig) *LogPruner {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &LogPruner{
+		db:     db,
+		config: cfg,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start begins periodic pruning
+func (lp *LogPruner) Start() {
+	interval := time.Duration(lp.config.PruneInterval) * time.Second
+	if interval <= 0 || (lp.config.RetentionDays <= 0 && lp.config.MaxRowsPerDeployment <= 0) {
+		return
+	}
+
+	slog.Info("Starting deployment log pruning", "interval", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if pruned, err := lp.Prune(); err != nil {
+					slog.Error("Deployment log pruning failed", "error", err)
+				} else if pruned > 0 {
+					slog.Info("Pruned deployment logs", "rows", pruned)
+				}
+			case <-lp.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops periodic pruning
+func (lp *LogPruner) Stop() {
+	lp.cancel()
+}
+
+// Prune removes expired log rows of every deployment and returns how many were deleted
+func (lp *LogPruner) Prune() (int64, error) {
+	rows, err := lp.db.QueryContext(lp.ctx, "SELECT DISTINCT deployment_id FROM deployment_logs")
+	if err != nil {
+		return 0, err
+	}
+
+	var deploymentIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			deploymentIDs = append(deploymentIDs, id)
+		}
+	}
+	rows.Close()
+
+	var total int64
+	for _, id := range deploymentIDs {
+		pruned, err := lp.pruneDeployment(id)
+		if err != nil {
+			return total, fmt.Errorf("deployment %s: %w", id, err)
+		}
+		total += pruned
+	}
+	return total, nil
+}
+
+// pruneDeployment archives and deletes the rows of one deployment that are older than
+// the retention period or beyond the newest MaxRowsPerDeployment rows
+func (lp *LogPruner) pruneDeployment(deploymentID string) (int64, error) {
+	// Rows with an ID up to cutoffID exceed the row cap
+	var cutoffID int64
+	if lp.config.MaxRowsPerDeployment > 0 {
+		err := lp.db.QueryRowContext(lp.ctx, `
+			SELECT id FROM deployment_logs
+			WHERE deployment_id = $1
+			ORDER BY id DESC
+			LIMIT 1 OFFSET $2`, deploymentID, lp.config.MaxRowsPerDeployment).Scan(&cutoffID)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, err
+		}
+	}
+
+	// A retention of 0 days keeps rows regardless of age
+	where := `deployment_id = $1 AND id <= $2`
+	args := []interface{}{deploymentID, cutoffID}
+	if lp.config.RetentionDays > 0 {
+		where = `deployment_id = $1 AND (julianday(timestamp) < julianday('now', $2) OR id <= $3)`
+		args = []interface{}{deploymentID, fmt.Sprintf("-%d days", lp.config.RetentionDays), cutoffID}
+	} else if cutoffID == 0 {
+		return 0, nil
+	}
+
+	if lp.config.ArchivePath != "" {
+		if err := lp.archive(deploymentID, where, args); err != nil {
+			return 0, fmt.Errorf("failed to archive logs: %w", err)
+		}
+	}
+
+	result, err := lp.db.ExecContext(lp.ctx, "DELETE FROM deployment_logs WHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// archive writes the rows matching where to a new archive file of the deployment.
+// Nothing is written when no rows match
+func (lp *LogPruner) archive(deploymentID, where string, args []interface{}) error {
+	rows, err := lp.db.QueryContext(lp.ctx,
+		"SELECT id, log_level, message, timestamp FROM deployment_logs WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var file *os.File
+	var writer *gzip.Writer
+	var encoder *json.Encoder
+	for rows.Next() {
+		var entry archivedLog
+		var level, message sql.NullString
+		if err := rows.Scan(&entry.ID, &level, &message, &entry.Timestamp); err != nil {
+			return err
+		}
+		entry.Level, entry.Message = level.String, message.String
+
+		if file == nil {
+			dir := filepath.Join(lp.config.ArchivePath, deploymentID)
+			if err := os.MkdirAll(dir, 0750); err != nil {
+				return err
+			}
+			name := fmt.Sprintf("%s.jsonl.gz", time.Now().UTC().Format("20060102T150405Z"))
+			if file, err = os.Create(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+			defer file.Close()
+			writer = gzip.NewWriter(file)
+			encoder = json.NewEncoder(writer)
+		}
+
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if writer != nil {
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		return file.Sync()
+	}
+	return nil
+}
@@ -0,0 +1,4 @@
+-- Deploy mode per deployment: 'compose' (docker compose) or 'swarm' (docker stack deploy)
+ALTER TABLE deployments ADD COLUMN deploy_mode TEXT CHECK(deploy_mode IN ('compose', 'swarm')) DEFAULT 'compose';
+
+CREATE INDEX IF NOT EXISTS idx_deployments_deploy_mode ON deployments(deploy_mode);
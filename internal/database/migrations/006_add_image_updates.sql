@@ -0,0 +1,14 @@
+-- Registry update status per deployed service image
+CREATE TABLE IF NOT EXISTS image_updates (
+    deployment_id TEXT NOT NULL,
+    service_name TEXT NOT NULL,
+    image TEXT NOT NULL,
+    current_digest TEXT,
+    latest_digest TEXT,
+    update_available BOOLEAN DEFAULT 0,
+    checked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (deployment_id, service_name),
+    FOREIGN KEY (deployment_id) REFERENCES deployments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_image_updates_available ON image_updates(update_available);
@@ -0,0 +1,20 @@
+-- Automatic update policy per deployment
+ALTER TABLE deployments ADD COLUMN update_policy TEXT CHECK(update_policy IN ('pinned', 'patch', 'any')) DEFAULT 'pinned';
+ALTER TABLE deployments ADD COLUMN update_schedule TEXT;
+
+-- Image upgrades applied to a deployment
+CREATE TABLE IF NOT EXISTS deployment_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    deployment_id TEXT NOT NULL,
+    revision INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    services TEXT, -- JSON array of upgraded services
+    previous_images TEXT, -- JSON object of service to previous image ID
+    status TEXT CHECK(status IN ('applied', 'rolled_back', 'failed')) NOT NULL,
+    message TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (deployment_id) REFERENCES deployments(id) ON DELETE CASCADE,
+    UNIQUE(deployment_id, revision)
+);
+
+CREATE INDEX IF NOT EXISTS idx_deployment_revisions_deployment ON deployment_revisions(deployment_id);
@@ -0,0 +1,12 @@
+-- Newt tunnel state transitions per deployment
+CREATE TABLE IF NOT EXISTS newt_tunnel_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    deployment_id TEXT NOT NULL,
+    previous_state TEXT,
+    state TEXT CHECK(state IN ('connected', 'disconnected', 'stopped', 'missing')) NOT NULL,
+    message TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (deployment_id) REFERENCES deployments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_newt_tunnel_events_deployment ON newt_tunnel_events(deployment_id, created_at);
@@ -0,0 +1,3 @@
+-- Tunnel sidecar provider per template (default) and per deployment
+ALTER TABLE templates ADD COLUMN tunnel_provider TEXT CHECK(tunnel_provider IN ('newt', 'cloudflared', 'tailscale')) DEFAULT 'newt';
+ALTER TABLE deployments ADD COLUMN tunnel_provider TEXT CHECK(tunnel_provider IN ('newt', 'cloudflared', 'tailscale')) DEFAULT 'newt';
@@ -0,0 +1,11 @@
+-- Explicit template discovery sources (organizations, users, repositories, topics)
+CREATE TABLE IF NOT EXISTS github_sources (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT CHECK(type IN ('org', 'user', 'repo', 'topic')) NOT NULL,
+    value TEXT NOT NULL,
+    include_patterns TEXT DEFAULT '[]', -- JSON array of globs on owner/repo
+    exclude_patterns TEXT DEFAULT '[]', -- JSON array of globs on owner/repo
+    enabled BOOLEAN DEFAULT TRUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(type, value)
+);
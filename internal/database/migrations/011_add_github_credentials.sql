@@ -0,0 +1,8 @@
+-- GitHub token connected through the API (single row)
+CREATE TABLE IF NOT EXISTS github_credentials (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    token TEXT NOT NULL, -- Encrypted with SECRET_KEY when secret encryption is enabled
+    login TEXT NOT NULL,
+    scopes TEXT DEFAULT '[]', -- JSON array of OAuth scopes
+    connected_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
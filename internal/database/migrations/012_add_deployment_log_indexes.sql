@@ -0,0 +1,3 @@
+-- Time ordered and level filtered deployment log queries
+CREATE INDEX IF NOT EXISTS idx_deployment_logs_deployment_time ON deployment_logs(deployment_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_deployment_logs_deployment_level ON deployment_logs(deployment_id, log_level, timestamp);
@@ -0,0 +1,17 @@
+-- Background operations (deployments, backups, restores, syncs) for the activity feed
+CREATE TABLE IF NOT EXISTS tasks (
+    id TEXT PRIMARY KEY,
+    type TEXT NOT NULL, -- deployment, backup, restore, github_sync
+    state TEXT CHECK(state IN ('pending', 'running', 'succeeded', 'failed')) DEFAULT 'pending',
+    progress INTEGER DEFAULT 0, -- percent complete
+    message TEXT DEFAULT '',
+    error TEXT DEFAULT '',
+    resource_id TEXT DEFAULT '', -- ID of the deployment, backup, etc. the task operates on
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    started_at DATETIME,
+    finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_tasks_created ON tasks(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_tasks_state ON tasks(state);
+CREATE INDEX IF NOT EXISTS idx_tasks_resource ON tasks(resource_id);
@@ -0,0 +1,3 @@
+-- Concurrency token of a deployment's desired state, bumped on every configuration
+-- change (not on status changes) and exposed as its ETag
+ALTER TABLE deployments ADD COLUMN resource_version INTEGER DEFAULT 1;
@@ -0,0 +1,28 @@
+-- Outgoing webhooks for lifecycle events
+CREATE TABLE IF NOT EXISTS webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL, -- HMAC-SHA256 signing key
+    events TEXT NOT NULL DEFAULT '[]', -- JSON array of subscribed events
+    enabled BOOLEAN DEFAULT TRUE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Outbox of webhook deliveries, written with the event and sent asynchronously
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL, -- JSON body as sent
+    status TEXT CHECK(status IN ('pending', 'delivered', 'failed')) DEFAULT 'pending',
+    attempts INTEGER DEFAULT 0,
+    next_attempt_at DATETIME,
+    response_status INTEGER DEFAULT 0,
+    error TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    delivered_at DATETIME,
+    FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at DESC);
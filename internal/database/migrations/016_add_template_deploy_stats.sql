@@ -0,0 +1,13 @@
+-- Outcome of every finished deployment, kept after the deployment is deleted so
+-- template statistics cover its whole history
+CREATE TABLE IF NOT EXISTS template_deploy_outcomes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    template_id TEXT NOT NULL,
+    deployment_id TEXT NOT NULL,
+    succeeded BOOLEAN NOT NULL,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    failure_reason TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_deploy_outcomes_template ON template_deploy_outcomes(template_id, created_at);
@@ -0,0 +1,2 @@
+-- Deployment selectors of backup schedules, evaluated when a schedule runs
+ALTER TABLE backup_schedules ADD COLUMN selector TEXT DEFAULT '{}';
@@ -0,0 +1,2 @@
+-- Time zone a backup schedule's cron expression is evaluated in
+ALTER TABLE backup_schedules ADD COLUMN timezone TEXT DEFAULT '';
@@ -0,0 +1,24 @@
+-- Projects group deployments for teams sharing one instance
+CREATE TABLE IF NOT EXISTS projects (
+    id TEXT PRIMARY KEY, -- Slug, also used in URLs and filters
+    name TEXT NOT NULL,
+    description TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT OR IGNORE INTO projects (id, name, description) VALUES ('global', 'Global', 'Default project');
+
+-- Per-project roles, replacing a member's global role within the project
+CREATE TABLE IF NOT EXISTS project_members (
+    project_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    role TEXT CHECK(role IN ('viewer', 'operator', 'admin')) NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, user_id),
+    FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE deployments ADD COLUMN project_id TEXT DEFAULT 'global';
+
+CREATE INDEX IF NOT EXISTS idx_deployments_project ON deployments(project_id);
@@ -0,0 +1,16 @@
+-- Lineage of deployments promoted between projects, kept for audit
+CREATE TABLE IF NOT EXISTS deployment_promotions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source_deployment_id TEXT NOT NULL,
+    target_deployment_id TEXT NOT NULL,
+    source_project_id TEXT NOT NULL,
+    target_project_id TEXT NOT NULL,
+    template_id TEXT NOT NULL,
+    template_version TEXT DEFAULT '',
+    overridden_keys TEXT DEFAULT '[]', -- JSON array of environment keys overridden, never their values
+    promoted_by TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_deployment_promotions_source ON deployment_promotions(source_deployment_id);
+CREATE INDEX IF NOT EXISTS idx_deployment_promotions_target ON deployment_promotions(target_deployment_id);
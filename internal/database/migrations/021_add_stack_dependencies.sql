@@ -0,0 +1,14 @@
+-- Stacks that must be running before a stack starts, within one project
+CREATE TABLE IF NOT EXISTS stack_dependencies (
+    deployment_id TEXT NOT NULL,
+    depends_on_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (deployment_id, depends_on_id),
+    FOREIGN KEY (deployment_id) REFERENCES deployments(id) ON DELETE CASCADE,
+    FOREIGN KEY (depends_on_id) REFERENCES deployments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_stack_dependencies_depends_on ON stack_dependencies(depends_on_id);
+
+-- Topological start order of the project's stacks, a JSON array of deployment IDs
+ALTER TABLE projects ADD COLUMN stack_order TEXT DEFAULT '[]';
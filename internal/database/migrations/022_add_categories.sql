@@ -0,0 +1,24 @@
+-- Marketplace categories, managed by admins. Keywords are matched against a
+-- repository's name and description to categorize templates on discovery
+CREATE TABLE IF NOT EXISTS categories (
+    name TEXT PRIMARY KEY, -- Slug stored in templates.category
+    display_name TEXT NOT NULL,
+    description TEXT DEFAULT '',
+    icon TEXT DEFAULT '',
+    keywords TEXT DEFAULT '[]', -- JSON array
+    position INTEGER DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_categories_position ON categories(position);
+
+INSERT OR IGNORE INTO categories (name, display_name, icon, keywords, position) VALUES
+    ('web', 'Web', '🌐', '["web","website","frontend","react","vue","angular","nextjs","nuxt","nginx","apache"]', 0),
+    ('database', 'Database', '🗄️', '["database","db","mysql","postgres","mongodb","redis","elasticsearch"]', 1),
+    ('monitoring', 'Monitoring', '📊', '["monitoring","metrics","grafana","prometheus","alertmanager","jaeger"]', 2),
+    ('networking', 'Networking', '🌐', '["network","proxy","load-balancer","traefik","caddy","haproxy"]', 3),
+    ('development', 'Development', '🛠️', '["dev","development","testing","ci","cd","jenkins","gitlab"]', 4),
+    ('ai-ml', 'AI & ML', '🤖', '["ai","ml","machine-learning","tensorflow","pytorch","jupyter"]', 5),
+    ('security', 'Security', '🔒', '["security","auth","oauth","keycloak","vault","ssl","cert"]', 6),
+    ('analytics', 'Analytics', '📈', '["analytics","data","spark","kafka","elastic","kibana"]', 7);
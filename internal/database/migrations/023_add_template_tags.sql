@@ -0,0 +1,15 @@
+-- Template tags, one row per tag, so templates can be filtered and tags counted
+-- without scanning the JSON in templates.tags. Tags are stored lowercase
+CREATE TABLE IF NOT EXISTS template_tags (
+    template_id TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (template_id, tag),
+    FOREIGN KEY (template_id) REFERENCES templates(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_tags_tag ON template_tags(tag);
+
+INSERT OR IGNORE INTO template_tags (template_id, tag)
+SELECT t.id, LOWER(TRIM(j.value))
+FROM templates t, json_each(t.tags) j
+WHERE json_valid(t.tags) AND j.type = 'text' AND TRIM(j.value) != '';
@@ -0,0 +1,29 @@
+-- Installs of a template, one row per installer: the user who deployed it, or
+-- 'instance' for deployments made without a signed-in user. download_count keeps
+-- counting every deployment; unique_installs counts installers
+CREATE TABLE IF NOT EXISTS template_installs (
+    template_id TEXT NOT NULL,
+    installer TEXT NOT NULL,
+    deploys INTEGER NOT NULL DEFAULT 1,
+    first_installed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    last_installed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (template_id, installer),
+    FOREIGN KEY (template_id) REFERENCES templates(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_installs_last ON template_installs(last_installed_at);
+
+ALTER TABLE templates ADD COLUMN unique_installs INTEGER DEFAULT 0;
+
+CREATE TRIGGER IF NOT EXISTS increment_unique_installs
+AFTER INSERT ON template_installs
+BEGIN
+    UPDATE templates SET unique_installs = unique_installs + 1 WHERE id = NEW.template_id;
+END;
+
+-- Existing deployments have no known installer; they count as this instance
+INSERT OR IGNORE INTO template_installs (template_id, installer, deploys, first_installed_at, last_installed_at)
+SELECT template_id, 'instance', COUNT(*), MIN(created_at), MAX(created_at)
+FROM deployments
+WHERE template_id IN (SELECT id FROM templates)
+GROUP BY template_id;
@@ -0,0 +1,14 @@
+-- Rolling deployment and install counts per template, refreshed by a background
+-- job so the trending endpoint does not aggregate deployments on every request
+CREATE TABLE IF NOT EXISTS template_stats (
+    template_id TEXT PRIMARY KEY,
+    deploys_7d INTEGER NOT NULL DEFAULT 0,
+    deploys_30d INTEGER NOT NULL DEFAULT 0,
+    installs_7d INTEGER NOT NULL DEFAULT 0,
+    installs_30d INTEGER NOT NULL DEFAULT 0,
+    refreshed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (template_id) REFERENCES templates(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_stats_installs_7d ON template_stats(installs_7d);
+CREATE INDEX IF NOT EXISTS idx_template_stats_installs_30d ON template_stats(installs_30d);
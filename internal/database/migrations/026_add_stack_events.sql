@@ -0,0 +1,15 @@
+-- Container lifecycle events per deployment, recorded by the Docker monitor so
+-- they outlive the WebSocket subscribers they are streamed to
+CREATE TABLE IF NOT EXISTS stack_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    deployment_id TEXT NOT NULL,
+    service_name TEXT,
+    container_id TEXT NOT NULL,
+    action TEXT CHECK(action IN ('start', 'stop', 'die', 'oom')) NOT NULL,
+    exit_code INTEGER,
+    image TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (deployment_id) REFERENCES deployments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_stack_events_deployment ON stack_events(deployment_id, created_at);
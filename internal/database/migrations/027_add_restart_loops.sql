@@ -0,0 +1,15 @@
+-- Services flagged for restarting repeatedly. A deployment with an uncleared
+-- loop is degraded
+CREATE TABLE IF NOT EXISTS restart_loops (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    deployment_id TEXT NOT NULL,
+    service_name TEXT NOT NULL,
+    restarts INTEGER NOT NULL,
+    window_minutes INTEGER NOT NULL,
+    stopped BOOLEAN DEFAULT FALSE,
+    detected_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    cleared_at DATETIME,
+    FOREIGN KEY (deployment_id) REFERENCES deployments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_restart_loops_deployment ON restart_loops(deployment_id, cleared_at);
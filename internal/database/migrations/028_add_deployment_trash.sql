@@ -0,0 +1,18 @@
+-- Deleted deployments kept restorable until expires_at. The deployments row is
+-- stored as JSON, the compose files are moved under deployments/.trash and the
+-- stack's volumes are left in place
+CREATE TABLE IF NOT EXISTS deployment_trash (
+    id TEXT PRIMARY KEY,
+    stack_name TEXT NOT NULL,
+    project_id TEXT,
+    template_id TEXT,
+    deploy_mode TEXT,
+    deployment TEXT NOT NULL, -- JSON object of the deployments row
+    volumes TEXT, -- JSON array of volume names
+    deleted_by TEXT,
+    deleted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_deployment_trash_expires ON deployment_trash(expires_at);
+CREATE INDEX IF NOT EXISTS idx_deployment_trash_stack ON deployment_trash(stack_name);
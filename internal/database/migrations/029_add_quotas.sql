@@ -0,0 +1,16 @@
+-- Quota limits of a project or user, replacing the configured defaults. NULL
+-- keeps the default of a limit and 0 is unlimited
+CREATE TABLE IF NOT EXISTS quotas (
+    scope TEXT CHECK(scope IN ('project', 'user')) NOT NULL,
+    scope_id TEXT NOT NULL,
+    max_deployments INTEGER,
+    max_memory_mb INTEGER,
+    max_backup_storage_mb INTEGER,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (scope, scope_id)
+);
+
+-- The user who created a deployment, counted against their quota
+ALTER TABLE deployments ADD COLUMN created_by TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_deployments_created_by ON deployments(created_by);
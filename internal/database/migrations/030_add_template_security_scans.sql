@@ -0,0 +1,13 @@
+-- Security lint findings of each synced version of a template
+CREATE TABLE IF NOT EXISTS template_security_scans (
+    template_id TEXT NOT NULL,
+    version TEXT NOT NULL,
+    badge TEXT CHECK(badge IN ('passed', 'warning', 'critical', 'unscanned')) NOT NULL,
+    findings TEXT, -- JSON array of findings
+    scanned_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (template_id, version),
+    FOREIGN KEY (template_id) REFERENCES templates(id) ON DELETE CASCADE
+);
+
+-- Badge of the latest scan, shown in marketplace listings
+ALTER TABLE templates ADD COLUMN security_badge TEXT DEFAULT 'unscanned';
@@ -0,0 +1,18 @@
+-- Public keys trusted to sign the template manifests of a publisher
+CREATE TABLE IF NOT EXISTS publisher_keys (
+    id TEXT PRIMARY KEY,
+    publisher_id TEXT NOT NULL, -- GitHub owner, lowercase
+    key_type TEXT CHECK(key_type IN ('cosign', 'minisign')) NOT NULL,
+    public_key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    description TEXT,
+    created_by TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (publisher_id, fingerprint)
+);
+
+CREATE INDEX IF NOT EXISTS idx_publisher_keys_publisher ON publisher_keys(publisher_id);
+
+-- Key that verified the template manifest on the last sync, so revoking a key
+-- unverifies the templates it signed
+ALTER TABLE templates ADD COLUMN verified_key_id TEXT;
@@ -0,0 +1,22 @@
+-- Reviews and template descriptions that broke the content policy, awaiting a
+-- moderator. The same text is queued once per item
+CREATE TABLE IF NOT EXISTS moderation_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    content_type TEXT CHECK(content_type IN ('review', 'template_description')) NOT NULL,
+    template_id TEXT NOT NULL,
+    author_id TEXT NOT NULL DEFAULT '', -- Reviewer; empty for template descriptions
+    content TEXT NOT NULL,
+    content_hash TEXT NOT NULL,
+    violations TEXT NOT NULL, -- JSON array of policy violations
+    status TEXT CHECK(status IN ('pending', 'approved', 'rejected')) DEFAULT 'pending',
+    reviewed_by TEXT,
+    reviewed_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (content_type, template_id, author_id, content_hash),
+    FOREIGN KEY (template_id) REFERENCES templates(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_queue_status ON moderation_queue(status, created_at);
+
+-- Reviews held for moderation are not listed
+ALTER TABLE template_ratings ADD COLUMN moderation_status TEXT DEFAULT 'approved';
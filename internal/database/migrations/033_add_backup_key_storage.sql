@@ -0,0 +1,5 @@
+-- Where the key of an encrypted backup is kept, and what that storage needs to
+-- recover it, such as the data key wrapped by KMS or Vault. Backups from before
+-- this migration keep their keys in local storage
+ALTER TABLE backups ADD COLUMN key_storage TEXT DEFAULT '';
+ALTER TABLE backups ADD COLUMN key_metadata TEXT DEFAULT '';
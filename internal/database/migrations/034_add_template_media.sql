@@ -0,0 +1,16 @@
+-- Screenshots and other images of templates, from the media section of
+-- .template.json. The images are cached under data/media/<template_id>, named by
+-- file; etag is the blob SHA of repository images, to skip unchanged ones
+CREATE TABLE IF NOT EXISTS template_media (
+    template_id TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    source TEXT NOT NULL, -- Image URL or repository-relative path
+    caption TEXT DEFAULT '',
+    file TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    etag TEXT DEFAULT '',
+    synced_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (template_id, position),
+    FOREIGN KEY (template_id) REFERENCES templates(id) ON DELETE CASCADE
+);
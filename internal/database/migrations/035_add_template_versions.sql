@@ -0,0 +1,19 @@
+-- Released versions of templates, from the GitHub releases of their repository,
+-- or its tags when it publishes no releases. notes holds the release notes as
+-- Markdown; tags carry none and have no published_at
+CREATE TABLE IF NOT EXISTS template_versions (
+    template_id TEXT NOT NULL,
+    version TEXT NOT NULL, -- Tag name without a leading v
+    tag_name TEXT NOT NULL,
+    name TEXT DEFAULT '',
+    notes TEXT DEFAULT '',
+    url TEXT DEFAULT '',
+    prerelease BOOLEAN DEFAULT FALSE,
+    published_at DATETIME,
+    position INTEGER NOT NULL, -- 0 for the newest, as listed by GitHub
+    synced_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (template_id, version),
+    FOREIGN KEY (template_id) REFERENCES templates(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_versions_position ON template_versions(template_id, position);
@@ -0,0 +1,5 @@
+-- License of templates, an SPDX identifier from .template.json, the repository
+-- metadata or its LICENSE file, and the minimum cpu, memory and disk from the
+-- resources section of .template.json, as JSON
+ALTER TABLE templates ADD COLUMN license TEXT DEFAULT '';
+ALTER TABLE templates ADD COLUMN resources TEXT DEFAULT '';
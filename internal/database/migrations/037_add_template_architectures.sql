@@ -0,0 +1,4 @@
+-- Architectures templates run on, as JSON, such as ["amd64","arm64"]: those
+-- every image of the compose file is published for, read from the registry
+-- manifests on sync. Empty when unknown, [] when no architecture fits all images
+ALTER TABLE templates ADD COLUMN architectures TEXT DEFAULT '';
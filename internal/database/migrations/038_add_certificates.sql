@@ -0,0 +1,20 @@
+-- TLS certificates obtained over ACME for services exposed directly on host
+-- ports. Their certificate and key files are kept under acme.storage_path by ID;
+-- deployments serve them through a TLS sidecar
+CREATE TABLE IF NOT EXISTS certificates (
+    id TEXT PRIMARY KEY,
+    domains TEXT NOT NULL, -- JSON array, the first being the common name
+    challenge TEXT NOT NULL, -- http-01 or dns-01
+    status TEXT NOT NULL DEFAULT 'pending',
+    issuer TEXT DEFAULT '',
+    not_before DATETIME,
+    not_after DATETIME,
+    last_error TEXT DEFAULT '',
+    renewed_at DATETIME,
+    alerted_at DATETIME, -- Last expiry alert, so they go out once a day at most
+    created_by TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_certificates_not_after ON certificates(not_after);
@@ -0,0 +1,15 @@
+-- Snapshots of single stack volumes, archived under
+-- docker.volume_snapshots.storage_path. They are not tied to the deployment row
+-- by a foreign key, so a trashed deployment keeps its snapshots when restored
+CREATE TABLE IF NOT EXISTS volume_snapshots (
+    id TEXT PRIMARY KEY,
+    deployment_id TEXT NOT NULL,
+    volume_name TEXT NOT NULL,
+    note TEXT DEFAULT '',
+    size_bytes INTEGER DEFAULT 0,
+    storage_path TEXT NOT NULL,
+    created_by TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_volume_snapshots_volume ON volume_snapshots(deployment_id, volume_name, created_at);
@@ -0,0 +1,4 @@
+-- Backup hooks templates declare, as JSON: commands dumping the data of a
+-- service, such as a database, into backups and loading it back on restore.
+-- Empty when the template declares none
+ALTER TABLE templates ADD COLUMN backup_hooks TEXT DEFAULT '';
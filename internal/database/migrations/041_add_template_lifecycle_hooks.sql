@@ -0,0 +1,4 @@
+-- Lifecycle hooks templates declare, as JSON: commands run before or after a
+-- stack is deployed, or before it is torn down. Empty when the template
+-- declares none; deployments add their own in their configuration
+ALTER TABLE templates ADD COLUMN lifecycle_hooks TEXT DEFAULT '';
@@ -0,0 +1,4 @@
+-- Config files templates declare, as JSON with the content of each fetched at
+-- sync time: files rendered from deployment variables into the config
+-- directory of a deployment. Empty when the template declares none
+ALTER TABLE templates ADD COLUMN config_files TEXT DEFAULT '';
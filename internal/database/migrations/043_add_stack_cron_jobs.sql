@@ -0,0 +1,37 @@
+-- Commands run on a schedule in a service container of a stack
+CREATE TABLE IF NOT EXISTS stack_cron_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    deployment_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    schedule TEXT NOT NULL,
+    timezone TEXT DEFAULT '',
+    service TEXT NOT NULL,
+    command TEXT NOT NULL,
+    timeout INTEGER NOT NULL DEFAULT 300,
+    enabled BOOLEAN DEFAULT TRUE,
+    alert_on_failure BOOLEAN DEFAULT TRUE,
+    last_run_at DATETIME,
+    last_status TEXT DEFAULT '',
+    created_by TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (deployment_id) REFERENCES deployments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_stack_cron_jobs_deployment ON stack_cron_jobs(deployment_id);
+
+-- Run history of cron jobs; the oldest runs of a job are pruned
+CREATE TABLE IF NOT EXISTS stack_cron_job_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id INTEGER NOT NULL,
+    triggered_by TEXT NOT NULL,
+    status TEXT NOT NULL,
+    exit_code INTEGER,
+    output TEXT DEFAULT '',
+    error TEXT DEFAULT '',
+    started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    FOREIGN KEY (job_id) REFERENCES stack_cron_jobs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_stack_cron_job_runs_job ON stack_cron_job_runs(job_id, started_at);
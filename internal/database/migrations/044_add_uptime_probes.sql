@@ -0,0 +1,38 @@
+-- HTTP and TCP probes checking that deployments answer, with the state of
+-- their latest checks
+CREATE TABLE IF NOT EXISTS uptime_probes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    deployment_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    target TEXT NOT NULL,
+    interval_seconds INTEGER NOT NULL DEFAULT 60,
+    timeout_seconds INTEGER NOT NULL DEFAULT 10,
+    expected_status INTEGER DEFAULT 0,
+    keyword TEXT DEFAULT '',
+    failure_threshold INTEGER NOT NULL DEFAULT 3,
+    enabled BOOLEAN DEFAULT TRUE,
+    status TEXT DEFAULT 'unknown',
+    consecutive_failures INTEGER DEFAULT 0,
+    last_check_at DATETIME,
+    last_latency_ms INTEGER DEFAULT 0,
+    last_error TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (deployment_id) REFERENCES deployments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_uptime_probes_deployment ON uptime_probes(deployment_id);
+
+-- Check history of probes, pruned after monitoring.uptime.retention_days
+CREATE TABLE IF NOT EXISTS uptime_checks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    probe_id INTEGER NOT NULL,
+    up BOOLEAN NOT NULL,
+    latency_ms INTEGER NOT NULL,
+    status_code INTEGER DEFAULT 0,
+    error TEXT DEFAULT '',
+    checked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (probe_id) REFERENCES uptime_probes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_uptime_checks_probe ON uptime_checks(probe_id, checked_at);
@@ -0,0 +1,8 @@
+-- Per-webhook quiet hours, as JSON, and grouping of deliveries due together
+ALTER TABLE webhooks ADD COLUMN quiet_hours TEXT DEFAULT '';
+ALTER TABLE webhooks ADD COLUMN group_alerts BOOLEAN DEFAULT FALSE;
+
+-- Subject of alert deliveries, to de-duplicate them and resolve them on recovery
+ALTER TABLE webhook_deliveries ADD COLUMN dedup_key TEXT DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_dedup ON webhook_deliveries(webhook_id, dedup_key, id);
@@ -0,0 +1,13 @@
+-- Resource usage of the running containers of deployments, sampled for
+-- footprint estimates and pruned after monitoring.footprint.retention_days
+CREATE TABLE IF NOT EXISTS resource_samples (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    deployment_id TEXT NOT NULL,
+    cpu_usage REAL NOT NULL, -- Percent of one CPU
+    memory_usage INTEGER NOT NULL, -- Bytes
+    containers INTEGER NOT NULL,
+    sampled_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (deployment_id) REFERENCES deployments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_resource_samples_deployment ON resource_samples(deployment_id, sampled_at);
@@ -0,0 +1,22 @@
+-- Test deploys of templates: each deploys a template version into a throwaway
+-- stack with generated values, waits for it to come up healthy and tears it down
+CREATE TABLE IF NOT EXISTS template_tests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    template_id TEXT NOT NULL,
+    version TEXT NOT NULL,
+    stack_name TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'running', -- running, passed, failed
+    error TEXT DEFAULT '',
+    logs TEXT DEFAULT '', -- Tail of the stack's logs before teardown
+    duration_ms INTEGER DEFAULT 0,
+    requested_by TEXT DEFAULT '',
+    started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME,
+    FOREIGN KEY (template_id) REFERENCES templates(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_template_tests_template ON template_tests(template_id, started_at);
+
+-- Latest version whose test passed, badging the template as verified working
+-- while it is the current version
+ALTER TABLE templates ADD COLUMN tested_version TEXT;
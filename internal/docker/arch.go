@@ -0,0 +1,272 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/models"
+)
+
+// imageArchTTL is how long the architectures of an image are reused before its
+// registry is asked again
+const imageArchTTL = 24 * time.Hour
+
+// Platforms returns the Linux architectures an image is published for, such as
+// amd64, arm64 or arm/v7. Multi-platform images list them in their index; for a
+// single-platform image the architecture is read from its configuration
+func (rc *RegistryClient) Platforms(image string) ([]string, error) {
+	ref := parseImageRef(image)
+
+	m, err := rc.fetchManifest(ref, ref.reference)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(m.Manifests) > 0 {
+		seen := make(map[string]bool)
+		var archs []string
+		for _, entry := range m.Manifests {
+			// Attestations are listed as unknown/unknown
+			if entry.Platform.OS != "linux" || entry.Platform.Architecture == "" || entry.Platform.Architecture == "unknown" {
+				continue
+			}
+			arch := entry.Platform.Architecture
+			if entry.Platform.Variant != "" {
+				arch += "/" + entry.Platform.Variant
+			}
+			if !seen[arch] {
+				seen[arch] = true
+				archs = append(archs, arch)
+			}
+		}
+		sort.Strings(archs)
+		return archs, nil
+	}
+
+	if m.Config.Digest == "" {
+		return nil, fmt.Errorf("manifest of %s has no configuration", image)
+	}
+	var config struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+		Variant      string `json:"variant"`
+	}
+	if err := rc.fetchBlob(ref, m.Config.Digest, &config); err != nil {
+		return nil, err
+	}
+	if config.OS != "" && config.OS != "linux" {
+		return []string{}, nil
+	}
+	arch := config.Architecture
+	if config.Variant != "" {
+		arch += "/" + config.Variant
+	}
+	return []string{arch}, nil
+}
+
+// fetchBlob decodes a JSON blob of a repository, such as an image configuration
+func (rc *RegistryClient) fetchBlob(ref imageRef, digest string, target interface{}) error {
+	blobURL := fmt.Sprintf("https://%s/v2/%s/blobs/%s", ref.registry, ref.repository, digest)
+
+	resp, err := rc.get(blobURL, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		token, err := rc.token(challenge)
+		if err != nil {
+			return err
+		}
+		if resp, err = rc.get(blobURL, token); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry returned %s for %s", resp.Status, ref.repository)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("failed to decode blob: %w", err)
+	}
+	return nil
+}
+
+// ComposeImages returns the distinct images of the services of compose content.
+// Services built locally have none
+func ComposeImages(content []byte) ([]string, error) {
+	doc, err := parseComposeDocument(content)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var images []string
+	for _, name := range doc.serviceNames() {
+		image := doc.decodeService(name).Image
+		if image != "" && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+// cachedArchs are the architectures of an image, as last read from its registry
+type cachedArchs struct {
+	archs     []string
+	fetchedAt time.Time
+}
+
+// ArchResolver works out the architectures a compose file can run on from the
+// registries of its images. Results are cached per image, as templates often
+// share images
+type ArchResolver struct {
+	registry *RegistryClient
+
+	mu    sync.Mutex
+	cache map[string]cachedArchs
+}
+
+// NewArchResolver creates a new architecture resolver
+func NewArchResolver() *ArchResolver {
+	return &ArchResolver{
+		registry: NewRegistryClient(10 * time.Second),
+		cache:    make(map[string]cachedArchs),
+	}
+}
+
+// Architectures returns the architectures every image of compose content is
+// published for, interpolated with env. Images whose registry cannot be read,
+// or whose name still holds a variable, are left out; without any image known
+// the result is nil, meaning unknown rather than none
+func (ar *ArchResolver) Architectures(content []byte, env map[string]string) ([]string, error) {
+	interpolated, err := Interpolate(content, env)
+	if err != nil {
+		interpolated = content
+	}
+	images, err := ComposeImages(interpolated)
+	if err != nil {
+		return nil, err
+	}
+
+	var common []string
+	known := false
+	for _, image := range images {
+		if strings.Contains(image, "$") {
+			continue
+		}
+		archs, err := ar.imageArchitectures(image)
+		if err != nil {
+			continue
+		}
+		if !known {
+			common, known = archs, true
+			continue
+		}
+		common = intersectArchitectures(common, archs)
+	}
+	if !known {
+		return nil, nil
+	}
+	if common == nil {
+		common = []string{}
+	}
+	return common, nil
+}
+
+// imageArchitectures returns the architectures of an image, from the cache while current
+func (ar *ArchResolver) imageArchitectures(image string) ([]string, error) {
+	ar.mu.Lock()
+	cached, ok := ar.cache[image]
+	ar.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < imageArchTTL {
+		return cached.archs, nil
+	}
+
+	archs, err := ar.registry.Platforms(image)
+	if err != nil {
+		return nil, err
+	}
+
+	ar.mu.Lock()
+	ar.cache[image] = cachedArchs{archs: archs, fetchedAt: time.Now()}
+	ar.mu.Unlock()
+	return archs, nil
+}
+
+// intersectArchitectures keeps the architectures of a also in b, the more
+// specific of the two where one names no variant
+func intersectArchitectures(a, b []string) []string {
+	var common []string
+	for _, arch := range a {
+		for _, other := range b {
+			if models.ArchitectureMatches(arch, other) {
+				if len(other) > len(arch) {
+					arch = other
+				}
+				common = append(common, arch)
+				break
+			}
+		}
+	}
+	return common
+}
+
+// HostPlatform reads the architecture of the Docker host once and remembers it
+type HostPlatform struct {
+	client *client.Client
+
+	mu   sync.Mutex
+	arch string
+}
+
+// NewHostPlatform creates a new host platform reader
+func NewHostPlatform(dockerClient *client.Client) *HostPlatform {
+	return &HostPlatform{client: dockerClient}
+}
+
+// Architecture returns the architecture of the Docker host in image platform
+// terms, such as amd64 or arm64. It is empty when the host cannot be reached
+func (hp *HostPlatform) Architecture(ctx context.Context) string {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	if hp.arch != "" {
+		return hp.arch
+	}
+
+	info, err := hp.client.Info(ctx)
+	if err != nil {
+		return ""
+	}
+	hp.arch = NormalizeArchitecture(info.Architecture)
+	return hp.arch
+}
+
+// NormalizeArchitecture converts a machine name as reported by the Docker engine,
+// such as x86_64 or aarch64, to its image platform architecture
+func NormalizeArchitecture(machine string) string {
+	switch strings.ToLower(machine) {
+	case "x86_64", "x86-64", "amd64":
+		return "amd64"
+	case "aarch64", "arm64":
+		return "arm64"
+	case "armv7l", "armv7", "armhf":
+		return "arm/v7"
+	case "armv6l", "armv6":
+		return "arm/v6"
+	case "i386", "i686", "386":
+		return "386"
+	}
+	return strings.ToLower(machine)
+}
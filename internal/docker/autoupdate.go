@@ -0,0 +1,465 @@
+package docker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/robfig/cron/v3"
+	"docker-deploy-app/internal/maintenance"
+	"docker-deploy-app/internal/models"
+)
+
+// patchTagPattern matches tags pinned to at least major.minor (e.g. 1.25, v2.1.3-alpine)
+var patchTagPattern = regexp.MustCompile(`^v?\d+\.\d+`)
+
+// AutoUpdater applies image updates to deployments according to their update policy
+type AutoUpdater struct {
+	db            *sql.DB
+	client        *client.Client
+	checker       *UpdateChecker
+	compose       *ComposeManager
+	swarm         *SwarmManager
+	cron          *cron.Cron
+	jobs          map[string]cron.EntryID
+	schedules     map[string]string
+	healthTimeout time.Duration
+	beforeUpgrade func(*models.Deployment) error
+	mu            sync.Mutex
+}
+
+// NewAutoUpdater creates a new automatic updater
+func NewAutoUpdater(db *sql.DB, dockerClient *client.Client, checker *UpdateChecker, compose *ComposeManager, swarm *SwarmManager) *AutoUpdater {
+	return &AutoUpdater{
+		db:            db,
+		client:        dockerClient,
+		checker:       checker,
+		compose:       compose,
+		swarm:         swarm,
+		cron:          cron.New(),
+		jobs:          make(map[string]cron.EntryID),
+		schedules:     make(map[string]string),
+		healthTimeout: 2 * time.Minute,
+	}
+}
+
+// SetBeforeUpgrade registers a hook run before a stack's images are upgraded. An
+// error from the hook aborts the upgrade
+func (au *AutoUpdater) SetBeforeUpgrade(hook func(*models.Deployment) error) {
+	au.mu.Lock()
+	defer au.mu.Unlock()
+	au.beforeUpgrade = hook
+}
+
+// ValidateSchedule returns an error if the cron expression cannot be parsed
+func ValidateSchedule(expression string) error {
+	if _, err := cron.ParseStandard(expression); err != nil {
+		return fmt.Errorf("invalid update schedule: %w", err)
+	}
+	return nil
+}
+
+// Start loads deployment policies and starts the scheduler
+func (au *AutoUpdater) Start() error {
+	if err := au.loadPolicies(); err != nil {
+		return err
+	}
+
+	// Policies are edited through the API; pick up changes periodically
+	if _, err := au.cron.AddFunc("@every 5m", func() {
+		if err := au.loadPolicies(); err != nil {
+			slog.Error("Failed to reload update policies", "error", err)
+		}
+	}); err != nil {
+		return err
+	}
+
+	au.cron.Start()
+	slog.Info("Auto updater started")
+	return nil
+}
+
+// Stop stops the scheduler
+func (au *AutoUpdater) Stop() {
+	au.cron.Stop()
+	slog.Info("Auto updater stopped")
+}
+
+// Apply upgrades the services of a deployment allowed by the given policy.
+// Returns a nil revision if there was nothing to upgrade.
+func (au *AutoUpdater) Apply(deployment *models.Deployment, policy models.UpdatePolicy, reason string) (*models.DeploymentRevision, error) {
+	updates, err := au.checker.CheckStack(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make(map[string]string)
+	var services []string
+	for _, update := range updates {
+		if update.UpdateAvailable && policyAllows(policy, update.Image) {
+			services = append(services, update.ServiceName)
+			images[update.ServiceName] = update.Image
+		}
+	}
+	if len(services) == 0 {
+		return nil, nil
+	}
+
+	au.mu.Lock()
+	beforeUpgrade := au.beforeUpgrade
+	au.mu.Unlock()
+	if beforeUpgrade != nil {
+		if err := beforeUpgrade(deployment); err != nil {
+			return nil, err
+		}
+	}
+
+	revision := &models.DeploymentRevision{
+		DeploymentID: deployment.ID,
+		Reason:       reason,
+		Services:     services,
+		Status:       models.RevisionStatusApplied,
+		CreatedAt:    time.Now(),
+	}
+
+	previous, err := au.currentImages(deployment, services)
+	if err != nil {
+		return nil, err
+	}
+	revision.PreviousImages = previous
+
+	if err := au.upgrade(deployment, services); err != nil {
+		revision.Status = models.RevisionStatusFailed
+		revision.Message = err.Error()
+	} else if err := au.verifyHealth(deployment, services); err != nil {
+		revision.Status = models.RevisionStatusRolledBack
+		revision.Message = err.Error()
+		if rbErr := au.rollback(deployment, services, images, previous); rbErr != nil {
+			revision.Status = models.RevisionStatusFailed
+			revision.Message = fmt.Sprintf("%v; rollback failed: %v", err, rbErr)
+		}
+	}
+
+	if err := au.saveRevision(revision); err != nil {
+		slog.Error("Failed to record revision", "stack", deployment.StackName, "error", err)
+	}
+
+	// Refresh stored digests after the upgrade or rollback
+	au.checker.CheckStack(deployment)
+
+	if revision.Status != models.RevisionStatusApplied {
+		return revision, fmt.Errorf("upgrade of %s %s: %s", deployment.StackName, revision.Status, revision.Message)
+	}
+	return revision, nil
+}
+
+// GetRevisions returns the recorded revisions of a deployment, newest first
+func (au *AutoUpdater) GetRevisions(deploymentID string) ([]models.DeploymentRevision, error) {
+	rows, err := au.db.Query(`
+		SELECT id, deployment_id, revision, reason, services, previous_images, status, message, created_at
+		FROM deployment_revisions
+		WHERE deployment_id = $1
+		ORDER BY revision DESC`, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []models.DeploymentRevision
+	for rows.Next() {
+		var rev models.DeploymentRevision
+		var servicesJSON, imagesJSON, message sql.NullString
+		if err := rows.Scan(&rev.ID, &rev.DeploymentID, &rev.Revision, &rev.Reason,
+			&servicesJSON, &imagesJSON, &rev.Status, &message, &rev.CreatedAt); err != nil {
+			continue
+		}
+		json.Unmarshal([]byte(servicesJSON.String), &rev.Services)
+		json.Unmarshal([]byte(imagesJSON.String), &rev.PreviousImages)
+		rev.Message = message.String
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+// loadPolicies synchronizes cron jobs with the policies stored in the database
+func (au *AutoUpdater) loadPolicies() error {
+	rows, err := au.db.Query(`
+		SELECT id, update_schedule FROM deployments
+		WHERE update_policy IN ('patch', 'any') AND update_schedule IS NOT NULL AND update_schedule != ''`)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]string)
+	for rows.Next() {
+		var id, schedule string
+		if err := rows.Scan(&id, &schedule); err != nil {
+			continue
+		}
+		wanted[id] = schedule
+	}
+	rows.Close()
+
+	au.mu.Lock()
+	defer au.mu.Unlock()
+
+	for id, entryID := range au.jobs {
+		if schedule, ok := wanted[id]; !ok || schedule != au.schedules[id] {
+			au.cron.Remove(entryID)
+			delete(au.jobs, id)
+			delete(au.schedules, id)
+		}
+	}
+
+	for id, schedule := range wanted {
+		if _, exists := au.jobs[id]; exists {
+			continue
+		}
+		deploymentID := id
+		entryID, err := au.cron.AddFunc(schedule, func() {
+			au.runScheduledUpdate(deploymentID)
+		})
+		if err != nil {
+			slog.Error("Failed to schedule updates", "deployment_id", id, "error", err)
+			continue
+		}
+		au.jobs[id] = entryID
+		au.schedules[id] = schedule
+	}
+
+	return nil
+}
+
+// runScheduledUpdate applies the current policy of a deployment
+func (au *AutoUpdater) runScheduledUpdate(deploymentID string) {
+	if maintenance.Active(au.db) {
+		slog.Info("Skipping auto-update during maintenance", "deployment_id", deploymentID)
+		return
+	}
+
+	var d models.Deployment
+	err := au.db.QueryRow(`
+		SELECT id, stack_name, status, deploy_mode, update_policy
+		FROM deployments WHERE id = $1`, deploymentID).Scan(
+		&d.ID, &d.StackName, &d.Status, &d.DeployMode, &d.UpdatePolicy)
+	if err != nil {
+		slog.Error("Failed to load deployment for auto-update", "deployment_id", deploymentID, "error", err)
+		return
+	}
+
+	if !d.IsRunning() || !d.AutoUpdates() {
+		return
+	}
+
+	revision, err := au.Apply(&d, d.UpdatePolicy, "auto-update")
+	if err != nil {
+		slog.Error("Auto-update failed", "stack", d.StackName, "error", err)
+		return
+	}
+	if revision != nil {
+		slog.Info("Auto-updated stack", "stack", d.StackName, "revision", revision.Revision, "services", revision.Services)
+	}
+}
+
+// upgrade pulls and recreates the given services
+func (au *AutoUpdater) upgrade(deployment *models.Deployment, services []string) error {
+	if deployment.IsSwarm() {
+		// Redeploying with registry auth makes swarm resolve and roll out new digests
+		return au.swarm.Deploy(deployment.StackName)
+	}
+	if err := au.compose.Pull(deployment.StackName, services...); err != nil {
+		return err
+	}
+	return au.compose.Recreate(deployment.StackName, services...)
+}
+
+// rollback restores the previous images of the given services
+func (au *AutoUpdater) rollback(deployment *models.Deployment, services []string, images, previous map[string]string) error {
+	if deployment.IsSwarm() {
+		return au.swarm.Rollback(deployment.StackName, services...)
+	}
+
+	// Point the tags back at the old images so compose recreates from them
+	ctx := context.Background()
+	for _, service := range services {
+		imageID, ok := previous[service]
+		if !ok {
+			continue
+		}
+		if err := au.client.ImageTag(ctx, imageID, images[service]); err != nil {
+			return fmt.Errorf("failed to retag %s: %w", images[service], err)
+		}
+	}
+	return au.compose.Recreate(deployment.StackName, services...)
+}
+
+// currentImages returns the image ID each service container is currently running
+func (au *AutoUpdater) currentImages(deployment *models.Deployment, services []string) (map[string]string, error) {
+	images := make(map[string]string)
+	if deployment.IsSwarm() {
+		return images, nil // Swarm keeps the previous spec for rollback itself
+	}
+
+	containers, err := au.serviceContainers(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, container := range containers {
+		service := container.Labels["com.docker.compose.service"]
+		if contains(services, service) {
+			images[service] = container.ImageID
+		}
+	}
+	return images, nil
+}
+
+// verifyHealth waits until every upgraded service is running and healthy
+func (au *AutoUpdater) verifyHealth(deployment *models.Deployment, services []string) error {
+	deadline := time.Now().Add(au.healthTimeout)
+	var lastErr error
+
+	for time.Now().Before(deadline) {
+		if deployment.IsSwarm() {
+			lastErr = au.checkSwarmHealth(deployment, services)
+		} else {
+			lastErr = au.checkComposeHealth(deployment, services)
+		}
+		if lastErr == nil {
+			return nil
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	return fmt.Errorf("health check failed: %w", lastErr)
+}
+
+// checkComposeHealth checks container state and healthcheck status of compose services
+func (au *AutoUpdater) checkComposeHealth(deployment *models.Deployment, services []string) error {
+	containers, err := au.serviceContainers(deployment)
+	if err != nil {
+		return err
+	}
+
+	healthy := make(map[string]bool)
+	for _, container := range containers {
+		service := container.Labels["com.docker.compose.service"]
+		if !contains(services, service) {
+			continue
+		}
+
+		inspect, err := au.client.ContainerInspect(context.Background(), container.ID)
+		if err != nil {
+			return err
+		}
+		if !inspect.State.Running {
+			return fmt.Errorf("service %s is %s", service, inspect.State.Status)
+		}
+		if inspect.State.Health != nil && inspect.State.Health.Status != "healthy" {
+			return fmt.Errorf("service %s is %s", service, inspect.State.Health.Status)
+		}
+		healthy[service] = true
+	}
+
+	for _, service := range services {
+		if !healthy[service] {
+			return fmt.Errorf("service %s has no running container", service)
+		}
+	}
+	return nil
+}
+
+// checkSwarmHealth checks that every upgraded swarm service reached its desired replicas
+func (au *AutoUpdater) checkSwarmHealth(deployment *models.Deployment, services []string) error {
+	stackServices, err := au.swarm.GetServices(deployment.StackName)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range stackServices {
+		if contains(services, service.Name) && service.Status != "running" {
+			return fmt.Errorf("service %s has %d/%d replicas running", service.Name, service.Replicas, service.DesiredReplicas)
+		}
+	}
+	return nil
+}
+
+// serviceContainers lists the compose containers of a deployment
+func (au *AutoUpdater) serviceContainers(deployment *models.Deployment) ([]types.Container, error) {
+	return au.client.ContainerList(context.Background(), types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", "com.docker.compose.project="+deployment.StackName)),
+	})
+}
+
+// saveRevision stores a revision with the next revision number of its deployment
+func (au *AutoUpdater) saveRevision(revision *models.DeploymentRevision) error {
+	servicesJSON, _ := json.Marshal(revision.Services)
+	imagesJSON, _ := json.Marshal(revision.PreviousImages)
+
+	err := au.db.QueryRow("SELECT COALESCE(MAX(revision), 0) + 1 FROM deployment_revisions WHERE deployment_id = $1",
+		revision.DeploymentID).Scan(&revision.Revision)
+	if err != nil {
+		return err
+	}
+
+	result, err := au.db.Exec(`
+		INSERT INTO deployment_revisions
+		(deployment_id, revision, reason, services, previous_images, status, message, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		revision.DeploymentID, revision.Revision, revision.Reason, string(servicesJSON),
+		string(imagesJSON), revision.Status, revision.Message, revision.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	revision.ID = int(id)
+	return nil
+}
+
+// policyAllows returns true if an update to the image is permitted by the policy
+func policyAllows(policy models.UpdatePolicy, image string) bool {
+	switch policy {
+	case models.UpdatePolicyAny:
+		return true
+	case models.UpdatePolicyPatch:
+		return patchTagPattern.MatchString(imageTag(image))
+	default:
+		return false
+	}
+}
+
+// imageTag returns the tag of an image reference, defaulting to latest
+func imageTag(image string) string {
+	if idx := strings.Index(image, "@"); idx >= 0 {
+		image = image[:idx]
+	}
+	if idx := strings.LastIndex(image, ":"); idx > strings.LastIndex(image, "/") {
+		return image[idx+1:]
+	}
+	return "latest"
+}
+
+// contains returns true if the slice contains the value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
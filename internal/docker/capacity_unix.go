@@ -0,0 +1,14 @@
+//go:build !windows
+
+package docker
+
+import "syscall"
+
+// filesystemCapacity returns the total and free bytes of the filesystem holding path
+func filesystemCapacity(path string) (total, free uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return stat.Blocks * uint64(stat.Bsize), stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package docker
+
+import "fmt"
+
+// filesystemCapacity is not supported on Windows hosts
+func filesystemCapacity(path string) (total, free uint64, err error) {
+	return 0, 0, fmt.Errorf("filesystem capacity is not supported on windows")
+}
@@ -0,0 +1,209 @@
+package docker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/models"
+)
+
+// Cleaner removes Docker resources left behind by deleted deployments
+type Cleaner struct {
+	db             *sql.DB
+	client         *client.Client
+	defaultNetwork string
+}
+
+// NewCleaner creates a new resource cleaner
+func NewCleaner(db *sql.DB, dockerClient *client.Client, defaultNetwork string) *Cleaner {
+	return &Cleaner{
+		db:             db,
+		client:         dockerClient,
+		defaultNetwork: defaultNetwork,
+	}
+}
+
+// Cleanup removes the selected resource types, or only reports them in dry-run mode
+func (c *Cleaner) Cleanup(options *models.CleanupOptions) (*models.CleanupReport, error) {
+	ctx := context.Background()
+	report := &models.CleanupReport{DryRun: options.DryRun}
+
+	if options.DanglingImages {
+		if err := c.cleanupImages(ctx, options.DryRun, report); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.UnusedNetworks {
+		if err := c.cleanupNetworks(ctx, options.DryRun, report); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.OrphanedVolumes {
+		minAge := time.Duration(options.VolumeMinAgeDays) * 24 * time.Hour
+		if err := c.cleanupVolumes(ctx, options.DryRun, minAge, report); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// cleanupImages removes dangling images
+func (c *Cleaner) cleanupImages(ctx context.Context, dryRun bool, report *models.CleanupReport) error {
+	images, err := c.client.ImageList(ctx, types.ImageListOptions{
+		Filters: filters.NewArgs(filters.Arg("dangling", "true")),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list dangling images: %w", err)
+	}
+
+	for _, image := range images {
+		if !dryRun {
+			if _, err := c.client.ImageRemove(ctx, image.ID, types.ImageRemoveOptions{PruneChildren: true}); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("image %s: %v", shortID(image.ID), err))
+				continue
+			}
+		}
+		report.Images = append(report.Images, models.CleanupItem{
+			ID:        image.ID,
+			Name:      shortID(image.ID),
+			SizeBytes: image.Size,
+		})
+		report.ReclaimedBytes += image.Size
+	}
+
+	return nil
+}
+
+// cleanupNetworks removes app networks that no container is attached to
+func (c *Cleaner) cleanupNetworks(ctx context.Context, dryRun bool, report *models.CleanupReport) error {
+	networks, err := c.client.NetworkList(ctx, types.NetworkListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list networks: %w", err)
+	}
+
+	for _, network := range networks {
+		// Compose prefixes networks with the project name, e.g. "mystack_app_network"
+		if network.Name != c.defaultNetwork && !strings.HasSuffix(network.Name, "_"+c.defaultNetwork) {
+			continue
+		}
+
+		// NetworkList does not populate attached containers
+		inspect, err := c.client.NetworkInspect(ctx, network.ID, types.NetworkInspectOptions{})
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("network %s: %v", network.Name, err))
+			continue
+		}
+		if len(inspect.Containers) > 0 {
+			continue
+		}
+
+		if !dryRun {
+			if err := c.client.NetworkRemove(ctx, network.ID); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("network %s: %v", network.Name, err))
+				continue
+			}
+		}
+		report.Networks = append(report.Networks, models.CleanupItem{
+			ID:   network.ID,
+			Name: network.Name,
+		})
+	}
+
+	return nil
+}
+
+// cleanupVolumes removes unused volumes of stacks that no longer have a deployment,
+// trashed ones included
+func (c *Cleaner) cleanupVolumes(ctx context.Context, dryRun bool, minAge time.Duration, report *models.CleanupReport) error {
+	stacks, err := c.deployedStacks()
+	if err != nil {
+		return err
+	}
+
+	// DiskUsage is the only API that reports volume sizes and reference counts
+	usage, err := c.client.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.VolumeObject}})
+	if err != nil {
+		return fmt.Errorf("failed to get volume usage: %w", err)
+	}
+
+	for _, vol := range usage.Volumes {
+		project, ok := vol.Labels["com.docker.compose.project"]
+		if !ok || stacks[project] {
+			continue
+		}
+		if vol.UsageData != nil && vol.UsageData.RefCount > 0 {
+			continue
+		}
+		if !volumeOlderThan(vol, minAge) {
+			continue
+		}
+
+		if !dryRun {
+			if err := c.client.VolumeRemove(ctx, vol.Name, false); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("volume %s: %v", vol.Name, err))
+				continue
+			}
+		}
+
+		item := models.CleanupItem{ID: vol.Name, Name: vol.Name}
+		if vol.UsageData != nil && vol.UsageData.Size > 0 {
+			item.SizeBytes = vol.UsageData.Size
+		}
+		report.Volumes = append(report.Volumes, item)
+		report.ReclaimedBytes += item.SizeBytes
+	}
+
+	return nil
+}
+
+// deployedStacks returns the stack names of all existing deployments and of
+// those in the trash, whose volumes are kept for a restore
+func (c *Cleaner) deployedStacks() (map[string]bool, error) {
+	rows, err := c.db.Query("SELECT stack_name FROM deployments UNION SELECT stack_name FROM deployment_trash")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stacks := make(map[string]bool)
+	for rows.Next() {
+		var stackName string
+		if err := rows.Scan(&stackName); err != nil {
+			continue
+		}
+		stacks[stackName] = true
+	}
+
+	return stacks, rows.Err()
+}
+
+// volumeOlderThan returns true if the volume was created more than minAge ago
+func volumeOlderThan(vol *volume.Volume, minAge time.Duration) bool {
+	if minAge <= 0 {
+		return true
+	}
+	created, err := time.Parse(time.RFC3339, vol.CreatedAt)
+	if err != nil {
+		return false // Keep volumes whose age cannot be determined
+	}
+	return time.Since(created) > minAge
+}
+
+// shortID returns the short form of an image or container ID
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,40 @@
+package docker
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestDeployedStacksKeepsTrashedStacks(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, statement := range []string{
+		"CREATE TABLE deployments (id TEXT PRIMARY KEY, stack_name TEXT NOT NULL)",
+		"CREATE TABLE deployment_trash (id TEXT PRIMARY KEY, stack_name TEXT NOT NULL)",
+		"INSERT INTO deployments (id, stack_name) VALUES ('deploy_1', 'running')",
+		"INSERT INTO deployment_trash (id, stack_name) VALUES ('deploy_2', 'trashed')",
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	stacks, err := NewCleaner(db, nil, "").deployedStacks()
+	if err != nil {
+		t.Fatalf("deployedStacks: %v", err)
+	}
+	for _, stack := range []string{"running", "trashed"} {
+		if !stacks[stack] {
+			t.Errorf("%s is missing from the deployed stacks, so its volumes would be removed", stack)
+		}
+	}
+	if stacks["deleted"] {
+		t.Errorf("a stack without a deployment is reported as deployed")
+	}
+}
@@ -32,6 +32,14 @@ func NewClient() (*Client, error) {
 	}, nil
 }
 
+// WrapClient wraps an existing Docker client, sharing its connection
+func WrapClient(cli *client.Client) *Client {
+	return &Client{
+		cli: cli,
+		ctx: context.Background(),
+	}
+}
+
 // Close closes the Docker client connection
 func (c *Client) Close() error {
 	return c.cli.Close()
@@ -69,7 +77,14 @@ func (c *Client) GetStackContainers(stackName string) ([]types.Container, error)
 
 // GetContainerStats retrieves resource usage statistics for a container
 func (c *Client) GetContainerStats(containerID string) (*models.ServiceStats, error) {
-	stats, err := c.cli.ContainerStats(c.ctx, containerID, false)
+	return c.GetContainerStatsContext(c.ctx, containerID)
+}
+
+// GetContainerStatsContext retrieves resource usage statistics for a container,
+// giving up when ctx is done. A one-shot sample takes about a second, as the
+// daemon waits for a second CPU reading
+func (c *Client) GetContainerStatsContext(ctx context.Context, containerID string) (*models.ServiceStats, error) {
+	stats, err := c.cli.ContainerStats(ctx, containerID, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get container stats: %w", err)
 	}
@@ -96,11 +111,11 @@ func (c *Client) GetContainerStats(containerID string) (*models.ServiceStats, er
 
 	// Get block I/O stats
 	var blockRead, blockWrite int64
-	for _, bioEntry := range containerStats.BloodStats.IoServiceBytesRecursive {
+	for _, bioEntry := range containerStats.BlkioStats.IoServiceBytesRecursive {
 		switch bioEntry.Op {
-		case "read":
+		case "read", "Read":
 			blockRead += int64(bioEntry.Value)
-		case "write":
+		case "write", "Write":
 			blockWrite += int64(bioEntry.Value)
 		}
 	}
@@ -120,14 +135,20 @@ func (c *Client) GetContainerStats(containerID string) (*models.ServiceStats, er
 
 // GetContainerLogs retrieves logs from a container
 func (c *Client) GetContainerLogs(containerID string, tail string) (io.ReadCloser, error) {
-	options := types.ContainerLogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
+	return c.GetContainerLogsContext(c.ctx, containerID, types.ContainerLogsOptions{
 		Timestamps: true,
 		Tail:       tail,
-	}
+	})
+}
+
+// GetContainerLogsContext retrieves stdout and stderr logs from a container with
+// the given tail, since and follow options, until ctx is done. Unless the
+// container has a TTY the two streams are multiplexed, see stdcopy
+func (c *Client) GetContainerLogsContext(ctx context.Context, containerID string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	options.ShowStdout = true
+	options.ShowStderr = true
 
-	logs, err := c.cli.ContainerLogs(c.ctx, containerID, options)
+	logs, err := c.cli.ContainerLogs(ctx, containerID, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get container logs: %w", err)
 	}
@@ -135,6 +156,16 @@ func (c *Client) GetContainerLogs(containerID string, tail string) (io.ReadClose
 	return logs, nil
 }
 
+// HasTTY reports whether a container was started with a TTY, so its logs are a
+// single raw stream
+func (c *Client) HasTTY(ctx context.Context, containerID string) (bool, error) {
+	info, err := c.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	return info.Config != nil && info.Config.Tty, nil
+}
+
 // StartContainer starts a container
 func (c *Client) StartContainer(containerID string) error {
 	err := c.cli.ContainerStart(c.ctx, containerID, types.ContainerStartOptions{})
@@ -291,8 +322,14 @@ func calculateCPUUsage(stats *types.StatsJSON) float64 {
 	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
 	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
 	
+	// Per-CPU usage is not reported under cgroup v2
+	cpus := float64(stats.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+
 	if systemDelta > 0.0 && cpuDelta > 0.0 {
-		return (cpuDelta / systemDelta) * float64(len(stats.CPUStats.CPUUsage.PercpuUsage)) * 100.0
+		return (cpuDelta / systemDelta) * cpus * 100.0
 	}
 	return 0.0
 }
\ No newline at end of file
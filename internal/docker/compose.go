@@ -83,12 +83,21 @@ type DeployOptions struct {
 	BuildArgs   map[string]string
 	Detached    bool
 	PullImages  bool
+	Ports       *PortChecker // Checks host ports before starting, when set
+	RemapPorts  bool         // Move conflicting host ports to free ones instead of failing
+	GPU         *models.TemplateGPU // GPUs to reserve for the services, when set
+	TLS         *models.TLSConfig   // Routes to serve over TLS through a sidecar, when set
+	TLSCertDir  string              // Absolute directory of the TLS certificate files
 }
 
 // Deploy deploys a Docker Compose stack
 func (cm *ComposeManager) Deploy(options DeployOptions) error {
-	// Create project directory
-	projectDir := filepath.Join(cm.workDir, options.StackName)
+	// Create project directory, by absolute path as the work directory is
+	// relative to the one changed from
+	projectDir, err := filepath.Abs(filepath.Join(cm.workDir, options.StackName))
+	if err != nil {
+		return fmt.Errorf("failed to resolve project directory: %w", err)
+	}
 	if err := os.MkdirAll(projectDir, 0755); err != nil {
 		return fmt.Errorf("failed to create project directory: %w", err)
 	}
@@ -118,6 +127,29 @@ func (cm *ComposeManager) Deploy(options DeployOptions) error {
 		}
 	}
 
+	// Fail before touching containers when a required variable is missing
+	if err := cm.checkInterpolation(projectDir, options.EnvVars); err != nil {
+		return err
+	}
+
+	if options.Ports != nil {
+		if err := cm.checkPorts(projectDir, options); err != nil {
+			return err
+		}
+	}
+
+	if options.GPU != nil {
+		if err := cm.reserveGPUs(projectDir, options.GPU); err != nil {
+			return err
+		}
+	}
+
+	if options.TLS != nil {
+		if err := cm.addTLSSidecar(projectDir, options); err != nil {
+			return err
+		}
+	}
+
 	// Build command
 	args := []string{"compose"}
 	
@@ -153,6 +185,12 @@ func (cm *ComposeManager) Start(stackName string) error {
 	return cm.runCommand("docker", args)
 }
 
+// Up creates and starts the containers of a stack from its compose file, as after Down
+func (cm *ComposeManager) Up(stackName string) error {
+	args := append(cm.projectArgs(stackName), "up", "--detach")
+	return cm.runCommand("docker", args)
+}
+
 // Restart restarts a Docker Compose stack
 func (cm *ComposeManager) Restart(stackName string) error {
 	args := []string{"compose", "--project-name", stackName, "restart"}
@@ -168,6 +206,114 @@ func (cm *ComposeManager) Down(stackName string, removeVolumes bool) error {
 	return cm.runCommand("docker", args)
 }
 
+// Remove tears a stack down like Down, also removing the images of its services
+// when removeImages is set
+func (cm *ComposeManager) Remove(stackName string, removeVolumes, removeImages bool) error {
+	args := []string{"compose", "--project-name", stackName, "down", "--remove-orphans"}
+	if removeVolumes {
+		args = append(args, "--volumes")
+	}
+	if removeImages {
+		args = append(args, "--rmi", "all")
+	}
+	return cm.runCommand("docker", args)
+}
+
+// RemoveFiles deletes the directory of a stack with its compose file
+func (cm *ComposeManager) RemoveFiles(stackName string) error {
+	if stackName == "" || stackName != filepath.Base(stackName) || strings.HasPrefix(stackName, ".") {
+		return fmt.Errorf("invalid stack name %q", stackName)
+	}
+	return os.RemoveAll(filepath.Join(cm.workDir, stackName))
+}
+
+// Pull pulls the images of the given services (all services if none given)
+func (cm *ComposeManager) Pull(stackName string, services ...string) error {
+	args := append(cm.projectArgs(stackName), "pull")
+	return cm.runCommand("docker", append(args, services...))
+}
+
+// Recreate recreates the given services without touching their dependencies
+func (cm *ComposeManager) Recreate(stackName string, services ...string) error {
+	args := append(cm.projectArgs(stackName), "up", "--detach", "--no-deps", "--force-recreate")
+	return cm.runCommand("docker", append(args, services...))
+}
+
+// GetServiceEnv returns the environment of a service as declared in the stack's compose file
+func (cm *ComposeManager) GetServiceEnv(stackName, serviceName string) (map[string]string, error) {
+	root, err := cm.readComposeNode(stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string)
+	envNode := findServiceEnvironment(root, serviceName)
+	if envNode == nil {
+		return env, nil
+	}
+
+	switch envNode.Kind {
+	case yaml.SequenceNode:
+		for _, item := range envNode.Content {
+			parts := strings.SplitN(item.Value, "=", 2)
+			if len(parts) == 2 {
+				env[parts[0]] = parts[1]
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(envNode.Content); i += 2 {
+			env[envNode.Content[i].Value] = envNode.Content[i+1].Value
+		}
+	}
+	return env, nil
+}
+
+// SetServiceEnv sets an environment variable of a service in the stack's compose file,
+// leaving the rest of the file untouched
+func (cm *ComposeManager) SetServiceEnv(stackName, serviceName, key, value string) error {
+	root, err := cm.readComposeNode(stackName)
+	if err != nil {
+		return err
+	}
+
+	envNode := findServiceEnvironment(root, serviceName)
+	if envNode == nil {
+		return fmt.Errorf("service %s has no environment in %s", serviceName, stackName)
+	}
+
+	updated := false
+	switch envNode.Kind {
+	case yaml.SequenceNode:
+		for _, item := range envNode.Content {
+			if strings.HasPrefix(item.Value, key+"=") {
+				item.Value = key + "=" + value
+				updated = true
+			}
+		}
+		if !updated {
+			envNode.Content = append(envNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key + "=" + value})
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(envNode.Content); i += 2 {
+			if envNode.Content[i].Value == key {
+				envNode.Content[i+1].Value = value
+				updated = true
+			}
+		}
+		if !updated {
+			envNode.Content = append(envNode.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+				&yaml.Node{Kind: yaml.ScalarNode, Value: value})
+		}
+	}
+
+	data, err := yaml.Marshal(root)
+	if err != nil {
+		return fmt.Errorf("failed to marshal compose data: %w", err)
+	}
+	return os.WriteFile(cm.composePath(stackName), data, 0644)
+}
+
 // Logs retrieves logs from a Docker Compose stack
 func (cm *ComposeManager) Logs(stackName string, follow bool, tail int) (*exec.Cmd, error) {
 	args := []string{"compose", "--project-name", stackName, "logs"}
@@ -308,6 +454,134 @@ func (cm *ComposeManager) createEnvFile(dir string, envVars map[string]string) e
 	return os.WriteFile(envPath, []byte(content), 0644)
 }
 
+// checkInterpolation interpolates the compose files of a project to report missing
+// required variables with their names rather than a compose parse error
+func (cm *ComposeManager) checkInterpolation(projectDir string, envVars map[string]string) error {
+	// Compose also reads variables from the shell it runs in
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		if parts := strings.SplitN(entry, "=", 2); len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+	for key, value := range envVars {
+		env[key] = value
+	}
+
+	for _, file := range []string{"docker-compose.yml", "docker-compose.yaml", "docker-compose.override.yml", "docker-compose.override.yaml"} {
+		content, err := os.ReadFile(filepath.Join(projectDir, file))
+		if err != nil {
+			continue
+		}
+		if _, err := Interpolate(content, env); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// checkPorts fails on host port conflicts, or rewrites the compose file when remapping
+func (cm *ComposeManager) checkPorts(projectDir string, options DeployOptions) error {
+	composePath := filepath.Join(projectDir, "docker-compose.yml")
+	content, err := os.ReadFile(composePath)
+	if err != nil {
+		return fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	updated, conflicts, err := options.Ports.Check(options.StackName, content, options.EnvVars, options.RemapPorts)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	return os.WriteFile(composePath, updated, 0644)
+}
+
+// reserveGPUs rewrites the compose file to reserve GPUs for its services
+func (cm *ComposeManager) reserveGPUs(projectDir string, gpu *models.TemplateGPU) error {
+	composePath := filepath.Join(projectDir, "docker-compose.yml")
+	content, err := os.ReadFile(composePath)
+	if err != nil {
+		return fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	updated, err := InjectGPU(content, gpu)
+	if err != nil {
+		return fmt.Errorf("failed to reserve GPUs: %w", err)
+	}
+	return os.WriteFile(composePath, updated, 0644)
+}
+
+// ReadComposeFile returns the compose file a stack was deployed from
+func (cm *ComposeManager) ReadComposeFile(stackName string) ([]byte, error) {
+	return os.ReadFile(cm.composePath(stackName))
+}
+
+// WriteStackCompose writes the compose file a stack is deployed from
+func (cm *ComposeManager) WriteStackCompose(stackName string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(cm.composePath(stackName)), 0755); err != nil {
+		return fmt.Errorf("failed to create project directory: %w", err)
+	}
+	if err := os.WriteFile(cm.composePath(stackName), content, 0644); err != nil {
+		return fmt.Errorf("failed to write compose file: %w", err)
+	}
+	return nil
+}
+
+// composePath returns the path of a stack's compose file
+func (cm *ComposeManager) composePath(stackName string) string {
+	return filepath.Join(cm.workDir, stackName, "docker-compose.yml")
+}
+
+// projectArgs returns the compose arguments selecting a stack's project and file
+func (cm *ComposeManager) projectArgs(stackName string) []string {
+	return []string{"compose", "--project-name", stackName, "--file", cm.composePath(stackName)}
+}
+
+// readComposeNode parses a stack's compose file as a YAML node tree
+func (cm *ComposeManager) readComposeNode(stackName string) (*yaml.Node, error) {
+	data, err := os.ReadFile(cm.composePath(stackName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file: %w", err)
+	}
+	return &root, nil
+}
+
+// findServiceEnvironment returns the environment node of a service, or nil
+func findServiceEnvironment(root *yaml.Node, serviceName string) *yaml.Node {
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	services := mappingValue(root, "services")
+	if services == nil {
+		return nil
+	}
+	service := mappingValue(services, serviceName)
+	if service == nil {
+		return nil
+	}
+	return mappingValue(service, "environment")
+}
+
+// mappingValue returns the value node for a key of a YAML mapping, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
 // runCommand executes a command with timeout
 func (cm *ComposeManager) runCommand(command string, args []string) error {
 	cmd := exec.Command(command, args...)
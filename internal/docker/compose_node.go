@@ -0,0 +1,336 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// composeDocument is a compose file held as a YAML node tree, so edits touch only
+// the keys they change and keep build:, deploy:, x- extensions and comments intact
+type composeDocument struct {
+	root *yaml.Node
+	body *yaml.Node
+}
+
+// parseComposeDocument parses compose file content into a node tree
+func parseComposeDocument(data []byte) (*composeDocument, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse docker-compose: %w", err)
+	}
+
+	// An empty file decodes to no document at all
+	if root.Kind == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("failed to parse docker-compose: top level is not a mapping")
+	}
+
+	return &composeDocument{root: &root, body: root.Content[0]}, nil
+}
+
+// Bytes renders the document using the two-space indent of typical compose files
+func (d *composeDocument) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(d.root); err != nil {
+		return nil, fmt.Errorf("failed to marshal docker-compose: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal docker-compose: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// section returns a top-level mapping such as services or networks, creating it if asked
+func (d *composeDocument) section(key string, create bool) *yaml.Node {
+	node := mappingValue(d.body, key)
+	if node != nil && node.Kind == yaml.MappingNode {
+		return node
+	}
+	if !create {
+		return nil
+	}
+
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	if node != nil {
+		// Replace a null value such as "networks:" with no entries
+		*node = *mapping
+		return node
+	}
+	setMappingValue(d.body, key, mapping)
+	return mapping
+}
+
+// serviceNames returns the names of all services in file order
+func (d *composeDocument) serviceNames() []string {
+	services := d.section("services", false)
+	if services == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(services.Content)/2)
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		names = append(names, services.Content[i].Value)
+	}
+	return names
+}
+
+// service returns the mapping node of a service, or nil
+func (d *composeDocument) service(name string) *yaml.Node {
+	node := mappingValue(d.section("services", false), name)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	return node
+}
+
+// setService adds a service, or replaces an existing one in place
+func (d *composeDocument) setService(name string, service ComposeService) error {
+	var node yaml.Node
+	if err := node.Encode(service); err != nil {
+		return fmt.Errorf("failed to encode service %s: %w", name, err)
+	}
+	setMappingValue(d.section("services", true), name, &node)
+	return nil
+}
+
+// hasNetwork returns true if a top-level network is defined
+func (d *composeDocument) hasNetwork(name string) bool {
+	return mappingValue(d.section("networks", false), name) != nil
+}
+
+// setNetwork adds a top-level network if it is not defined yet
+func (d *composeDocument) setNetwork(name string, network ComposeNetwork) error {
+	if d.hasNetwork(name) {
+		return nil
+	}
+
+	var node yaml.Node
+	if err := node.Encode(network); err != nil {
+		return fmt.Errorf("failed to encode network %s: %w", name, err)
+	}
+	setMappingValue(d.section("networks", true), name, &node)
+	return nil
+}
+
+// serviceNetworks returns the networks a service joins, in list or mapping form
+func (d *composeDocument) serviceNetworks(name string) []string {
+	networks := mappingValue(d.service(name), "networks")
+	if networks == nil {
+		return nil
+	}
+	if networks.Kind == yaml.MappingNode {
+		return mappingKeys(networks)
+	}
+	return scalarValues(networks)
+}
+
+// addServiceNetwork joins a service to a network, keeping the form the file uses
+func (d *composeDocument) addServiceNetwork(name, network string) {
+	service := d.service(name)
+	if service == nil || contains(d.serviceNetworks(name), network) {
+		return
+	}
+
+	networks := mappingValue(service, "networks")
+	switch {
+	case networks == nil || (networks.Kind == yaml.ScalarNode && networks.Tag == "!!null"):
+		setMappingValue(service, "networks", &yaml.Node{
+			Kind:    yaml.SequenceNode,
+			Content: []*yaml.Node{scalarNode(network)},
+		})
+	case networks.Kind == yaml.MappingNode:
+		networks.Content = append(networks.Content, scalarNode(network), &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"})
+	case networks.Kind == yaml.SequenceNode:
+		networks.Content = append(networks.Content, scalarNode(network))
+	}
+}
+
+// removeServiceNetwork takes a service off a network, in either form
+func (d *composeDocument) removeServiceNetwork(name, network string) {
+	networks := mappingValue(d.service(name), "networks")
+	if networks == nil {
+		return
+	}
+
+	switch networks.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(networks.Content); i += 2 {
+			if networks.Content[i].Value == network {
+				networks.Content = append(networks.Content[:i], networks.Content[i+2:]...)
+				return
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range networks.Content {
+			if item.Value == network {
+				networks.Content = append(networks.Content[:i], networks.Content[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// setServiceNetworkOption sets an option of a service on a network, such as
+// ipv4_address, joining the network and switching the service's networks to
+// mapping form where needed
+func (d *composeDocument) setServiceNetworkOption(name, network, key, value string) {
+	service := d.service(name)
+	if service == nil {
+		return
+	}
+	d.addServiceNetwork(name, network)
+
+	networks := mappingValue(service, "networks")
+	if networks.Kind == yaml.SequenceNode {
+		mapping := &yaml.Node{Kind: yaml.MappingNode}
+		for _, item := range networks.Content {
+			mapping.Content = append(mapping.Content, scalarNode(item.Value), &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"})
+		}
+		*networks = *mapping
+	}
+	setMappingValue(childMapping(networks, network), key, scalarNode(value))
+}
+
+// setServiceLabels merges labels into a service, keeping the form the file uses
+func (d *composeDocument) setServiceLabels(name string, labels map[string]string) {
+	service := d.service(name)
+	if service == nil || len(labels) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	node := mappingValue(service, "labels")
+	if node == nil || (node.Kind != yaml.MappingNode && node.Kind != yaml.SequenceNode) {
+		node = &yaml.Node{Kind: yaml.MappingNode}
+		setMappingValue(service, "labels", node)
+	}
+
+	for _, key := range keys {
+		if node.Kind == yaml.MappingNode {
+			setMappingValue(node, key, scalarNode(labels[key]))
+			continue
+		}
+
+		entry := key + "=" + labels[key]
+		replaced := false
+		for _, item := range node.Content {
+			if item.Value == key || strings.HasPrefix(item.Value, key+"=") {
+				item.Value = entry
+				replaced = true
+			}
+		}
+		if !replaced {
+			node.Content = append(node.Content, scalarNode(entry))
+		}
+	}
+}
+
+// decodeService reads the fields injectors inspect from a service, accepting both
+// the list and mapping forms compose allows for environment, labels and networks
+func (d *composeDocument) decodeService(name string) ComposeService {
+	node := d.service(name)
+	var service ComposeService
+	if node == nil {
+		return service
+	}
+
+	if image := mappingValue(node, "image"); image != nil {
+		service.Image = image.Value
+	}
+	if env := mappingValue(node, "environment"); env != nil {
+		if env.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(env.Content); i += 2 {
+				service.Environment = append(service.Environment, env.Content[i].Value+"="+env.Content[i+1].Value)
+			}
+		} else {
+			service.Environment = scalarValues(env)
+		}
+	}
+	if labels := mappingValue(node, "labels"); labels != nil {
+		service.Labels = make(map[string]string)
+		if labels.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(labels.Content); i += 2 {
+				service.Labels[labels.Content[i].Value] = labels.Content[i+1].Value
+			}
+		} else {
+			for _, label := range scalarValues(labels) {
+				parts := strings.SplitN(label, "=", 2)
+				if len(parts) == 2 {
+					service.Labels[parts[0]] = parts[1]
+				} else {
+					service.Labels[parts[0]] = ""
+				}
+			}
+		}
+	}
+	if volumes := mappingValue(node, "volumes"); volumes != nil {
+		for _, volume := range volumes.Content {
+			if volume.Kind == yaml.ScalarNode {
+				service.Volumes = append(service.Volumes, volume.Value)
+			} else if source := mappingValue(volume, "source"); source != nil {
+				target := mappingValue(volume, "target")
+				if target != nil {
+					service.Volumes = append(service.Volumes, source.Value+":"+target.Value)
+				}
+			}
+		}
+	}
+	if ports := mappingValue(node, "ports"); ports != nil {
+		service.Ports = scalarValues(ports)
+	}
+	service.Networks = d.serviceNetworks(name)
+
+	return service
+}
+
+// setMappingValue sets the value node for a key of a YAML mapping, appending the key if new
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, scalarNode(key), value)
+}
+
+// mappingKeys returns the keys of a YAML mapping in order
+func mappingKeys(node *yaml.Node) []string {
+	var keys []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keys = append(keys, node.Content[i].Value)
+	}
+	return keys
+}
+
+// scalarValues returns the scalar items of a YAML sequence
+func scalarValues(node *yaml.Node) []string {
+	var values []string
+	if node.Kind != yaml.SequenceNode {
+		return values
+	}
+	for _, item := range node.Content {
+		if item.Kind == yaml.ScalarNode {
+			values = append(values, item.Value)
+		}
+	}
+	return values
+}
+
+// scalarNode returns a plain string scalar node
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
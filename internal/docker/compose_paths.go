@@ -0,0 +1,90 @@
+package docker
+
+import (
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AbsoluteComposePaths rewrites the relative host paths of a compose file -
+// bind mount sources, env_file entries and build contexts - against dir, the
+// directory the file was written for, so it still points at the same files
+// when run from a project directory elsewhere. It returns the number of paths
+// rewritten; the content is returned as is when there were none
+func AbsoluteComposePaths(content []byte, dir string) ([]byte, int, error) {
+	doc, err := parseComposeDocument(content)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rewritten := 0
+	absolute := func(node *yaml.Node) {
+		if node == nil || node.Kind != yaml.ScalarNode || !isRelativeHostPath(node.Value) {
+			return
+		}
+		node.Value = filepath.Join(dir, node.Value)
+		node.Style = 0
+		rewritten++
+	}
+
+	for _, name := range doc.serviceNames() {
+		service := doc.service(name)
+
+		if volumes := mappingValue(service, "volumes"); volumes != nil && volumes.Kind == yaml.SequenceNode {
+			for _, volume := range volumes.Content {
+				switch volume.Kind {
+				case yaml.ScalarNode:
+					// source:target[:mode]
+					source, rest, found := strings.Cut(volume.Value, ":")
+					if found && isRelativeHostPath(source) {
+						volume.Value = filepath.Join(dir, source) + ":" + rest
+						volume.Style = 0
+						rewritten++
+					}
+				case yaml.MappingNode:
+					if volumeType := mappingValue(volume, "type"); volumeType != nil && volumeType.Value == "bind" {
+						absolute(mappingValue(volume, "source"))
+					}
+				}
+			}
+		}
+
+		switch envFile := mappingValue(service, "env_file"); {
+		case envFile == nil:
+		case envFile.Kind == yaml.SequenceNode:
+			for _, entry := range envFile.Content {
+				if entry.Kind == yaml.MappingNode {
+					absolute(mappingValue(entry, "path"))
+				} else {
+					absolute(entry)
+				}
+			}
+		default:
+			absolute(envFile)
+		}
+
+		if build := mappingValue(service, "build"); build != nil {
+			if build.Kind == yaml.MappingNode {
+				absolute(mappingValue(build, "context"))
+			} else {
+				absolute(build)
+			}
+		}
+	}
+
+	if rewritten == 0 {
+		return content, 0, nil
+	}
+	updated, err := doc.Bytes()
+	if err != nil {
+		return nil, 0, err
+	}
+	return updated, rewritten, nil
+}
+
+// isRelativeHostPath reports whether a compose path is relative to the compose
+// file. Named volumes and URLs such as git build contexts are not
+func isRelativeHostPath(value string) bool {
+	return value == "." || value == ".." || strings.HasPrefix(value, "./") || strings.HasPrefix(value, "../")
+}
@@ -0,0 +1,104 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"docker-deploy-app/internal/models"
+)
+
+// configDir is the directory of a stack that config files are rendered into,
+// for its compose file to bind-mount as ./config
+const configDir = "config"
+
+// bareVariable matches the Jinja-style {{ NAME }} form, rewritten to {{ .NAME }}
+// before parsing. Words that are template keywords or functions are left alone
+var bareVariable = regexp.MustCompile(`\{\{(-?\s*)([A-Za-z_][A-Za-z0-9_]*)(\s*-?)\}\}`)
+
+// configFileFuncs are the functions available to config file templates
+var configFileFuncs = template.FuncMap{
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	"quote": func(value string) string { return fmt.Sprintf("%q", value) },
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"bool": func(value string) bool {
+		switch strings.ToLower(value) {
+		case "1", "t", "true", "yes", "on":
+			return true
+		}
+		return false
+	},
+}
+
+// templateKeywords are the words of bare {{ word }} actions that are not variables
+var templateKeywords = map[string]bool{
+	"end": true, "else": true, "nil": true, "true": true, "false": true,
+	"break": true, "continue": true,
+}
+
+// RenderConfigFile renders a config file from deployment variables. Referring
+// to a variable that is not set is an error rather than an empty value
+func RenderConfigFile(file models.ConfigFile, vars map[string]string) ([]byte, error) {
+	source := bareVariable.ReplaceAllStringFunc(file.Content, func(action string) string {
+		parts := bareVariable.FindStringSubmatch(action)
+		if templateKeywords[parts[2]] || configFileFuncs[parts[2]] != nil {
+			return action
+		}
+		return "{{" + parts[1] + "." + parts[2] + parts[3] + "}}"
+	})
+
+	tmpl, err := template.New(file.Target).Funcs(configFileFuncs).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", file.Target, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return nil, fmt.Errorf("failed to render config file %s: %w", file.Target, err)
+	}
+	return out.Bytes(), nil
+}
+
+// WriteConfigFiles renders config files into the config directory of a stack,
+// replacing any rendered by an earlier deploy
+func (cm *ComposeManager) WriteConfigFiles(stackName string, files []models.ConfigFile, vars map[string]string) error {
+	baseDir := filepath.Join(cm.workDir, stackName, configDir)
+	for _, file := range files {
+		if err := file.Validate(); err != nil {
+			return err
+		}
+		content, err := RenderConfigFile(file, vars)
+		if err != nil {
+			return err
+		}
+		mode, _ := file.FileMode()
+
+		target := filepath.Join(baseDir, filepath.FromSlash(file.Target))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+		// Rename over the old file so services never read a partial one
+		tmp := target + ".tmp"
+		if err := os.WriteFile(tmp, content, os.FileMode(mode)); err != nil {
+			return fmt.Errorf("failed to write config file %s: %w", file.Target, err)
+		}
+		if err := os.Chmod(tmp, os.FileMode(mode)); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to write config file %s: %w", file.Target, err)
+		}
+		if err := os.Rename(tmp, target); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to write config file %s: %w", file.Target, err)
+		}
+	}
+	return nil
+}
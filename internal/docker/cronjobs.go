@@ -0,0 +1,431 @@
+package docker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/robfig/cron/v3"
+	"docker-deploy-app/internal/maintenance"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/webhooks"
+)
+
+const (
+	// cronOutputLimit is how much of the end of a run's output is kept
+	cronOutputLimit = 64 * 1024
+
+	// cronRunHistory is the number of runs kept per job
+	cronRunHistory = 50
+)
+
+// CronRunner runs the cron jobs of stacks in their service containers,
+// recording each run and alerting on failures
+type CronRunner struct {
+	db        *sql.DB
+	client    *client.Client
+	publisher *webhooks.Publisher
+	cron      *cron.Cron
+	jobs      map[int64]cron.EntryID
+	specs     map[int64]string
+	mu        sync.Mutex // Guards jobs and specs
+}
+
+// NewCronRunner creates a new stack cron job runner
+func NewCronRunner(db *sql.DB, dockerClient *client.Client) *CronRunner {
+	return &CronRunner{
+		db:        db,
+		client:    dockerClient,
+		publisher: webhooks.NewPublisher(db),
+		cron:      cron.New(),
+		jobs:      make(map[int64]cron.EntryID),
+		specs:     make(map[int64]string),
+	}
+}
+
+// Start fails runs interrupted by a restart, loads the enabled jobs and starts
+// the scheduler
+func (cr *CronRunner) Start() error {
+	if _, err := cr.db.Exec(`
+		UPDATE stack_cron_job_runs SET status = $1, error = $2, finished_at = $3
+		WHERE status = $4`,
+		models.CronRunFailed, "interrupted by a server restart", time.Now(), models.CronRunRunning); err != nil {
+		return err
+	}
+	if err := cr.loadJobs(); err != nil {
+		return err
+	}
+
+	// Jobs are edited through the API; pick up changes every minute
+	if _, err := cr.cron.AddFunc("@every 1m", func() {
+		if err := cr.loadJobs(); err != nil {
+			slog.Error("Failed to reload stack cron jobs", "error", err)
+		}
+	}); err != nil {
+		return err
+	}
+
+	cr.cron.Start()
+	slog.Info("Stack cron runner started")
+	return nil
+}
+
+// Stop stops the scheduler
+func (cr *CronRunner) Stop() {
+	cr.cron.Stop()
+	slog.Info("Stack cron runner stopped")
+}
+
+// List returns the cron jobs of a deployment
+func (cr *CronRunner) List(deploymentID string) ([]*models.CronJob, error) {
+	rows, err := cr.db.Query(`
+		SELECT id, deployment_id, name, schedule, timezone, service, command, timeout, enabled,
+		       alert_on_failure, last_run_at, last_status, created_by, created_at, updated_at
+		FROM stack_cron_jobs WHERE deployment_id = $1 ORDER BY name`, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []*models.CronJob{}
+	for rows.Next() {
+		job, err := scanCronJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Get returns a cron job of a deployment
+func (cr *CronRunner) Get(deploymentID string, jobID int64) (*models.CronJob, error) {
+	job, err := scanCronJob(cr.db.QueryRow(`
+		SELECT id, deployment_id, name, schedule, timezone, service, command, timeout, enabled,
+		       alert_on_failure, last_run_at, last_status, created_by, created_at, updated_at
+		FROM stack_cron_jobs WHERE id = $1 AND deployment_id = $2`, jobID, deploymentID))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrCronJobNotFound
+	}
+	return job, err
+}
+
+// Create saves a new cron job. The scheduler picks it up within a minute
+func (cr *CronRunner) Create(job *models.CronJob) error {
+	if err := job.Validate(); err != nil {
+		return err
+	}
+	now := time.Now()
+	job.CreatedAt, job.UpdatedAt = now, now
+
+	result, err := cr.db.Exec(`
+		INSERT INTO stack_cron_jobs (deployment_id, name, schedule, timezone, service, command, timeout,
+		                             enabled, alert_on_failure, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		job.DeploymentID, job.Name, job.Schedule, job.Timezone, job.Service, job.Command, job.Timeout,
+		job.Enabled, job.AlertOnFailure, job.CreatedBy, job.CreatedAt, job.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	job.ID, _ = result.LastInsertId()
+	job.Describe()
+	return nil
+}
+
+// Update saves the changes of a cron job
+func (cr *CronRunner) Update(job *models.CronJob) error {
+	if err := job.Validate(); err != nil {
+		return err
+	}
+	job.UpdatedAt = time.Now()
+
+	_, err := cr.db.Exec(`
+		UPDATE stack_cron_jobs
+		SET name = $1, schedule = $2, timezone = $3, service = $4, command = $5, timeout = $6,
+		    enabled = $7, alert_on_failure = $8, updated_at = $9
+		WHERE id = $10`,
+		job.Name, job.Schedule, job.Timezone, job.Service, job.Command, job.Timeout,
+		job.Enabled, job.AlertOnFailure, job.UpdatedAt, job.ID)
+	if err != nil {
+		return err
+	}
+	job.Describe()
+	return nil
+}
+
+// Delete removes a cron job with its run history
+func (cr *CronRunner) Delete(job *models.CronJob) error {
+	if _, err := cr.db.Exec("DELETE FROM stack_cron_job_runs WHERE job_id = $1", job.ID); err != nil {
+		return err
+	}
+	_, err := cr.db.Exec("DELETE FROM stack_cron_jobs WHERE id = $1", job.ID)
+	return err
+}
+
+// Runs returns the recorded runs of a job, newest first
+func (cr *CronRunner) Runs(jobID int64, limit int) ([]*models.CronJobRun, error) {
+	rows, err := cr.db.Query(`
+		SELECT id, job_id, triggered_by, status, exit_code, output, error, started_at, finished_at
+		FROM stack_cron_job_runs WHERE job_id = $1
+		ORDER BY started_at DESC, id DESC LIMIT $2`, jobID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []*models.CronJobRun{}
+	for rows.Next() {
+		var run models.CronJobRun
+		var exitCode sql.NullInt64
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&run.ID, &run.JobID, &run.Trigger, &run.Status, &exitCode,
+			&run.Output, &run.Error, &run.StartedAt, &finishedAt); err != nil {
+			return nil, err
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			run.ExitCode = &code
+		}
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
+
+// StartRun records a run of a job and runs it in the background. A job runs once
+// at a time; starting a running job fails with ErrCronJobRunning
+func (cr *CronRunner) StartRun(job *models.CronJob, stackName string, trigger models.CronRunTrigger) (*models.CronJobRun, error) {
+	run := &models.CronJobRun{
+		JobID:     job.ID,
+		Trigger:   trigger,
+		Status:    models.CronRunRunning,
+		StartedAt: time.Now(),
+	}
+	// The check and the insert are one statement, so concurrent starts cannot
+	// both pass
+	result, err := cr.db.Exec(`
+		INSERT INTO stack_cron_job_runs (job_id, triggered_by, status, started_at)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (SELECT 1 FROM stack_cron_job_runs WHERE job_id = $1 AND status = $3)`,
+		run.JobID, run.Trigger, run.Status, run.StartedAt)
+	if err != nil {
+		return nil, err
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		return nil, models.ErrCronJobRunning
+	}
+	run.ID, _ = result.LastInsertId()
+
+	go cr.execute(job, stackName, run)
+	return run, nil
+}
+
+// execute runs a job's command and records the outcome of the run
+func (cr *CronRunner) execute(job *models.CronJob, stackName string, run *models.CronJobRun) {
+	timeout := defaultHookTimeout
+	if job.Timeout > 0 {
+		timeout = time.Duration(job.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output := &tailBuffer{limit: cronOutputLimit}
+	exitCode, err := cr.exec(ctx, stackName, job, output)
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Output = output.String()
+	run.Status = models.CronRunSucceeded
+	switch {
+	case err != nil:
+		run.Status = models.CronRunFailed
+		run.Error = err.Error()
+	case exitCode != 0:
+		run.Status = models.CronRunFailed
+		run.ExitCode = &exitCode
+		run.Error = fmt.Sprintf("command exited with code %d", exitCode)
+	default:
+		run.ExitCode = &exitCode
+	}
+
+	if _, dbErr := cr.db.Exec(`
+		UPDATE stack_cron_job_runs SET status = $1, exit_code = $2, output = $3, error = $4, finished_at = $5
+		WHERE id = $6`,
+		run.Status, run.ExitCode, run.Output, run.Error, run.FinishedAt, run.ID); dbErr != nil {
+		slog.Error("Failed to record cron job run", "job_id", job.ID, "run_id", run.ID, "error", dbErr)
+	}
+	cr.db.Exec("UPDATE stack_cron_jobs SET last_run_at = $1, last_status = $2 WHERE id = $3",
+		run.StartedAt, run.Status, job.ID)
+	cr.db.Exec(`
+		DELETE FROM stack_cron_job_runs
+		WHERE job_id = $1 AND id NOT IN (
+			SELECT id FROM stack_cron_job_runs WHERE job_id = $1 ORDER BY started_at DESC, id DESC LIMIT $2)`,
+		job.ID, cronRunHistory)
+
+	if run.Status == models.CronRunSucceeded {
+		slog.Info("Cron job succeeded", "stack", stackName, "job", job.Name, "duration", finishedAt.Sub(run.StartedAt))
+		return
+	}
+	slog.Warn("Cron job failed", "stack", stackName, "job", job.Name, "error", run.Error)
+	if job.AlertOnFailure {
+		cr.publisher.Publish(models.WebhookEventCronJobFailed, map[string]interface{}{
+			"deployment_id": job.DeploymentID,
+			"stack_name":    stackName,
+			"job_id":        job.ID,
+			"job":           job.Name,
+			"service":       job.Service,
+			"run_id":        run.ID,
+			"trigger":       run.Trigger,
+			"exit_code":     run.ExitCode,
+			"error":         run.Error,
+		})
+	}
+}
+
+// exec runs a job's command in a container of its service
+func (cr *CronRunner) exec(ctx context.Context, stackName string, job *models.CronJob, output *tailBuffer) (int, error) {
+	containerID, err := serviceContainer(ctx, cr.client, stackName, job.Service)
+	if err != nil {
+		return 0, err
+	}
+	return execCommand(ctx, cr.client, containerID, job.Command, nil, output)
+}
+
+// loadJobs synchronizes cron entries with the enabled jobs of running stacks
+func (cr *CronRunner) loadJobs() error {
+	rows, err := cr.db.Query(`
+		SELECT j.id, j.schedule, j.timezone
+		FROM stack_cron_jobs j
+		JOIN deployments d ON d.id = j.deployment_id
+		WHERE j.enabled = TRUE AND d.status = 'running'`)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[int64]string)
+	for rows.Next() {
+		var job models.CronJob
+		if err := rows.Scan(&job.ID, &job.Schedule, &job.Timezone); err != nil {
+			continue
+		}
+		wanted[job.ID] = job.CronSpec()
+	}
+	rows.Close()
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	for id, entryID := range cr.jobs {
+		if spec, ok := wanted[id]; !ok || spec != cr.specs[id] {
+			cr.cron.Remove(entryID)
+			delete(cr.jobs, id)
+			delete(cr.specs, id)
+		}
+	}
+
+	for id, spec := range wanted {
+		if _, exists := cr.jobs[id]; exists {
+			continue
+		}
+		jobID := id
+		entryID, err := cr.cron.AddFunc(spec, func() {
+			cr.runScheduled(jobID)
+		})
+		if err != nil {
+			slog.Error("Failed to schedule stack cron job", "job_id", id, "error", err)
+			continue
+		}
+		cr.jobs[id] = entryID
+		cr.specs[id] = spec
+	}
+	return nil
+}
+
+// runScheduled runs a job at its scheduled time, unless it has been disabled
+// or removed since the last reload
+func (cr *CronRunner) runScheduled(jobID int64) {
+	if maintenance.Active(cr.db) {
+		slog.Info("Skipping stack cron job during maintenance", "job_id", jobID)
+		return
+	}
+
+	var deploymentID, stackName string
+	err := cr.db.QueryRow(`
+		SELECT j.deployment_id, d.stack_name
+		FROM stack_cron_jobs j
+		JOIN deployments d ON d.id = j.deployment_id
+		WHERE j.id = $1 AND j.enabled = TRUE AND d.status = 'running'`, jobID).Scan(&deploymentID, &stackName)
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to load stack cron job", "job_id", jobID, "error", err)
+		return
+	}
+
+	job, err := cr.Get(deploymentID, jobID)
+	if err != nil {
+		slog.Error("Failed to load stack cron job", "job_id", jobID, "error", err)
+		return
+	}
+	if _, err := cr.StartRun(job, stackName, models.CronTriggerSchedule); err == models.ErrCronJobRunning {
+		slog.Warn("Skipping stack cron job that is still running", "stack", stackName, "job", job.Name)
+	} else if err != nil {
+		slog.Error("Failed to start stack cron job", "stack", stackName, "job", job.Name, "error", err)
+	}
+}
+
+// scanCronJob scans a cron job from a database row
+func scanCronJob(scanner interface {
+	Scan(dest ...interface{}) error
+}) (*models.CronJob, error) {
+	var job models.CronJob
+	var lastRunAt sql.NullTime
+	var lastStatus, createdBy sql.NullString
+	err := scanner.Scan(&job.ID, &job.DeploymentID, &job.Name, &job.Schedule, &job.Timezone,
+		&job.Service, &job.Command, &job.Timeout, &job.Enabled, &job.AlertOnFailure,
+		&lastRunAt, &lastStatus, &createdBy, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastRunAt.Valid {
+		job.LastRunAt = &lastRunAt.Time
+	}
+	job.LastStatus = models.CronRunStatus(lastStatus.String)
+	job.CreatedBy = createdBy.String
+	job.Describe()
+	return &job, nil
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	limit     int
+	data      []byte
+	truncated bool
+}
+
+func (tb *tailBuffer) Write(p []byte) (int, error) {
+	tb.data = append(tb.data, p...)
+	if len(tb.data) > tb.limit {
+		tb.data = append(tb.data[:0], tb.data[len(tb.data)-tb.limit:]...)
+		tb.truncated = true
+	}
+	return len(p), nil
+}
+
+// String returns the kept output, marked when its start was dropped
+func (tb *tailBuffer) String() string {
+	if tb.truncated {
+		return "[output truncated]\n" + string(tb.data)
+	}
+	return string(tb.data)
+}
@@ -0,0 +1,246 @@
+package docker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+)
+
+const (
+	// footprintConcurrency bounds the containers sampled at once
+	footprintConcurrency = 8
+
+	// footprintPruneInterval is how often old samples are pruned
+	footprintPruneInterval = time.Hour
+)
+
+// FootprintSampler records the CPU and memory usage of the running containers
+// of every running deployment on an interval, and estimates the monthly
+// footprint of deployments and projects from these samples
+type FootprintSampler struct {
+	db     *sql.DB
+	client *client.Client
+	config config.FootprintConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewFootprintSampler creates a new footprint sampler
+func NewFootprintSampler(db *sql.DB, dockerClient *client.Client, cfg config.FootprintConfig) *FootprintSampler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &FootprintSampler{
+		db:     db,
+		client: dockerClient,
+		config: cfg,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start begins sampling deployments every sample interval
+func (fs *FootprintSampler) Start() {
+	go func() {
+		ticker := time.NewTicker(time.Duration(fs.config.SampleInterval) * time.Second)
+		defer ticker.Stop()
+		lastPrune := time.Time{}
+		for {
+			select {
+			case <-ticker.C:
+				fs.sample()
+				if time.Since(lastPrune) >= footprintPruneInterval {
+					fs.prune()
+					lastPrune = time.Now()
+				}
+			case <-fs.ctx.Done():
+				return
+			}
+		}
+	}()
+	slog.Info("Footprint sampler started", "interval", fs.config.SampleInterval)
+}
+
+// Stop stops sampling
+func (fs *FootprintSampler) Stop() {
+	fs.cancel()
+	slog.Info("Footprint sampler stopped")
+}
+
+// Estimate returns the footprint of a deployment over the window, named name.
+// A deployment without samples in the window has an empty footprint
+func (fs *FootprintSampler) Estimate(deploymentID, name string, window time.Duration) (*models.Footprint, error) {
+	footprint := &models.Footprint{DeploymentID: deploymentID, Window: name}
+
+	var since sql.NullTime
+	var avgCPU, peakCPU, avgMemory sql.NullFloat64
+	var peakMemory sql.NullInt64
+	err := fs.db.QueryRow(`
+		SELECT COUNT(*), MIN(sampled_at), AVG(cpu_usage), MAX(cpu_usage), AVG(memory_usage), MAX(memory_usage)
+		FROM resource_samples
+		WHERE deployment_id = $1 AND sampled_at >= $2`,
+		deploymentID, time.Now().Add(-window),
+	).Scan(&footprint.Samples, &since, &avgCPU, &peakCPU, &avgMemory, &peakMemory)
+	if err != nil {
+		return nil, err
+	}
+
+	if since.Valid {
+		footprint.Since = &since.Time
+	}
+	footprint.AvgCPUCores = avgCPU.Float64 / 100
+	footprint.PeakCPUCores = peakCPU.Float64 / 100
+	footprint.AvgMemory = int64(avgMemory.Float64)
+	footprint.PeakMemory = peakMemory.Int64
+	footprint.Estimate(fs.config.WattsPerCore)
+	return footprint, nil
+}
+
+// EstimateProject returns the footprint of the deployments of a project over
+// the window, with the footprint of each
+func (fs *FootprintSampler) EstimateProject(projectID, name string, window time.Duration) (*models.Footprint, error) {
+	rows, err := fs.db.Query(`
+		SELECT id, stack_name FROM deployments
+		WHERE COALESCE(project_id, 'global') = $1
+		ORDER BY stack_name`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	type deployment struct{ id, stackName string }
+	var deployments []deployment
+	for rows.Next() {
+		var d deployment
+		if err := rows.Scan(&d.id, &d.stackName); err == nil {
+			deployments = append(deployments, d)
+		}
+	}
+	rows.Close()
+
+	footprint := &models.Footprint{ProjectID: projectID, Window: name, Deployments: []models.Footprint{}}
+	for _, d := range deployments {
+		deploymentFootprint, err := fs.Estimate(d.id, name, window)
+		if err != nil {
+			return nil, err
+		}
+		deploymentFootprint.StackName = d.stackName
+		footprint.Add(deploymentFootprint)
+		footprint.Deployments = append(footprint.Deployments, *deploymentFootprint)
+	}
+	footprint.Estimate(fs.config.WattsPerCore)
+	return footprint, nil
+}
+
+// sample records the usage of every running deployment
+func (fs *FootprintSampler) sample() {
+	rows, err := fs.db.Query("SELECT id, stack_name FROM deployments WHERE status = 'running'")
+	if err != nil {
+		slog.Error("Failed to load deployments to sample", "error", err)
+		return
+	}
+	deployments := make(map[string]string) // Stack name to deployment ID
+	for rows.Next() {
+		var id, stackName string
+		if err := rows.Scan(&id, &stackName); err == nil {
+			deployments[stackName] = id
+		}
+	}
+	rows.Close()
+	if len(deployments) == 0 {
+		return
+	}
+
+	samples := make(map[string]*models.ResourceSample)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, footprintConcurrency)
+	for _, label := range []string{"com.docker.compose.project", "com.docker.stack.namespace"} {
+		containers, err := fs.client.ContainerList(fs.ctx, types.ContainerListOptions{
+			Filters: filters.NewArgs(filters.Arg("label", label)),
+		})
+		if err != nil {
+			slog.Error("Failed to list containers to sample", "error", err)
+			return
+		}
+		for _, c := range containers {
+			deploymentID, ok := deployments[c.Labels[label]]
+			if !ok {
+				continue
+			}
+
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(containerID, deploymentID string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				cpu, memory, err := fs.containerUsage(containerID)
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				s := samples[deploymentID]
+				if s == nil {
+					s = &models.ResourceSample{DeploymentID: deploymentID}
+					samples[deploymentID] = s
+				}
+				s.CPUUsage += cpu
+				s.MemoryUsage += memory
+				s.Containers++
+			}(c.ID, deploymentID)
+		}
+	}
+	wg.Wait()
+
+	now := time.Now()
+	for _, s := range samples {
+		_, err := fs.db.Exec(`
+			INSERT INTO resource_samples (deployment_id, cpu_usage, memory_usage, containers, sampled_at)
+			VALUES ($1, $2, $3, $4, $5)`,
+			s.DeploymentID, s.CPUUsage, s.MemoryUsage, s.Containers, now,
+		)
+		if err != nil {
+			slog.Error("Failed to record resource sample", "deployment_id", s.DeploymentID, "error", err)
+		}
+	}
+}
+
+// containerUsage returns the CPU usage, in percent of one CPU, and the memory
+// usage of a container. Taking the sample takes about a second
+func (fs *FootprintSampler) containerUsage(containerID string) (float64, int64, error) {
+	ctx, cancel := context.WithTimeout(fs.ctx, statsSampleTimeout)
+	defer cancel()
+
+	response, err := fs.client.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer response.Body.Close()
+
+	var stats types.StatsJSON
+	if err := json.NewDecoder(response.Body).Decode(&stats); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode stats: %w", err)
+	}
+	return calculateCPUUsage(&stats), int64(stats.MemoryStats.Usage), nil
+}
+
+// prune removes samples older than the retention period
+func (fs *FootprintSampler) prune() {
+	cutoff := time.Now().AddDate(0, 0, -fs.config.RetentionDays)
+	result, err := fs.db.Exec("DELETE FROM resource_samples WHERE sampled_at < $1", cutoff)
+	if err != nil {
+		slog.Error("Failed to prune resource samples", "error", err)
+		return
+	}
+	if pruned, _ := result.RowsAffected(); pruned > 0 {
+		slog.Info("Pruned resource samples", "count", pruned)
+	}
+}
@@ -0,0 +1,147 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"gopkg.in/yaml.v3"
+	"docker-deploy-app/internal/models"
+)
+
+// gpuRuntimes are the Docker runtimes that give containers GPUs
+var gpuRuntimes = []string{"nvidia"}
+
+// gpuCapability reads GPU support from the Docker engine information. Devices are
+// listed with nvidia-smi, which only sees them when Docker runs on this machine
+func gpuCapability(ctx context.Context, info types.Info, local bool) models.GPUCapability {
+	gpu := models.GPUCapability{Devices: []models.GPUDevice{}, Checked: true}
+	for _, runtime := range gpuRuntimes {
+		if _, ok := info.Runtimes[runtime]; ok {
+			gpu.Available = true
+			gpu.Runtime = runtime
+			gpu.Default = info.DefaultRuntime == runtime
+			break
+		}
+	}
+	if gpu.Available && gpu.Runtime == "nvidia" && local {
+		if devices, err := nvidiaDevices(ctx); err == nil {
+			gpu.Devices = devices
+		}
+	}
+	return gpu
+}
+
+// nvidiaDevices lists the NVIDIA GPUs of this machine
+func nvidiaDevices(ctx context.Context) ([]models.GPUDevice, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "nvidia-smi", "--query-gpu=index,name,memory.total",
+		"--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []models.GPUDevice{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			continue
+		}
+		device := models.GPUDevice{Index: index, Name: strings.TrimSpace(fields[1])}
+		if mib, err := strconv.ParseInt(strings.TrimSpace(fields[2]), 10, 64); err == nil {
+			device.MemoryBytes = mib * 1024 * 1024
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// InjectGPU reserves the GPUs a template needs for its services, as compose
+// deploy.resources.reservations.devices entries. Services that already reserve
+// devices are left as they are
+func InjectGPU(composeContent []byte, gpu *models.TemplateGPU) ([]byte, error) {
+	if gpu == nil {
+		return composeContent, nil
+	}
+
+	doc, err := parseComposeDocument(composeContent)
+	if err != nil {
+		return nil, err
+	}
+
+	services := gpu.Services
+	if len(services) == 0 {
+		for _, name := range doc.serviceNames() {
+			if doc.decodeService(name).Image != "" || mappingValue(doc.service(name), "build") != nil {
+				services = append(services, name)
+			}
+		}
+	}
+
+	for _, name := range services {
+		service := doc.service(name)
+		if service == nil {
+			return nil, fmt.Errorf("gpu requirement references unknown service: %s", name)
+		}
+
+		reservations := childMapping(childMapping(childMapping(service, "deploy"), "resources"), "reservations")
+		if devices := mappingValue(reservations, "devices"); devices != nil && len(devices.Content) > 0 {
+			continue
+		}
+		setMappingValue(reservations, "devices", &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{gpuDeviceNode(gpu)}})
+	}
+
+	return doc.Bytes()
+}
+
+// gpuDeviceNode renders a device reservation for a GPU requirement
+func gpuDeviceNode(gpu *models.TemplateGPU) *yaml.Node {
+	driver := gpu.Driver
+	if driver == "" {
+		driver = "nvidia"
+	}
+	count := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "all"}
+	if gpu.Count > 0 {
+		count = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(gpu.Count)}
+	}
+	capabilities := gpu.Capabilities
+	if len(capabilities) == 0 {
+		capabilities = []string{"gpu"}
+	}
+	capabilityNodes := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, capability := range capabilities {
+		capabilityNodes.Content = append(capabilityNodes.Content, scalarNode(capability))
+	}
+
+	device := &yaml.Node{Kind: yaml.MappingNode}
+	setMappingValue(device, "driver", scalarNode(driver))
+	setMappingValue(device, "count", count)
+	setMappingValue(device, "capabilities", capabilityNodes)
+	return device
+}
+
+// childMapping returns the mapping under a key of a mapping, creating it or
+// replacing a null value
+func childMapping(node *yaml.Node, key string) *yaml.Node {
+	child := mappingValue(node, key)
+	if child != nil && child.Kind == yaml.MappingNode {
+		return child
+	}
+	mapping := &yaml.Node{Kind: yaml.MappingNode}
+	if child != nil {
+		*child = *mapping
+		return child
+	}
+	setMappingValue(node, key, mapping)
+	return mapping
+}
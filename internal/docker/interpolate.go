@@ -0,0 +1,306 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"docker-deploy-app/internal/models"
+)
+
+// placeholderPattern matches compose placeholders: $$, $NAME and ${NAME[modifier]}
+var placeholderPattern = regexp.MustCompile(`\$(\$|[A-Za-z_][A-Za-z0-9_]*|\{[^}]*\})`)
+
+// variableNamePattern matches a valid compose variable name
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// placeholder is a parsed ${NAME<op>arg} reference
+type placeholder struct {
+	Name string
+	Op   string // "", ":-", "-", ":?", "?", ":+" or "+"
+	Arg  string
+}
+
+// InterpolationError lists the required variables missing during interpolation
+type InterpolationError struct {
+	Missing map[string]string // Variable name to the message from ${NAME:?message}
+}
+
+func (e *InterpolationError) Error() string {
+	names := make([]string, 0, len(e.Missing))
+	for name := range e.Missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		if e.Missing[name] != "" {
+			parts = append(parts, fmt.Sprintf("%s (%s)", name, e.Missing[name]))
+		} else {
+			parts = append(parts, name)
+		}
+	}
+	return fmt.Sprintf("missing required variables: %s", strings.Join(parts, ", "))
+}
+
+// Interpolate substitutes placeholders the way docker compose does. Unset variables
+// without a default become empty; ${NAME:?msg} and ${NAME?msg} fail with an
+// *InterpolationError naming every missing variable
+func Interpolate(content []byte, env map[string]string) ([]byte, error) {
+	missing := make(map[string]string)
+
+	result := placeholderPattern.ReplaceAllStringFunc(string(content), func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+
+		ref, ok := parsePlaceholder(match)
+		if !ok {
+			return match
+		}
+
+		value, set := env[ref.Name]
+		switch ref.Op {
+		case ":-":
+			if value == "" {
+				return ref.Arg
+			}
+		case "-":
+			if !set {
+				return ref.Arg
+			}
+		case ":?":
+			if value == "" {
+				missing[ref.Name] = ref.Arg
+			}
+		case "?":
+			if !set {
+				missing[ref.Name] = ref.Arg
+			}
+		case ":+":
+			if value != "" {
+				return ref.Arg
+			}
+			return ""
+		case "+":
+			if set {
+				return ref.Arg
+			}
+			return ""
+		}
+		return value
+	})
+
+	if len(missing) > 0 {
+		return nil, &InterpolationError{Missing: missing}
+	}
+	return []byte(result), nil
+}
+
+// ExtractVariables returns a template variable for each distinct placeholder in
+// compose content, with defaults from ${NAME:-default} and required flags from ${NAME:?msg}
+func ExtractVariables(content []byte) []models.TemplateVariable {
+	var variables []models.TemplateVariable
+	index := make(map[string]int)
+
+	for _, match := range placeholderPattern.FindAllString(string(content), -1) {
+		ref, ok := parsePlaceholder(match)
+		if !ok {
+			continue
+		}
+
+		i, seen := index[ref.Name]
+		if !seen {
+			i = len(variables)
+			index[ref.Name] = i
+			variables = append(variables, newTemplateVariable(ref.Name, ""))
+		}
+
+		variable := &variables[i]
+		switch ref.Op {
+		case ":-", "-":
+			if variable.DefaultValue == "" {
+				variable.DefaultValue = ref.Arg
+				variable.Type = guessVariableType(ref.Name, ref.Arg)
+			}
+		case ":?", "?":
+			variable.Required = true
+			if ref.Arg != "" && variable.Description == "" {
+				variable.Description = ref.Arg
+			}
+		}
+	}
+
+	return variables
+}
+
+// ParseEnvExample returns a template variable for each entry of a .env.example file.
+// Comment lines directly above an entry become its description, and entries
+// without a value are treated as required
+func ParseEnvExample(content []byte) []models.TemplateVariable {
+	var variables []models.TemplateVariable
+	var comments []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			comments = nil
+			continue
+		case strings.HasPrefix(line, "#"):
+			comments = append(comments, strings.TrimSpace(strings.TrimPrefix(line, "#")))
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		parts := strings.SplitN(line, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if !variableNamePattern.MatchString(name) {
+			comments = nil
+			continue
+		}
+
+		value := ""
+		if len(parts) == 2 {
+			value = unquoteEnvValue(strings.TrimSpace(parts[1]))
+		}
+
+		variable := newTemplateVariable(name, value)
+		variable.Required = value == ""
+		variable.Description = strings.Join(comments, " ")
+		variables = append(variables, variable)
+		comments = nil
+	}
+
+	return variables
+}
+
+// MergeVariables adds discovered variables to declared ones. Declared variables win;
+// discovered ones only fill in a missing default or description, or mark a variable
+// required when compose would refuse to run without it
+func MergeVariables(declared []models.TemplateVariable, discovered ...[]models.TemplateVariable) []models.TemplateVariable {
+	merged := append([]models.TemplateVariable{}, declared...)
+	index := make(map[string]int)
+	for i, variable := range merged {
+		index[variable.Name] = i
+	}
+
+	for _, set := range discovered {
+		for _, variable := range set {
+			i, exists := index[variable.Name]
+			if !exists {
+				index[variable.Name] = len(merged)
+				merged = append(merged, variable)
+				continue
+			}
+			if merged[i].DefaultValue == "" {
+				merged[i].DefaultValue = variable.DefaultValue
+			}
+			if merged[i].Description == "" {
+				merged[i].Description = variable.Description
+			}
+			merged[i].Required = merged[i].Required || variable.Required
+		}
+	}
+
+	return merged
+}
+
+// parsePlaceholder parses a matched placeholder; $$ and malformed references are not placeholders
+func parsePlaceholder(match string) (placeholder, bool) {
+	if match == "$$" {
+		return placeholder{}, false
+	}
+	if !strings.HasPrefix(match, "${") {
+		return placeholder{Name: match[1:]}, true
+	}
+
+	body := match[2 : len(match)-1]
+	end := 0
+	for end < len(body) && (body[end] == '_' || isAlphaNumeric(body[end])) {
+		end++
+	}
+
+	ref := placeholder{Name: body[:end]}
+	if !variableNamePattern.MatchString(ref.Name) {
+		return placeholder{}, false
+	}
+
+	rest := body[end:]
+	for _, op := range []string{":-", ":?", ":+", "-", "?", "+"} {
+		if strings.HasPrefix(rest, op) {
+			ref.Op = op
+			ref.Arg = rest[len(op):]
+			return ref, true
+		}
+	}
+	return ref, rest == ""
+}
+
+// newTemplateVariable creates a variable with a label and type guessed from its name
+func newTemplateVariable(name, defaultValue string) models.TemplateVariable {
+	return models.TemplateVariable{
+		Name:         name,
+		Label:        variableLabel(name),
+		Type:         guessVariableType(name, defaultValue),
+		DefaultValue: defaultValue,
+	}
+}
+
+// variableLabel turns DB_HOST_NAME into "Db Host Name"
+func variableLabel(name string) string {
+	words := strings.Fields(strings.ReplaceAll(strings.ToLower(name), "_", " "))
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// guessVariableType picks a variable type from its name and default value
+func guessVariableType(name, defaultValue string) string {
+	if IsSecretVariable(name) {
+		return "password"
+	}
+
+	switch strings.ToLower(defaultValue) {
+	case "true", "false":
+		return "boolean"
+	}
+	if _, err := strconv.Atoi(defaultValue); err == nil {
+		return "number"
+	}
+	return "text"
+}
+
+// IsSecretVariable reports whether a variable name looks like it holds a
+// secret, such as DB_PASSWORD or GITHUB_TOKEN
+func IsSecretVariable(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, secret := range []string{"PASSWORD", "SECRET", "TOKEN", "API_KEY", "PRIVATE_KEY"} {
+		if strings.Contains(upper, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// unquoteEnvValue strips matching quotes or a trailing comment from a .env value
+func unquoteEnvValue(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
+
+func isAlphaNumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,234 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"docker-deploy-app/internal/models"
+)
+
+// defaultHookTimeout bounds a lifecycle hook that does not set its own timeout
+const defaultHookTimeout = 300 * time.Second
+
+// HookOutput receives the output of a lifecycle hook, a line at a time
+type HookOutput func(hook models.LifecycleHook, line string)
+
+// HookRunner runs the lifecycle hooks of a stack, in a service's container or
+// in one-shot containers
+type HookRunner struct {
+	client *client.Client
+}
+
+// NewHookRunner creates a new lifecycle hook runner
+func NewHookRunner(dockerClient *client.Client) *HookRunner {
+	return &HookRunner{client: dockerClient}
+}
+
+// RunPhase runs the hooks of a phase in their declared order, with env set in
+// their environment. A failed hook stops the phase with an error if it is
+// required; others are logged and the phase goes on
+func (hr *HookRunner) RunPhase(ctx context.Context, stackName string, phase models.HookPhase, hooks []models.LifecycleHook, env map[string]string, output HookOutput) error {
+	for _, hook := range models.HooksForPhase(hooks, phase) {
+		err := hr.run(ctx, stackName, hook, envList(env), output)
+		if err == nil {
+			continue
+		}
+		if hook.Required {
+			return fmt.Errorf("%s hook %q failed: %w", phase, hook.DisplayName(), err)
+		}
+		slog.Warn("Lifecycle hook failed", "stack", stackName, "phase", phase, "hook", hook.DisplayName(), "error", err)
+	}
+	return nil
+}
+
+// run runs a single hook within its timeout
+func (hr *HookRunner) run(ctx context.Context, stackName string, hook models.LifecycleHook, env []string, output HookOutput) error {
+	timeout := defaultHookTimeout
+	if hook.Timeout > 0 {
+		timeout = time.Duration(hook.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	writer := &lineWriter{emit: func(line string) {
+		if output != nil {
+			output(hook, line)
+		}
+	}}
+	defer writer.Flush()
+
+	var serviceID string
+	if hook.Service != "" {
+		id, err := serviceContainer(ctx, hr.client, stackName, hook.Service)
+		if err != nil {
+			return err
+		}
+		serviceID = id
+	}
+
+	var err error
+	if hook.Image != "" {
+		err = hr.runContainer(ctx, stackName, hook, serviceID, env, writer)
+	} else {
+		var exitCode int
+		exitCode, err = execCommand(ctx, hr.client, serviceID, hook.Command, env, writer)
+		if err == nil && exitCode != 0 {
+			err = fmt.Errorf("command exited with code %d", exitCode)
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// execCommand runs a command with sh in a running container, writing its
+// output to output, and returns its exit code
+func execCommand(ctx context.Context, dockerClient *client.Client, containerID, command string, env []string, output io.Writer) (int, error) {
+	created, err := dockerClient.ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		Cmd:          []string{"sh", "-c", command},
+		Env:          env,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	resp, err := dockerClient.ContainerExecAttach(ctx, created.ID, types.ExecStartCheck{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to start exec: %w", err)
+	}
+	defer resp.Close()
+
+	if _, err := stdcopy.StdCopy(output, output, resp.Reader); err != nil {
+		return 0, fmt.Errorf("failed to read command output: %w", err)
+	}
+
+	inspect, err := dockerClient.ContainerExecInspect(ctx, created.ID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect exec: %w", err)
+	}
+	return inspect.ExitCode, nil
+}
+
+// runContainer runs a hook command in a one-shot container of the hook's
+// image. With a service container, it shares its volumes and network
+func (hr *HookRunner) runContainer(ctx context.Context, stackName string, hook models.LifecycleHook, serviceID string, env []string, output io.Writer) error {
+	if err := ensureImage(ctx, hr.client, hook.Image); err != nil {
+		return err
+	}
+
+	hostConfig := &container.HostConfig{}
+	if serviceID != "" {
+		hostConfig.VolumesFrom = []string{serviceID}
+		hostConfig.NetworkMode = container.NetworkMode("container:" + serviceID)
+	}
+	resp, err := hr.client.ContainerCreate(ctx, &container.Config{
+		Image: hook.Image,
+		Cmd:   []string{"sh", "-c", hook.Command},
+		Env:   env,
+		Labels: map[string]string{
+			"app.managed": "true",
+			"app.hook":    string(hook.Phase),
+			"app.stack":   stackName,
+		},
+	}, hostConfig, nil, nil, "")
+	if err != nil {
+		return fmt.Errorf("failed to create hook container: %w", err)
+	}
+	defer func() {
+		removeCtx, cancel := context.WithTimeout(context.Background(), helperRemoveTimeout)
+		defer cancel()
+		hr.client.ContainerRemove(removeCtx, resp.ID, types.ContainerRemoveOptions{Force: true})
+	}()
+
+	if err := hr.client.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return fmt.Errorf("failed to start hook container: %w", err)
+	}
+
+	logs, err := hr.client.ContainerLogs(ctx, resp.ID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true, Follow: true})
+	if err != nil {
+		return fmt.Errorf("failed to read hook output: %w", err)
+	}
+	defer logs.Close()
+	if _, err := stdcopy.StdCopy(output, output, logs); err != nil {
+		return fmt.Errorf("failed to read hook output: %w", err)
+	}
+
+	statusCh, errCh := hr.client.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("command exited with code %d", status.StatusCode)
+		}
+		return nil
+	case err := <-errCh:
+		return fmt.Errorf("failed to wait for hook container: %w", err)
+	}
+}
+
+// serviceContainer returns the ID of a running container of a compose service
+func serviceContainer(ctx context.Context, dockerClient *client.Client, stackName, service string) (string, error) {
+	containers, err := dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", "com.docker.compose.project="+stackName),
+			filters.Arg("label", "com.docker.compose.service="+service),
+		),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list containers: %w", err)
+	}
+	if len(containers) == 0 {
+		return "", fmt.Errorf("service %s has no running container", service)
+	}
+	return containers[0].ID, nil
+}
+
+// envList turns variables into KEY=value pairs, sorted for a stable order
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for key, value := range env {
+		list = append(list, key+"="+value)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// lineWriter calls emit with each complete line written to it
+type lineWriter struct {
+	emit func(line string)
+	buf  bytes.Buffer
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.buf.Write(p)
+	for {
+		line, err := lw.buf.ReadString('\n')
+		if err != nil {
+			// Keep the partial line for the next write
+			lw.buf.WriteString(line)
+			return len(p), nil
+		}
+		lw.emit(strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// Flush emits a trailing line that has no newline
+func (lw *lineWriter) Flush() {
+	if lw.buf.Len() > 0 {
+		lw.emit(strings.TrimRight(lw.buf.String(), "\r\n"))
+		lw.buf.Reset()
+	}
+}
@@ -2,9 +2,10 @@ package docker
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
-	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -12,12 +13,33 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/bus"
+	"docker-deploy-app/internal/config"
 	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/webhooks"
 )
 
-// Monitor watches Docker events and container status
+// eventPruneInterval is how often recorded stack events are pruned
+const eventPruneInterval = time.Hour
+
+// recordedActions are the container actions persisted as stack events
+var recordedActions = map[string]bool{
+	models.StackEventStart: true,
+	models.StackEventStop:  true,
+	models.StackEventDie:   true,
+	models.StackEventOOM:   true,
+}
+
+// Monitor watches Docker events and container status. Container lifecycle events
+// of deployed stacks are recorded whether or not anyone is subscribed, and
+// services that keep dying are flagged as restart loops. Container events go out
+// on the bus, so subscribers in every replica receive them
 type Monitor struct {
 	client      *client.Client
+	db          *sql.DB
+	config      config.MonitoringConfig
+	publisher   *webhooks.Publisher
+	events      bus.Bus
 	ctx         context.Context
 	cancel      context.CancelFunc
 	subscribers map[string][]chan *MonitorEvent
@@ -38,11 +60,15 @@ type MonitorEvent struct {
 }
 
 // NewMonitor creates a new Docker monitor
-func NewMonitor(dockerClient *client.Client) *Monitor {
+func NewMonitor(dockerClient *client.Client, db *sql.DB, cfg config.MonitoringConfig) *Monitor {
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	return &Monitor{
 		client:      dockerClient,
+		db:          db,
+		config:      cfg,
+		publisher:   webhooks.NewPublisher(db),
+		events:      bus.Default(),
 		ctx:         ctx,
 		cancel:      cancel,
 		subscribers: make(map[string][]chan *MonitorEvent),
@@ -51,20 +77,26 @@ func NewMonitor(dockerClient *client.Client) *Monitor {
 
 // Start begins monitoring Docker events
 func (m *Monitor) Start() error {
-	log.Println("Starting Docker monitor...")
+	slog.Info("Starting Docker monitor")
 
 	// Start event monitoring goroutine
 	go m.monitorEvents()
 
+	// Hand the events on the bus to local subscribers
+	go m.relayEvents()
+
 	// Start periodic status updates
 	go m.periodicStatusUpdate()
 
+	// Start pruning of recorded events
+	go m.periodicPrune()
+
 	return nil
 }
 
 // Stop stops the Docker monitor
 func (m *Monitor) Stop() {
-	log.Println("Stopping Docker monitor...")
+	slog.Info("Stopping Docker monitor")
 	m.cancel()
 }
 
@@ -99,19 +131,28 @@ func (m *Monitor) Unsubscribe(stackName string, ch chan *MonitorEvent) {
 	}
 }
 
-// monitorEvents listens for Docker events
+// monitorEvents listens for Docker events, subscribing again when the stream ends
 func (m *Monitor) monitorEvents() {
-	eventsCh, errCh := m.client.Events(m.ctx, types.EventsOptions{})
-
 	for {
-		select {
-		case event := <-eventsCh:
-			m.handleDockerEvent(event)
-		case err := <-errCh:
-			if err != nil {
-				log.Printf("Docker events error: %v", err)
-				time.Sleep(5 * time.Second) // Reconnect delay
+		eventsCh, errCh := m.client.Events(m.ctx, types.EventsOptions{})
+
+	stream:
+		for {
+			select {
+			case event := <-eventsCh:
+				m.handleDockerEvent(event)
+			case err := <-errCh:
+				if err != nil && m.ctx.Err() == nil {
+					slog.Error("Docker events error", "error", err)
+				}
+				break stream
+			case <-m.ctx.Done():
+				return
 			}
+		}
+
+		select {
+		case <-time.After(5 * time.Second): // Reconnect delay
 		case <-m.ctx.Done():
 			return
 		}
@@ -129,35 +170,135 @@ func (m *Monitor) handleDockerEvent(event events.Message) {
 		containerID = containerID[:12] // Short ID
 	}
 
-	// Get container info
-	container, err := m.client.ContainerInspect(m.ctx, event.Actor.ID)
-	if err != nil {
-		return
+	// Extract stack and service information from labels. Event attributes carry
+	// them too, for containers already removed when the event is handled
+	labels, image, status := event.Actor.Attributes, event.Actor.Attributes["image"], ""
+	if container, err := m.client.ContainerInspect(m.ctx, event.Actor.ID); err == nil {
+		labels, image, status = container.Config.Labels, container.Config.Image, container.State.Status
 	}
-
-	// Extract stack and service information from labels
-	stackName := m.getStackName(container.Config.Labels)
-	serviceName := m.getServiceName(container.Config.Labels)
+	stackName := m.getStackName(labels)
+	serviceName := m.getServiceName(labels)
 
 	if stackName == "" {
 		return // Not a compose stack
 	}
 
+	timestamp := time.Unix(event.Time, 0)
+	if event.TimeNano != 0 {
+		timestamp = time.Unix(0, event.TimeNano)
+	}
+
 	monitorEvent := &MonitorEvent{
 		Type:        "container",
 		Action:      event.Action,
 		ContainerID: containerID,
-		ImageName:   container.Config.Image,
+		ImageName:   image,
 		StackName:   stackName,
 		ServiceName: serviceName,
-		Status:      container.State.Status,
-		Timestamp:   time.Unix(event.Time, 0),
+		Status:      status,
+		Timestamp:   timestamp,
 		Attributes: map[string]interface{}{
-			"labels": container.Config.Labels,
+			"labels": labels,
 		},
 	}
 
-	m.publishEvent(stackName, monitorEvent)
+	if exitCode, ok := event.Actor.Attributes["exitCode"]; ok && event.Action == models.StackEventDie {
+		monitorEvent.Attributes["exit_code"] = exitCode
+	}
+
+	if recordedActions[event.Action] {
+		deploymentID := m.recordEvent(monitorEvent)
+		if deploymentID != "" && event.Action == models.StackEventDie {
+			m.checkRestartLoop(deploymentID, monitorEvent)
+		}
+	}
+	m.broadcast(monitorEvent)
+}
+
+// recordEvent stores a container lifecycle event for the deployment of its stack
+// and returns the deployment ID. Events of stacks not deployed by this instance
+// are not kept, and an empty ID is returned
+func (m *Monitor) recordEvent(event *MonitorEvent) string {
+	var deploymentID string
+	err := m.db.QueryRow("SELECT id FROM deployments WHERE stack_name = $1", event.StackName).Scan(&deploymentID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			slog.Warn("Failed to look up deployment for stack event", "stack", event.StackName, "error", err)
+		}
+		return ""
+	}
+
+	var code sql.NullInt64
+	if exitCode, ok := event.Attributes["exit_code"].(string); ok {
+		if n, err := strconv.Atoi(exitCode); err == nil {
+			code = sql.NullInt64{Int64: int64(n), Valid: true}
+		}
+	}
+
+	_, err = m.db.Exec(`
+		INSERT INTO stack_events (deployment_id, service_name, container_id, action, exit_code, image, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		deploymentID, event.ServiceName, event.ContainerID, event.Action, code, event.ImageName, event.Timestamp)
+	if err != nil {
+		slog.Warn("Failed to record stack event", "stack", event.StackName, "action", event.Action, "error", err)
+	}
+	return deploymentID
+}
+
+// periodicPrune removes recorded events past their retention
+func (m *Monitor) periodicPrune() {
+	if m.config.EventRetentionDays <= 0 && m.config.MaxEventsPerStack <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(eventPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if pruned, err := m.PruneEvents(); err != nil {
+				slog.Error("Failed to prune stack events", "error", err)
+			} else if pruned > 0 {
+				slog.Info("Pruned stack events", "rows", pruned)
+			}
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// PruneEvents deletes recorded events older than the retention period or beyond
+// the newest MaxEventsPerStack of a deployment, returning how many were deleted
+func (m *Monitor) PruneEvents() (int64, error) {
+	var total int64
+
+	if m.config.EventRetentionDays > 0 {
+		cutoff := time.Now().AddDate(0, 0, -m.config.EventRetentionDays)
+		result, err := m.db.Exec("DELETE FROM stack_events WHERE created_at < $1", cutoff)
+		if err != nil {
+			return total, err
+		}
+		n, _ := result.RowsAffected()
+		total += n
+	}
+
+	if m.config.MaxEventsPerStack > 0 {
+		result, err := m.db.Exec(`
+			DELETE FROM stack_events WHERE id IN (
+				SELECT id FROM (
+					SELECT id, ROW_NUMBER() OVER (PARTITION BY deployment_id ORDER BY created_at DESC, id DESC) AS n
+					FROM stack_events
+				) WHERE n > $1
+			)`, m.config.MaxEventsPerStack)
+		if err != nil {
+			return total, err
+		}
+		n, _ := result.RowsAffected()
+		total += n
+	}
+
+	return total, nil
 }
 
 // periodicStatusUpdate sends periodic status updates
@@ -261,7 +402,42 @@ func (m *Monitor) getStackStats(stackName string) *models.StackStats {
 	}
 }
 
-// publishEvent sends an event to all subscribers of a stack
+// broadcast sends an event on the bus; relayEvents delivers it, here included
+func (m *Monitor) broadcast(event *MonitorEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := m.events.Publish(bus.TopicStackEvents, payload); err != nil {
+		slog.Warn("Failed to publish stack event", "stack", event.StackName, "error", err)
+		m.publishEvent(event.StackName, event)
+	}
+}
+
+// relayEvents delivers the stack events on the bus to the subscribers of their stack
+func (m *Monitor) relayEvents() {
+	messages, unsubscribe := m.events.Subscribe(bus.TopicStackEvents)
+	defer unsubscribe()
+
+	for {
+		select {
+		case payload, ok := <-messages:
+			if !ok {
+				return
+			}
+			var event MonitorEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				continue
+			}
+			m.publishEvent(event.StackName, &event)
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// publishEvent sends an event to the subscribers of a stack in this process. Status
+// updates are sampled per process, so they only go here
 func (m *Monitor) publishEvent(stackName string, event *MonitorEvent) {
 	m.mu.RLock()
 	subscribers := m.subscribers[stackName]
@@ -375,11 +551,6 @@ func (m *Monitor) GetContainerStatus(stackName string) ([]models.StackService, e
 		services = append(services, service)
 	}
 
-	return services, nil
-
-		services = append(services, service)
-	}
-
 	return services, nil
 }
 
@@ -0,0 +1,177 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v3"
+	"docker-deploy-app/internal/models"
+)
+
+// ErrSubnetInUse is returned by CheckSubnet for a subnet another network uses
+var ErrSubnetInUse = fmt.Errorf("subnet overlaps an existing Docker network")
+
+// stackNetworkKey is the compose key of an isolated stack's own network
+const stackNetworkKey = "stack_network"
+
+// IsolatedNetworkName returns the Docker network of an isolated stack, named
+// explicitly so other stacks can join it from their allow-lists
+func IsolatedNetworkName(stackName string) string {
+	return stackName + "_isolated"
+}
+
+// ApplyNetworkPolicy applies the network policy of a deployment that runs no
+// tunnel sidecar. Deployments with one get it applied by their TunnelInjector
+func ApplyNetworkPolicy(composeContent []byte, stackName string, policy *models.NetworkPolicy) ([]byte, error) {
+	doc, err := parseComposeDocument(composeContent)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := applyNetworkPolicy(doc, stackName, policy, "")
+	if err != nil || !changed {
+		return composeContent, err
+	}
+	return doc.Bytes()
+}
+
+// applyNetworkPolicy applies the isolation, allow-list and addressing of a
+// network policy. sidecar names the tunnel service, if any
+func applyNetworkPolicy(doc *composeDocument, stackName string, policy *models.NetworkPolicy, sidecar string) (bool, error) {
+	changed, err := applyIsolation(doc, stackName, policy, sidecar)
+	if err != nil || policy.Subnet == "" {
+		return changed, err
+	}
+	return true, applyAddressing(doc, policy)
+}
+
+// applyIsolation isolates the services of a stack on its own network, moving
+// them off app_network, and joins them to the isolated networks of the stacks
+// they are allowed to reach. The tunnel sidecar is the only service left on
+// app_network. Services using network_mode are left alone
+func applyIsolation(doc *composeDocument, stackName string, policy *models.NetworkPolicy, sidecar string) (bool, error) {
+	isolated := policy.Isolation == models.NetworkIsolated
+	if !isolated && len(policy.Allow) == 0 {
+		return false, nil
+	}
+
+	var joined []string
+	for _, name := range doc.serviceNames() {
+		if mappingValue(doc.service(name), "network_mode") != nil {
+			continue
+		}
+		if isolated {
+			if name != sidecar {
+				doc.removeServiceNetwork(name, "app_network")
+			}
+			doc.addServiceNetwork(name, stackNetworkKey)
+		}
+		if name == sidecar {
+			continue
+		}
+		for _, stack := range policy.Allow {
+			doc.addServiceNetwork(name, allowedNetworkKey(stack))
+		}
+		joined = append(joined, name)
+	}
+
+	if isolated {
+		if err := doc.setNetwork(stackNetworkKey, ComposeNetwork{
+			Driver: "bridge",
+			Name:   IsolatedNetworkName(stackName),
+			Labels: map[string]string{
+				"app.managed":   "true",
+				"app.isolation": stackName,
+			},
+		}); err != nil {
+			return false, err
+		}
+	}
+	if len(joined) == 0 {
+		return isolated, nil
+	}
+	for _, stack := range policy.Allow {
+		if err := doc.setNetwork(allowedNetworkKey(stack), ComposeNetwork{
+			External: true,
+			Name:     IsolatedNetworkName(stack),
+		}); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// applyAddressing sets the subnet and gateway of the network the stack's
+// services share, and the static addresses of services on it: the isolated
+// network, else app_network, else the default network
+func applyAddressing(doc *composeDocument, policy *models.NetworkPolicy) error {
+	network := "default"
+	if policy.Isolation == models.NetworkIsolated {
+		network = stackNetworkKey
+	} else if doc.hasNetwork("app_network") {
+		network = "app_network"
+	}
+
+	definition := childMapping(doc.section("networks", true), network)
+	if external := mappingValue(definition, "external"); external != nil && external.Value == "true" {
+		return fmt.Errorf("network %s is external and cannot be addressed", network)
+	}
+	ipamConfig := &yaml.Node{Kind: yaml.MappingNode}
+	setMappingValue(ipamConfig, "subnet", scalarNode(policy.Subnet))
+	if policy.Gateway != "" {
+		setMappingValue(ipamConfig, "gateway", scalarNode(policy.Gateway))
+	}
+	setMappingValue(childMapping(definition, "ipam"), "config", &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{ipamConfig}})
+
+	for service, address := range policy.StaticIPs {
+		if doc.service(service) == nil {
+			return fmt.Errorf("static IP references unknown service: %s", service)
+		}
+		if mappingValue(doc.service(service), "network_mode") != nil {
+			return fmt.Errorf("service %s uses network_mode and cannot have a static IP", service)
+		}
+		key := "ipv4_address"
+		if net.ParseIP(address).To4() == nil {
+			key = "ipv6_address"
+		}
+		doc.setServiceNetworkOption(service, network, key, address)
+	}
+	return nil
+}
+
+// CheckSubnet returns an error naming the Docker network a subnet overlaps, if
+// any. Networks of the stack itself are skipped, as they are replaced when it
+// is deployed again
+func CheckSubnet(ctx context.Context, dockerClient *client.Client, stackName, subnet string) error {
+	_, requested, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return fmt.Errorf("invalid subnet: %q", subnet)
+	}
+
+	networks, err := dockerClient.NetworkList(ctx, types.NetworkListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list networks: %w", err)
+	}
+	for _, network := range networks {
+		if network.Labels["com.docker.compose.project"] == stackName || network.Name == IsolatedNetworkName(stackName) {
+			continue
+		}
+		for _, config := range network.IPAM.Config {
+			_, existing, err := net.ParseCIDR(config.Subnet)
+			if err != nil {
+				continue
+			}
+			if existing.Contains(requested.IP) || requested.Contains(existing.IP) {
+				return fmt.Errorf("%w: %s (%s)", ErrSubnetInUse, network.Name, config.Subnet)
+			}
+		}
+	}
+	return nil
+}
+
+// allowedNetworkKey is the compose key of the isolated network of an allowed stack
+func allowedNetworkKey(stackName string) string {
+	return fmt.Sprintf("allow_%s", stackName)
+}
@@ -0,0 +1,158 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v3"
+	"docker-deploy-app/internal/models"
+)
+
+// Planner resolves the images, ports and volumes a compose file will use on this host
+type Planner struct {
+	client   *client.Client
+	ports    *PortChecker
+	registry *RegistryClient
+}
+
+// NewPlanner creates a new deploy planner
+func NewPlanner(dockerClient *client.Client) *Planner {
+	return &Planner{
+		client:   dockerClient,
+		ports:    NewPortChecker(dockerClient),
+		registry: NewRegistryClient(10 * time.Second),
+	}
+}
+
+// Plan fills the images, ports and volumes of a deploy plan from compose content
+func (p *Planner) Plan(stackName string, composeContent []byte, env map[string]string, plan *models.DeployPlan) error {
+	interpolated, err := Interpolate(composeContent, env)
+	if err != nil {
+		// Required variables are filled in by the wizard; plan with what is known
+		plan.Warnings = append(plan.Warnings, err.Error())
+		interpolated = composeContent
+	}
+
+	doc, err := parseComposeDocument(interpolated)
+	if err != nil {
+		return err
+	}
+
+	plan.Images = []models.PlanImage{}
+	plan.Ports = []models.PlanPort{}
+	plan.Volumes = []models.PlanVolume{}
+
+	p.planImages(doc, plan)
+	p.planVolumes(doc, plan)
+	return p.planPorts(stackName, doc, plan)
+}
+
+// planImages reports whether each image is present and how much a pull downloads
+func (p *Planner) planImages(doc *composeDocument, plan *models.DeployPlan) {
+	ctx := context.Background()
+	counted := make(map[string]bool)
+
+	for _, name := range doc.serviceNames() {
+		service := doc.service(name)
+		image := doc.decodeService(name).Image
+		if image == "" {
+			if mappingValue(service, "build") != nil {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("service %s is built locally; build time and size are not estimated", name))
+			}
+			continue
+		}
+
+		entry := models.PlanImage{Service: name, Image: image}
+		if inspect, _, err := p.client.ImageInspectWithRaw(ctx, image); err == nil {
+			entry.Present = true
+			entry.SizeBytes = inspect.Size
+			entry.SizeKnown = true
+		} else if size, err := p.registry.CompressedSize(image); err == nil {
+			entry.SizeBytes = size
+			entry.SizeKnown = true
+			if !counted[image] {
+				plan.DownloadBytes += size
+				counted[image] = true
+			}
+		} else {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("could not size image %s: %v", image, err))
+		}
+
+		plan.Images = append(plan.Images, entry)
+	}
+}
+
+// planPorts lists published host ports and marks those already in use
+func (p *Planner) planPorts(stackName string, doc *composeDocument, plan *models.DeployPlan) error {
+	requested, err := publishedPorts(doc, nil)
+	if err != nil {
+		return err
+	}
+	if len(requested) == 0 {
+		return nil
+	}
+
+	bound, err := p.ports.containerPorts(stackName)
+	if err != nil {
+		return err
+	}
+
+	for _, port := range requested {
+		entry := models.PlanPort{
+			Service:       port.service,
+			HostPort:      port.hostPort,
+			ContainerPort: port.target,
+			Protocol:      port.protocol,
+		}
+		if usedBy := bound[portKey(port.hostPort, port.protocol)]; usedBy != "" {
+			entry.InUse = true
+			entry.UsedBy = usedBy
+		} else if !hostPortFree(port.hostIP, port.hostPort, port.protocol) {
+			entry.InUse = true
+			entry.UsedBy = "host process"
+		}
+		plan.Ports = append(plan.Ports, entry)
+	}
+	return nil
+}
+
+// planVolumes lists named volumes and bind mounts of all services
+func (p *Planner) planVolumes(doc *composeDocument, plan *models.DeployPlan) {
+	for _, name := range doc.serviceNames() {
+		volumes := mappingValue(doc.service(name), "volumes")
+		if volumes == nil || volumes.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for _, item := range volumes.Content {
+			var source, target string
+			if item.Kind == yaml.MappingNode {
+				if node := mappingValue(item, "source"); node != nil {
+					source = node.Value
+				}
+				if node := mappingValue(item, "target"); node != nil {
+					target = node.Value
+				}
+			} else {
+				parts := strings.Split(item.Value, ":")
+				if len(parts) < 2 {
+					continue // Anonymous volume
+				}
+				source, target = parts[0], parts[1]
+			}
+			if source == "" {
+				continue
+			}
+
+			plan.Volumes = append(plan.Volumes, models.PlanVolume{
+				Service: name,
+				Source:  source,
+				Target:  target,
+				Named:   !strings.ContainsAny(source[:1], "./~$"),
+			})
+		}
+	}
+}
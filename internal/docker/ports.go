@@ -0,0 +1,295 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v3"
+	"docker-deploy-app/internal/models"
+)
+
+// maxRemapAttempts bounds the search for a free port above a conflicting one
+const maxRemapAttempts = 100
+
+// PortConflictError lists host ports a stack requests that are already in use
+type PortConflictError struct {
+	Conflicts []models.PortConflict
+}
+
+func (e *PortConflictError) Error() string {
+	parts := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		parts = append(parts, fmt.Sprintf("%d/%s for %s (used by %s)", c.HostPort, c.Protocol, c.Service, c.UsedBy))
+	}
+	return fmt.Sprintf("host ports already in use: %s", strings.Join(parts, ", "))
+}
+
+// PortChecker detects requested host ports that are bound by containers or host sockets
+type PortChecker struct {
+	client *client.Client
+}
+
+// NewPortChecker creates a new port checker
+func NewPortChecker(dockerClient *client.Client) *PortChecker {
+	return &PortChecker{client: dockerClient}
+}
+
+// publishedPort is a host port requested by a compose service
+type publishedPort struct {
+	service  string
+	hostIP   string
+	hostPort int
+	protocol string
+	node     *yaml.Node // Ports item, rewritten when remapping
+	target   string
+}
+
+// Check compares the host ports of compose content with those in use. Containers of the
+// stack itself are ignored so redeploys do not conflict with themselves. With remap set,
+// conflicting ports are moved to the next free port and the rewritten content is returned;
+// otherwise a *PortConflictError is returned
+func (pc *PortChecker) Check(stackName string, composeContent []byte, env map[string]string, remap bool) ([]byte, []models.PortConflict, error) {
+	doc, err := parseComposeDocument(composeContent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	requested, err := publishedPorts(doc, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(requested) == 0 {
+		return composeContent, nil, nil
+	}
+
+	bound, err := pc.containerPorts(stackName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var conflicts []models.PortConflict
+	claimed := make(map[string]bool)
+	for _, port := range requested {
+		key := portKey(port.hostPort, port.protocol)
+		usedBy := bound[key]
+		if usedBy == "" && !hostPortFree(port.hostIP, port.hostPort, port.protocol) {
+			usedBy = "host process"
+		}
+		if usedBy == "" && claimed[key] {
+			usedBy = "another service in this stack"
+		}
+		if usedBy == "" {
+			claimed[key] = true
+			continue
+		}
+
+		conflict := models.PortConflict{
+			Service:  port.service,
+			HostPort: port.hostPort,
+			Protocol: port.protocol,
+			UsedBy:   usedBy,
+		}
+
+		if remap {
+			free := pc.nextFreePort(port, bound, claimed)
+			if free > 0 {
+				rewritePort(port, free)
+				conflict.RemappedTo = free
+				claimed[portKey(free, port.protocol)] = true
+			}
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	var unresolved []models.PortConflict
+	for _, conflict := range conflicts {
+		if conflict.RemappedTo == 0 {
+			unresolved = append(unresolved, conflict)
+		}
+	}
+	if len(unresolved) > 0 {
+		return nil, conflicts, &PortConflictError{Conflicts: unresolved}
+	}
+	if len(conflicts) == 0 {
+		return composeContent, nil, nil
+	}
+
+	content, err := doc.Bytes()
+	if err != nil {
+		return nil, conflicts, err
+	}
+	return content, conflicts, nil
+}
+
+// containerPorts returns the public ports of running containers outside the stack,
+// keyed by port and protocol, with the container name as value
+func (pc *PortChecker) containerPorts(stackName string) (map[string]string, error) {
+	containers, err := pc.client.ContainerList(context.Background(), types.ContainerListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	bound := make(map[string]string)
+	for _, container := range containers {
+		if container.Labels["com.docker.compose.project"] == stackName {
+			continue
+		}
+		name := container.ID[:12]
+		if len(container.Names) > 0 {
+			name = strings.TrimPrefix(container.Names[0], "/")
+		}
+		for _, port := range container.Ports {
+			if port.PublicPort != 0 {
+				bound[portKey(int(port.PublicPort), port.Type)] = name
+			}
+		}
+	}
+	return bound, nil
+}
+
+// nextFreePort returns the first port above a conflicting one that nothing uses, or 0
+func (pc *PortChecker) nextFreePort(port publishedPort, bound map[string]string, claimed map[string]bool) int {
+	for candidate := port.hostPort + 1; candidate <= port.hostPort+maxRemapAttempts && candidate <= 65535; candidate++ {
+		key := portKey(candidate, port.protocol)
+		if bound[key] == "" && !claimed[key] && hostPortFree(port.hostIP, candidate, port.protocol) {
+			return candidate
+		}
+	}
+	return 0
+}
+
+// publishedPorts collects the host ports of all services, interpolating variables first
+func publishedPorts(doc *composeDocument, env map[string]string) ([]publishedPort, error) {
+	var ports []publishedPort
+	for _, name := range doc.serviceNames() {
+		items := mappingValue(doc.service(name), "ports")
+		if items == nil || items.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		for _, item := range items.Content {
+			port, ok, err := parsePublishedPort(name, item, env)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: %w", name, err)
+			}
+			if ok {
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports, nil
+}
+
+// parsePublishedPort parses a short ("[ip:]host:container[/proto]") or long form port
+// entry; entries without a fixed host port are skipped
+func parsePublishedPort(service string, item *yaml.Node, env map[string]string) (publishedPort, bool, error) {
+	port := publishedPort{service: service, protocol: "tcp", node: item}
+
+	if item.Kind == yaml.MappingNode {
+		published := mappingValue(item, "published")
+		if published == nil {
+			return port, false, nil
+		}
+		value, err := Interpolate([]byte(published.Value), env)
+		if err != nil {
+			return port, false, err
+		}
+		if protocol := mappingValue(item, "protocol"); protocol != nil {
+			port.protocol = protocol.Value
+		}
+		if hostIP := mappingValue(item, "host_ip"); hostIP != nil {
+			port.hostIP = hostIP.Value
+		}
+		if target := mappingValue(item, "target"); target != nil {
+			port.target = target.Value
+		}
+		port.hostPort, err = strconv.Atoi(string(value))
+		return port, err == nil, nil
+	}
+
+	value, err := Interpolate([]byte(item.Value), env)
+	if err != nil {
+		return port, false, err
+	}
+	spec := string(value)
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		port.protocol = spec[i+1:]
+		spec = spec[:i]
+	}
+
+	// IPv6 host addresses are bracketed, so split on the last two colons
+	i := strings.LastIndex(spec, ":")
+	if i < 0 {
+		return port, false, nil // Container port only; Docker picks the host port
+	}
+	port.target = spec[i+1:]
+	host := spec[:i]
+	if j := strings.LastIndex(host, ":"); j >= 0 && !strings.HasSuffix(host, "]") {
+		port.hostIP = strings.Trim(host[:j], "[]")
+		host = host[j+1:]
+	}
+
+	port.hostPort, err = strconv.Atoi(host)
+	if err != nil {
+		return port, false, nil // Port ranges are left to compose
+	}
+	return port, true, nil
+}
+
+// rewritePort points a ports entry at a new host port
+func rewritePort(port publishedPort, hostPort int) {
+	if port.node.Kind == yaml.MappingNode {
+		setMappingValue(port.node, "published", scalarNode(strconv.Itoa(hostPort)))
+		return
+	}
+
+	value := fmt.Sprintf("%d:%s", hostPort, port.target)
+	if port.hostIP != "" {
+		hostIP := port.hostIP
+		if strings.Contains(hostIP, ":") {
+			hostIP = "[" + hostIP + "]"
+		}
+		value = hostIP + ":" + value
+	}
+	if port.protocol != "tcp" {
+		value += "/" + port.protocol
+	}
+	port.node.Value = value
+	port.node.Tag = "!!str"
+}
+
+// hostPortFree reports whether a port can be bound on the host. Privileged ports the
+// server may not bind are assumed free; the container check still covers them
+func hostPortFree(hostIP string, port int, protocol string) bool {
+	address := net.JoinHostPort(hostIP, strconv.Itoa(port))
+	if protocol == "udp" {
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return errors.Is(err, os.ErrPermission)
+		}
+		conn.Close()
+		return true
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return errors.Is(err, os.ErrPermission)
+	}
+	listener.Close()
+	return true
+}
+
+// portKey identifies a host port and protocol
+func portKey(port int, protocol string) string {
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	return fmt.Sprintf("%d/%s", port, protocol)
+}
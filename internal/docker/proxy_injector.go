@@ -0,0 +1,166 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"docker-deploy-app/internal/models"
+)
+
+// ProxyInjector adds reverse-proxy routing labels to services of a Docker Compose file
+type ProxyInjector struct {
+	config    *models.ProxyConfig
+	stackName string
+	variables map[string]string
+}
+
+// NewProxyInjector creates a new proxy injector; variables resolve ${NAME}
+// references in route hostnames and ports
+func NewProxyInjector(config *models.ProxyConfig, stackName string, variables map[string]string) *ProxyInjector {
+	return &ProxyInjector{
+		config:    config,
+		stackName: stackName,
+		variables: variables,
+	}
+}
+
+// ProcessCompose adds proxy labels and the proxy network to the routed services,
+// leaving the rest of the file untouched
+func (pi *ProxyInjector) ProcessCompose(composeContent []byte) ([]byte, *ValidationResult, error) {
+	doc, err := parseComposeDocument(composeContent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := &ValidationResult{
+		Valid:       true,
+		NetworkOK:   true,
+		Issues:      []string{},
+		Warnings:    []string{},
+		Suggestions: []string{},
+	}
+
+	for _, route := range pi.config.Routes {
+		if doc.service(route.Service) == nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("proxy route references unknown service: %s", route.Service))
+			continue
+		}
+
+		labels, err := pi.routeLabels(route)
+		if err != nil {
+			result.Issues = append(result.Issues, err.Error())
+			continue
+		}
+		doc.setServiceLabels(route.Service, labels)
+
+		if pi.config.Network != "" && !contains(doc.serviceNetworks(route.Service), pi.config.Network) {
+			// Keep the service reachable by its siblings on the default network
+			if len(doc.serviceNetworks(route.Service)) == 0 {
+				doc.addServiceNetwork(route.Service, "default")
+			}
+			doc.addServiceNetwork(route.Service, pi.config.Network)
+		}
+
+		result.Suggestions = append(result.Suggestions,
+			fmt.Sprintf("Added %s labels to %s for %s", pi.config.Type, route.Service, labels[pi.hostLabel(route)]))
+	}
+
+	if pi.config.Network != "" {
+		if err := doc.setNetwork(pi.config.Network, ComposeNetwork{External: true}); err != nil {
+			return nil, result, err
+		}
+	}
+
+	result.Valid = len(result.Issues) == 0
+
+	modifiedContent, err := doc.Bytes()
+	if err != nil {
+		return nil, result, err
+	}
+
+	return modifiedContent, result, nil
+}
+
+// InjectIntoFile reads, processes, and writes back a docker-compose file
+func (pi *ProxyInjector) InjectIntoFile(filePath string) (*ValidationResult, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	modifiedContent, result, err := pi.ProcessCompose(content)
+	if err != nil {
+		return result, err
+	}
+
+	if err := os.WriteFile(filePath, modifiedContent, 0644); err != nil {
+		return result, fmt.Errorf("failed to write modified compose file: %w", err)
+	}
+
+	return result, nil
+}
+
+// routeLabels returns the proxy labels exposing a route
+func (pi *ProxyInjector) routeLabels(route models.ProxyRoute) (map[string]string, error) {
+	hostname, err := pi.expand(route.Hostname)
+	if err != nil {
+		return nil, fmt.Errorf("proxy route for %s: %w", route.Service, err)
+	}
+	port, err := pi.expand(route.Port)
+	if err != nil {
+		return nil, fmt.Errorf("proxy route for %s: %w", route.Service, err)
+	}
+	if hostname == "" || port == "" {
+		return nil, fmt.Errorf("proxy route for %s resolves to an empty hostname or port", route.Service)
+	}
+
+	switch pi.config.Type {
+	case models.ProxyTypeCaddy:
+		// Labels understood by caddy-docker-proxy
+		return map[string]string{
+			"caddy":               hostname,
+			"caddy.reverse_proxy": fmt.Sprintf("{{upstreams %s}}", port),
+		}, nil
+	default:
+		router := pi.routerName(route)
+		labels := map[string]string{
+			"traefik.enable": "true",
+			fmt.Sprintf("traefik.http.routers.%s.rule", router):                      fmt.Sprintf("Host(`%s`)", hostname),
+			fmt.Sprintf("traefik.http.routers.%s.service", router):                   router,
+			fmt.Sprintf("traefik.http.services.%s.loadbalancer.server.port", router): port,
+		}
+		if pi.config.EntryPoint != "" {
+			labels[fmt.Sprintf("traefik.http.routers.%s.entrypoints", router)] = pi.config.EntryPoint
+		}
+		if pi.config.CertResolver != "" {
+			labels[fmt.Sprintf("traefik.http.routers.%s.tls.certresolver", router)] = pi.config.CertResolver
+		}
+		if pi.config.Network != "" {
+			labels["traefik.docker.network"] = pi.config.Network
+		}
+		return labels, nil
+	}
+}
+
+// hostLabel returns the label key holding the hostname of a route
+func (pi *ProxyInjector) hostLabel(route models.ProxyRoute) string {
+	if pi.config.Type == models.ProxyTypeCaddy {
+		return "caddy"
+	}
+	return fmt.Sprintf("traefik.http.routers.%s.rule", pi.routerName(route))
+}
+
+// routerName returns a router name unique across stacks sharing one proxy
+func (pi *ProxyInjector) routerName(route models.ProxyRoute) string {
+	return strings.ReplaceAll(pi.stackName+"-"+route.Service, "_", "-")
+}
+
+// expand resolves ${NAME} references against the deployment variables
+func (pi *ProxyInjector) expand(value string) (string, error) {
+	expanded, err := Interpolate([]byte(value), pi.variables)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(expanded)), nil
+}
@@ -0,0 +1,213 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// manifestMediaTypes are the manifest formats accepted from registries
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+// RegistryClient reads image manifests anonymously from registries to size pulls
+type RegistryClient struct {
+	client *http.Client
+}
+
+// NewRegistryClient creates a new registry client
+func NewRegistryClient(timeout time.Duration) *RegistryClient {
+	return &RegistryClient{client: &http.Client{Timeout: timeout}}
+}
+
+// imageRef is a parsed image reference
+type imageRef struct {
+	registry   string
+	repository string
+	reference  string // Tag or digest
+}
+
+// manifest covers both image indexes and image manifests
+type manifest struct {
+	MediaType string `json:"mediaType"`
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant"`
+		} `json:"platform"`
+	} `json:"manifests"`
+	Config struct {
+		Digest string `json:"digest"`
+		Size   int64  `json:"size"`
+	} `json:"config"`
+	Layers []struct {
+		Size int64 `json:"size"`
+	} `json:"layers"`
+}
+
+// CompressedSize returns the download size of an image for the server's platform
+func (rc *RegistryClient) CompressedSize(image string) (int64, error) {
+	ref := parseImageRef(image)
+
+	m, err := rc.fetchManifest(ref, ref.reference)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(m.Manifests) > 0 {
+		digest := ""
+		for _, entry := range m.Manifests {
+			if entry.Platform.OS == "linux" && entry.Platform.Architecture == runtime.GOARCH {
+				digest = entry.Digest
+				break
+			}
+		}
+		if digest == "" {
+			return 0, fmt.Errorf("no linux/%s image for %s", runtime.GOARCH, image)
+		}
+		if m, err = rc.fetchManifest(ref, digest); err != nil {
+			return 0, err
+		}
+	}
+
+	size := m.Config.Size
+	for _, layer := range m.Layers {
+		size += layer.Size
+	}
+	return size, nil
+}
+
+// fetchManifest gets a manifest, obtaining an anonymous bearer token when challenged
+func (rc *RegistryClient) fetchManifest(ref imageRef, reference string) (*manifest, error) {
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", ref.registry, ref.repository, reference)
+
+	resp, err := rc.get(manifestURL, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		token, err := rc.token(challenge)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = rc.get(manifestURL, token); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned %s for %s", resp.Status, ref.repository)
+	}
+
+	var m manifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// token requests an anonymous pull token from the realm named in a Bearer challenge
+func (rc *RegistryClient) token(challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported registry authentication")
+	}
+
+	params := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid registry authentication realm")
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	resp, err := rc.client.Get(realm.String())
+	if err != nil {
+		return "", fmt.Errorf("failed to get registry token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode registry token: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// get performs a manifest or blob request
+func (rc *RegistryClient) get(rawURL, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query registry: %w", err)
+	}
+	return resp, nil
+}
+
+// parseImageRef splits an image name into registry, repository and tag or digest,
+// applying Docker Hub defaults
+func parseImageRef(image string) imageRef {
+	ref := imageRef{registry: "registry-1.docker.io", reference: "latest"}
+
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.reference = name[i+1:]
+		name = name[:i]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.reference = name[i+1:]
+		name = name[:i]
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		if parts[0] != "docker.io" && parts[0] != "index.docker.io" {
+			ref.registry = parts[0]
+		}
+		name = parts[1]
+	}
+
+	if ref.registry == "registry-1.docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	ref.repository = name
+	return ref
+}
@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/models"
+)
+
+// ResourceChecker compares the resource requirements of templates with the
+// capacity of the Docker host
+type ResourceChecker struct {
+	client   *client.Client
+	diskPath string // Checked for free disk when the Docker root is not local
+}
+
+// NewResourceChecker creates a new resource checker
+func NewResourceChecker(dockerClient *client.Client, diskPath string) *ResourceChecker {
+	return &ResourceChecker{client: dockerClient, diskPath: diskPath}
+}
+
+// Host reads the CPUs, memory, architecture, swarm state and GPU support of the
+// Docker host and the free disk of its data root
+func (rc *ResourceChecker) Host(ctx context.Context) models.HostResources {
+	host := models.HostResources{GPU: models.GPUCapability{Devices: []models.GPUDevice{}}}
+	diskPath := rc.diskPath
+	if info, err := rc.client.Info(ctx); err == nil {
+		host.CPUs = info.NCPU
+		host.MemoryBytes = info.MemTotal
+		host.Architecture = NormalizeArchitecture(info.Architecture)
+		host.SwarmActive = info.Swarm.LocalNodeState == swarm.LocalNodeStateActive
+		host.GPU = gpuCapability(ctx, info, rc.local())
+		if _, _, err := filesystemCapacity(info.DockerRootDir); err == nil {
+			diskPath = info.DockerRootDir
+		}
+	}
+	if _, free, err := filesystemCapacity(diskPath); err == nil {
+		host.FreeDisk = int64(free)
+	}
+	return host
+}
+
+// local reports whether the Docker engine runs on this machine, so its devices
+// and filesystems can be inspected directly
+func (rc *ResourceChecker) local() bool {
+	host := rc.client.DaemonHost()
+	return strings.HasPrefix(host, "unix://") || strings.HasPrefix(host, "npipe://")
+}
+
+// Check returns warnings for the requirements the host does not meet. Templates
+// without requirements are not checked
+func (rc *ResourceChecker) Check(ctx context.Context, resources *models.TemplateResources) []string {
+	if resources == nil || resources.IsZero() {
+		return nil
+	}
+	return resources.Shortfalls(rc.Host(ctx))
+}
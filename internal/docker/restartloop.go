@@ -0,0 +1,121 @@
+package docker
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"docker-deploy-app/internal/models"
+)
+
+// checkRestartLoop flags the service of a die event when its containers died more
+// than MaxRestarts times within the window. A flagged service is reported once,
+// until its loop is cleared, and stopped if so configured
+func (m *Monitor) checkRestartLoop(deploymentID string, event *MonitorEvent) {
+	cfg := m.config.RestartLoop
+	if !cfg.Enabled || event.ServiceName == "" {
+		return
+	}
+
+	window := time.Duration(cfg.Window) * time.Minute
+	var restarts int
+	err := m.db.QueryRow(`
+		SELECT COUNT(*) FROM stack_events
+		WHERE deployment_id = $1 AND service_name = $2 AND action = $3 AND created_at >= $4`,
+		deploymentID, event.ServiceName, models.StackEventDie, event.Timestamp.Add(-window)).Scan(&restarts)
+	if err != nil {
+		slog.Warn("Failed to count service restarts", "stack", event.StackName, "service", event.ServiceName, "error", err)
+		return
+	}
+	if restarts <= cfg.MaxRestarts {
+		return
+	}
+
+	var loopID int64
+	err = m.db.QueryRow(`
+		SELECT id FROM restart_loops
+		WHERE deployment_id = $1 AND service_name = $2 AND cleared_at IS NULL`,
+		deploymentID, event.ServiceName).Scan(&loopID)
+	if err == nil {
+		m.db.Exec("UPDATE restart_loops SET restarts = $1 WHERE id = $2", restarts, loopID)
+		return // Already reported
+	}
+	if err != sql.ErrNoRows {
+		slog.Warn("Failed to look up restart loop", "stack", event.StackName, "service", event.ServiceName, "error", err)
+		return
+	}
+
+	stopped := false
+	if cfg.StopService {
+		stopped = m.stopService(event.StackName, event.ServiceName)
+	}
+
+	loop := models.RestartLoop{
+		DeploymentID: deploymentID,
+		ServiceName:  event.ServiceName,
+		Restarts:     restarts,
+		Window:       cfg.Window,
+		Stopped:      stopped,
+		DetectedAt:   time.Now(),
+	}
+	result, err := m.db.Exec(`
+		INSERT INTO restart_loops (deployment_id, service_name, restarts, window_minutes, stopped, detected_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		loop.DeploymentID, loop.ServiceName, loop.Restarts, loop.Window, loop.Stopped, loop.DetectedAt)
+	if err != nil {
+		slog.Error("Failed to record restart loop", "stack", event.StackName, "service", event.ServiceName, "error", err)
+		return
+	}
+	loop.ID, _ = result.LastInsertId()
+
+	slog.Warn("Service is in a restart loop", "stack", event.StackName, "service", event.ServiceName,
+		"restarts", restarts, "window", window, "stopped", stopped)
+	m.publisher.Publish(models.WebhookEventStackRestartLoop, map[string]interface{}{
+		"deployment_id": deploymentID,
+		"stack_name":    event.StackName,
+		"service":       event.ServiceName,
+		"restarts":      restarts,
+		"window":        cfg.Window,
+		"stopped":       stopped,
+		"exit_code":     event.Attributes["exit_code"],
+	})
+}
+
+// stopService stops the containers of a compose service. A manually stopped
+// container is not restarted by its restart policy
+func (m *Monitor) stopService(stackName, serviceName string) bool {
+	containers, err := m.client.ContainerList(m.ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", "com.docker.compose.project="+stackName),
+			filters.Arg("label", "com.docker.compose.service="+serviceName),
+		),
+	})
+	if err != nil {
+		slog.Error("Failed to list containers of crashing service", "stack", stackName, "service", serviceName, "error", err)
+		return false
+	}
+
+	stopped := true
+	for _, c := range containers {
+		if err := m.client.ContainerStop(m.ctx, c.ID, container.StopOptions{}); err != nil {
+			slog.Error("Failed to stop crashing container", "stack", stackName, "service", serviceName,
+				"container_id", c.ID, "error", err)
+			stopped = false
+		}
+	}
+	return stopped
+}
+
+// ClearRestartLoops clears the uncleared restart loops of a deployment, ending its
+// degraded state, and returns how many were cleared
+func ClearRestartLoops(db *sql.DB, deploymentID string) (int64, error) {
+	result, err := db.Exec("UPDATE restart_loops SET cleared_at = $1 WHERE deployment_id = $2 AND cleared_at IS NULL",
+		time.Now(), deploymentID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,298 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/models"
+)
+
+// SBOM document formats
+const (
+	SBOMFormatCycloneDX = "cyclonedx"
+	SBOMFormatSPDX      = "spdx"
+)
+
+// sbomTool names this application as the author of generated documents
+const sbomTool = "docker-deploy-app"
+
+// SBOMGenerator describes the images of a stack as a CycloneDX or SPDX document.
+// When syft is installed, the packages inside each image are listed too
+type SBOMGenerator struct {
+	client *client.Client
+	syft   string // Path of the syft binary, empty when it is not installed
+}
+
+// NewSBOMGenerator creates a new SBOM generator, using syft from PATH if found
+func NewSBOMGenerator(dockerClient *client.Client) *SBOMGenerator {
+	syft, _ := exec.LookPath("syft")
+	return &SBOMGenerator{client: dockerClient, syft: syft}
+}
+
+// PackagesAvailable reports whether image packages can be listed
+func (g *SBOMGenerator) PackagesAvailable() bool {
+	return g.syft != ""
+}
+
+// sbomImage is an image run by a stack and the services running it
+type sbomImage struct {
+	ref          string
+	id           string
+	repository   string
+	tag          string
+	digest       string
+	os           string
+	architecture string
+	services     []string
+}
+
+// Generate returns the SBOM of a stack's images in format, or ErrNoStackContainers. With packages set and
+// syft installed, each image's packages are included; an image syft fails on is
+// described without them
+func (g *SBOMGenerator) Generate(ctx context.Context, stackName string, mode models.DeployMode, format string, packages bool) (interface{}, error) {
+	images, err := g.stackImages(ctx, stackName, mode)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, models.ErrNoStackContainers
+	}
+
+	packages = packages && g.PackagesAvailable()
+	switch format {
+	case SBOMFormatSPDX:
+		return g.spdx(ctx, stackName, images, packages), nil
+	case SBOMFormatCycloneDX, "":
+		return g.cycloneDX(ctx, stackName, images, packages), nil
+	}
+	return nil, fmt.Errorf("unknown SBOM format %q: use %s or %s", format, SBOMFormatCycloneDX, SBOMFormatSPDX)
+}
+
+// stackImages returns the distinct images of a stack's containers, sorted by reference
+func (g *SBOMGenerator) stackImages(ctx context.Context, stackName string, mode models.DeployMode) ([]*sbomImage, error) {
+	stackLabel, serviceLabel := composeProjectLabel, composeServiceLabel
+	if mode == models.DeployModeSwarm {
+		stackLabel, serviceLabel = swarmStackLabel, swarmServiceLabel
+	}
+
+	containers, err := g.client.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", stackLabel+"="+stackName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	byID := make(map[string]*sbomImage)
+	for _, container := range containers {
+		image := byID[container.ImageID]
+		if image == nil {
+			image = &sbomImage{ref: container.Image, id: container.ImageID}
+			image.repository, image.tag = splitImageRef(container.Image)
+			if info, _, err := g.client.ImageInspectWithRaw(ctx, container.ImageID); err == nil {
+				image.os, image.architecture = info.Os, info.Architecture
+				for _, repoDigest := range info.RepoDigests {
+					if i := strings.Index(repoDigest, "@"); i >= 0 {
+						image.digest = repoDigest[i+1:]
+						break
+					}
+				}
+			}
+			byID[container.ImageID] = image
+		}
+		if service := container.Labels[serviceLabel]; service != "" && !contains(image.services, service) {
+			image.services = append(image.services, service)
+		}
+	}
+
+	images := make([]*sbomImage, 0, len(byID))
+	for _, image := range byID {
+		sort.Strings(image.services)
+		images = append(images, image)
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].ref < images[j].ref })
+	return images, nil
+}
+
+// purl returns the package URL of an image
+func (i *sbomImage) purl() string {
+	name := i.repository[strings.LastIndex(i.repository, "/")+1:]
+	purl := "pkg:oci/" + name
+	if i.digest != "" {
+		purl += "@" + strings.Replace(i.digest, ":", "%3A", 1)
+	}
+	purl += "?repository_url=" + i.repository
+	if i.tag != "" {
+		purl += "&tag=" + i.tag
+	}
+	return purl
+}
+
+// cycloneDX builds a CycloneDX 1.5 document with the stack as the described
+// application and one container component per image
+func (g *SBOMGenerator) cycloneDX(ctx context.Context, stackName string, images []*sbomImage, packages bool) map[string]interface{} {
+	stackRef := "stack:" + stackName
+	components := make([]interface{}, 0, len(images))
+	imageRefs := make([]string, 0, len(images))
+
+	for _, image := range images {
+		ref := "image:" + image.id
+		imageRefs = append(imageRefs, ref)
+
+		properties := []map[string]string{
+			{"name": sbomTool + ":services", "value": strings.Join(image.services, ",")},
+		}
+		if image.os != "" {
+			properties = append(properties, map[string]string{"name": sbomTool + ":platform", "value": image.os + "/" + image.architecture})
+		}
+
+		component := map[string]interface{}{
+			"type":       "container",
+			"bom-ref":    ref,
+			"name":       image.repository,
+			"version":    image.tag,
+			"purl":       image.purl(),
+			"properties": properties,
+		}
+		if image.digest != "" {
+			component["hashes"] = []map[string]string{{"alg": "SHA-256", "content": strings.TrimPrefix(image.digest, "sha256:")}}
+		}
+
+		if packages {
+			var doc struct {
+				Components []json.RawMessage `json:"components"`
+			}
+			if err := g.runSyft(ctx, image, "cyclonedx-json", &doc); err != nil {
+				component["properties"] = append(properties, map[string]string{"name": sbomTool + ":packages", "value": "unavailable"})
+			} else {
+				component["components"] = doc.Components
+			}
+		}
+
+		components = append(components, component)
+	}
+
+	return map[string]interface{}{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + newUUID(),
+		"version":      1,
+		"metadata": map[string]interface{}{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"tools": map[string]interface{}{
+				"components": []map[string]string{{"type": "application", "name": sbomTool}},
+			},
+			"component": map[string]string{"type": "application", "bom-ref": stackRef, "name": stackName},
+		},
+		"components":   components,
+		"dependencies": []map[string]interface{}{{"ref": stackRef, "dependsOn": imageRefs}},
+	}
+}
+
+// spdx builds an SPDX 2.3 document describing one package per image, which
+// contains the packages syft found in it
+func (g *SBOMGenerator) spdx(ctx context.Context, stackName string, images []*sbomImage, packages bool) map[string]interface{} {
+	spdxPackages := make([]interface{}, 0, len(images))
+	relationships := make([]map[string]string, 0, len(images))
+
+	for n, image := range images {
+		id := fmt.Sprintf("SPDXRef-Image-%d", n+1)
+		pkg := map[string]interface{}{
+			"name":                  image.repository,
+			"SPDXID":                id,
+			"versionInfo":           image.tag,
+			"downloadLocation":      "NOASSERTION",
+			"filesAnalyzed":         false,
+			"primaryPackagePurpose": "CONTAINER",
+			"comment":               "Services: " + strings.Join(image.services, ", "),
+			"externalRefs": []map[string]string{
+				{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": image.purl()},
+			},
+		}
+		if image.digest != "" {
+			pkg["checksums"] = []map[string]string{{"algorithm": "SHA256", "checksumValue": strings.TrimPrefix(image.digest, "sha256:")}}
+		}
+		spdxPackages = append(spdxPackages, pkg)
+		relationships = append(relationships, map[string]string{
+			"spdxElementId": "SPDXRef-DOCUMENT", "relationshipType": "DESCRIBES", "relatedSpdxElement": id,
+		})
+
+		if !packages {
+			continue
+		}
+		var doc struct {
+			Packages []map[string]interface{} `json:"packages"`
+		}
+		if err := g.runSyft(ctx, image, "spdx-json", &doc); err != nil {
+			pkg["comment"] = pkg["comment"].(string) + "; packages unavailable"
+			continue
+		}
+		for _, contained := range doc.Packages {
+			// Package IDs are only unique within syft's document for one image
+			containedID := fmt.Sprintf("%s-%v", id, strings.TrimPrefix(fmt.Sprint(contained["SPDXID"]), "SPDXRef-"))
+			contained["SPDXID"] = containedID
+			spdxPackages = append(spdxPackages, contained)
+			relationships = append(relationships, map[string]string{
+				"spdxElementId": id, "relationshipType": "CONTAINS", "relatedSpdxElement": containedID,
+			})
+		}
+	}
+
+	return map[string]interface{}{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              stackName,
+		"documentNamespace": fmt.Sprintf("https://%s/spdx/%s-%s", sbomTool, stackName, newUUID()),
+		"creationInfo": map[string]interface{}{
+			"created":  time.Now().UTC().Format(time.RFC3339),
+			"creators": []string{"Tool: " + sbomTool},
+		},
+		"packages":      spdxPackages,
+		"relationships": relationships,
+	}
+}
+
+// runSyft lists the packages of an image with syft in a syft output format,
+// decoding the document into out
+func (g *SBOMGenerator) runSyft(ctx context.Context, image *sbomImage, output string, out interface{}) error {
+	cmd := exec.CommandContext(ctx, g.syft, "-q", "docker:"+image.id, "-o", output)
+	data, err := cmd.Output()
+	if err == nil {
+		err = json.Unmarshal(data, out)
+	}
+	if err != nil {
+		slog.Warn("Failed to list image packages with syft", "image", image.ref, "error", err)
+	}
+	return err
+}
+
+// splitImageRef splits an image reference into repository and tag, ignoring a
+// digest and taking a colon before the last slash as a registry port
+func splitImageRef(ref string) (string, string) {
+	ref = strings.SplitN(ref, "@", 2)[0]
+	slash := strings.LastIndex(ref, "/")
+	if i := strings.LastIndex(ref, ":"); i > slash {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, "latest"
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
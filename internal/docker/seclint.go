@@ -0,0 +1,112 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"docker-deploy-app/internal/models"
+)
+
+// Security lint rules
+const (
+	RulePrivileged       = "privileged"
+	RuleHostNetwork      = "host-network"
+	RuleDockerSocket     = "docker-socket"
+	RuleLatestTag        = "latest-tag"
+	RuleNoResourceLimits = "no-resource-limits"
+)
+
+// dockerSockets are the paths the Docker socket is mounted from
+var dockerSockets = []string{"/var/run/docker.sock", "/run/docker.sock"}
+
+// LintComposeSecurity flags risky settings of compose content: privileged services,
+// host networking, Docker socket mounts, images without a pinned tag and services
+// without resource limits. The newt tunnel service needs the socket, so its mount
+// is not flagged
+func LintComposeSecurity(content []byte) ([]models.SecurityFinding, error) {
+	doc, err := parseComposeDocument(content)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []models.SecurityFinding{}
+	add := func(rule string, severity models.SecuritySeverity, service, format string, args ...interface{}) {
+		findings = append(findings, models.SecurityFinding{
+			Rule:     rule,
+			Severity: severity,
+			Service:  service,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, name := range doc.serviceNames() {
+		node := doc.service(name)
+		if node == nil {
+			continue
+		}
+		service := doc.decodeService(name)
+
+		if value := mappingValue(node, "privileged"); value != nil && value.Value == "true" {
+			add(RulePrivileged, models.SecuritySeverityCritical, name, "runs privileged, with full access to the host")
+		}
+
+		if value := mappingValue(node, "network_mode"); value != nil && value.Value == "host" {
+			add(RuleHostNetwork, models.SecuritySeverityHigh, name, "uses the host network")
+		}
+
+		if !isNewtService(name, service.Image) {
+			for _, volume := range service.Volumes {
+				source := strings.SplitN(volume, ":", 2)[0]
+				if contains(dockerSockets, source) {
+					add(RuleDockerSocket, models.SecuritySeverityCritical, name, "mounts the Docker socket %s, giving it control of the host", source)
+				}
+			}
+		}
+
+		if service.Image != "" && !pinnedImage(service.Image) {
+			add(RuleLatestTag, models.SecuritySeverityMedium, name, "image %s is not pinned to a version", service.Image)
+		}
+
+		if !hasResourceLimits(node) {
+			add(RuleNoResourceLimits, models.SecuritySeverityLow, name, "sets no memory or CPU limits")
+		}
+	}
+
+	return findings, nil
+}
+
+// isNewtService reports whether a service is the newt tunnel client
+func isNewtService(name, image string) bool {
+	return name == "newt" || strings.HasPrefix(image, "fosrl/newt")
+}
+
+// pinnedImage reports whether an image names a version: a digest or a tag other
+// than latest. Tags set by a variable are assumed to be pinned by the deployer
+func pinnedImage(image string) bool {
+	if strings.Contains(image, "@") {
+		return true
+	}
+
+	// A colon after the last slash separates the tag; one before is a registry port
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	if i < 0 {
+		return strings.Contains(name, "${")
+	}
+	tag := name[i+1:]
+	return tag != "latest" && tag != ""
+}
+
+// hasResourceLimits reports whether a service limits its memory or CPU, through
+// deploy.resources.limits or the mem_limit and cpus keys
+func hasResourceLimits(service *yaml.Node) bool {
+	for _, key := range []string{"mem_limit", "cpus"} {
+		if mappingValue(service, key) != nil {
+			return true
+		}
+	}
+
+	limits := mappingValue(mappingValue(mappingValue(service, "deploy"), "resources"), "limits")
+	return limits != nil && limits.Kind == yaml.MappingNode && len(limits.Content) > 0
+}
@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+
+	"docker-deploy-app/internal/models"
+)
+
+// StackComposeOptions are the rewrites of a template's compose file for one
+// deployment
+type StackComposeOptions struct {
+	StackName string
+	Tunnel    TunnelProvider        // Sidecar to inject, when set
+	Network   *models.NetworkPolicy // Isolation and allow-list to apply, when set
+	Proxy     *models.ProxyConfig   // Reverse-proxy routes to label, when set
+	Variables map[string]string     // Resolve ${NAME} in proxy routes
+}
+
+// GenerateStackCompose rewrites the compose file of a template into the one a
+// deployment is brought up from. The network policy is applied by the tunnel
+// injector when there is a sidecar, as it decides which networks it joins
+func GenerateStackCompose(content []byte, options StackComposeOptions) ([]byte, error) {
+	switch {
+	case options.Tunnel != nil:
+		injector := NewTunnelInjector(options.Tunnel)
+		if options.Network != nil {
+			injector.WithNetworkPolicy(options.StackName, options.Network)
+		}
+		updated, result, err := injector.ProcessCompose(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inject tunnel: %w", err)
+		}
+		if !result.NetworkOK {
+			return nil, fmt.Errorf("failed to inject tunnel: %s", strings.Join(result.Issues, "; "))
+		}
+		content = updated
+	case options.Network != nil:
+		updated, err := ApplyNetworkPolicy(content, options.StackName, options.Network)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply network policy: %w", err)
+		}
+		content = updated
+	}
+
+	if options.Proxy != nil {
+		updated, result, err := NewProxyInjector(options.Proxy, options.StackName, options.Variables).ProcessCompose(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add proxy labels: %w", err)
+		}
+		if !result.Valid {
+			return nil, fmt.Errorf("invalid proxy routes: %s", strings.Join(result.Issues, "; "))
+		}
+		content = updated
+	}
+	return content, nil
+}
@@ -0,0 +1,199 @@
+package docker
+
+import (
+	"sort"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"docker-deploy-app/internal/models"
+)
+
+const testStackCompose = `services:
+  web:
+    image: nginx
+  db:
+    image: postgres
+`
+
+// composeFile is the part of a generated compose file the tests look at
+type composeFile struct {
+	Services map[string]struct {
+		Labels   map[string]string `yaml:"labels"`
+		Networks interface{}       `yaml:"networks"` // A list of names or a mapping by name
+	} `yaml:"services"`
+	Networks map[string]struct {
+		External bool   `yaml:"external"`
+		Name     string `yaml:"name"`
+	} `yaml:"networks"`
+}
+
+func generateStackCompose(t *testing.T, options StackComposeOptions) composeFile {
+	t.Helper()
+	content, err := GenerateStackCompose([]byte(testStackCompose), options)
+	if err != nil {
+		t.Fatalf("GenerateStackCompose: %v", err)
+	}
+	var compose composeFile
+	if err := yaml.Unmarshal(content, &compose); err != nil {
+		t.Fatalf("generated compose file does not parse: %v\n%s", err, content)
+	}
+	return compose
+}
+
+// networkNames returns the networks a service joins, sorted
+func (c composeFile) networkNames(service string) []string {
+	var names []string
+	switch networks := c.Services[service].Networks.(type) {
+	case []interface{}:
+		for _, name := range networks {
+			names = append(names, name.(string))
+		}
+	case map[string]interface{}:
+		for name := range networks {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGenerateStackComposeProxy(t *testing.T) {
+	compose := generateStackCompose(t, StackComposeOptions{
+		StackName: "shop",
+		Proxy: &models.ProxyConfig{
+			Type:    models.ProxyTypeTraefik,
+			Network: "proxy",
+			Routes:  []models.ProxyRoute{{Service: "web", Hostname: "${DOMAIN}", Port: "80"}},
+		},
+		Variables: map[string]string{"DOMAIN": "shop.example.com"},
+	})
+
+	web := compose.Services["web"]
+	if got := web.Labels["traefik.http.routers.shop-web.rule"]; got != "Host(`shop.example.com`)" {
+		t.Errorf("router rule = %q, want the route hostname with variables resolved", got)
+	}
+	if got := web.Labels["traefik.http.services.shop-web.loadbalancer.server.port"]; got != "80" {
+		t.Errorf("service port = %q, want 80", got)
+	}
+	if got := compose.networkNames("web"); !equalNames(got, []string{"default", "proxy"}) {
+		t.Errorf("routed service networks = %v, want default and proxy", got)
+	}
+	if !compose.Networks["proxy"].External {
+		t.Errorf("proxy network is not external")
+	}
+	if len(compose.Services["db"].Labels) != 0 {
+		t.Errorf("unrouted service got labels: %v", compose.Services["db"].Labels)
+	}
+}
+
+func TestGenerateStackComposeProxyUnknownService(t *testing.T) {
+	_, err := GenerateStackCompose([]byte(testStackCompose), StackComposeOptions{
+		StackName: "shop",
+		Proxy: &models.ProxyConfig{
+			Type:   models.ProxyTypeCaddy,
+			Routes: []models.ProxyRoute{{Service: "api", Hostname: "api.example.com", Port: "8080"}},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected a route to an unknown service to fail the deployment")
+	}
+}
+
+func TestGenerateStackComposeIsolation(t *testing.T) {
+	compose := generateStackCompose(t, StackComposeOptions{
+		StackName: "shop",
+		Network: &models.NetworkPolicy{
+			Isolation: models.NetworkIsolated,
+			Allow:     []string{"billing"},
+		},
+	})
+
+	// Services reach their own stack and the allowed one, and nothing else
+	want := []string{"allow_billing", "stack_network"}
+	for _, service := range []string{"web", "db"} {
+		if got := compose.networkNames(service); !equalNames(got, want) {
+			t.Errorf("%s networks = %v, want %v", service, got, want)
+		}
+	}
+	if network := compose.Networks["stack_network"]; network.External || network.Name != "shop_isolated" {
+		t.Errorf("stack network = %+v, want shop_isolated owned by the stack", network)
+	}
+	if network := compose.Networks["allow_billing"]; !network.External || network.Name != "billing_isolated" {
+		t.Errorf("allowed network = %+v, want the external billing_isolated", network)
+	}
+	if len(compose.Networks) != 2 {
+		t.Errorf("networks = %v, want only the stack's own and the allowed stack's", compose.Networks)
+	}
+}
+
+func TestGenerateStackComposeIsolationWithTunnel(t *testing.T) {
+	compose := generateStackCompose(t, StackComposeOptions{
+		StackName: "shop",
+		Tunnel:    NewNewtProvider(&models.NewtConfig{Endpoint: "https://pangolin.example.com", NewtID: "id", Secret: "secret"}),
+		Network:   &models.NetworkPolicy{Isolation: models.NetworkIsolated},
+	})
+
+	// Only the sidecar stays on app_network, shared with other stacks
+	if got := compose.networkNames("newt"); !equalNames(got, []string{"app_network", "stack_network"}) {
+		t.Errorf("newt networks = %v, want app_network and stack_network", got)
+	}
+	for _, service := range []string{"web", "db"} {
+		if got := compose.networkNames(service); !equalNames(got, []string{"stack_network"}) {
+			t.Errorf("%s networks = %v, want only stack_network", service, got)
+		}
+	}
+}
+
+func TestGenerateStackComposeStaticIPs(t *testing.T) {
+	content, err := GenerateStackCompose([]byte(testStackCompose), StackComposeOptions{
+		StackName: "shop",
+		Network: &models.NetworkPolicy{
+			Isolation: models.NetworkShared,
+			Subnet:    "172.28.0.0/24",
+			Gateway:   "172.28.0.1",
+			StaticIPs: map[string]string{"db": "172.28.0.10"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GenerateStackCompose: %v", err)
+	}
+
+	var compose struct {
+		Services map[string]struct {
+			Networks map[string]struct {
+				IPv4Address string `yaml:"ipv4_address"`
+			} `yaml:"networks"`
+		} `yaml:"services"`
+		Networks map[string]struct {
+			IPAM struct {
+				Config []struct {
+					Subnet  string `yaml:"subnet"`
+					Gateway string `yaml:"gateway"`
+				} `yaml:"config"`
+			} `yaml:"ipam"`
+		} `yaml:"networks"`
+	}
+	if err := yaml.Unmarshal(content, &compose); err != nil {
+		t.Fatalf("generated compose file does not parse: %v\n%s", err, content)
+	}
+
+	if got := compose.Services["db"].Networks["default"].IPv4Address; got != "172.28.0.10" {
+		t.Errorf("db ipv4_address = %q, want 172.28.0.10\n%s", got, content)
+	}
+	ipam := compose.Networks["default"].IPAM.Config
+	if len(ipam) != 1 || ipam[0].Subnet != "172.28.0.0/24" || ipam[0].Gateway != "172.28.0.1" {
+		t.Errorf("default network ipam = %+v, want the policy's subnet and gateway", ipam)
+	}
+}
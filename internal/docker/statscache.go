@@ -0,0 +1,166 @@
+package docker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"docker-deploy-app/internal/models"
+)
+
+const (
+	// statsIdleTimeout is how long a container is sampled after stats were last asked for
+	statsIdleTimeout = 2 * time.Minute
+	// statsSampleTimeout bounds a single sample, which takes about a second
+	statsSampleTimeout = 5 * time.Second
+	// statsConcurrency bounds the samples taken at once by a refresh
+	statsConcurrency = 8
+)
+
+// StatsCache samples container stats in the background and serves the latest
+// sample, so any number of dashboards polling a stack cost one sample per
+// container per interval. Only containers asked for within statsIdleTimeout are
+// sampled; the first request for a container samples it directly
+type StatsCache struct {
+	client   *Client
+	interval time.Duration
+	entries  map[string]*statsEntry
+	mu       sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// statsEntry is the latest sample of a container
+type statsEntry struct {
+	stats       *models.ServiceStats
+	err         error
+	requestedAt time.Time
+	ready       chan struct{} // Closed once the first sample is in
+}
+
+// NewStatsCache creates a stats cache refreshing every interval. With no interval
+// stats are sampled on every request
+func NewStatsCache(c *Client, interval time.Duration) *StatsCache {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &StatsCache{
+		client:   c,
+		interval: interval,
+		entries:  make(map[string]*statsEntry),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start begins refreshing cached stats
+func (sc *StatsCache) Start() {
+	if sc.interval <= 0 {
+		return
+	}
+
+	slog.Info("Starting container stats cache", "interval", sc.interval)
+	go func() {
+		ticker := time.NewTicker(sc.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				sc.refresh()
+			case <-sc.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops refreshing cached stats
+func (sc *StatsCache) Stop() {
+	sc.cancel()
+}
+
+// Get returns the latest stats of a container. Their UpdatedAt is when they were
+// sampled, at most one interval ago unless sampling has started failing
+func (sc *StatsCache) Get(ctx context.Context, containerID string) (*models.ServiceStats, error) {
+	if sc.interval <= 0 {
+		return sc.sample(ctx, containerID)
+	}
+
+	sc.mu.Lock()
+	entry, ok := sc.entries[containerID]
+	if !ok {
+		entry = &statsEntry{ready: make(chan struct{})}
+		sc.entries[containerID] = entry
+	}
+	entry.requestedAt = time.Now()
+	sc.mu.Unlock()
+
+	if !ok {
+		stats, err := sc.sample(ctx, containerID)
+		sc.mu.Lock()
+		entry.stats, entry.err = stats, err
+		sc.mu.Unlock()
+		close(entry.ready)
+		return stats, err
+	}
+
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return entry.stats, entry.err
+}
+
+// refresh samples the containers asked for recently and forgets the others
+func (sc *StatsCache) refresh() {
+	sc.mu.Lock()
+	var ids []string
+	for id, entry := range sc.entries {
+		select {
+		case <-entry.ready:
+		default:
+			continue // First sample still being taken
+		}
+		if time.Since(entry.requestedAt) > statsIdleTimeout {
+			delete(sc.entries, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sc.mu.Unlock()
+
+	limit := make(chan struct{}, statsConcurrency)
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-limit }()
+
+			stats, err := sc.sample(sc.ctx, id)
+			sc.mu.Lock()
+			defer sc.mu.Unlock()
+			entry, ok := sc.entries[id]
+			if !ok {
+				return
+			}
+			if err != nil && entry.stats != nil {
+				return // Keep serving the last sample, its age shows it is stale
+			}
+			entry.stats, entry.err = stats, err
+		}(id)
+	}
+	wg.Wait()
+}
+
+// sample takes a one-shot stats sample of a container
+func (sc *StatsCache) sample(ctx context.Context, containerID string) (*models.ServiceStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, statsSampleTimeout)
+	defer cancel()
+	return sc.client.GetContainerStatsContext(ctx, containerID)
+}
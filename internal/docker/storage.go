@@ -0,0 +1,251 @@
+package docker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/webhooks"
+)
+
+// StorageReporter collects disk usage and capacity figures
+type StorageReporter struct {
+	client          *client.Client
+	backupPath      string
+	databasePath    string
+	warningPercent  float64
+	criticalPercent float64
+}
+
+// NewStorageReporter creates a new storage reporter
+func NewStorageReporter(dockerClient *client.Client, backupPath, databasePath string, warningPercent, criticalPercent int) *StorageReporter {
+	return &StorageReporter{
+		client:          dockerClient,
+		backupPath:      backupPath,
+		databasePath:    databasePath,
+		warningPercent:  float64(warningPercent),
+		criticalPercent: float64(criticalPercent),
+	}
+}
+
+// Report gathers Docker disk usage, backup and database sizes, and filesystem capacity
+func (sr *StorageReporter) Report(ctx context.Context) (*models.StorageReport, error) {
+	report := &models.StorageReport{GeneratedAt: time.Now()}
+
+	usage, err := sr.client.DiskUsage(ctx, types.DiskUsageOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get docker disk usage: %w", err)
+	}
+	report.Docker = summarizeDiskUsage(usage)
+
+	report.BackupsBytes, err = directorySize(sr.backupPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to size backup directory: %w", err)
+	}
+
+	// SQLite keeps recent writes in the WAL file until checkpointed
+	for _, path := range []string{sr.databasePath, sr.databasePath + "-wal"} {
+		if info, err := os.Stat(path); err == nil {
+			report.DatabaseBytes += info.Size()
+		}
+	}
+
+	paths := []struct{ name, path string }{
+		{"backups", sr.backupPath},
+		{"database", filepath.Dir(sr.databasePath)},
+	}
+	if info, err := sr.client.Info(ctx); err == nil && info.DockerRootDir != "" {
+		paths = append(paths, struct{ name, path string }{"docker", info.DockerRootDir})
+	}
+
+	for _, p := range paths {
+		fs, err := sr.filesystemUsage(p.name, p.path)
+		if err != nil {
+			continue // Path may live on a remote Docker host or not exist yet
+		}
+		report.Filesystems = append(report.Filesystems, *fs)
+		if fs.Level != models.StorageLevelOK {
+			report.Alerts = append(report.Alerts, models.StorageAlert{
+				Level:   fs.Level,
+				Name:    fs.Name,
+				Path:    fs.Path,
+				Message: fmt.Sprintf("%s filesystem is %.1f%% full", fs.Name, fs.UsedPercent),
+			})
+		}
+	}
+
+	return report, nil
+}
+
+// filesystemUsage reports capacity of the filesystem holding path and its threshold level
+func (sr *StorageReporter) filesystemUsage(name, path string) (*models.FilesystemUsage, error) {
+	total, free, err := filesystemCapacity(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := &models.FilesystemUsage{
+		Name:       name,
+		Path:       path,
+		TotalBytes: total,
+		FreeBytes:  free,
+		UsedBytes:  total - free,
+		Level:      models.StorageLevelOK,
+	}
+	if total > 0 {
+		fs.UsedPercent = float64(fs.UsedBytes) / float64(total) * 100
+	}
+
+	switch {
+	case sr.criticalPercent > 0 && fs.UsedPercent >= sr.criticalPercent:
+		fs.Level = models.StorageLevelCritical
+	case sr.warningPercent > 0 && fs.UsedPercent >= sr.warningPercent:
+		fs.Level = models.StorageLevelWarning
+	}
+
+	return fs, nil
+}
+
+// storageLevelRanks orders the storage levels by severity
+var storageLevelRanks = map[string]int{
+	models.StorageLevelOK:       0,
+	models.StorageLevelWarning:  1,
+	models.StorageLevelCritical: 2,
+}
+
+// StorageMonitor checks the filesystem thresholds on an interval and publishes
+// storage.threshold when a filesystem crosses into a more severe level
+type StorageMonitor struct {
+	reporter  *StorageReporter
+	publisher *webhooks.Publisher
+	interval  time.Duration
+	levels    map[string]string // Last level of each filesystem, by name
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewStorageMonitor creates a new storage threshold monitor
+func NewStorageMonitor(db *sql.DB, reporter *StorageReporter, interval time.Duration) *StorageMonitor {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &StorageMonitor{
+		reporter:  reporter,
+		publisher: webhooks.NewPublisher(db),
+		interval:  interval,
+		levels:    make(map[string]string),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start begins checking the thresholds every interval, and once right away
+func (sm *StorageMonitor) Start() {
+	go func() {
+		sm.check()
+
+		ticker := time.NewTicker(sm.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sm.check()
+			case <-sm.ctx.Done():
+				return
+			}
+		}
+	}()
+	slog.Info("Storage monitor started", "interval", sm.interval)
+}
+
+// Stop stops checking
+func (sm *StorageMonitor) Stop() {
+	sm.cancel()
+	slog.Info("Storage monitor stopped")
+}
+
+// check publishes the filesystems whose level rose since the last check. A level
+// that fell is only recorded, so crossing the threshold again is reported again
+func (sm *StorageMonitor) check() {
+	report, err := sm.reporter.Report(sm.ctx)
+	if err != nil {
+		slog.Warn("Failed to check storage usage", "error", err)
+		return
+	}
+
+	for _, fs := range report.Filesystems {
+		previous := sm.levels[fs.Name]
+		sm.levels[fs.Name] = fs.Level
+		if storageLevelRanks[fs.Level] <= storageLevelRanks[previous] {
+			continue
+		}
+
+		slog.Warn("Filesystem crossed a storage threshold", "name", fs.Name, "path", fs.Path,
+			"level", fs.Level, "used_percent", fs.UsedPercent)
+		sm.publisher.Publish(models.WebhookEventStorageThreshold, map[string]interface{}{
+			"name":         fs.Name,
+			"path":         fs.Path,
+			"level":        fs.Level,
+			"used_percent": fs.UsedPercent,
+			"free_bytes":   fs.FreeBytes,
+			"total_bytes":  fs.TotalBytes,
+		})
+	}
+}
+
+// summarizeDiskUsage converts the Docker disk usage response into totals
+func summarizeDiskUsage(usage types.DiskUsage) models.DockerDiskUsage {
+	summary := models.DockerDiskUsage{
+		ImageCount:     len(usage.Images),
+		ContainerCount: len(usage.Containers),
+		VolumeCount:    len(usage.Volumes),
+		ImagesBytes:    usage.LayersSize,
+	}
+
+	for _, image := range usage.Images {
+		if image.Containers == 0 {
+			summary.ImagesReclaimable += image.Size - image.SharedSize
+		}
+	}
+
+	for _, container := range usage.Containers {
+		summary.ContainersBytes += container.SizeRw
+	}
+
+	for _, vol := range usage.Volumes {
+		if vol.UsageData != nil && vol.UsageData.Size > 0 {
+			summary.VolumesBytes += vol.UsageData.Size
+		}
+	}
+
+	for _, cache := range usage.BuildCache {
+		summary.BuildCacheBytes += cache.Size
+		if !cache.InUse && !cache.Shared {
+			summary.BuildCacheReclaimable += cache.Size
+		}
+	}
+
+	summary.TotalBytes = summary.ImagesBytes + summary.ContainersBytes + summary.VolumesBytes + summary.BuildCacheBytes
+	return summary
+}
+
+// directorySize returns the total size of regular files below a directory
+func directorySize(root string) (int64, error) {
+	var size int64
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"docker-deploy-app/internal/models"
+)
+
+// Labels identifying the stack and service of a container, for compose projects
+// and swarm stacks
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+	swarmStackLabel     = "com.docker.stack.namespace"
+	swarmServiceLabel   = "com.docker.swarm.service.name"
+)
+
+// SummarizeStacks returns the container state of every compose project and swarm
+// stack on the host, by stack name, from a single container listing. Only the
+// container counts and status of the summaries are filled in
+func (c *Client) SummarizeStacks(ctx context.Context) (map[string]*models.StackSummary, error) {
+	containers, err := c.cli.ContainerList(ctx, types.ContainerListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	summaries := make(map[string]*models.StackSummary)
+	services := make(map[string]map[string]bool) // Whether a service of a stack has a running container
+	for _, container := range containers {
+		stack, service := container.Labels[composeProjectLabel], container.Labels[composeServiceLabel]
+		if stack == "" {
+			stack, service = container.Labels[swarmStackLabel], container.Labels[swarmServiceLabel]
+		}
+		if stack == "" {
+			continue
+		}
+
+		summary := summaries[stack]
+		if summary == nil {
+			summary = &models.StackSummary{Name: stack}
+			summaries[stack] = summary
+			services[stack] = make(map[string]bool)
+		}
+
+		running := container.State == "running"
+		summary.Containers++
+		if running {
+			summary.RunningContainers++
+		}
+		if strings.Contains(container.Status, "(unhealthy)") {
+			summary.Unhealthy++
+		}
+		services[stack][service] = services[stack][service] || running
+	}
+
+	for stack, summary := range summaries {
+		summary.Services = len(services[stack])
+		for _, running := range services[stack] {
+			if running {
+				summary.RunningServices++
+			}
+		}
+		switch {
+		case summary.RunningServices == 0:
+			summary.Status = models.StackStatusStopped
+		case summary.RunningServices == summary.Services:
+			summary.Status = models.StackStatusRunning
+		default:
+			summary.Status = models.StackStatusPartial
+		}
+	}
+	return summaries, nil
+}
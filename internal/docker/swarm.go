@@ -0,0 +1,223 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/models"
+)
+
+// stackNamespaceLabel is the label Docker sets on services deployed with `docker stack deploy`
+const stackNamespaceLabel = "com.docker.stack.namespace"
+
+// SwarmManager handles Docker Swarm stack operations
+type SwarmManager struct {
+	client  *client.Client
+	workDir string
+	timeout time.Duration
+}
+
+// NewSwarmManager creates a new swarm manager
+func NewSwarmManager(dockerClient *client.Client, workDir string, timeout time.Duration) *SwarmManager {
+	return &SwarmManager{
+		client:  dockerClient,
+		workDir: workDir,
+		timeout: timeout,
+	}
+}
+
+// IsSwarmActive returns true if the Docker engine is an active swarm manager
+func (sm *SwarmManager) IsSwarmActive() (bool, error) {
+	info, err := sm.client.Info(context.Background())
+	if err != nil {
+		return false, fmt.Errorf("failed to get docker info: %w", err)
+	}
+	return info.Swarm.LocalNodeState == swarm.LocalNodeStateActive && info.Swarm.ControlAvailable, nil
+}
+
+// Deploy deploys a stack to the swarm using `docker stack deploy`
+func (sm *SwarmManager) Deploy(stackName string) error {
+	composePath := filepath.Join(sm.workDir, stackName, "docker-compose.yml")
+	args := []string{"stack", "deploy", "--compose-file", composePath, "--with-registry-auth", stackName}
+	return sm.runCommand(args)
+}
+
+// Remove removes a stack from the swarm
+func (sm *SwarmManager) Remove(stackName string) error {
+	return sm.runCommand([]string{"stack", "rm", stackName})
+}
+
+// Rollback reverts the given stack services to their previous spec
+func (sm *SwarmManager) Rollback(stackName string, services ...string) error {
+	for _, service := range services {
+		if err := sm.runCommand([]string{"service", "rollback", stackName + "_" + service}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start brings a stopped stack back up. It is deployed again rather than scaled,
+// restoring the replica counts of its compose file
+func (sm *SwarmManager) Start(stackName string) error {
+	return sm.Deploy(stackName)
+}
+
+// Stop scales every replicated service of a stack to zero
+func (sm *SwarmManager) Stop(stackName string) error {
+	return sm.Scale(stackName, 0)
+}
+
+// Scale sets the replica count of every replicated service in a stack
+func (sm *SwarmManager) Scale(stackName string, replicas uint64) error {
+	ctx := context.Background()
+	services, err := sm.listServices(ctx, stackName)
+	if err != nil {
+		return err
+	}
+
+	for _, service := range services {
+		if service.Spec.Mode.Replicated == nil {
+			continue // Global services cannot be scaled
+		}
+		spec := service.Spec
+		spec.Mode.Replicated.Replicas = &replicas
+		if _, err := sm.client.ServiceUpdate(ctx, service.ID, service.Version, spec, types.ServiceUpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to scale service %s: %w", service.Spec.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// GetServices retrieves services and replica counts for a swarm stack
+func (sm *SwarmManager) GetServices(stackName string) ([]models.StackService, error) {
+	ctx := context.Background()
+	services, err := sm.listServices(ctx, stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := sm.client.TaskList(ctx, types.TaskListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", stackNamespaceLabel+"="+stackName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+
+	running := make(map[string]int)
+	for _, task := range tasks {
+		if task.Status.State == swarm.TaskStateRunning {
+			running[task.ServiceID]++
+		}
+	}
+
+	var result []models.StackService
+	for _, service := range services {
+		desired := 0
+		if service.Spec.Mode.Replicated != nil && service.Spec.Mode.Replicated.Replicas != nil {
+			desired = int(*service.Spec.Mode.Replicated.Replicas)
+		} else if service.Spec.Mode.Global != nil {
+			desired = running[service.ID] // Global services run one task per eligible node
+		}
+
+		stackService := models.StackService{
+			Name:            service.Spec.Labels["com.docker.stack.service.name"],
+			Image:           service.Spec.TaskTemplate.ContainerSpec.Image,
+			Labels:          service.Spec.Labels,
+			CreatedAt:       service.CreatedAt,
+			Replicas:        running[service.ID],
+			DesiredReplicas: desired,
+		}
+		if stackService.Name == "" {
+			stackService.Name = service.Spec.Name
+		}
+
+		switch {
+		case desired > 0 && running[service.ID] >= desired:
+			stackService.Status = "running"
+			stackService.State = "running"
+			stackService.Health = "healthy"
+		case running[service.ID] > 0:
+			stackService.Status = "partial"
+			stackService.State = "running"
+			stackService.Health = "degraded"
+		default:
+			stackService.Status = "stopped"
+			stackService.State = "exited"
+			stackService.Health = "unhealthy"
+		}
+
+		for _, port := range service.Endpoint.Ports {
+			stackService.Ports = append(stackService.Ports, models.ServicePort{
+				HostPort:      int(port.PublishedPort),
+				ContainerPort: int(port.TargetPort),
+				Protocol:      string(port.Protocol),
+			})
+		}
+
+		result = append(result, stackService)
+	}
+
+	return result, nil
+}
+
+// GetStackStatus returns the status of a swarm stack based on replica counts
+func (sm *SwarmManager) GetStackStatus(stackName string) (models.StackStatus, error) {
+	services, err := sm.GetServices(stackName)
+	if err != nil {
+		return models.StackStatusUnknown, err
+	}
+
+	if len(services) == 0 {
+		return models.StackStatusStopped, nil
+	}
+
+	runningCount := 0
+	for _, service := range services {
+		if service.Status == "running" {
+			runningCount++
+		}
+	}
+
+	if runningCount == len(services) {
+		return models.StackStatusRunning, nil
+	} else if runningCount == 0 {
+		return models.StackStatusStopped, nil
+	}
+	return models.StackStatusPartial, nil
+}
+
+// listServices lists the swarm services belonging to a stack
+func (sm *SwarmManager) listServices(ctx context.Context, stackName string) ([]swarm.Service, error) {
+	services, err := sm.client.ServiceList(ctx, types.ServiceListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", stackNamespaceLabel+"="+stackName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list swarm services: %w", err)
+	}
+	return services, nil
+}
+
+// runCommand executes a docker CLI command with the configured timeout
+func (sm *SwarmManager) runCommand(args []string) error {
+	ctx := context.Background()
+	if sm.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, sm.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("command failed: docker %v: %w: %s", args, err, string(output))
+	}
+	return nil
+}
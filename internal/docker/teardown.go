@@ -0,0 +1,141 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/models"
+)
+
+// StackResources are the Docker resources labelled as belonging to a stack
+type StackResources struct {
+	Containers map[string]string // Name by ID
+	Networks   map[string]string
+	Volumes    map[string]bool
+	Images     map[string]string // Reference by ID, for images of the stack's containers
+}
+
+// ListStackResources returns the containers, networks, volumes and images of a
+// compose project or swarm stack
+func ListStackResources(ctx context.Context, cli *client.Client, stackName string, mode models.DeployMode) (*StackResources, error) {
+	label := "com.docker.compose.project=" + stackName
+	if mode == models.DeployModeSwarm {
+		label = "com.docker.stack.namespace=" + stackName
+	}
+	args := filters.NewArgs(filters.Arg("label", label))
+
+	resources := &StackResources{
+		Containers: make(map[string]string),
+		Networks:   make(map[string]string),
+		Volumes:    make(map[string]bool),
+		Images:     make(map[string]string),
+	}
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	for _, c := range containers {
+		name := shortID(c.ID)
+		if len(c.Names) > 0 {
+			name = c.Names[0][1:]
+		}
+		resources.Containers[c.ID] = name
+		resources.Images[c.ImageID] = c.Image
+	}
+
+	networks, err := cli.NetworkList(ctx, types.NetworkListOptions{Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	for _, n := range networks {
+		resources.Networks[n.ID] = n.Name
+	}
+
+	volumes, err := cli.VolumeList(ctx, volume.ListOptions{Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+	for _, v := range volumes.Volumes {
+		resources.Volumes[v.Name] = true
+	}
+
+	return resources, nil
+}
+
+// Removed returns the summary of the resources listed before a stack was torn
+// down that no longer exist after. Images are checked by ID, as they carry no
+// stack label
+func (before *StackResources) Removed(ctx context.Context, cli *client.Client, after *StackResources) *models.DeploymentDeleteSummary {
+	summary := &models.DeploymentDeleteSummary{
+		Containers:  []string{},
+		Networks:    []string{},
+		Volumes:     []string{},
+		Images:      []string{},
+		KeptVolumes: []string{},
+	}
+	for id, name := range before.Containers {
+		if _, ok := after.Containers[id]; !ok {
+			summary.Containers = append(summary.Containers, name)
+		}
+	}
+	for id, name := range before.Networks {
+		if _, ok := after.Networks[id]; !ok {
+			summary.Networks = append(summary.Networks, name)
+		}
+	}
+	for name := range before.Volumes {
+		if after.Volumes[name] {
+			summary.KeptVolumes = append(summary.KeptVolumes, name)
+		} else {
+			summary.Volumes = append(summary.Volumes, name)
+		}
+	}
+	for id, ref := range before.Images {
+		if _, _, err := cli.ImageInspectWithRaw(ctx, id); client.IsErrNotFound(err) {
+			summary.Images = append(summary.Images, ref)
+		}
+	}
+	return summary
+}
+
+// RemoveSwarmLeftovers removes the volumes and images of a removed swarm stack,
+// which docker stack rm leaves behind. Its containers shut down asynchronously,
+// so volumes still in use are retried until removeWait passes. Images are only
+// removed from this node. Failures are returned as messages
+func RemoveSwarmLeftovers(ctx context.Context, cli *client.Client, resources *StackResources, removeVolumes, removeImages bool) []string {
+	const removeWait = 30 * time.Second
+	var errs []string
+
+	if removeVolumes {
+		deadline := time.Now().Add(removeWait)
+		for name := range resources.Volumes {
+			for {
+				err := cli.VolumeRemove(ctx, name, false)
+				if err == nil || client.IsErrNotFound(err) {
+					break
+				}
+				if time.Now().After(deadline) {
+					errs = append(errs, fmt.Sprintf("volume %s: %v", name, err))
+					break
+				}
+				time.Sleep(time.Second)
+			}
+		}
+	}
+
+	if removeImages {
+		for id, ref := range resources.Images {
+			if _, err := cli.ImageRemove(ctx, id, types.ImageRemoveOptions{}); err != nil && !client.IsErrNotFound(err) {
+				errs = append(errs, fmt.Sprintf("image %s: %v", ref, err))
+			}
+		}
+	}
+
+	return errs
+}
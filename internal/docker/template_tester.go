@@ -0,0 +1,355 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/models"
+)
+
+const (
+	// templateTestPollInterval is how often a test checks its services
+	templateTestPollInterval = 5 * time.Second
+
+	// templateTestLogTail is the log lines of each service kept with a test
+	templateTestLogTail = 200
+
+	// templateTestMaxLogs bounds the logs kept with a test, keeping the end
+	templateTestMaxLogs = 64 * 1024
+
+	// templateTestMaxRestarts fails a test once a container restarted this often
+	templateTestMaxRestarts = 3
+)
+
+// TemplateTester test deploys templates: it deploys a template version into a
+// throwaway stack with generated values and remapped host ports, waits for
+// every service to come up running and healthy, keeps the tail of the logs
+// and tears the stack down with its volumes. A pass badges the version as
+// verified working
+type TemplateTester struct {
+	db      *sql.DB
+	client  *client.Client
+	compose *ComposeManager
+	ports   *PortChecker
+	timeout time.Duration // For the services to come up healthy
+}
+
+// NewTemplateTester creates a new template tester deploying into workDir
+func NewTemplateTester(db *sql.DB, dockerClient *client.Client, workDir string, composeTimeout, timeout time.Duration) *TemplateTester {
+	return &TemplateTester{
+		db:      db,
+		client:  dockerClient,
+		compose: NewComposeManager(workDir, composeTimeout),
+		ports:   NewPortChecker(dockerClient),
+		timeout: timeout,
+	}
+}
+
+// Start records a running test of a template version. A template is tested
+// one version at a time; a test running for over an hour past the timeout is
+// taken as abandoned by a restart
+func (tt *TemplateTester) Start(templateID, version, requestedBy string) (*models.TemplateTest, error) {
+	var running bool
+	err := tt.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM template_tests WHERE template_id = $1 AND status = $2 AND started_at > $3)`,
+		templateID, models.TemplateTestRunning, time.Now().Add(-(tt.timeout + time.Hour)),
+	).Scan(&running)
+	if err != nil {
+		return nil, err
+	}
+	if running {
+		return nil, models.ErrTemplateTestRunning
+	}
+
+	test := &models.TemplateTest{
+		TemplateID:  templateID,
+		Version:     version,
+		StackName:   templateTestStackName(templateID),
+		Status:      models.TemplateTestRunning,
+		RequestedBy: requestedBy,
+		StartedAt:   time.Now(),
+	}
+	result, err := tt.db.Exec(`
+		INSERT INTO template_tests (template_id, version, stack_name, status, requested_by, started_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		test.TemplateID, test.Version, test.StackName, test.Status, test.RequestedBy, test.StartedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	test.ID, _ = result.LastInsertId()
+	return test, nil
+}
+
+// Run runs a started test of compose content and records its outcome. The
+// stack is torn down whatever the outcome
+func (tt *TemplateTester) Run(test *models.TemplateTest, compose []byte, variables []models.TemplateVariable) error {
+	logger := slog.With("template_id", test.TemplateID, "version", test.Version, "stack_name", test.StackName)
+	logger.Info("Template test started")
+
+	err := tt.deploy(test.StackName, compose, TemplateTestValues(variables))
+	if err == nil {
+		err = tt.waitHealthy(test.StackName)
+	}
+	test.Logs = tt.logs(test.StackName)
+
+	if downErr := tt.compose.Remove(test.StackName, true, false); downErr != nil {
+		logger.Warn("Failed to tear down template test", "error", downErr)
+	}
+	if rmErr := tt.compose.RemoveFiles(test.StackName); rmErr != nil {
+		logger.Warn("Failed to remove template test files", "error", rmErr)
+	}
+
+	test.Finish(err)
+	if _, dbErr := tt.db.Exec(`
+		UPDATE template_tests SET status = $1, error = $2, logs = $3, duration_ms = $4, finished_at = $5
+		WHERE id = $6`,
+		test.Status, test.Error, test.Logs, test.DurationMs, test.FinishedAt, test.ID,
+	); dbErr != nil {
+		logger.Error("Failed to record template test", "error", dbErr)
+	}
+	if err != nil {
+		logger.Warn("Template test failed", "error", err)
+		return err
+	}
+
+	// Only while the tested version is still the current one
+	if _, dbErr := tt.db.Exec("UPDATE templates SET tested_version = $1 WHERE id = $2 AND version = $1",
+		test.Version, test.TemplateID); dbErr != nil {
+		logger.Error("Failed to badge tested template", "error", dbErr)
+	}
+	logger.Info("Template test passed", "duration_ms", test.DurationMs)
+	return nil
+}
+
+// List returns the latest tests of a template, newest first, without their logs
+func (tt *TemplateTester) List(templateID string, limit int) ([]models.TemplateTest, error) {
+	rows, err := tt.db.Query(`
+		SELECT id, template_id, version, stack_name, status, COALESCE(error, ''), COALESCE(duration_ms, 0),
+		       COALESCE(requested_by, ''), started_at, finished_at
+		FROM template_tests WHERE template_id = $1
+		ORDER BY started_at DESC, id DESC LIMIT $2`, templateID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tests := []models.TemplateTest{}
+	for rows.Next() {
+		var test models.TemplateTest
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&test.ID, &test.TemplateID, &test.Version, &test.StackName, &test.Status, &test.Error,
+			&test.DurationMs, &test.RequestedBy, &test.StartedAt, &finishedAt); err != nil {
+			return nil, err
+		}
+		if finishedAt.Valid {
+			test.FinishedAt = &finishedAt.Time
+		}
+		tests = append(tests, test)
+	}
+	return tests, rows.Err()
+}
+
+// Get returns a test of a template with its logs
+func (tt *TemplateTester) Get(templateID string, testID int64) (*models.TemplateTest, error) {
+	var test models.TemplateTest
+	var finishedAt sql.NullTime
+	err := tt.db.QueryRow(`
+		SELECT id, template_id, version, stack_name, status, COALESCE(error, ''), COALESCE(logs, ''),
+		       COALESCE(duration_ms, 0), COALESCE(requested_by, ''), started_at, finished_at
+		FROM template_tests WHERE template_id = $1 AND id = $2`, templateID, testID,
+	).Scan(&test.ID, &test.TemplateID, &test.Version, &test.StackName, &test.Status, &test.Error, &test.Logs,
+		&test.DurationMs, &test.RequestedBy, &test.StartedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		test.FinishedAt = &finishedAt.Time
+	}
+	return &test, nil
+}
+
+// deploy writes the compose file of a test stack and brings it up
+func (tt *TemplateTester) deploy(stackName string, compose []byte, env map[string]string) error {
+	projectDir := filepath.Join(tt.compose.workDir, stackName)
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		return fmt.Errorf("failed to create project directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "docker-compose.yml"), compose, 0644); err != nil {
+		return fmt.Errorf("failed to write compose file: %w", err)
+	}
+
+	return tt.compose.Deploy(DeployOptions{
+		StackName:  stackName,
+		EnvVars:    env,
+		Detached:   true,
+		PullImages: true,
+		Ports:      tt.ports,
+		RemapPorts: true, // Never collide with the deployments on the host
+	})
+}
+
+// waitHealthy waits until every container of a stack is running and healthy,
+// failing early on containers that exited with an error, keep restarting or
+// turned unhealthy
+func (tt *TemplateTester) waitHealthy(stackName string) error {
+	deadline := time.Now().Add(tt.timeout)
+	for {
+		pending, err := tt.checkHealth(stackName)
+		if err != nil {
+			return err
+		}
+		if pending == "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s: %s", tt.timeout, pending)
+		}
+		time.Sleep(templateTestPollInterval)
+	}
+}
+
+// checkHealth returns why a stack is not healthy yet, empty once it is, or an
+// error once it cannot become healthy
+func (tt *TemplateTester) checkHealth(stackName string) (string, error) {
+	ctx := context.Background()
+	containers, err := tt.client.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", "com.docker.compose.project="+stackName)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list containers: %w", err)
+	}
+	if len(containers) == 0 {
+		return "no containers were created", nil
+	}
+
+	for _, c := range containers {
+		service := c.Labels["com.docker.compose.service"]
+		inspect, err := tt.client.ContainerInspect(ctx, c.ID)
+		if err != nil {
+			return "", fmt.Errorf("failed to inspect service %s: %w", service, err)
+		}
+		state := inspect.State
+		switch {
+		case state.Status == "exited" && state.ExitCode == 0:
+			// One-shot services such as migrations
+		case state.Status == "exited" || state.Status == "dead":
+			return "", fmt.Errorf("service %s exited with code %d", service, state.ExitCode)
+		case inspect.RestartCount >= templateTestMaxRestarts:
+			return "", fmt.Errorf("service %s restarted %d times", service, inspect.RestartCount)
+		case !state.Running:
+			return fmt.Sprintf("service %s is %s", service, state.Status), nil
+		case state.Health != nil && state.Health.Status == "unhealthy":
+			return "", fmt.Errorf("service %s is unhealthy", service)
+		case state.Health != nil && state.Health.Status != "healthy":
+			return fmt.Sprintf("service %s is %s", service, state.Health.Status), nil
+		}
+	}
+	return "", nil
+}
+
+// logs returns the tail of the logs of a test stack
+func (tt *TemplateTester) logs(stackName string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	output, _ := exec.CommandContext(ctx, "docker", "compose", "--project-name", stackName, "logs",
+		"--no-color", "--tail", strconv.Itoa(templateTestLogTail)).CombinedOutput()
+	if len(output) > templateTestMaxLogs {
+		output = output[len(output)-templateTestMaxLogs:]
+	}
+	return string(output)
+}
+
+// TemplateTestValues generates a value for each variable of a template test:
+// its default, or one its type and validation accept
+func TemplateTestValues(variables []models.TemplateVariable) map[string]string {
+	values := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		values[variable.Name] = templateTestValue(variable)
+	}
+	return values
+}
+
+// templateTestValue generates the value of a variable for a template test
+func templateTestValue(variable models.TemplateVariable) string {
+	if variable.DefaultValue != "" {
+		return variable.DefaultValue
+	}
+	minLength := 0
+	if variable.Validation != nil && variable.Validation.MinLength != nil {
+		minLength = *variable.Validation.MinLength
+	}
+
+	switch variable.Type {
+	case "password":
+		return templateTestSecret(minLength)
+	case "number":
+		if variable.Validation != nil && variable.Validation.Min != nil {
+			return strconv.Itoa(*variable.Validation.Min)
+		}
+		return "1"
+	case "boolean":
+		return "false"
+	case "select":
+		if len(variable.Options) > 0 {
+			return variable.Options[0].Value
+		}
+	}
+
+	upper := strings.ToUpper(variable.Name)
+	value := "test"
+	switch {
+	case IsSecretVariable(variable.Name):
+		return templateTestSecret(minLength)
+	case strings.Contains(upper, "EMAIL"):
+		value = "admin@example.com"
+	case strings.Contains(upper, "URL"):
+		value = "http://localhost"
+	case strings.Contains(upper, "DOMAIN"), strings.Contains(upper, "HOST"):
+		value = "localhost"
+	}
+	for len(value) < minLength {
+		value += "0"
+	}
+	return value
+}
+
+// templateTestSecret returns a random secret of at least minLength characters
+func templateTestSecret(minLength int) string {
+	n := 16
+	if minLength > 2*n {
+		n = (minLength + 1) / 2
+	}
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// templateTestStackName names the throwaway stack of a test of a template
+func templateTestStackName(templateID string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(templateID) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			b.WriteRune(r)
+		}
+		if b.Len() == 32 {
+			break
+		}
+	}
+	return fmt.Sprintf("%s%s-%d", models.TemplateTestStackPrefix, strings.Trim(b.String(), "-"), time.Now().Unix())
+}
@@ -0,0 +1,155 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"docker-deploy-app/internal/models"
+)
+
+const (
+	// TLSCertificateLabel marks the TLS sidecars serving a certificate, so they
+	// can be reloaded when it is renewed
+	TLSCertificateLabel = "docker-deploy.certificate"
+	// TLSCertFile and TLSKeyFile are the certificate chain and private key kept in
+	// the directory of a certificate
+	TLSCertFile = "fullchain.pem"
+	TLSKeyFile  = "privkey.pem"
+
+	tlsSidecarService = "tls-proxy"
+	tlsSidecarImage   = "nginx:1.27-alpine"
+	tlsSidecarConfig  = "tls_proxy_conf"
+	tlsCertMount      = "/etc/nginx/certs"
+)
+
+// InjectTLSSidecar adds an nginx service terminating TLS on a host port with the
+// certificate in certDir, proxying each route's hostname to its service. The
+// nginx configuration is inlined as a compose config, which needs Compose 2.23
+// or later. variables resolve ${NAME} references in route hostnames and ports
+func InjectTLSSidecar(composeContent []byte, tls *models.TLSConfig, certDir string, variables map[string]string) ([]byte, error) {
+	doc, err := parseComposeDocument(composeContent)
+	if err != nil {
+		return nil, err
+	}
+	if doc.service(tlsSidecarService) != nil {
+		return nil, fmt.Errorf("compose file already defines a %s service", tlsSidecarService)
+	}
+
+	var servers, services, networks []string
+	defaultNetwork := false
+	for _, route := range tls.Routes {
+		if doc.service(route.Service) == nil {
+			return nil, fmt.Errorf("TLS route references unknown service: %s", route.Service)
+		}
+		hostname, err := Interpolate([]byte(route.Hostname), variables)
+		if err != nil {
+			return nil, fmt.Errorf("TLS route for %s: %w", route.Service, err)
+		}
+		port, err := Interpolate([]byte(route.Port), variables)
+		if err != nil {
+			return nil, fmt.Errorf("TLS route for %s: %w", route.Service, err)
+		}
+		servers = append(servers, tlsServerBlock(strings.TrimSpace(string(hostname)), route.Service, strings.TrimSpace(string(port))))
+
+		if contains(services, route.Service) {
+			continue
+		}
+		services = append(services, route.Service)
+		// Join the networks of the routed services to reach them
+		serviceNetworks := doc.serviceNetworks(route.Service)
+		if len(serviceNetworks) == 0 {
+			defaultNetwork = true
+		}
+		for _, network := range serviceNetworks {
+			if !contains(networks, network) {
+				networks = append(networks, network)
+			}
+		}
+	}
+	if defaultNetwork && len(networks) > 0 && !contains(networks, "default") {
+		networks = append(networks, "default")
+	}
+
+	image := tls.Image
+	if image == "" {
+		image = tlsSidecarImage
+	}
+	if err := doc.setService(tlsSidecarService, ComposeService{
+		Image:     image,
+		Restart:   "unless-stopped",
+		Ports:     []string{fmt.Sprintf("%d:443", tls.Port)},
+		Volumes:   []string{certDir + ":" + tlsCertMount + ":ro"},
+		Networks:  networks,
+		DependsOn: services,
+		Labels:    map[string]string{TLSCertificateLabel: tls.CertificateID},
+	}); err != nil {
+		return nil, err
+	}
+
+	configRef := &yaml.Node{Kind: yaml.MappingNode}
+	setMappingValue(configRef, "source", scalarNode(tlsSidecarConfig))
+	setMappingValue(configRef, "target", scalarNode("/etc/nginx/conf.d/default.conf"))
+	setMappingValue(doc.service(tlsSidecarService), "configs", &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{configRef}})
+
+	config := &yaml.Node{Kind: yaml.MappingNode}
+	setMappingValue(config, "content", &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!!str",
+		Style: yaml.LiteralStyle,
+		Value: tlsUpgradeMap + strings.Join(servers, ""),
+	})
+	setMappingValue(doc.section("configs", true), tlsSidecarConfig, config)
+
+	return doc.Bytes()
+}
+
+// tlsUpgradeMap lets WebSocket upgrades through the proxy. Dollar signs are
+// doubled throughout the configuration so compose leaves nginx variables alone
+const tlsUpgradeMap = `map $$http_upgrade $$connection_upgrade {
+    default upgrade;
+    '' close;
+}
+`
+
+// tlsServerBlock renders the nginx server proxying a hostname to a service port
+func tlsServerBlock(hostname, service, port string) string {
+	return fmt.Sprintf(`
+server {
+    listen 443 ssl;
+    http2 on;
+    server_name %s;
+    ssl_certificate %s/%s;
+    ssl_certificate_key %s/%s;
+    ssl_protocols TLSv1.2 TLSv1.3;
+
+    location / {
+        proxy_pass http://%s:%s;
+        proxy_http_version 1.1;
+        proxy_set_header Host $$host;
+        proxy_set_header X-Real-IP $$remote_addr;
+        proxy_set_header X-Forwarded-For $$proxy_add_x_forwarded_for;
+        proxy_set_header X-Forwarded-Proto https;
+        proxy_set_header Upgrade $$http_upgrade;
+        proxy_set_header Connection $$connection_upgrade;
+    }
+}
+`, hostname, tlsCertMount, TLSCertFile, tlsCertMount, TLSKeyFile, service, port)
+}
+
+// addTLSSidecar rewrites the compose file to serve routes over TLS
+func (cm *ComposeManager) addTLSSidecar(projectDir string, options DeployOptions) error {
+	composePath := filepath.Join(projectDir, "docker-compose.yml")
+	content, err := os.ReadFile(composePath)
+	if err != nil {
+		return fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	updated, err := InjectTLSSidecar(content, options.TLS, options.TLSCertDir, options.EnvVars)
+	if err != nil {
+		return fmt.Errorf("failed to add TLS sidecar: %w", err)
+	}
+	return os.WriteFile(composePath, updated, 0644)
+}
@@ -0,0 +1,376 @@
+package docker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/maintenance"
+	"docker-deploy-app/internal/models"
+)
+
+// trashPurgeInterval is how often expired deployments are purged from the trash
+const trashPurgeInterval = time.Hour
+
+// Trash keeps deleted deployments restorable. A trashed deployment's row is
+// stored as JSON, its directory is moved under .trash in the deployments
+// directory, and its volumes are left in place until it is purged
+type Trash struct {
+	db        *sql.DB
+	client    *client.Client
+	workDir   string
+	retention time.Duration
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewTrash creates a deployment trash keeping deployments for retentionDays
+func NewTrash(db *sql.DB, dockerClient *client.Client, workDir string, retentionDays int) *Trash {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Trash{
+		db:        db,
+		client:    dockerClient,
+		workDir:   workDir,
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start begins purging expired deployments
+func (t *Trash) Start() {
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if maintenance.Active(t.db) {
+					continue
+				}
+				if purged, err := t.PurgeExpired(); err != nil {
+					slog.Error("Failed to purge expired deployments from trash", "error", err)
+				} else if purged > 0 {
+					slog.Info("Purged expired deployments from trash", "deployments", purged)
+				}
+			case <-t.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops purging
+func (t *Trash) Stop() {
+	t.cancel()
+}
+
+// Add moves a deployment whose stack was taken down to the trash, with the
+// volumes it kept. Its logs are deleted with it
+func (t *Trash) Add(deploymentID, deletedBy string, volumes []string) (*models.TrashedDeployment, error) {
+	columns, err := t.deploymentColumns()
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]string, len(columns))
+	for i, column := range columns {
+		pairs[i] = fmt.Sprintf("'%s', \"%s\"", column, column)
+	}
+
+	item := &models.TrashedDeployment{ID: deploymentID, DeletedBy: deletedBy, Volumes: volumes}
+	if item.Volumes == nil {
+		item.Volumes = []string{}
+	}
+	var snapshot string
+	var projectID, templateID, deployMode sql.NullString
+	err = t.db.QueryRow(`
+		SELECT stack_name, project_id, template_id, deploy_mode, json_object(`+strings.Join(pairs, ", ")+`)
+		FROM deployments WHERE id = $1`, deploymentID).Scan(&item.StackName, &projectID, &templateID, &deployMode, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+	item.ProjectID, item.TemplateID, item.DeployMode = projectID.String, templateID.String, models.DeployMode(deployMode.String)
+	item.DeletedAt = time.Now()
+	item.ExpiresAt = item.DeletedAt.Add(t.retention)
+
+	// Move the files first, so they can be moved back if the database refuses
+	if err := t.moveFiles(t.stackDir(item.StackName), t.trashDir(item.ID)); err != nil {
+		return nil, err
+	}
+	if err := t.add(item, snapshot); err != nil {
+		if moveErr := t.moveFiles(t.trashDir(item.ID), t.stackDir(item.StackName)); moveErr != nil {
+			slog.Error("Failed to move deployment files back from trash", "stack", item.StackName, "error", moveErr)
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+func (t *Trash) add(item *models.TrashedDeployment, snapshot string) error {
+	volumesJSON, _ := json.Marshal(item.Volumes)
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO deployment_trash (id, stack_name, project_id, template_id, deploy_mode, deployment, volumes,
+		                              deleted_by, deleted_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		item.ID, item.StackName, item.ProjectID, item.TemplateID, item.DeployMode, snapshot, string(volumesJSON),
+		item.DeletedBy, item.DeletedAt, item.ExpiresAt); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM deployment_logs WHERE deployment_id = $1", item.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM deployments WHERE id = $1", item.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// List returns the trashed deployments, most recently deleted first
+func (t *Trash) List() ([]models.TrashedDeployment, error) {
+	rows, err := t.db.Query(`
+		SELECT id, stack_name, COALESCE(project_id, ''), COALESCE(template_id, ''), COALESCE(deploy_mode, ''),
+		       COALESCE(volumes, '[]'), COALESCE(deleted_by, ''), deleted_at, expires_at
+		FROM deployment_trash
+		ORDER BY deleted_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.TrashedDeployment{}
+	for rows.Next() {
+		item, err := scanTrashed(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+// Get returns a trashed deployment, or ErrTrashNotFound
+func (t *Trash) Get(id string) (*models.TrashedDeployment, error) {
+	item, err := scanTrashed(t.db.QueryRow(`
+		SELECT id, stack_name, COALESCE(project_id, ''), COALESCE(template_id, ''), COALESCE(deploy_mode, ''),
+		       COALESCE(volumes, '[]'), COALESCE(deleted_by, ''), deleted_at, expires_at
+		FROM deployment_trash
+		WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrTrashNotFound
+	}
+	return item, err
+}
+
+// Restore puts a trashed deployment back, stopped, with its files. It fails with
+// ErrStackNameTaken when another deployment has taken its stack name since
+func (t *Trash) Restore(id string) (*models.TrashedDeployment, error) {
+	item, err := t.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var taken bool
+	if err := t.db.QueryRow("SELECT EXISTS(SELECT 1 FROM deployments WHERE stack_name = $1 OR id = $2)",
+		item.StackName, item.ID).Scan(&taken); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(t.stackDir(item.StackName)); taken || err == nil {
+		return nil, models.ErrStackNameTaken
+	}
+
+	if err := t.moveFiles(t.trashDir(item.ID), t.stackDir(item.StackName)); err != nil {
+		return nil, err
+	}
+	if err := t.restore(item); err != nil {
+		if moveErr := t.moveFiles(t.stackDir(item.StackName), t.trashDir(item.ID)); moveErr != nil {
+			slog.Error("Failed to move deployment files back to trash", "stack", item.StackName, "error", moveErr)
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+func (t *Trash) restore(item *models.TrashedDeployment) error {
+	var snapshot string
+	if err := t.db.QueryRow("SELECT deployment FROM deployment_trash WHERE id = $1", item.ID).Scan(&snapshot); err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(snapshot))
+	decoder.UseNumber()
+	var row map[string]interface{}
+	if err := decoder.Decode(&row); err != nil {
+		return fmt.Errorf("invalid trashed deployment: %w", err)
+	}
+	row["status"] = string(models.StatusStopped)
+	row["updated_at"] = time.Now()
+
+	// Columns added since the deployment was trashed keep their defaults
+	columns, err := t.deploymentColumns()
+	if err != nil {
+		return err
+	}
+	var names, placeholders []string
+	var args []interface{}
+	for _, column := range columns {
+		value, ok := row[column]
+		if !ok {
+			continue
+		}
+		if number, isNumber := value.(json.Number); isNumber {
+			if n, err := number.Int64(); err == nil {
+				value = n
+			} else {
+				value, _ = number.Float64()
+			}
+		}
+		args = append(args, value)
+		names = append(names, fmt.Sprintf("\"%s\"", column))
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT INTO deployments ("+strings.Join(names, ", ")+") VALUES ("+strings.Join(placeholders, ", ")+")",
+		args...); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM deployment_trash WHERE id = $1", item.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Purge permanently deletes a trashed deployment with its volumes and files. It
+// stays in the trash if a volume cannot be removed, so it can be retried
+func (t *Trash) Purge(id string) (*models.TrashedDeployment, error) {
+	item, err := t.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	for _, name := range item.Volumes {
+		if err := t.client.VolumeRemove(t.ctx, name, false); err != nil && !client.IsErrNotFound(err) {
+			errs = append(errs, fmt.Sprintf("volume %s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to remove volumes: %s", strings.Join(errs, "; "))
+	}
+
+	if err := os.RemoveAll(t.trashDir(item.ID)); err != nil {
+		return nil, fmt.Errorf("failed to remove files: %w", err)
+	}
+	if _, err := t.db.Exec("DELETE FROM deployment_trash WHERE id = $1", item.ID); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// PurgeExpired purges the deployments kept past their retention and returns how
+// many were purged
+func (t *Trash) PurgeExpired() (int, error) {
+	rows, err := t.db.Query("SELECT id FROM deployment_trash WHERE expires_at <= $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	purged := 0
+	for _, id := range ids {
+		if _, err := t.Purge(id); err != nil {
+			slog.Warn("Failed to purge deployment from trash", "deployment_id", id, "error", err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// deploymentColumns returns the current columns of the deployments table
+func (t *Trash) deploymentColumns() ([]string, error) {
+	rows, err := t.db.Query("SELECT name FROM pragma_table_info('deployments')")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+func (t *Trash) stackDir(stackName string) string {
+	return filepath.Join(t.workDir, stackName)
+}
+
+func (t *Trash) trashDir(id string) string {
+	return filepath.Join(t.workDir, ".trash", id)
+}
+
+// moveFiles moves a deployment directory, doing nothing when there is none
+func (t *Trash) moveFiles(from, to string) error {
+	if _, err := os.Stat(from); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(from, to); err != nil {
+		return fmt.Errorf("failed to move deployment files: %w", err)
+	}
+	return nil
+}
+
+// trashScanner is a *sql.Row or *sql.Rows
+type trashScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTrashed(row trashScanner) (*models.TrashedDeployment, error) {
+	var item models.TrashedDeployment
+	var deployMode, volumesJSON string
+	if err := row.Scan(&item.ID, &item.StackName, &item.ProjectID, &item.TemplateID, &deployMode, &volumesJSON,
+		&item.DeletedBy, &item.DeletedAt, &item.ExpiresAt); err != nil {
+		return nil, err
+	}
+	item.DeployMode = models.DeployMode(deployMode)
+	json.Unmarshal([]byte(volumesJSON), &item.Volumes)
+	if item.Volumes == nil {
+		item.Volumes = []string{}
+	}
+	return &item, nil
+}
@@ -0,0 +1,349 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+
+	"docker-deploy-app/internal/models"
+)
+
+// TunnelProvider describes a tunnel sidecar that can be injected into a stack
+type TunnelProvider interface {
+	// Name returns the provider identifier
+	Name() models.TunnelProvider
+	// ServiceName returns the compose service name of the sidecar
+	ServiceName() string
+	// Validate checks the provider configuration before injection
+	Validate() error
+	// EnvironmentVars returns the sidecar environment
+	EnvironmentVars() []string
+	// CreateService returns a properly configured sidecar service
+	CreateService() ComposeService
+	// ValidateService checks an existing sidecar service in a compose file
+	ValidateService(service ComposeService) error
+	// Probe returns the log markers used to derive tunnel connectivity
+	Probe() TunnelProbe
+}
+
+// TunnelProbe holds log markers that indicate tunnel connectivity changes
+type TunnelProbe struct {
+	ConnectedMarkers    []string
+	DisconnectedMarkers []string
+}
+
+// NewTunnelProvider creates the tunnel provider selected for a deployment
+func NewTunnelProvider(provider models.TunnelProvider, newtConfig *models.NewtConfig, tunnelConfig *models.TunnelConfig) (TunnelProvider, error) {
+	switch provider {
+	case "", models.TunnelProviderNewt:
+		if newtConfig == nil {
+			return nil, models.ErrNewtConfigRequired
+		}
+		return NewNewtProvider(newtConfig), nil
+	case models.TunnelProviderCloudflare:
+		if tunnelConfig == nil {
+			return nil, models.ErrTunnelConfigRequired
+		}
+		return &CloudflareProvider{config: tunnelConfig}, nil
+	case models.TunnelProviderTailscale:
+		if tunnelConfig == nil {
+			return nil, models.ErrTunnelConfigRequired
+		}
+		return &TailscaleProvider{config: tunnelConfig}, nil
+	default:
+		return nil, models.ErrInvalidTunnelProvider
+	}
+}
+
+// ProbeFor returns the log probe of a provider by name, as found in the app.name label
+func ProbeFor(name string) TunnelProbe {
+	switch models.TunnelProvider(name) {
+	case models.TunnelProviderCloudflare:
+		return (&CloudflareProvider{}).Probe()
+	case models.TunnelProviderTailscale:
+		return (&TailscaleProvider{}).Probe()
+	default:
+		return (&NewtProvider{}).Probe()
+	}
+}
+
+// tunnelLabels returns the labels shared by all tunnel sidecars
+func tunnelLabels(name models.TunnelProvider) map[string]string {
+	return map[string]string{
+		"app.type":       "tunnel",
+		"app.name":       string(name),
+		"app.managed":    "true",
+		"traefik.enable": "false",
+	}
+}
+
+// requireEnv returns an error listing required variables missing from a service environment
+func requireEnv(service ComposeService, names ...string) error {
+	var missing []string
+	for _, name := range names {
+		found := false
+		for _, env := range service.Environment {
+			if strings.HasPrefix(env, name+"=") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required environment variables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// NewtProvider injects a Newt sidecar connecting to a Pangolin server
+type NewtProvider struct {
+	config *models.NewtConfig
+}
+
+// NewNewtProvider creates a new Newt tunnel provider
+func NewNewtProvider(config *models.NewtConfig) *NewtProvider {
+	return &NewtProvider{config: config}
+}
+
+// Name returns the provider identifier
+func (p *NewtProvider) Name() models.TunnelProvider {
+	return models.TunnelProviderNewt
+}
+
+// ServiceName returns the compose service name of the sidecar
+func (p *NewtProvider) ServiceName() string {
+	return "newt"
+}
+
+// Validate checks the Newt configuration
+func (p *NewtProvider) Validate() error {
+	return p.config.Validate()
+}
+
+// EnvironmentVars returns the Newt environment
+func (p *NewtProvider) EnvironmentVars() []string {
+	return p.config.GetEnvironmentVars()
+}
+
+// CreateService creates a properly configured Newt service
+func (p *NewtProvider) CreateService() ComposeService {
+	service := ComposeService{
+		Image:         "fosrl/newt:latest",
+		ContainerName: "newt",
+		Restart:       "unless-stopped",
+		Environment:   p.EnvironmentVars(),
+		Volumes: []string{
+			"/var/run/docker.sock:/var/run/docker.sock:ro",
+		},
+		Networks: []string{"app_network"},
+		Labels:   tunnelLabels(p.Name()),
+		HealthCheck: &ComposeHealthCheck{
+			Test:        []string{"CMD", "test", "-f", "/tmp/healthy"},
+			Interval:    "30s",
+			Timeout:     "10s",
+			Retries:     3,
+			StartPeriod: "60s",
+		},
+	}
+
+	// Add additional configuration if specified
+	if p.config.Image != "" {
+		service.Image = p.config.Image
+	}
+
+	return service
+}
+
+// ValidateService validates an existing newt service configuration
+func (p *NewtProvider) ValidateService(service ComposeService) error {
+	// Check image
+	if service.Image == "" {
+		return fmt.Errorf("newt service missing image")
+	}
+
+	if !strings.Contains(service.Image, "newt") {
+		return fmt.Errorf("newt service using incorrect image: %s", service.Image)
+	}
+
+	if err := requireEnv(service, "PANGOLIN_ENDPOINT", "NEWT_ID", "NEWT_SECRET"); err != nil {
+		return err
+	}
+
+	// Check for Docker socket mount
+	hasDockerSocket := false
+	for _, volume := range service.Volumes {
+		if strings.Contains(volume, "/var/run/docker.sock") {
+			hasDockerSocket = true
+			break
+		}
+	}
+
+	if !hasDockerSocket {
+		return fmt.Errorf("newt service missing Docker socket mount")
+	}
+
+	return nil
+}
+
+// Probe returns the Newt log markers
+func (p *NewtProvider) Probe() TunnelProbe {
+	return TunnelProbe{
+		ConnectedMarkers:    []string{"connected", "established"},
+		DisconnectedMarkers: []string{"disconnect", "connection lost"},
+	}
+}
+
+// CloudflareProvider injects a cloudflared sidecar running a remotely-managed tunnel
+type CloudflareProvider struct {
+	config *models.TunnelConfig
+}
+
+// Name returns the provider identifier
+func (p *CloudflareProvider) Name() models.TunnelProvider {
+	return models.TunnelProviderCloudflare
+}
+
+// ServiceName returns the compose service name of the sidecar
+func (p *CloudflareProvider) ServiceName() string {
+	return "cloudflared"
+}
+
+// Validate checks the tunnel token
+func (p *CloudflareProvider) Validate() error {
+	if strings.TrimSpace(p.config.Token) == "" {
+		return fmt.Errorf("cloudflare tunnel token is required")
+	}
+	return nil
+}
+
+// EnvironmentVars returns the cloudflared environment
+func (p *CloudflareProvider) EnvironmentVars() []string {
+	return []string{
+		"TUNNEL_TOKEN=" + p.config.Token,
+	}
+}
+
+// CreateService creates a properly configured cloudflared service
+func (p *CloudflareProvider) CreateService() ComposeService {
+	service := ComposeService{
+		Image:         "cloudflare/cloudflared:latest",
+		ContainerName: "cloudflared",
+		Restart:       "unless-stopped",
+		Environment:   p.EnvironmentVars(),
+		Networks:      []string{"app_network"},
+		Labels:        tunnelLabels(p.Name()),
+		Command:       []string{"tunnel", "--no-autoupdate", "run"},
+	}
+
+	if p.config.Image != "" {
+		service.Image = p.config.Image
+	}
+
+	return service
+}
+
+// ValidateService validates an existing cloudflared service configuration
+func (p *CloudflareProvider) ValidateService(service ComposeService) error {
+	if !strings.Contains(service.Image, "cloudflared") {
+		return fmt.Errorf("cloudflared service using incorrect image: %s", service.Image)
+	}
+	return requireEnv(service, "TUNNEL_TOKEN")
+}
+
+// Probe returns the cloudflared log markers
+func (p *CloudflareProvider) Probe() TunnelProbe {
+	return TunnelProbe{
+		ConnectedMarkers:    []string{"registered tunnel connection"},
+		DisconnectedMarkers: []string{"unregistered tunnel connection", "connection terminated"},
+	}
+}
+
+// TailscaleProvider injects a Tailscale sidecar, optionally exposing services with Funnel
+type TailscaleProvider struct {
+	config *models.TunnelConfig
+}
+
+// Name returns the provider identifier
+func (p *TailscaleProvider) Name() models.TunnelProvider {
+	return models.TunnelProviderTailscale
+}
+
+// ServiceName returns the compose service name of the sidecar
+func (p *TailscaleProvider) ServiceName() string {
+	return "tailscale"
+}
+
+// Validate checks the auth key and node name
+func (p *TailscaleProvider) Validate() error {
+	if !strings.HasPrefix(p.config.AuthKey, "tskey-") {
+		return fmt.Errorf("tailscale auth key must start with tskey-")
+	}
+	if strings.TrimSpace(p.config.Hostname) == "" {
+		return fmt.Errorf("tailscale hostname is required")
+	}
+	return nil
+}
+
+// EnvironmentVars returns the Tailscale environment
+func (p *TailscaleProvider) EnvironmentVars() []string {
+	env := []string{
+		"TS_AUTHKEY=" + p.config.AuthKey,
+		"TS_HOSTNAME=" + p.config.Hostname,
+		"TS_STATE_DIR=/var/lib/tailscale",
+		"TS_USERSPACE=true",
+	}
+	if p.config.ServeConfig != "" {
+		env = append(env, "TS_SERVE_CONFIG=/config/serve.json")
+	}
+	return env
+}
+
+// CreateService creates a properly configured Tailscale service
+func (p *TailscaleProvider) CreateService() ComposeService {
+	service := ComposeService{
+		Image:         "tailscale/tailscale:latest",
+		ContainerName: "tailscale",
+		Restart:       "unless-stopped",
+		Environment:   p.EnvironmentVars(),
+		Volumes: []string{
+			"./tailscale:/var/lib/tailscale",
+		},
+		Networks: []string{"app_network"},
+		Labels:   tunnelLabels(p.Name()),
+		HealthCheck: &ComposeHealthCheck{
+			Test:        []string{"CMD", "tailscale", "status"},
+			Interval:    "30s",
+			Timeout:     "10s",
+			Retries:     3,
+			StartPeriod: "30s",
+		},
+	}
+
+	if p.config.ServeConfig != "" {
+		service.Volumes = append(service.Volumes, p.config.ServeConfig+":/config/serve.json:ro")
+	}
+	if p.config.Image != "" {
+		service.Image = p.config.Image
+	}
+
+	return service
+}
+
+// ValidateService validates an existing tailscale service configuration
+func (p *TailscaleProvider) ValidateService(service ComposeService) error {
+	if !strings.Contains(service.Image, "tailscale") {
+		return fmt.Errorf("tailscale service using incorrect image: %s", service.Image)
+	}
+	return requireEnv(service, "TS_AUTHKEY")
+}
+
+// Probe returns the Tailscale log markers
+func (p *TailscaleProvider) Probe() TunnelProbe {
+	return TunnelProbe{
+		ConnectedMarkers:    []string{"startup complete", "-> running"},
+		DisconnectedMarkers: []string{"-> needslogin", "-> stopped"},
+	}
+}
@@ -0,0 +1,311 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"docker-deploy-app/internal/models"
+)
+
+// TunnelInjector handles injection of a tunnel sidecar service into Docker Compose files
+type TunnelInjector struct {
+	provider  TunnelProvider
+	stackName string
+	policy    *models.NetworkPolicy
+}
+
+// NewTunnelInjector creates a new tunnel injector for the given provider
+func NewTunnelInjector(provider TunnelProvider) *TunnelInjector {
+	return &TunnelInjector{provider: provider}
+}
+
+// WithNetworkPolicy applies the network policy of a stack when injecting
+func (ni *TunnelInjector) WithNetworkPolicy(stackName string, policy *models.NetworkPolicy) *TunnelInjector {
+	ni.stackName = stackName
+	ni.policy = policy
+	return ni
+}
+
+// NewNewtInjector creates a tunnel injector for a Newt (Pangolin) tunnel
+func NewNewtInjector(config *models.NewtConfig) *TunnelInjector {
+	return NewTunnelInjector(NewNewtProvider(config))
+}
+
+// ValidationResult represents the result of tunnel validation
+type ValidationResult struct {
+	Valid        bool     `json:"valid"`
+	HasTunnel    bool     `json:"has_tunnel"`
+	NetworkOK    bool     `json:"network_ok"`
+	Issues       []string `json:"issues"`
+	Warnings     []string `json:"warnings"`
+	Suggestions  []string `json:"suggestions"`
+}
+
+// ProcessCompose processes a docker-compose.yml file and injects the tunnel service if needed.
+// Only the tunnel service and network entries are touched; content that needs no change is
+// returned as-is
+func (ni *TunnelInjector) ProcessCompose(composeContent []byte) ([]byte, *ValidationResult, error) {
+	doc, err := parseComposeDocument(composeContent)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Validate current compose file
+	result := ni.validateDocument(doc)
+	changed := false
+
+	// Check if tunnel service already exists
+	serviceName := ni.provider.ServiceName()
+	if doc.service(serviceName) != nil {
+		result.HasTunnel = true
+		// Validate existing tunnel configuration
+		if err := ni.provider.ValidateService(doc.decodeService(serviceName)); err != nil {
+			result.Issues = append(result.Issues, err.Error())
+			// Update the existing tunnel service with correct config
+			if err := doc.setService(serviceName, ni.provider.CreateService()); err != nil {
+				return nil, result, err
+			}
+			changed = true
+			result.Suggestions = append(result.Suggestions, fmt.Sprintf("Updated existing %s service with correct configuration", serviceName))
+		}
+	} else {
+		// Add tunnel service
+		if err := doc.setService(serviceName, ni.provider.CreateService()); err != nil {
+			return nil, result, err
+		}
+		changed = true
+		result.HasTunnel = true
+		result.Suggestions = append(result.Suggestions, fmt.Sprintf("Added %s service for tunnel connectivity", serviceName))
+	}
+
+	// Ensure network configuration
+	networksChanged, err := ni.ensureNetworkConfiguration(doc)
+	if err != nil {
+		result.NetworkOK = false
+		result.Issues = append(result.Issues, err.Error())
+	} else {
+		result.NetworkOK = true
+	}
+	changed = changed || networksChanged
+
+	// Final validation
+	result.Valid = len(result.Issues) == 0
+
+	if !changed {
+		return composeContent, result, nil
+	}
+
+	modifiedContent, err := doc.Bytes()
+	if err != nil {
+		return nil, result, err
+	}
+
+	return modifiedContent, result, nil
+}
+
+// ValidateCompose validates docker-compose content for tunnel compatibility
+func (ni *TunnelInjector) ValidateCompose(composeContent []byte) (*ValidationResult, error) {
+	doc, err := parseComposeDocument(composeContent)
+	if err != nil {
+		return nil, err
+	}
+	return ni.validateDocument(doc), nil
+}
+
+// validateDocument validates a parsed compose file for tunnel compatibility
+func (ni *TunnelInjector) validateDocument(doc *composeDocument) *ValidationResult {
+	result := &ValidationResult{
+		Valid:       true,
+		HasTunnel:   false,
+		NetworkOK:   true,
+		Issues:      []string{},
+		Warnings:    []string{},
+		Suggestions: []string{},
+	}
+
+	// Check if services exist
+	serviceNames := doc.serviceNames()
+	if len(serviceNames) == 0 {
+		result.Issues = append(result.Issues, "No services defined in docker-compose file")
+		result.Valid = false
+		return result
+	}
+
+	// Check for tunnel service
+	if doc.service(ni.provider.ServiceName()) != nil {
+		result.HasTunnel = true
+		if err := ni.provider.ValidateService(doc.decodeService(ni.provider.ServiceName())); err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("%s service configuration error: %s", ni.provider.ServiceName(), err.Error()))
+			result.Valid = false
+		}
+	}
+
+	// Check network configuration
+	if err := ni.validateNetworkConfiguration(doc); err != nil {
+		result.NetworkOK = false
+		result.Warnings = append(result.Warnings, err.Error())
+	}
+
+	// Check for potential port conflicts
+	ports := make(map[string][]string)
+	for _, serviceName := range serviceNames {
+		for _, port := range doc.decodeService(serviceName).Ports {
+			hostPort := strings.Split(port, ":")[0]
+			ports[hostPort] = append(ports[hostPort], serviceName)
+		}
+	}
+
+	for port, services := range ports {
+		if len(services) > 1 {
+			result.Warnings = append(result.Warnings, 
+				fmt.Sprintf("Port %s is used by multiple services: %s", port, strings.Join(services, ", ")))
+		}
+	}
+
+	// Suggest improvements
+	if !result.HasTunnel {
+		result.Suggestions = append(result.Suggestions, fmt.Sprintf("Add %s service for remote tunnel access", ni.provider.ServiceName()))
+	}
+
+	if doc.section("networks", false) == nil {
+		result.Suggestions = append(result.Suggestions, "Define custom networks for better service isolation")
+	}
+
+	return result
+}
+
+// ensureNetworkConfiguration joins every service to app_network and defines it,
+// then applies the network policy, reporting whether the document changed
+func (ni *TunnelInjector) ensureNetworkConfiguration(doc *composeDocument) (bool, error) {
+	changed := false
+	isolated := ni.policy != nil && ni.policy.Isolation == models.NetworkIsolated
+
+	// Ensure all services are connected to app_network, only the tunnel sidecar
+	// when isolated; network_mode excludes networks
+	for _, name := range doc.serviceNames() {
+		if mappingValue(doc.service(name), "network_mode") != nil {
+			continue
+		}
+		if isolated && name != ni.provider.ServiceName() {
+			continue
+		}
+		if !contains(doc.serviceNetworks(name), "app_network") {
+			doc.addServiceNetwork(name, "app_network")
+			changed = true
+		}
+	}
+
+	if !doc.hasNetwork("app_network") {
+		err := doc.setNetwork("app_network", ComposeNetwork{
+			Driver: "bridge",
+			Labels: map[string]string{
+				"app.managed": "true",
+			},
+		})
+		if err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+
+	if ni.policy != nil {
+		policyChanged, err := applyNetworkPolicy(doc, ni.stackName, ni.policy, ni.provider.ServiceName())
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || policyChanged
+	}
+
+	return changed, nil
+}
+
+// validateNetworkConfiguration validates network configuration
+func (ni *TunnelInjector) validateNetworkConfiguration(doc *composeDocument) error {
+	if doc.section("networks", false) == nil {
+		return fmt.Errorf("no networks defined - services may not be able to communicate")
+	}
+
+	// Check if services are properly networked
+	servicesWithoutNetworks := []string{}
+	for _, name := range doc.serviceNames() {
+		if len(doc.serviceNetworks(name)) == 0 {
+			servicesWithoutNetworks = append(servicesWithoutNetworks, name)
+		}
+	}
+
+	if len(servicesWithoutNetworks) > 0 {
+		return fmt.Errorf("services without network configuration: %s", strings.Join(servicesWithoutNetworks, ", "))
+	}
+
+	return nil
+}
+
+// InjectIntoFile reads, processes, and writes back a docker-compose file
+func (ni *TunnelInjector) InjectIntoFile(filePath string) (*ValidationResult, error) {
+	// Read the file
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	// Process the content
+	modifiedContent, result, err := ni.ProcessCompose(content)
+	if err != nil {
+		return result, err
+	}
+
+	// Write back the modified content
+	if err := os.WriteFile(filePath, modifiedContent, 0644); err != nil {
+		return result, fmt.Errorf("failed to write modified compose file: %w", err)
+	}
+
+	return result, nil
+}
+
+// PreviewInjection shows what changes would be made without applying them
+func (ni *TunnelInjector) PreviewInjection(composeContent []byte) (map[string]interface{}, error) {
+	doc, err := parseComposeDocument(composeContent)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := map[string]interface{}{
+		"provider":           ni.provider.Name(),
+		"has_tunnel_service": false,
+		"will_add_tunnel":    false,
+		"will_add_network":   false,
+		"changes":            []string{},
+	}
+
+	// Check if tunnel service exists
+	if doc.service(ni.provider.ServiceName()) != nil {
+		preview["has_tunnel_service"] = true
+	} else {
+		preview["will_add_tunnel"] = true
+		preview["changes"] = append(preview["changes"].([]string), fmt.Sprintf("Add %s service", ni.provider.ServiceName()))
+	}
+
+	// Check if networks need to be added
+	if !doc.hasNetwork("app_network") {
+		preview["will_add_network"] = true
+		preview["changes"] = append(preview["changes"].([]string), "Add app_network")
+	}
+
+	// Check which services will be modified for networking
+	servicesNeedingNetworks := []string{}
+	for _, name := range doc.serviceNames() {
+		if !contains(doc.serviceNetworks(name), "app_network") {
+			servicesNeedingNetworks = append(servicesNeedingNetworks, name)
+		}
+	}
+
+	if len(servicesNeedingNetworks) > 0 {
+		preview["changes"] = append(preview["changes"].([]string), 
+			fmt.Sprintf("Add network configuration to services: %s", strings.Join(servicesNeedingNetworks, ", ")))
+	}
+
+	preview["tunnel_service"] = ni.provider.CreateService()
+
+	return preview, nil
+}
\ No newline at end of file
@@ -0,0 +1,212 @@
+package docker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/maintenance"
+	"docker-deploy-app/internal/models"
+)
+
+// UpdateChecker compares running image digests against their registries
+type UpdateChecker struct {
+	db     *sql.DB
+	client *client.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewUpdateChecker creates a new image update checker
+func NewUpdateChecker(db *sql.DB, dockerClient *client.Client) *UpdateChecker {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &UpdateChecker{
+		db:     db,
+		client: dockerClient,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start begins periodic update checks for all running deployments
+func (uc *UpdateChecker) Start(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	slog.Info("Starting image update checker", "interval", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				uc.checkAll()
+			case <-uc.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops periodic update checks
+func (uc *UpdateChecker) Stop() {
+	uc.cancel()
+}
+
+// CheckStack checks every service image of a deployment and records the result
+func (uc *UpdateChecker) CheckStack(deployment *models.Deployment) ([]models.ImageUpdate, error) {
+	projectLabel, serviceLabel := "com.docker.compose.project", "com.docker.compose.service"
+	if deployment.IsSwarm() {
+		projectLabel, serviceLabel = stackNamespaceLabel, "com.docker.swarm.service.name"
+	}
+
+	containers, err := uc.client.ContainerList(uc.ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", projectLabel+"="+deployment.StackName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stack containers: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var updates []models.ImageUpdate
+	for _, container := range containers {
+		serviceName := container.Labels[serviceLabel]
+		if serviceName == "" || seen[serviceName] {
+			continue
+		}
+		seen[serviceName] = true
+
+		update, err := uc.checkImage(serviceName, container.Image, container.ImageID)
+		if err != nil {
+			slog.Warn("Failed to check image", "image", container.Image, "stack", deployment.StackName, "service", serviceName, "error", err)
+			continue
+		}
+
+		if err := uc.saveUpdate(deployment.ID, update); err != nil {
+			return nil, fmt.Errorf("failed to save update status: %w", err)
+		}
+		updates = append(updates, *update)
+	}
+
+	return updates, nil
+}
+
+// GetUpdates returns the last recorded update status for a deployment
+func (uc *UpdateChecker) GetUpdates(deploymentID string) ([]models.ImageUpdate, error) {
+	rows, err := uc.db.Query(`
+		SELECT service_name, image, current_digest, latest_digest, update_available, checked_at
+		FROM image_updates
+		WHERE deployment_id = $1
+		ORDER BY service_name`, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var updates []models.ImageUpdate
+	for rows.Next() {
+		var update models.ImageUpdate
+		if err := rows.Scan(&update.ServiceName, &update.Image, &update.CurrentDigest,
+			&update.LatestDigest, &update.UpdateAvailable, &update.CheckedAt); err != nil {
+			continue
+		}
+		updates = append(updates, update)
+	}
+
+	return updates, rows.Err()
+}
+
+// checkAll checks every running deployment
+func (uc *UpdateChecker) checkAll() {
+	if maintenance.Active(uc.db) {
+		return
+	}
+
+	rows, err := uc.db.Query("SELECT id, stack_name, deploy_mode FROM deployments WHERE status = 'running'")
+	if err != nil {
+		slog.Error("Failed to list deployments for update check", "error", err)
+		return
+	}
+
+	var deployments []models.Deployment
+	for rows.Next() {
+		var d models.Deployment
+		if err := rows.Scan(&d.ID, &d.StackName, &d.DeployMode); err != nil {
+			continue
+		}
+		deployments = append(deployments, d)
+	}
+	rows.Close()
+
+	for i := range deployments {
+		if _, err := uc.CheckStack(&deployments[i]); err != nil {
+			slog.Error("Update check failed", "stack", deployments[i].StackName, "error", err)
+		}
+	}
+}
+
+// checkImage compares the local digest of an image with the registry manifest digest
+func (uc *UpdateChecker) checkImage(serviceName, imageRef, imageID string) (*models.ImageUpdate, error) {
+	update := &models.ImageUpdate{
+		ServiceName: serviceName,
+		Image:       imageRef,
+		CheckedAt:   time.Now(),
+	}
+
+	inspect, _, err := uc.client.ImageInspectWithRaw(uc.ctx, imageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image: %w", err)
+	}
+
+	// Locally built images have no repo digest and cannot be compared
+	if len(inspect.RepoDigests) == 0 {
+		return update, nil
+	}
+	update.CurrentDigest = digestFromRef(inspect.RepoDigests[0])
+
+	distribution, err := uc.client.DistributionInspect(uc.ctx, imageRef, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query registry: %w", err)
+	}
+	update.LatestDigest = distribution.Descriptor.Digest.String()
+
+	// An image can carry several repo digests (e.g. per platform); any match means up to date
+	update.UpdateAvailable = true
+	for _, repoDigest := range inspect.RepoDigests {
+		if digestFromRef(repoDigest) == update.LatestDigest {
+			update.UpdateAvailable = false
+			update.CurrentDigest = update.LatestDigest
+			break
+		}
+	}
+
+	return update, nil
+}
+
+// saveUpdate upserts the update status of a service
+func (uc *UpdateChecker) saveUpdate(deploymentID string, update *models.ImageUpdate) error {
+	_, err := uc.db.Exec(`
+		INSERT OR REPLACE INTO image_updates
+		(deployment_id, service_name, image, current_digest, latest_digest, update_available, checked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		deploymentID, update.ServiceName, update.Image, update.CurrentDigest,
+		update.LatestDigest, update.UpdateAvailable, update.CheckedAt)
+	return err
+}
+
+// digestFromRef extracts the digest from a reference like "nginx@sha256:..."
+func digestFromRef(ref string) string {
+	if idx := strings.Index(ref, "@"); idx >= 0 {
+		return ref[idx+1:]
+	}
+	return ref
+}
@@ -0,0 +1,530 @@
+package docker
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/webhooks"
+)
+
+const (
+	// uptimeTick is how often probes are checked for being due
+	uptimeTick = 5 * time.Second
+
+	// uptimePruneInterval is how often old checks are pruned
+	uptimePruneInterval = time.Hour
+
+	// probeBodyLimit bounds the part of an HTTP response searched for a keyword
+	probeBodyLimit = 1 << 20
+)
+
+// UptimeMonitor runs the HTTP and TCP probes of deployments on their intervals,
+// recording each check. A probe failing FailureThreshold checks in a row goes
+// down and publishes probe.down; its first successful check after that
+// publishes probe.recovered
+type UptimeMonitor struct {
+	db        *sql.DB
+	client    *client.Client
+	config    config.UptimeConfig
+	publisher *webhooks.Publisher
+	ctx       context.Context
+	cancel    context.CancelFunc
+	inFlight  map[int64]bool
+	mu        sync.Mutex // Guards inFlight
+}
+
+// NewUptimeMonitor creates a new uptime monitor
+func NewUptimeMonitor(db *sql.DB, dockerClient *client.Client, cfg config.UptimeConfig) *UptimeMonitor {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &UptimeMonitor{
+		db:        db,
+		client:    dockerClient,
+		config:    cfg,
+		publisher: webhooks.NewPublisher(db),
+		ctx:       ctx,
+		cancel:    cancel,
+		inFlight:  make(map[int64]bool),
+	}
+}
+
+// Start begins checking probes as they come due
+func (um *UptimeMonitor) Start() {
+	go func() {
+		ticker := time.NewTicker(uptimeTick)
+		defer ticker.Stop()
+		lastPrune := time.Time{}
+		for {
+			select {
+			case <-ticker.C:
+				um.checkDue()
+				if time.Since(lastPrune) >= uptimePruneInterval {
+					um.prune()
+					lastPrune = time.Now()
+				}
+			case <-um.ctx.Done():
+				return
+			}
+		}
+	}()
+	slog.Info("Uptime monitor started")
+}
+
+// Stop stops checking probes
+func (um *UptimeMonitor) Stop() {
+	um.cancel()
+	slog.Info("Uptime monitor stopped")
+}
+
+// List returns the probes of a deployment with their uptime over the last day
+func (um *UptimeMonitor) List(deploymentID string) ([]*models.UptimeProbe, error) {
+	rows, err := um.db.Query(`
+		SELECT id, deployment_id, name, type, target, interval_seconds, timeout_seconds, expected_status,
+		       keyword, failure_threshold, enabled, status, consecutive_failures, last_check_at,
+		       last_latency_ms, last_error, created_at
+		FROM uptime_probes WHERE deployment_id = $1 ORDER BY name`, deploymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	probes := []*models.UptimeProbe{}
+	for rows.Next() {
+		probe, err := scanProbe(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		probes = append(probes, probe)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, probe := range probes {
+		stats, err := um.Stats(probe.ID, "24h", 24*time.Hour)
+		if err != nil {
+			return nil, err
+		}
+		probe.Uptime = stats
+	}
+	return probes, nil
+}
+
+// Get returns a probe of a deployment
+func (um *UptimeMonitor) Get(deploymentID string, probeID int64) (*models.UptimeProbe, error) {
+	probe, err := scanProbe(um.db.QueryRow(`
+		SELECT id, deployment_id, name, type, target, interval_seconds, timeout_seconds, expected_status,
+		       keyword, failure_threshold, enabled, status, consecutive_failures, last_check_at,
+		       last_latency_ms, last_error, created_at
+		FROM uptime_probes WHERE id = $1 AND deployment_id = $2`, probeID, deploymentID))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrProbeNotFound
+	}
+	return probe, err
+}
+
+// Create saves a new probe, first checked on the next tick
+func (um *UptimeMonitor) Create(probe *models.UptimeProbe) error {
+	if err := probe.Validate(); err != nil {
+		return err
+	}
+	probe.Status = models.ProbeUnknown
+	probe.CreatedAt = time.Now()
+
+	result, err := um.db.Exec(`
+		INSERT INTO uptime_probes (deployment_id, name, type, target, interval_seconds, timeout_seconds,
+		                           expected_status, keyword, failure_threshold, enabled, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		probe.DeploymentID, probe.Name, probe.Type, probe.Target, probe.Interval, probe.Timeout,
+		probe.ExpectedStatus, probe.Keyword, probe.FailureThreshold, probe.Enabled, probe.Status, probe.CreatedAt)
+	if err != nil {
+		return err
+	}
+	probe.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// Update saves the changes of a probe. A changed target starts over from an
+// unknown status
+func (um *UptimeMonitor) Update(probe *models.UptimeProbe, targetChanged bool) error {
+	if err := probe.Validate(); err != nil {
+		return err
+	}
+	if targetChanged {
+		probe.Status = models.ProbeUnknown
+		probe.ConsecutiveFailures = 0
+	}
+
+	_, err := um.db.Exec(`
+		UPDATE uptime_probes
+		SET name = $1, type = $2, target = $3, interval_seconds = $4, timeout_seconds = $5,
+		    expected_status = $6, keyword = $7, failure_threshold = $8, enabled = $9,
+		    status = $10, consecutive_failures = $11
+		WHERE id = $12`,
+		probe.Name, probe.Type, probe.Target, probe.Interval, probe.Timeout,
+		probe.ExpectedStatus, probe.Keyword, probe.FailureThreshold, probe.Enabled,
+		probe.Status, probe.ConsecutiveFailures, probe.ID)
+	return err
+}
+
+// Delete removes a probe with its check history
+func (um *UptimeMonitor) Delete(probe *models.UptimeProbe) error {
+	if _, err := um.db.Exec("DELETE FROM uptime_checks WHERE probe_id = $1", probe.ID); err != nil {
+		return err
+	}
+	_, err := um.db.Exec("DELETE FROM uptime_probes WHERE id = $1", probe.ID)
+	return err
+}
+
+// History returns the checks of a probe within a window, newest first
+func (um *UptimeMonitor) History(probeID int64, window time.Duration, limit int) ([]models.ProbeCheck, error) {
+	rows, err := um.db.Query(`
+		SELECT probe_id, up, latency_ms, COALESCE(status_code, 0), COALESCE(error, ''), checked_at
+		FROM uptime_checks WHERE probe_id = $1 AND checked_at >= $2
+		ORDER BY checked_at DESC LIMIT $3`, probeID, time.Now().Add(-window), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checks := []models.ProbeCheck{}
+	for rows.Next() {
+		var check models.ProbeCheck
+		if err := rows.Scan(&check.ProbeID, &check.Up, &check.LatencyMs, &check.StatusCode, &check.Error, &check.CheckedAt); err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	return checks, rows.Err()
+}
+
+// Stats summarizes the checks of a probe within a window, labeled name
+func (um *UptimeMonitor) Stats(probeID int64, name string, window time.Duration) (*models.UptimeStats, error) {
+	rows, err := um.db.Query(`
+		SELECT up, latency_ms FROM uptime_checks
+		WHERE probe_id = $1 AND checked_at >= $2`, probeID, time.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := &models.UptimeStats{Window: name}
+	var latencies []int64
+	for rows.Next() {
+		var up bool
+		var latency int64
+		if err := rows.Scan(&up, &latency); err != nil {
+			return nil, err
+		}
+		stats.Checks++
+		if up {
+			stats.UpChecks++
+			latencies = append(latencies, latency)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if stats.Checks > 0 {
+		stats.UptimePercent = float64(stats.UpChecks) * 100 / float64(stats.Checks)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats.LatencyP50 = percentile(latencies, 50)
+	stats.LatencyP95 = percentile(latencies, 95)
+	stats.LatencyP99 = percentile(latencies, 99)
+	return stats, nil
+}
+
+// CheckNow checks a probe immediately and records the result
+func (um *UptimeMonitor) CheckNow(ctx context.Context, probe *models.UptimeProbe, stackName string) models.ProbeCheck {
+	check := um.check(ctx, probe, stackName)
+	um.record(probe, stackName, check)
+	return check
+}
+
+// checkDue checks the probes that are due, of running deployments, up to
+// MaxConcurrent at once
+func (um *UptimeMonitor) checkDue() {
+	rows, err := um.db.Query(`
+		SELECT p.id, p.deployment_id, p.name, p.type, p.target, p.interval_seconds, p.timeout_seconds,
+		       p.expected_status, p.keyword, p.failure_threshold, p.enabled, p.status, p.consecutive_failures,
+		       p.last_check_at, p.last_latency_ms, p.last_error, p.created_at, d.stack_name
+		FROM uptime_probes p
+		JOIN deployments d ON d.id = p.deployment_id
+		WHERE p.enabled = TRUE AND d.status = 'running'`)
+	if err != nil {
+		slog.Error("Failed to load uptime probes", "error", err)
+		return
+	}
+
+	type dueProbe struct {
+		probe     *models.UptimeProbe
+		stackName string
+	}
+	now := time.Now()
+	var due []dueProbe
+	for rows.Next() {
+		var stackName string
+		probe, err := scanProbe(rows, &stackName)
+		if err != nil {
+			continue
+		}
+		if probe.Due(now) {
+			due = append(due, dueProbe{probe, stackName})
+		}
+	}
+	rows.Close()
+
+	sem := make(chan struct{}, um.config.MaxConcurrent)
+	for _, d := range due {
+		if !um.claim(d.probe.ID) {
+			continue // Still checking since an earlier tick
+		}
+		sem <- struct{}{}
+		go func(d dueProbe) {
+			defer func() { <-sem }()
+			defer um.release(d.probe.ID)
+			um.CheckNow(um.ctx, d.probe, d.stackName)
+		}(d)
+	}
+}
+
+// claim marks a probe as being checked, reporting false if it already is
+func (um *UptimeMonitor) claim(probeID int64) bool {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+	if um.inFlight[probeID] {
+		return false
+	}
+	um.inFlight[probeID] = true
+	return true
+}
+
+func (um *UptimeMonitor) release(probeID int64) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+	delete(um.inFlight, probeID)
+}
+
+// check runs one check of a probe
+func (um *UptimeMonitor) check(ctx context.Context, probe *models.UptimeProbe, stackName string) models.ProbeCheck {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(probe.Timeout)*time.Second)
+	defer cancel()
+
+	check := models.ProbeCheck{ProbeID: probe.ID, CheckedAt: time.Now()}
+	var err error
+	if probe.Type == models.ProbeTCP {
+		err = um.checkTCP(ctx, probe, stackName)
+	} else {
+		check.StatusCode, err = um.checkHTTP(ctx, probe, stackName)
+	}
+	check.LatencyMs = time.Since(check.CheckedAt).Milliseconds()
+	check.Up = err == nil
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// checkTCP opens a connection to the target of a probe
+func (um *UptimeMonitor) checkTCP(ctx context.Context, probe *models.UptimeProbe, stackName string) error {
+	host, port, _ := net.SplitHostPort(probe.Target)
+	address := net.JoinHostPort(um.resolveHost(ctx, stackName, host), port)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// checkHTTP requests the target of a probe, checking its status and keyword
+func (um *UptimeMonitor) checkHTTP(ctx context.Context, probe *models.UptimeProbe, stackName string) (int, error) {
+	target, err := url.Parse(probe.Target)
+	if err != nil {
+		return 0, err
+	}
+	hostname := target.Hostname()
+	resolved := um.resolveHost(ctx, stackName, hostname)
+	requestURL := *target
+	if resolved != hostname {
+		requestURL.Host = resolved
+		if port := target.Port(); port != "" {
+			requestURL.Host = net.JoinHostPort(resolved, port)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Host = target.Host
+	req.Header.Set("User-Agent", "docker-deploy-uptime/1.0")
+
+	// Services reached at their container address still present their name
+	httpClient := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{ServerName: hostname},
+		DisableKeepAlives: true,
+	}}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if probe.ExpectedStatus != 0 && resp.StatusCode != probe.ExpectedStatus {
+		return resp.StatusCode, fmt.Errorf("status %d, expected %d", resp.StatusCode, probe.ExpectedStatus)
+	}
+	if probe.ExpectedStatus == 0 && resp.StatusCode >= 400 {
+		return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if probe.Keyword != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, probeBodyLimit))
+		if err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to read response: %w", err)
+		}
+		if !strings.Contains(string(body), probe.Keyword) {
+			return resp.StatusCode, fmt.Errorf("keyword %q not found in response", probe.Keyword)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// resolveHost returns the container address of a stack service named host,
+// or host itself when no such service runs
+func (um *UptimeMonitor) resolveHost(ctx context.Context, stackName, host string) string {
+	containers, err := um.client.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", "com.docker.compose.project="+stackName),
+			filters.Arg("label", "com.docker.compose.service="+host),
+		),
+	})
+	if err != nil || len(containers) == 0 || containers[0].NetworkSettings == nil {
+		return host
+	}
+	for _, endpoint := range containers[0].NetworkSettings.Networks {
+		if endpoint != nil && endpoint.IPAddress != "" {
+			return endpoint.IPAddress
+		}
+	}
+	return host
+}
+
+// record saves a check and moves the probe's status, alerting when it goes
+// down or recovers
+func (um *UptimeMonitor) record(probe *models.UptimeProbe, stackName string, check models.ProbeCheck) {
+	if _, err := um.db.Exec(`
+		INSERT INTO uptime_checks (probe_id, up, latency_ms, status_code, error, checked_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		check.ProbeID, check.Up, check.LatencyMs, check.StatusCode, check.Error, check.CheckedAt); err != nil {
+		slog.Error("Failed to record uptime check", "probe_id", probe.ID, "error", err)
+		return
+	}
+
+	previous := probe.Status
+	if check.Up {
+		probe.ConsecutiveFailures = 0
+		probe.Status = models.ProbeUp
+	} else {
+		probe.ConsecutiveFailures++
+		if probe.ConsecutiveFailures >= probe.FailureThreshold {
+			probe.Status = models.ProbeDown
+		}
+	}
+	probe.LastCheckAt = &check.CheckedAt
+	probe.LastLatencyMs = check.LatencyMs
+	probe.LastError = check.Error
+
+	um.db.Exec(`
+		UPDATE uptime_probes
+		SET status = $1, consecutive_failures = $2, last_check_at = $3, last_latency_ms = $4, last_error = $5
+		WHERE id = $6`,
+		probe.Status, probe.ConsecutiveFailures, probe.LastCheckAt, probe.LastLatencyMs, probe.LastError, probe.ID)
+
+	event := map[string]interface{}{
+		"deployment_id":        probe.DeploymentID,
+		"stack_name":           stackName,
+		"probe_id":             probe.ID,
+		"probe":                probe.Name,
+		"target":               probe.Target,
+		"consecutive_failures": probe.ConsecutiveFailures,
+		"status_code":          check.StatusCode,
+		"error":                check.Error,
+	}
+	switch {
+	case probe.Status == models.ProbeDown && previous != models.ProbeDown:
+		slog.Warn("Uptime probe is down", "stack", stackName, "probe", probe.Name, "failures", probe.ConsecutiveFailures, "error", check.Error)
+		um.publisher.Publish(models.WebhookEventProbeDown, event)
+	case probe.Status == models.ProbeUp && previous == models.ProbeDown:
+		slog.Info("Uptime probe recovered", "stack", stackName, "probe", probe.Name)
+		um.publisher.Publish(models.WebhookEventProbeRecovered, event)
+	}
+}
+
+// prune removes checks older than the retention period
+func (um *UptimeMonitor) prune() {
+	cutoff := time.Now().AddDate(0, 0, -um.config.RetentionDays)
+	result, err := um.db.Exec("DELETE FROM uptime_checks WHERE checked_at < $1", cutoff)
+	if err != nil {
+		slog.Error("Failed to prune uptime checks", "error", err)
+		return
+	}
+	if pruned, _ := result.RowsAffected(); pruned > 0 {
+		slog.Info("Pruned uptime checks", "count", pruned)
+	}
+}
+
+// percentile returns the p-th percentile of sorted values, 0 without values
+func percentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := (len(sorted)*p + 99) / 100
+	if index > 0 {
+		index--
+	}
+	return sorted[index]
+}
+
+// scanProbe scans an uptime probe from a database row, followed by extra
+// columns into extra
+func scanProbe(scanner interface {
+	Scan(dest ...interface{}) error
+}, extra ...interface{}) (*models.UptimeProbe, error) {
+	var probe models.UptimeProbe
+	var keyword, lastError sql.NullString
+	var lastCheckAt sql.NullTime
+	dest := []interface{}{
+		&probe.ID, &probe.DeploymentID, &probe.Name, &probe.Type, &probe.Target, &probe.Interval, &probe.Timeout,
+		&probe.ExpectedStatus, &keyword, &probe.FailureThreshold, &probe.Enabled, &probe.Status,
+		&probe.ConsecutiveFailures, &lastCheckAt, &probe.LastLatencyMs, &lastError, &probe.CreatedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	probe.Keyword = keyword.String
+	probe.LastError = lastError.String
+	if lastCheckAt.Valid {
+		probe.LastCheckAt = &lastCheckAt.Time
+	}
+	return &probe, nil
+}
@@ -0,0 +1,353 @@
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+)
+
+const (
+	// maxVolumeEntries bounds the entries listed for one directory
+	maxVolumeEntries = 1000
+
+	// volumeBrowserTimeout bounds running the helper container of a listing
+	volumeBrowserTimeout = 30 * time.Second
+)
+
+var (
+	ErrVolumeBrowserDisabled = fmt.Errorf("volume browser is disabled")
+	ErrVolumeReadOnly        = fmt.Errorf("volume browser does not allow writes")
+	ErrVolumeNotFound        = fmt.Errorf("volume not found")
+	ErrVolumePathNotFound    = fmt.Errorf("path not found in volume")
+	ErrVolumePathInvalid     = fmt.Errorf("invalid volume path")
+	ErrVolumeNotDirectory    = fmt.Errorf("path is not a directory")
+	ErrVolumeNotFile         = fmt.Errorf("path is not a regular file")
+	ErrVolumeFileTooLarge    = fmt.Errorf("file exceeds the volume browser size limit")
+)
+
+// listScript prints one line per entry of the directory given as $1, hidden
+// entries included. Exit codes 3 and 4 report a missing path and a path that
+// is not a directory. One line past maxVolumeEntries marks a truncated listing
+const listScript = `[ -e "$1" ] || [ -L "$1" ] || exit 3
+[ -d "$1" ] || exit 4
+cd "$1" || exit 3
+for f in * .[!.]* ..?*; do
+	{ [ -e "$f" ] || [ -L "$f" ]; } && stat -c '%F|%s|%Y|%a|%n' "$f"
+done | head -n 1001`
+
+// VolumeBrowser reads and writes the files of stack volumes. Volume mount
+// points are often not readable by this process, and may live on a remote
+// Docker host, so files are reached through a short-lived helper container
+// that mounts the volume, read-only unless a file is written
+type VolumeBrowser struct {
+	client *client.Client
+	config config.VolumeBrowserConfig
+	helper *volumeHelper
+}
+
+// NewVolumeBrowser creates a new volume browser
+func NewVolumeBrowser(dockerClient *client.Client, cfg config.VolumeBrowserConfig) *VolumeBrowser {
+	return &VolumeBrowser{
+		client: dockerClient,
+		config: cfg,
+		helper: newVolumeHelper(dockerClient, cfg.HelperImage),
+	}
+}
+
+// Enabled reports whether volumes may be browsed
+func (vb *VolumeBrowser) Enabled() bool {
+	return vb.config.Enabled
+}
+
+// Writable reports whether files may be uploaded to volumes
+func (vb *VolumeBrowser) Writable() bool {
+	return vb.config.Enabled && vb.config.AllowWrite
+}
+
+// MaxFileSize returns the largest file downloaded or uploaded, in bytes
+func (vb *VolumeBrowser) MaxFileSize() int64 {
+	return int64(vb.config.MaxFileSizeMB) << 20
+}
+
+// Volumes returns the named volumes of a stack
+func (vb *VolumeBrowser) Volumes(ctx context.Context, stackName string) ([]models.StackVolume, error) {
+	if !vb.config.Enabled {
+		return nil, ErrVolumeBrowserDisabled
+	}
+	list, err := vb.client.VolumeList(ctx, volume.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", "com.docker.compose.project="+stackName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+
+	volumes := make([]models.StackVolume, 0, len(list.Volumes))
+	for _, vol := range list.Volumes {
+		volumes = append(volumes, models.StackVolume{
+			Name:       vol.Name,
+			Driver:     vol.Driver,
+			MountPoint: vol.Mountpoint,
+			Labels:     vol.Labels,
+		})
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	return volumes, nil
+}
+
+// List returns the entries of a directory of a stack volume, directories first
+func (vb *VolumeBrowser) List(ctx context.Context, stackName, volumeName, dir string) (*models.VolumeListing, error) {
+	relative, target, err := vb.resolve(ctx, stackName, volumeName, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, volumeBrowserTimeout)
+	defer cancel()
+
+	containerID, err := vb.helper.create(ctx, []mount.Mount{volumeMount(volumeName, volumeMountPath, false)}, "sh", "-c", listScript, "sh", target)
+	if err != nil {
+		return nil, err
+	}
+	defer vb.helper.remove(containerID)
+
+	stdout, err := vb.helper.run(ctx, containerID)
+	var exitErr *helperExitError
+	if errors.As(err, &exitErr) && exitErr.code == 3 {
+		return nil, ErrVolumePathNotFound
+	}
+	if errors.As(err, &exitErr) && exitErr.code == 4 {
+		return nil, ErrVolumeNotDirectory
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	listing := &models.VolumeListing{Volume: volumeName, Path: relative, Files: []models.VolumeFile{}}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if len(listing.Files) == maxVolumeEntries {
+			listing.Truncated = true
+			break
+		}
+		if file, ok := parseStatLine(scanner.Text(), relative); ok {
+			listing.Files = append(listing.Files, file)
+		}
+	}
+
+	sort.Slice(listing.Files, func(i, j int) bool {
+		a, b := listing.Files[i], listing.Files[j]
+		if (a.Type == models.VolumeFileDirectory) != (b.Type == models.VolumeFileDirectory) {
+			return a.Type == models.VolumeFileDirectory
+		}
+		return a.Name < b.Name
+	})
+	return listing, nil
+}
+
+// Download copies a regular file of a stack volume to the writer start
+// returns. start is given the file name and size before any content is copied
+func (vb *VolumeBrowser) Download(ctx context.Context, stackName, volumeName, filePath string, start func(name string, size int64) io.Writer) error {
+	_, target, err := vb.resolve(ctx, stackName, volumeName, filePath)
+	if err != nil {
+		return err
+	}
+
+	containerID, err := vb.helper.create(ctx, []mount.Mount{volumeMount(volumeName, volumeMountPath, false)}, "true")
+	if err != nil {
+		return err
+	}
+	defer vb.helper.remove(containerID)
+
+	stat, err := vb.client.ContainerStatPath(ctx, containerID, target)
+	if client.IsErrNotFound(err) {
+		return ErrVolumePathNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", filePath, err)
+	}
+	if !stat.Mode.IsRegular() {
+		return ErrVolumeNotFile
+	}
+	if stat.Size > vb.MaxFileSize() {
+		return ErrVolumeFileTooLarge
+	}
+
+	reader, _, err := vb.client.CopyFromContainer(ctx, containerID, target)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %w", filePath, err)
+	}
+	defer reader.Close()
+
+	tarReader := tar.NewReader(reader)
+	header, err := tarReader.Next()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	_, err = io.Copy(start(stat.Name, header.Size), tarReader)
+	return err
+}
+
+// Upload writes a file to a stack volume, replacing any file at the path. The
+// parent directory must exist. Content larger than the size limit is rejected
+// before anything is written to the volume
+func (vb *VolumeBrowser) Upload(ctx context.Context, stackName, volumeName, filePath string, content io.Reader) (*models.VolumeFile, error) {
+	if !vb.config.Enabled {
+		return nil, ErrVolumeBrowserDisabled
+	}
+	if !vb.config.AllowWrite {
+		return nil, ErrVolumeReadOnly
+	}
+	relative, target, err := vb.resolve(ctx, stackName, volumeName, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if target == volumeMountPath {
+		return nil, ErrVolumeNotFile
+	}
+
+	// The tar header needs the size, so the content is spooled first
+	spool, err := os.CreateTemp("", "volume-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer upload: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, io.LimitReader(content, vb.MaxFileSize()+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer upload: %w", err)
+	}
+	if size > vb.MaxFileSize() {
+		return nil, ErrVolumeFileTooLarge
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to buffer upload: %w", err)
+	}
+
+	containerID, err := vb.helper.create(ctx, []mount.Mount{volumeMount(volumeName, volumeMountPath, true)}, "true")
+	if err != nil {
+		return nil, err
+	}
+	defer vb.helper.remove(containerID)
+
+	dir := path.Dir(target)
+	stat, err := vb.client.ContainerStatPath(ctx, containerID, dir)
+	if client.IsErrNotFound(err) {
+		return nil, ErrVolumePathNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", path.Dir(relative), err)
+	}
+	if !stat.Mode.IsDir() {
+		return nil, ErrVolumeNotDirectory
+	}
+	if existing, err := vb.client.ContainerStatPath(ctx, containerID, target); err == nil && !existing.Mode.IsRegular() {
+		return nil, ErrVolumeNotFile
+	}
+
+	modTime := time.Now()
+	archive, writer := io.Pipe()
+	go func() {
+		tarWriter := tar.NewWriter(writer)
+		err := tarWriter.WriteHeader(&tar.Header{
+			Name:     path.Base(target),
+			Mode:     0644,
+			Size:     size,
+			ModTime:  modTime,
+			Typeflag: tar.TypeReg,
+		})
+		if err == nil {
+			_, err = io.Copy(tarWriter, spool)
+		}
+		if err == nil {
+			err = tarWriter.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	if err := vb.client.CopyToContainer(ctx, containerID, dir, archive, types.CopyToContainerOptions{}); err != nil {
+		archive.CloseWithError(err)
+		return nil, fmt.Errorf("failed to write %s: %w", relative, err)
+	}
+
+	return &models.VolumeFile{
+		Name:       path.Base(relative),
+		Path:       relative,
+		Type:       models.VolumeFileRegular,
+		Size:       size,
+		Mode:       "644",
+		ModifiedAt: modTime,
+	}, nil
+}
+
+// resolve checks that a volume belongs to a stack and maps a path in it to
+// the helper container. The path is cleaned as if rooted at the volume, so
+// ".." cannot leave it. It returns the cleaned path and the container path
+func (vb *VolumeBrowser) resolve(ctx context.Context, stackName, volumeName, volumePath string) (string, string, error) {
+	if !vb.config.Enabled {
+		return "", "", ErrVolumeBrowserDisabled
+	}
+	if strings.ContainsRune(volumePath, 0) {
+		return "", "", ErrVolumePathInvalid
+	}
+
+	if _, err := stackVolume(ctx, vb.client, stackName, volumeName); err != nil {
+		return "", "", err
+	}
+
+	relative := path.Clean("/" + volumePath)
+	return relative, path.Join(volumeMountPath, relative), nil
+}
+
+// parseStatLine parses a "%F|%s|%Y|%a|%n" line of stat. The name comes last,
+// so names containing the separator still parse
+func parseStatLine(line, dir string) (models.VolumeFile, bool) {
+	fields := strings.SplitN(line, "|", 5)
+	if len(fields) != 5 {
+		return models.VolumeFile{}, false
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return models.VolumeFile{}, false
+	}
+	modified, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return models.VolumeFile{}, false
+	}
+
+	fileType := models.VolumeFileOther
+	switch fields[0] {
+	case "regular file", "regular empty file":
+		fileType = models.VolumeFileRegular
+	case "directory":
+		fileType = models.VolumeFileDirectory
+	case "symbolic link":
+		fileType = models.VolumeFileSymlink
+	}
+
+	return models.VolumeFile{
+		Name:       fields[4],
+		Path:       path.Join(dir, fields[4]),
+		Type:       fileType,
+		Size:       size,
+		Mode:       fields[3],
+		ModifiedAt: time.Unix(modified, 0),
+	}, true
+}
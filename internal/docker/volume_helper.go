@@ -0,0 +1,150 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// volumeMountPath is where helper containers mount the volume they work on
+const volumeMountPath = "/volume"
+
+// helperRemoveTimeout bounds removing a helper container once its work is done
+const helperRemoveTimeout = 30 * time.Second
+
+// volumeHelper runs short-lived containers that mount volumes, so their data is
+// reached through the Docker API rather than the host filesystem. Helpers have
+// no network and are removed as soon as their work is done
+type volumeHelper struct {
+	client *client.Client
+	image  string
+}
+
+// helperExitError reports a helper command that exited with a non-zero code
+type helperExitError struct {
+	code   int64
+	stderr string
+}
+
+func (e *helperExitError) Error() string {
+	return fmt.Sprintf("volume helper exited with code %d: %s", e.code, e.stderr)
+}
+
+func newVolumeHelper(dockerClient *client.Client, image string) *volumeHelper {
+	return &volumeHelper{client: dockerClient, image: image}
+}
+
+// create creates a helper container with the given mounts, pulling the helper
+// image if it is missing
+func (vh *volumeHelper) create(ctx context.Context, mounts []mount.Mount, cmd ...string) (string, error) {
+	if err := ensureImage(ctx, vh.client, vh.image); err != nil {
+		return "", err
+	}
+
+	resp, err := vh.client.ContainerCreate(ctx, &container.Config{
+		Image:           vh.image,
+		Cmd:             cmd,
+		NetworkDisabled: true,
+		Labels: map[string]string{
+			"app.managed":       "true",
+			"app.volume-helper": "true",
+		},
+	}, &container.HostConfig{Mounts: mounts}, nil, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to create volume helper: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// run starts a helper container and waits for its command, returning its
+// standard output. A non-zero exit is returned as a *helperExitError
+func (vh *volumeHelper) run(ctx context.Context, containerID string) (*bytes.Buffer, error) {
+	if err := vh.client.ContainerStart(ctx, containerID, types.ContainerStartOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to start volume helper: %w", err)
+	}
+	statusCh, errCh := vh.client.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	var exitCode int64
+	select {
+	case status := <-statusCh:
+		exitCode = status.StatusCode
+	case err := <-errCh:
+		return nil, fmt.Errorf("failed to wait for volume helper: %w", err)
+	}
+
+	logs, err := vh.client.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read volume helper output: %w", err)
+	}
+	defer logs.Close()
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, logs); err != nil {
+		return nil, fmt.Errorf("failed to read volume helper output: %w", err)
+	}
+
+	if exitCode != 0 {
+		return nil, &helperExitError{code: exitCode, stderr: strings.TrimSpace(stderr.String())}
+	}
+	return &stdout, nil
+}
+
+// remove removes a helper container, whatever state it is in
+func (vh *volumeHelper) remove(containerID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), helperRemoveTimeout)
+	defer cancel()
+	vh.client.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true})
+}
+
+// ensureImage pulls an image unless it is present
+func ensureImage(ctx context.Context, dockerClient *client.Client, image string) error {
+	if _, _, err := dockerClient.ImageInspectWithRaw(ctx, image); err == nil {
+		return nil
+	} else if !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+
+	reader, err := dockerClient.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", image, err)
+	}
+	defer reader.Close()
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", image, err)
+	}
+	return nil
+}
+
+// volumeMount mounts a named volume in a helper container, read-only unless write
+func volumeMount(volumeName, target string, write bool) mount.Mount {
+	return mount.Mount{
+		Type:     mount.TypeVolume,
+		Source:   volumeName,
+		Target:   target,
+		ReadOnly: !write,
+	}
+}
+
+// stackVolume returns a volume of a stack. Volumes of other stacks are reported
+// as not found, like missing ones
+func stackVolume(ctx context.Context, dockerClient *client.Client, stackName, volumeName string) (*volume.Volume, error) {
+	vol, err := dockerClient.VolumeInspect(ctx, volumeName)
+	if client.IsErrNotFound(err) {
+		return nil, ErrVolumeNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect volume: %w", err)
+	}
+	if vol.Labels["com.docker.compose.project"] != stackName {
+		return nil, ErrVolumeNotFound
+	}
+	return &vol, nil
+}
@@ -0,0 +1,344 @@
+package docker
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+)
+
+// cloneSourcePath is where the helper container of a clone mounts the source
+const cloneSourcePath = "/source"
+
+var (
+	ErrVolumeSnapshotsDisabled = fmt.Errorf("volume snapshots are disabled")
+	ErrVolumeInUse             = fmt.Errorf("volume is used by running containers; stop the stack first")
+	ErrVolumeExists            = fmt.Errorf("a volume with that name already exists")
+)
+
+// VolumeSnapshots archives single stack volumes into managed storage, restores
+// them, and clones volumes into new ones. Data is copied through helper
+// containers, like the volume browser does. A snapshot of a volume in use is
+// taken as-is, so databases should be stopped first for a consistent copy
+type VolumeSnapshots struct {
+	db     *sql.DB
+	client *client.Client
+	config config.VolumeSnapshotsConfig
+	helper *volumeHelper
+}
+
+// NewVolumeSnapshots creates a volume snapshot manager running helper containers
+// from helperImage
+func NewVolumeSnapshots(db *sql.DB, dockerClient *client.Client, cfg config.VolumeSnapshotsConfig, helperImage string) *VolumeSnapshots {
+	return &VolumeSnapshots{
+		db:     db,
+		client: dockerClient,
+		config: cfg,
+		helper: newVolumeHelper(dockerClient, helperImage),
+	}
+}
+
+// Enabled reports whether volumes may be snapshotted and cloned
+func (vs *VolumeSnapshots) Enabled() bool {
+	return vs.config.Enabled
+}
+
+// Check returns an error if a volume cannot be snapshotted or cloned: the
+// feature is disabled or the volume does not belong to the stack
+func (vs *VolumeSnapshots) Check(ctx context.Context, stackName, volumeName string) error {
+	if !vs.config.Enabled {
+		return ErrVolumeSnapshotsDisabled
+	}
+	_, err := stackVolume(ctx, vs.client, stackName, volumeName)
+	return err
+}
+
+// CheckRestore returns an error if a volume cannot be restored now: besides the
+// checks of Check, containers using it must be stopped, so none sees it half
+// restored
+func (vs *VolumeSnapshots) CheckRestore(ctx context.Context, stackName, volumeName string) error {
+	if err := vs.Check(ctx, stackName, volumeName); err != nil {
+		return err
+	}
+	containers, err := vs.client.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("volume", volumeName)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+	if len(containers) > 0 {
+		return ErrVolumeInUse
+	}
+	return nil
+}
+
+// CheckClone returns an error if a volume cannot be cloned to target: besides
+// the checks of Check, no volume may be named target yet
+func (vs *VolumeSnapshots) CheckClone(ctx context.Context, stackName, volumeName, target string) error {
+	if err := vs.Check(ctx, stackName, volumeName); err != nil {
+		return err
+	}
+	if _, err := vs.client.VolumeInspect(ctx, target); err == nil {
+		return ErrVolumeExists
+	} else if !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to inspect volume: %w", err)
+	}
+	return nil
+}
+
+// Create archives a volume of a stack as a gzipped tar, then removes the oldest
+// snapshots of the volume beyond the configured limit
+func (vs *VolumeSnapshots) Create(ctx context.Context, deploymentID, stackName, volumeName, note, createdBy string) (*models.VolumeSnapshot, error) {
+	if err := vs.Check(ctx, stackName, volumeName); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	snapshot := &models.VolumeSnapshot{
+		ID:           fmt.Sprintf("snap_%d", now.UnixNano()),
+		DeploymentID: deploymentID,
+		VolumeName:   volumeName,
+		Note:         note,
+		CreatedBy:    createdBy,
+		CreatedAt:    now,
+	}
+	snapshot.StoragePath = filepath.Join(vs.config.StoragePath, deploymentID, snapshot.ID+".tar.gz")
+
+	size, err := vs.archive(ctx, volumeName, snapshot.StoragePath)
+	if err != nil {
+		os.Remove(snapshot.StoragePath)
+		return nil, err
+	}
+	snapshot.SizeBytes = size
+
+	_, err = vs.db.Exec(`
+		INSERT INTO volume_snapshots (id, deployment_id, volume_name, note, size_bytes, storage_path, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		snapshot.ID, snapshot.DeploymentID, snapshot.VolumeName, snapshot.Note, snapshot.SizeBytes,
+		snapshot.StoragePath, snapshot.CreatedBy, snapshot.CreatedAt)
+	if err != nil {
+		os.Remove(snapshot.StoragePath)
+		return nil, fmt.Errorf("failed to record snapshot: %w", err)
+	}
+
+	if err := vs.prune(deploymentID, volumeName); err != nil {
+		slog.Warn("Failed to prune volume snapshots", "deployment_id", deploymentID, "volume", volumeName, "error", err)
+	}
+	return snapshot, nil
+}
+
+// archive copies the data of a volume out of a helper container that is created
+// but never started, and writes it gzipped to archivePath
+func (vs *VolumeSnapshots) archive(ctx context.Context, volumeName, archivePath string) (int64, error) {
+	containerID, err := vs.helper.create(ctx, []mount.Mount{volumeMount(volumeName, volumeMountPath, false)}, "true")
+	if err != nil {
+		return 0, err
+	}
+	defer vs.helper.remove(containerID)
+
+	// Entries are named volume/..., so a restore extracts them at the container root
+	reader, _, err := vs.client.CopyFromContainer(ctx, containerID, volumeMountPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy volume %s: %w", volumeName, err)
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	if _, err := io.Copy(gzipWriter, reader); err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// List returns the snapshots of a volume of a deployment, newest first
+func (vs *VolumeSnapshots) List(deploymentID, volumeName string) ([]models.VolumeSnapshot, error) {
+	rows, err := vs.db.Query(`
+		SELECT id, deployment_id, volume_name, note, size_bytes, storage_path, created_by, created_at
+		FROM volume_snapshots
+		WHERE deployment_id = $1 AND volume_name = $2
+		ORDER BY created_at DESC`, deploymentID, volumeName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []models.VolumeSnapshot{}
+	for rows.Next() {
+		var snapshot models.VolumeSnapshot
+		if err := rows.Scan(&snapshot.ID, &snapshot.DeploymentID, &snapshot.VolumeName, &snapshot.Note,
+			&snapshot.SizeBytes, &snapshot.StoragePath, &snapshot.CreatedBy, &snapshot.CreatedAt); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// Get returns a snapshot of a volume of a deployment
+func (vs *VolumeSnapshots) Get(deploymentID, volumeName, id string) (*models.VolumeSnapshot, error) {
+	var snapshot models.VolumeSnapshot
+	err := vs.db.QueryRow(`
+		SELECT id, deployment_id, volume_name, note, size_bytes, storage_path, created_by, created_at
+		FROM volume_snapshots
+		WHERE id = $1 AND deployment_id = $2 AND volume_name = $3`, id, deploymentID, volumeName).Scan(
+		&snapshot.ID, &snapshot.DeploymentID, &snapshot.VolumeName, &snapshot.Note,
+		&snapshot.SizeBytes, &snapshot.StoragePath, &snapshot.CreatedBy, &snapshot.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrVolumeSnapshotNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// Restore replaces the content of a volume with a snapshot of it
+func (vs *VolumeSnapshots) Restore(ctx context.Context, stackName string, snapshot *models.VolumeSnapshot) error {
+	if err := vs.CheckRestore(ctx, stackName, snapshot.VolumeName); err != nil {
+		return err
+	}
+
+	file, err := os.Open(snapshot.StoragePath)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	defer gzipReader.Close()
+
+	containerID, err := vs.helper.create(ctx, []mount.Mount{volumeMount(snapshot.VolumeName, volumeMountPath, true)},
+		"find", volumeMountPath, "-mindepth", "1", "-delete")
+	if err != nil {
+		return err
+	}
+	defer vs.helper.remove(containerID)
+
+	if _, err := vs.helper.run(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to clear volume %s: %w", snapshot.VolumeName, err)
+	}
+	if err := vs.client.CopyToContainer(ctx, containerID, "/", gzipReader, types.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to restore volume %s: %w", snapshot.VolumeName, err)
+	}
+	return nil
+}
+
+// Delete removes a snapshot and its archive
+func (vs *VolumeSnapshots) Delete(snapshot *models.VolumeSnapshot) error {
+	if err := os.Remove(snapshot.StoragePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove snapshot archive: %w", err)
+	}
+	_, err := vs.db.Exec("DELETE FROM volume_snapshots WHERE id = $1", snapshot.ID)
+	return err
+}
+
+// Clone copies a volume of a stack into a new volume, labelled as part of the
+// same stack so it can be browsed and snapshotted too. The new volume is removed
+// if the copy fails
+func (vs *VolumeSnapshots) Clone(ctx context.Context, stackName, volumeName, target string) (*models.StackVolume, error) {
+	if err := vs.CheckClone(ctx, stackName, volumeName, target); err != nil {
+		return nil, err
+	}
+	source, err := stackVolume(ctx, vs.client, stackName, volumeName)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := vs.client.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   target,
+		Driver: source.Driver,
+		Labels: map[string]string{
+			"com.docker.compose.project": stackName,
+			"app.managed":                "true",
+			"app.cloned-from":            volumeName,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create volume %s: %w", target, err)
+	}
+
+	err = vs.copyVolume(ctx, volumeName, target)
+	if err != nil {
+		if removeErr := vs.client.VolumeRemove(context.Background(), target, true); removeErr != nil {
+			slog.Warn("Failed to remove volume of a failed clone", "volume", target, "error", removeErr)
+		}
+		return nil, err
+	}
+
+	return &models.StackVolume{
+		Name:       created.Name,
+		Driver:     created.Driver,
+		MountPoint: created.Mountpoint,
+		Labels:     created.Labels,
+	}, nil
+}
+
+// copyVolume copies the data of one volume into another, keeping ownership,
+// permissions and timestamps
+func (vs *VolumeSnapshots) copyVolume(ctx context.Context, from, to string) error {
+	containerID, err := vs.helper.create(ctx, []mount.Mount{
+		volumeMount(from, cloneSourcePath, false),
+		volumeMount(to, volumeMountPath, true),
+	}, "cp", "-a", cloneSourcePath+"/.", volumeMountPath+"/")
+	if err != nil {
+		return err
+	}
+	defer vs.helper.remove(containerID)
+
+	if _, err := vs.helper.run(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to copy volume %s to %s: %w", from, to, err)
+	}
+	return nil
+}
+
+// prune removes the oldest snapshots of a volume beyond the configured limit
+func (vs *VolumeSnapshots) prune(deploymentID, volumeName string) error {
+	if vs.config.MaxPerVolume <= 0 {
+		return nil
+	}
+	snapshots, err := vs.List(deploymentID, volumeName)
+	if err != nil {
+		return err
+	}
+	for i := vs.config.MaxPerVolume; i < len(snapshots); i++ {
+		if err := vs.Delete(&snapshots[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
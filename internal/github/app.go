@@ -0,0 +1,131 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiry an installation token is renewed
+const tokenRefreshMargin = 5 * time.Minute
+
+// AppAuth issues GitHub App installation tokens, refreshing them before they expire
+type AppAuth struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	baseURL        string
+	httpClient     *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewAppAuth creates GitHub App authentication from a PEM encoded private key
+func NewAppAuth(appID, installationID int64, privateKeyPEM []byte) (*AppAuth, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("GitHub App private key is not PEM encoded")
+	}
+
+	// GitHub issues PKCS#1 keys; accept PKCS#8 for keys converted by other tools
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err8 := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err8 != nil {
+			return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+		}
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("GitHub App private key is not an RSA key")
+		}
+		key = rsaKey
+	}
+
+	return &AppAuth{
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		baseURL:        "https://api.github.com",
+		httpClient:     &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Token returns a valid installation token, requesting a new one when close to expiry
+func (a *AppAuth) Token() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Until(a.expiresAt) > tokenRefreshMargin {
+		return a.token, nil
+	}
+
+	jwt, err := a.appJWT()
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", a.baseURL, a.installationID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("User-Agent", "docker-deploy-app/1.0")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request installation token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("GitHub App token error: %d %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode installation token: %w", err)
+	}
+
+	a.token = body.Token
+	a.expiresAt = body.ExpiresAt
+	return a.token, nil
+}
+
+// appJWT signs the short-lived RS256 JWT that authenticates the App itself
+func (a *AppAuth) appJWT() (string, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-60 * time.Second).Unix(), // Allow for clock drift
+		"exp": now.Add(9 * time.Minute).Unix(),   // GitHub rejects more than 10 minutes
+		"iss": strconv.FormatInt(a.appID, 10),
+	})
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign GitHub App JWT: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,78 @@
+package github
+
+import (
+	"net/http"
+	"sync"
+)
+
+// maxCachedResponses bounds the response cache; it is reset when full
+const maxCachedResponses = 2000
+
+// cachedResponse is a GET response body with its validators
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+// responseCache stores GET responses so repeat requests can be made conditional.
+// GitHub does not count 304 Not Modified responses against the rate limit
+type responseCache struct {
+	mu      sync.RWMutex
+	entries map[string]*cachedResponse
+}
+
+// newResponseCache creates an empty response cache
+func newResponseCache() *responseCache {
+	return &responseCache{entries: make(map[string]*cachedResponse)}
+}
+
+// prepare adds conditional headers for a previously cached URL
+func (rc *responseCache) prepare(req *http.Request) {
+	rc.mu.RLock()
+	entry, ok := rc.entries[req.URL.String()]
+	rc.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	if entry.etag != "" {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+	if entry.lastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.lastModified)
+	}
+}
+
+// get returns the cached body for a URL
+func (rc *responseCache) get(url string) ([]byte, bool) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	entry, ok := rc.entries[url]
+	if !ok {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+// store caches a successful response body if it carries validators
+func (rc *responseCache) store(url string, resp *http.Response, body []byte) {
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if len(rc.entries) >= maxCachedResponses {
+		rc.entries = make(map[string]*cachedResponse)
+	}
+	rc.entries[url] = &cachedResponse{
+		etag:         etag,
+		lastModified: lastModified,
+		body:         body,
+	}
+}
@@ -0,0 +1,152 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// maxChangelogVersions bounds the versions kept per template, newest first
+	maxChangelogVersions = 50
+	// maxReleaseNotes bounds the release notes kept per version
+	maxReleaseNotes = 64 * 1024
+)
+
+// Release represents a GitHub release
+type Release struct {
+	TagName     string     `json:"tag_name"`
+	Name        string     `json:"name"`
+	Body        string     `json:"body"`
+	HTMLURL     string     `json:"html_url"`
+	Draft       bool       `json:"draft"`
+	Prerelease  bool       `json:"prerelease"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+// Tag represents a tag of a GitHub repository
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// ListReleases lists the releases of a repository, newest first
+func (c *Client) ListReleases(owner, repo string, perPage int) ([]*Release, error) {
+	url := fmt.Sprintf("/repos/%s/%s/releases?per_page=%d", owner, repo, perPage)
+
+	var releases []*Release
+	err := c.makeRequest("GET", url, nil, &releases)
+	if err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+// ListTags lists the tags of a repository, newest first
+func (c *Client) ListTags(owner, repo string, perPage int) ([]*Tag, error) {
+	url := fmt.Sprintf("/repos/%s/%s/tags?per_page=%d", owner, repo, perPage)
+
+	var tags []*Tag
+	err := c.makeRequest("GET", url, nil, &tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// changelogVersion is a version of a template's changelog
+type changelogVersion struct {
+	tagName     string
+	name        string
+	notes       string
+	url         string
+	prerelease  bool
+	publishedAt *time.Time
+}
+
+// NormalizeVersion returns a version as recorded in the changelog: a tag name
+// without its leading v
+func NormalizeVersion(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if len(tag) > 1 && (tag[0] == 'v' || tag[0] == 'V') && tag[1] >= '0' && tag[1] <= '9' {
+		return tag[1:]
+	}
+	return tag
+}
+
+// syncChangelog records the released versions of a template with their release
+// notes, replacing the previous changelog. Drafts are left out. Repositories that
+// publish no releases fall back to their tags, which carry no notes
+func (rs *RepositoryService) syncChangelog(owner, repoName, htmlURL, templateID string) error {
+	releases, err := rs.client.ListReleases(owner, repoName, maxChangelogVersions)
+	if err != nil {
+		return err
+	}
+
+	var versions []changelogVersion
+	for _, release := range releases {
+		if release.Draft || release.TagName == "" {
+			continue
+		}
+		notes := release.Body
+		if len(notes) > maxReleaseNotes {
+			notes = notes[:maxReleaseNotes]
+		}
+		versions = append(versions, changelogVersion{
+			tagName:     release.TagName,
+			name:        release.Name,
+			notes:       notes,
+			url:         release.HTMLURL,
+			prerelease:  release.Prerelease,
+			publishedAt: release.PublishedAt,
+		})
+	}
+
+	if len(releases) == 0 {
+		tags, err := rs.client.ListTags(owner, repoName, maxChangelogVersions)
+		if err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			versions = append(versions, changelogVersion{
+				tagName: tag.Name,
+				url:     fmt.Sprintf("%s/tree/%s", htmlURL, tag.Name),
+			})
+		}
+	}
+
+	return rs.saveChangelog(templateID, versions)
+}
+
+// saveChangelog replaces the recorded changelog of a template. Tags that
+// normalize to the same version keep the newest
+func (rs *RepositoryService) saveChangelog(templateID string, versions []changelogVersion) error {
+	tx, err := rs.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM template_versions WHERE template_id = $1", templateID); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(versions))
+	now := time.Now()
+	for _, v := range versions {
+		version := NormalizeVersion(v.tagName)
+		if seen[version] {
+			continue
+		}
+		seen[version] = true
+
+		if _, err := tx.Exec(`
+			INSERT INTO template_versions (template_id, version, tag_name, name, notes, url, prerelease, published_at, position, synced_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			templateID, version, v.tagName, v.name, v.notes, v.url, v.prerelease, v.publishedAt, len(seen)-1, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -2,18 +2,50 @@ package github
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
 )
 
 // Client handles GitHub API interactions
 type Client struct {
 	token      string
+	app        *AppAuth // Takes precedence over token when set
 	baseURL    string
 	httpClient *http.Client
+	cache      *responseCache
+
+	mu        sync.RWMutex
+	rateLimit RateLimit
+	scopes    []string
+}
+
+// RateLimit is the request quota GitHub reported on the latest response
+type RateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Used      int       `json:"used"`
+	Reset     time.Time `json:"reset"`
+	Known     bool      `json:"known"`
+}
+
+// RateLimitError is returned when GitHub rejects a request for exceeding a rate limit
+type RateLimitError struct {
+	Reset   time.Time
+	Message string
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("GitHub rate limit exceeded until %s: %s", e.Reset.Format(time.RFC3339), e.Message)
 }
 
 // Repository represents a GitHub repository
@@ -33,6 +65,7 @@ type Repository struct {
 	Size        int    `json:"size"`
 	StarCount   int    `json:"stargazers_count"`
 	Topics      []string `json:"topics"`
+	License     *RepositoryLicense `json:"license"`
 }
 
 // FileContent represents a file from GitHub API
@@ -68,7 +101,43 @@ func NewClient(token string) *Client {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		cache: newResponseCache(),
+	}
+}
+
+// NewAppClient creates a GitHub client authenticated as a GitHub App installation
+func NewAppClient(app *AppAuth) *Client {
+	client := NewClient("")
+	client.app = app
+	return client
+}
+
+// NewClientFromConfig creates a GitHub App client when App credentials are configured,
+// falling back to the personal access token otherwise
+func NewClientFromConfig(cfg config.GitHubConfig) (*Client, error) {
+	if cfg.AppID == 0 || cfg.InstallationID == 0 {
+		return NewClient(cfg.Token), nil
+	}
+
+	privateKey := []byte(cfg.AppPrivateKey)
+	if len(privateKey) == 0 {
+		data, err := os.ReadFile(cfg.AppPrivateKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+		}
+		privateKey = data
+	}
+
+	app, err := NewAppAuth(cfg.AppID, cfg.InstallationID, privateKey)
+	if err != nil {
+		return nil, err
 	}
+	return NewAppClient(app), nil
+}
+
+// IsApp returns true if the client authenticates as a GitHub App installation
+func (c *Client) IsApp() bool {
+	return c.app != nil
 }
 
 // GetUser gets the authenticated user information
@@ -81,8 +150,21 @@ func (c *Client) GetUser() (*User, error) {
 	return &user, nil
 }
 
-// ListRepositories lists repositories for the authenticated user
+// ListRepositories lists repositories for the authenticated user, or those the
+// App installation was granted
 func (c *Client) ListRepositories(page, perPage int) ([]*Repository, error) {
+	if c.IsApp() {
+		url := fmt.Sprintf("/installation/repositories?page=%d&per_page=%d", page, perPage)
+
+		var response struct {
+			Repositories []*Repository `json:"repositories"`
+		}
+		if err := c.makeRequest("GET", url, nil, &response); err != nil {
+			return nil, err
+		}
+		return response.Repositories, nil
+	}
+
 	url := fmt.Sprintf("/user/repos?page=%d&per_page=%d&sort=updated", page, perPage)
 	
 	var repos []*Repository
@@ -94,6 +176,28 @@ func (c *Client) ListRepositories(page, perPage int) ([]*Repository, error) {
 	return repos, nil
 }
 
+// ListOrgRepositories lists repositories of an organization
+func (c *Client) ListOrgRepositories(org string, page, perPage int) ([]*Repository, error) {
+	url := fmt.Sprintf("/orgs/%s/repos?page=%d&per_page=%d&type=all", org, page, perPage)
+	
+	var repos []*Repository
+	if err := c.makeRequest("GET", url, nil, &repos); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// ListUserRepositories lists public repositories of a user
+func (c *Client) ListUserRepositories(user string, page, perPage int) ([]*Repository, error) {
+	url := fmt.Sprintf("/users/%s/repos?page=%d&per_page=%d", user, page, perPage)
+	
+	var repos []*Repository
+	if err := c.makeRequest("GET", url, nil, &repos); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
 // GetRepository gets a specific repository
 func (c *Client) GetRepository(owner, repo string) (*Repository, error) {
 	url := fmt.Sprintf("/repos/%s/%s", owner, repo)
@@ -193,7 +297,9 @@ func (c *Client) CheckFileExists(owner, repo, path, ref string) (bool, error) {
 		return false, err
 	}
 	
-	req.Header.Set("Authorization", "token "+c.token)
+	if err := c.authorize(req); err != nil {
+		return false, err
+	}
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 	
 	resp, err := c.httpClient.Do(req)
@@ -205,12 +311,32 @@ func (c *Client) CheckFileExists(owner, repo, path, ref string) (bool, error) {
 	return resp.StatusCode == 200, nil
 }
 
-// ValidateToken validates the GitHub token
+// ValidateToken validates the GitHub token or App credentials
 func (c *Client) ValidateToken() error {
+	if c.IsApp() {
+		// Installation tokens cannot read /user
+		_, err := c.app.Token()
+		return err
+	}
 	_, err := c.GetUser()
 	return err
 }
 
+// Scopes returns the OAuth scopes GitHub reported for the token on the most recent
+// response. Fine-grained tokens and GitHub Apps report none
+func (c *Client) Scopes() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.scopes
+}
+
+// RateLimit returns the quota reported on the most recent response
+func (c *Client) RateLimit() RateLimit {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.rateLimit
+}
+
 // GetRateLimit gets current rate limit status
 func (c *Client) GetRateLimit() (map[string]interface{}, error) {
 	var rateLimit map[string]interface{}
@@ -225,7 +351,9 @@ func (c *Client) makeRequest(method, endpoint string, body io.Reader, target int
 		return err
 	}
 	
-	req.Header.Set("Authorization", "token "+c.token)
+	if err := c.authorize(req); err != nil {
+		return err
+	}
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 	req.Header.Set("User-Agent", "docker-deploy-app/1.0")
 	
@@ -233,21 +361,124 @@ func (c *Client) makeRequest(method, endpoint string, body io.Reader, target int
 		req.Header.Set("Content-Type", "application/json")
 	}
 	
-	resp, err := c.httpClient.Do(req)
+	data, err := c.do(req)
 	if err != nil {
 		return err
 	}
+	
+	if target != nil && len(data) > 0 {
+		return json.Unmarshal(data, target)
+	}
+	
+	return nil
+}
+
+// do sends a request and returns the response body. GET requests are made
+// conditional on cached validators and served from the cache on 304
+func (c *Client) do(req *http.Request) ([]byte, error) {
+	cacheable := req.Method == "GET"
+	if cacheable {
+		c.cache.prepare(req)
+	}
+	
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
+	c.recordRateLimit(resp)
+	
+	if err := c.rateLimitError(resp); err != nil {
+		return nil, err
+	}
+	
+	if resp.StatusCode == http.StatusNotModified && cacheable {
+		if data, ok := c.cache.get(req.URL.String()); ok {
+			return data, nil
+		}
+	}
 	
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("GitHub API error: %d %s", resp.StatusCode, string(bodyBytes))
+		return nil, fmt.Errorf("GitHub API error: %d %s", resp.StatusCode, string(bodyBytes))
 	}
 	
-	if target != nil {
-		return json.NewDecoder(resp.Body).Decode(target)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if cacheable {
+		c.cache.store(req.URL.String(), resp, data)
+	}
+	return data, nil
+}
+
+// recordRateLimit stores the X-RateLimit and X-OAuth-Scopes headers of a response
+func (c *Client) recordRateLimit(resp *http.Response) {
+	if header, ok := resp.Header["X-Oauth-Scopes"]; ok {
+		scopes := []string{}
+		for _, scope := range strings.Split(strings.Join(header, ","), ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				scopes = append(scopes, scope)
+			}
+		}
+		c.mu.Lock()
+		c.scopes = scopes
+		c.mu.Unlock()
+	}
+
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return // Raw downloads do not report a quota
+	}
+	limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	used, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Used"))
+	reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rateLimit = RateLimit{
+		Limit:     limit,
+		Remaining: remaining,
+		Used:      used,
+		Reset:     time.Unix(reset, 0),
+		Known:     true,
+	}
+}
+
+// rateLimitError detects primary (quota exhausted) and secondary (Retry-After)
+// rate limit rejections
+func (c *Client) rateLimitError(resp *http.Response) error {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	var reset time.Time
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		reset = time.Now().Add(time.Duration(seconds) * time.Second)
+	} else if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset = c.RateLimit().Reset
+	} else {
+		return nil // Permission error
+	}
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	return &RateLimitError{Reset: reset, Message: strings.TrimSpace(string(bodyBytes))}
+}
+
+// authorize sets the Authorization header from the installation token or the PAT
+func (c *Client) authorize(req *http.Request) error {
+	if c.app != nil {
+		token, err := c.app.Token()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "token "+token)
+		return nil
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "token "+c.token)
 	}
-	
 	return nil
 }
 
@@ -258,19 +489,16 @@ func (c *Client) downloadFile(url string) ([]byte, error) {
 		return nil, err
 	}
 	
-	req.Header.Set("Authorization", "token "+c.token)
-	
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
+	if err := c.authorize(req); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 	
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to download file: %d", resp.StatusCode)
+	data, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
 	}
 	
-	return io.ReadAll(resp.Body)
+	return data, nil
 }
 
 // ParseRepoURL parses GitHub repository URL
@@ -304,15 +532,12 @@ func ParseRepoURL(repoURL string) (owner, repo string, err error) {
 
 // IsDockerComposeRepo checks if repository contains docker-compose files
 func (c *Client) IsDockerComposeRepo(owner, repo string) (bool, error) {
-	// Check for common docker-compose file names
-	composeFiles := []string{
-		"docker-compose.yml",
-		"docker-compose.yaml",
-		"compose.yml",
-		"compose.yaml",
+	// A single tree listing replaces a request per file name
+	if tree, err := c.GetTree(owner, repo, ""); err == nil && !tree.Truncated {
+		return tree.ComposeFile() != "", nil
 	}
 	
-	for _, file := range composeFiles {
+	for _, file := range composeFileNames {
 		exists, err := c.CheckFileExists(owner, repo, file, "")
 		if err != nil {
 			continue
@@ -327,19 +552,51 @@ func (c *Client) IsDockerComposeRepo(owner, repo string) (bool, error) {
 
 // GetTemplateConfig gets template configuration file
 func (c *Client) GetTemplateConfig(owner, repo, ref string) (map[string]interface{}, error) {
-	configFiles := []string{
-		".template.json",
-		"template.json",
-		".docker-deploy.json",
+	tree, err := c.GetTree(owner, repo, ref)
+	if err != nil {
+		return nil, err
 	}
-	
-	for _, configFile := range configFiles {
-		exists, err := c.CheckFileExists(owner, repo, configFile, ref)
-		if err != nil || !exists {
+	return c.GetTemplateConfigFromTree(owner, repo, ref, tree)
+}
+
+// GetTemplateConfigFromTree gets the template configuration file listed in a repository tree
+func (c *Client) GetTemplateConfigFromTree(owner, repo, ref string, tree *Tree) (map[string]interface{}, error) {
+	manifest, err := c.GetTemplateManifest(owner, repo, ref, tree)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Config, nil
+}
+
+// TemplateManifest is a template configuration file with the detached
+// signatures published next to it
+type TemplateManifest struct {
+	Path       string
+	Content    []byte
+	Config     map[string]interface{}
+	Signatures map[models.PublisherKeyType][]byte
+}
+
+// manifestSignatureSuffixes are the signature files looked for next to a
+// template configuration file, by key type
+var manifestSignatureSuffixes = map[models.PublisherKeyType]string{
+	models.PublisherKeyCosign:   ".sig",
+	models.PublisherKeyMinisign: ".minisig",
+}
+
+// GetTemplateManifest gets the template configuration file listed in a repository
+// tree and its signatures
+func (c *Client) GetTemplateManifest(owner, repo, ref string, tree *Tree) (*TemplateManifest, error) {
+	for _, configFile := range templateConfigFileNames {
+		if !tree.Has(configFile) {
 			continue
 		}
 		
 		content, err := c.GetRawFileContent(owner, repo, configFile, ref)
+		var rateLimitErr *RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			return nil, err
+		}
 		if err != nil {
 			continue
 		}
@@ -348,8 +605,27 @@ func (c *Client) GetTemplateConfig(owner, repo, ref string) (map[string]interfac
 		if err := json.Unmarshal(content, &config); err != nil {
 			continue
 		}
+
+		manifest := &TemplateManifest{
+			Path:       configFile,
+			Content:    content,
+			Config:     config,
+			Signatures: make(map[models.PublisherKeyType][]byte),
+		}
+		for keyType, suffix := range manifestSignatureSuffixes {
+			if !tree.Has(configFile + suffix) {
+				continue
+			}
+			signature, err := c.GetRawFileContent(owner, repo, configFile+suffix, ref)
+			if errors.As(err, &rateLimitErr) {
+				return nil, err
+			}
+			if err == nil {
+				manifest.Signatures[keyType] = signature
+			}
+		}
 		
-		return config, nil
+		return manifest, nil
 	}
 	
 	return nil, fmt.Errorf("no template configuration found")
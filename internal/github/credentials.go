@@ -0,0 +1,181 @@
+package github
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"docker-deploy-app/internal/config"
+)
+
+// encryptedPrefix marks tokens stored encrypted
+const encryptedPrefix = "enc:"
+
+// Credentials describes the GitHub account connected through the API
+type Credentials struct {
+	Login       string    `json:"login"`
+	Scopes      []string  `json:"scopes"`
+	ConnectedAt time.Time `json:"connected_at"`
+}
+
+// CredentialStore keeps the connected GitHub token, encrypted with the server secret key
+type CredentialStore struct {
+	db      *sql.DB
+	encrypt bool
+	key     []byte
+}
+
+// NewCredentialStore creates a new credential store
+func NewCredentialStore(db *sql.DB, security config.SecurityConfig) *CredentialStore {
+	store := &CredentialStore{
+		db:      db,
+		encrypt: security.EncryptSecrets,
+	}
+	if security.SecretKey != "" {
+		key := sha256.Sum256([]byte(security.SecretKey))
+		store.key = key[:]
+	}
+	return store
+}
+
+// Save stores a token with the account it belongs to, replacing any previous one
+func (cs *CredentialStore) Save(token string, user *User, scopes []string) error {
+	stored, err := cs.seal(token)
+	if err != nil {
+		return err
+	}
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+	scopesJSON, _ := json.Marshal(scopes)
+
+	_, err = cs.db.Exec(`
+		INSERT INTO github_credentials (id, token, login, scopes, connected_at)
+		VALUES (1, $1, $2, $3, $4)
+		ON CONFLICT(id) DO UPDATE SET
+			token = excluded.token,
+			login = excluded.login,
+			scopes = excluded.scopes,
+			connected_at = excluded.connected_at`,
+		stored, user.Login, string(scopesJSON), time.Now())
+	return err
+}
+
+// Load returns the stored token and account, or an empty token when none is connected
+func (cs *CredentialStore) Load() (string, *Credentials, error) {
+	var stored, scopesJSON string
+	var creds Credentials
+
+	err := cs.db.QueryRow(`
+		SELECT token, login, scopes, connected_at
+		FROM github_credentials WHERE id = 1`).Scan(&stored, &creds.Login, &scopesJSON, &creds.ConnectedAt)
+	if err == sql.ErrNoRows {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := json.Unmarshal([]byte(scopesJSON), &creds.Scopes); err != nil {
+		creds.Scopes = []string{}
+	}
+
+	token, err := cs.open(stored)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &creds, nil
+}
+
+// Delete removes the stored token
+func (cs *CredentialStore) Delete() error {
+	_, err := cs.db.Exec("DELETE FROM github_credentials")
+	return err
+}
+
+// seal encrypts a token with AES-GCM when secret encryption is enabled
+func (cs *CredentialStore) seal(token string) (string, error) {
+	if !cs.encrypt {
+		return token, nil
+	}
+	if cs.key == nil {
+		return "", fmt.Errorf("SECRET_KEY must be set to store GitHub tokens")
+	}
+
+	gcm, err := cs.aead()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(token), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a stored token; tokens stored without encryption are returned as is
+func (cs *CredentialStore) open(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	}
+	if cs.key == nil {
+		return "", fmt.Errorf("SECRET_KEY is required to decrypt the stored GitHub token")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode stored GitHub token: %w", err)
+	}
+
+	gcm, err := cs.aead()
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("stored GitHub token is corrupt")
+	}
+
+	token, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt stored GitHub token: %w", err)
+	}
+	return string(token), nil
+}
+
+// aead creates the AES-GCM cipher from the secret key
+func (cs *CredentialStore) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(cs.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ResolveClient creates a GitHub client from, in order of preference, GitHub App
+// credentials, a token connected through the API, and the GITHUB_TOKEN setting
+func ResolveClient(db *sql.DB, cfg *config.Config) (*Client, error) {
+	if cfg.GitHub.AppID != 0 && cfg.GitHub.InstallationID != 0 {
+		return NewClientFromConfig(cfg.GitHub)
+	}
+
+	token, _, err := NewCredentialStore(db, cfg.Security).Load()
+	if err != nil {
+		return NewClient(cfg.GitHub.Token), err
+	}
+	if token != "" {
+		return NewClient(token), nil
+	}
+	return NewClient(cfg.GitHub.Token), nil
+}
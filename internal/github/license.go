@@ -0,0 +1,185 @@
+package github
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"docker-deploy-app/internal/models"
+)
+
+// RepositoryLicense is the license GitHub detected for a repository
+type RepositoryLicense struct {
+	Key    string `json:"key"`
+	Name   string `json:"name"`
+	SPDXID string `json:"spdx_id"` // NOASSERTION when GitHub could not tell
+}
+
+// licenseFileNames are the license files looked for at the repository root, in
+// order of preference
+var licenseFileNames = []string{
+	"LICENSE",
+	"LICENSE.md",
+	"LICENSE.txt",
+	"LICENCE",
+	"LICENCE.md",
+	"COPYING",
+}
+
+// licensePhrases identify common licenses from their text, checked in order so
+// that the more specific phrases come first
+var licensePhrases = []struct {
+	spdxID string
+	phrase string
+}{
+	{"AGPL-3.0", "GNU AFFERO GENERAL PUBLIC LICENSE"},
+	{"LGPL-3.0", "GNU LESSER GENERAL PUBLIC LICENSE Version 3"},
+	{"LGPL-2.1", "GNU LESSER GENERAL PUBLIC LICENSE Version 2.1"},
+	{"GPL-3.0", "GNU GENERAL PUBLIC LICENSE Version 3"},
+	{"GPL-2.0", "GNU GENERAL PUBLIC LICENSE Version 2"},
+	{"Apache-2.0", "Apache License Version 2.0"},
+	{"Apache-2.0", "Licensed under the Apache License, Version 2.0"},
+	{"MPL-2.0", "Mozilla Public License Version 2.0"},
+	{"MPL-2.0", "Mozilla Public License, version 2.0"},
+	{"BSD-3-Clause", "Neither the name of"},
+	{"BSD-2-Clause", "Redistribution and use in source and binary forms"},
+	{"ISC", "Permission to use, copy, modify, and/or distribute this software for any"},
+	{"Unlicense", "This is free and unencumbered software released into the public domain"},
+	{"MIT", "Permission is hereby granted, free of charge, to any person obtaining a copy"},
+}
+
+// detectLicense returns the license of a template repository: the SPDX identifier
+// GitHub reports for it or, when GitHub could not tell, one recognized from the
+// license file at its root. It is empty when neither is known
+func (rs *RepositoryService) detectLicense(owner, repoName, ref string, tree *Tree, repo *Repository) (string, error) {
+	if repo.License != nil && repo.License.SPDXID != "" && repo.License.SPDXID != "NOASSERTION" {
+		return repo.License.SPDXID, nil
+	}
+
+	for _, name := range licenseFileNames {
+		if !tree.Has(name) {
+			continue
+		}
+		content, err := rs.client.GetRawFileContent(owner, repoName, name, ref)
+		if err != nil {
+			return "", err
+		}
+		if spdxID := licenseFromText(content); spdxID != "" {
+			return spdxID, nil
+		}
+		return "NOASSERTION", nil
+	}
+	return "", nil
+}
+
+// licenseFromText recognizes a common license from the text of a license file
+func licenseFromText(content []byte) string {
+	// Wrapped lines and indentation vary; compare with single spaces
+	text := strings.Join(strings.Fields(string(content)), " ")
+	for _, l := range licensePhrases {
+		if strings.Contains(text, l.phrase) {
+			return l.spdxID
+		}
+	}
+	return ""
+}
+
+// parseResources reads the resources section of a template configuration:
+// min_cpu, min_memory and min_disk, given as strings or numbers, and gpu.
+// Requirements that do not parse are dropped
+func parseResources(config map[string]interface{}) *models.TemplateResources {
+	section, ok := config["resources"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	value := func(key string) string {
+		switch v := section[key].(type) {
+		case string:
+			return strings.TrimSpace(v)
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	}
+
+	resources := &models.TemplateResources{}
+	if cpu := value("min_cpu"); cpu != "" {
+		if _, err := (models.TemplateResources{MinCPU: cpu}).CPUs(); err == nil {
+			resources.MinCPU = cpu
+		}
+	}
+	for key, field := range map[string]*string{"min_memory": &resources.MinMemory, "min_disk": &resources.MinDisk} {
+		size := value(key)
+		if size == "" {
+			continue
+		}
+		// A bare number is taken as megabytes
+		if _, err := strconv.ParseFloat(size, 64); err == nil {
+			size += "M"
+		}
+		if _, err := models.ParseByteSize(size); err == nil {
+			*field = size
+		}
+	}
+	resources.GPU = parseGPU(section["gpu"])
+
+	if resources.IsZero() {
+		return nil
+	}
+	return resources
+}
+
+// syncLicense fills in the license of a template that .template.json leaves out
+func (rs *RepositoryService) syncLicense(owner, repoName, ref string, tree *Tree, repo *Repository, template *models.Template) error {
+	if template.License != "" {
+		return nil
+	}
+	license, err := rs.detectLicense(owner, repoName, ref, tree, repo)
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return err
+	}
+	if err != nil {
+		slog.Warn("Failed to read template license", "template_id", template.ID, "error", err)
+		return nil
+	}
+	template.License = license
+	return nil
+}
+
+// parseGPU reads a GPU requirement: true for all GPUs, a number of GPUs, "all",
+// or an object with driver, count, capabilities and services
+func parseGPU(value interface{}) *models.TemplateGPU {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return &models.TemplateGPU{}
+		}
+	case float64:
+		if v >= 1 {
+			return &models.TemplateGPU{Count: int(v)}
+		}
+	case string:
+		if strings.EqualFold(strings.TrimSpace(v), "all") {
+			return &models.TemplateGPU{}
+		}
+	case map[string]interface{}:
+		gpu := &models.TemplateGPU{}
+		gpu.Driver, _ = v["driver"].(string)
+		if count, ok := v["count"].(float64); ok && count >= 1 {
+			gpu.Count = int(count)
+		}
+		for key, list := range map[string]*[]string{"capabilities": &gpu.Capabilities, "services": &gpu.Services} {
+			items, _ := v[key].([]interface{})
+			for _, item := range items {
+				if s, ok := item.(string); ok && s != "" {
+					*list = append(*list, s)
+				}
+			}
+		}
+		return gpu
+	}
+	return nil
+}
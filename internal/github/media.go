@@ -0,0 +1,325 @@
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"docker-deploy-app/internal/models"
+)
+
+const (
+	// maxMediaItems bounds the media gallery of a template; further items are ignored
+	maxMediaItems = 10
+	// maxMediaSize bounds each image of a media gallery
+	maxMediaSize = 5 * 1024 * 1024
+	// remoteMediaTTL is how long an image from a URL is kept before it is fetched again
+	remoteMediaTTL = 24 * time.Hour
+)
+
+// mediaTypes are the image types accepted in media galleries, as detected from
+// their content. SVG is left out: it can carry scripts
+var mediaTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// mediaClient fetches images from URLs. It refuses private and loopback addresses,
+// so a template cannot make the server probe its own network
+var mediaClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+					ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+					return fmt.Errorf("media host %s is not a public address", host)
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+}
+
+// mediaItem is an entry of the media section of .template.json
+type mediaItem struct {
+	source  string
+	caption string
+	remote  bool // source is a URL rather than a repository path
+}
+
+// parseMedia reads the media section of a template configuration. Items are image
+// URLs or repository-relative paths, given as strings or as objects with a url or
+// path and an optional caption
+func parseMedia(config map[string]interface{}) []mediaItem {
+	list, _ := config["media"].([]interface{})
+
+	var items []mediaItem
+	for _, entry := range list {
+		var item mediaItem
+		switch value := entry.(type) {
+		case string:
+			item.source = value
+		case map[string]interface{}:
+			url, _ := value["url"].(string)
+			repoPath, _ := value["path"].(string)
+			item.source = url
+			if url == "" {
+				item.source = repoPath
+			}
+			item.caption, _ = value["caption"].(string)
+		}
+
+		item.source = strings.TrimSpace(item.source)
+		if item.source == "" {
+			continue
+		}
+		item.remote = strings.HasPrefix(item.source, "https://") || strings.HasPrefix(item.source, "http://")
+		if !item.remote {
+			item.source = strings.TrimPrefix(path.Clean("/"+item.source), "/")
+		}
+
+		items = append(items, item)
+		if len(items) == maxMediaItems {
+			break
+		}
+	}
+	return items
+}
+
+// cachedMedia is a previously synced image, reused while it is unchanged
+type cachedMedia struct {
+	file        string
+	contentType string
+	size        int64
+	etag        string
+	syncedAt    time.Time
+}
+
+// syncMedia caches the images of a template's media gallery under the media
+// directory and records them, replacing the previous gallery. Repository images
+// are downloaded again only when their blob changes, and images from URLs once a
+// day. Images that fail to download, are too large or are not PNG, JPEG, GIF or
+// WebP are skipped. Nothing is done without a media directory
+func (rs *RepositoryService) syncMedia(owner, repoName, ref string, tree *Tree, templateID string, config map[string]interface{}) error {
+	if rs.mediaDir == "" {
+		return nil
+	}
+	dir := filepath.Join(rs.mediaDir, templateID)
+
+	cached, err := rs.cachedMedia(templateID)
+	if err != nil {
+		return err
+	}
+
+	var media []models.TemplateMedia
+	for _, item := range parseMedia(config) {
+		entry, err := rs.syncMediaItem(owner, repoName, ref, tree, dir, item, cached[item.source])
+		var rateLimitErr *RateLimitError
+		if errors.As(err, &rateLimitErr) {
+			return err
+		}
+		if err != nil {
+			slog.Warn("Skipping template media", "template_id", templateID, "source", item.source, "error", err)
+			continue
+		}
+		entry.Position = len(media)
+		media = append(media, *entry)
+	}
+
+	if err := rs.saveMedia(templateID, media); err != nil {
+		return err
+	}
+	removeUnusedMedia(dir, media)
+	return nil
+}
+
+// syncMediaItem returns the cached image of a media item, downloading it unless
+// the cached copy is current
+func (rs *RepositoryService) syncMediaItem(owner, repoName, ref string, tree *Tree, dir string, item mediaItem, cached *cachedMedia) (*models.TemplateMedia, error) {
+	var etag string
+	if !item.remote {
+		entry, ok := tree.Entry(item.source)
+		if !ok {
+			return nil, fmt.Errorf("not found in repository")
+		}
+		if entry.Size > maxMediaSize {
+			return nil, fmt.Errorf("larger than %d MB", maxMediaSize/(1024*1024))
+		}
+		etag = entry.SHA
+	}
+
+	entry := &models.TemplateMedia{Source: item.source, Caption: item.caption, ETag: etag, SyncedAt: time.Now()}
+	if cached != nil {
+		current := cached.etag == etag
+		if item.remote {
+			current = time.Since(cached.syncedAt) < remoteMediaTTL
+		}
+		if _, err := os.Stat(filepath.Join(dir, cached.file)); err == nil && current {
+			entry.File, entry.ContentType, entry.SizeBytes = cached.file, cached.contentType, cached.size
+			entry.SyncedAt = cached.syncedAt
+			return entry, nil
+		}
+	}
+
+	var data []byte
+	var err error
+	if item.remote {
+		data, err = fetchMedia(item.source)
+	} else {
+		data, err = rs.client.GetRawFileContent(owner, repoName, item.source, ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, err := checkMedia(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	entry.File = hex.EncodeToString(sum[:16])
+	entry.ContentType = contentType
+	entry.SizeBytes = int64(len(data))
+	if err := writeMedia(filepath.Join(dir, entry.File), data); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// cachedMedia returns the recorded gallery of a template, keyed by source
+func (rs *RepositoryService) cachedMedia(templateID string) (map[string]*cachedMedia, error) {
+	rows, err := rs.db.Query(`
+		SELECT source, file, content_type, size_bytes, COALESCE(etag, ''), synced_at
+		FROM template_media WHERE template_id = $1`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cached := make(map[string]*cachedMedia)
+	for rows.Next() {
+		var source string
+		var c cachedMedia
+		if err := rows.Scan(&source, &c.file, &c.contentType, &c.size, &c.etag, &c.syncedAt); err != nil {
+			return nil, err
+		}
+		cached[source] = &c
+	}
+	return cached, rows.Err()
+}
+
+// saveMedia replaces the recorded gallery of a template
+func (rs *RepositoryService) saveMedia(templateID string, media []models.TemplateMedia) error {
+	tx, err := rs.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM template_media WHERE template_id = $1", templateID); err != nil {
+		return err
+	}
+	for _, m := range media {
+		if _, err := tx.Exec(`
+			INSERT INTO template_media (template_id, position, source, caption, file, content_type, size_bytes, etag, synced_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			templateID, m.Position, m.Source, m.Caption, m.File, m.ContentType, m.SizeBytes, m.ETag, m.SyncedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// fetchMedia downloads an image from a URL
+func fetchMedia(url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "docker-deploy-media/1.0")
+
+	resp, err := mediaClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("media server responded with %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxMediaSize+1))
+}
+
+// checkMedia checks the size and detected type of an image, returning the type
+func checkMedia(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", fmt.Errorf("empty image")
+	}
+	if len(data) > maxMediaSize {
+		return "", fmt.Errorf("larger than %d MB", maxMediaSize/(1024*1024))
+	}
+	contentType := http.DetectContentType(data)
+	if !mediaTypes[contentType] {
+		return "", fmt.Errorf("unsupported type %s, expected PNG, JPEG, GIF or WebP", contentType)
+	}
+	return contentType, nil
+}
+
+// writeMedia writes an image file atomically, so it is never served half written
+func writeMedia(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".media-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// removeUnusedMedia deletes the cached images of a template no longer in its gallery
+func removeUnusedMedia(dir string, media []models.TemplateMedia) {
+	used := make(map[string]bool, len(media))
+	for _, m := range media {
+		used[m.File] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if !used[entry.Name()] {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
@@ -3,17 +3,23 @@ package github
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"path"
 	"strings"
 	"time"
 
+	"docker-deploy-app/internal/docker"
 	"docker-deploy-app/internal/models"
 )
 
 // RepositoryService handles GitHub repository operations
 type RepositoryService struct {
-	client *Client
-	db     *sql.DB
+	client   *Client
+	db       *sql.DB
+	mediaDir string // Where media galleries are cached; not synced when empty
+	arch     *docker.ArchResolver
 }
 
 // NewRepositoryService creates a new repository service
@@ -21,45 +27,184 @@ func NewRepositoryService(client *Client, db *sql.DB) *RepositoryService {
 	return &RepositoryService{
 		client: client,
 		db:     db,
+		arch:   docker.NewArchResolver(),
 	}
 }
 
+// SetMediaDir sets the directory template media galleries are cached in. Without
+// it, media are not synced
+func (rs *RepositoryService) SetMediaDir(dir string) {
+	rs.mediaDir = dir
+}
+
 // DiscoverTemplates discovers Docker Compose templates from repositories
 func (rs *RepositoryService) DiscoverTemplates() error {
-	// Get user repositories
-	repos, err := rs.client.ListRepositories(1, 100)
+	// Get repositories of the configured sources
+	repos, err := NewSyncService(rs.client, rs.db).getAllRepositories()
 	if err != nil {
 		return fmt.Errorf("failed to list repositories: %w", err)
 	}
 
 	for _, repo := range repos {
-		if err := rs.processRepository(repo); err != nil {
-			fmt.Printf("Failed to process repository %s: %v\n", repo.FullName, err)
+		if _, err := rs.processRepository(repo); err != nil {
+			slog.Error("Failed to process repository", "repository", repo.FullName, "error", err)
 		}
 	}
 
 	return nil
 }
 
-// processRepository processes a single repository for templates
-func (rs *RepositoryService) processRepository(repo *Repository) error {
-	// Check if repository contains docker-compose files
-	isDockerRepo, err := rs.client.IsDockerComposeRepo(parseOwnerRepo(repo.FullName))
-	if err != nil || !isDockerRepo {
-		return nil // Skip repositories without docker-compose
+// processRepository processes a single repository for templates. It reports
+// whether the repository contained a docker-compose file
+func (rs *RepositoryService) processRepository(repo *Repository) (bool, error) {
+	// One tree listing tells which of the files below exist
+	owner, repoName := parseOwnerRepo(repo.FullName)
+	tree, err := rs.client.GetTree(owner, repoName, repo.DefaultBranch)
+	var rateLimitErr *RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		return false, err
+	}
+	if err != nil {
+		return false, nil // Skip empty or inaccessible repositories
+	}
+	if tree.ComposeFile() == "" {
+		return false, nil // Skip repositories without docker-compose
 	}
 
 	// Try to get template configuration
-	owner, repoName := parseOwnerRepo(repo.FullName)
-	templateConfig, err := rs.client.GetTemplateConfig(owner, repoName, repo.DefaultBranch)
+	var templateConfig map[string]interface{}
+	manifest, err := rs.client.GetTemplateManifest(owner, repoName, repo.DefaultBranch, tree)
+	if errors.As(err, &rateLimitErr) {
+		return false, err
+	}
 	if err != nil {
 		// Create default template config
 		templateConfig = rs.createDefaultTemplateConfig(repo)
+	} else {
+		templateConfig = manifest.Config
+	}
+
+	composeContent, err := rs.client.GetRawFileContent(owner, repoName, tree.ComposeFile(), repo.DefaultBranch)
+	if errors.As(err, &rateLimitErr) {
+		return false, err
 	}
 
 	// Create or update template
 	template := rs.buildTemplate(repo, templateConfig)
-	return rs.saveTemplate(template)
+	template.VerifiedKeyID = rs.verifyManifest(template, manifest)
+	template.IsVerified = template.VerifiedKeyID != ""
+	template.Variables = docker.MergeVariables(template.Variables, rs.discoverVariables(owner, repoName, repo.DefaultBranch, tree, composeContent)...)
+	if err := rs.syncLicense(owner, repoName, repo.DefaultBranch, tree, repo, template); err != nil {
+		return false, err
+	}
+	template.Architectures = rs.templateArchitectures(template, composeContent)
+	configFiles, err := rs.fetchConfigFiles(owner, repoName, repo.DefaultBranch, tree, parseConfigFiles(templateConfig))
+	if err != nil {
+		return false, err
+	}
+	template.ConfigFiles = configFiles
+	template.SecurityBadge = models.SecurityBadgeUnscanned
+	scan := rs.scanTemplate(template, composeContent)
+	if scan != nil {
+		template.SecurityBadge = scan.Badge
+	}
+	if err := rs.saveTemplate(template); err != nil {
+		return true, err
+	}
+	if scan != nil {
+		if err := rs.saveSecurityScan(scan); err != nil {
+			slog.Warn("Failed to save template security scan", "template_id", template.ID, "error", err)
+		}
+	}
+	if err := rs.syncMedia(owner, repoName, repo.DefaultBranch, tree, template.ID, templateConfig); err != nil {
+		if errors.As(err, &rateLimitErr) {
+			return true, err
+		}
+		slog.Warn("Failed to sync template media", "template_id", template.ID, "error", err)
+	}
+	if err := rs.syncChangelog(owner, repoName, repo.HTMLURL, template.ID); err != nil {
+		if errors.As(err, &rateLimitErr) {
+			return true, err
+		}
+		slog.Warn("Failed to sync template changelog", "template_id", template.ID, "error", err)
+	}
+	return true, nil
+}
+
+// discoverVariables derives template variables from the .env.example and the
+// ${VAR} placeholders of the compose file
+func (rs *RepositoryService) discoverVariables(owner, repoName, ref string, tree *Tree, composeContent []byte) [][]models.TemplateVariable {
+	var discovered [][]models.TemplateVariable
+
+	if tree.Has(".env.example") {
+		if content, err := rs.client.GetRawFileContent(owner, repoName, ".env.example", ref); err == nil {
+			discovered = append(discovered, docker.ParseEnvExample(content))
+		}
+	}
+
+	if composeContent != nil {
+		discovered = append(discovered, docker.ExtractVariables(composeContent))
+	}
+
+	return discovered
+}
+
+// scanTemplate lints the compose file of a template for risky settings. It
+// returns nil when there is no compose content to scan
+func (rs *RepositoryService) scanTemplate(template *models.Template, composeContent []byte) *models.SecurityScan {
+	if composeContent == nil {
+		return nil
+	}
+
+	scan := &models.SecurityScan{
+		TemplateID: template.ID,
+		Version:    template.Version,
+		Badge:      models.SecurityBadgeUnscanned,
+		Findings:   []models.SecurityFinding{},
+		ScannedAt:  time.Now(),
+	}
+	findings, err := docker.LintComposeSecurity(composeContent)
+	if err != nil {
+		slog.Warn("Failed to scan template compose file", "template_id", template.ID, "error", err)
+		return scan
+	}
+	scan.Findings = findings
+	scan.Badge = models.SecurityBadgeOf(findings)
+	return scan
+}
+
+// templateArchitectures reads the architectures the images of a template are
+// published for, with its variables at their defaults. It is nil when unknown
+func (rs *RepositoryService) templateArchitectures(template *models.Template, composeContent []byte) []string {
+	if composeContent == nil {
+		return nil
+	}
+
+	env := make(map[string]string)
+	for _, variable := range template.Variables {
+		if variable.DefaultValue != "" {
+			env[variable.Name] = variable.DefaultValue
+		}
+	}
+	archs, err := rs.arch.Architectures(composeContent, env)
+	if err != nil {
+		slog.Warn("Failed to read template architectures", "template_id", template.ID, "error", err)
+		return nil
+	}
+	return archs
+}
+
+// saveSecurityScan records the scan of a template version, replacing an earlier
+// scan of the same version
+func (rs *RepositoryService) saveSecurityScan(scan *models.SecurityScan) error {
+	findingsJSON, _ := json.Marshal(scan.Findings)
+	_, err := rs.db.Exec(`
+		INSERT INTO template_security_scans (template_id, version, badge, findings, scanned_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (template_id, version) DO UPDATE SET
+			badge = excluded.badge, findings = excluded.findings, scanned_at = excluded.scanned_at`,
+		scan.TemplateID, scan.Version, scan.Badge, string(findingsJSON), scan.ScannedAt)
+	return err
 }
 
 // createDefaultTemplateConfig creates default template configuration
@@ -109,8 +254,11 @@ func (rs *RepositoryService) buildTemplate(repo *Repository, config map[string]i
 		template.Description = repo.Description
 	}
 
-	if category, ok := config["category"].(string); ok {
+	// A category outside the marketplace set would hide the template from the sidebar
+	if category, ok := config["category"].(string); ok && rs.knownCategory(category) {
 		template.Category = category
+	} else {
+		template.Category = rs.guessCategory(template.Name, template.Description)
 	}
 
 	if icon, ok := config["icon"].(string); ok {
@@ -121,6 +269,14 @@ func (rs *RepositoryService) buildTemplate(repo *Repository, config map[string]i
 		template.Version = version
 	}
 
+	// The license falls back to the repository's, filled in by syncLicense
+	if license, ok := config["license"].(string); ok {
+		template.License = strings.TrimSpace(license)
+	}
+	template.Resources = parseResources(config)
+	template.BackupHooks = parseBackupHooks(config)
+	template.Hooks = parseLifecycleHooks(config)
+
 	// Handle tags
 	if tags, ok := config["tags"].([]interface{}); ok {
 		for _, tag := range tags {
@@ -180,14 +336,136 @@ func (rs *RepositoryService) buildTemplate(repo *Repository, config map[string]i
 		}
 	}
 
-	// Set publisher info
+	// Set publisher info. The template is verified later, from its manifest signature
 	owner, _ := parseOwnerRepo(repo.FullName)
 	template.PublisherID = owner
-	template.IsVerified = rs.isVerifiedPublisher(owner)
 
 	return template
 }
 
+// parseBackupHooks reads the hooks of the backup section of a template
+// configuration. Hooks that do not validate are dropped
+func parseBackupHooks(config map[string]interface{}) []models.BackupHook {
+	section, ok := config["backup"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	entries, ok := section["hooks"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var hooks []models.BackupHook
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		var hook models.BackupHook
+		if err := json.Unmarshal(data, &hook); err != nil {
+			continue
+		}
+		if err := hook.Validate(); err != nil || seen[hook.DumpName()] {
+			slog.Warn("Ignoring invalid backup hook", "service", hook.Service, "name", hook.DumpName(), "error", err)
+			continue
+		}
+		seen[hook.DumpName()] = true
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+// parseLifecycleHooks reads the hooks of a template configuration. Hooks that
+// do not validate are dropped
+func parseLifecycleHooks(config map[string]interface{}) []models.LifecycleHook {
+	entries, ok := config["hooks"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var hooks []models.LifecycleHook
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		var hook models.LifecycleHook
+		if err := json.Unmarshal(data, &hook); err != nil {
+			continue
+		}
+		if err := hook.Validate(); err != nil {
+			slog.Warn("Ignoring invalid lifecycle hook", "hook", hook.DisplayName(), "error", err)
+			continue
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+// parseConfigFiles reads the config files of a template configuration.
+// Declarations that do not validate are dropped
+func parseConfigFiles(config map[string]interface{}) []models.ConfigFile {
+	entries, ok := config["config_files"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var files []models.ConfigFile
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		var file models.ConfigFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			continue
+		}
+		file.Content = ""
+		if err := file.Validate(); err != nil || seen[path.Clean(file.Target)] {
+			slog.Warn("Ignoring invalid config file", "source", file.Source, "target", file.Target, "error", err)
+			continue
+		}
+		seen[path.Clean(file.Target)] = true
+		files = append(files, file)
+	}
+	return files
+}
+
+// fetchConfigFiles fetches the content of config files from next to the
+// compose file. Files missing from the repository or too large are dropped;
+// only rate limiting fails the sync
+func (rs *RepositoryService) fetchConfigFiles(owner, repoName, ref string, tree *Tree, files []models.ConfigFile) ([]models.ConfigFile, error) {
+	var rateLimitErr *RateLimitError
+	baseDir := path.Dir(tree.ComposeFile())
+
+	var fetched []models.ConfigFile
+	for _, file := range files {
+		source := path.Join(baseDir, file.Source)
+		entry, ok := tree.Entry(source)
+		if !ok {
+			slog.Warn("Ignoring config file missing from the repository", "repo", owner+"/"+repoName, "source", source)
+			continue
+		}
+		if entry.Size > models.MaxConfigFileSize {
+			slog.Warn("Ignoring config file that is too large", "repo", owner+"/"+repoName, "source", source, "size", entry.Size)
+			continue
+		}
+		content, err := rs.client.GetRawFileContent(owner, repoName, source, ref)
+		if errors.As(err, &rateLimitErr) {
+			return nil, err
+		}
+		if err != nil {
+			slog.Warn("Failed to fetch config file", "repo", owner+"/"+repoName, "source", source, "error", err)
+			continue
+		}
+		file.Content = string(content)
+		fetched = append(fetched, file)
+	}
+	return fetched, nil
+}
+
 // saveTemplate saves or updates a template in the database
 func (rs *RepositoryService) saveTemplate(template *models.Template) error {
 	// Check if template already exists
@@ -198,9 +476,16 @@ func (rs *RepositoryService) saveTemplate(template *models.Template) error {
 	}
 
 	// Marshal JSON fields
+	template.Tags = models.NormalizeTags(template.Tags)
 	tagsJSON, _ := template.MarshalTags()
 	variablesJSON, _ := template.MarshalVariables()
 	newtConfigJSON, _ := template.MarshalNewtConfig()
+	resourcesJSON, _ := template.MarshalResources()
+	architecturesJSON, _ := template.MarshalArchitectures()
+	backupHooksJSON, _ := template.MarshalBackupHooks()
+	hooksJSON, _ := template.MarshalHooks()
+	configFilesJSON, _ := template.MarshalConfigFiles()
+	verifiedKeyID := sql.NullString{String: template.VerifiedKeyID, Valid: template.VerifiedKeyID != ""}
 
 	if exists {
 		// Update existing template
@@ -209,26 +494,53 @@ func (rs *RepositoryService) saveTemplate(template *models.Template) error {
 				name = $1, description = $2, icon = $3, category = $4, tags = $5,
 				repo_url = $6, branch = $7, path = $8, version = $9, variables = $10,
 				requires_newt = $11, newt_config = $12, publisher_id = $13, is_verified = $14,
-				updated_at = $15
-			WHERE id = $16`,
+				verified_key_id = $15, security_badge = $16, license = $17, resources = $18, architectures = $19,
+				backup_hooks = $20, lifecycle_hooks = $21, config_files = $22, updated_at = $23
+			WHERE id = $24`,
 			template.Name, template.Description, template.Icon, template.Category, tagsJSON,
 			template.RepoURL, template.Branch, template.Path, template.Version, variablesJSON,
 			template.RequiresNewt, newtConfigJSON, template.PublisherID, template.IsVerified,
-			template.UpdatedAt, template.ID)
+			verifiedKeyID, template.SecurityBadge, template.License, resourcesJSON, architecturesJSON,
+			backupHooksJSON, hooksJSON, configFilesJSON, template.UpdatedAt, template.ID)
 	} else {
 		// Insert new template
 		_, err = rs.db.Exec(`
 			INSERT INTO templates (
 				id, name, description, icon, category, tags, repo_url, branch, path, version,
-				variables, requires_newt, newt_config, publisher_id, is_verified, created_at, updated_at
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+				variables, requires_newt, newt_config, publisher_id, is_verified, verified_key_id, security_badge,
+				license, resources, architectures, backup_hooks, lifecycle_hooks, config_files, created_at, updated_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25)`,
 			template.ID, template.Name, template.Description, template.Icon, template.Category, tagsJSON,
 			template.RepoURL, template.Branch, template.Path, template.Version, variablesJSON,
 			template.RequiresNewt, newtConfigJSON, template.PublisherID, template.IsVerified,
-			template.CreatedAt, template.UpdatedAt)
+			verifiedKeyID, template.SecurityBadge, template.License, resourcesJSON, architecturesJSON,
+			backupHooksJSON, hooksJSON, configFilesJSON, template.CreatedAt, template.UpdatedAt)
+	}
+	if err != nil {
+		return err
 	}
 
-	return err
+	return rs.saveTags(template)
+}
+
+// saveTags replaces the rows of template_tags of a template, which filters and
+// the tag cloud query instead of the JSON column
+func (rs *RepositoryService) saveTags(template *models.Template) error {
+	tx, err := rs.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM template_tags WHERE template_id = $1", template.ID); err != nil {
+		return err
+	}
+	for _, tag := range template.Tags {
+		if _, err := tx.Exec("INSERT INTO template_tags (template_id, tag) VALUES ($1, $2)", template.ID, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 // SyncRepository syncs a specific repository
@@ -243,7 +555,8 @@ func (rs *RepositoryService) SyncRepository(repoURL string) error {
 		return err
 	}
 
-	return rs.processRepository(repo)
+	_, err = rs.processRepository(repo)
+	return err
 }
 
 // GetDockerComposeContent gets docker-compose file content
@@ -264,14 +577,7 @@ func (rs *RepositoryService) GetDockerComposeContent(templateID string) ([]byte,
 	}
 
 	// Try different compose file names
-	composeFiles := []string{
-		"docker-compose.yml",
-		"docker-compose.yaml",
-		"compose.yml",
-		"compose.yaml",
-	}
-
-	for _, filename := range composeFiles {
+	for _, filename := range composeFileNames {
 		filePath := filename
 		if path != "/" {
 			filePath = strings.TrimSuffix(path, "/") + "/" + filename
@@ -286,6 +592,28 @@ func (rs *RepositoryService) GetDockerComposeContent(templateID string) ([]byte,
 	return nil, fmt.Errorf("no docker-compose file found")
 }
 
+// GetTemplateFile gets a file next to a template's docker-compose file
+func (rs *RepositoryService) GetTemplateFile(templateID, filename string) ([]byte, error) {
+	var repoURL, branch, path string
+	err := rs.db.QueryRow(`
+		SELECT repo_url, branch, path 
+		FROM templates WHERE id = $1`, templateID).Scan(&repoURL, &branch, &path)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, repoName, err := ParseRepoURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	filePath := filename
+	if path != "/" {
+		filePath = strings.TrimSuffix(path, "/") + "/" + filename
+	}
+	return rs.client.GetRawFileContent(owner, repoName, filePath, branch)
+}
+
 // Helper functions
 
 func (rs *RepositoryService) generateTemplateID(fullName string) string {
@@ -296,72 +624,111 @@ func (rs *RepositoryService) generateTemplateID(fullName string) string {
 	return id
 }
 
+// guessCategory picks the first category, in marketplace order, with a keyword
+// found in the repository name or description
 func (rs *RepositoryService) guessCategory(name, description string) string {
 	text := strings.ToLower(name + " " + description)
 
-	categories := map[string][]string{
-		"web": {"web", "website", "frontend", "react", "vue", "angular", "nextjs", "nuxt", "nginx", "apache"},
-		"database": {"database", "db", "mysql", "postgres", "mongodb", "redis", "elasticsearch"},
-		"monitoring": {"monitoring", "metrics", "grafana", "prometheus", "alertmanager", "jaeger"},
-		"development": {"dev", "development", "testing", "ci", "cd", "jenkins", "gitlab"},
-		"networking": {"network", "proxy", "load-balancer", "traefik", "caddy", "haproxy"},
-		"security": {"security", "auth", "oauth", "keycloak", "vault", "ssl", "cert"},
-		"analytics": {"analytics", "data", "spark", "kafka", "elastic", "kibana"},
-		"ai-ml": {"ai", "ml", "machine-learning", "tensorflow", "pytorch", "jupyter"},
-	}
-
-	for category, keywords := range categories {
-		for _, keyword := range keywords {
+	categories := rs.categories()
+	for _, category := range categories {
+		for _, keyword := range category.Keywords {
 			if strings.Contains(text, keyword) {
-				return category
+				return category.Name
 			}
 		}
 	}
 
-	return "web" // Default category
+	// Default category
+	for _, category := range categories {
+		if category.Name == "web" {
+			return category.Name
+		}
+	}
+	if len(categories) > 0 {
+		return categories[0].Name
+	}
+	return ""
 }
 
 func (rs *RepositoryService) getDefaultIcon(category string) string {
-	icons := map[string]string{
-		"web":         "🌐",
-		"database":    "🗄️",
-		"monitoring":  "📊",
-		"development": "🛠️",
-		"networking":  "🌐",
-		"security":    "🔒",
-		"analytics":   "📈",
-		"ai-ml":       "🤖",
-	}
-
-	if icon, exists := icons[category]; exists {
-		return icon
+	for _, c := range rs.categories() {
+		if c.Name == category && c.Icon != "" {
+			return c.Icon
+		}
 	}
 	return "📦"
 }
 
-func (rs *RepositoryService) isVerifiedPublisher(publisher string) bool {
-	verifiedPublishers := []string{
-		"docker",
-		"bitnami",
-		"linuxserver",
-		"nextcloud",
-		"wordpress",
-		"ghost",
-		"grafana",
-		"prometheus",
-		"elastic",
-	}
-
-	publisher = strings.ToLower(publisher)
-	for _, verified := range verifiedPublishers {
-		if publisher == verified {
+// knownCategory reports whether a category is defined in the marketplace
+func (rs *RepositoryService) knownCategory(category string) bool {
+	for _, c := range rs.categories() {
+		if c.Name == category {
 			return true
 		}
 	}
-
 	return false
 }
 
+// categories returns the marketplace categories in display order. They are
+// managed by admins, so they are read on every use
+func (rs *RepositoryService) categories() []models.Category {
+	rows, err := rs.db.Query("SELECT name, icon, keywords FROM categories ORDER BY position, name")
+	if err != nil {
+		slog.Warn("Failed to load categories", "error", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		var c models.Category
+		var keywordsJSON string
+		if err := rows.Scan(&c.Name, &c.Icon, &keywordsJSON); err != nil {
+			continue
+		}
+		json.Unmarshal([]byte(keywordsJSON), &c.Keywords)
+		categories = append(categories, c)
+	}
+	return categories
+}
+
+// verifyManifest checks the manifest signatures of a template against the keys
+// trusted for its publisher, returning the ID of the key that verified one or ""
+func (rs *RepositoryService) verifyManifest(template *models.Template, manifest *TemplateManifest) string {
+	if manifest == nil || len(manifest.Signatures) == 0 {
+		return ""
+	}
+
+	rows, err := rs.db.Query(`
+		SELECT id, publisher_id, key_type, public_key FROM publisher_keys
+		WHERE publisher_id = $1 ORDER BY created_at`, strings.ToLower(template.PublisherID))
+	if err != nil {
+		slog.Warn("Failed to load publisher keys", "publisher", template.PublisherID, "error", err)
+		return ""
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key models.PublisherKey
+		if err := rows.Scan(&key.ID, &key.PublisherID, &key.KeyType, &key.PublicKey); err != nil {
+			continue
+		}
+		signature, ok := manifest.Signatures[key.KeyType]
+		if !ok {
+			continue
+		}
+		if err := verifySignature(key, manifest.Content, signature); err != nil {
+			slog.Debug("Template manifest signature not verified by key", "template_id", template.ID, "key_id", key.ID, "error", err)
+			continue
+		}
+		return key.ID
+	}
+
+	slog.Warn("Template manifest is signed but no trusted publisher key verifies it",
+		"template_id", template.ID, "publisher", template.PublisherID, "manifest", manifest.Path)
+	return ""
+}
+
 func parseOwnerRepo(fullName string) (string, string) {
 	parts := strings.Split(fullName, "/")
 	if len(parts) >= 2 {
@@ -404,7 +771,7 @@ func (rs *RepositoryService) CleanupDeletedRepositories() error {
 	for _, templateID := range templatesToDelete {
 		_, err := rs.db.Exec("DELETE FROM templates WHERE id = $1", templateID)
 		if err != nil {
-			fmt.Printf("Failed to delete template %s: %v\n", templateID, err)
+			slog.Error("Failed to delete template", "template_id", templateID, "error", err)
 		}
 	}
 
@@ -0,0 +1,177 @@
+package github
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"docker-deploy-app/internal/models"
+)
+
+// signatureVerifier checks detached signatures made with a publisher key
+type signatureVerifier interface {
+	verify(message, signature []byte) error
+	fingerprint() string
+}
+
+// ParsePublisherKey checks that a public key of the given type can verify
+// signatures and returns its fingerprint
+func ParsePublisherKey(keyType models.PublisherKeyType, publicKey string) (string, error) {
+	verifier, err := parsePublisherKey(keyType, publicKey)
+	if err != nil {
+		return "", err
+	}
+	return verifier.fingerprint(), nil
+}
+
+// verifySignature verifies a detached signature of message by a publisher key
+func verifySignature(key models.PublisherKey, message, signature []byte) error {
+	verifier, err := parsePublisherKey(key.KeyType, key.PublicKey)
+	if err != nil {
+		return err
+	}
+	return verifier.verify(message, signature)
+}
+
+func parsePublisherKey(keyType models.PublisherKeyType, publicKey string) (signatureVerifier, error) {
+	switch keyType {
+	case models.PublisherKeyCosign:
+		return parseCosignKey(publicKey)
+	case models.PublisherKeyMinisign:
+		return parseMinisignKey(publicKey)
+	}
+	return nil, models.ErrPublisherKeyType
+}
+
+// cosignKey verifies `cosign sign-blob` signatures: base64 of an ASN.1 ECDSA,
+// Ed25519 or RSA PKCS#1 v1.5 signature over the SHA-256 of the blob
+type cosignKey struct {
+	der []byte
+	key crypto.PublicKey
+}
+
+func parseCosignKey(publicKey string) (*cosignKey, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("cosign key must be a PEM encoded PUBLIC KEY")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cosign key: %w", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey, *rsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported cosign key algorithm %T", key)
+	}
+	return &cosignKey{der: block.Bytes, key: key}, nil
+}
+
+func (k *cosignKey) verify(message, signature []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil {
+		return fmt.Errorf("invalid cosign signature encoding: %w", err)
+	}
+	digest := sha256.Sum256(message)
+
+	valid := false
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, digest[:], sig)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, message, sig)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	}
+	if !valid {
+		return fmt.Errorf("cosign signature does not match")
+	}
+	return nil
+}
+
+func (k *cosignKey) fingerprint() string {
+	sum := sha256.Sum256(k.der)
+	return hex.EncodeToString(sum[:])
+}
+
+// minisignKey verifies minisign signatures. Only legacy signatures over the
+// message itself (minisign -l) are supported: prehashed ones need BLAKE2b
+type minisignKey struct {
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// minisignLines drops the comment lines of a minisign key or signature file
+func minisignLines(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "untrusted comment:") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func parseMinisignKey(publicKey string) (*minisignKey, error) {
+	lines := minisignLines(publicKey)
+	if len(lines) != 1 {
+		return nil, fmt.Errorf("minisign key must be a single base64 line")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return nil, fmt.Errorf("invalid minisign key")
+	}
+	return &minisignKey{id: raw[2:10], key: ed25519.PublicKey(raw[10:])}, nil
+}
+
+func (k *minisignKey) verify(message, signature []byte) error {
+	lines := minisignLines(string(signature))
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "trusted comment: ") {
+		return fmt.Errorf("invalid minisign signature file")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		return fmt.Errorf("prehashed minisign signatures are not supported, sign with minisign -l")
+	default:
+		return fmt.Errorf("unknown minisign signature algorithm")
+	}
+	if !bytes.Equal(sig[2:10], k.id) {
+		return fmt.Errorf("minisign signature was made by another key")
+	}
+	if !ed25519.Verify(k.key, message, sig[10:]) {
+		return fmt.Errorf("minisign signature does not match")
+	}
+
+	// The global signature covers the signature and the trusted comment
+	globalSig, err := base64.StdEncoding.DecodeString(lines[2])
+	trustedComment := strings.TrimPrefix(lines[1], "trusted comment: ")
+	if err != nil || !ed25519.Verify(k.key, append(sig[10:], trustedComment...), globalSig) {
+		return fmt.Errorf("minisign trusted comment signature does not match")
+	}
+	return nil
+}
+
+func (k *minisignKey) fingerprint() string {
+	// minisign identifies keys by their ID, printed as big-endian hex
+	id := make([]byte, len(k.id))
+	for i := range k.id {
+		id[i] = k.id[len(k.id)-1-i]
+	}
+	return strings.ToUpper(hex.EncodeToString(id))
+}
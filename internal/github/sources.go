@@ -0,0 +1,150 @@
+package github
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	"docker-deploy-app/internal/models"
+)
+
+// LoadSources returns the configured template discovery sources
+func LoadSources(db *sql.DB, enabledOnly bool) ([]*models.GitHubSource, error) {
+	query := `
+		SELECT id, type, value, include_patterns, exclude_patterns, enabled, created_at
+		FROM github_sources`
+	if enabledOnly {
+		query += " WHERE enabled = TRUE"
+	}
+	query += " ORDER BY id"
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := []*models.GitHubSource{}
+	for rows.Next() {
+		var source models.GitHubSource
+		var include, exclude string
+
+		if err := rows.Scan(&source.ID, &source.Type, &source.Value, &include, &exclude,
+			&source.Enabled, &source.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := source.UnmarshalPatterns(include, exclude); err != nil {
+			return nil, fmt.Errorf("source %d has invalid patterns: %w", source.ID, err)
+		}
+		sources = append(sources, &source)
+	}
+
+	return sources, rows.Err()
+}
+
+// sourceRepositories lists the repositories of all enabled sources that pass their
+// patterns. Without sources the token owner's repositories are filtered by heuristics
+func (ss *SyncService) sourceRepositories() ([]*Repository, error) {
+	sources, err := LoadSources(ss.db, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load sources: %w", err)
+	}
+
+	if len(sources) == 0 {
+		return ss.listPages(ss.client.ListRepositories, ss.shouldProcessRepository)
+	}
+
+	seen := make(map[string]bool)
+	var allRepos []*Repository
+	for _, source := range sources {
+		repos, err := ss.listSource(source)
+		if isRateLimitError(err) {
+			return nil, err
+		}
+		if err != nil {
+			slog.Warn("Skipping GitHub source", "type", source.Type, "value", source.Value, "error", err)
+			continue
+		}
+
+		for _, repo := range repos {
+			if seen[repo.FullName] || !source.Matches(repo.FullName) {
+				continue
+			}
+			seen[repo.FullName] = true
+			allRepos = append(allRepos, repo)
+		}
+	}
+
+	return allRepos, nil
+}
+
+// listSource lists the repositories of a single source
+func (ss *SyncService) listSource(source *models.GitHubSource) ([]*Repository, error) {
+	switch source.Type {
+	case models.GitHubSourceOrg:
+		return ss.listPages(func(page, perPage int) ([]*Repository, error) {
+			return ss.client.ListOrgRepositories(source.Value, page, perPage)
+		}, nil)
+	case models.GitHubSourceUser:
+		return ss.listPages(func(page, perPage int) ([]*Repository, error) {
+			return ss.client.ListUserRepositories(source.Value, page, perPage)
+		}, nil)
+	case models.GitHubSourceTopic:
+		query := url.QueryEscape("topic:" + source.Value)
+		return ss.listPages(func(page, perPage int) ([]*Repository, error) {
+			return ss.client.SearchRepositories(query, page, perPage)
+		}, nil)
+	case models.GitHubSourceRepo:
+		owner, repoName, err := ParseRepoURL(source.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		var repo *Repository
+		err = ss.withRateLimit(func() error {
+			var err error
+			repo, err = ss.client.GetRepository(owner, repoName)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []*Repository{repo}, nil
+	}
+
+	return nil, models.ErrGitHubSourceInvalidType
+}
+
+// listPages collects all pages of a repository listing, keeping those accepted by keep
+func (ss *SyncService) listPages(list func(page, perPage int) ([]*Repository, error), keep func(*Repository) bool) ([]*Repository, error) {
+	var allRepos []*Repository
+	page := 1
+	perPage := 100
+
+	for {
+		var repos []*Repository
+		err := ss.withRateLimit(func() error {
+			var err error
+			repos, err = list(page, perPage)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, repo := range repos {
+			if keep == nil || keep(repo) {
+				allRepos = append(allRepos, repo)
+			}
+		}
+
+		if len(repos) < perPage {
+			break
+		}
+
+		page++
+	}
+
+	return allRepos, nil
+}
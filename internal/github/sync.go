@@ -3,11 +3,28 @@ package github
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"docker-deploy-app/internal/maintenance"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/tasks"
+	"docker-deploy-app/internal/webhooks"
+)
+
+const (
+	// rateLimitReserve is the quota left untouched for interactive requests
+	rateLimitReserve = 50
+	// maxRateLimitWait is the longest a sync sleeps for the quota to reset; beyond
+	// that it checkpoints and resumes on the next run
+	maxRateLimitWait = 15 * time.Minute
+	// maxRateLimitRetries bounds the retries of a single request
+	maxRateLimitRetries = 3
 )
 
 // SyncService handles template synchronization from GitHub
@@ -15,7 +32,11 @@ type SyncService struct {
 	client    *Client
 	db        *sql.DB
 	repoSvc   *RepositoryService
+	tasks     *tasks.Tracker
+	webhooks  *webhooks.Publisher
+	afterSync func()
 	isRunning bool
+	syncing   bool
 	mu        sync.RWMutex
 	stopChan  chan struct{}
 }
@@ -31,6 +52,18 @@ type SyncResult struct {
 	TemplatesDeleted int       `json:"templates_deleted"`
 	Errors           []string  `json:"errors"`
 	Success          bool      `json:"success"`
+	ResumedFrom      string    `json:"resumed_from,omitempty"`
+	Interrupted      bool      `json:"interrupted"`
+	RateLimit        RateLimit `json:"rate_limit"`
+}
+
+// SyncStatus reports the state of synchronization and the remaining GitHub quota
+type SyncStatus struct {
+	Running    bool        `json:"running"`
+	Syncing    bool        `json:"syncing"`
+	Checkpoint string      `json:"checkpoint,omitempty"`
+	RateLimit  RateLimit   `json:"rate_limit"`
+	LastResult *SyncResult `json:"last_result"`
 }
 
 // NewSyncService creates a new sync service
@@ -39,10 +72,34 @@ func NewSyncService(client *Client, db *sql.DB) *SyncService {
 		client:   client,
 		db:       db,
 		repoSvc:  NewRepositoryService(client, db),
+		tasks:    tasks.NewTracker(db),
+		webhooks: webhooks.NewPublisher(db),
 		stopChan: make(chan struct{}),
 	}
 }
 
+// SetMediaDir sets the directory template media galleries are cached in
+func (ss *SyncService) SetMediaDir(dir string) {
+	ss.repoSvc.SetMediaDir(dir)
+}
+
+// SetAfterSync registers a hook run in the background after templates were synced
+func (ss *SyncService) SetAfterSync(hook func()) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.afterSync = hook
+}
+
+// runAfterSync starts the after-sync hook, if any
+func (ss *SyncService) runAfterSync() {
+	ss.mu.RLock()
+	hook := ss.afterSync
+	ss.mu.RUnlock()
+	if hook != nil {
+		go hook()
+	}
+}
+
 // StartPeriodicSync starts periodic synchronization
 func (ss *SyncService) StartPeriodicSync(interval time.Duration) {
 	if ss.IsRunning() {
@@ -51,10 +108,12 @@ func (ss *SyncService) StartPeriodicSync(interval time.Duration) {
 
 	ss.mu.Lock()
 	ss.isRunning = true
+	ss.stopChan = make(chan struct{}) // A previous stop closed the old channel
+	stopChan := ss.stopChan
 	ss.mu.Unlock()
 
-	go ss.syncLoop(interval)
-	log.Printf("Started periodic GitHub sync with interval: %v", interval)
+	go ss.syncLoop(interval, stopChan)
+	slog.Info("Started periodic GitHub sync", "interval", interval)
 }
 
 // StopPeriodicSync stops periodic synchronization
@@ -68,7 +127,7 @@ func (ss *SyncService) StopPeriodicSync() {
 
 	close(ss.stopChan)
 	ss.isRunning = false
-	log.Println("Stopped periodic GitHub sync")
+	slog.Info("Stopped periodic GitHub sync")
 }
 
 // IsRunning returns true if periodic sync is running
@@ -78,52 +137,116 @@ func (ss *SyncService) IsRunning() bool {
 	return ss.isRunning
 }
 
-// SyncAll performs a full synchronization of all repositories
+// SyncAll performs a full synchronization of all repositories. A sync stopped by
+// rate limiting resumes after the last processed repository on the next call
 func (ss *SyncService) SyncAll() (*SyncResult, error) {
+	ss.mu.Lock()
+	if ss.syncing {
+		ss.mu.Unlock()
+		return nil, fmt.Errorf("sync already in progress")
+	}
+	ss.syncing = true
+	ss.mu.Unlock()
+
+	defer func() {
+		ss.mu.Lock()
+		ss.syncing = false
+		ss.mu.Unlock()
+	}()
+
 	result := &SyncResult{
 		StartTime: time.Now(),
 		Errors:    []string{},
 	}
 
-	log.Println("Starting full GitHub sync...")
+	slog.Info("Starting full GitHub sync")
+	taskID := ss.tasks.Start(models.TaskTypeGitHubSync, "", "Synchronizing templates from GitHub")
+
+	checkpoint, err := ss.loadCheckpoint()
+	if err != nil {
+		slog.Error("Failed to load sync checkpoint", "error", err)
+	}
+	if checkpoint != "" {
+		result.ResumedFrom = checkpoint
+		slog.Info("Resuming GitHub sync", "after", checkpoint)
+	}
 
 	// Get all repositories
 	repos, err := ss.getAllRepositories()
 	if err != nil {
 		result.Errors = append(result.Errors, fmt.Sprintf("Failed to get repositories: %v", err))
 		result.Success = false
+		result.Interrupted = isRateLimitError(err)
+		result.RateLimit = ss.client.RateLimit()
 		result.EndTime = time.Now()
 		result.Duration = result.EndTime.Sub(result.StartTime).String()
+		ss.tasks.Finish(taskID, err)
 		return result, err
 	}
 
 	result.RepositoriesFound = len(repos)
 
-	// Process each repository
-	for _, repo := range repos {
-		if err := ss.processRepository(repo, result); err != nil {
+	// Process repositories in name order so a checkpoint marks a stable position
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].FullName < repos[j].FullName
+	})
+
+	for i, repo := range repos {
+		if checkpoint != "" && repo.FullName <= checkpoint {
+			continue
+		}
+		ss.tasks.Progress(taskID, i*100/len(repos), fmt.Sprintf("Processing %s", repo.FullName))
+
+		err := ss.withRateLimit(func() error {
+			return ss.processRepository(repo, result)
+		})
+		if isRateLimitError(err) {
+			result.Interrupted = true
+			result.Errors = append(result.Errors, fmt.Sprintf("Sync paused before %s: %v", repo.FullName, err))
+			break
+		}
+		if err != nil {
 			result.Errors = append(result.Errors, fmt.Sprintf("Failed to process %s: %v", repo.FullName, err))
 		}
+
+		if err := ss.saveCheckpoint(repo.FullName); err != nil {
+			slog.Error("Failed to save sync checkpoint", "error", err)
+		}
 	}
 
-	// Cleanup deleted repositories
-	deleted, err := ss.cleanupDeletedRepositories()
-	if err != nil {
-		result.Errors = append(result.Errors, fmt.Sprintf("Cleanup failed: %v", err))
-	} else {
-		result.TemplatesDeleted = deleted
+	if !result.Interrupted {
+		// Cleanup deleted repositories
+		deleted, err := ss.cleanupDeletedRepositories()
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Cleanup failed: %v", err))
+		} else {
+			result.TemplatesDeleted = deleted
+		}
+
+		if err := ss.clearCheckpoint(); err != nil {
+			slog.Error("Failed to clear sync checkpoint", "error", err)
+		}
 	}
 
+	result.RateLimit = ss.client.RateLimit()
 	result.EndTime = time.Now()
 	result.Duration = result.EndTime.Sub(result.StartTime).String()
 	result.Success = len(result.Errors) == 0
 
 	// Save sync result
 	ss.saveSyncResult(result)
+	ss.runAfterSync()
 
-	log.Printf("GitHub sync completed: %d repos, %d created, %d updated, %d deleted, %d errors",
-		result.RepositoriesFound, result.TemplatesCreated, result.TemplatesUpdated,
-		result.TemplatesDeleted, len(result.Errors))
+	var taskErr error
+	if !result.Success {
+		taskErr = errors.New(strings.Join(result.Errors, "; "))
+	}
+	ss.tasks.Finish(taskID, taskErr)
+
+	slog.Info("GitHub sync completed",
+		"repositories", result.RepositoriesFound, "created", result.TemplatesCreated,
+		"updated", result.TemplatesUpdated, "deleted", result.TemplatesDeleted,
+		"errors", len(result.Errors))
 
 	return result, nil
 }
@@ -150,18 +273,56 @@ func (ss *SyncService) SyncRepository(repoURL string) error {
 		return err
 	}
 
-	log.Printf("Synced repository %s: %d created, %d updated",
-		repo.FullName, result.TemplatesCreated, result.TemplatesUpdated)
+	slog.Info("Synced repository", "repository", repo.FullName,
+		"created", result.TemplatesCreated, "updated", result.TemplatesUpdated)
+	ss.runAfterSync()
 
 	return nil
 }
 
+// Status returns whether a sync is running, where an interrupted sync will resume
+// and the remaining GitHub quota
+func (ss *SyncService) Status() (*SyncStatus, error) {
+	ss.mu.RLock()
+	status := &SyncStatus{
+		Running: ss.isRunning,
+		Syncing: ss.syncing,
+	}
+	ss.mu.RUnlock()
+
+	checkpoint, err := ss.loadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	status.Checkpoint = checkpoint
+
+	status.LastResult, err = ss.GetLastSyncResult()
+	if err != nil && !strings.Contains(err.Error(), "no such table") {
+		return nil, err
+	}
+
+	// Querying /rate_limit does not count against the quota
+	if !ss.client.RateLimit().Known {
+		if _, err := ss.client.GetRateLimit(); err != nil {
+			slog.Warn("Failed to get GitHub rate limit", "error", err)
+		}
+	}
+	status.RateLimit = ss.client.RateLimit()
+
+	return status, nil
+}
+
 // GetLastSyncResult returns the last sync result
 func (ss *SyncService) GetLastSyncResult() (*SyncResult, error) {
+	return LastSyncResult(ss.db)
+}
+
+// LastSyncResult returns the result of the most recent sync, or nil before the first
+func LastSyncResult(db *sql.DB) (*SyncResult, error) {
 	var result SyncResult
 	var errorsJSON string
 
-	err := ss.db.QueryRow(`
+	err := db.QueryRow(`
 		SELECT start_time, end_time, duration, repositories_found, templates_created,
 		       templates_updated, templates_deleted, errors, success
 		FROM sync_results ORDER BY start_time DESC LIMIT 1
@@ -189,53 +350,29 @@ func (ss *SyncService) GetLastSyncResult() (*SyncResult, error) {
 }
 
 // syncLoop runs the periodic sync loop
-func (ss *SyncService) syncLoop(interval time.Duration) {
+func (ss *SyncService) syncLoop(interval time.Duration, stopChan chan struct{}) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
+			if maintenance.Active(ss.db) {
+				slog.Info("Skipping periodic sync during maintenance")
+				continue
+			}
 			if _, err := ss.SyncAll(); err != nil {
-				log.Printf("Periodic sync failed: %v", err)
+				slog.Error("Periodic sync failed", "error", err)
 			}
-		case <-ss.stopChan:
+		case <-stopChan:
 			return
 		}
 	}
 }
 
-// getAllRepositories gets all accessible repositories
+// getAllRepositories gets all repositories of the configured discovery sources
 func (ss *SyncService) getAllRepositories() ([]*Repository, error) {
-	var allRepos []*Repository
-	page := 1
-	perPage := 100
-
-	for {
-		repos, err := ss.client.ListRepositories(page, perPage)
-		if err != nil {
-			return nil, err
-		}
-
-		if len(repos) == 0 {
-			break
-		}
-
-		// Filter for repositories that might contain Docker Compose files
-		for _, repo := range repos {
-			if ss.shouldProcessRepository(repo) {
-				allRepos = append(allRepos, repo)
-			}
-		}
-
-		if len(repos) < perPage {
-			break
-		}
-
-		page++
-	}
-
-	return allRepos, nil
+	return ss.sourceRepositories()
 }
 
 // shouldProcessRepository determines if a repository should be processed
@@ -283,17 +420,6 @@ func (ss *SyncService) shouldProcessRepository(repo *Repository) bool {
 
 // processRepository processes a single repository
 func (ss *SyncService) processRepository(repo *Repository, result *SyncResult) error {
-	// Check if repository actually contains docker-compose files
-	owner, repoName := parseOwnerRepo(repo.FullName)
-	isDockerRepo, err := ss.client.IsDockerComposeRepo(owner, repoName)
-	if err != nil {
-		return err
-	}
-
-	if !isDockerRepo {
-		return nil // Skip repositories without docker-compose
-	}
-
 	// Check if template already exists
 	templateID := ss.generateTemplateID(repo.FullName)
 	exists, err := ss.templateExists(templateID)
@@ -301,21 +427,133 @@ func (ss *SyncService) processRepository(repo *Repository, result *SyncResult) e
 		return err
 	}
 
-	// Process the repository
-	if err := ss.repoSvc.processRepository(repo); err != nil {
+	// Process the repository; those without docker-compose files are skipped
+	processed, err := ss.repoSvc.processRepository(repo)
+	if err != nil {
 		return err
 	}
+	if !processed {
+		return nil
+	}
 
 	// Update counters
+	action := "created"
 	if exists {
 		result.TemplatesUpdated++
+		action = "updated"
 	} else {
 		result.TemplatesCreated++
 	}
 
+	ss.webhooks.Publish(models.WebhookEventTemplateUpdated, map[string]interface{}{
+		"template_id": templateID,
+		"repository":  repo.FullName,
+		"action":      action,
+	})
+
 	return nil
 }
 
+// withRateLimit runs a GitHub call, waiting for the quota to reset when it is nearly
+// exhausted and retrying with backoff when the call is rate limited. A
+// *RateLimitError is returned when the wait would exceed maxRateLimitWait
+func (ss *SyncService) withRateLimit(fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		if limit := ss.client.RateLimit(); limit.Known && limit.Remaining <= rateLimitReserve {
+			if err := ss.waitUntil(limit.Reset, 0); err != nil {
+				return err
+			}
+		}
+
+		err := fn()
+		var rateLimitErr *RateLimitError
+		if !errors.As(err, &rateLimitErr) || attempt >= maxRateLimitRetries {
+			return err
+		}
+
+		// Secondary limits may be hit repeatedly; back off further on each retry
+		var backoff time.Duration
+		if attempt > 0 {
+			backoff = time.Minute << (attempt - 1)
+		}
+		slog.Warn("GitHub rate limit hit, waiting for reset", "reset", rateLimitErr.Reset)
+		if err := ss.waitUntil(rateLimitErr.Reset, backoff); err != nil {
+			return err
+		}
+	}
+}
+
+// waitUntil sleeps until a rate limit reset, or at least minWait
+func (ss *SyncService) waitUntil(reset time.Time, minWait time.Duration) error {
+	wait := time.Until(reset) + time.Second
+	if wait < minWait {
+		wait = minWait
+	}
+	if wait <= 0 {
+		return nil
+	}
+	if wait > maxRateLimitWait {
+		return &RateLimitError{Reset: reset, Message: "quota resets too late to wait"}
+	}
+
+	ss.mu.RLock()
+	stopChan := ss.stopChan
+	ss.mu.RUnlock()
+
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-stopChan:
+		return &RateLimitError{Reset: reset, Message: "sync stopped while waiting for quota"}
+	}
+}
+
+// isRateLimitError reports whether an error is caused by GitHub rate limiting
+func isRateLimitError(err error) bool {
+	var rateLimitErr *RateLimitError
+	return errors.As(err, &rateLimitErr)
+}
+
+// loadCheckpoint returns the last repository processed by an interrupted sync
+func (ss *SyncService) loadCheckpoint() (string, error) {
+	ss.ensureCheckpointTable()
+
+	var lastRepository string
+	err := ss.db.QueryRow("SELECT last_repository FROM sync_checkpoint WHERE id = 1").Scan(&lastRepository)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return lastRepository, err
+}
+
+// saveCheckpoint records the last processed repository
+func (ss *SyncService) saveCheckpoint(fullName string) error {
+	_, err := ss.db.Exec(`
+		INSERT INTO sync_checkpoint (id, last_repository, updated_at)
+		VALUES (1, $1, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET
+			last_repository = excluded.last_repository,
+			updated_at = excluded.updated_at`, fullName)
+	return err
+}
+
+// clearCheckpoint removes the checkpoint after a complete sync
+func (ss *SyncService) clearCheckpoint() error {
+	_, err := ss.db.Exec("DELETE FROM sync_checkpoint")
+	return err
+}
+
+// ensureCheckpointTable creates the single-row checkpoint table if it doesn't exist
+func (ss *SyncService) ensureCheckpointTable() {
+	ss.db.Exec(`
+		CREATE TABLE IF NOT EXISTS sync_checkpoint (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			last_repository TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+}
+
 // cleanupDeletedRepositories removes templates for deleted repositories
 func (ss *SyncService) cleanupDeletedRepositories() (int, error) {
 	if err := ss.repoSvc.CleanupDeletedRepositories(); err != nil {
@@ -373,7 +611,7 @@ func (ss *SyncService) saveSyncResult(result *SyncResult) {
 		string(errorsJSON), result.Success)
 
 	if err != nil {
-		log.Printf("Failed to save sync result: %v", err)
+		slog.Error("Failed to save sync result", "error", err)
 	}
 
 	// Clean up old sync results (keep last 10)
@@ -0,0 +1,95 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+)
+
+// composeFileNames are the docker-compose file names looked for, in order of preference
+var composeFileNames = []string{
+	"docker-compose.yml",
+	"docker-compose.yaml",
+	"compose.yml",
+	"compose.yaml",
+}
+
+// templateConfigFileNames are the template configuration file names, in order of preference
+var templateConfigFileNames = []string{
+	".template.json",
+	"template.json",
+	".docker-deploy.json",
+}
+
+// TreeEntry is a file or directory in a repository tree
+type TreeEntry struct {
+	Path string `json:"path"`
+	Type string `json:"type"` // blob or tree
+	SHA  string `json:"sha"`
+	Size int    `json:"size"`
+}
+
+// Tree is the recursive file listing of a repository at a ref
+type Tree struct {
+	SHA       string      `json:"sha"`
+	Entries   []TreeEntry `json:"tree"`
+	Truncated bool        `json:"truncated"`
+
+	files map[string]bool
+}
+
+// GetTree lists all files of a repository in a single request
+func (c *Client) GetTree(owner, repo, ref string) (*Tree, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	url := fmt.Sprintf("/repos/%s/%s/git/trees/%s?recursive=1", owner, repo, ref)
+
+	var tree Tree
+	if err := c.makeRequest("GET", url, nil, &tree); err != nil {
+		return nil, err
+	}
+
+	tree.files = make(map[string]bool, len(tree.Entries))
+	for _, entry := range tree.Entries {
+		if entry.Type == "blob" {
+			tree.files[entry.Path] = true
+		}
+	}
+	return &tree, nil
+}
+
+// Has reports whether a file exists in the tree
+func (t *Tree) Has(path string) bool {
+	return t.files[strings.TrimPrefix(path, "/")]
+}
+
+// Entry returns the tree entry of a file
+func (t *Tree) Entry(path string) (TreeEntry, bool) {
+	path = strings.TrimPrefix(path, "/")
+	for _, entry := range t.Entries {
+		if entry.Type == "blob" && entry.Path == path {
+			return entry, true
+		}
+	}
+	return TreeEntry{}, false
+}
+
+// FindFirst returns the first of the given files present under dir, or ""
+func (t *Tree) FindFirst(dir string, names ...string) string {
+	dir = strings.Trim(dir, "/")
+	for _, name := range names {
+		path := name
+		if dir != "" {
+			path = dir + "/" + name
+		}
+		if t.Has(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// ComposeFile returns the path of the root docker-compose file, or ""
+func (t *Tree) ComposeFile() string {
+	return t.FindFirst("", composeFileNames...)
+}
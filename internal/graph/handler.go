@@ -0,0 +1,20 @@
+//go:build graphql
+
+package graph
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+)
+
+// NewHandler serves GraphQL queries sent by GET or POST
+func NewHandler(db *sql.DB, dockerClient *client.Client, config *config.Config) http.Handler {
+	server := handler.NewDefaultServer(NewExecutableSchema(Config{Resolvers: NewResolver(db, dockerClient, config)}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.ServeHTTP(w, r.WithContext(WithStackCache(r.Context())))
+	})
+}
@@ -0,0 +1,19 @@
+//go:build !graphql
+
+package graph
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+)
+
+// NewHandler answers every query with 501: the executable schema is generated
+// by gqlgen, so it is only built with the graphql tag
+func NewHandler(db *sql.DB, dockerClient *client.Client, config *config.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "GraphQL not available in this build", http.StatusNotImplemented)
+	})
+}
@@ -0,0 +1,110 @@
+package graph
+
+//go:generate go run github.com/99designs/gqlgen generate --config gqlgen.yml
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/docker/docker/client"
+	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/analytics"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/models"
+	"docker-deploy-app/internal/newt"
+)
+
+// Resolver serves the GraphQL schema from the same tables and Docker queries as
+// the REST handlers. Deployments are only visible to viewers of their project
+type Resolver struct {
+	db          *sql.DB
+	containers  *docker.Client
+	newtStatus  *newt.StatusCollector
+	stats       *analytics.Recorder
+	authEnabled bool
+}
+
+// NewResolver creates the root resolver
+func NewResolver(db *sql.DB, dockerClient *client.Client, config *config.Config) *Resolver {
+	return &Resolver{
+		db:          db,
+		containers:  docker.WrapClient(dockerClient),
+		newtStatus:  newt.NewStatusCollector(db, dockerClient),
+		stats:       analytics.NewRecorder(db),
+		authEnabled: config.Security.AuthEnabled,
+	}
+}
+
+// canView reports whether the user of a request may see the deployments of a project
+func (r *Resolver) canView(ctx context.Context, projectID string) bool {
+	if !r.authEnabled {
+		return true
+	}
+	user := apiMiddleware.UserFromContext(ctx)
+	return user != nil && apiMiddleware.HasProjectRole(r.db, user, projectID, "viewer")
+}
+
+// visibleDeployments drops the deployments the user of a request may not see
+func (r *Resolver) visibleDeployments(ctx context.Context, deployments []*models.Deployment) []*models.Deployment {
+	visible := deployments[:0]
+	for _, d := range deployments {
+		if r.canView(ctx, d.ProjectID) {
+			visible = append(visible, d)
+		}
+	}
+	return visible
+}
+
+type stackCacheKey struct{}
+
+// stackCache holds the container state of every stack for one request, so a
+// query of many deployments with their stacks lists containers once
+type stackCache struct {
+	once      sync.Once
+	summaries map[string]*models.StackSummary
+	err       error
+}
+
+// WithStackCache prepares a request context for resolving stacks
+func WithStackCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stackCacheKey{}, &stackCache{})
+}
+
+// stackSummaries returns the container state of every stack by stack name
+func (r *Resolver) stackSummaries(ctx context.Context) (map[string]*models.StackSummary, error) {
+	cache, ok := ctx.Value(stackCacheKey{}).(*stackCache)
+	if !ok {
+		return r.containers.SummarizeStacks(ctx)
+	}
+	cache.once.Do(func() {
+		cache.summaries, cache.err = r.containers.SummarizeStacks(ctx)
+	})
+	return cache.summaries, cache.err
+}
+
+// fillStackState copies the container state of a stack from the summaries of all
+// stacks; a stack without containers is stopped
+func fillStackState(stack *models.StackSummary, summaries map[string]*models.StackSummary) {
+	stack.Status = models.StackStatusStopped
+	if c, ok := summaries[stack.Name]; ok {
+		stack.Status = c.Status
+		stack.Services, stack.RunningServices = c.Services, c.RunningServices
+		stack.Containers, stack.RunningContainers, stack.Unhealthy = c.Containers, c.RunningContainers, c.Unhealthy
+	}
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func intValue(value *int, fallback int) int {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
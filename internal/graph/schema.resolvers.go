@@ -0,0 +1,163 @@
+//go:build graphql
+
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver
+// implementations will be copied through when generating and any unknown code
+// will be moved to the end.
+
+import (
+	"context"
+	"fmt"
+
+	"docker-deploy-app/internal/models"
+)
+
+// Templates is the resolver for the templates field.
+func (r *queryResolver) Templates(ctx context.Context, category *string, search *string, verified *bool, limit *int, offset *int) ([]*models.Template, error) {
+	return r.listTemplates(templateFilter{
+		category: stringValue(category),
+		search:   stringValue(search),
+		verified: verified != nil && *verified,
+		limit:    intValue(limit, 50),
+		offset:   intValue(offset, 0),
+	})
+}
+
+// Template is the resolver for the template field.
+func (r *queryResolver) Template(ctx context.Context, id string) (*models.Template, error) {
+	return r.getTemplate(id)
+}
+
+// Deployments is the resolver for the deployments field.
+func (r *queryResolver) Deployments(ctx context.Context, status *string, projectID *string, limit *int, offset *int) ([]*models.Deployment, error) {
+	deployments, err := r.listDeployments(deploymentFilter{
+		status:    stringValue(status),
+		projectID: stringValue(projectID),
+		limit:     intValue(limit, 50),
+		offset:    intValue(offset, 0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.visibleDeployments(ctx, deployments), nil
+}
+
+// Deployment is the resolver for the deployment field.
+func (r *queryResolver) Deployment(ctx context.Context, id string) (*models.Deployment, error) {
+	deployment, err := r.getDeployment(id)
+	if err != nil || deployment == nil {
+		return nil, err
+	}
+	if !r.canView(ctx, deployment.ProjectID) {
+		return nil, fmt.Errorf("insufficient project role")
+	}
+	return deployment, nil
+}
+
+// Stacks is the resolver for the stacks field.
+func (r *queryResolver) Stacks(ctx context.Context, projectID *string) ([]*models.StackSummary, error) {
+	stacks, err := r.listStacks(stringValue(projectID))
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := r.stackSummaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := stacks[:0]
+	for _, stack := range stacks {
+		if r.canView(ctx, stack.ProjectID) {
+			fillStackState(stack, summaries)
+			visible = append(visible, stack)
+		}
+	}
+	return visible, nil
+}
+
+// Backups is the resolver for the backups field.
+func (r *queryResolver) Backups(ctx context.Context, limit *int) ([]*models.Backup, error) {
+	return r.listBackups("", intValue(limit, 50))
+}
+
+// Events is the resolver for the events field.
+func (r *queryResolver) Events(ctx context.Context, deploymentID string, limit *int) ([]*models.StackEvent, error) {
+	deployment, err := r.getDeployment(deploymentID)
+	if err != nil || deployment == nil {
+		return []*models.StackEvent{}, err
+	}
+	if !r.canView(ctx, deployment.ProjectID) {
+		return nil, fmt.Errorf("insufficient project role")
+	}
+	return r.listEvents(deploymentID, intValue(limit, 100))
+}
+
+// Stats is the resolver for the stats field.
+func (r *templateResolver) Stats(ctx context.Context, obj *models.Template) (*models.TemplateStats, error) {
+	return r.stats.TemplateStats(obj.ID)
+}
+
+// Deployments is the resolver for the deployments field.
+func (r *templateResolver) Deployments(ctx context.Context, obj *models.Template, limit *int) ([]*models.Deployment, error) {
+	deployments, err := r.listDeployments(deploymentFilter{templateID: obj.ID, limit: intValue(limit, 20)})
+	if err != nil {
+		return nil, err
+	}
+	return r.visibleDeployments(ctx, deployments), nil
+}
+
+// Template is the resolver for the template field.
+func (r *deploymentResolver) Template(ctx context.Context, obj *models.Deployment) (*models.Template, error) {
+	return r.getTemplate(obj.TemplateID)
+}
+
+// Stack is the resolver for the stack field.
+func (r *deploymentResolver) Stack(ctx context.Context, obj *models.Deployment) (*models.StackSummary, error) {
+	summaries, err := r.stackSummaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stack := &models.StackSummary{
+		ID:           obj.ID,
+		Name:         obj.StackName,
+		ProjectID:    obj.ProjectID,
+		DeployMode:   obj.DeployMode,
+		NewtInjected: obj.NewtInjected,
+		TunnelURL:    obj.TunnelURL,
+		CreatedAt:    obj.CreatedAt,
+	}
+	fillStackState(stack, summaries)
+	return stack, nil
+}
+
+// NewtStatus is the resolver for the newtStatus field.
+func (r *deploymentResolver) NewtStatus(ctx context.Context, obj *models.Deployment) (*models.NewtStatus, error) {
+	if !obj.NewtInjected {
+		return nil, nil
+	}
+	return r.newtStatus.Collect(obj.ID, obj.StackName)
+}
+
+// Backups is the resolver for the backups field.
+func (r *deploymentResolver) Backups(ctx context.Context, obj *models.Deployment, limit *int) ([]*models.Backup, error) {
+	return r.listBackups(obj.ID, intValue(limit, 10))
+}
+
+// Events is the resolver for the events field.
+func (r *deploymentResolver) Events(ctx context.Context, obj *models.Deployment, limit *int) ([]*models.StackEvent, error) {
+	return r.listEvents(obj.ID, intValue(limit, 50))
+}
+
+// Deployment returns DeploymentResolver implementation.
+func (r *Resolver) Deployment() DeploymentResolver { return &deploymentResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// Template returns TemplateResolver implementation.
+func (r *Resolver) Template() TemplateResolver { return &templateResolver{r} }
+
+type deploymentResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type templateResolver struct{ *Resolver }
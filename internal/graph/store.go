@@ -0,0 +1,258 @@
+package graph
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"docker-deploy-app/internal/models"
+)
+
+const templateColumns = `
+	id, name, COALESCE(description, ''), COALESCE(icon, ''), COALESCE(category, ''), COALESCE(tags, '[]'),
+	COALESCE(repo_url, ''), COALESCE(version, ''), requires_newt, COALESCE(publisher_id, ''), is_verified,
+	COALESCE(security_badge, 'unscanned'), download_count, unique_installs, avg_rating, total_ratings,
+	created_at, updated_at`
+
+const deploymentColumns = `
+	id, template_id, stack_name, COALESCE(project_id, 'global'), status, deploy_mode,
+	COALESCE(update_policy, 'pinned'), COALESCE(config, ''), newt_injected, COALESCE(tunnel_provider, 'newt'),
+	COALESCE(tunnel_url, ''), COALESCE(resource_version, 1), created_at, updated_at`
+
+// templateFilter narrows a template listing
+type templateFilter struct {
+	category string
+	search   string
+	verified bool
+	limit    int
+	offset   int
+}
+
+func (r *Resolver) listTemplates(filter templateFilter) ([]*models.Template, error) {
+	query := "SELECT" + templateColumns + " FROM templates WHERE 1=1"
+	var args []interface{}
+	if filter.category != "" {
+		args = append(args, filter.category)
+		query += fmt.Sprintf(" AND category = $%d", len(args))
+	}
+	if filter.search != "" {
+		args = append(args, "%"+filter.search+"%")
+		query += fmt.Sprintf(" AND (name LIKE $%d OR description LIKE $%d)", len(args), len(args))
+	}
+	if filter.verified {
+		query += " AND is_verified = true"
+	}
+	args = append(args, filter.limit, filter.offset)
+	query += fmt.Sprintf(" ORDER BY avg_rating DESC, unique_installs DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := []*models.Template{}
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// getTemplate returns a template, or nil if there is none with the ID
+func (r *Resolver) getTemplate(id string) (*models.Template, error) {
+	t, err := scanTemplate(r.db.QueryRow("SELECT"+templateColumns+" FROM templates WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// deploymentFilter narrows a deployment listing
+type deploymentFilter struct {
+	status     string
+	projectID  string
+	templateID string
+	limit      int
+	offset     int
+}
+
+func (r *Resolver) listDeployments(filter deploymentFilter) ([]*models.Deployment, error) {
+	query := "SELECT" + deploymentColumns + " FROM deployments WHERE 1=1"
+	var args []interface{}
+	if filter.status != "" {
+		args = append(args, filter.status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	if filter.projectID != "" {
+		args = append(args, filter.projectID)
+		query += fmt.Sprintf(" AND COALESCE(project_id, 'global') = $%d", len(args))
+	}
+	if filter.templateID != "" {
+		args = append(args, filter.templateID)
+		query += fmt.Sprintf(" AND template_id = $%d", len(args))
+	}
+	args = append(args, filter.limit, filter.offset)
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deployments := []*models.Deployment{}
+	for rows.Next() {
+		d, err := scanDeployment(rows)
+		if err != nil {
+			return nil, err
+		}
+		deployments = append(deployments, d)
+	}
+	return deployments, rows.Err()
+}
+
+// getDeployment returns a deployment, or nil if there is none with the ID
+func (r *Resolver) getDeployment(id string) (*models.Deployment, error) {
+	d, err := scanDeployment(r.db.QueryRow("SELECT"+deploymentColumns+" FROM deployments WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// listStacks returns the stacks of deployments with their database fields; the
+// container state is filled in from a stack summary
+func (r *Resolver) listStacks(projectID string) ([]*models.StackSummary, error) {
+	query := `
+		SELECT d.id, d.stack_name, COALESCE(d.project_id, 'global'), d.deploy_mode, d.newt_injected,
+		       COALESCE(d.tunnel_url, ''), d.created_at, COALESCE(t.name, ''),
+		       EXISTS(SELECT 1 FROM restart_loops l WHERE l.deployment_id = d.id AND l.cleared_at IS NULL)
+		FROM deployments d
+		LEFT JOIN templates t ON d.template_id = t.id`
+	var args []interface{}
+	if projectID != "" {
+		query += " WHERE COALESCE(d.project_id, 'global') = $1"
+		args = append(args, projectID)
+	}
+	query += " ORDER BY d.created_at DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stacks := []*models.StackSummary{}
+	for rows.Next() {
+		var s models.StackSummary
+		if err := rows.Scan(&s.ID, &s.Name, &s.ProjectID, &s.DeployMode, &s.NewtInjected,
+			&s.TunnelURL, &s.CreatedAt, &s.TemplateName, &s.Degraded); err != nil {
+			return nil, err
+		}
+		stacks = append(stacks, &s)
+	}
+	return stacks, rows.Err()
+}
+
+// listBackups returns the latest backups, of one deployment when deploymentID is set
+func (r *Resolver) listBackups(deploymentID string, limit int) ([]*models.Backup, error) {
+	query := `
+		SELECT id, name, type, status, size_bytes, include_volumes, encrypted,
+		       COALESCE(deployment_ids, '[]'), created_at, completed_at
+		FROM backups`
+	var args []interface{}
+	if deploymentID != "" {
+		query += " WHERE EXISTS (SELECT 1 FROM json_each(backups.deployment_ids) WHERE value = $1)"
+		args = append(args, deploymentID)
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	backups := []*models.Backup{}
+	for rows.Next() {
+		var b models.Backup
+		var deploymentIDs string
+		var completedAt sql.NullTime
+		if err := rows.Scan(&b.ID, &b.Name, &b.Type, &b.Status, &b.SizeBytes, &b.IncludeVolumes, &b.Encrypted,
+			&deploymentIDs, &b.CreatedAt, &completedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(deploymentIDs), &b.DeploymentIDs)
+		if completedAt.Valid {
+			b.CompletedAt = &completedAt.Time
+		}
+		backups = append(backups, &b)
+	}
+	return backups, rows.Err()
+}
+
+// listEvents returns the latest container events of a deployment
+func (r *Resolver) listEvents(deploymentID string, limit int) ([]*models.StackEvent, error) {
+	rows, err := r.db.Query(`
+		SELECT id, deployment_id, COALESCE(service_name, ''), container_id, action, exit_code,
+		       COALESCE(image, ''), created_at
+		FROM stack_events
+		WHERE deployment_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`, deploymentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*models.StackEvent{}
+	for rows.Next() {
+		var e models.StackEvent
+		var exitCode sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.DeploymentID, &e.ServiceName, &e.ContainerID, &e.Action, &exitCode,
+			&e.Image, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			e.ExitCode = &code
+		}
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTemplate(row rowScanner) (*models.Template, error) {
+	var t models.Template
+	var tagsJSON string
+	if err := row.Scan(&t.ID, &t.Name, &t.Description, &t.Icon, &t.Category, &tagsJSON,
+		&t.RepoURL, &t.Version, &t.RequiresNewt, &t.PublisherID, &t.IsVerified,
+		&t.SecurityBadge, &t.DownloadCount, &t.UniqueInstalls, &t.AvgRating, &t.TotalRatings,
+		&t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	t.UnmarshalTags(tagsJSON)
+	return &t, nil
+}
+
+func scanDeployment(row rowScanner) (*models.Deployment, error) {
+	var d models.Deployment
+	var configJSON string
+	if err := row.Scan(&d.ID, &d.TemplateID, &d.StackName, &d.ProjectID, &d.Status, &d.DeployMode,
+		&d.UpdatePolicy, &configJSON, &d.NewtInjected, &d.TunnelProvider,
+		&d.TunnelURL, &d.ResourceVersion, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	d.UnmarshalConfig(configJSON)
+	return &d, nil
+}
@@ -0,0 +1,124 @@
+//go:build grpc
+
+package grpcapi
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	apiMiddleware "docker-deploy-app/internal/api/middleware"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/maintenance"
+	"docker-deploy-app/internal/models"
+)
+
+// authenticator authenticates calls with the API keys of the REST API and checks
+// project roles as the route middleware does
+type authenticator struct {
+	db      *sql.DB
+	enabled bool
+	apiKey  string
+}
+
+func newAuthenticator(db *sql.DB, security config.SecurityConfig) *authenticator {
+	return &authenticator{
+		db:      db,
+		enabled: security.AuthEnabled,
+		apiKey:  security.APIKey,
+	}
+}
+
+// authenticate adds the user of the call's authorization or x-api-key metadata
+// to its context. Metadata keys are lower case, which http.Header accepts
+func (a *authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	if !a.enabled {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	r := &http.Request{Header: http.Header{}}
+	for _, key := range []string{"authorization", "x-api-key"} {
+		if values := md.Get(key); len(values) > 0 {
+			r.Header.Set(key, values[0])
+		}
+	}
+
+	user := apiMiddleware.AuthenticateRequest(r, a.db, a.apiKey)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	return context.WithValue(ctx, apiMiddleware.UserKey, user), nil
+}
+
+func (a *authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticatedStream carries the context with the user to a streaming call
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// requireRole checks the caller's role in a project
+func (a *authenticator) requireRole(ctx context.Context, projectID, role string) error {
+	if !a.enabled {
+		return nil
+	}
+	user := apiMiddleware.UserFromContext(ctx)
+	if user == nil {
+		return status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	if !apiMiddleware.HasProjectRole(a.db, user, projectID, role) {
+		return status.Error(codes.PermissionDenied, "Forbidden")
+	}
+	return nil
+}
+
+// requireDeploymentRole checks the caller's role in the project of a deployment
+// and returns the deployment's stack name and mode
+func (a *authenticator) requireDeploymentRole(ctx context.Context, deploymentID, role string) (string, models.DeployMode, error) {
+	var projectID, stackName string
+	var deployMode models.DeployMode
+	err := a.db.QueryRow("SELECT COALESCE(project_id, 'global'), stack_name, deploy_mode FROM deployments WHERE id = $1",
+		deploymentID).Scan(&projectID, &stackName, &deployMode)
+	if err == sql.ErrNoRows {
+		return "", "", status.Error(codes.NotFound, "Deployment not found")
+	}
+	if err != nil {
+		return "", "", status.Errorf(codes.Internal, "Database error: %v", err)
+	}
+	if err := a.requireRole(ctx, projectID, role); err != nil {
+		return "", "", err
+	}
+	return stackName, deployMode, nil
+}
+
+// requireWritable refuses changes during a maintenance window, as the REST API does
+func (a *authenticator) requireWritable() error {
+	if maintenance.Active(a.db) {
+		return status.Error(codes.Unavailable, "Service under maintenance")
+	}
+	return nil
+}
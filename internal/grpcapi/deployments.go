@@ -0,0 +1,125 @@
+//go:build grpc
+
+package grpcapi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	deployv1 "docker-deploy-app/internal/grpcapi/gen/dockerdeploy/v1"
+	"docker-deploy-app/internal/models"
+)
+
+// deploymentService implements DeploymentService
+type deploymentService struct {
+	deployv1.UnimplementedDeploymentServiceServer
+	db   *sql.DB
+	auth *authenticator
+}
+
+const deploymentColumns = `
+	id, template_id, stack_name, COALESCE(project_id, 'global'), status, deploy_mode,
+	COALESCE(update_policy, 'pinned'), COALESCE(config, ''), newt_injected, COALESCE(tunnel_provider, 'newt'),
+	COALESCE(tunnel_url, ''), COALESCE(resource_version, 1), created_at, updated_at`
+
+// ListDeployments returns the deployments the caller can view, filtered as
+// GET /api/deployments filters them
+func (s *deploymentService) ListDeployments(ctx context.Context, req *deployv1.ListDeploymentsRequest) (*deployv1.ListDeploymentsResponse, error) {
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := "SELECT" + deploymentColumns + " FROM deployments WHERE 1=1"
+	var args []interface{}
+	if req.GetStatus() != "" {
+		args = append(args, req.GetStatus())
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	if req.GetProject() != "" {
+		args = append(args, req.GetProject())
+		query += fmt.Sprintf(" AND COALESCE(project_id, 'global') = $%d", len(args))
+	}
+	args = append(args, limit, req.GetOffset())
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Database error: %v", err)
+	}
+	defer rows.Close()
+
+	response := &deployv1.ListDeploymentsResponse{}
+	for rows.Next() {
+		d, err := scanDeployment(rows)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Database error: %v", err)
+		}
+		if s.auth.requireRole(ctx, d.ProjectID, "viewer") != nil {
+			continue
+		}
+		response.Deployments = append(response.Deployments, deploymentMessage(d))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, status.Errorf(codes.Internal, "Database error: %v", err)
+	}
+	return response, nil
+}
+
+// GetDeployment returns one deployment
+func (s *deploymentService) GetDeployment(ctx context.Context, req *deployv1.GetDeploymentRequest) (*deployv1.Deployment, error) {
+	if _, _, err := s.auth.requireDeploymentRole(ctx, req.GetId(), "viewer"); err != nil {
+		return nil, err
+	}
+
+	d, err := scanDeployment(s.db.QueryRow("SELECT"+deploymentColumns+" FROM deployments WHERE id = $1", req.GetId()))
+	if err == sql.ErrNoRows {
+		return nil, status.Error(codes.NotFound, "Deployment not found")
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Database error: %v", err)
+	}
+	return deploymentMessage(d), nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeployment(row rowScanner) (*models.Deployment, error) {
+	var d models.Deployment
+	var configJSON string
+	if err := row.Scan(&d.ID, &d.TemplateID, &d.StackName, &d.ProjectID, &d.Status, &d.DeployMode,
+		&d.UpdatePolicy, &configJSON, &d.NewtInjected, &d.TunnelProvider,
+		&d.TunnelURL, &d.ResourceVersion, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	d.UnmarshalConfig(configJSON)
+	return &d, nil
+}
+
+func deploymentMessage(d *models.Deployment) *deployv1.Deployment {
+	// Configs come from JSON, so every value converts
+	config, _ := structpb.NewStruct(d.Config)
+	return &deployv1.Deployment{
+		Id:              d.ID,
+		TemplateId:      d.TemplateID,
+		StackName:       d.StackName,
+		ProjectId:       d.ProjectID,
+		Status:          string(d.Status),
+		DeployMode:      string(d.DeployMode),
+		UpdatePolicy:    string(d.UpdatePolicy),
+		Config:          config,
+		NewtInjected:    d.NewtInjected,
+		TunnelProvider:  string(d.TunnelProvider),
+		TunnelUrl:       d.TunnelURL,
+		ResourceVersion: int32(d.ResourceVersion),
+		CreatedAt:       timestamppb.New(d.CreatedAt),
+		UpdatedAt:       timestamppb.New(d.UpdatedAt),
+	}
+}
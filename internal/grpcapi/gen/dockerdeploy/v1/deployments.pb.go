@@ -0,0 +1,436 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: dockerdeploy/v1/deployments.proto
+
+package deployv1
+
+import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Deployment struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Id         string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	TemplateId string                 `protobuf:"bytes,2,opt,name=template_id,json=templateId,proto3" json:"template_id,omitempty"`
+	StackName  string                 `protobuf:"bytes,3,opt,name=stack_name,json=stackName,proto3" json:"stack_name,omitempty"`
+	ProjectId  string                 `protobuf:"bytes,4,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	// pending, deploying, running, stopped or failed
+	Status string `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	// compose or swarm
+	DeployMode      string                 `protobuf:"bytes,6,opt,name=deploy_mode,json=deployMode,proto3" json:"deploy_mode,omitempty"`
+	UpdatePolicy    string                 `protobuf:"bytes,7,opt,name=update_policy,json=updatePolicy,proto3" json:"update_policy,omitempty"`
+	Config          *structpb.Struct       `protobuf:"bytes,8,opt,name=config,proto3" json:"config,omitempty"`
+	NewtInjected    bool                   `protobuf:"varint,9,opt,name=newt_injected,json=newtInjected,proto3" json:"newt_injected,omitempty"`
+	TunnelProvider  string                 `protobuf:"bytes,10,opt,name=tunnel_provider,json=tunnelProvider,proto3" json:"tunnel_provider,omitempty"`
+	TunnelUrl       string                 `protobuf:"bytes,11,opt,name=tunnel_url,json=tunnelUrl,proto3" json:"tunnel_url,omitempty"`
+	ResourceVersion int32                  `protobuf:"varint,12,opt,name=resource_version,json=resourceVersion,proto3" json:"resource_version,omitempty"`
+	CreatedAt       *timestamppb.Timestamp `protobuf:"bytes,13,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt       *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Deployment) Reset() {
+	*x = Deployment{}
+	mi := &file_dockerdeploy_v1_deployments_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Deployment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Deployment) ProtoMessage() {}
+
+func (x *Deployment) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_deployments_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Deployment.ProtoReflect.Descriptor instead.
+func (*Deployment) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_deployments_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Deployment) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Deployment) GetTemplateId() string {
+	if x != nil {
+		return x.TemplateId
+	}
+	return ""
+}
+
+func (x *Deployment) GetStackName() string {
+	if x != nil {
+		return x.StackName
+	}
+	return ""
+}
+
+func (x *Deployment) GetProjectId() string {
+	if x != nil {
+		return x.ProjectId
+	}
+	return ""
+}
+
+func (x *Deployment) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Deployment) GetDeployMode() string {
+	if x != nil {
+		return x.DeployMode
+	}
+	return ""
+}
+
+func (x *Deployment) GetUpdatePolicy() string {
+	if x != nil {
+		return x.UpdatePolicy
+	}
+	return ""
+}
+
+func (x *Deployment) GetConfig() *structpb.Struct {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+func (x *Deployment) GetNewtInjected() bool {
+	if x != nil {
+		return x.NewtInjected
+	}
+	return false
+}
+
+func (x *Deployment) GetTunnelProvider() string {
+	if x != nil {
+		return x.TunnelProvider
+	}
+	return ""
+}
+
+func (x *Deployment) GetTunnelUrl() string {
+	if x != nil {
+		return x.TunnelUrl
+	}
+	return ""
+}
+
+func (x *Deployment) GetResourceVersion() int32 {
+	if x != nil {
+		return x.ResourceVersion
+	}
+	return 0
+}
+
+func (x *Deployment) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Deployment) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type ListDeploymentsRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Status string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	// Maps to ?project=; deployments outside a project are in "global"
+	Project string `protobuf:"bytes,2,opt,name=project,proto3" json:"project,omitempty"`
+	// Defaults to 50
+	Limit         int32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset        int32 `protobuf:"varint,4,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDeploymentsRequest) Reset() {
+	*x = ListDeploymentsRequest{}
+	mi := &file_dockerdeploy_v1_deployments_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDeploymentsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDeploymentsRequest) ProtoMessage() {}
+
+func (x *ListDeploymentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_deployments_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDeploymentsRequest.ProtoReflect.Descriptor instead.
+func (*ListDeploymentsRequest) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_deployments_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListDeploymentsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ListDeploymentsRequest) GetProject() string {
+	if x != nil {
+		return x.Project
+	}
+	return ""
+}
+
+func (x *ListDeploymentsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListDeploymentsRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type ListDeploymentsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Deployments   []*Deployment          `protobuf:"bytes,1,rep,name=deployments,proto3" json:"deployments,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDeploymentsResponse) Reset() {
+	*x = ListDeploymentsResponse{}
+	mi := &file_dockerdeploy_v1_deployments_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDeploymentsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDeploymentsResponse) ProtoMessage() {}
+
+func (x *ListDeploymentsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_deployments_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDeploymentsResponse.ProtoReflect.Descriptor instead.
+func (*ListDeploymentsResponse) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_deployments_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListDeploymentsResponse) GetDeployments() []*Deployment {
+	if x != nil {
+		return x.Deployments
+	}
+	return nil
+}
+
+type GetDeploymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDeploymentRequest) Reset() {
+	*x = GetDeploymentRequest{}
+	mi := &file_dockerdeploy_v1_deployments_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDeploymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDeploymentRequest) ProtoMessage() {}
+
+func (x *GetDeploymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_deployments_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDeploymentRequest.ProtoReflect.Descriptor instead.
+func (*GetDeploymentRequest) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_deployments_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetDeploymentRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+var File_dockerdeploy_v1_deployments_proto protoreflect.FileDescriptor
+
+const file_dockerdeploy_v1_deployments_proto_rawDesc = "" +
+	"\n" +
+	"!dockerdeploy/v1/deployments.proto\x12\x0fdockerdeploy.v1\x1a\x1cgoogle/api/annotations.proto\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x98\x04\n" +
+	"\n" +
+	"Deployment\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1f\n" +
+	"\vtemplate_id\x18\x02 \x01(\tR\n" +
+	"templateId\x12\x1d\n" +
+	"\n" +
+	"stack_name\x18\x03 \x01(\tR\tstackName\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\x04 \x01(\tR\tprojectId\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12\x1f\n" +
+	"\vdeploy_mode\x18\x06 \x01(\tR\n" +
+	"deployMode\x12#\n" +
+	"\rupdate_policy\x18\a \x01(\tR\fupdatePolicy\x12/\n" +
+	"\x06config\x18\b \x01(\v2\x17.google.protobuf.StructR\x06config\x12#\n" +
+	"\rnewt_injected\x18\t \x01(\bR\fnewtInjected\x12'\n" +
+	"\x0ftunnel_provider\x18\n" +
+	" \x01(\tR\x0etunnelProvider\x12\x1d\n" +
+	"\n" +
+	"tunnel_url\x18\v \x01(\tR\ttunnelUrl\x12)\n" +
+	"\x10resource_version\x18\f \x01(\x05R\x0fresourceVersion\x129\n" +
+	"\n" +
+	"created_at\x18\r \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"x\n" +
+	"\x16ListDeploymentsRequest\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
+	"\aproject\x18\x02 \x01(\tR\aproject\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06offset\x18\x04 \x01(\x05R\x06offset\"X\n" +
+	"\x17ListDeploymentsResponse\x12=\n" +
+	"\vdeployments\x18\x01 \x03(\v2\x1b.dockerdeploy.v1.DeploymentR\vdeployments\"&\n" +
+	"\x14GetDeploymentRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id2\x87\x02\n" +
+	"\x11DeploymentService\x12~\n" +
+	"\x0fListDeployments\x12'.dockerdeploy.v1.ListDeploymentsRequest\x1a(.dockerdeploy.v1.ListDeploymentsResponse\"\x18\x82\xd3\xe4\x93\x02\x12\x12\x10/api/deployments\x12r\n" +
+	"\rGetDeployment\x12%.dockerdeploy.v1.GetDeploymentRequest\x1a\x1b.dockerdeploy.v1.Deployment\"\x1d\x82\xd3\xe4\x93\x02\x17\x12\x15/api/deployments/{id}BAZ?docker-deploy-app/internal/grpcapi/gen/dockerdeploy/v1;deployv1b\x06proto3"
+
+var (
+	file_dockerdeploy_v1_deployments_proto_rawDescOnce sync.Once
+	file_dockerdeploy_v1_deployments_proto_rawDescData []byte
+)
+
+func file_dockerdeploy_v1_deployments_proto_rawDescGZIP() []byte {
+	file_dockerdeploy_v1_deployments_proto_rawDescOnce.Do(func() {
+		file_dockerdeploy_v1_deployments_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_dockerdeploy_v1_deployments_proto_rawDesc), len(file_dockerdeploy_v1_deployments_proto_rawDesc)))
+	})
+	return file_dockerdeploy_v1_deployments_proto_rawDescData
+}
+
+var file_dockerdeploy_v1_deployments_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_dockerdeploy_v1_deployments_proto_goTypes = []any{
+	(*Deployment)(nil),              // 0: dockerdeploy.v1.Deployment
+	(*ListDeploymentsRequest)(nil),  // 1: dockerdeploy.v1.ListDeploymentsRequest
+	(*ListDeploymentsResponse)(nil), // 2: dockerdeploy.v1.ListDeploymentsResponse
+	(*GetDeploymentRequest)(nil),    // 3: dockerdeploy.v1.GetDeploymentRequest
+	(*structpb.Struct)(nil),         // 4: google.protobuf.Struct
+	(*timestamppb.Timestamp)(nil),   // 5: google.protobuf.Timestamp
+}
+var file_dockerdeploy_v1_deployments_proto_depIdxs = []int32{
+	4, // 0: dockerdeploy.v1.Deployment.config:type_name -> google.protobuf.Struct
+	5, // 1: dockerdeploy.v1.Deployment.created_at:type_name -> google.protobuf.Timestamp
+	5, // 2: dockerdeploy.v1.Deployment.updated_at:type_name -> google.protobuf.Timestamp
+	0, // 3: dockerdeploy.v1.ListDeploymentsResponse.deployments:type_name -> dockerdeploy.v1.Deployment
+	1, // 4: dockerdeploy.v1.DeploymentService.ListDeployments:input_type -> dockerdeploy.v1.ListDeploymentsRequest
+	3, // 5: dockerdeploy.v1.DeploymentService.GetDeployment:input_type -> dockerdeploy.v1.GetDeploymentRequest
+	2, // 6: dockerdeploy.v1.DeploymentService.ListDeployments:output_type -> dockerdeploy.v1.ListDeploymentsResponse
+	0, // 7: dockerdeploy.v1.DeploymentService.GetDeployment:output_type -> dockerdeploy.v1.Deployment
+	6, // [6:8] is the sub-list for method output_type
+	4, // [4:6] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_dockerdeploy_v1_deployments_proto_init() }
+func file_dockerdeploy_v1_deployments_proto_init() {
+	if File_dockerdeploy_v1_deployments_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dockerdeploy_v1_deployments_proto_rawDesc), len(file_dockerdeploy_v1_deployments_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dockerdeploy_v1_deployments_proto_goTypes,
+		DependencyIndexes: file_dockerdeploy_v1_deployments_proto_depIdxs,
+		MessageInfos:      file_dockerdeploy_v1_deployments_proto_msgTypes,
+	}.Build()
+	File_dockerdeploy_v1_deployments_proto = out.File
+	file_dockerdeploy_v1_deployments_proto_goTypes = nil
+	file_dockerdeploy_v1_deployments_proto_depIdxs = nil
+}
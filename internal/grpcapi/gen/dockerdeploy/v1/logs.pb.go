@@ -0,0 +1,391 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: dockerdeploy/v1/logs.proto
+
+package deployv1
+
+import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type DeploymentLog struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// info, warning or error
+	Level         string                 `protobuf:"bytes,2,opt,name=level,proto3" json:"level,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeploymentLog) Reset() {
+	*x = DeploymentLog{}
+	mi := &file_dockerdeploy_v1_logs_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeploymentLog) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeploymentLog) ProtoMessage() {}
+
+func (x *DeploymentLog) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_logs_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeploymentLog.ProtoReflect.Descriptor instead.
+func (*DeploymentLog) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_logs_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DeploymentLog) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *DeploymentLog) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+func (x *DeploymentLog) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *DeploymentLog) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+type GetDeploymentLogsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Comma-separated levels, as ?level= takes them
+	Level string `protobuf:"bytes,2,opt,name=level,proto3" json:"level,omitempty"`
+	// Defaults to 100
+	Limit         int32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDeploymentLogsRequest) Reset() {
+	*x = GetDeploymentLogsRequest{}
+	mi := &file_dockerdeploy_v1_logs_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDeploymentLogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDeploymentLogsRequest) ProtoMessage() {}
+
+func (x *GetDeploymentLogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_logs_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDeploymentLogsRequest.ProtoReflect.Descriptor instead.
+func (*GetDeploymentLogsRequest) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_logs_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetDeploymentLogsRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GetDeploymentLogsRequest) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+func (x *GetDeploymentLogsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type GetDeploymentLogsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Logs          []*DeploymentLog       `protobuf:"bytes,1,rep,name=logs,proto3" json:"logs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDeploymentLogsResponse) Reset() {
+	*x = GetDeploymentLogsResponse{}
+	mi := &file_dockerdeploy_v1_logs_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDeploymentLogsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDeploymentLogsResponse) ProtoMessage() {}
+
+func (x *GetDeploymentLogsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_logs_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDeploymentLogsResponse.ProtoReflect.Descriptor instead.
+func (*GetDeploymentLogsResponse) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_logs_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetDeploymentLogsResponse) GetLogs() []*DeploymentLog {
+	if x != nil {
+		return x.Logs
+	}
+	return nil
+}
+
+type StreamStackLogsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Lines of existing output to send first, 100 by default
+	Tail          int32 `protobuf:"varint,2,opt,name=tail,proto3" json:"tail,omitempty"`
+	Follow        bool  `protobuf:"varint,3,opt,name=follow,proto3" json:"follow,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamStackLogsRequest) Reset() {
+	*x = StreamStackLogsRequest{}
+	mi := &file_dockerdeploy_v1_logs_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamStackLogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamStackLogsRequest) ProtoMessage() {}
+
+func (x *StreamStackLogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_logs_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamStackLogsRequest.ProtoReflect.Descriptor instead.
+func (*StreamStackLogsRequest) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_logs_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamStackLogsRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *StreamStackLogsRequest) GetTail() int32 {
+	if x != nil {
+		return x.Tail
+	}
+	return 0
+}
+
+func (x *StreamStackLogsRequest) GetFollow() bool {
+	if x != nil {
+		return x.Follow
+	}
+	return false
+}
+
+type LogLine struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// As docker compose logs prints it, prefixed with the container name
+	Line          string `protobuf:"bytes,1,opt,name=line,proto3" json:"line,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogLine) Reset() {
+	*x = LogLine{}
+	mi := &file_dockerdeploy_v1_logs_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogLine) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogLine) ProtoMessage() {}
+
+func (x *LogLine) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_logs_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogLine.ProtoReflect.Descriptor instead.
+func (*LogLine) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_logs_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *LogLine) GetLine() string {
+	if x != nil {
+		return x.Line
+	}
+	return ""
+}
+
+var File_dockerdeploy_v1_logs_proto protoreflect.FileDescriptor
+
+const file_dockerdeploy_v1_logs_proto_rawDesc = "" +
+	"\n" +
+	"\x1adockerdeploy/v1/logs.proto\x12\x0fdockerdeploy.v1\x1a\x1cgoogle/api/annotations.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\x89\x01\n" +
+	"\rDeploymentLog\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x14\n" +
+	"\x05level\x18\x02 \x01(\tR\x05level\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x128\n" +
+	"\ttimestamp\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"V\n" +
+	"\x18GetDeploymentLogsRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05level\x18\x02 \x01(\tR\x05level\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\"O\n" +
+	"\x19GetDeploymentLogsResponse\x122\n" +
+	"\x04logs\x18\x01 \x03(\v2\x1e.dockerdeploy.v1.DeploymentLogR\x04logs\"T\n" +
+	"\x16StreamStackLogsRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04tail\x18\x02 \x01(\x05R\x04tail\x12\x16\n" +
+	"\x06follow\x18\x03 \x01(\bR\x06follow\"\x1d\n" +
+	"\aLogLine\x12\x12\n" +
+	"\x04line\x18\x01 \x01(\tR\x04line2\x9b\x02\n" +
+	"\n" +
+	"LogService\x12\x8e\x01\n" +
+	"\x11GetDeploymentLogs\x12).dockerdeploy.v1.GetDeploymentLogsRequest\x1a*.dockerdeploy.v1.GetDeploymentLogsResponse\"\"\x82\xd3\xe4\x93\x02\x1c\x12\x1a/api/deployments/{id}/logs\x12|\n" +
+	"\x0fStreamStackLogs\x12'.dockerdeploy.v1.StreamStackLogsRequest\x1a\x18.dockerdeploy.v1.LogLine\"$\x82\xd3\xe4\x93\x02\x1e\x12\x1c/api/stacks/{id}/logs/stream0\x01BAZ?docker-deploy-app/internal/grpcapi/gen/dockerdeploy/v1;deployv1b\x06proto3"
+
+var (
+	file_dockerdeploy_v1_logs_proto_rawDescOnce sync.Once
+	file_dockerdeploy_v1_logs_proto_rawDescData []byte
+)
+
+func file_dockerdeploy_v1_logs_proto_rawDescGZIP() []byte {
+	file_dockerdeploy_v1_logs_proto_rawDescOnce.Do(func() {
+		file_dockerdeploy_v1_logs_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_dockerdeploy_v1_logs_proto_rawDesc), len(file_dockerdeploy_v1_logs_proto_rawDesc)))
+	})
+	return file_dockerdeploy_v1_logs_proto_rawDescData
+}
+
+var file_dockerdeploy_v1_logs_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_dockerdeploy_v1_logs_proto_goTypes = []any{
+	(*DeploymentLog)(nil),             // 0: dockerdeploy.v1.DeploymentLog
+	(*GetDeploymentLogsRequest)(nil),  // 1: dockerdeploy.v1.GetDeploymentLogsRequest
+	(*GetDeploymentLogsResponse)(nil), // 2: dockerdeploy.v1.GetDeploymentLogsResponse
+	(*StreamStackLogsRequest)(nil),    // 3: dockerdeploy.v1.StreamStackLogsRequest
+	(*LogLine)(nil),                   // 4: dockerdeploy.v1.LogLine
+	(*timestamppb.Timestamp)(nil),     // 5: google.protobuf.Timestamp
+}
+var file_dockerdeploy_v1_logs_proto_depIdxs = []int32{
+	5, // 0: dockerdeploy.v1.DeploymentLog.timestamp:type_name -> google.protobuf.Timestamp
+	0, // 1: dockerdeploy.v1.GetDeploymentLogsResponse.logs:type_name -> dockerdeploy.v1.DeploymentLog
+	1, // 2: dockerdeploy.v1.LogService.GetDeploymentLogs:input_type -> dockerdeploy.v1.GetDeploymentLogsRequest
+	3, // 3: dockerdeploy.v1.LogService.StreamStackLogs:input_type -> dockerdeploy.v1.StreamStackLogsRequest
+	2, // 4: dockerdeploy.v1.LogService.GetDeploymentLogs:output_type -> dockerdeploy.v1.GetDeploymentLogsResponse
+	4, // 5: dockerdeploy.v1.LogService.StreamStackLogs:output_type -> dockerdeploy.v1.LogLine
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_dockerdeploy_v1_logs_proto_init() }
+func file_dockerdeploy_v1_logs_proto_init() {
+	if File_dockerdeploy_v1_logs_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dockerdeploy_v1_logs_proto_rawDesc), len(file_dockerdeploy_v1_logs_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dockerdeploy_v1_logs_proto_goTypes,
+		DependencyIndexes: file_dockerdeploy_v1_logs_proto_depIdxs,
+		MessageInfos:      file_dockerdeploy_v1_logs_proto_msgTypes,
+	}.Build()
+	File_dockerdeploy_v1_logs_proto = out.File
+	file_dockerdeploy_v1_logs_proto_goTypes = nil
+	file_dockerdeploy_v1_logs_proto_depIdxs = nil
+}
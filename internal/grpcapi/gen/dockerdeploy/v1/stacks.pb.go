@@ -0,0 +1,467 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: dockerdeploy/v1/stacks.proto
+
+package deployv1
+
+import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Stack struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Id           string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name         string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	ProjectId    string                 `protobuf:"bytes,3,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	TemplateName string                 `protobuf:"bytes,4,opt,name=template_name,json=templateName,proto3" json:"template_name,omitempty"`
+	DeployMode   string                 `protobuf:"bytes,5,opt,name=deploy_mode,json=deployMode,proto3" json:"deploy_mode,omitempty"`
+	// running, stopped, partial or unknown
+	Status            string `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`
+	Services          int32  `protobuf:"varint,7,opt,name=services,proto3" json:"services,omitempty"`
+	RunningServices   int32  `protobuf:"varint,8,opt,name=running_services,json=runningServices,proto3" json:"running_services,omitempty"`
+	Containers        int32  `protobuf:"varint,9,opt,name=containers,proto3" json:"containers,omitempty"`
+	RunningContainers int32  `protobuf:"varint,10,opt,name=running_containers,json=runningContainers,proto3" json:"running_containers,omitempty"`
+	// Containers failing their health check
+	Unhealthy int32 `protobuf:"varint,11,opt,name=unhealthy,proto3" json:"unhealthy,omitempty"`
+	// A service is in a restart loop
+	Degraded      bool                   `protobuf:"varint,12,opt,name=degraded,proto3" json:"degraded,omitempty"`
+	NewtInjected  bool                   `protobuf:"varint,13,opt,name=newt_injected,json=newtInjected,proto3" json:"newt_injected,omitempty"`
+	TunnelUrl     string                 `protobuf:"bytes,14,opt,name=tunnel_url,json=tunnelUrl,proto3" json:"tunnel_url,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stack) Reset() {
+	*x = Stack{}
+	mi := &file_dockerdeploy_v1_stacks_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stack) ProtoMessage() {}
+
+func (x *Stack) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_stacks_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stack.ProtoReflect.Descriptor instead.
+func (*Stack) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_stacks_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Stack) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Stack) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Stack) GetProjectId() string {
+	if x != nil {
+		return x.ProjectId
+	}
+	return ""
+}
+
+func (x *Stack) GetTemplateName() string {
+	if x != nil {
+		return x.TemplateName
+	}
+	return ""
+}
+
+func (x *Stack) GetDeployMode() string {
+	if x != nil {
+		return x.DeployMode
+	}
+	return ""
+}
+
+func (x *Stack) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Stack) GetServices() int32 {
+	if x != nil {
+		return x.Services
+	}
+	return 0
+}
+
+func (x *Stack) GetRunningServices() int32 {
+	if x != nil {
+		return x.RunningServices
+	}
+	return 0
+}
+
+func (x *Stack) GetContainers() int32 {
+	if x != nil {
+		return x.Containers
+	}
+	return 0
+}
+
+func (x *Stack) GetRunningContainers() int32 {
+	if x != nil {
+		return x.RunningContainers
+	}
+	return 0
+}
+
+func (x *Stack) GetUnhealthy() int32 {
+	if x != nil {
+		return x.Unhealthy
+	}
+	return 0
+}
+
+func (x *Stack) GetDegraded() bool {
+	if x != nil {
+		return x.Degraded
+	}
+	return false
+}
+
+func (x *Stack) GetNewtInjected() bool {
+	if x != nil {
+		return x.NewtInjected
+	}
+	return false
+}
+
+func (x *Stack) GetTunnelUrl() string {
+	if x != nil {
+		return x.TunnelUrl
+	}
+	return ""
+}
+
+func (x *Stack) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type ListStacksRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only the stacks of a project; deployments outside a project are in "global"
+	Project       string `protobuf:"bytes,1,opt,name=project,proto3" json:"project,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStacksRequest) Reset() {
+	*x = ListStacksRequest{}
+	mi := &file_dockerdeploy_v1_stacks_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStacksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStacksRequest) ProtoMessage() {}
+
+func (x *ListStacksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_stacks_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStacksRequest.ProtoReflect.Descriptor instead.
+func (*ListStacksRequest) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_stacks_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListStacksRequest) GetProject() string {
+	if x != nil {
+		return x.Project
+	}
+	return ""
+}
+
+type ListStacksResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Stacks        []*Stack               `protobuf:"bytes,1,rep,name=stacks,proto3" json:"stacks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStacksResponse) Reset() {
+	*x = ListStacksResponse{}
+	mi := &file_dockerdeploy_v1_stacks_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStacksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStacksResponse) ProtoMessage() {}
+
+func (x *ListStacksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_stacks_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStacksResponse.ProtoReflect.Descriptor instead.
+func (*ListStacksResponse) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_stacks_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListStacksResponse) GetStacks() []*Stack {
+	if x != nil {
+		return x.Stacks
+	}
+	return nil
+}
+
+type StackActionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StackActionRequest) Reset() {
+	*x = StackActionRequest{}
+	mi := &file_dockerdeploy_v1_stacks_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StackActionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StackActionRequest) ProtoMessage() {}
+
+func (x *StackActionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_stacks_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StackActionRequest.ProtoReflect.Descriptor instead.
+func (*StackActionRequest) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_stacks_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StackActionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type StackActionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StackActionResponse) Reset() {
+	*x = StackActionResponse{}
+	mi := &file_dockerdeploy_v1_stacks_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StackActionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StackActionResponse) ProtoMessage() {}
+
+func (x *StackActionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dockerdeploy_v1_stacks_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StackActionResponse.ProtoReflect.Descriptor instead.
+func (*StackActionResponse) Descriptor() ([]byte, []int) {
+	return file_dockerdeploy_v1_stacks_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StackActionResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+var File_dockerdeploy_v1_stacks_proto protoreflect.FileDescriptor
+
+const file_dockerdeploy_v1_stacks_proto_rawDesc = "" +
+	"\n" +
+	"\x1cdockerdeploy/v1/stacks.proto\x12\x0fdockerdeploy.v1\x1a\x1cgoogle/api/annotations.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf7\x03\n" +
+	"\x05Stack\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\x03 \x01(\tR\tprojectId\x12#\n" +
+	"\rtemplate_name\x18\x04 \x01(\tR\ftemplateName\x12\x1f\n" +
+	"\vdeploy_mode\x18\x05 \x01(\tR\n" +
+	"deployMode\x12\x16\n" +
+	"\x06status\x18\x06 \x01(\tR\x06status\x12\x1a\n" +
+	"\bservices\x18\a \x01(\x05R\bservices\x12)\n" +
+	"\x10running_services\x18\b \x01(\x05R\x0frunningServices\x12\x1e\n" +
+	"\n" +
+	"containers\x18\t \x01(\x05R\n" +
+	"containers\x12-\n" +
+	"\x12running_containers\x18\n" +
+	" \x01(\x05R\x11runningContainers\x12\x1c\n" +
+	"\tunhealthy\x18\v \x01(\x05R\tunhealthy\x12\x1a\n" +
+	"\bdegraded\x18\f \x01(\bR\bdegraded\x12#\n" +
+	"\rnewt_injected\x18\r \x01(\bR\fnewtInjected\x12\x1d\n" +
+	"\n" +
+	"tunnel_url\x18\x0e \x01(\tR\ttunnelUrl\x129\n" +
+	"\n" +
+	"created_at\x18\x0f \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"-\n" +
+	"\x11ListStacksRequest\x12\x18\n" +
+	"\aproject\x18\x01 \x01(\tR\aproject\"D\n" +
+	"\x12ListStacksResponse\x12.\n" +
+	"\x06stacks\x18\x01 \x03(\v2\x16.dockerdeploy.v1.StackR\x06stacks\"$\n" +
+	"\x12StackActionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"/\n" +
+	"\x13StackActionResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage2\xef\x03\n" +
+	"\fStackService\x12r\n" +
+	"\n" +
+	"ListStacks\x12\".dockerdeploy.v1.ListStacksRequest\x1a#.dockerdeploy.v1.ListStacksResponse\"\x1b\x82\xd3\xe4\x93\x02\x15\x12\x13/api/stacks/summary\x12w\n" +
+	"\n" +
+	"StartStack\x12#.dockerdeploy.v1.StackActionRequest\x1a$.dockerdeploy.v1.StackActionResponse\"\x1e\x82\xd3\xe4\x93\x02\x18\"\x16/api/stacks/{id}/start\x12u\n" +
+	"\tStopStack\x12#.dockerdeploy.v1.StackActionRequest\x1a$.dockerdeploy.v1.StackActionResponse\"\x1d\x82\xd3\xe4\x93\x02\x17\"\x15/api/stacks/{id}/stop\x12{\n" +
+	"\fRestartStack\x12#.dockerdeploy.v1.StackActionRequest\x1a$.dockerdeploy.v1.StackActionResponse\" \x82\xd3\xe4\x93\x02\x1a\"\x18/api/stacks/{id}/restartBAZ?docker-deploy-app/internal/grpcapi/gen/dockerdeploy/v1;deployv1b\x06proto3"
+
+var (
+	file_dockerdeploy_v1_stacks_proto_rawDescOnce sync.Once
+	file_dockerdeploy_v1_stacks_proto_rawDescData []byte
+)
+
+func file_dockerdeploy_v1_stacks_proto_rawDescGZIP() []byte {
+	file_dockerdeploy_v1_stacks_proto_rawDescOnce.Do(func() {
+		file_dockerdeploy_v1_stacks_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_dockerdeploy_v1_stacks_proto_rawDesc), len(file_dockerdeploy_v1_stacks_proto_rawDesc)))
+	})
+	return file_dockerdeploy_v1_stacks_proto_rawDescData
+}
+
+var file_dockerdeploy_v1_stacks_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_dockerdeploy_v1_stacks_proto_goTypes = []any{
+	(*Stack)(nil),                 // 0: dockerdeploy.v1.Stack
+	(*ListStacksRequest)(nil),     // 1: dockerdeploy.v1.ListStacksRequest
+	(*ListStacksResponse)(nil),    // 2: dockerdeploy.v1.ListStacksResponse
+	(*StackActionRequest)(nil),    // 3: dockerdeploy.v1.StackActionRequest
+	(*StackActionResponse)(nil),   // 4: dockerdeploy.v1.StackActionResponse
+	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
+}
+var file_dockerdeploy_v1_stacks_proto_depIdxs = []int32{
+	5, // 0: dockerdeploy.v1.Stack.created_at:type_name -> google.protobuf.Timestamp
+	0, // 1: dockerdeploy.v1.ListStacksResponse.stacks:type_name -> dockerdeploy.v1.Stack
+	1, // 2: dockerdeploy.v1.StackService.ListStacks:input_type -> dockerdeploy.v1.ListStacksRequest
+	3, // 3: dockerdeploy.v1.StackService.StartStack:input_type -> dockerdeploy.v1.StackActionRequest
+	3, // 4: dockerdeploy.v1.StackService.StopStack:input_type -> dockerdeploy.v1.StackActionRequest
+	3, // 5: dockerdeploy.v1.StackService.RestartStack:input_type -> dockerdeploy.v1.StackActionRequest
+	2, // 6: dockerdeploy.v1.StackService.ListStacks:output_type -> dockerdeploy.v1.ListStacksResponse
+	4, // 7: dockerdeploy.v1.StackService.StartStack:output_type -> dockerdeploy.v1.StackActionResponse
+	4, // 8: dockerdeploy.v1.StackService.StopStack:output_type -> dockerdeploy.v1.StackActionResponse
+	4, // 9: dockerdeploy.v1.StackService.RestartStack:output_type -> dockerdeploy.v1.StackActionResponse
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_dockerdeploy_v1_stacks_proto_init() }
+func file_dockerdeploy_v1_stacks_proto_init() {
+	if File_dockerdeploy_v1_stacks_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dockerdeploy_v1_stacks_proto_rawDesc), len(file_dockerdeploy_v1_stacks_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dockerdeploy_v1_stacks_proto_goTypes,
+		DependencyIndexes: file_dockerdeploy_v1_stacks_proto_depIdxs,
+		MessageInfos:      file_dockerdeploy_v1_stacks_proto_msgTypes,
+	}.Build()
+	File_dockerdeploy_v1_stacks_proto = out.File
+	file_dockerdeploy_v1_stacks_proto_goTypes = nil
+	file_dockerdeploy_v1_stacks_proto_depIdxs = nil
+}
@@ -0,0 +1,249 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: dockerdeploy/v1/stacks.proto
+
+package deployv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	StackService_ListStacks_FullMethodName   = "/dockerdeploy.v1.StackService/ListStacks"
+	StackService_StartStack_FullMethodName   = "/dockerdeploy.v1.StackService/StartStack"
+	StackService_StopStack_FullMethodName    = "/dockerdeploy.v1.StackService/StopStack"
+	StackService_RestartStack_FullMethodName = "/dockerdeploy.v1.StackService/RestartStack"
+)
+
+// StackServiceClient is the client API for StackService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// StackService lists stacks and changes whether they run. Stacks are addressed
+// by the ID of their deployment
+type StackServiceClient interface {
+	// ListStacks returns every stack with its container state
+	ListStacks(ctx context.Context, in *ListStacksRequest, opts ...grpc.CallOption) (*ListStacksResponse, error)
+	// StartStack starts a stack; swarm stacks are scaled to one replica per service
+	StartStack(ctx context.Context, in *StackActionRequest, opts ...grpc.CallOption) (*StackActionResponse, error)
+	// StopStack stops a stack; swarm stacks are scaled to zero
+	StopStack(ctx context.Context, in *StackActionRequest, opts ...grpc.CallOption) (*StackActionResponse, error)
+	// RestartStack restarts a stack's containers
+	RestartStack(ctx context.Context, in *StackActionRequest, opts ...grpc.CallOption) (*StackActionResponse, error)
+}
+
+type stackServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStackServiceClient(cc grpc.ClientConnInterface) StackServiceClient {
+	return &stackServiceClient{cc}
+}
+
+func (c *stackServiceClient) ListStacks(ctx context.Context, in *ListStacksRequest, opts ...grpc.CallOption) (*ListStacksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListStacksResponse)
+	err := c.cc.Invoke(ctx, StackService_ListStacks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stackServiceClient) StartStack(ctx context.Context, in *StackActionRequest, opts ...grpc.CallOption) (*StackActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StackActionResponse)
+	err := c.cc.Invoke(ctx, StackService_StartStack_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stackServiceClient) StopStack(ctx context.Context, in *StackActionRequest, opts ...grpc.CallOption) (*StackActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StackActionResponse)
+	err := c.cc.Invoke(ctx, StackService_StopStack_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *stackServiceClient) RestartStack(ctx context.Context, in *StackActionRequest, opts ...grpc.CallOption) (*StackActionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StackActionResponse)
+	err := c.cc.Invoke(ctx, StackService_RestartStack_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StackServiceServer is the server API for StackService service.
+// All implementations must embed UnimplementedStackServiceServer
+// for forward compatibility.
+//
+// StackService lists stacks and changes whether they run. Stacks are addressed
+// by the ID of their deployment
+type StackServiceServer interface {
+	// ListStacks returns every stack with its container state
+	ListStacks(context.Context, *ListStacksRequest) (*ListStacksResponse, error)
+	// StartStack starts a stack; swarm stacks are scaled to one replica per service
+	StartStack(context.Context, *StackActionRequest) (*StackActionResponse, error)
+	// StopStack stops a stack; swarm stacks are scaled to zero
+	StopStack(context.Context, *StackActionRequest) (*StackActionResponse, error)
+	// RestartStack restarts a stack's containers
+	RestartStack(context.Context, *StackActionRequest) (*StackActionResponse, error)
+	mustEmbedUnimplementedStackServiceServer()
+}
+
+// UnimplementedStackServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedStackServiceServer struct{}
+
+func (UnimplementedStackServiceServer) ListStacks(context.Context, *ListStacksRequest) (*ListStacksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStacks not implemented")
+}
+func (UnimplementedStackServiceServer) StartStack(context.Context, *StackActionRequest) (*StackActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartStack not implemented")
+}
+func (UnimplementedStackServiceServer) StopStack(context.Context, *StackActionRequest) (*StackActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StopStack not implemented")
+}
+func (UnimplementedStackServiceServer) RestartStack(context.Context, *StackActionRequest) (*StackActionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestartStack not implemented")
+}
+func (UnimplementedStackServiceServer) mustEmbedUnimplementedStackServiceServer() {}
+func (UnimplementedStackServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeStackServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StackServiceServer will
+// result in compilation errors.
+type UnsafeStackServiceServer interface {
+	mustEmbedUnimplementedStackServiceServer()
+}
+
+func RegisterStackServiceServer(s grpc.ServiceRegistrar, srv StackServiceServer) {
+	// If the following call pancis, it indicates UnimplementedStackServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&StackService_ServiceDesc, srv)
+}
+
+func _StackService_ListStacks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStacksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StackServiceServer).ListStacks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StackService_ListStacks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StackServiceServer).ListStacks(ctx, req.(*ListStacksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StackService_StartStack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StackActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StackServiceServer).StartStack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StackService_StartStack_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StackServiceServer).StartStack(ctx, req.(*StackActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StackService_StopStack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StackActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StackServiceServer).StopStack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StackService_StopStack_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StackServiceServer).StopStack(ctx, req.(*StackActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StackService_RestartStack_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StackActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StackServiceServer).RestartStack(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StackService_RestartStack_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StackServiceServer).RestartStack(ctx, req.(*StackActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// StackService_ServiceDesc is the grpc.ServiceDesc for StackService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var StackService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dockerdeploy.v1.StackService",
+	HandlerType: (*StackServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListStacks",
+			Handler:    _StackService_ListStacks_Handler,
+		},
+		{
+			MethodName: "StartStack",
+			Handler:    _StackService_StartStack_Handler,
+		},
+		{
+			MethodName: "StopStack",
+			Handler:    _StackService_StopStack_Handler,
+		},
+		{
+			MethodName: "RestartStack",
+			Handler:    _StackService_RestartStack_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "dockerdeploy/v1/stacks.proto",
+}
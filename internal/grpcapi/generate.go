@@ -0,0 +1,5 @@
+package grpcapi
+
+// The stubs in gen are generated from proto/dockerdeploy/v1 by buf; this file
+// carries no build tag so go generate finds the directive without -tags grpc
+//go:generate sh -c "cd ../../proto && buf generate"
@@ -0,0 +1,120 @@
+//go:build grpc
+
+package grpcapi
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"docker-deploy-app/internal/docker"
+	deployv1 "docker-deploy-app/internal/grpcapi/gen/dockerdeploy/v1"
+)
+
+// logService implements LogService
+type logService struct {
+	deployv1.UnimplementedLogServiceServer
+	db      *sql.DB
+	auth    *authenticator
+	compose *docker.ComposeManager
+}
+
+// GetDeploymentLogs returns a deployment's log entries newest first
+func (s *logService) GetDeploymentLogs(ctx context.Context, req *deployv1.GetDeploymentLogsRequest) (*deployv1.GetDeploymentLogsResponse, error) {
+	if _, _, err := s.auth.requireDeploymentRole(ctx, req.GetId(), "viewer"); err != nil {
+		return nil, err
+	}
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := "SELECT id, log_level, message, timestamp FROM deployment_logs WHERE deployment_id = $1"
+	args := []interface{}{req.GetId()}
+	if req.GetLevel() != "" {
+		var placeholders []string
+		for _, level := range strings.Split(req.GetLevel(), ",") {
+			args = append(args, strings.TrimSpace(level))
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+		query += " AND log_level IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY timestamp DESC LIMIT $%d", len(args))
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Database error: %v", err)
+	}
+	defer rows.Close()
+
+	response := &deployv1.GetDeploymentLogsResponse{}
+	for rows.Next() {
+		var log deployv1.DeploymentLog
+		var timestamp sql.NullTime
+		if err := rows.Scan(&log.Id, &log.Level, &log.Message, &timestamp); err != nil {
+			return nil, status.Errorf(codes.Internal, "Database error: %v", err)
+		}
+		if timestamp.Valid {
+			log.Timestamp = timestamppb.New(timestamp.Time)
+		}
+		response.Logs = append(response.Logs, &log)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, status.Errorf(codes.Internal, "Database error: %v", err)
+	}
+	return response, nil
+}
+
+// StreamStackLogs sends a stack's output line by line. The compose process is
+// killed when the client cancels or disconnects
+func (s *logService) StreamStackLogs(req *deployv1.StreamStackLogsRequest, stream deployv1.LogService_StreamStackLogsServer) error {
+	ctx := stream.Context()
+	stackName, _, err := s.auth.requireDeploymentRole(ctx, req.GetId(), "viewer")
+	if err != nil {
+		return err
+	}
+	tail := int(req.GetTail())
+	if tail <= 0 {
+		tail = 100
+	}
+
+	cmd, err := s.compose.Logs(stackName, req.GetFollow(), tail)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to start log stream: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to start log stream: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return status.Errorf(codes.Internal, "Failed to start log stream: %v", err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cmd.Process.Kill()
+		case <-done:
+		}
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := stream.Send(&deployv1.LogLine{Line: scanner.Text()}); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+	cmd.Wait()
+	return ctx.Err()
+}
@@ -0,0 +1,92 @@
+//go:build grpc
+
+// Package grpcapi serves the deployment, stack and log services defined in
+// proto/dockerdeploy/v1 over gRPC, for the multi-node agent and automation
+// clients. The services read the same tables and drive the same compose and
+// swarm managers as the REST handlers; the gateway mappings in the .proto files
+// name the REST routes each call corresponds to
+package grpcapi
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/docker/docker/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/docker"
+	deployv1 "docker-deploy-app/internal/grpcapi/gen/dockerdeploy/v1"
+)
+
+// Server is the gRPC server
+type Server struct {
+	db       *sql.DB
+	config   *config.Config
+	server   *grpc.Server
+	listener net.Listener
+}
+
+// NewServer creates a gRPC server with the services registered
+func NewServer(db *sql.DB, dockerClient *client.Client, config *config.Config) *Server {
+	composeTimeout := time.Duration(config.Docker.ComposeTimeout) * time.Second
+	auth := newAuthenticator(db, config.Security)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(auth.unary),
+		grpc.ChainStreamInterceptor(auth.stream),
+	)
+
+	stacks := &stackService{
+		db:         db,
+		auth:       auth,
+		compose:    docker.NewComposeManager("./deployments", composeTimeout),
+		swarm:      docker.NewSwarmManager(dockerClient, "./deployments", composeTimeout),
+		containers: docker.WrapClient(dockerClient),
+	}
+	deployv1.RegisterDeploymentServiceServer(server, &deploymentService{db: db, auth: auth})
+	deployv1.RegisterStackServiceServer(server, stacks)
+	deployv1.RegisterLogServiceServer(server, &logService{db: db, auth: auth, compose: stacks.compose})
+	reflection.Register(server)
+
+	return &Server{
+		db:     db,
+		config: config,
+		server: server,
+	}
+}
+
+// Start listens on the configured port and serves in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.config.Server.Host, s.config.Server.GRPC.Port))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	s.listener = listener
+
+	go func() {
+		slog.Info("Starting gRPC server", "addr", listener.Addr().String())
+		if err := s.server.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+			slog.Error("gRPC server failed", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Stop finishes the calls in progress and stops the server. Log streams that
+// follow never finish on their own, so they are cut off after a grace period
+func (s *Server) Stop() {
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		s.server.Stop()
+	}
+}
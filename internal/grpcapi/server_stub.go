@@ -0,0 +1,32 @@
+//go:build !grpc
+
+// Package grpcapi serves the deployment, stack and log services over gRPC. The
+// services pull in the gRPC dependencies, so they are only built with the grpc
+// tag:
+//
+//	go build -tags grpc ./cmd/server
+package grpcapi
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+)
+
+// Server stands in for the gRPC server in builds without the grpc tag
+type Server struct{}
+
+// NewServer creates a server that cannot be started
+func NewServer(db *sql.DB, dockerClient *client.Client, config *config.Config) *Server {
+	return &Server{}
+}
+
+// Start fails: this binary was built without the gRPC services
+func (s *Server) Start() error {
+	return fmt.Errorf("built without gRPC support; rebuild with -tags grpc")
+}
+
+// Stop does nothing
+func (s *Server) Stop() {}
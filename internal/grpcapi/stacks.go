@@ -0,0 +1,172 @@
+//go:build grpc
+
+package grpcapi
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"docker-deploy-app/internal/docker"
+	deployv1 "docker-deploy-app/internal/grpcapi/gen/dockerdeploy/v1"
+	"docker-deploy-app/internal/models"
+)
+
+// stackService implements StackService
+type stackService struct {
+	deployv1.UnimplementedStackServiceServer
+	db         *sql.DB
+	auth       *authenticator
+	compose    *docker.ComposeManager
+	swarm      *docker.SwarmManager
+	containers *docker.Client
+}
+
+// ListStacks returns the stacks the caller can view, with the container state
+// of one listing as GET /api/stacks/summary does
+func (s *stackService) ListStacks(ctx context.Context, req *deployv1.ListStacksRequest) (*deployv1.ListStacksResponse, error) {
+	summaries, err := s.containers.SummarizeStacks(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "Failed to list containers: %v", err)
+	}
+
+	query := `
+		SELECT d.id, d.stack_name, COALESCE(d.project_id, 'global'), d.deploy_mode, d.newt_injected,
+		       COALESCE(d.tunnel_url, ''), d.created_at, COALESCE(t.name, ''),
+		       EXISTS(SELECT 1 FROM restart_loops l WHERE l.deployment_id = d.id AND l.cleared_at IS NULL)
+		FROM deployments d
+		LEFT JOIN templates t ON d.template_id = t.id`
+	var args []interface{}
+	if req.GetProject() != "" {
+		query += " WHERE COALESCE(d.project_id, 'global') = $1"
+		args = append(args, req.GetProject())
+	}
+	query += " ORDER BY d.created_at DESC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Database error: %v", err)
+	}
+	defer rows.Close()
+
+	response := &deployv1.ListStacksResponse{}
+	for rows.Next() {
+		var stack models.StackSummary
+		if err := rows.Scan(&stack.ID, &stack.Name, &stack.ProjectID, &stack.DeployMode, &stack.NewtInjected,
+			&stack.TunnelURL, &stack.CreatedAt, &stack.TemplateName, &stack.Degraded); err != nil {
+			return nil, status.Errorf(codes.Internal, "Database error: %v", err)
+		}
+		if s.auth.requireRole(ctx, stack.ProjectID, "viewer") != nil {
+			continue
+		}
+
+		// A stack without containers is stopped
+		stack.Status = models.StackStatusStopped
+		if c, ok := summaries[stack.Name]; ok {
+			stack.Status = c.Status
+			stack.Services, stack.RunningServices = c.Services, c.RunningServices
+			stack.Containers, stack.RunningContainers, stack.Unhealthy = c.Containers, c.RunningContainers, c.Unhealthy
+		}
+		response.Stacks = append(response.Stacks, stackMessage(&stack))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, status.Errorf(codes.Internal, "Database error: %v", err)
+	}
+	return response, nil
+}
+
+// StartStack starts a stack
+func (s *stackService) StartStack(ctx context.Context, req *deployv1.StackActionRequest) (*deployv1.StackActionResponse, error) {
+	if err := s.setRunning(ctx, req.GetId(), true); err != nil {
+		return nil, err
+	}
+	docker.ClearRestartLoops(s.db, req.GetId()) // Started again on purpose, so no longer degraded
+	return &deployv1.StackActionResponse{Message: "Stack started successfully"}, nil
+}
+
+// StopStack stops a stack
+func (s *stackService) StopStack(ctx context.Context, req *deployv1.StackActionRequest) (*deployv1.StackActionResponse, error) {
+	if err := s.setRunning(ctx, req.GetId(), false); err != nil {
+		return nil, err
+	}
+	return &deployv1.StackActionResponse{Message: "Stack stopped successfully"}, nil
+}
+
+// RestartStack restarts a stack
+func (s *stackService) RestartStack(ctx context.Context, req *deployv1.StackActionRequest) (*deployv1.StackActionResponse, error) {
+	if err := s.auth.requireWritable(); err != nil {
+		return nil, err
+	}
+	stackName, _, err := s.auth.requireDeploymentRole(ctx, req.GetId(), "operator")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.compose.Restart(stackName); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to restart stack: %v", err)
+	}
+	docker.ClearRestartLoops(s.db, req.GetId())
+	return &deployv1.StackActionResponse{Message: "Stack restarted successfully"}, nil
+}
+
+// setRunning starts or stops a stack and records the deployment's status. Swarm
+// stacks are scaled to zero and deployed again, as the REST handlers do
+func (s *stackService) setRunning(ctx context.Context, deploymentID string, running bool) error {
+	if err := s.auth.requireWritable(); err != nil {
+		return err
+	}
+	stackName, deployMode, err := s.auth.requireDeploymentRole(ctx, deploymentID, "operator")
+	if err != nil {
+		return err
+	}
+
+	action := "stop"
+	if running {
+		action = "start"
+	}
+	if deployMode == models.DeployModeSwarm {
+		if running {
+			err = s.swarm.Start(stackName)
+		} else {
+			err = s.swarm.Stop(stackName)
+		}
+	} else if running {
+		err = s.compose.Start(stackName)
+	} else {
+		err = s.compose.Stop(stackName)
+	}
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to %s stack: %v", action, err)
+	}
+
+	newStatus := models.StatusStopped
+	if running {
+		newStatus = models.StatusRunning
+	}
+	s.db.Exec("UPDATE deployments SET status = $1, updated_at = $2 WHERE id = $3",
+		newStatus, time.Now(), deploymentID)
+	return nil
+}
+
+func stackMessage(stack *models.StackSummary) *deployv1.Stack {
+	return &deployv1.Stack{
+		Id:                stack.ID,
+		Name:              stack.Name,
+		ProjectId:         stack.ProjectID,
+		TemplateName:      stack.TemplateName,
+		DeployMode:        string(stack.DeployMode),
+		Status:            string(stack.Status),
+		Services:          int32(stack.Services),
+		RunningServices:   int32(stack.RunningServices),
+		Containers:        int32(stack.Containers),
+		RunningContainers: int32(stack.RunningContainers),
+		Unhealthy:         int32(stack.Unhealthy),
+		Degraded:          stack.Degraded,
+		NewtInjected:      stack.NewtInjected,
+		TunnelUrl:         stack.TunnelURL,
+		CreatedAt:         timestamppb.New(stack.CreatedAt),
+	}
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"docker-deploy-app/internal/config"
+)
+
+// contextKey is the context key of the request-scoped logger
+type contextKey struct{}
+
+// level is the level of the default logger, changed by SetLevel without
+// replacing the logger
+var level = new(slog.LevelVar)
+
+// Setup installs the default slog logger described by the logging configuration.
+// The standard log package is routed through it as well. The returned closer
+// closes the log file when output is a file path
+func Setup(cfg config.LoggingConfig) (io.Closer, error) {
+	var output io.Writer
+	var closer io.Closer = io.NopCloser(nil)
+
+	switch cfg.Output {
+	case "", "stdout":
+		output = os.Stdout
+	case "stderr":
+		output = os.Stderr
+	default:
+		if err := os.MkdirAll(filepath.Dir(cfg.Output), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+		file, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		output, closer = file, file
+	}
+
+	level.Set(ParseLevel(cfg.Level))
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "text":
+		handler = slog.NewTextHandler(output, options)
+	default:
+		handler = slog.NewJSONHandler(output, options)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return closer, nil
+}
+
+// SetLevel changes the level of the logger installed by Setup
+func SetLevel(name string) {
+	level.Set(ParseLevel(name))
+}
+
+// ParseLevel converts a configured level name to a slog level, defaulting to info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithLogger returns a context carrying a request-scoped logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the request-scoped logger, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader is the W3C Trace Context header used by OpenTelemetry
+const TraceparentHeader = "traceparent"
+
+// traceContextKey is the context key of the current trace context
+type traceContextKey struct{}
+
+// TraceContext identifies the current span of a distributed trace
+type TraceContext struct {
+	TraceID  string
+	SpanID   string
+	ParentID string // Span ID of the caller, empty for a new trace
+	Sampled  bool
+}
+
+// ParseTraceparent parses a version 00 traceparent header
+func ParseTraceparent(header string) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return TraceContext{}, false
+	}
+	if !isHex(parts[1]) || !isHex(parts[2]) || !isHex(parts[3]) ||
+		parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return TraceContext{}, false
+	}
+
+	flags, _ := hex.DecodeString(parts[3])
+	return TraceContext{
+		TraceID: parts[1],
+		SpanID:  parts[2],
+		Sampled: flags[0]&0x01 == 1,
+	}, true
+}
+
+// StartSpan continues the trace of an incoming request, or starts a new one when the
+// request carries no valid traceparent header
+func StartSpan(r *http.Request) TraceContext {
+	parent, ok := ParseTraceparent(r.Header.Get(TraceparentHeader))
+	if !ok {
+		return TraceContext{TraceID: randomHex(16), SpanID: randomHex(8), Sampled: true}
+	}
+	return TraceContext{
+		TraceID:  parent.TraceID,
+		SpanID:   randomHex(8),
+		ParentID: parent.SpanID,
+		Sampled:  parent.Sampled,
+	}
+}
+
+// Traceparent formats the trace context as a traceparent header value
+func (tc TraceContext) Traceparent() string {
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", tc.TraceID, tc.SpanID, flags)
+}
+
+// WithTrace returns a context carrying a trace context
+func WithTrace(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceFromContext returns the trace context of a request, if any
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// Inject sets the traceparent header of an outgoing request so downstream services
+// continue the trace of ctx
+func Inject(ctx context.Context, header http.Header) {
+	if tc, ok := TraceFromContext(ctx); ok {
+		header.Set(TraceparentHeader, tc.Traceparent())
+	}
+}
+
+// randomHex returns n random bytes hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// isHex reports whether s is lowercase hexadecimal
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
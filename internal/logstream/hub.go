@@ -0,0 +1,172 @@
+// Package logstream buffers the most recent deployment logs in memory and tells
+// subscribers when new ones are written, so live log views are pushed from
+// memory instead of polling the database
+package logstream
+
+import (
+	"encoding/json"
+	"sync"
+
+	"docker-deploy-app/internal/bus"
+	"docker-deploy-app/internal/models"
+)
+
+// Hub keeps a ring buffer of recent logs per deployment. Log IDs serve as
+// sequence numbers: the table's AUTOINCREMENT never reuses or reorders them.
+// Logs travel over the bus, so the buffers of every replica see every log
+type Hub struct {
+	size        int
+	bus         bus.Bus
+	unsubscribe func()
+	rings       map[string]*ring
+	subscribers map[string]map[chan struct{}]struct{}
+	mu          sync.Mutex
+}
+
+// ring holds the newest logs of a deployment, oldest first from start. Every log
+// of the deployment with an ID above floor is in the ring
+type ring struct {
+	logs  []models.DeploymentLog
+	start int
+	count int
+	floor int
+}
+
+// NewHub creates a hub buffering up to size logs per deployment from the logs
+// published on a bus
+func NewHub(size int, b bus.Bus) *Hub {
+	messages, unsubscribe := b.Subscribe(bus.TopicDeploymentLogs)
+	h := &Hub{
+		size:        size,
+		bus:         b,
+		unsubscribe: unsubscribe,
+		rings:       make(map[string]*ring),
+		subscribers: make(map[string]map[chan struct{}]struct{}),
+	}
+	go h.receive(messages)
+	return h
+}
+
+// Publish sends a log just written to the database to the hubs of every
+// replica. When the bus fails it is at least buffered here
+func (h *Hub) Publish(log models.DeploymentLog) {
+	payload, err := json.Marshal(log)
+	if err == nil {
+		err = h.bus.Publish(bus.TopicDeploymentLogs, payload)
+	}
+	if err != nil {
+		h.add(log)
+	}
+}
+
+// Close stops receiving logs from the bus
+func (h *Hub) Close() {
+	h.unsubscribe()
+}
+
+func (h *Hub) receive(messages <-chan []byte) {
+	for payload := range messages {
+		var log models.DeploymentLog
+		if err := json.Unmarshal(payload, &log); err == nil {
+			h.add(log)
+		}
+	}
+}
+
+// add buffers a log and wakes the subscribers of its deployment
+func (h *Hub) add(log models.DeploymentLog) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.rings[log.DeploymentID]
+	if !ok {
+		// Logs written before the ring existed all have lower IDs
+		r = &ring{logs: make([]models.DeploymentLog, h.size), floor: log.ID - 1}
+		h.rings[log.DeploymentID] = r
+	}
+	r.push(log)
+
+	for notify := range h.subscribers[log.DeploymentID] {
+		select {
+		case notify <- struct{}{}:
+		default: // Already woken and not caught up yet
+		}
+	}
+}
+
+// After returns the newest limit logs of a deployment written after the log with
+// ID afterID, oldest first, as the database would. ok is false when the buffer
+// does not reach back far enough and the database has to be read instead
+func (h *Hub) After(deploymentID string, afterID, limit int) ([]models.DeploymentLog, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.rings[deploymentID]
+	if !ok {
+		return nil, false
+	}
+
+	var logs []models.DeploymentLog
+	for i := 0; i < r.count; i++ {
+		if log := r.at(i); log.ID > afterID {
+			logs = append(logs, log)
+		}
+	}
+	if len(logs) > limit {
+		return logs[len(logs)-limit:], true
+	}
+	if afterID < r.floor && len(logs) < limit {
+		return nil, false
+	}
+	return logs, true
+}
+
+// Subscribe returns a channel signalled when logs of a deployment are published,
+// and a function ending the subscription. Signals coalesce, so a subscriber reads
+// everything after the last log it has seen when woken
+func (h *Hub) Subscribe(deploymentID string) (<-chan struct{}, func()) {
+	notify := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	if h.subscribers[deploymentID] == nil {
+		h.subscribers[deploymentID] = make(map[chan struct{}]struct{})
+	}
+	h.subscribers[deploymentID][notify] = struct{}{}
+	h.mu.Unlock()
+
+	return notify, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subscribers[deploymentID], notify)
+		if len(h.subscribers[deploymentID]) == 0 {
+			delete(h.subscribers, deploymentID)
+		}
+	}
+}
+
+// Remove drops the buffered logs of a deleted deployment
+func (h *Hub) Remove(deploymentID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.rings, deploymentID)
+}
+
+func (r *ring) push(log models.DeploymentLog) {
+	if len(r.logs) == 0 {
+		r.floor = log.ID
+		return
+	}
+	if r.count == len(r.logs) {
+		// Overwrite the oldest, which the database now has to serve
+		r.floor = r.logs[r.start].ID
+		r.logs[r.start] = log
+		r.start = (r.start + 1) % len(r.logs)
+		return
+	}
+	r.logs[(r.start+r.count)%len(r.logs)] = log
+	r.count++
+}
+
+func (r *ring) at(i int) models.DeploymentLog {
+	return r.logs[(r.start+i)%len(r.logs)]
+}
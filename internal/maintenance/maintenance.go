@@ -0,0 +1,116 @@
+package maintenance
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"docker-deploy-app/internal/models"
+)
+
+// settingKey is the system_settings key holding the maintenance state, so it
+// survives restarts during a maintenance window
+const settingKey = "maintenance"
+
+// DefaultMessage is shown to clients when maintenance starts without a message
+const DefaultMessage = "The instance is under maintenance. Changes are disabled until it ends."
+
+// drainInterval is how often Drain checks for running tasks
+const drainInterval = 2 * time.Second
+
+// Get returns the maintenance state of the instance with the number of
+// background tasks still running
+func Get(db *sql.DB) (*models.MaintenanceState, error) {
+	state := &models.MaintenanceState{}
+
+	var value sql.NullString
+	err := db.QueryRow("SELECT value FROM system_settings WHERE key = $1", settingKey).Scan(&value)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if value.String != "" {
+		if err := json.Unmarshal([]byte(value.String), state); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM tasks WHERE state = $1", models.TaskStateRunning).Scan(&state.RunningTasks); err != nil {
+		return nil, err
+	}
+	state.Drained = state.Active && state.RunningTasks == 0
+	return state, nil
+}
+
+// Active reports whether maintenance mode is on. Background jobs check it before
+// every run. A failed lookup counts as off, so a settings problem never stops
+// the instance's jobs
+func Active(db *sql.DB) bool {
+	var value sql.NullString
+	if err := db.QueryRow("SELECT value FROM system_settings WHERE key = $1", settingKey).Scan(&value); err != nil {
+		return false
+	}
+
+	var state models.MaintenanceState
+	if err := json.Unmarshal([]byte(value.String), &state); err != nil {
+		return false
+	}
+	return state.Active
+}
+
+// Enable turns maintenance mode on. Enabling it again only updates the message
+func Enable(db *sql.DB, message, startedBy string) (*models.MaintenanceState, error) {
+	current, err := Get(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if message == "" {
+		message = DefaultMessage
+	}
+	startedAt := time.Now()
+	if current.Active && current.StartedAt != nil {
+		startedAt = *current.StartedAt
+		startedBy = current.StartedBy
+	}
+
+	if err := save(db, &models.MaintenanceState{
+		Active:    true,
+		Message:   message,
+		StartedAt: &startedAt,
+		StartedBy: startedBy,
+	}); err != nil {
+		return nil, err
+	}
+	return Get(db)
+}
+
+// Disable turns maintenance mode off
+func Disable(db *sql.DB) error {
+	return save(db, &models.MaintenanceState{})
+}
+
+// Drain waits until no background tasks are running, or the timeout elapses, and
+// returns the resulting state
+func Drain(db *sql.DB, timeout time.Duration) (*models.MaintenanceState, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		state, err := Get(db)
+		if err != nil || state.RunningTasks == 0 || !time.Now().Before(deadline) {
+			return state, err
+		}
+		time.Sleep(drainInterval)
+	}
+}
+
+func save(db *sql.DB, state *models.MaintenanceState) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO system_settings (key, value, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		settingKey, string(value), time.Now())
+	return err
+}
@@ -0,0 +1,232 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/models"
+)
+
+// Compose and override file names looked for in a project, in the order compose
+// itself prefers them
+var (
+	composeDirFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+	composeDirOverrideFiles = []string{
+		"compose.override.yaml", "compose.override.yml", "docker-compose.override.yaml", "docker-compose.override.yml",
+	}
+)
+
+// ComposeDirImporter adopts a folder of compose projects, one subdirectory per
+// stack as Dockge and many hand-kept setups arrange them, as deployments. The
+// compose file and .env of each are copied to its project directory, with
+// relative paths pointed back at the folder so bind mounts keep their data.
+// Nothing is started or restarted: running projects are recorded as running
+type ComposeDirImporter struct {
+	db             *sql.DB
+	dockerClient   *client.Client
+	deploymentsDir string
+}
+
+// NewComposeDirImporter creates a new compose folder importer
+func NewComposeDirImporter(db *sql.DB, dockerClient *client.Client, deploymentsDir string) *ComposeDirImporter {
+	return &ComposeDirImporter{
+		db:             db,
+		dockerClient:   dockerClient,
+		deploymentsDir: deploymentsDir,
+	}
+}
+
+// Import adopts the compose projects in the subdirectories of a validated
+// request's path. Subdirectories without a compose file are ignored, and those
+// already adopted are skipped, so an import can be repeated
+func (ci *ComposeDirImporter) Import(ctx context.Context, req *models.ComposeDirImportRequest, requestedBy string) (*models.ComposeDirImportResult, error) {
+	entries, err := os.ReadDir(req.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", req.Path, err)
+	}
+	if absDeployments, err := filepath.Abs(ci.deploymentsDir); err == nil && absDeployments == filepath.Clean(req.Path) {
+		return nil, fmt.Errorf("%s holds the deployments of this instance", req.Path)
+	}
+
+	projectID := req.ProjectID
+	if projectID == "" {
+		projectID = models.DefaultProjectID
+	}
+
+	result := &models.ComposeDirImportResult{DryRun: req.DryRun, Deployments: []models.ComposeDirAdoption{}}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		ci.importProject(ctx, filepath.Join(req.Path, entry.Name()), projectID, requestedBy, req.DryRun, result)
+	}
+	return result, nil
+}
+
+// importProject adopts the compose project of a directory
+func (ci *ComposeDirImporter) importProject(ctx context.Context, source, projectID, requestedBy string, dryRun bool, result *models.ComposeDirImportResult) {
+	name := filepath.Base(source)
+	composeFile := firstExisting(source, composeDirFiles)
+	if composeFile == "" {
+		return
+	}
+
+	// Compose names projects after their directory, lowercased
+	stackName := strings.ToLower(name)
+	if !models.IsValidStackName(stackName) {
+		result.Skip(name, "not a valid stack name")
+		return
+	}
+	var exists bool
+	ci.db.QueryRow("SELECT EXISTS(SELECT 1 FROM deployments WHERE stack_name = $1)", stackName).Scan(&exists)
+	if exists {
+		result.Skip(name, "a deployment with this stack name already exists")
+		return
+	}
+	projectDir := filepath.Join(ci.deploymentsDir, stackName)
+	if _, err := os.Stat(projectDir); err == nil {
+		result.Skip(name, "the project directory already exists")
+		return
+	}
+
+	compose, err := os.ReadFile(filepath.Join(source, composeFile))
+	if err != nil {
+		result.Skip(name, fmt.Sprintf("failed to read %s: %v", composeFile, err))
+		return
+	}
+	compose, rewritten, err := docker.AbsoluteComposePaths(compose, source)
+	if err != nil {
+		result.Skip(name, err.Error())
+		return
+	}
+	var override []byte
+	overrideFile := firstExisting(source, composeDirOverrideFiles)
+	if overrideFile != "" {
+		if override, err = os.ReadFile(filepath.Join(source, overrideFile)); err == nil {
+			var n int
+			override, n, err = docker.AbsoluteComposePaths(override, source)
+			rewritten += n
+		}
+		if err != nil {
+			result.Skip(name, fmt.Sprintf("%s: %v", overrideFile, err))
+			return
+		}
+	}
+
+	dotEnv, err := os.ReadFile(filepath.Join(source, ".env"))
+	if err != nil && !os.IsNotExist(err) {
+		result.Skip(name, fmt.Sprintf("failed to read .env: %v", err))
+		return
+	}
+	variables := docker.ParseEnvExample(dotEnv)
+	env := make(map[string]string, len(variables))
+	for i := range variables {
+		env[variables[i].Name] = variables[i].DefaultValue
+		variables[i].Required = false
+		// Templates are visible to every user; the values stay with the deployment
+		if variables[i].Type == "password" {
+			variables[i].DefaultValue = ""
+		}
+	}
+
+	status := models.StatusStopped
+	if stackRunning(ctx, ci.dockerClient, "com.docker.compose.project", stackName) {
+		status = models.StatusRunning
+	}
+	adoption := models.ComposeDirAdoption{
+		StackName:      stackName,
+		Source:         source,
+		ComposeFile:    composeFile,
+		Variables:      len(env),
+		Status:         status,
+		RewrittenPaths: rewritten,
+	}
+	if dryRun {
+		result.Deployments = append(result.Deployments, adoption)
+		return
+	}
+
+	template := &models.Template{
+		ID:          "compose-dir-" + templateSlug(stackName),
+		Name:        name,
+		Description: fmt.Sprintf("Adopted from %s", source),
+		Tags:        []string{"adopted"},
+		Branch:      "main",
+		Path:        "/",
+		Version:     "1.0.0",
+		Variables:   variables,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if !templateExists(ci.db, template.ID) {
+		if err := saveTemplate(ci.db, template); err != nil {
+			result.Skip(name, err.Error())
+			return
+		}
+	}
+
+	if err := writeAdoptedFiles(projectDir, compose, override, dotEnv); err != nil {
+		os.RemoveAll(projectDir)
+		result.Skip(name, err.Error())
+		return
+	}
+
+	deployment := &models.Deployment{
+		ID:              fmt.Sprintf("deploy_%d", time.Now().UnixNano()),
+		TemplateID:      template.ID,
+		StackName:       stackName,
+		ProjectID:       projectID,
+		Status:          status,
+		DeployMode:      models.DeployModeCompose,
+		Config:          map[string]interface{}{"environment": env, "imported_from": "compose-dir", "source_dir": source},
+		ResourceVersion: 1,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	if err := insertDeployment(ci.db, deployment, requestedBy); err != nil {
+		os.RemoveAll(projectDir)
+		result.Skip(name, err.Error())
+		return
+	}
+	result.Deployments = append(result.Deployments, adoption)
+}
+
+// writeAdoptedFiles writes the compose files and .env of an adopted project
+// to its project directory. The .env is copied as is, comments included
+func writeAdoptedFiles(projectDir string, compose, override, dotEnv []byte) error {
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		return fmt.Errorf("failed to create project directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "docker-compose.yml"), compose, 0644); err != nil {
+		return fmt.Errorf("failed to write compose file: %w", err)
+	}
+	if override != nil {
+		if err := os.WriteFile(filepath.Join(projectDir, "docker-compose.override.yml"), override, 0644); err != nil {
+			return fmt.Errorf("failed to write compose override file: %w", err)
+		}
+	}
+	if dotEnv != nil {
+		if err := os.WriteFile(filepath.Join(projectDir, ".env"), dotEnv, 0600); err != nil {
+			return fmt.Errorf("failed to write .env file: %w", err)
+		}
+	}
+	return nil
+}
+
+// firstExisting returns the first of names that is a file in dir
+func firstExisting(dir string, names []string) string {
+	for _, name := range names {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return name
+		}
+	}
+	return ""
+}
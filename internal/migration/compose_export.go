@@ -0,0 +1,248 @@
+package migration
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/models"
+)
+
+// composeExportFiles are the compose files of a project directory copied into
+// an export bundle
+var composeExportFiles = []string{
+	"docker-compose.yml",
+	"docker-compose.yaml",
+	"docker-compose.override.yml",
+	"docker-compose.override.yaml",
+}
+
+// composeExportConfigDir is the directory config files are rendered into
+const composeExportConfigDir = "config"
+
+// ComposeExporter bundles the deployments of a project as plain compose
+// projects, one directory each with its compose files and .env, and a README
+// on starting them with docker compose alone, so a project can leave this tool
+// without losing its setup
+type ComposeExporter struct {
+	db             *sql.DB
+	deploymentsDir string
+}
+
+// exportedStack is a deployment as written to an export bundle
+type exportedStack struct {
+	name       string
+	deployMode models.DeployMode
+	redacted   []string // Variables whose values were left out
+	missing    bool     // No compose file in its project directory
+}
+
+// NewComposeExporter creates a new compose bundle exporter
+func NewComposeExporter(db *sql.DB, deploymentsDir string) *ComposeExporter {
+	return &ComposeExporter{
+		db:             db,
+		deploymentsDir: deploymentsDir,
+	}
+}
+
+// Export writes a zip bundle of the deployments of a project. Unless
+// withSecrets, the values of secret variables are left empty in .env files and
+// rendered config files, which may hold them, are left out
+func (ce *ComposeExporter) Export(w io.Writer, project *models.Project, withSecrets bool) error {
+	rows, err := ce.db.Query(`
+		SELECT d.stack_name, COALESCE(d.deploy_mode, 'compose'), COALESCE(d.config, '{}'), COALESCE(t.variables, '[]')
+		FROM deployments d
+		LEFT JOIN templates t ON t.id = d.template_id
+		WHERE COALESCE(d.project_id, 'global') = $1
+		ORDER BY d.stack_name`, project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load deployments: %w", err)
+	}
+	type deployment struct {
+		stackName  string
+		deployMode models.DeployMode
+		config     string
+		variables  string
+	}
+	var deployments []deployment
+	for rows.Next() {
+		var d deployment
+		if err := rows.Scan(&d.stackName, &d.deployMode, &d.config, &d.variables); err == nil {
+			deployments = append(deployments, d)
+		}
+	}
+	rows.Close()
+
+	zipWriter := zip.NewWriter(w)
+	var stacks []exportedStack
+	for _, d := range deployments {
+		var config map[string]interface{}
+		json.Unmarshal([]byte(d.config), &config)
+		template := &models.Template{}
+		template.UnmarshalVariables(d.variables)
+
+		stack, err := ce.exportStack(zipWriter, d.stackName, config, template, withSecrets)
+		if err != nil {
+			return fmt.Errorf("stack %s: %w", d.stackName, err)
+		}
+		stack.deployMode = d.deployMode
+		stacks = append(stacks, stack)
+	}
+
+	readme, err := zipWriter.CreateHeader(&zip.FileHeader{Name: "README.md", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(readme, composeExportReadme(project, stacks, withSecrets)); err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+// exportStack writes the files of a deployment under a directory named after
+// its stack
+func (ce *ComposeExporter) exportStack(zipWriter *zip.Writer, stackName string, config map[string]interface{}, template *models.Template, withSecrets bool) (exportedStack, error) {
+	stack := exportedStack{name: stackName, missing: true}
+	projectDir := filepath.Join(ce.deploymentsDir, stackName)
+
+	for _, name := range composeExportFiles {
+		content, err := os.ReadFile(filepath.Join(projectDir, name))
+		if err != nil {
+			continue
+		}
+		if err := writeZipFile(zipWriter, stackName+"/"+name, content, 0644); err != nil {
+			return stack, err
+		}
+		stack.missing = false
+	}
+
+	secret := func(name string) bool {
+		if withSecrets {
+			return false
+		}
+		if variable := template.GetVariable(name); variable != nil && variable.Type == "password" {
+			return true
+		}
+		return docker.IsSecretVariable(name)
+	}
+
+	// The .env compose reads, comments included, or one from the deployment's
+	// variables when it has none
+	dotEnv, err := os.ReadFile(filepath.Join(projectDir, ".env"))
+	if err != nil {
+		env, _ := config["environment"].(map[string]interface{})
+		lines := make([]string, 0, len(env))
+		for key, value := range env {
+			lines = append(lines, fmt.Sprintf("%s=%v", key, value))
+		}
+		sort.Strings(lines)
+		dotEnv = []byte(strings.Join(lines, "\n"))
+	}
+	dotEnv, stack.redacted = redactEnv(dotEnv, secret)
+	if len(dotEnv) > 0 {
+		if err := writeZipFile(zipWriter, stackName+"/.env", dotEnv, 0600); err != nil {
+			return stack, err
+		}
+	}
+
+	if !withSecrets {
+		return stack, nil
+	}
+	configDir := filepath.Join(projectDir, composeExportConfigDir)
+	err = filepath.Walk(configDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(projectDir, path)
+		return writeZipFile(zipWriter, stackName+"/"+filepath.ToSlash(rel), content, info.Mode().Perm())
+	})
+	return stack, err
+}
+
+// redactEnv empties the values of the secret entries of .env content, keeping
+// comments and order, and returns the names of the entries it emptied
+func redactEnv(content []byte, secret func(name string) bool) ([]byte, []string) {
+	var out bytes.Buffer
+	var redacted []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimPrefix(strings.TrimSpace(line), "export ")
+		name, _, found := strings.Cut(trimmed, "=")
+		name = strings.TrimSpace(name)
+		if found && !strings.HasPrefix(trimmed, "#") && secret(name) {
+			line = name + "="
+			redacted = append(redacted, name)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), redacted
+}
+
+// writeZipFile adds a file to a bundle
+func writeZipFile(zipWriter *zip.Writer, name string, content []byte, mode os.FileMode) error {
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()}
+	header.SetMode(mode)
+	file, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	return err
+}
+
+// composeExportReadme renders the README of a bundle
+func composeExportReadme(project *models.Project, stacks []exportedStack, withSecrets bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", project.Name)
+	if project.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", project.Description)
+	}
+	fmt.Fprintf(&b, "Exported on %s. Each directory is a plain Docker Compose project and needs\n", time.Now().UTC().Format("2006-01-02 15:04 MST"))
+	b.WriteString("nothing but Docker to run.\n\n")
+
+	b.WriteString("## Starting the stacks\n\n")
+	if len(stacks) == 0 {
+		b.WriteString("The project had no deployments.\n\n")
+	}
+	for _, stack := range stacks {
+		fmt.Fprintf(&b, "### %s\n\n", stack.name)
+		if stack.missing {
+			b.WriteString("Its compose file was not found when exporting, so it cannot be started from this bundle.\n\n")
+			continue
+		}
+		if stack.deployMode == models.DeployModeSwarm {
+			fmt.Fprintf(&b, "```sh\ncd %s\nset -a; . ./.env; set +a\ndocker stack deploy -c docker-compose.yml %s\n```\n\n", stack.name, stack.name)
+		} else {
+			fmt.Fprintf(&b, "```sh\ncd %s\ndocker compose -p %s up -d\n```\n\n", stack.name, stack.name)
+		}
+		if len(stack.redacted) > 0 {
+			fmt.Fprintf(&b, "Set these in `.env` first: %s.\n\n", "`"+strings.Join(stack.redacted, "`, `")+"`")
+		}
+	}
+
+	b.WriteString("## Notes\n\n")
+	b.WriteString("- Volume data is not included; back it up separately, or keep the volumes on this host.\n")
+	b.WriteString("- Keeping the stack names as project names lets compose adopt the running containers and volumes.\n")
+	if !withSecrets {
+		b.WriteString("- Secret values were left out, and so were rendered config files, which may hold them.\n")
+		b.WriteString("  Export again with secrets to include both.\n")
+	}
+	return b.String()
+}
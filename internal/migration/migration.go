@@ -0,0 +1,394 @@
+package migration
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/models"
+)
+
+// BundleVersion is the layout version of migration bundles. A bundle is a gzipped
+// tar holding:
+//
+//	manifest.json
+//	database.db              snapshot of the SQLite database
+//	deployments/<stack>/...  project directory of every stack
+//	volumes/<name>/...       data of the stacks' volumes, when included
+const BundleVersion = 1
+
+// skippedTables are not carried over: they are bookkeeping of the migrations
+// applied, or only meaningful to the process that wrote them
+var skippedTables = map[string]bool{
+	"schema_migrations": true,
+	"sqlite_sequence":   true,
+	"sessions":          true,
+	"tasks":             true,
+}
+
+// Migrator exports an instance to a migration bundle and imports bundles into
+// another instance
+type Migrator struct {
+	db             *sql.DB
+	dockerClient   *client.Client
+	deploymentsDir string
+	workDir        string // Scratch space for database snapshots and extracted bundles
+}
+
+// NewMigrator creates a new migrator
+func NewMigrator(db *sql.DB, dockerClient *client.Client, deploymentsDir, workDir string) *Migrator {
+	return &Migrator{
+		db:             db,
+		dockerClient:   dockerClient,
+		deploymentsDir: deploymentsDir,
+		workDir:        workDir,
+	}
+}
+
+// Export writes a migration bundle to w
+func (m *Migrator) Export(w io.Writer, includeVolumes bool) error {
+	if err := os.MkdirAll(m.workDir, 0755); err != nil {
+		return err
+	}
+	scratch, err := os.MkdirTemp(m.workDir, "export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(scratch)
+
+	// VACUUM INTO gives a consistent snapshot while the instance keeps running
+	snapshot := filepath.Join(scratch, "database.db")
+	if _, err := m.db.Exec("VACUUM INTO ?", snapshot); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	manifest := models.MigrationManifest{
+		Version:        BundleVersion,
+		AppVersion:     "1.0.0",
+		CreatedAt:      time.Now(),
+		IncludeVolumes: includeVolumes,
+	}
+	m.db.QueryRow("SELECT COUNT(*) FROM deployments").Scan(&manifest.Deployments)
+	m.db.QueryRow("SELECT COUNT(*) FROM templates").Scan(&manifest.Templates)
+
+	var volumes []*volume.Volume
+	if includeVolumes {
+		volumes, err = m.stackVolumes()
+		if err != nil {
+			return err
+		}
+		for _, vol := range volumes {
+			manifest.Volumes = append(manifest.Volumes, models.MigrationVolume{
+				Name:   vol.Name,
+				Driver: vol.Driver,
+				Labels: vol.Labels,
+			})
+		}
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tarWriter, "manifest.json", manifestJSON); err != nil {
+		return err
+	}
+	if err := addTarPath(tarWriter, snapshot, "database.db"); err != nil {
+		return fmt.Errorf("failed to add database: %w", err)
+	}
+	if _, err := os.Stat(m.deploymentsDir); err == nil {
+		if err := addTarPath(tarWriter, m.deploymentsDir, "deployments"); err != nil {
+			return fmt.Errorf("failed to add deployments: %w", err)
+		}
+	}
+	for _, vol := range volumes {
+		if err := addTarPath(tarWriter, vol.Mountpoint, "volumes/"+vol.Name); err != nil {
+			return fmt.Errorf("failed to add volume %s: %w", vol.Name, err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// Import rehydrates this instance from a migration bundle read from r. Database
+// rows are inserted or replaced, project directories and volumes that already
+// exist are left untouched
+func (m *Migrator) Import(r io.Reader) (*models.MigrationResult, error) {
+	if err := os.MkdirAll(m.workDir, 0755); err != nil {
+		return nil, err
+	}
+	scratch, err := os.MkdirTemp(m.workDir, "import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratch)
+
+	if err := extractBundle(r, scratch); err != nil {
+		return nil, fmt.Errorf("failed to extract bundle: %w", err)
+	}
+
+	result := &models.MigrationResult{Tables: make(map[string]int64)}
+	manifestJSON, err := os.ReadFile(filepath.Join(scratch, "manifest.json"))
+	if err != nil {
+		return nil, fmt.Errorf("bundle has no manifest: %w", err)
+	}
+	if err := json.Unmarshal(manifestJSON, &result.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if result.Manifest.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", result.Manifest.Version)
+	}
+
+	if err := m.importDatabase(filepath.Join(scratch, "database.db"), result); err != nil {
+		return nil, fmt.Errorf("failed to import database: %w", err)
+	}
+	if err := m.importDeployments(filepath.Join(scratch, "deployments"), result); err != nil {
+		return nil, fmt.Errorf("failed to import deployments: %w", err)
+	}
+	if err := m.importVolumes(filepath.Join(scratch, "volumes"), result); err != nil {
+		return nil, fmt.Errorf("failed to import volumes: %w", err)
+	}
+
+	return result, nil
+}
+
+// importDatabase copies the rows of every table both databases have, limited to
+// the columns both have, so bundles from a slightly older schema still import
+func (m *Migrator) importDatabase(path string, result *models.MigrationResult) error {
+	ctx := context.Background()
+
+	// ATTACH applies to a single connection, so pin one for the whole import
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS bundle", path); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE bundle")
+
+	// Only tables of this instance's schema are copied, so names from the bundle
+	// never reach a query unchecked
+	tables, err := tableNames(ctx, conn, "main")
+	if err != nil {
+		return err
+	}
+	bundleTables, err := tableNames(ctx, conn, "bundle")
+	if err != nil {
+		return err
+	}
+	inBundle := make(map[string]bool, len(bundleTables))
+	for _, table := range bundleTables {
+		inBundle[table] = true
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Tables are copied in name order, so foreign keys are checked at commit
+	if _, err := tx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if skippedTables[table] || !inBundle[table] {
+			continue
+		}
+
+		columns, err := sharedColumns(ctx, tx, table)
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			continue
+		}
+
+		list := `"` + strings.Join(columns, `", "`) + `"`
+		query := fmt.Sprintf(`INSERT OR REPLACE INTO main."%s" (%s) SELECT %s FROM bundle."%s"`, table, list, list, table)
+		if table == "system_settings" {
+			// The telemetry instance ID identifies the old instance only, and bundles
+			// are often exported during a maintenance window of the old host
+			query += " WHERE key NOT LIKE 'telemetry_%' AND key <> 'maintenance'"
+		}
+
+		res, err := tx.ExecContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("table %s: %w", table, err)
+		}
+		result.Tables[table], _ = res.RowsAffected()
+	}
+
+	return tx.Commit()
+}
+
+// importDeployments copies the project directories of the bundle's stacks
+func (m *Migrator) importDeployments(dir string, result *models.MigrationResult) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		target := filepath.Join(m.deploymentsDir, entry.Name())
+		if _, err := os.Stat(target); err == nil {
+			result.Skipped = append(result.Skipped, "deployments/"+entry.Name())
+			continue
+		}
+		if err := copyDir(filepath.Join(dir, entry.Name()), target); err != nil {
+			return err
+		}
+		result.Deployments = append(result.Deployments, entry.Name())
+	}
+
+	return nil
+}
+
+// importVolumes creates the bundle's volumes with their original labels, so
+// compose adopts them, and fills them with the bundled data
+func (m *Migrator) importVolumes(dir string, result *models.MigrationResult) error {
+	ctx := context.Background()
+
+	for _, vol := range result.Manifest.Volumes {
+		if _, err := m.dockerClient.VolumeInspect(ctx, vol.Name); err == nil {
+			result.Skipped = append(result.Skipped, "volumes/"+vol.Name)
+			continue
+		}
+
+		created, err := m.dockerClient.VolumeCreate(ctx, volume.CreateOptions{
+			Name:   vol.Name,
+			Driver: vol.Driver,
+			Labels: vol.Labels,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create volume %s: %w", vol.Name, err)
+		}
+
+		src := filepath.Join(dir, vol.Name)
+		if _, err := os.Stat(src); err == nil {
+			if err := copyDir(src, created.Mountpoint); err != nil {
+				return fmt.Errorf("failed to restore volume %s: %w", vol.Name, err)
+			}
+		}
+		result.Volumes = append(result.Volumes, vol.Name)
+	}
+
+	return nil
+}
+
+// stackVolumes returns the volumes of every stack managed by this instance
+func (m *Migrator) stackVolumes() ([]*volume.Volume, error) {
+	rows, err := m.db.Query("SELECT stack_name FROM deployments")
+	if err != nil {
+		return nil, err
+	}
+	var stackNames []string
+	for rows.Next() {
+		var stackName string
+		if err := rows.Scan(&stackName); err == nil {
+			stackNames = append(stackNames, stackName)
+		}
+	}
+	rows.Close()
+
+	var volumes []*volume.Volume
+	for _, stackName := range stackNames {
+		list, err := m.dockerClient.VolumeList(context.Background(), volume.ListOptions{
+			Filters: filters.NewArgs(filters.Arg("label", "com.docker.compose.project="+stackName)),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list volumes: %w", err)
+		}
+		volumes = append(volumes, list.Volumes...)
+	}
+
+	return volumes, nil
+}
+
+func tableNames(ctx context.Context, conn *sql.Conn, schema string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT name FROM %s.sqlite_master WHERE type = 'table' ORDER BY name", schema))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// sharedColumns returns the columns a table has both in this database and the bundle
+func sharedColumns(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	columns := func(schema string) ([]string, error) {
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT name FROM pragma_table_info('%s', '%s')`, table, schema))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var names []string
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+		}
+		return names, rows.Err()
+	}
+
+	mainColumns, err := columns("main")
+	if err != nil {
+		return nil, err
+	}
+	bundleColumns, err := columns("bundle")
+	if err != nil {
+		return nil, err
+	}
+
+	inMain := make(map[string]bool, len(mainColumns))
+	for _, name := range mainColumns {
+		inMain[name] = true
+	}
+	var shared []string
+	for _, name := range bundleColumns {
+		if inMain[name] {
+			shared = append(shared, name)
+		}
+	}
+	return shared, nil
+}
@@ -0,0 +1,432 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/models"
+)
+
+// portainerTimeout bounds each request to the Portainer API
+const portainerTimeout = 30 * time.Second
+
+// PortainerImporter converts the stacks and app templates of a Portainer
+// instance into deployments and templates of this one. Stack templates point
+// at their repository like synced templates do. Each stack becomes a
+// deployment, with its compose file and environment written to its project
+// directory, and a template of its own holding its variables. Stacks keep
+// running under Portainer until they are redeployed from here
+type PortainerImporter struct {
+	db             *sql.DB
+	dockerClient   *client.Client
+	deploymentsDir string
+	httpClient     *http.Client
+}
+
+// NewPortainerImporter creates a new Portainer importer
+func NewPortainerImporter(db *sql.DB, dockerClient *client.Client, deploymentsDir string) *PortainerImporter {
+	return &PortainerImporter{
+		db:             db,
+		dockerClient:   dockerClient,
+		deploymentsDir: deploymentsDir,
+		httpClient:     &http.Client{Timeout: portainerTimeout},
+	}
+}
+
+// Import imports the stacks and templates of a validated request, read from
+// the Portainer API when it has a URL. Stacks and templates that already exist
+// here are skipped, so an import can be repeated
+func (pi *PortainerImporter) Import(ctx context.Context, req *models.PortainerImportRequest, requestedBy string) (*models.PortainerImportResult, error) {
+	stacks, templates := req.Stacks, req.Templates
+	if req.URL != "" {
+		var err error
+		if stacks, err = pi.fetchStacks(ctx, req); err != nil {
+			return nil, fmt.Errorf("failed to read Portainer stacks: %w", err)
+		}
+		if templates, err = pi.fetchTemplates(ctx, req); err != nil {
+			return nil, fmt.Errorf("failed to read Portainer templates: %w", err)
+		}
+	}
+
+	projectID := req.ProjectID
+	if projectID == "" {
+		projectID = models.DefaultProjectID
+	}
+
+	result := &models.PortainerImportResult{DryRun: req.DryRun, Templates: []string{}, Deployments: []string{}}
+	categories := pi.categories()
+	for _, t := range templates {
+		pi.importTemplate(t, categories, req.DryRun, result)
+	}
+	for _, stack := range stacks {
+		if req.EndpointID != 0 && stack.EndpointID != req.EndpointID {
+			continue
+		}
+		pi.importStack(ctx, stack, projectID, requestedBy, req.DryRun, result)
+	}
+	return result, nil
+}
+
+// importTemplate imports an app template. Only stack templates can be: container
+// templates have no compose file
+func (pi *PortainerImporter) importTemplate(t models.PortainerTemplate, categories map[string]string, dryRun bool, result *models.PortainerImportResult) {
+	if t.Type == models.PortainerTemplateContainer {
+		result.Skip("template", t.Title, "container templates have no compose file")
+		return
+	}
+	if t.Repository == nil || t.Repository.URL == "" || t.Repository.StackFile == "" {
+		result.Skip("template", t.Title, "template has no repository")
+		return
+	}
+
+	id := "portainer-" + templateSlug(t.Title)
+	if templateExists(pi.db, id) {
+		result.Skip("template", t.Title, "template already imported")
+		return
+	}
+
+	template := &models.Template{
+		ID:          id,
+		Name:        t.Title,
+		Description: t.Description,
+		Icon:        t.Logo,
+		Tags:        append([]string{"portainer"}, t.Categories...),
+		RepoURL:     t.Repository.URL,
+		Branch:      "main",
+		Path:        "/" + strings.TrimPrefix(path.Dir(t.Repository.StackFile), "."),
+		Version:     "1.0.0",
+		Variables:   portainerVariables(t.Env),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	for _, category := range t.Categories {
+		if name, ok := categories[strings.ToLower(category)]; ok {
+			template.Category = name
+			break
+		}
+	}
+
+	if !dryRun {
+		if err := saveTemplate(pi.db, template); err != nil {
+			result.Skip("template", t.Title, err.Error())
+			return
+		}
+	}
+	result.Templates = append(result.Templates, id)
+}
+
+// importStack imports a stack as a deployment with a template of its own
+func (pi *PortainerImporter) importStack(ctx context.Context, stack models.PortainerStack, projectID, requestedBy string, dryRun bool, result *models.PortainerImportResult) {
+	stackName := strings.ToLower(stack.Name)
+	if !models.IsValidStackName(stackName) {
+		result.Skip("stack", stack.Name, "not a valid stack name")
+		return
+	}
+	if stack.StackFileContent == "" {
+		result.Skip("stack", stack.Name, "stack has no compose file content")
+		return
+	}
+
+	var exists bool
+	pi.db.QueryRow("SELECT EXISTS(SELECT 1 FROM deployments WHERE stack_name = $1)", stackName).Scan(&exists)
+	if exists {
+		result.Skip("stack", stack.Name, "a deployment with this stack name already exists")
+		return
+	}
+	projectDir := filepath.Join(pi.deploymentsDir, stackName)
+	if _, err := os.Stat(projectDir); err == nil {
+		result.Skip("stack", stack.Name, "the project directory already exists")
+		return
+	}
+
+	env := make(map[string]string, len(stack.Env))
+	envVars := make([]models.PortainerTemplateEnv, 0, len(stack.Env))
+	for _, v := range stack.Env {
+		env[v.Name] = v.Value
+		envVars = append(envVars, models.PortainerTemplateEnv{Name: v.Name, Label: v.Name})
+	}
+
+	deployMode := models.DeployModeCompose
+	label := "com.docker.compose.project"
+	if stack.Type == models.PortainerStackSwarm {
+		deployMode = models.DeployModeSwarm
+		label = "com.docker.stack.namespace"
+	}
+
+	template := &models.Template{
+		ID:          "portainer-stack-" + templateSlug(stackName),
+		Name:        stack.Name,
+		Description: fmt.Sprintf("Imported from the Portainer stack %s", stack.Name),
+		Tags:        []string{"portainer"},
+		Branch:      "main",
+		Path:        "/",
+		Version:     "1.0.0",
+		Variables:   portainerVariables(envVars),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if git := stack.GitConfig; git != nil && git.URL != "" {
+		template.RepoURL = git.URL
+		template.Branch = strings.TrimPrefix(git.ReferenceName, "refs/heads/")
+		template.Path = "/" + strings.TrimPrefix(path.Dir(git.ConfigFilePath), ".")
+	}
+
+	if dryRun {
+		result.Deployments = append(result.Deployments, stackName)
+		return
+	}
+
+	if !templateExists(pi.db, template.ID) {
+		if err := saveTemplate(pi.db, template); err != nil {
+			result.Skip("stack", stack.Name, err.Error())
+			return
+		}
+	}
+	if err := writeProjectFiles(projectDir, stack.StackFileContent, env); err != nil {
+		os.RemoveAll(projectDir)
+		result.Skip("stack", stack.Name, err.Error())
+		return
+	}
+
+	status := models.StatusStopped
+	if stackRunning(ctx, pi.dockerClient, label, stackName) {
+		status = models.StatusRunning
+	}
+	deployment := &models.Deployment{
+		ID:              fmt.Sprintf("deploy_%d", time.Now().UnixNano()),
+		TemplateID:      template.ID,
+		StackName:       stackName,
+		ProjectID:       projectID,
+		Status:          status,
+		DeployMode:      deployMode,
+		Config:          map[string]interface{}{"environment": env, "imported_from": "portainer"},
+		ResourceVersion: 1,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	if err := insertDeployment(pi.db, deployment, requestedBy); err != nil {
+		os.RemoveAll(projectDir)
+		result.Skip("stack", stack.Name, err.Error())
+		return
+	}
+	result.Deployments = append(result.Deployments, stackName)
+}
+
+// fetchStacks lists the stacks of a Portainer instance with their compose files
+func (pi *PortainerImporter) fetchStacks(ctx context.Context, req *models.PortainerImportRequest) ([]models.PortainerStack, error) {
+	var stacks []models.PortainerStack
+	if err := pi.get(ctx, req, "/api/stacks", &stacks); err != nil {
+		return nil, err
+	}
+	for i := range stacks {
+		if req.EndpointID != 0 && stacks[i].EndpointID != req.EndpointID {
+			continue
+		}
+		var file struct {
+			StackFileContent string `json:"StackFileContent"`
+		}
+		if err := pi.get(ctx, req, fmt.Sprintf("/api/stacks/%d/file", stacks[i].ID), &file); err != nil {
+			return nil, fmt.Errorf("stack %s: %w", stacks[i].Name, err)
+		}
+		stacks[i].StackFileContent = file.StackFileContent
+	}
+	return stacks, nil
+}
+
+// fetchTemplates reads the app templates a Portainer instance is configured with
+func (pi *PortainerImporter) fetchTemplates(ctx context.Context, req *models.PortainerImportRequest) ([]models.PortainerTemplate, error) {
+	var file struct {
+		Templates []models.PortainerTemplate `json:"templates"`
+	}
+	if err := pi.get(ctx, req, "/api/templates", &file); err != nil {
+		return nil, err
+	}
+	return file.Templates, nil
+}
+
+// get decodes the JSON response of a Portainer API request
+func (pi *PortainerImporter) get(ctx context.Context, req *models.PortainerImportRequest, endpoint string, v interface{}) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(req.URL, "/")+endpoint, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("X-API-Key", req.APIKey)
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := pi.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		return fmt.Errorf("GET %s: Portainer responded with %s", endpoint, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// stackRunning reports whether containers of a stack are running on this host
+func stackRunning(ctx context.Context, dockerClient *client.Client, label, stackName string) bool {
+	containers, err := dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", label+"="+stackName)),
+	})
+	return err == nil && len(containers) > 0
+}
+
+// insertDeployment records an imported deployment
+func insertDeployment(db *sql.DB, deployment *models.Deployment, requestedBy string) error {
+	configJSON, _ := deployment.MarshalConfig()
+	_, err := db.Exec(`
+		INSERT INTO deployments (id, template_id, stack_name, project_id, status, deploy_mode, config,
+		                         resource_version, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		deployment.ID, deployment.TemplateID, deployment.StackName, deployment.ProjectID, deployment.Status,
+		deployment.DeployMode, configJSON, deployment.ResourceVersion, requestedBy, deployment.CreatedAt, deployment.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save deployment: %w", err)
+	}
+	return nil
+}
+
+// templateExists reports whether a template is already stored
+func templateExists(db *sql.DB, id string) bool {
+	var exists bool
+	db.QueryRow("SELECT EXISTS(SELECT 1 FROM templates WHERE id = $1)", id).Scan(&exists)
+	return exists
+}
+
+// saveTemplate stores an imported template with its tags
+func saveTemplate(db *sql.DB, template *models.Template) error {
+	template.Tags = models.NormalizeTags(template.Tags)
+	tagsJSON, _ := template.MarshalTags()
+	variablesJSON, _ := template.MarshalVariables()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO templates (id, name, description, icon, category, tags, repo_url, branch, path, version,
+		                       variables, requires_newt, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		template.ID, template.Name, template.Description, template.Icon, template.Category, tagsJSON,
+		template.RepoURL, template.Branch, template.Path, template.Version, variablesJSON, false,
+		template.CreatedAt, template.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save template: %w", err)
+	}
+	for _, tag := range template.Tags {
+		if _, err := tx.Exec("INSERT INTO template_tags (template_id, tag) VALUES ($1, $2)", template.ID, tag); err != nil {
+			return fmt.Errorf("failed to save template tags: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// categories maps the lowercased names of the marketplace categories to their
+// names, to match Portainer's free-form categories
+func (pi *PortainerImporter) categories() map[string]string {
+	categories := make(map[string]string)
+	rows, err := pi.db.Query("SELECT name FROM categories")
+	if err != nil {
+		return categories
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			categories[strings.ToLower(name)] = name
+		}
+	}
+	return categories
+}
+
+// portainerVariables converts the variables of a Portainer template. Preset
+// variables keep their value as default
+func portainerVariables(env []models.PortainerTemplateEnv) []models.TemplateVariable {
+	variables := make([]models.TemplateVariable, 0, len(env))
+	for _, e := range env {
+		variable := models.TemplateVariable{
+			Name:         e.Name,
+			Label:        e.Label,
+			Description:  e.Description,
+			Type:         "text",
+			DefaultValue: e.Default,
+		}
+		if variable.Label == "" {
+			variable.Label = e.Name
+		}
+		if e.Preset && variable.Description == "" {
+			variable.Description = "Preset by the Portainer template"
+		}
+		if len(e.Select) > 0 {
+			variable.Type = "select"
+			for _, option := range e.Select {
+				variable.Options = append(variable.Options, models.TemplateVariableOption{Value: option.Value, Label: option.Text})
+				if option.Default {
+					variable.DefaultValue = option.Value
+				}
+			}
+		}
+		variables = append(variables, variable)
+	}
+	return variables
+}
+
+// writeProjectFiles writes the compose file and .env of an imported stack
+// to its project directory
+func writeProjectFiles(projectDir, compose string, env map[string]string) error {
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		return fmt.Errorf("failed to create project directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "docker-compose.yml"), []byte(compose), 0644); err != nil {
+		return fmt.Errorf("failed to write compose file: %w", err)
+	}
+	if len(env) == 0 {
+		return nil
+	}
+
+	lines := make([]string, 0, len(env))
+	for key, value := range env {
+		lines = append(lines, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(lines)
+	if err := os.WriteFile(filepath.Join(projectDir, ".env"), []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write .env file: %w", err)
+	}
+	return nil
+}
+
+// templateSlug turns a stack or template name into part of a template ID
+func templateSlug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
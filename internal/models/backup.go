@@ -1,8 +1,14 @@
 package models
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/robfig/cron/v3"
 )
 
 // BackupStatus represents the current status of a backup
@@ -34,21 +40,125 @@ type Backup struct {
 	Encrypted      bool           `json:"encrypted" db:"encrypted"`
 	StoragePath    string         `json:"storage_path" db:"storage_path"`
 	DeploymentIDs  []string       `json:"deployment_ids" db:"deployment_ids"`
+	KeyStorage     string         `json:"key_storage,omitempty" db:"key_storage"` // Where the key of an encrypted backup is kept
+	KeyMetadata    string         `json:"-" db:"key_metadata"`                    // What the key storage needs to recover the key
 	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
 	CompletedAt    *time.Time     `json:"completed_at" db:"completed_at"`
 }
 
 // BackupSchedule represents a scheduled backup configuration
 type BackupSchedule struct {
-	ID             int        `json:"id" db:"id"`
-	Name           string     `json:"name" db:"name"`
-	CronExpression string     `json:"cron_expression" db:"cron_expression"`
-	IncludeVolumes bool       `json:"include_volumes" db:"include_volumes"`
-	Encrypt        bool       `json:"encrypt" db:"encrypt"`
-	Enabled        bool       `json:"enabled" db:"enabled"`
-	LastRun        *time.Time `json:"last_run" db:"last_run"`
-	NextRun        *time.Time `json:"next_run" db:"next_run"`
-	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	ID             int              `json:"id" db:"id"`
+	Name           string           `json:"name" db:"name"`
+	CronExpression string           `json:"cron_expression" db:"cron_expression"`
+	Timezone       string           `json:"timezone" db:"timezone"` // IANA name, UTC when empty
+	Description    string           `json:"description" db:"-"`
+	IncludeVolumes bool             `json:"include_volumes" db:"include_volumes"`
+	Encrypt        bool             `json:"encrypt" db:"encrypt"`
+	Enabled        bool             `json:"enabled" db:"enabled"`
+	Selector       ScheduleSelector `json:"selector" db:"selector"`
+	LastRun        *time.Time       `json:"last_run" db:"last_run"`
+	NextRun        *time.Time       `json:"next_run" db:"next_run"`
+	CreatedAt      time.Time        `json:"created_at" db:"created_at"`
+}
+
+// ScheduleSelector chooses the running deployments a scheduled backup includes. A
+// deployment is included when its ID is listed, it belongs to one of the projects,
+// its template is in one of the categories, or a container of its stack matches
+// every label expression. An empty selector includes all running deployments
+type ScheduleSelector struct {
+	DeploymentIDs []string `json:"deployment_ids,omitempty"`
+	Projects      []string `json:"projects,omitempty"`
+	Categories    []string `json:"categories,omitempty"`
+	Labels        []string `json:"labels,omitempty"` // key, !key, key=value or key!=value
+}
+
+// IsEmpty reports whether the selector includes all running deployments
+func (ss *ScheduleSelector) IsEmpty() bool {
+	return len(ss.DeploymentIDs) == 0 && len(ss.Projects) == 0 && len(ss.Categories) == 0 && len(ss.Labels) == 0
+}
+
+// Validate checks that every label expression parses
+func (ss *ScheduleSelector) Validate() error {
+	for _, expr := range ss.Labels {
+		if _, err := ParseLabelExpression(expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchesLabels reports whether labels satisfy every label expression of the
+// selector. It is false when the selector has no label expressions
+func (ss *ScheduleSelector) MatchesLabels(labels map[string]string) bool {
+	if len(ss.Labels) == 0 {
+		return false
+	}
+	for _, expr := range ss.Labels {
+		le, err := ParseLabelExpression(expr)
+		if err != nil || !le.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// LabelExpression is a single condition on a label
+type LabelExpression struct {
+	Key    string
+	Value  string
+	Negate bool // Label absent, or set to a different value
+	Exists bool // Only the presence of the key is tested
+}
+
+// ParseLabelExpression parses key, !key, key=value or key!=value
+func ParseLabelExpression(expr string) (LabelExpression, error) {
+	expr = strings.TrimSpace(expr)
+
+	var le LabelExpression
+	switch {
+	case strings.Contains(expr, "!="):
+		parts := strings.SplitN(expr, "!=", 2)
+		le = LabelExpression{Key: parts[0], Value: parts[1], Negate: true}
+	case strings.Contains(expr, "="):
+		parts := strings.SplitN(expr, "=", 2)
+		le = LabelExpression{Key: parts[0], Value: parts[1]}
+	case strings.HasPrefix(expr, "!"):
+		le = LabelExpression{Key: expr[1:], Negate: true, Exists: true}
+	default:
+		le = LabelExpression{Key: expr, Exists: true}
+	}
+
+	le.Key = strings.TrimSpace(le.Key)
+	le.Value = strings.TrimSpace(le.Value)
+	if le.Key == "" || strings.ContainsAny(le.Key, "!= ") {
+		return LabelExpression{}, fmt.Errorf("%w: %q", ErrLabelExpressionInvalid, expr)
+	}
+	return le, nil
+}
+
+// Matches reports whether labels satisfy the expression
+func (le LabelExpression) Matches(labels map[string]string) bool {
+	value, ok := labels[le.Key]
+	if le.Exists {
+		return ok != le.Negate
+	}
+	return (ok && value == le.Value) != le.Negate
+}
+
+// MarshalSelector converts the selector to JSON for database storage
+func (bs *BackupSchedule) MarshalSelector() (string, error) {
+	data, err := json.Marshal(bs.Selector)
+	return string(data), err
+}
+
+// UnmarshalSelector converts the stored JSON selector
+func (bs *BackupSchedule) UnmarshalSelector(data string) error {
+	bs.Selector = ScheduleSelector{}
+	if data == "" || data == "null" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &bs.Selector)
 }
 
 // BackupConfig holds configuration for creating a backup
@@ -62,6 +172,8 @@ type BackupConfig struct {
 	EnvConfigs      map[string]interface{} `json:"env_configs"`
 	NewtConfigs     map[string]interface{} `json:"newt_configs"`
 	StorageConfig   *StorageConfig         `json:"storage_config,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`   // Recorded in the backup metadata
+	Passphrase      string                 `json:"passphrase,omitempty"` // Derives the key with passphrase key storage
 }
 
 // DeploymentBackup represents backup data for a single deployment
@@ -94,6 +206,85 @@ type VolumeBackup struct {
 	SizeBytes  int64  `json:"size_bytes"`
 }
 
+// BackupHook dumps the data of a service with its own tools while a backup is
+// taken, as copying the files of a running database can archive them torn. Dump
+// runs with sh in the service's container and writes the dump to stdout.
+// Restore runs there once a restore has brought the services up, reading the
+// dump from stdin. Volumes are the compose volumes the dump covers, whose files
+// are not copied while the service runs
+type BackupHook struct {
+	Name    string   `json:"name"` // Names the dump in the archive; the service unless set
+	Service string   `json:"service"`
+	Dump    string   `json:"dump"`              // Such as pg_dump -U "$POSTGRES_USER" "$POSTGRES_DB"
+	Restore string   `json:"restore,omitempty"` // Such as psql -U "$POSTGRES_USER" "$POSTGRES_DB"
+	Volumes []string `json:"volumes,omitempty"`
+}
+
+// DumpName returns the name of the hook's dump
+func (h BackupHook) DumpName() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return h.Service
+}
+
+// Validate checks that a hook names its service and dump command, and that its
+// dump name is usable as a file name
+func (h BackupHook) Validate() error {
+	if h.Service == "" {
+		return ErrBackupHookService
+	}
+	if strings.TrimSpace(h.Dump) == "" {
+		return ErrBackupHookDump
+	}
+	if !isValidStackName(h.DumpName()) {
+		return fmt.Errorf("%w: %q", ErrBackupHookName, h.DumpName())
+	}
+	return nil
+}
+
+// DumpBackup is a dump a backup hook took, saved in the archive
+type DumpBackup struct {
+	Name      string `json:"name"`
+	Service   string `json:"service"`
+	DataPath  string `json:"data_path"` // Path in backup archive
+	SizeBytes int64  `json:"size_bytes"`
+	Restore   string `json:"restore,omitempty"` // Kept so the dump restores as taken, whatever the template says later
+}
+
+// BackupContents is the index of a backup archive, read without restoring it
+type BackupContents struct {
+	BackupID    string               `json:"backup_id"`
+	Metadata    *BackupMetadata      `json:"metadata,omitempty"`
+	Deployments []DeploymentContents `json:"deployments"`
+	SizeBytes   int64                `json:"size_bytes"` // Uncompressed size of all files
+}
+
+// DeploymentContents lists what a backup archive holds for one deployment
+type DeploymentContents struct {
+	ID         string           `json:"id"`
+	StackName  string           `json:"stack_name"`
+	TemplateID string           `json:"template_id"`
+	Files      []BackupFile     `json:"files"` // Compose and .env files
+	Volumes    []VolumeContents `json:"volumes"`
+	Dumps      []BackupFile     `json:"dumps"` // Taken by backup hooks
+}
+
+// BackupFile is a file of a backup archive. Path is its path in the archive, as
+// accepted by the file download endpoint
+type BackupFile struct {
+	Path      string `json:"path"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// VolumeContents is the data of a volume in a backup archive
+type VolumeContents struct {
+	Name      string `json:"name"`
+	Driver    string `json:"driver,omitempty"`
+	Files     int    `json:"files"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
 // StorageConfig represents storage configuration for backups
 type StorageConfig struct {
 	Type        string     `json:"type"` // local, s3
@@ -109,6 +300,20 @@ type RestoreConfig struct {
 	OverwriteExisting bool  `json:"overwrite_existing"`
 	RestoreVolumes bool     `json:"restore_volumes"`
 	TestRestore    bool     `json:"test_restore"`
+	Passphrase     string   `json:"passphrase,omitempty"` // For backups encrypted with a passphrase
+
+	// StackNames maps original stack names to new ones. A mapped deployment is
+	// restored as a new deployment alongside the original, with conflicting host
+	// ports remapped and its volumes created under the new stack name
+	StackNames map[string]string `json:"stack_names,omitempty"`
+}
+
+// RemoteRestoreConfig restores an archive streamed from a URL, such as a presigned
+// S3 URL, instead of a stored backup
+type RemoteRestoreConfig struct {
+	URL      string `json:"url"`
+	Checksum string `json:"checksum,omitempty"` // Hex SHA-256 of the archive, verified before restoring
+	RestoreConfig
 }
 
 // BackupMetadata contains metadata about a backup
@@ -165,6 +370,27 @@ func (b *Backup) GetDuration() time.Duration {
 	return b.CompletedAt.Sub(b.CreatedAt)
 }
 
+// Validation errors
+var (
+	ErrBackupNameRequired      = fmt.Errorf("backup name is required")
+	ErrBackupNoDeployments     = fmt.Errorf("backup must include at least one deployment")
+	ErrRestoreBackupRequired   = fmt.Errorf("backup ID is required")
+	ErrRestoreNoDeployments    = fmt.Errorf("selective restore requires deployment IDs")
+	ErrLabelExpressionInvalid  = fmt.Errorf("invalid label expression")
+	ErrRestoreURLRequired      = fmt.Errorf("restore URL is required")
+	ErrRestoreURLInvalid       = fmt.Errorf("restore URL must be http or https")
+	ErrRestoreChecksumInvalid  = fmt.Errorf("checksum must be a hex SHA-256")
+	ErrRestoreStackNameInvalid = fmt.Errorf("invalid stack name in stack_names")
+	ErrRestoreStackNameReused  = fmt.Errorf("stack_names maps two stacks to the same name")
+	ErrScheduleNameRequired    = fmt.Errorf("schedule name is required")
+	ErrScheduleCronRequired    = fmt.Errorf("cron expression is required")
+	ErrScheduleCronInvalid     = fmt.Errorf("invalid cron expression")
+	ErrScheduleTimezoneInvalid = fmt.Errorf("invalid schedule timezone")
+	ErrBackupHookService       = fmt.Errorf("backup hook service is required")
+	ErrBackupHookDump          = fmt.Errorf("backup hook dump command is required")
+	ErrBackupHookName          = fmt.Errorf("backup hook name may contain only letters, digits, '-' and '_'")
+)
+
 // Validate validates backup configuration
 func (bc *BackupConfig) Validate() error {
 	if bc.Name == "" {
@@ -203,17 +429,186 @@ func (bs *BackupSchedule) IsOverdue() bool {
 	return time.Now().After(*bs.NextRun)
 }
 
-// UpdateNextRun updates the next run time based on cron expression
+// Validate validates the schedule's name, cron expression, time zone and selector
+func (bs *BackupSchedule) Validate() error {
+	if bs.Name == "" {
+		return ErrScheduleNameRequired
+	}
+	if _, err := bs.parse(); err != nil {
+		return err
+	}
+	return bs.Selector.Validate()
+}
+
+// Location returns the time zone the cron expression is evaluated in
+func (bs *BackupSchedule) Location() (*time.Location, error) {
+	if bs.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(bs.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrScheduleTimezoneInvalid, bs.Timezone)
+	}
+	return loc, nil
+}
+
+// CronSpec returns the cron expression with the schedule's time zone applied, as
+// accepted by the cron scheduler
+func (bs *BackupSchedule) CronSpec() string {
+	if bs.Timezone == "" {
+		return bs.CronExpression
+	}
+	return "CRON_TZ=" + bs.Timezone + " " + bs.CronExpression
+}
+
+// parse parses the cron expression in the schedule's time zone
+func (bs *BackupSchedule) parse() (cron.Schedule, error) {
+	expr := strings.TrimSpace(bs.CronExpression)
+	if expr == "" {
+		return nil, ErrScheduleCronRequired
+	}
+	// The time zone has its own field so it can be validated and displayed
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		return nil, fmt.Errorf("%w: set the timezone field instead of a TZ prefix", ErrScheduleCronInvalid)
+	}
+	if _, err := bs.Location(); err != nil {
+		return nil, err
+	}
+
+	schedule, err := cron.ParseStandard(bs.CronSpec())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrScheduleCronInvalid, err)
+	}
+	return schedule, nil
+}
+
+// UpdateNextRun sets the next run time to the first activation of the cron
+// expression after now
 func (bs *BackupSchedule) UpdateNextRun() error {
-	// This would use a cron parser to calculate next run time
-	// Placeholder implementation
-	if bs.NextRun == nil {
-		nextRun := time.Now().Add(24 * time.Hour)
-		bs.NextRun = &nextRun
+	schedule, err := bs.parse()
+	if err != nil {
+		return err
 	}
+	nextRun := schedule.Next(time.Now())
+	bs.NextRun = &nextRun
 	return nil
 }
 
+// Describe fills in the human-readable description of the schedule
+func (bs *BackupSchedule) Describe() {
+	bs.Description = DescribeCron(bs.CronExpression)
+	if bs.Timezone != "" {
+		bs.Description += " (" + bs.Timezone + ")"
+	} else {
+		bs.Description += " (UTC)"
+	}
+}
+
+var (
+	cronWeekdays = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	cronMonths   = []string{"", "January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"}
+	cronDescriptors = map[string]string{
+		"@yearly":   "At 00:00 on January 1",
+		"@annually": "At 00:00 on January 1",
+		"@monthly":  "At 00:00 on day 1 of every month",
+		"@weekly":   "At 00:00 on Sunday",
+		"@daily":    "At 00:00 every day",
+		"@midnight": "At 00:00 every day",
+		"@hourly":   "At minute 0 of every hour",
+	}
+)
+
+// DescribeCron returns a human-readable description of a standard cron expression,
+// falling back to the expression itself for forms it cannot describe
+func DescribeCron(expr string) string {
+	expr = strings.TrimSpace(expr)
+	if desc, ok := cronDescriptors[expr]; ok {
+		return desc
+	}
+	if strings.HasPrefix(expr, "@every ") {
+		return "Every " + strings.TrimSpace(strings.TrimPrefix(expr, "@every "))
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return expr
+	}
+	minute, hour, dom, month, dow := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+	var desc string
+	switch {
+	case minute == "*" && hour == "*":
+		desc = "Every minute"
+	case strings.HasPrefix(minute, "*/") && hour == "*":
+		desc = "Every " + minute[2:] + " minutes"
+	case isCronNumber(minute) && isCronNumber(hour):
+		h, _ := strconv.Atoi(hour)
+		m, _ := strconv.Atoi(minute)
+		desc = fmt.Sprintf("At %02d:%02d", h, m)
+	case isCronNumber(minute) && hour == "*":
+		desc = "At minute " + minute + " of every hour"
+	case isCronNumber(minute) && strings.HasPrefix(hour, "*/"):
+		desc = "At minute " + minute + " of every " + hour[2:] + " hours"
+	default:
+		desc = "At minute " + minute + " past hour " + hour
+	}
+
+	if dom != "*" {
+		desc += " on day " + describeCronList(dom, nil) + " of the month"
+	}
+	if dow != "*" {
+		if dom != "*" {
+			desc += " and"
+		}
+		desc += " on " + describeCronList(dow, cronWeekdays)
+	}
+	if month != "*" {
+		desc += " in " + describeCronList(month, cronMonths)
+	} else if dom == "*" && dow == "*" && isCronNumber(hour) {
+		desc += " every day"
+	}
+
+	return desc
+}
+
+// describeCronList describes a cron field of lists and ranges, naming values
+// through names when given
+func describeCronList(field string, names []string) string {
+	name := func(value string) string {
+		n, err := strconv.Atoi(value)
+		if err != nil || names == nil {
+			return value
+		}
+		if n == 7 && len(names) == 7 {
+			n = 0 // Sunday may be written as 7
+		}
+		if n < 0 || n >= len(names) || names[n] == "" {
+			return value
+		}
+		return names[n]
+	}
+
+	var parts []string
+	for _, item := range strings.Split(field, ",") {
+		if bounds := strings.SplitN(item, "-", 2); len(bounds) == 2 && !strings.Contains(item, "/") {
+			parts = append(parts, name(bounds[0])+" through "+name(bounds[1]))
+			continue
+		}
+		parts = append(parts, name(item))
+	}
+
+	if len(parts) <= 1 {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
+
+func isCronNumber(field string) bool {
+	_, err := strconv.Atoi(field)
+	return err == nil
+}
+
 // Validate validates restore configuration
 func (rc *RestoreConfig) Validate() error {
 	if rc.BackupID == "" {
@@ -222,9 +617,52 @@ func (rc *RestoreConfig) Validate() error {
 	if rc.Selective && len(rc.DeploymentIDs) == 0 {
 		return ErrRestoreNoDeployments
 	}
+	return rc.validateStackNames()
+}
+
+// validateStackNames checks that stacks are restored under valid, distinct names
+func (rc *RestoreConfig) validateStackNames() error {
+	targets := make(map[string]bool, len(rc.StackNames))
+	for _, target := range rc.StackNames {
+		if !isValidStackName(target) {
+			return fmt.Errorf("%w: %q", ErrRestoreStackNameInvalid, target)
+		}
+		if targets[target] {
+			return fmt.Errorf("%w: %q", ErrRestoreStackNameReused, target)
+		}
+		targets[target] = true
+	}
 	return nil
 }
 
+// TargetStack returns the name a stack is restored as, and whether it differs
+// from the original
+func (rc *RestoreConfig) TargetStack(stackName string) (string, bool) {
+	if target, ok := rc.StackNames[stackName]; ok && target != stackName {
+		return target, true
+	}
+	return stackName, false
+}
+
+// Validate validates remote restore configuration
+func (rc *RemoteRestoreConfig) Validate() error {
+	if rc.URL == "" {
+		return ErrRestoreURLRequired
+	}
+	if !strings.HasPrefix(rc.URL, "https://") && !strings.HasPrefix(rc.URL, "http://") {
+		return ErrRestoreURLInvalid
+	}
+	if rc.Checksum != "" {
+		if _, err := hex.DecodeString(rc.Checksum); err != nil || len(rc.Checksum) != 64 {
+			return ErrRestoreChecksumInvalid
+		}
+	}
+	if rc.Selective && len(rc.DeploymentIDs) == 0 {
+		return ErrRestoreNoDeployments
+	}
+	return rc.validateStackNames()
+}
+
 // HasDeployment checks if a deployment ID is included in selective restore
 func (rc *RestoreConfig) HasDeployment(deploymentID string) bool {
 	if !rc.Selective {
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Category groups marketplace templates. Templates reference it by name
+type Category struct {
+	Name        string    `json:"name" db:"name"`
+	DisplayName string    `json:"display_name" db:"display_name"`
+	Description string    `json:"description" db:"description"`
+	Icon        string    `json:"icon" db:"icon"`
+	Keywords    []string  `json:"keywords" db:"keywords"` // Matched to categorize discovered repositories
+	Position    int       `json:"position" db:"position"`
+	Templates   int       `json:"templates" db:"-"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CategoryReassignRequest moves templates of one category to another, all of
+// them unless template IDs are given
+type CategoryReassignRequest struct {
+	To          string   `json:"to"`
+	TemplateIDs []string `json:"template_ids,omitempty"`
+}
+
+var categoryNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Validation errors
+var (
+	ErrCategoryNameInvalid     = fmt.Errorf("category name must be lowercase letters, digits and hyphens")
+	ErrCategoryNotFound        = fmt.Errorf("category not found")
+	ErrCategoryInUse           = fmt.Errorf("category has templates; reassign them first or pass reassign_to")
+	ErrCategoryReassignInvalid = fmt.Errorf("reassignment requires a different target category")
+)
+
+// Validate validates category data, defaulting the display name to the name
+func (c *Category) Validate() error {
+	if !categoryNamePattern.MatchString(c.Name) {
+		return ErrCategoryNameInvalid
+	}
+	if strings.TrimSpace(c.DisplayName) == "" {
+		c.DisplayName = c.Name
+	}
+	keywords := make([]string, 0, len(c.Keywords))
+	for _, keyword := range c.Keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	c.Keywords = keywords
+	return nil
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CertificateStatus represents the state of an ACME certificate
+type CertificateStatus string
+
+const (
+	CertificatePending CertificateStatus = "pending" // Being issued for the first time
+	CertificateIssued  CertificateStatus = "issued"
+	// CertificateFailed is set when issuance or renewal failed. A certificate issued
+	// before keeps being served until it expires
+	CertificateFailed CertificateStatus = "failed"
+)
+
+// ACME challenge types
+const (
+	ChallengeHTTP01 = "http-01"
+	ChallengeDNS01  = "dns-01"
+)
+
+// maxCertificateDomains is the most names Let's Encrypt puts on one certificate
+const maxCertificateDomains = 100
+
+// Certificate is a TLS certificate obtained over ACME for services exposed on host
+// ports. Its certificate and key files are kept under the ACME storage path
+type Certificate struct {
+	ID        string            `json:"id" db:"id"`
+	Domains   []string          `json:"domains" db:"domains"` // The first is the common name
+	Challenge string            `json:"challenge" db:"challenge"`
+	Status    CertificateStatus `json:"status" db:"status"`
+	Issuer    string            `json:"issuer,omitempty" db:"issuer"`
+	NotBefore *time.Time        `json:"not_before,omitempty" db:"not_before"`
+	NotAfter  *time.Time        `json:"not_after,omitempty" db:"not_after"`
+	LastError string            `json:"last_error,omitempty" db:"last_error"`
+	RenewedAt *time.Time        `json:"renewed_at,omitempty" db:"renewed_at"`
+	AlertedAt *time.Time        `json:"-" db:"alerted_at"`
+	CreatedBy string            `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// CertificateRequest asks for a certificate covering domains. The challenge
+// defaults to the configured one
+type CertificateRequest struct {
+	Domains   []string `json:"domains"`
+	Challenge string   `json:"challenge"`
+}
+
+// Certificate errors
+var (
+	ErrCertificateNotFound        = fmt.Errorf("certificate not found")
+	ErrCertificateDomainsRequired = fmt.Errorf("at least one domain is required")
+	ErrCertificateTooManyDomains  = fmt.Errorf("a certificate covers at most %d domains", maxCertificateDomains)
+	ErrCertificateChallenge       = fmt.Errorf("challenge must be 'http-01' or 'dns-01'")
+	ErrCertificateWildcard        = fmt.Errorf("wildcard domains require the dns-01 challenge")
+	ErrCertificateNotIssued       = fmt.Errorf("certificate has not been issued yet")
+)
+
+// Validate validates a certificate request, normalizing its domains to lowercase
+// without duplicates
+func (cr *CertificateRequest) Validate() error {
+	if cr.Challenge != ChallengeHTTP01 && cr.Challenge != ChallengeDNS01 {
+		return ErrCertificateChallenge
+	}
+
+	seen := make(map[string]bool, len(cr.Domains))
+	domains := make([]string, 0, len(cr.Domains))
+	for _, domain := range cr.Domains {
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain == "" || seen[domain] {
+			continue
+		}
+		if !isValidDomain(strings.TrimPrefix(domain, "*.")) {
+			return fmt.Errorf("invalid domain: %s", domain)
+		}
+		if strings.HasPrefix(domain, "*.") && cr.Challenge != ChallengeDNS01 {
+			return ErrCertificateWildcard
+		}
+		seen[domain] = true
+		domains = append(domains, domain)
+	}
+	if len(domains) == 0 {
+		return ErrCertificateDomainsRequired
+	}
+	if len(domains) > maxCertificateDomains {
+		return ErrCertificateTooManyDomains
+	}
+	cr.Domains = domains
+	return nil
+}
+
+// Covers returns true if the certificate is valid for hostname, directly or
+// through a wildcard one level up
+func (c *Certificate) Covers(hostname string) bool {
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+	for _, domain := range c.Domains {
+		if domain == hostname {
+			return true
+		}
+		if strings.HasPrefix(domain, "*.") {
+			if head, tail, found := strings.Cut(hostname, "."); found && head != "" && tail == domain[2:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Valid returns true if the certificate was issued and has not expired, even if
+// its last renewal failed
+func (c *Certificate) Valid() bool {
+	return c.NotAfter != nil && time.Now().Before(*c.NotAfter)
+}
+
+// ExpiresWithin returns true if the certificate is issued and expires within d
+func (c *Certificate) ExpiresWithin(d time.Duration) bool {
+	return c.NotAfter != nil && time.Until(*c.NotAfter) < d
+}
+
+// MarshalDomains converts domains to a JSON string for database storage
+func (c *Certificate) MarshalDomains() (string, error) {
+	data, err := json.Marshal(c.Domains)
+	return string(data), err
+}
+
+// UnmarshalDomains converts a JSON string from the database to domains
+func (c *Certificate) UnmarshalDomains(data string) error {
+	if data == "" {
+		c.Domains = []string{}
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &c.Domains)
+}
+
+// isValidDomain checks a DNS name: dot-separated labels of letters, digits and
+// hyphens, with at least two labels
+func isValidDomain(domain string) bool {
+	if len(domain) > 253 {
+		return false
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, char := range label {
+			if !((char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') || char == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
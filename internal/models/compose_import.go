@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// ComposeDirImportRequest adopts a folder of compose projects on the host, one
+// subdirectory per stack as Dockge keeps them, as deployments
+type ComposeDirImportRequest struct {
+	Path      string `json:"path"`                 // Absolute host path of the folder
+	ProjectID string `json:"project_id,omitempty"` // Project of the adopted deployments; global unless set
+	DryRun    bool   `json:"dry_run"`              // Report what would be adopted without adopting it
+}
+
+// ComposeDirImportResult reports the stacks a compose folder import adopted,
+// or would adopt on a dry run
+type ComposeDirImportResult struct {
+	DryRun      bool                 `json:"dry_run"`
+	Deployments []ComposeDirAdoption `json:"deployments"`
+	Skipped     []ImportSkipped      `json:"skipped,omitempty"`
+}
+
+// ComposeDirAdoption is a compose project adopted as a deployment
+type ComposeDirAdoption struct {
+	StackName      string           `json:"stack_name"`
+	Source         string           `json:"source"`          // Directory the project was read from
+	ComposeFile    string           `json:"compose_file"`    // Name of its compose file there
+	Variables      int              `json:"variables"`       // Entries of its .env
+	Status         DeploymentStatus `json:"status"`          // Running when its containers are
+	RewrittenPaths int              `json:"rewritten_paths"` // Relative paths pointed back at the source
+}
+
+var (
+	ErrComposeDirPathRequired = fmt.Errorf("path is required")
+	ErrComposeDirPathRelative = fmt.Errorf("path must be absolute")
+)
+
+// Validate validates a compose folder import request
+func (r *ComposeDirImportRequest) Validate() error {
+	if r.Path == "" {
+		return ErrComposeDirPathRequired
+	}
+	if !filepath.IsAbs(r.Path) {
+		return ErrComposeDirPathRelative
+	}
+	if r.ProjectID != "" && !IsValidProjectID(r.ProjectID) {
+		return ErrProjectIDInvalid
+	}
+	return nil
+}
+
+// Skip records a stack that was not adopted
+func (r *ComposeDirImportResult) Skip(name, reason string) {
+	r.Skipped = append(r.Skipped, ImportSkipped{Kind: "stack", Name: name, Reason: reason})
+}
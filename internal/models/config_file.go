@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// MaxConfigFileSize bounds the size of a config file template, in bytes
+const MaxConfigFileSize = 256 * 1024
+
+// ConfigFile is a file rendered from deployment variables into the config
+// directory of a deployment when it is deployed, for services to bind-mount
+// from ./config, such as configuration.yaml of Home Assistant. Its content is
+// a Go template of the variables, {{ .NAME }}, where {{ NAME }} works too
+type ConfigFile struct {
+	Source  string `json:"source"`            // Path of the file in the repository, relative to the compose file
+	Target  string `json:"target"`            // Path under the config directory of the deployment
+	Mode    string `json:"mode,omitempty"`    // Octal permissions; 0644 unless set
+	Content string `json:"content,omitempty"` // Fetched from Source when the template syncs
+}
+
+var (
+	ErrConfigFileSource = fmt.Errorf("config file source must be a relative path within the repository")
+	ErrConfigFileTarget = fmt.Errorf("config file target must be a relative path within the config directory")
+	ErrConfigFileMode   = fmt.Errorf("config file mode must be octal permissions such as 0644")
+)
+
+// Validate validates a config file declaration
+func (f ConfigFile) Validate() error {
+	if !relativePath(f.Source) {
+		return ErrConfigFileSource
+	}
+	if !relativePath(f.Target) {
+		return ErrConfigFileTarget
+	}
+	if f.Mode != "" {
+		if _, err := f.FileMode(); err != nil {
+			return ErrConfigFileMode
+		}
+	}
+	return nil
+}
+
+// FileMode returns the permissions of the rendered file
+func (f ConfigFile) FileMode() (uint32, error) {
+	if f.Mode == "" {
+		return 0644, nil
+	}
+	mode, err := strconv.ParseUint(f.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, ErrConfigFileMode
+	}
+	return uint32(mode), nil
+}
+
+// relativePath reports whether p is a relative path that stays below its base
+func relativePath(p string) bool {
+	if strings.TrimSpace(p) == "" || path.IsAbs(p) || strings.Contains(p, "\\") {
+		return false
+	}
+	cleaned := path.Clean(p)
+	return cleaned != "." && cleaned != ".." && !strings.HasPrefix(cleaned, "../")
+}
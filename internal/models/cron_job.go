@@ -0,0 +1,158 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// CronRunStatus is the outcome of a cron job run
+type CronRunStatus string
+
+const (
+	CronRunRunning   CronRunStatus = "running"
+	CronRunSucceeded CronRunStatus = "succeeded"
+	CronRunFailed    CronRunStatus = "failed"
+)
+
+// CronRunTrigger tells what started a cron job run
+type CronRunTrigger string
+
+const (
+	CronTriggerSchedule CronRunTrigger = "schedule"
+	CronTriggerManual   CronRunTrigger = "manual"
+)
+
+// maxCronJobTimeout bounds the timeout of a cron job, in seconds
+const maxCronJobTimeout = 24 * 3600
+
+// CronJob is a command run on a schedule in a service container of a stack,
+// such as pruning a queue every night
+type CronJob struct {
+	ID             int64         `json:"id" db:"id"`
+	DeploymentID   string        `json:"deployment_id" db:"deployment_id"`
+	Name           string        `json:"name" db:"name"`
+	Schedule       string        `json:"schedule" db:"schedule"`
+	Timezone       string        `json:"timezone" db:"timezone"` // IANA name, UTC when empty
+	Description    string        `json:"description" db:"-"`
+	Service        string        `json:"service" db:"service"`
+	Command        string        `json:"command" db:"command"` // Run with sh -c
+	Timeout        int           `json:"timeout" db:"timeout"` // Seconds
+	Enabled        bool          `json:"enabled" db:"enabled"`
+	AlertOnFailure bool          `json:"alert_on_failure" db:"alert_on_failure"` // Publish cronjob.failed when a run fails
+	LastRunAt      *time.Time    `json:"last_run_at,omitempty" db:"last_run_at"`
+	LastStatus     CronRunStatus `json:"last_status,omitempty" db:"last_status"`
+	NextRunAt      *time.Time    `json:"next_run_at,omitempty" db:"-"`
+	CreatedBy      string        `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// CronJobRequest creates or updates a cron job. Omitted booleans keep their
+// current value, or their default for a new job
+type CronJobRequest struct {
+	Name           string `json:"name"`
+	Schedule       string `json:"schedule"`
+	Timezone       string `json:"timezone"`
+	Service        string `json:"service"`
+	Command        string `json:"command"`
+	Timeout        int    `json:"timeout"` // Seconds; 300 unless set
+	Enabled        *bool  `json:"enabled,omitempty"`
+	AlertOnFailure *bool  `json:"alert_on_failure,omitempty"`
+}
+
+// CronJobRun is one run of a cron job. Output keeps the tail of what the
+// command wrote
+type CronJobRun struct {
+	ID         int64          `json:"id" db:"id"`
+	JobID      int64          `json:"job_id" db:"job_id"`
+	Trigger    CronRunTrigger `json:"trigger" db:"triggered_by"`
+	Status     CronRunStatus  `json:"status" db:"status"`
+	ExitCode   *int           `json:"exit_code,omitempty" db:"exit_code"`
+	Output     string         `json:"output,omitempty" db:"output"`
+	Error      string         `json:"error,omitempty" db:"error"`
+	StartedAt  time.Time      `json:"started_at" db:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+var (
+	ErrCronJobNotFound        = fmt.Errorf("cron job not found")
+	ErrCronJobNameRequired    = fmt.Errorf("cron job name is required")
+	ErrCronJobServiceRequired = fmt.Errorf("cron job service is required")
+	ErrCronJobCommandRequired = fmt.Errorf("cron job command is required")
+	ErrCronJobTimeoutInvalid  = fmt.Errorf("cron job timeout must be between 0 and %d seconds", maxCronJobTimeout)
+	ErrCronJobRunning         = fmt.Errorf("cron job is already running")
+)
+
+// Apply copies the fields of a request onto a job
+func (r *CronJobRequest) Apply(job *CronJob) {
+	job.Name = strings.TrimSpace(r.Name)
+	job.Schedule = strings.TrimSpace(r.Schedule)
+	job.Timezone = r.Timezone
+	job.Service = r.Service
+	job.Command = r.Command
+	job.Timeout = r.Timeout
+	if job.Timeout == 0 {
+		job.Timeout = 300
+	}
+	if r.Enabled != nil {
+		job.Enabled = *r.Enabled
+	}
+	if r.AlertOnFailure != nil {
+		job.AlertOnFailure = *r.AlertOnFailure
+	}
+}
+
+// Validate validates the job's name, schedule, time zone, target and timeout
+func (j *CronJob) Validate() error {
+	if j.Name == "" {
+		return ErrCronJobNameRequired
+	}
+	if _, err := j.parse(); err != nil {
+		return err
+	}
+	if j.Service == "" {
+		return ErrCronJobServiceRequired
+	}
+	if strings.TrimSpace(j.Command) == "" {
+		return ErrCronJobCommandRequired
+	}
+	if j.Timeout < 0 || j.Timeout > maxCronJobTimeout {
+		return ErrCronJobTimeoutInvalid
+	}
+	return nil
+}
+
+// CronSpec returns the schedule with the job's time zone applied, as accepted
+// by the cron scheduler
+func (j *CronJob) CronSpec() string {
+	if j.Timezone == "" {
+		return j.Schedule
+	}
+	return "CRON_TZ=" + j.Timezone + " " + j.Schedule
+}
+
+// Describe fills in the description and the next run time of the job
+func (j *CronJob) Describe() {
+	j.Description = DescribeCron(j.Schedule)
+	if j.Timezone != "" {
+		j.Description += " (" + j.Timezone + ")"
+	} else {
+		j.Description += " (UTC)"
+	}
+
+	j.NextRunAt = nil
+	if schedule, err := j.parse(); err == nil && j.Enabled {
+		next := schedule.Next(time.Now())
+		j.NextRunAt = &next
+	}
+}
+
+// parse parses the schedule in the job's time zone, with the checks of backup
+// schedules
+func (j *CronJob) parse() (cron.Schedule, error) {
+	schedule := BackupSchedule{CronExpression: j.Schedule, Timezone: j.Timezone}
+	return schedule.parse()
+}
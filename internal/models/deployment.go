@@ -3,6 +3,9 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
@@ -18,17 +21,68 @@ const (
 	StatusFailed    DeploymentStatus = "failed"
 )
 
+// DeployMode selects the orchestrator used to run a deployment
+type DeployMode string
+
+const (
+	DeployModeCompose DeployMode = "compose"
+	DeployModeSwarm   DeployMode = "swarm"
+)
+
+// ProxyType selects the reverse proxy whose labels are injected
+type ProxyType string
+
+const (
+	ProxyTypeTraefik ProxyType = "traefik"
+	ProxyTypeCaddy   ProxyType = "caddy"
+)
+
+// TunnelProvider selects the sidecar used to expose a deployment
+type TunnelProvider string
+
+const (
+	TunnelProviderNewt       TunnelProvider = "newt"
+	TunnelProviderCloudflare TunnelProvider = "cloudflared"
+	TunnelProviderTailscale  TunnelProvider = "tailscale"
+)
+
+// NetworkIsolation selects which networks the services of a deployment join
+type NetworkIsolation string
+
+const (
+	NetworkShared NetworkIsolation = "shared" // Every service joins app_network
+	// NetworkIsolated puts the services on a network of the stack's own; only the
+	// tunnel sidecar also joins app_network
+	NetworkIsolated NetworkIsolation = "isolated"
+)
+
+// UpdatePolicy controls which image updates are applied automatically
+type UpdatePolicy string
+
+const (
+	UpdatePolicyPinned UpdatePolicy = "pinned" // Never update automatically
+	UpdatePolicyPatch  UpdatePolicy = "patch"  // Only images tagged with at least major.minor
+	UpdatePolicyAny    UpdatePolicy = "any"    // Any tag, including latest
+)
+
 // Deployment represents a deployed Docker Compose stack
 type Deployment struct {
 	ID           string                 `json:"id" db:"id"`
 	TemplateID   string                 `json:"template_id" db:"template_id"`
 	StackName    string                 `json:"stack_name" db:"stack_name"`
+	ProjectID    string                 `json:"project_id" db:"project_id"`
 	Status       DeploymentStatus       `json:"status" db:"status"`
+	DeployMode   DeployMode             `json:"deploy_mode" db:"deploy_mode"`
+	UpdatePolicy UpdatePolicy           `json:"update_policy" db:"update_policy"`
+	UpdateSchedule string               `json:"update_schedule" db:"update_schedule"`
 	Config       map[string]interface{} `json:"config" db:"config"`
 	NewtInjected bool                   `json:"newt_injected" db:"newt_injected"`
+	TunnelProvider TunnelProvider       `json:"tunnel_provider" db:"tunnel_provider"`
 	TunnelURL    string                 `json:"tunnel_url" db:"tunnel_url"`
+	ResourceVersion int                 `json:"resource_version" db:"resource_version"`
 	CreatedAt    time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time              `json:"updated_at" db:"updated_at"`
+	Warnings     []string               `json:"warnings,omitempty" db:"-"` // Found when the deployment was started, such as missing host resources
 }
 
 // DeploymentLog represents a log entry for a deployment
@@ -40,17 +94,221 @@ type DeploymentLog struct {
 	Timestamp    time.Time `json:"timestamp" db:"timestamp"`
 }
 
+// DeploymentLogFilter narrows a deployment log query. Query matches messages as a
+// substring, or as a regular expression when Regex is set
+type DeploymentLogFilter struct {
+	Levels []string
+	Query  string
+	Regex  bool
+	Since  *time.Time
+	Until  *time.Time
+	Limit  int // 0 returns all matching rows
+
+	pattern *regexp.Regexp
+}
+
+// Validate validates the filter and compiles the regular expression
+func (f *DeploymentLogFilter) Validate() error {
+	for _, level := range f.Levels {
+		if level != LogLevelDebug && level != LogLevelInfo && level != LogLevelWarning && level != LogLevelError {
+			return ErrInvalidLogLevel
+		}
+	}
+	if f.Since != nil && f.Until != nil && f.Until.Before(*f.Since) {
+		return ErrInvalidLogTimeRange
+	}
+	if f.Limit < 0 {
+		return ErrInvalidLogLimit
+	}
+	if f.Regex && f.Query != "" {
+		pattern, err := regexp.Compile(f.Query)
+		if err != nil {
+			return fmt.Errorf("invalid log search pattern: %w", err)
+		}
+		f.pattern = pattern
+	}
+	return nil
+}
+
+// MatchMessage reports whether a message matches the regular expression; substring
+// searches are done by the database
+func (f *DeploymentLogFilter) MatchMessage(message string) bool {
+	return f.pattern == nil || f.pattern.MatchString(message)
+}
+
+// DeploymentDeleteOptions selects what deleting a deployment removes besides its
+// containers and networks. Volumes are kept unless asked for, so data is never
+// destroyed by default
+type DeploymentDeleteOptions struct {
+	RemoveVolumes bool `json:"remove_volumes"`
+	RemoveImages  bool `json:"remove_images"`
+	KeepFiles     bool `json:"keep_files"` // Keep the deployment directory with its compose file
+	Permanent     bool `json:"permanent"`  // Skip the trash
+	SkipHooks     bool `json:"skip_hooks"` // Do not run pre_destroy hooks
+}
+
+// CloneRequest duplicates a deployment under a new stack name
+type CloneRequest struct {
+	StackName   string            `json:"stack_name"`
+	ProjectID   string            `json:"project_id,omitempty"`  // Defaults to the source's project
+	Environment map[string]string `json:"environment,omitempty"` // Overrides merged over the source's environment
+	RemapPorts  bool              `json:"remap_ports"`           // Move host ports in use to free ones instead of failing
+	CopyVolumes bool              `json:"copy_volumes"`          // Copy the data of the source's named volumes
+	AutoStart   *bool             `json:"auto_start,omitempty"`
+}
+
+// Validate validates a clone request
+func (cr *CloneRequest) Validate() error {
+	if cr.StackName == "" {
+		return ErrDeploymentStackNameRequired
+	}
+	if !isValidStackName(cr.StackName) {
+		return ErrDeploymentInvalidStackName
+	}
+	if cr.ProjectID != "" && !IsValidProjectID(cr.ProjectID) {
+		return ErrProjectIDInvalid
+	}
+	return nil
+}
+
+// TrashedDeployment is a deleted deployment kept restorable until it expires
+type TrashedDeployment struct {
+	ID         string     `json:"id"`
+	StackName  string     `json:"stack_name"`
+	ProjectID  string     `json:"project_id"`
+	TemplateID string     `json:"template_id"`
+	DeployMode DeployMode `json:"deploy_mode"`
+	Volumes    []string   `json:"volumes"`
+	DeletedBy  string     `json:"deleted_by,omitempty"`
+	DeletedAt  time.Time  `json:"deleted_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+// Trash errors
+var (
+	ErrTrashNotFound  = fmt.Errorf("deployment not found in trash")
+	ErrStackNameTaken = fmt.Errorf("stack name is used by another deployment")
+)
+
+// ErrNoStackContainers is returned when describing a stack that has no containers
+var ErrNoStackContainers = fmt.Errorf("stack has no containers")
+
+// DeploymentDeleteSummary reports what deleting a deployment actually removed
+type DeploymentDeleteSummary struct {
+	Containers   []string `json:"containers"`
+	Networks     []string `json:"networks"`
+	Volumes      []string `json:"volumes"`
+	Images       []string `json:"images"`
+	KeptVolumes  []string `json:"kept_volumes"`
+	FilesRemoved bool     `json:"files_removed"`
+	Errors       []string `json:"errors,omitempty"`
+}
+
 // DeploymentConfig holds configuration for creating a deployment
 type DeploymentConfig struct {
 	TemplateID      string            `json:"template_id"`
 	StackName       string            `json:"stack_name"`
+	ProjectID       string            `json:"project_id"` // Defaults to the global project
 	Environment     map[string]string `json:"environment"`
 	NewtConfig      *NewtConfig       `json:"newt_config"`
 	AutoStart       bool              `json:"auto_start"`
 	IncludeNewt     bool              `json:"include_newt"`
 	OverrideExisting bool             `json:"override_existing"`
+	DeployMode      DeployMode        `json:"deploy_mode"`
+	TunnelProvider  TunnelProvider    `json:"tunnel_provider"`
+	TunnelConfig    *TunnelConfig     `json:"tunnel_config"`
+	Proxy           *ProxyConfig      `json:"proxy"`
+	TLS             *TLSConfig        `json:"tls,omitempty"`
+	Network         *NetworkPolicy    `json:"network,omitempty"`
+	Hooks           []LifecycleHook   `json:"hooks,omitempty"` // Run after the template's hooks of the same phase
+	RemapPorts      bool              `json:"remap_ports"`
+	IgnoreArchitecture bool           `json:"ignore_architecture,omitempty"` // Deploy even if the images are not published for the host's architecture
+	RequestedBy     string            `json:"-"` // User deploying, set from the request; empty without authentication
+}
+
+// PortConflict describes a requested host port that is already in use
+type PortConflict struct {
+	Service    string `json:"service"`
+	HostPort   int    `json:"host_port"`
+	Protocol   string `json:"protocol"`
+	UsedBy     string `json:"used_by"`
+	RemappedTo int    `json:"remapped_to,omitempty"`
+}
+
+// ProxyConfig describes reverse-proxy labels to add to services of a deployment
+type ProxyConfig struct {
+	Type         ProxyType    `json:"type"`
+	Network      string       `json:"network"`       // External network shared with the proxy
+	EntryPoint   string       `json:"entry_point"`   // Traefik entrypoint, e.g. websecure
+	CertResolver string       `json:"cert_resolver"` // Traefik certificate resolver
+	Routes       []ProxyRoute `json:"routes"`
+}
+
+// ProxyRoute exposes one service port on a hostname; values may reference
+// deployment variables as ${NAME}
+type ProxyRoute struct {
+	Service  string `json:"service"`
+	Hostname string `json:"hostname"`
+	Port     string `json:"port"`
+}
+
+// TLSConfig serves routes of a deployment over HTTPS on a host port with an ACME
+// certificate, through a TLS-terminating sidecar. Hostnames may reference
+// deployment variables as ${NAME}
+type TLSConfig struct {
+	CertificateID string       `json:"certificate_id"`
+	Port          int          `json:"port"`  // Host port, 443 by default
+	Image         string       `json:"image"` // Sidecar image, nginx by default
+	Routes        []ProxyRoute `json:"routes"`
 }
 
+// NetworkPolicy isolates a deployment from other stacks. Allow lists the stacks
+// whose isolated networks its services join, the only cross-stack connections
+// isolated stacks accept. Subnet, Gateway and StaticIPs address the network the
+// stack's services share, for templates that hard-code addresses
+type NetworkPolicy struct {
+	Isolation NetworkIsolation  `json:"isolation"`
+	Allow     []string          `json:"allow,omitempty"`
+	Subnet    string            `json:"subnet,omitempty"`     // CIDR, such as 172.28.0.0/24
+	Gateway   string            `json:"gateway,omitempty"`    // Within Subnet
+	StaticIPs map[string]string `json:"static_ips,omitempty"` // Service name to an address within Subnet
+}
+
+// TunnelConfig holds credentials for tunnel providers other than Newt
+type TunnelConfig struct {
+	Token       string `json:"token"`        // Cloudflare tunnel token
+	AuthKey     string `json:"auth_key"`     // Tailscale auth key
+	Hostname    string `json:"hostname"`     // Tailscale node name
+	ServeConfig string `json:"serve_config"` // Host path of a Tailscale serve/funnel config
+	Image       string `json:"image"`
+}
+
+// UpdatePolicyConfig holds the auto-update settings of a deployment
+type UpdatePolicyConfig struct {
+	Policy   UpdatePolicy `json:"policy"`
+	Schedule string       `json:"schedule"`
+}
+
+// DeploymentRevision records an image upgrade applied to a deployment
+type DeploymentRevision struct {
+	ID             int               `json:"id" db:"id"`
+	DeploymentID   string            `json:"deployment_id" db:"deployment_id"`
+	Revision       int               `json:"revision" db:"revision"`
+	Reason         string            `json:"reason" db:"reason"`
+	Services       []string          `json:"services" db:"services"`
+	PreviousImages map[string]string `json:"previous_images" db:"previous_images"`
+	Status         string            `json:"status" db:"status"`
+	Message        string            `json:"message" db:"message"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+}
+
+// Revision status constants
+const (
+	RevisionStatusApplied    = "applied"
+	RevisionStatusRolledBack = "rolled_back"
+	RevisionStatusFailed     = "failed"
+)
+
 // NewtConfig holds Newt tunnel configuration
 type NewtConfig struct {
 	Endpoint     string            `json:"endpoint"`
@@ -91,6 +349,21 @@ var (
 	ErrDeploymentInvalidStackName   = fmt.Errorf("invalid stack name format")
 	ErrNewtConfigRequired          = fmt.Errorf("newt configuration is required when newt is enabled")
 	ErrDeploymentNotFound          = fmt.Errorf("deployment not found")
+	ErrDeploymentInvalidMode       = fmt.Errorf("deploy mode must be 'compose' or 'swarm'")
+	ErrInvalidTunnelProvider       = fmt.Errorf("tunnel provider must be 'newt', 'cloudflared' or 'tailscale'")
+	ErrTunnelConfigRequired        = fmt.Errorf("tunnel configuration is required for this tunnel provider")
+	ErrInvalidProxyType            = fmt.Errorf("proxy type must be 'traefik' or 'caddy'")
+	ErrProxyRoutesRequired         = fmt.Errorf("at least one proxy route is required")
+	ErrProxyRouteInvalid           = fmt.Errorf("proxy routes require a service, hostname and port")
+	ErrTLSCertificateRequired      = fmt.Errorf("TLS configuration requires a certificate_id")
+	ErrTLSPortInvalid              = fmt.Errorf("TLS port must be between 1 and 65535")
+	ErrInvalidNetworkIsolation     = fmt.Errorf("network isolation must be 'shared' or 'isolated'")
+	ErrNetworkSubnetRequired       = fmt.Errorf("a subnet is required for a gateway or static IPs")
+	ErrInvalidUpdatePolicy         = fmt.Errorf("update policy must be 'pinned', 'patch' or 'any'")
+	ErrUpdateScheduleRequired      = fmt.Errorf("update schedule is required for automatic updates")
+	ErrInvalidLogLevel             = fmt.Errorf("log level must be 'debug', 'info', 'warning' or 'error'")
+	ErrInvalidLogTimeRange         = fmt.Errorf("log 'until' must not be before 'since'")
+	ErrInvalidLogLimit             = fmt.Errorf("log limit cannot be negative")
 )
 
 // MarshalConfig converts config map to JSON string for database storage
@@ -102,6 +375,12 @@ func (d *Deployment) MarshalConfig() (string, error) {
 	return string(data), err
 }
 
+// ETag returns the concurrency token of the deployment's desired state. It changes
+// whenever the configuration does, but not on status changes
+func (d *Deployment) ETag() string {
+	return fmt.Sprintf(`"%s.%d"`, d.ID, d.ResourceVersion)
+}
+
 // UnmarshalConfig converts JSON string from database to config map
 func (d *Deployment) UnmarshalConfig(data string) error {
 	if data == "" || data == "null" {
@@ -136,8 +415,52 @@ func (dc *DeploymentConfig) Validate() error {
 	if !isValidStackName(dc.StackName) {
 		return ErrDeploymentInvalidStackName
 	}
-	if dc.IncludeNewt && dc.NewtConfig == nil {
-		return ErrNewtConfigRequired
+	if dc.ProjectID == "" {
+		dc.ProjectID = DefaultProjectID
+	}
+	if !IsValidProjectID(dc.ProjectID) {
+		return ErrProjectIDInvalid
+	}
+	if dc.DeployMode == "" {
+		dc.DeployMode = DeployModeCompose
+	}
+	if dc.DeployMode != DeployModeCompose && dc.DeployMode != DeployModeSwarm {
+		return ErrDeploymentInvalidMode
+	}
+	if dc.TunnelProvider == "" {
+		dc.TunnelProvider = TunnelProviderNewt
+	}
+	switch dc.TunnelProvider {
+	case TunnelProviderNewt:
+		if dc.IncludeNewt && dc.NewtConfig == nil {
+			return ErrNewtConfigRequired
+		}
+	case TunnelProviderCloudflare, TunnelProviderTailscale:
+		if dc.IncludeNewt && dc.TunnelConfig == nil {
+			return ErrTunnelConfigRequired
+		}
+	default:
+		return ErrInvalidTunnelProvider
+	}
+	if dc.Proxy != nil {
+		if err := dc.Proxy.Validate(); err != nil {
+			return err
+		}
+	}
+	if dc.TLS != nil {
+		if err := dc.TLS.Validate(); err != nil {
+			return err
+		}
+	}
+	if dc.Network != nil {
+		if err := dc.Network.Validate(dc.StackName); err != nil {
+			return err
+		}
+	}
+	for i, hook := range dc.Hooks {
+		if err := hook.Validate(); err != nil {
+			return fmt.Errorf("hooks[%d]: %w", i, err)
+		}
 	}
 	if dc.NewtConfig != nil {
 		if err := dc.NewtConfig.Validate(); err != nil {
@@ -147,6 +470,161 @@ func (dc *DeploymentConfig) Validate() error {
 	return nil
 }
 
+// Validate validates update policy configuration
+func (uc *UpdatePolicyConfig) Validate() error {
+	switch uc.Policy {
+	case UpdatePolicyPinned:
+		return nil
+	case UpdatePolicyPatch, UpdatePolicyAny:
+		if strings.TrimSpace(uc.Schedule) == "" {
+			return ErrUpdateScheduleRequired
+		}
+		return nil
+	default:
+		return ErrInvalidUpdatePolicy
+	}
+}
+
+// Validate validates reverse-proxy configuration
+func (pc *ProxyConfig) Validate() error {
+	if pc.Type == "" {
+		pc.Type = ProxyTypeTraefik
+	}
+	if pc.Type != ProxyTypeTraefik && pc.Type != ProxyTypeCaddy {
+		return ErrInvalidProxyType
+	}
+	if len(pc.Routes) == 0 {
+		return ErrProxyRoutesRequired
+	}
+	for _, route := range pc.Routes {
+		if strings.TrimSpace(route.Service) == "" || strings.TrimSpace(route.Hostname) == "" || strings.TrimSpace(route.Port) == "" {
+			return ErrProxyRouteInvalid
+		}
+	}
+	return nil
+}
+
+// Validate validates TLS sidecar configuration
+func (tc *TLSConfig) Validate() error {
+	if strings.TrimSpace(tc.CertificateID) == "" {
+		return ErrTLSCertificateRequired
+	}
+	if tc.Port == 0 {
+		tc.Port = 443
+	}
+	if tc.Port < 1 || tc.Port > 65535 {
+		return ErrTLSPortInvalid
+	}
+	if len(tc.Routes) == 0 {
+		return ErrProxyRoutesRequired
+	}
+	for _, route := range tc.Routes {
+		if strings.TrimSpace(route.Service) == "" || strings.TrimSpace(route.Hostname) == "" || strings.TrimSpace(route.Port) == "" {
+			return ErrProxyRouteInvalid
+		}
+	}
+	return nil
+}
+
+// Validate validates the network policy of stackName, dropping duplicate stacks
+// from its allow-list
+func (np *NetworkPolicy) Validate(stackName string) error {
+	if np.Isolation == "" {
+		np.Isolation = NetworkShared
+	}
+	if np.Isolation != NetworkShared && np.Isolation != NetworkIsolated {
+		return ErrInvalidNetworkIsolation
+	}
+
+	allow := make([]string, 0, len(np.Allow))
+	for _, stack := range np.Allow {
+		stack = strings.TrimSpace(stack)
+		if !isValidStackName(stack) {
+			return fmt.Errorf("invalid stack name in network allow-list: %q", stack)
+		}
+		if stack == stackName {
+			return fmt.Errorf("a stack cannot allow itself: %s", stack)
+		}
+		if !contains(allow, stack) {
+			allow = append(allow, stack)
+		}
+	}
+	np.Allow = allow
+	return np.validateAddressing()
+}
+
+// validateAddressing checks that the gateway and static IPs are distinct usable
+// addresses of the subnet, normalizing them
+func (np *NetworkPolicy) validateAddressing() error {
+	if np.Subnet == "" {
+		if np.Gateway != "" || len(np.StaticIPs) > 0 {
+			return ErrNetworkSubnetRequired
+		}
+		return nil
+	}
+
+	ip, subnet, err := net.ParseCIDR(strings.TrimSpace(np.Subnet))
+	if err != nil {
+		return fmt.Errorf("invalid subnet: %q", np.Subnet)
+	}
+	if !ip.Equal(subnet.IP) {
+		return fmt.Errorf("subnet %s has host bits set; use %s", np.Subnet, subnet)
+	}
+	np.Subnet = subnet.String()
+
+	used := make(map[string]string)
+	address := func(value, owner string) (string, error) {
+		ip := net.ParseIP(strings.TrimSpace(value))
+		if ip == nil {
+			return "", fmt.Errorf("invalid address for %s: %q", owner, value)
+		}
+		if !subnet.Contains(ip) || ip.Equal(subnet.IP) || isBroadcast(ip, subnet) {
+			return "", fmt.Errorf("address %s of %s is not a usable address of subnet %s", ip, owner, subnet)
+		}
+		if other, ok := used[ip.String()]; ok {
+			return "", fmt.Errorf("address %s is used by both %s and %s", ip, other, owner)
+		}
+		used[ip.String()] = owner
+		return ip.String(), nil
+	}
+
+	if np.Gateway != "" {
+		if np.Gateway, err = address(np.Gateway, "the gateway"); err != nil {
+			return err
+		}
+	}
+	services := make([]string, 0, len(np.StaticIPs))
+	for service := range np.StaticIPs {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		if np.StaticIPs[service], err = address(np.StaticIPs[service], service); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBroadcast returns true if ip is the broadcast address of an IPv4 subnet
+func isBroadcast(ip net.IP, subnet *net.IPNet) bool {
+	ip4, mask := ip.To4(), subnet.Mask
+	if ip4 == nil || len(mask) != net.IPv4len {
+		return false
+	}
+	for i := range ip4 {
+		if ip4[i]|mask[i] != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+// AutoUpdates returns true if the deployment opted in to automatic updates
+func (d *Deployment) AutoUpdates() bool {
+	return d.UpdatePolicy == UpdatePolicyPatch || d.UpdatePolicy == UpdatePolicyAny
+}
+
 // Validate validates newt configuration
 func (nc *NewtConfig) Validate() error {
 	if strings.TrimSpace(nc.Endpoint) == "" {
@@ -268,6 +746,11 @@ func (d *Deployment) GetServiceURL(serviceName string, port int) string {
 	return fmt.Sprintf("%s/%s:%d", strings.TrimSuffix(d.TunnelURL, "/"), serviceName, port)
 }
 
+// IsSwarm returns true if the deployment runs as a swarm stack
+func (d *Deployment) IsSwarm() bool {
+	return d.DeployMode == DeployModeSwarm
+}
+
 // GetComposeProjectName returns the docker-compose project name
 func (d *Deployment) GetComposeProjectName() string {
 	return strings.ToLower(strings.ReplaceAll(d.StackName, " ", "_"))
@@ -304,6 +787,11 @@ func NewDeploymentLog(deploymentID, level, message string) *DeploymentLog {
 	}
 }
 
+// IsValidStackName reports whether name is a valid stack name
+func IsValidStackName(name string) bool {
+	return isValidStackName(name)
+}
+
 // Helper function to validate stack name format
 func isValidStackName(name string) bool {
 	if len(name) == 0 || len(name) > 63 {
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// HoursPerMonth is the average length of a month, which footprints are
+// extrapolated to
+const HoursPerMonth = 730
+
+const gigabyte = 1024 * 1024 * 1024
+
+// ResourceSample is the resource usage of the running containers of a
+// deployment at one time
+type ResourceSample struct {
+	DeploymentID string    `json:"deployment_id" db:"deployment_id"`
+	CPUUsage     float64   `json:"cpu_usage" db:"cpu_usage"` // Percent of one CPU, so it may exceed 100
+	MemoryUsage  int64     `json:"memory_usage" db:"memory_usage"`
+	Containers   int       `json:"containers" db:"containers"`
+	SampledAt    time.Time `json:"sampled_at" db:"sampled_at"`
+}
+
+// Footprint estimates the monthly resource use of a deployment, or of the
+// deployments of a project, from its average usage over a window. The usage of
+// a project sums that of its deployments, peaks included, so its peak is an
+// upper bound
+type Footprint struct {
+	DeploymentID         string      `json:"deployment_id,omitempty"`
+	StackName            string      `json:"stack_name,omitempty"`
+	ProjectID            string      `json:"project_id,omitempty"`
+	Window               string      `json:"window"`
+	Samples              int         `json:"samples"`
+	Since                *time.Time  `json:"since,omitempty"` // Oldest sample in the window
+	AvgCPUCores          float64     `json:"avg_cpu_cores"`
+	PeakCPUCores         float64     `json:"peak_cpu_cores"`
+	AvgMemory            int64       `json:"avg_memory"`  // Bytes
+	PeakMemory           int64       `json:"peak_memory"` // Bytes
+	MonthlyCPUCoreHours  float64     `json:"monthly_cpu_core_hours"`
+	MonthlyMemoryGBHours float64     `json:"monthly_memory_gb_hours"`
+	MonthlyEnergyKWh     *float64    `json:"monthly_energy_kwh,omitempty"` // Only with watts per core configured
+	Deployments          []Footprint `json:"deployments,omitempty"`        // Of a project
+}
+
+// Estimate extrapolates the average usage to a month. Energy counts the CPU
+// alone, at wattsPerCore per fully used core; without it none is estimated
+func (f *Footprint) Estimate(wattsPerCore int) {
+	f.MonthlyCPUCoreHours = f.AvgCPUCores * HoursPerMonth
+	f.MonthlyMemoryGBHours = float64(f.AvgMemory) / gigabyte * HoursPerMonth
+	f.MonthlyEnergyKWh = nil
+	if wattsPerCore > 0 {
+		kwh := f.MonthlyCPUCoreHours * float64(wattsPerCore) / 1000
+		f.MonthlyEnergyKWh = &kwh
+	}
+}
+
+// Add sums the usage of a deployment into the footprint of its project
+func (f *Footprint) Add(other *Footprint) {
+	f.Samples += other.Samples
+	if other.Since != nil && (f.Since == nil || other.Since.Before(*f.Since)) {
+		f.Since = other.Since
+	}
+	f.AvgCPUCores += other.AvgCPUCores
+	f.PeakCPUCores += other.PeakCPUCores
+	f.AvgMemory += other.AvgMemory
+	f.PeakMemory += other.PeakMemory
+}
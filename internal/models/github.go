@@ -0,0 +1,125 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// GitHubSourceType is where template repositories are discovered from
+type GitHubSourceType string
+
+const (
+	GitHubSourceOrg   GitHubSourceType = "org"
+	GitHubSourceUser  GitHubSourceType = "user"
+	GitHubSourceRepo  GitHubSourceType = "repo"
+	GitHubSourceTopic GitHubSourceType = "topic"
+)
+
+// GitHubSource is an explicit template discovery source. Include and exclude
+// are glob patterns matched against the repository full name ("owner/repo")
+type GitHubSource struct {
+	ID        int              `json:"id" db:"id"`
+	Type      GitHubSourceType `json:"type" db:"type"`
+	Value     string           `json:"value" db:"value"`
+	Include   []string         `json:"include" db:"include_patterns"`
+	Exclude   []string         `json:"exclude" db:"exclude_patterns"`
+	Enabled   bool             `json:"enabled" db:"enabled"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+}
+
+// Validation errors
+var (
+	ErrGitHubSourceInvalidType   = fmt.Errorf("source type must be 'org', 'user', 'repo' or 'topic'")
+	ErrGitHubSourceValueRequired = fmt.Errorf("source value is required")
+	ErrGitHubSourceInvalidRepo   = fmt.Errorf("repository sources must be in 'owner/repo' format")
+	ErrGitHubSourceInvalidGlob   = fmt.Errorf("invalid include or exclude pattern")
+)
+
+// Validate validates a discovery source
+func (s *GitHubSource) Validate() error {
+	switch s.Type {
+	case GitHubSourceOrg, GitHubSourceUser, GitHubSourceRepo, GitHubSourceTopic:
+	default:
+		return ErrGitHubSourceInvalidType
+	}
+
+	s.Value = strings.TrimSpace(s.Value)
+	if s.Value == "" {
+		return ErrGitHubSourceValueRequired
+	}
+	if s.Type == GitHubSourceRepo && len(strings.Split(s.Value, "/")) != 2 {
+		return ErrGitHubSourceInvalidRepo
+	}
+
+	for _, pattern := range append(append([]string{}, s.Include...), s.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: %s", ErrGitHubSourceInvalidGlob, pattern)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a repository passes the include and exclude patterns.
+// With no include patterns every repository is included
+func (s *GitHubSource) Matches(fullName string) bool {
+	fullName = strings.ToLower(fullName)
+
+	included := len(s.Include) == 0
+	for _, pattern := range s.Include {
+		if matched, _ := path.Match(strings.ToLower(pattern), fullName); matched {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+
+	for _, pattern := range s.Exclude {
+		if matched, _ := path.Match(strings.ToLower(pattern), fullName); matched {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalPatterns converts include and exclude patterns to JSON strings for database storage
+func (s *GitHubSource) MarshalPatterns() (string, string, error) {
+	include, err := json.Marshal(nonNilStrings(s.Include))
+	if err != nil {
+		return "", "", err
+	}
+	exclude, err := json.Marshal(nonNilStrings(s.Exclude))
+	if err != nil {
+		return "", "", err
+	}
+	return string(include), string(exclude), nil
+}
+
+// UnmarshalPatterns converts JSON strings from database to include and exclude patterns
+func (s *GitHubSource) UnmarshalPatterns(include, exclude string) error {
+	s.Include = []string{}
+	s.Exclude = []string{}
+	if include != "" && include != "null" {
+		if err := json.Unmarshal([]byte(include), &s.Include); err != nil {
+			return err
+		}
+	}
+	if exclude != "" && exclude != "null" {
+		if err := json.Unmarshal([]byte(exclude), &s.Exclude); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nonNilStrings returns an empty slice for nil so it is stored as []
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HookPhase is the point of a deployment's lifecycle a hook runs at
+type HookPhase string
+
+const (
+	HookPreDeploy  HookPhase = "pre_deploy"  // Before the stack is brought up
+	HookPostDeploy HookPhase = "post_deploy" // Once the stack is up
+	HookPreDestroy HookPhase = "pre_destroy" // Before the stack is torn down
+)
+
+// maxHookTimeout bounds the timeout a hook may ask for, in seconds
+const maxHookTimeout = 3600
+
+// LifecycleHook is a command run at a phase of a deployment's lifecycle, such
+// as migrating a database after deploying. It runs with sh, in the container of
+// Service, or in a one-shot container of Image. A one-shot container given a
+// service too shares that service's volumes and network. Deployment variables
+// are set in the command's environment
+type LifecycleHook struct {
+	Name     string    `json:"name"`
+	Phase    HookPhase `json:"phase"`
+	Service  string    `json:"service,omitempty"`
+	Image    string    `json:"image,omitempty"`
+	Command  string    `json:"command"`
+	Required bool      `json:"required"`          // A failure aborts the deploy or delete
+	Timeout  int       `json:"timeout,omitempty"` // Seconds; 300 unless set
+}
+
+var (
+	ErrHookPhaseInvalid   = fmt.Errorf("hook phase must be 'pre_deploy', 'post_deploy' or 'pre_destroy'")
+	ErrHookTargetRequired = fmt.Errorf("hook needs a service or an image to run in")
+	ErrHookCommandMissing = fmt.Errorf("hook command is required")
+	ErrHookTimeoutInvalid = fmt.Errorf("hook timeout must be between 0 and %d seconds", maxHookTimeout)
+)
+
+// Validate validates a lifecycle hook
+func (h LifecycleHook) Validate() error {
+	switch h.Phase {
+	case HookPreDeploy, HookPostDeploy, HookPreDestroy:
+	default:
+		return ErrHookPhaseInvalid
+	}
+	if h.Service == "" && h.Image == "" {
+		return ErrHookTargetRequired
+	}
+	if strings.TrimSpace(h.Command) == "" {
+		return ErrHookCommandMissing
+	}
+	if h.Timeout < 0 || h.Timeout > maxHookTimeout {
+		return ErrHookTimeoutInvalid
+	}
+	return nil
+}
+
+// DisplayName returns the name of a hook, or its command if it has none
+func (h LifecycleHook) DisplayName() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return h.Command
+}
+
+// HooksForPhase returns the hooks of a phase, in their declared order
+func HooksForPhase(hooks []LifecycleHook, phase HookPhase) []LifecycleHook {
+	var selected []LifecycleHook
+	for _, hook := range hooks {
+		if hook.Phase == phase {
+			selected = append(selected, hook)
+		}
+	}
+	return selected
+}
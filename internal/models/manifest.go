@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ManifestAPIVersion = "v1"
+	ManifestKind       = "Deployments"
+)
+
+// Manifest declares the desired deployments of the instance. Deployments are
+// matched to existing ones by stack name
+type Manifest struct {
+	APIVersion  string             `json:"apiVersion"`
+	Kind        string             `json:"kind"`
+	Prune       bool               `json:"prune"` // Delete deployments missing from the manifest
+	Deployments []DeploymentConfig `json:"deployments"`
+}
+
+// ApplyAction is the change apply makes to converge one deployment
+type ApplyAction string
+
+const (
+	ApplyActionCreate    ApplyAction = "create"
+	ApplyActionUpdate    ApplyAction = "update"
+	ApplyActionReplace   ApplyAction = "replace" // Torn down and redeployed, e.g. on a deploy mode change
+	ApplyActionDelete    ApplyAction = "delete"
+	ApplyActionUnchanged ApplyAction = "unchanged"
+)
+
+// ApplyChange describes the planned or applied change of one deployment
+type ApplyChange struct {
+	StackName    string      `json:"stack_name"`
+	Action       ApplyAction `json:"action"`
+	DeploymentID string      `json:"deployment_id,omitempty"`
+	Fields       []string    `json:"fields,omitempty"` // Changed fields of an update
+	TaskID       string      `json:"task_id,omitempty"`
+	BackupID     string      `json:"backup_id,omitempty"` // Safety backup taken before a redeploy
+	Error        string      `json:"error,omitempty"`
+}
+
+// Manifest errors
+var (
+	ErrManifestInvalidVersion = fmt.Errorf("manifest apiVersion must be '%s'", ManifestAPIVersion)
+	ErrManifestInvalidKind    = fmt.Errorf("manifest kind must be '%s'", ManifestKind)
+)
+
+// ParseManifest parses a YAML or JSON manifest. YAML is converted to JSON first so
+// deployments use the same field names as the deployments API
+func ParseManifest(data []byte) (*Manifest, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	jsonData, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(jsonData, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// Validate validates the manifest and every deployment in it
+func (m *Manifest) Validate() error {
+	if m.APIVersion != ManifestAPIVersion {
+		return ErrManifestInvalidVersion
+	}
+	if m.Kind != ManifestKind {
+		return ErrManifestInvalidKind
+	}
+
+	seen := make(map[string]bool)
+	for i := range m.Deployments {
+		deployment := &m.Deployments[i]
+		if err := deployment.Validate(); err != nil {
+			return fmt.Errorf("deployment %d (%s): %w", i+1, deployment.StackName, err)
+		}
+		if seen[deployment.StackName] {
+			return fmt.Errorf("stack name %s is declared more than once", deployment.StackName)
+		}
+		seen[deployment.StackName] = true
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ModerationContentType is the kind of text a moderation item holds
+type ModerationContentType string
+
+const (
+	ModerationReview              ModerationContentType = "review"
+	ModerationTemplateDescription ModerationContentType = "template_description"
+)
+
+// ModerationStatus is the state of a moderation item, and of a review
+type ModerationStatus string
+
+const (
+	ModerationPending  ModerationStatus = "pending"
+	ModerationApproved ModerationStatus = "approved"
+	ModerationRejected ModerationStatus = "rejected"
+)
+
+// PolicyViolation is a content policy rule a text breaks
+type PolicyViolation struct {
+	Rule   string `json:"rule"` // banned-word, too-many-links or too-long
+	Detail string `json:"detail"`
+}
+
+// ModerationItem is a review or template description queued for a moderator
+type ModerationItem struct {
+	ID          int                   `json:"id" db:"id"`
+	ContentType ModerationContentType `json:"content_type" db:"content_type"`
+	TemplateID  string                `json:"template_id" db:"template_id"`
+	AuthorID    string                `json:"author_id,omitempty" db:"author_id"`
+	Content     string                `json:"content" db:"content"`
+	ContentHash string                `json:"-" db:"content_hash"`
+	Violations  []PolicyViolation     `json:"violations" db:"violations"`
+	Status      ModerationStatus      `json:"status" db:"status"`
+	ReviewedBy  string                `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewedAt  *time.Time            `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	CreatedAt   time.Time             `json:"created_at" db:"created_at"`
+}
+
+// Moderation errors
+var (
+	ErrModerationItemNotFound = fmt.Errorf("moderation item not found")
+	ErrModerationDecided      = fmt.Errorf("moderation item was already decided")
+)
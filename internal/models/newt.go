@@ -39,9 +39,61 @@ type NewtValidationResult struct {
 	Issues        []string `json:"issues"`
 	Version       string   `json:"version"`
 	Features      []string `json:"features"`
+	TLS           *NewtTLSInfo `json:"tls,omitempty"`
+	Tests         []NewtConnectionTest `json:"tests"`
 	TestedAt      time.Time `json:"tested_at"`
 }
 
+// NewtTLSInfo describes the certificate presented by a Pangolin endpoint
+type NewtTLSInfo struct {
+	Version       string    `json:"version"`
+	Subject       string    `json:"subject"`
+	Issuer        string    `json:"issuer"`
+	DNSNames      []string  `json:"dns_names"`
+	NotAfter      time.Time `json:"not_after"`
+	DaysRemaining int       `json:"days_remaining"`
+	Verified      bool      `json:"verified"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// NewtTunnelEvent records a change in the tunnel state of a deployment
+type NewtTunnelEvent struct {
+	ID            int       `json:"id" db:"id"`
+	DeploymentID  string    `json:"deployment_id" db:"deployment_id"`
+	PreviousState string    `json:"previous_state" db:"previous_state"`
+	State         string    `json:"state" db:"state"`
+	Message       string    `json:"message" db:"message"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// Tunnel state constants
+const (
+	TunnelStateConnected    = "connected"
+	TunnelStateDisconnected = "disconnected"
+	TunnelStateStopped      = "stopped"
+	TunnelStateMissing      = "missing"
+)
+
+// NewtSecretRotation is a request to replace the active Newt secret
+type NewtSecretRotation struct {
+	Secret string `json:"newt_secret"`
+}
+
+// NewtRolloutStatus reports the rollout of a Newt config change to one stack
+type NewtRolloutStatus struct {
+	DeploymentID string `json:"deployment_id"`
+	StackName    string `json:"stack_name"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+}
+
+// Rollout status constants
+const (
+	RolloutStatusUpdated = "updated"
+	RolloutStatusSkipped = "skipped"
+	RolloutStatusFailed  = "failed"
+)
+
 // NewtServiceConfig represents Newt service configuration for Docker Compose
 type NewtServiceConfig struct {
 	Image         string            `json:"image"`
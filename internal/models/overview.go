@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Overview is the aggregate dashboard payload. Sections that could not be collected
+// are left empty and their error is reported in Errors
+type Overview struct {
+	Deployments     DeploymentCounts    `json:"deployments"`
+	UnhealthyStacks []UnhealthyStack    `json:"unhealthy_stacks"`
+	RecentFailures  []DeploymentFailure `json:"recent_failures"`
+	LatestBackups   []Backup            `json:"latest_backups"`
+	Tunnels         TunnelSummary       `json:"tunnels"`
+	Disk            *StorageReport      `json:"disk"`
+	LastSync        interface{}         `json:"last_sync"` // Last GitHub sync result, nil before the first
+	Errors          map[string]string   `json:"errors,omitempty"`
+	GeneratedAt     time.Time           `json:"generated_at"`
+}
+
+// DeploymentCounts holds the number of deployments in each status
+type DeploymentCounts struct {
+	Total    int                      `json:"total"`
+	ByStatus map[DeploymentStatus]int `json:"by_status"`
+}
+
+// UnhealthyStack is a managed stack with at least one container failing its health check
+type UnhealthyStack struct {
+	DeploymentID string   `json:"deployment_id"`
+	StackName    string   `json:"stack_name"`
+	Containers   []string `json:"containers"`
+}
+
+// DeploymentFailure is a failed deployment and the error of its last failed task
+type DeploymentFailure struct {
+	DeploymentID string    `json:"deployment_id"`
+	StackName    string    `json:"stack_name"`
+	TemplateID   string    `json:"template_id"`
+	Error        string    `json:"error,omitempty"`
+	FailedAt     time.Time `json:"failed_at"`
+}
+
+// TunnelSummary counts deployments with Newt injected by their last known tunnel state
+type TunnelSummary struct {
+	Enabled      bool           `json:"enabled"`
+	Total        int            `json:"total"`
+	ByState      map[string]int `json:"by_state"`
+	Disconnected []string       `json:"disconnected"` // Stack names not connected
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Portainer stack and template types
+const (
+	PortainerStackSwarm   = 1
+	PortainerStackCompose = 2
+
+	PortainerTemplateContainer = 1
+	PortainerTemplateSwarm     = 2
+	PortainerTemplateCompose   = 3
+)
+
+// PortainerImportRequest imports the stacks and app templates of a Portainer
+// instance, read from its API when URL is set. Stacks and templates exported
+// from Portainer can be given instead: an app templates file decodes as is
+type PortainerImportRequest struct {
+	URL        string `json:"url,omitempty"`         // Base URL of the Portainer instance
+	APIKey     string `json:"api_key,omitempty"`     // Access token, sent as X-API-Key
+	EndpointID int    `json:"endpoint_id,omitempty"` // Only stacks of this environment; all unless set
+	ProjectID  string `json:"project_id,omitempty"`  // Project of the imported deployments; global unless set
+	DryRun     bool   `json:"dry_run"`               // Report what would be imported without importing it
+
+	Version   string              `json:"version,omitempty"` // Of an app templates file
+	Stacks    []PortainerStack    `json:"stacks,omitempty"`
+	Templates []PortainerTemplate `json:"templates,omitempty"`
+}
+
+// PortainerStack is a stack as Portainer's API lists it. StackFileContent is
+// read separately from the API, but must be set in exported stacks
+type PortainerStack struct {
+	ID               int                 `json:"Id"`
+	Name             string              `json:"Name"`
+	Type             int                 `json:"Type"` // 1 swarm, 2 compose
+	EndpointID       int                 `json:"EndpointId"`
+	Status           int                 `json:"Status"` // 1 active, 2 inactive
+	Env              []PortainerEnvVar   `json:"Env"`
+	GitConfig        *PortainerGitConfig `json:"GitConfig,omitempty"`
+	StackFileContent string              `json:"StackFileContent,omitempty"`
+}
+
+// PortainerEnvVar is an environment variable of a Portainer stack
+type PortainerEnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PortainerGitConfig is the repository a Portainer stack is deployed from
+type PortainerGitConfig struct {
+	URL            string `json:"URL"`
+	ReferenceName  string `json:"ReferenceName"` // refs/heads/<branch>
+	ConfigFilePath string `json:"ConfigFilePath"`
+}
+
+// PortainerTemplate is an app template of Portainer's templates file
+type PortainerTemplate struct {
+	Type        int                    `json:"type"` // 1 container, 2 swarm stack, 3 compose stack
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Logo        string                 `json:"logo"`
+	Categories  []string               `json:"categories"`
+	Repository  *PortainerRepository   `json:"repository,omitempty"`
+	Env         []PortainerTemplateEnv `json:"env,omitempty"`
+}
+
+// PortainerRepository is the git repository of a stack template
+type PortainerRepository struct {
+	URL       string `json:"url"`
+	StackFile string `json:"stackfile"`
+}
+
+// PortainerTemplateEnv is a variable of a Portainer app template. Preset
+// variables are not asked for and keep their default
+type PortainerTemplateEnv struct {
+	Name        string                    `json:"name"`
+	Label       string                    `json:"label"`
+	Description string                    `json:"description"`
+	Default     string                    `json:"default"`
+	Preset      bool                      `json:"preset"`
+	Select      []PortainerTemplateOption `json:"select,omitempty"`
+}
+
+// PortainerTemplateOption is a choice of a select variable
+type PortainerTemplateOption struct {
+	Text    string `json:"text"`
+	Value   string `json:"value"`
+	Default bool   `json:"default"`
+}
+
+// PortainerImportResult reports what a Portainer import created, or would
+// create on a dry run
+type PortainerImportResult struct {
+	DryRun      bool            `json:"dry_run"`
+	Templates   []string        `json:"templates"`   // IDs of the imported templates
+	Deployments []string        `json:"deployments"` // Stack names of the imported deployments
+	Skipped     []ImportSkipped `json:"skipped,omitempty"`
+}
+
+// ImportSkipped is a stack or template that an import left out
+type ImportSkipped struct {
+	Kind   string `json:"kind"` // stack or template
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+var (
+	ErrPortainerSourceRequired = fmt.Errorf("a Portainer URL, or exported stacks or templates, are required")
+	ErrPortainerURLInvalid     = fmt.Errorf("Portainer URL must be an absolute http or https URL")
+	ErrPortainerAPIKeyRequired = fmt.Errorf("a Portainer API key is required to read its API")
+)
+
+// Validate validates a Portainer import request
+func (r *PortainerImportRequest) Validate() error {
+	if r.URL == "" {
+		if len(r.Stacks) == 0 && len(r.Templates) == 0 {
+			return ErrPortainerSourceRequired
+		}
+	} else {
+		u, err := url.Parse(r.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrPortainerURLInvalid
+		}
+		if r.APIKey == "" {
+			return ErrPortainerAPIKeyRequired
+		}
+	}
+	if r.ProjectID != "" && !IsValidProjectID(r.ProjectID) {
+		return ErrProjectIDInvalid
+	}
+	return nil
+}
+
+// Skip records a stack or template that was not imported
+func (r *PortainerImportResult) Skip(kind, name, reason string) {
+	r.Skipped = append(r.Skipped, ImportSkipped{Kind: kind, Name: name, Reason: reason})
+}
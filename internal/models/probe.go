@@ -0,0 +1,164 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProbeType is the protocol an uptime probe checks with
+type ProbeType string
+
+const (
+	ProbeHTTP ProbeType = "http" // Requests a URL; http and https
+	ProbeTCP  ProbeType = "tcp"  // Opens a connection to host:port
+)
+
+// ProbeStatus is the state of an uptime probe
+type ProbeStatus string
+
+const (
+	ProbeUnknown ProbeStatus = "unknown" // Not checked yet
+	ProbeUp      ProbeStatus = "up"
+	ProbeDown    ProbeStatus = "down" // Failed FailureThreshold checks in a row
+)
+
+const (
+	minProbeInterval = 10 // Seconds
+	maxProbeInterval = 24 * 3600
+)
+
+// UptimeProbe checks that a deployment answers on an interval. The host of
+// its target may be a service of the stack, reached at its container address
+type UptimeProbe struct {
+	ID                  int64        `json:"id" db:"id"`
+	DeploymentID        string       `json:"deployment_id" db:"deployment_id"`
+	Name                string       `json:"name" db:"name"`
+	Type                ProbeType    `json:"type" db:"type"`
+	Target              string       `json:"target" db:"target"`                   // URL for http, host:port for tcp
+	Interval            int          `json:"interval" db:"interval_seconds"`       // Seconds between checks
+	Timeout             int          `json:"timeout" db:"timeout_seconds"`         // Seconds
+	ExpectedStatus      int          `json:"expected_status" db:"expected_status"` // 0 accepts any 2xx or 3xx
+	Keyword             string       `json:"keyword,omitempty" db:"keyword"`       // Must appear in the HTTP response body
+	FailureThreshold    int          `json:"failure_threshold" db:"failure_threshold"`
+	Enabled             bool         `json:"enabled" db:"enabled"`
+	Status              ProbeStatus  `json:"status" db:"status"`
+	ConsecutiveFailures int          `json:"consecutive_failures" db:"consecutive_failures"`
+	LastCheckAt         *time.Time   `json:"last_check_at,omitempty" db:"last_check_at"`
+	LastLatencyMs       int64        `json:"last_latency_ms" db:"last_latency_ms"`
+	LastError           string       `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt           time.Time    `json:"created_at" db:"created_at"`
+	Uptime              *UptimeStats `json:"uptime,omitempty" db:"-"`
+}
+
+// UptimeProbeRequest creates or updates an uptime probe. Omitted numbers take
+// their defaults
+type UptimeProbeRequest struct {
+	Name             string    `json:"name"`
+	Type             ProbeType `json:"type"`
+	Target           string    `json:"target"`
+	Interval         int       `json:"interval"` // 60 unless set
+	Timeout          int       `json:"timeout"`  // 10 unless set
+	ExpectedStatus   int       `json:"expected_status"`
+	Keyword          string    `json:"keyword"`
+	FailureThreshold int       `json:"failure_threshold"` // 3 unless set
+	Enabled          *bool     `json:"enabled,omitempty"`
+}
+
+// ProbeCheck is the result of one check of a probe
+type ProbeCheck struct {
+	ProbeID    int64     `json:"probe_id" db:"probe_id"`
+	Up         bool      `json:"up" db:"up"`
+	LatencyMs  int64     `json:"latency_ms" db:"latency_ms"`
+	StatusCode int       `json:"status_code,omitempty" db:"status_code"`
+	Error      string    `json:"error,omitempty" db:"error"`
+	CheckedAt  time.Time `json:"checked_at" db:"checked_at"`
+}
+
+// UptimeStats summarizes the checks of a probe over a window
+type UptimeStats struct {
+	Window        string  `json:"window"`
+	Checks        int     `json:"checks"`
+	UpChecks      int     `json:"up_checks"`
+	UptimePercent float64 `json:"uptime_percent"` // 0 without checks
+	LatencyP50    int64   `json:"latency_p50_ms"` // Of successful checks
+	LatencyP95    int64   `json:"latency_p95_ms"`
+	LatencyP99    int64   `json:"latency_p99_ms"`
+}
+
+var (
+	ErrProbeNotFound         = fmt.Errorf("uptime probe not found")
+	ErrProbeNameRequired     = fmt.Errorf("probe name is required")
+	ErrProbeTypeInvalid      = fmt.Errorf("probe type must be 'http' or 'tcp'")
+	ErrProbeTargetInvalid    = fmt.Errorf("probe target must be an http(s) URL for http probes and host:port for tcp probes")
+	ErrProbeIntervalInvalid  = fmt.Errorf("probe interval must be between %d and %d seconds", minProbeInterval, maxProbeInterval)
+	ErrProbeTimeoutInvalid   = fmt.Errorf("probe timeout must be positive and shorter than its interval")
+	ErrProbeStatusInvalid    = fmt.Errorf("expected status must be 0 or an HTTP status code")
+	ErrProbeThresholdInvalid = fmt.Errorf("failure threshold must be between 1 and 100")
+)
+
+// Apply copies the fields of a request onto a probe, with defaults for the
+// numbers left out
+func (r *UptimeProbeRequest) Apply(probe *UptimeProbe) {
+	probe.Name = strings.TrimSpace(r.Name)
+	probe.Type = r.Type
+	probe.Target = strings.TrimSpace(r.Target)
+	probe.Interval = r.Interval
+	if probe.Interval == 0 {
+		probe.Interval = 60
+	}
+	probe.Timeout = r.Timeout
+	if probe.Timeout == 0 {
+		probe.Timeout = 10
+	}
+	probe.ExpectedStatus = r.ExpectedStatus
+	probe.Keyword = r.Keyword
+	probe.FailureThreshold = r.FailureThreshold
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = 3
+	}
+	if r.Enabled != nil {
+		probe.Enabled = *r.Enabled
+	}
+}
+
+// Validate validates an uptime probe
+func (p *UptimeProbe) Validate() error {
+	if p.Name == "" {
+		return ErrProbeNameRequired
+	}
+	switch p.Type {
+	case ProbeHTTP:
+		u, err := url.Parse(p.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrProbeTargetInvalid
+		}
+	case ProbeTCP:
+		host, port, err := net.SplitHostPort(p.Target)
+		if err != nil || host == "" || port == "" {
+			return ErrProbeTargetInvalid
+		}
+	default:
+		return ErrProbeTypeInvalid
+	}
+	if p.Interval < minProbeInterval || p.Interval > maxProbeInterval {
+		return ErrProbeIntervalInvalid
+	}
+	if p.Timeout <= 0 || p.Timeout >= p.Interval {
+		return ErrProbeTimeoutInvalid
+	}
+	if p.ExpectedStatus != 0 && (p.ExpectedStatus < 100 || p.ExpectedStatus > 599) {
+		return ErrProbeStatusInvalid
+	}
+	if p.FailureThreshold < 1 || p.FailureThreshold > 100 {
+		return ErrProbeThresholdInvalid
+	}
+	return nil
+}
+
+// Due reports whether the probe should be checked at now
+func (p *UptimeProbe) Due(now time.Time) bool {
+	return p.Enabled && (p.LastCheckAt == nil || !now.Before(p.LastCheckAt.Add(time.Duration(p.Interval)*time.Second)))
+}
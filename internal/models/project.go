@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// DefaultProjectID is the project deployments belong to unless another is given
+const DefaultProjectID = "global"
+
+// Project groups deployments, typically of one team or environment
+type Project struct {
+	ID          string    `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Deployments int       `json:"deployments" db:"-"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// ProjectMember gives a user a role within a project, replacing their global role
+// for the project's deployments
+type ProjectMember struct {
+	ProjectID string    `json:"project_id" db:"project_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Username  string    `json:"username,omitempty" db:"-"`
+	Role      UserRole  `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+var projectIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Validation errors
+var (
+	ErrProjectIDInvalid     = fmt.Errorf("project ID must be lowercase letters, digits and hyphens")
+	ErrProjectNameRequired  = fmt.Errorf("project name is required")
+	ErrProjectNotFound      = fmt.Errorf("project not found")
+	ErrProjectMemberInvalid = fmt.Errorf("member requires a user ID and a role of 'viewer', 'operator' or 'admin'")
+)
+
+// Validate validates project data
+func (p *Project) Validate() error {
+	if !IsValidProjectID(p.ID) {
+		return ErrProjectIDInvalid
+	}
+	if p.Name == "" {
+		return ErrProjectNameRequired
+	}
+	return nil
+}
+
+// Validate validates project member data
+func (pm *ProjectMember) Validate() error {
+	if pm.UserID == "" {
+		return ErrProjectMemberInvalid
+	}
+	switch pm.Role {
+	case RoleViewer, RoleOperator, RoleAdmin:
+		return nil
+	}
+	return ErrProjectMemberInvalid
+}
+
+// IsValidProjectID reports whether id is a valid project slug
+func IsValidProjectID(id string) bool {
+	return projectIDPattern.MatchString(id)
+}
+
+// PromotionRequest promotes a deployment into another project, typically from a
+// dev to a prod environment
+type PromotionRequest struct {
+	ProjectID   string            `json:"project_id"`
+	StackName   string            `json:"stack_name"`
+	Environment map[string]string `json:"environment,omitempty"` // Overrides merged over the source's environment
+	NewtConfig  *NewtConfig       `json:"newt_config,omitempty"` // Replaces the source's newt settings
+	AutoStart   *bool             `json:"auto_start,omitempty"`
+}
+
+// Promotion records a deployment promoted from another, for audit
+type Promotion struct {
+	ID                 int       `json:"id" db:"id"`
+	SourceDeploymentID string    `json:"source_deployment_id" db:"source_deployment_id"`
+	TargetDeploymentID string    `json:"target_deployment_id" db:"target_deployment_id"`
+	SourceProjectID    string    `json:"source_project_id" db:"source_project_id"`
+	TargetProjectID    string    `json:"target_project_id" db:"target_project_id"`
+	TemplateID         string    `json:"template_id" db:"template_id"`
+	TemplateVersion    string    `json:"template_version" db:"template_version"`
+	OverriddenKeys     []string  `json:"overridden_keys" db:"overridden_keys"`
+	PromotedBy         string    `json:"promoted_by" db:"promoted_by"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+}
+
+// Validate validates a promotion request
+func (pr *PromotionRequest) Validate() error {
+	if !IsValidProjectID(pr.ProjectID) {
+		return ErrProjectIDInvalid
+	}
+	if pr.StackName == "" {
+		return ErrDeploymentStackNameRequired
+	}
+	if !isValidStackName(pr.StackName) {
+		return ErrDeploymentInvalidStackName
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PublisherKeyType is the signing tool a publisher key belongs to
+type PublisherKeyType string
+
+const (
+	// PublisherKeyCosign is a PEM public key verifying `cosign sign-blob` signatures,
+	// published as <manifest>.sig
+	PublisherKeyCosign PublisherKeyType = "cosign"
+	// PublisherKeyMinisign is a minisign public key verifying <manifest>.minisig
+	PublisherKeyMinisign PublisherKeyType = "minisign"
+)
+
+// PublisherKey is a public key trusted to sign a publisher's template manifests.
+// A template is verified only when its manifest carries a valid signature by one
+// of its publisher's keys
+type PublisherKey struct {
+	ID          string           `json:"id" db:"id"`
+	PublisherID string           `json:"publisher_id" db:"publisher_id"` // GitHub owner
+	KeyType     PublisherKeyType `json:"key_type" db:"key_type"`
+	PublicKey   string           `json:"public_key" db:"public_key"`
+	Fingerprint string           `json:"fingerprint" db:"fingerprint"`
+	Description string           `json:"description,omitempty" db:"description"`
+	CreatedBy   string           `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt   time.Time        `json:"created_at" db:"created_at"`
+}
+
+// Publisher key errors
+var (
+	ErrPublisherKeyNotFound = fmt.Errorf("publisher key not found")
+	ErrPublisherKeyExists   = fmt.Errorf("key is already trusted for this publisher")
+	ErrPublisherRequired    = fmt.Errorf("publisher_id is required")
+	ErrPublisherKeyType     = fmt.Errorf("key_type must be cosign or minisign")
+	ErrPublisherKeyRequired = fmt.Errorf("public_key is required")
+)
+
+// Validate validates a publisher key, normalizing the publisher to lowercase
+// since GitHub owners are case-insensitive. The key itself is parsed by the
+// signature verifier
+func (k *PublisherKey) Validate() error {
+	k.PublisherID = strings.ToLower(strings.TrimSpace(k.PublisherID))
+	if k.PublisherID == "" {
+		return ErrPublisherRequired
+	}
+	if k.KeyType != PublisherKeyCosign && k.KeyType != PublisherKeyMinisign {
+		return ErrPublisherKeyType
+	}
+	k.PublicKey = strings.TrimSpace(k.PublicKey)
+	if k.PublicKey == "" {
+		return ErrPublisherKeyRequired
+	}
+	return nil
+}
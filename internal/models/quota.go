@@ -0,0 +1,69 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuotaScope is what a quota limits: a project or a user
+type QuotaScope string
+
+const (
+	QuotaScopeProject QuotaScope = "project"
+	QuotaScopeUser    QuotaScope = "user"
+)
+
+// QuotaLimits are the limits of a quota; 0 is unlimited
+type QuotaLimits struct {
+	MaxDeployments     int `json:"max_deployments"`
+	MaxMemoryMB        int `json:"max_memory_mb"`         // Sum of the memory limits of running containers
+	MaxBackupStorageMB int `json:"max_backup_storage_mb"` // Size of completed backups
+}
+
+// QuotaUpdate changes the limits of a project's or user's quota. Limits left out
+// keep the configured default
+type QuotaUpdate struct {
+	MaxDeployments     *int `json:"max_deployments"`
+	MaxMemoryMB        *int `json:"max_memory_mb"`
+	MaxBackupStorageMB *int `json:"max_backup_storage_mb"`
+}
+
+// QuotaResource is the consumption of one resource against its limit
+type QuotaResource struct {
+	Used    int64 `json:"used"`
+	Limit   int64 `json:"limit"`   // 0 is unlimited
+	Reached bool  `json:"reached"` // Nothing more can be created
+}
+
+// QuotaUsage is the consumption of a project or user against its quota. Memory
+// and backup storage are in bytes
+type QuotaUsage struct {
+	Scope         QuotaScope    `json:"scope"`
+	ScopeID       string        `json:"scope_id"`
+	Enforced      bool          `json:"enforced"`
+	Custom        bool          `json:"custom"` // Limits were set for the scope, replacing the defaults
+	Deployments   QuotaResource `json:"deployments"`
+	Memory        QuotaResource `json:"memory"`
+	BackupStorage QuotaResource `json:"backup_storage"`
+	UpdatedAt     *time.Time    `json:"updated_at,omitempty"`
+}
+
+// Validation errors
+var (
+	ErrQuotaLimitInvalid = fmt.Errorf("quota limits must not be negative")
+)
+
+// Validate validates a quota update
+func (qu *QuotaUpdate) Validate() error {
+	for _, limit := range []*int{qu.MaxDeployments, qu.MaxMemoryMB, qu.MaxBackupStorageMB} {
+		if limit != nil && *limit < 0 {
+			return ErrQuotaLimitInvalid
+		}
+	}
+	return nil
+}
+
+// NewQuotaResource reports used against limit, where a limit of 0 is unlimited
+func NewQuotaResource(used, limit int64) QuotaResource {
+	return QuotaResource{Used: used, Limit: limit, Reached: limit > 0 && used >= limit}
+}
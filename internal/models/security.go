@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// SecuritySeverity ranks a security finding
+type SecuritySeverity string
+
+const (
+	SecuritySeverityCritical SecuritySeverity = "critical"
+	SecuritySeverityHigh     SecuritySeverity = "high"
+	SecuritySeverityMedium   SecuritySeverity = "medium"
+	SecuritySeverityLow      SecuritySeverity = "low"
+)
+
+// SecurityBadge summarizes the security scan of a template for the marketplace
+type SecurityBadge string
+
+const (
+	SecurityBadgePassed    SecurityBadge = "passed"    // No findings
+	SecurityBadgeWarning   SecurityBadge = "warning"   // Medium or low findings only
+	SecurityBadgeCritical  SecurityBadge = "critical"  // Critical or high findings
+	SecurityBadgeUnscanned SecurityBadge = "unscanned" // Not scanned, or the compose file did not parse
+)
+
+// SecurityFinding is a risky setting found in a template's compose file
+type SecurityFinding struct {
+	Rule     string           `json:"rule"`
+	Severity SecuritySeverity `json:"severity"`
+	Service  string           `json:"service"`
+	Message  string           `json:"message"`
+}
+
+// SecurityScan is the security scan of one version of a template
+type SecurityScan struct {
+	TemplateID string            `json:"template_id"`
+	Version    string            `json:"version"`
+	Badge      SecurityBadge     `json:"badge"`
+	Findings   []SecurityFinding `json:"findings"`
+	ScannedAt  time.Time         `json:"scanned_at"`
+}
+
+// SecurityBadgeOf returns the badge earned by a scan's findings
+func SecurityBadgeOf(findings []SecurityFinding) SecurityBadge {
+	badge := SecurityBadgePassed
+	for _, f := range findings {
+		switch f.Severity {
+		case SecuritySeverityCritical, SecuritySeverityHigh:
+			return SecurityBadgeCritical
+		default:
+			badge = SecurityBadgeWarning
+		}
+	}
+	return badge
+}
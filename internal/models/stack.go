@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"sort"
 	"time"
 )
 
@@ -28,6 +30,58 @@ type Stack struct {
 	Stats        *StackStats         `json:"stats,omitempty"`
 }
 
+// StackSummary is the container state of a stack, as the stacks list shows it
+type StackSummary struct {
+	ID                string      `json:"id"`
+	Name              string      `json:"name"`
+	ProjectID         string      `json:"project_id"`
+	TemplateName      string      `json:"template_name"`
+	DeployMode        DeployMode  `json:"deploy_mode"`
+	Status            StackStatus `json:"status"`
+	Services          int         `json:"services"`
+	RunningServices   int         `json:"running_services"`
+	Containers        int         `json:"containers"`
+	RunningContainers int         `json:"running_containers"`
+	Unhealthy         int         `json:"unhealthy"` // Containers failing their health check
+	Degraded          bool        `json:"degraded"`  // A service is in a restart loop
+	NewtInjected      bool        `json:"newt_injected"`
+	TunnelURL         string      `json:"tunnel_url"`
+	CreatedAt         time.Time   `json:"created_at"`
+}
+
+// StackEvent is a container lifecycle event of a stack, recorded by the monitor
+type StackEvent struct {
+	ID           int64     `json:"id"`
+	DeploymentID string    `json:"deployment_id"`
+	ServiceName  string    `json:"service_name"`
+	ContainerID  string    `json:"container_id"`
+	Action       string    `json:"action"`
+	ExitCode     *int      `json:"exit_code,omitempty"` // die events only
+	Image        string    `json:"image"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Container actions recorded as stack events
+const (
+	StackEventStart = "start"
+	StackEventStop  = "stop"
+	StackEventDie   = "die"
+	StackEventOOM   = "oom"
+)
+
+// RestartLoop is a service flagged for restarting repeatedly. Its deployment is
+// degraded until the loop is cleared
+type RestartLoop struct {
+	ID           int64      `json:"id"`
+	DeploymentID string     `json:"deployment_id"`
+	ServiceName  string     `json:"service_name"`
+	Restarts     int        `json:"restarts"`
+	Window       int        `json:"window"` // Minutes
+	Stopped      bool       `json:"stopped"`
+	DetectedAt   time.Time  `json:"detected_at"`
+	ClearedAt    *time.Time `json:"cleared_at,omitempty"`
+}
+
 // StackService represents a service within a stack
 type StackService struct {
 	Name        string            `json:"name"`
@@ -40,6 +94,10 @@ type StackService struct {
 	Labels      map[string]string `json:"labels"`
 	CreatedAt   time.Time         `json:"created_at"`
 	Stats       *ServiceStats     `json:"stats,omitempty"`
+
+	// Swarm mode only
+	Replicas        int `json:"replicas,omitempty"`
+	DesiredReplicas int `json:"desired_replicas,omitempty"`
 }
 
 // ServicePort represents a port mapping for a service
@@ -68,29 +126,98 @@ type StackVolume struct {
 	Labels     map[string]string `json:"labels"`
 }
 
-// StackStats represents resource usage statistics for a stack
+// VolumeFileType is the kind of an entry in a volume
+type VolumeFileType string
+
+const (
+	VolumeFileRegular   VolumeFileType = "file"
+	VolumeFileDirectory VolumeFileType = "directory"
+	VolumeFileSymlink   VolumeFileType = "symlink"
+	VolumeFileOther     VolumeFileType = "other"
+)
+
+// VolumeFile is an entry of a volume directory, as the volume browser lists it.
+// Path is relative to the root of the volume
+type VolumeFile struct {
+	Name       string         `json:"name"`
+	Path       string         `json:"path"`
+	Type       VolumeFileType `json:"type"`
+	Size       int64          `json:"size"`
+	Mode       string         `json:"mode"` // Octal permission bits
+	ModifiedAt time.Time      `json:"modified_at"`
+}
+
+// VolumeListing is the content of a volume directory
+type VolumeListing struct {
+	Volume    string       `json:"volume"`
+	Path      string       `json:"path"`
+	Files     []VolumeFile `json:"files"`
+	Truncated bool         `json:"truncated"` // More entries than the browser lists
+}
+
+// StackStats represents resource usage statistics for a stack, summed over its
+// running containers. The memory limit is the sum of the containers' limits
 type StackStats struct {
-	CPUUsage    float64 `json:"cpu_usage"`
-	MemoryUsage int64   `json:"memory_usage"`
-	MemoryLimit int64   `json:"memory_limit"`
-	NetworkRx   int64   `json:"network_rx"`
-	NetworkTx   int64   `json:"network_tx"`
-	BlockRead   int64   `json:"block_read"`
-	BlockWrite  int64   `json:"block_write"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	StackName       string         `json:"stack_name"`
+	TotalServices   int            `json:"total_services"`
+	RunningServices int            `json:"running_services"`
+	Containers      int            `json:"containers"` // Running containers measured
+	CPUUsage        float64        `json:"cpu_usage"`  // Percent of one CPU, so it may exceed 100
+	MemoryUsage     int64          `json:"memory_usage"`
+	MemoryLimit     int64          `json:"memory_limit"`
+	MemoryPercent   float64        `json:"memory_percent"`
+	NetworkRx       int64          `json:"network_rx"`
+	NetworkTx       int64          `json:"network_tx"`
+	BlockRead       int64          `json:"block_read"`
+	BlockWrite      int64          `json:"block_write"`
+	PIDs            int            `json:"pids"`
+	Services        []ServiceStats `json:"services"`
+	Errors          []string       `json:"errors,omitempty"` // Containers whose stats could not be read
+	SampledAt       time.Time      `json:"sampled_at"`       // Oldest container sample, which may be cached
+	Age             float64        `json:"age"`              // Seconds since SampledAt
+	UpdatedAt       time.Time      `json:"updated_at"`
 }
 
-// ServiceStats represents resource usage statistics for a service
+// ServiceStats represents resource usage statistics for a service, or for one of
+// its containers
 type ServiceStats struct {
-	CPUUsage     float64 `json:"cpu_usage"`
-	MemoryUsage  int64   `json:"memory_usage"`
-	MemoryLimit  int64   `json:"memory_limit"`
-	NetworkRx    int64   `json:"network_rx"`
-	NetworkTx    int64   `json:"network_tx"`
-	BlockRead    int64   `json:"block_read"`
-	BlockWrite   int64   `json:"block_write"`
-	PIDs         int     `json:"pids"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	Name        string    `json:"name,omitempty"`
+	Containers  int       `json:"containers,omitempty"`
+	CPUUsage    float64   `json:"cpu_usage"`
+	MemoryUsage int64     `json:"memory_usage"`
+	MemoryLimit int64     `json:"memory_limit"`
+	NetworkRx   int64     `json:"network_rx"`
+	NetworkTx   int64     `json:"network_tx"`
+	BlockRead   int64     `json:"block_read"`
+	BlockWrite  int64     `json:"block_write"`
+	PIDs        int       `json:"pids"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Add sums the usage of another container into the stats
+func (s *ServiceStats) Add(other *ServiceStats) {
+	s.Containers++
+	s.CPUUsage += other.CPUUsage
+	s.MemoryUsage += other.MemoryUsage
+	s.MemoryLimit += other.MemoryLimit
+	s.NetworkRx += other.NetworkRx
+	s.NetworkTx += other.NetworkTx
+	s.BlockRead += other.BlockRead
+	s.BlockWrite += other.BlockWrite
+	s.PIDs += other.PIDs
+	if other.UpdatedAt.After(s.UpdatedAt) {
+		s.UpdatedAt = other.UpdatedAt
+	}
+}
+
+// ImageUpdate represents the registry update status of a service image
+type ImageUpdate struct {
+	ServiceName     string    `json:"service_name"`
+	Image           string    `json:"image"`
+	CurrentDigest   string    `json:"current_digest"`
+	LatestDigest    string    `json:"latest_digest"`
+	UpdateAvailable bool      `json:"update_available"`
+	CheckedAt       time.Time `json:"checked_at"`
 }
 
 // StackOperation represents an operation that can be performed on a stack
@@ -234,4 +361,72 @@ func formatBytes(bytes int64) string {
 		exp++
 	}
 	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
\ No newline at end of file
+}
+// StackDependencies lists the stacks a stack needs running before it starts, such
+// as the database stack of an app stack. Dependencies stay within one project
+type StackDependencies struct {
+	DeploymentID string   `json:"deployment_id"`
+	ProjectID    string   `json:"project_id"`
+	DependsOn    []string `json:"depends_on"`
+}
+
+// StackOperationResult is the outcome of starting or stopping one stack of a bulk
+// operation
+type StackOperationResult struct {
+	DeploymentID string `json:"deployment_id"`
+	StackName    string `json:"stack_name"`
+	Status       string `json:"status"` // started, stopped, failed or skipped
+	Error        string `json:"error,omitempty"`
+}
+
+// ErrStackDependencyCycle is returned when dependencies between stacks form a cycle
+var ErrStackDependencyCycle = fmt.Errorf("stack dependencies form a cycle")
+
+// OrderStacks returns the stacks in an order where every stack comes after the
+// stacks it depends on. Stacks without an ordering constraint between them keep
+// their order in stacks. Dependencies on stacks not listed are ignored
+func OrderStacks(stacks []string, dependsOn map[string][]string) ([]string, error) {
+	position := make(map[string]int, len(stacks))
+	for i, id := range stacks {
+		position[id] = i
+	}
+
+	pending := make(map[string]int, len(stacks))
+	dependents := make(map[string][]string)
+	for _, id := range stacks {
+		for _, dep := range dependsOn[id] {
+			if _, ok := position[dep]; !ok || dep == id {
+				continue
+			}
+			pending[id]++
+			dependents[dep] = append(dependents[dep], id)
+		}
+	}
+
+	var ready []string
+	for _, id := range stacks {
+		if pending[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	order := make([]string, 0, len(stacks))
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+
+		for _, dependent := range dependents[id] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+		sort.SliceStable(ready, func(i, j int) bool { return position[ready[i]] < position[ready[j]] })
+	}
+
+	if len(order) != len(stacks) {
+		return nil, ErrStackDependencyCycle
+	}
+	return order, nil
+}
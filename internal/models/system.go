@@ -0,0 +1,194 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// CleanupOptions selects which orphaned Docker resources to remove
+type CleanupOptions struct {
+	DanglingImages   bool `json:"dangling_images"`
+	UnusedNetworks   bool `json:"unused_networks"`
+	OrphanedVolumes  bool `json:"orphaned_volumes"`
+	VolumeMinAgeDays int  `json:"volume_min_age_days"`
+	DryRun           bool `json:"dry_run"`
+}
+
+// CleanupItem is a single resource removed (or to be removed) by a cleanup
+type CleanupItem struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// CleanupReport summarizes the result of a cleanup run
+type CleanupReport struct {
+	DryRun         bool          `json:"dry_run"`
+	Images         []CleanupItem `json:"images"`
+	Networks       []CleanupItem `json:"networks"`
+	Volumes        []CleanupItem `json:"volumes"`
+	ReclaimedBytes int64         `json:"reclaimed_bytes"`
+	Errors         []string      `json:"errors,omitempty"`
+}
+
+// StorageReport summarizes disk usage of Docker, backups and the database
+type StorageReport struct {
+	Docker        DockerDiskUsage   `json:"docker"`
+	BackupsBytes  int64             `json:"backups_bytes"`
+	DatabaseBytes int64             `json:"database_bytes"`
+	Filesystems   []FilesystemUsage `json:"filesystems"`
+	Alerts        []StorageAlert    `json:"alerts"`
+	GeneratedAt   time.Time         `json:"generated_at"`
+}
+
+// DockerDiskUsage holds the figures returned by the Docker disk usage API
+type DockerDiskUsage struct {
+	ImageCount            int   `json:"image_count"`
+	ImagesBytes           int64 `json:"images_bytes"`
+	ImagesReclaimable     int64 `json:"images_reclaimable"`
+	ContainerCount        int   `json:"container_count"`
+	ContainersBytes       int64 `json:"containers_bytes"`
+	VolumeCount           int   `json:"volume_count"`
+	VolumesBytes          int64 `json:"volumes_bytes"`
+	BuildCacheBytes       int64 `json:"build_cache_bytes"`
+	BuildCacheReclaimable int64 `json:"build_cache_reclaimable"`
+	TotalBytes            int64 `json:"total_bytes"`
+}
+
+// FilesystemUsage describes the capacity of the filesystem holding a path
+type FilesystemUsage struct {
+	Name        string  `json:"name"`
+	Path        string  `json:"path"`
+	TotalBytes  uint64  `json:"total_bytes"`
+	UsedBytes   uint64  `json:"used_bytes"`
+	FreeBytes   uint64  `json:"free_bytes"`
+	UsedPercent float64 `json:"used_percent"`
+	Level       string  `json:"level"`
+}
+
+// StorageAlert is raised when a filesystem crosses a usage threshold
+type StorageAlert struct {
+	Level   string `json:"level"`
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Storage alert levels
+const (
+	StorageLevelOK       = "ok"
+	StorageLevelWarning  = "warning"
+	StorageLevelCritical = "critical"
+)
+
+// Validation errors
+var (
+	ErrCleanupNothingSelected = fmt.Errorf("at least one cleanup option must be selected")
+	ErrCleanupInvalidAge      = fmt.Errorf("volume minimum age cannot be negative")
+)
+
+// Validate validates cleanup options
+func (co *CleanupOptions) Validate() error {
+	if !co.DanglingImages && !co.UnusedNetworks && !co.OrphanedVolumes {
+		return ErrCleanupNothingSelected
+	}
+	if co.VolumeMinAgeDays < 0 {
+		return ErrCleanupInvalidAge
+	}
+	return nil
+}
+
+// TelemetryReport is the anonymous usage report sent when telemetry is enabled. It
+// holds counts only, never names, URLs, hostnames or configuration values
+type TelemetryReport struct {
+	InstanceID        string         `json:"instance_id"` // Random, not derived from the host
+	Version           string         `json:"version"`
+	OS                string         `json:"os"`
+	Arch              string         `json:"arch"`
+	GoVersion         string         `json:"go_version"`
+	TemplatesDeployed int            `json:"templates_deployed"` // Distinct templates with deployments
+	Deployments       int            `json:"deployments"`
+	DeploymentsByMode map[string]int `json:"deployments_by_mode"`
+	NewtDeployments   int            `json:"newt_deployments"`
+	Backups           int            `json:"backups"`
+	GeneratedAt       time.Time      `json:"generated_at"`
+}
+
+// MigrationManifest describes the contents of a migration bundle, which moves a
+// whole instance to another host
+type MigrationManifest struct {
+	Version        int               `json:"version"`
+	AppVersion     string            `json:"app_version"`
+	CreatedAt      time.Time         `json:"created_at"`
+	Deployments    int               `json:"deployments"`
+	Templates      int               `json:"templates"`
+	IncludeVolumes bool              `json:"include_volumes"`
+	Volumes        []MigrationVolume `json:"volumes,omitempty"`
+}
+
+// MigrationVolume is a Docker volume carried in a migration bundle
+type MigrationVolume struct {
+	Name   string            `json:"name"`
+	Driver string            `json:"driver"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// MigrationResult reports what an imported migration bundle rehydrated
+type MigrationResult struct {
+	Manifest    MigrationManifest `json:"manifest"`
+	Tables      map[string]int64  `json:"tables"` // Rows imported per table
+	Deployments []string          `json:"deployments"`
+	Skipped     []string          `json:"skipped,omitempty"` // Project directories or volumes that already existed
+	Volumes     []string          `json:"volumes,omitempty"`
+}
+
+// MaintenanceState is the maintenance mode of the instance. While active,
+// background jobs are paused and mutating API requests are refused
+type MaintenanceState struct {
+	Active       bool       `json:"active"`
+	Message      string     `json:"message,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	StartedBy    string     `json:"started_by,omitempty"`
+	RunningTasks int        `json:"running_tasks"` // Background tasks still to drain
+	Drained      bool       `json:"drained"`
+}
+
+// HealthStatus is the health of the instance or one of its dependencies
+type HealthStatus string
+
+const (
+	HealthStatusHealthy   HealthStatus = "healthy"
+	HealthStatusDegraded  HealthStatus = "degraded"  // Serving, with some features unavailable
+	HealthStatusUnhealthy HealthStatus = "unhealthy" // Not able to serve requests
+)
+
+// DependencyHealth is the result of checking one dependency of the instance
+type DependencyHealth struct {
+	Status    HealthStatus `json:"status"`
+	LatencyMS int64        `json:"latency_ms"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// ReadinessReport tells whether the instance can serve requests, with the health
+// of each dependency. The instance is as healthy as its least healthy dependency
+type ReadinessReport struct {
+	Status       HealthStatus                `json:"status"`
+	Timestamp    int64                       `json:"timestamp"`
+	Maintenance  bool                        `json:"maintenance"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"`
+}
+
+// Add records the health of a dependency and lowers the overall status to match
+func (rr *ReadinessReport) Add(name string, health DependencyHealth) {
+	if rr.Dependencies == nil {
+		rr.Dependencies = make(map[string]DependencyHealth)
+	}
+	rr.Dependencies[name] = health
+
+	switch {
+	case health.Status == HealthStatusUnhealthy:
+		rr.Status = HealthStatusUnhealthy
+	case health.Status == HealthStatusDegraded && rr.Status != HealthStatusUnhealthy:
+		rr.Status = HealthStatusDegraded
+	}
+}
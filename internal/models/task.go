@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// TaskType identifies the kind of background operation a task tracks
+type TaskType string
+
+const (
+	TaskTypeDeployment   TaskType = "deployment"
+	TaskTypeBackup       TaskType = "backup"
+	TaskTypeRestore      TaskType = "restore"
+	TaskTypeGitHubSync   TaskType = "github_sync"
+	TaskTypeCertificate  TaskType = "certificate"
+	TaskTypeVolume       TaskType = "volume"
+	TaskTypeTemplateTest TaskType = "template_test"
+)
+
+// TaskState represents the lifecycle state of a task
+type TaskState string
+
+const (
+	TaskStatePending   TaskState = "pending"
+	TaskStateRunning   TaskState = "running"
+	TaskStateSucceeded TaskState = "succeeded"
+	TaskStateFailed    TaskState = "failed"
+)
+
+// Task is a background operation shown in the activity feed
+type Task struct {
+	ID         string     `json:"id" db:"id"`
+	Type       TaskType   `json:"type" db:"type"`
+	State      TaskState  `json:"state" db:"state"`
+	Progress   int        `json:"progress" db:"progress"`
+	Message    string     `json:"message" db:"message"`
+	Error      string     `json:"error,omitempty" db:"error"`
+	ResourceID string     `json:"resource_id,omitempty" db:"resource_id"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	StartedAt  *time.Time `json:"started_at" db:"started_at"`
+	FinishedAt *time.Time `json:"finished_at" db:"finished_at"`
+}
+
+// IsFinished returns true once the task has succeeded or failed
+func (t *Task) IsFinished() bool {
+	return t.State == TaskStateSucceeded || t.State == TaskStateFailed
+}
+
+// IsValidTaskState reports whether state is a known task state
+func IsValidTaskState(state string) bool {
+	switch TaskState(state) {
+	case TaskStatePending, TaskStateRunning, TaskStateSucceeded, TaskStateFailed:
+		return true
+	}
+	return false
+}
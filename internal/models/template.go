@@ -3,6 +3,8 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -22,11 +24,24 @@ type Template struct {
 	Variables     []TemplateVariable     `json:"variables" db:"variables"`
 	RequiresNewt  bool                   `json:"requires_newt" db:"requires_newt"`
 	NewtConfig    *TemplateNewtConfig    `json:"newt_config" db:"newt_config"`
+	TunnelProvider TunnelProvider        `json:"tunnel_provider" db:"tunnel_provider"`
 	PublisherID   string                 `json:"publisher_id" db:"publisher_id"`
 	IsVerified    bool                   `json:"is_verified" db:"is_verified"`
-	DownloadCount int                    `json:"download_count" db:"download_count"`
+	VerifiedKeyID string                 `json:"verified_key_id,omitempty" db:"verified_key_id"` // Publisher key that signed the manifest
+	DownloadCount int                    `json:"download_count" db:"download_count"`   // Every deployment
+	UniqueInstalls int                   `json:"unique_installs" db:"unique_installs"` // Distinct users or instances
 	AvgRating     float64                `json:"avg_rating" db:"avg_rating"`
 	TotalRatings  int                    `json:"total_ratings" db:"total_ratings"`
+	SecurityBadge SecurityBadge          `json:"security_badge" db:"security_badge"`
+	TestedVersion string                 `json:"tested_version,omitempty" db:"tested_version"` // Latest version whose test deploy passed
+	VerifiedWorking bool                 `json:"verified_working" db:"-"` // The current version passed its test deploy
+	License       string                 `json:"license,omitempty" db:"license"` // SPDX identifier where known
+	Architectures []string               `json:"architectures,omitempty" db:"architectures"` // Supported by all its images; unknown if empty
+	Compatible    *bool                  `json:"compatible,omitempty" db:"-"` // Runs on the host's architecture, when both are known
+	Resources     *TemplateResources     `json:"resources,omitempty" db:"resources"`
+	BackupHooks   []BackupHook           `json:"backup_hooks,omitempty" db:"backup_hooks"` // Dump databases rather than copying their files
+	Hooks         []LifecycleHook        `json:"hooks,omitempty" db:"lifecycle_hooks"`
+	ConfigFiles   []ConfigFile           `json:"config_files,omitempty" db:"config_files"`
 	CreatedAt     time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time              `json:"updated_at" db:"updated_at"`
 }
@@ -58,6 +73,53 @@ type TemplateVariableValidation struct {
 	Max       *int    `json:"max,omitempty"`
 }
 
+// VariableError describes an invalid value submitted for a template variable
+type VariableError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// DeployPlan gathers everything needed to render a one-page deploy wizard
+type DeployPlan struct {
+	TemplateID    string              `json:"template_id"`
+	Variables     []TemplateVariable  `json:"variables"`
+	RequiresNewt  bool                `json:"requires_newt"`
+	NewtConfig    *TemplateNewtConfig `json:"newt_config,omitempty"`
+	Images        []PlanImage         `json:"images"`
+	Ports         []PlanPort          `json:"ports"`
+	Volumes       []PlanVolume        `json:"volumes"`
+	DownloadBytes int64               `json:"download_bytes"`
+	Resources     *TemplateResources  `json:"resources,omitempty"`
+	Warnings      []string            `json:"warnings"`
+}
+
+// PlanImage is an image a deployment uses and whether it must be pulled
+type PlanImage struct {
+	Service   string `json:"service"`
+	Image     string `json:"image"`
+	Present   bool   `json:"present"`
+	SizeBytes int64  `json:"size_bytes"` // Local size if present, else compressed download size
+	SizeKnown bool   `json:"size_known"`
+}
+
+// PlanPort is a host port a deployment publishes
+type PlanPort struct {
+	Service       string `json:"service"`
+	HostPort      int    `json:"host_port"`
+	ContainerPort string `json:"container_port"`
+	Protocol      string `json:"protocol"`
+	InUse         bool   `json:"in_use"`
+	UsedBy        string `json:"used_by,omitempty"`
+}
+
+// PlanVolume is a named volume or bind mount a deployment uses
+type PlanVolume struct {
+	Service string `json:"service"`
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Named   bool   `json:"named"`
+}
+
 // TemplateNewtConfig represents newt-specific configuration for a template
 type TemplateNewtConfig struct {
 	AutoInject       bool              `json:"auto_inject"`
@@ -80,13 +142,15 @@ type NewtHealthCheck struct {
 
 // TemplateRating represents a user rating for a template
 type TemplateRating struct {
-	ID         int       `json:"id" db:"id"`
-	TemplateID string    `json:"template_id" db:"template_id"`
-	UserID     *string   `json:"user_id" db:"user_id"` // Nullable for anonymous ratings
-	Rating     int       `json:"rating" db:"rating"`
-	Review     string    `json:"review" db:"review"`
-	CreatedAt  time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+	ID                 int       `json:"id" db:"id"`
+	TemplateID         string    `json:"template_id" db:"template_id"`
+	UserID             *string   `json:"user_id" db:"user_id"` // Nullable for anonymous ratings
+	Rating             int       `json:"rating" db:"rating"`
+	Review             string    `json:"review" db:"review"`
+	HelpfulCount       int       `json:"helpful_count" db:"helpful_count"`
+	VerifiedDeployment bool      `json:"verified_deployment" db:"-"` // The reviewer deployed the template
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // TemplateMetadata represents additional metadata for templates
@@ -99,11 +163,209 @@ type TemplateMetadata struct {
 	Resources     TemplateResources `json:"resources"`
 }
 
-// TemplateResources represents resource requirements for a template
+// TemplateResources represents resource requirements for a template. CPU is a
+// number of cores such as 0.5 or 2; memory and disk are sizes such as 512M or 10GB
 type TemplateResources struct {
-	MinCPU    string `json:"min_cpu"`
-	MinMemory string `json:"min_memory"`
-	MinDisk   string `json:"min_disk"`
+	MinCPU    string       `json:"min_cpu,omitempty"`
+	MinMemory string       `json:"min_memory,omitempty"`
+	MinDisk   string       `json:"min_disk,omitempty"`
+	GPU       *TemplateGPU `json:"gpu,omitempty"`
+}
+
+// TemplateGPU is the GPU requirement of a template, reserved for its services as
+// compose device reservations
+type TemplateGPU struct {
+	Driver       string   `json:"driver"`                 // nvidia unless set
+	Count        int      `json:"count"`                  // 0 reserves all GPUs
+	Capabilities []string `json:"capabilities"`           // [gpu] unless set
+	Services     []string `json:"services,omitempty"`     // All services with an image when empty
+}
+
+// IsZero reports whether no requirement is set
+func (r TemplateResources) IsZero() bool {
+	return r.MinCPU == "" && r.MinMemory == "" && r.MinDisk == "" && r.GPU == nil
+}
+
+// Validate checks that the requirements parse
+func (r TemplateResources) Validate() error {
+	if r.MinCPU != "" {
+		if _, err := r.CPUs(); err != nil {
+			return err
+		}
+	}
+	if r.MinMemory != "" {
+		if _, err := ParseByteSize(r.MinMemory); err != nil {
+			return fmt.Errorf("invalid min_memory: %w", err)
+		}
+	}
+	if r.MinDisk != "" {
+		if _, err := ParseByteSize(r.MinDisk); err != nil {
+			return fmt.Errorf("invalid min_disk: %w", err)
+		}
+	}
+	if r.GPU != nil && r.GPU.Count < 0 {
+		return fmt.Errorf("invalid gpu count %d", r.GPU.Count)
+	}
+	return nil
+}
+
+// CPUs returns the minimum number of cores, 0 when unset
+func (r TemplateResources) CPUs() (float64, error) {
+	if r.MinCPU == "" {
+		return 0, nil
+	}
+	cpus, err := strconv.ParseFloat(strings.TrimSpace(r.MinCPU), 64)
+	if err != nil || cpus < 0 {
+		return 0, fmt.Errorf("invalid min_cpu %q", r.MinCPU)
+	}
+	return cpus, nil
+}
+
+// HostResources are the capacity and capabilities of the Docker host a
+// deployment runs on. A figure that could not be read is 0
+type HostResources struct {
+	CPUs         int           `json:"cpus"`
+	MemoryBytes  int64         `json:"memory_bytes"`
+	FreeDisk     int64         `json:"free_disk_bytes"`
+	Architecture string        `json:"architecture,omitempty"`
+	SwarmActive  bool          `json:"swarm_active"`
+	GPU          GPUCapability `json:"gpu"`
+}
+
+// GPUCapability tells whether containers of the host can be given GPUs
+type GPUCapability struct {
+	Available bool        `json:"available"`         // A GPU runtime is registered with Docker
+	Runtime   string      `json:"runtime,omitempty"` // Such as nvidia
+	Default   bool        `json:"default"`           // The runtime is Docker's default
+	Devices   []GPUDevice `json:"devices"`           // Listed by nvidia-smi when Docker runs locally
+	Checked   bool        `json:"-"`                 // GPU support could be read from Docker
+}
+
+// GPUDevice is a GPU of the host
+type GPUDevice struct {
+	Index       int    `json:"index"`
+	Name        string `json:"name"`
+	MemoryBytes int64  `json:"memory_bytes"`
+}
+
+// Shortfalls describes the requirements the host does not meet. Unknown host
+// figures are not checked
+func (r TemplateResources) Shortfalls(host HostResources) []string {
+	var shortfalls []string
+	if cpus, err := r.CPUs(); err == nil && host.CPUs > 0 && cpus > float64(host.CPUs) {
+		shortfalls = append(shortfalls, fmt.Sprintf("template needs at least %s CPUs, the host has %d", r.MinCPU, host.CPUs))
+	}
+	if memory, err := ParseByteSize(r.MinMemory); err == nil && host.MemoryBytes > 0 && memory > host.MemoryBytes {
+		shortfalls = append(shortfalls, fmt.Sprintf("template needs at least %s of memory, the host has %s", formatBytes(memory), formatBytes(host.MemoryBytes)))
+	}
+	if disk, err := ParseByteSize(r.MinDisk); err == nil && host.FreeDisk > 0 && disk > host.FreeDisk {
+		shortfalls = append(shortfalls, fmt.Sprintf("template needs at least %s of disk, the host has %s free", formatBytes(disk), formatBytes(host.FreeDisk)))
+	}
+	if r.GPU != nil && host.GPU.Checked {
+		switch {
+		case !host.GPU.Available:
+			shortfalls = append(shortfalls, "template needs a GPU, the host has no GPU runtime such as nvidia-container-runtime")
+		case len(host.GPU.Devices) > 0 && r.GPU.Count > len(host.GPU.Devices):
+			shortfalls = append(shortfalls, fmt.Sprintf("template needs %d GPUs, the host has %d", r.GPU.Count, len(host.GPU.Devices)))
+		}
+	}
+	return shortfalls
+}
+
+// byteSizePattern matches sizes such as 512M, 1.5GB or 2Gi
+var byteSizePattern = regexp.MustCompile(`(?i)^\s*([0-9]+(?:\.[0-9]+)?)\s*([kmgt]?)(i?b?)\s*$`)
+
+// ParseByteSize parses a size in bytes with an optional K, M, G or T unit. Units
+// are binary, as in Docker memory limits, with or without a trailing B or iB
+func ParseByteSize(size string) (int64, error) {
+	match := byteSizePattern.FindStringSubmatch(size)
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	switch strings.ToLower(match[2]) {
+	case "k":
+		value *= 1 << 10
+	case "m":
+		value *= 1 << 20
+	case "g":
+		value *= 1 << 30
+	case "t":
+		value *= 1 << 40
+	}
+	return int64(value), nil
+}
+
+// TemplateStats summarizes the finished deployments of a template
+type TemplateStats struct {
+	TemplateID         string          `json:"template_id"`
+	Deployments        int             `json:"deployments"`
+	Succeeded          int             `json:"succeeded"`
+	Failed             int             `json:"failed"`
+	SuccessRate        float64         `json:"success_rate"` // Percentage, 0 without deployments
+	AvgDurationSeconds float64         `json:"avg_duration_seconds"`
+	FailureReasons     []FailureReason `json:"failure_reasons"`
+	LastDeployedAt     *time.Time      `json:"last_deployed_at"`
+	Flaky              bool            `json:"flaky"`
+}
+
+// TemplateMedia is an image of a template's media gallery, such as a screenshot,
+// cached locally by the GitHub sync
+type TemplateMedia struct {
+	Position    int       `json:"position"`
+	Source      string    `json:"source"` // Image URL or repository-relative path in .template.json
+	Caption     string    `json:"caption,omitempty"`
+	URL         string    `json:"url"` // Where the cached copy is served
+	File        string    `json:"-"`
+	ETag        string    `json:"-"` // Blob SHA of a repository image
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	SyncedAt    time.Time `json:"synced_at"`
+}
+
+// TemplateVersion is a released version of a template with its release notes,
+// ingested from the GitHub releases or tags of its repository
+type TemplateVersion struct {
+	Version     string     `json:"version"`
+	TagName     string     `json:"tag_name"`
+	Name        string     `json:"name,omitempty"`
+	Notes       string     `json:"notes,omitempty"` // Markdown
+	URL         string     `json:"url,omitempty"`
+	Prerelease  bool       `json:"prerelease"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	IsCurrent   bool       `json:"is_current"`
+}
+
+// RelatedTemplate is a template suggested alongside another, with the signals
+// behind the suggestion
+type RelatedTemplate struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	Icon          string   `json:"icon"`
+	Category      string   `json:"category"`
+	AvgRating     float64  `json:"avg_rating"`
+	Score         float64  `json:"score"`
+	SharedTags    []string `json:"shared_tags"`
+	SameCategory  bool     `json:"same_category"`
+	CoDeployments int      `json:"co_deployments"` // Projects running both templates
+	SharedUsers   int      `json:"shared_users"`   // Users who rated both templates well
+}
+
+// FailureReason is a distinct deployment failure and how often it occurred
+type FailureReason struct {
+	Reason     string    `json:"reason"`
+	Count      int       `json:"count"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// IsFlaky reports whether enough deployments finished to judge the template and its
+// success rate is below successPercent
+func (s *TemplateStats) IsFlaky(minDeployments, successPercent int) bool {
+	return minDeployments > 0 && s.Deployments >= minDeployments && s.SuccessRate < float64(successPercent)
 }
 
 // Validation errors
@@ -114,6 +376,22 @@ var (
 	ErrTemplateInvalidVariable  = fmt.Errorf("invalid template variable")
 )
 
+// NormalizeTags returns tags lowercased and trimmed, without empty tags or
+// duplicates, in their original order
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
 // MarshalTags converts tags slice to JSON string for database storage
 func (t *Template) MarshalTags() (string, error) {
 	if t.Tags == nil {
@@ -159,6 +437,143 @@ func (t *Template) MarshalNewtConfig() (string, error) {
 	return string(data), err
 }
 
+// ArchitectureMatches reports whether an image platform architecture, such as
+// amd64 or arm/v7, matches another. One without a variant matches all variants
+func ArchitectureMatches(a, b string) bool {
+	if a == b {
+		return true
+	}
+	aBase, aVariant, _ := strings.Cut(a, "/")
+	bBase, bVariant, _ := strings.Cut(b, "/")
+	return aBase == bBase && (aVariant == "" || bVariant == "")
+}
+
+// SupportsArchitecture reports whether the template runs on an architecture.
+// Templates whose architectures are unknown, and unknown hosts, are assumed to
+func (t *Template) SupportsArchitecture(arch string) bool {
+	if t.Architectures == nil || arch == "" {
+		return true
+	}
+	for _, supported := range t.Architectures {
+		if ArchitectureMatches(supported, arch) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkCompatible sets Compatible from the host's architecture, leaving it unset
+// when either side is unknown
+func (t *Template) MarkCompatible(arch string) {
+	if t.Architectures == nil || arch == "" {
+		t.Compatible = nil
+		return
+	}
+	compatible := t.SupportsArchitecture(arch)
+	t.Compatible = &compatible
+}
+
+// MarkVerifiedWorking sets VerifiedWorking from the version that last passed a
+// test deploy
+func (t *Template) MarkVerifiedWorking() {
+	t.VerifiedWorking = t.TestedVersion != "" && t.TestedVersion == t.Version
+}
+
+// MarshalArchitectures converts architectures to a JSON string for database
+// storage, empty when unknown
+func (t *Template) MarshalArchitectures() (string, error) {
+	if t.Architectures == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(t.Architectures)
+	return string(data), err
+}
+
+// UnmarshalArchitectures converts JSON string from database to architectures
+func (t *Template) UnmarshalArchitectures(data string) error {
+	if data == "" || data == "null" {
+		t.Architectures = nil
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &t.Architectures)
+}
+
+// MarshalBackupHooks converts backup hooks to a JSON string for database
+// storage, empty without hooks
+func (t *Template) MarshalBackupHooks() (string, error) {
+	if len(t.BackupHooks) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(t.BackupHooks)
+	return string(data), err
+}
+
+// UnmarshalBackupHooks converts JSON string from database to backup hooks
+func (t *Template) UnmarshalBackupHooks(data string) error {
+	if data == "" || data == "null" {
+		t.BackupHooks = nil
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &t.BackupHooks)
+}
+
+// MarshalHooks converts lifecycle hooks to a JSON string for database storage,
+// empty without hooks
+func (t *Template) MarshalHooks() (string, error) {
+	if len(t.Hooks) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(t.Hooks)
+	return string(data), err
+}
+
+// UnmarshalHooks converts JSON string from database to lifecycle hooks
+func (t *Template) UnmarshalHooks(data string) error {
+	if data == "" || data == "null" {
+		t.Hooks = nil
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &t.Hooks)
+}
+
+// MarshalConfigFiles converts config files to a JSON string for database
+// storage, empty without any
+func (t *Template) MarshalConfigFiles() (string, error) {
+	if len(t.ConfigFiles) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(t.ConfigFiles)
+	return string(data), err
+}
+
+// UnmarshalConfigFiles converts JSON string from database to config files
+func (t *Template) UnmarshalConfigFiles(data string) error {
+	if data == "" || data == "null" {
+		t.ConfigFiles = nil
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &t.ConfigFiles)
+}
+
+// MarshalResources converts resource requirements to a JSON string for database
+// storage, empty without requirements
+func (t *Template) MarshalResources() (string, error) {
+	if t.Resources == nil || t.Resources.IsZero() {
+		return "", nil
+	}
+	data, err := json.Marshal(t.Resources)
+	return string(data), err
+}
+
+// UnmarshalResources converts JSON string from database to resource requirements
+func (t *Template) UnmarshalResources(data string) error {
+	if data == "" || data == "null" {
+		t.Resources = nil
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &t.Resources)
+}
+
 // UnmarshalNewtConfig converts JSON string from database to newt config
 func (t *Template) UnmarshalNewtConfig(data string) error {
 	if data == "" || data == "null" {
@@ -212,6 +627,88 @@ func (v *TemplateVariable) Validate() error {
 		return fmt.Errorf("select type variables must have options")
 	}
 
+	if v.Validation != nil && v.Validation.Pattern != nil {
+		if _, err := regexp.Compile(*v.Validation.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern for %s: %v", v.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateEnvironment checks submitted values against the template variables, filling
+// in defaults for omitted ones, and returns one error per invalid field
+func (t *Template) ValidateEnvironment(env map[string]string) []VariableError {
+	var errs []VariableError
+	for _, variable := range t.Variables {
+		value, set := env[variable.Name]
+		if !set || value == "" {
+			if variable.DefaultValue != "" {
+				env[variable.Name] = variable.DefaultValue
+				continue
+			}
+			if variable.Required {
+				errs = append(errs, VariableError{Field: variable.Name, Message: "is required"})
+			}
+			continue
+		}
+
+		if err := variable.ValidateValue(value); err != nil {
+			errs = append(errs, VariableError{Field: variable.Name, Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// ValidateValue checks a value against the variable type, options and validation rules
+func (v *TemplateVariable) ValidateValue(value string) error {
+	switch v.Type {
+	case "number":
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be a whole number")
+		}
+		if v.Validation != nil && v.Validation.Min != nil && number < *v.Validation.Min {
+			return fmt.Errorf("must be at least %d", *v.Validation.Min)
+		}
+		if v.Validation != nil && v.Validation.Max != nil && number > *v.Validation.Max {
+			return fmt.Errorf("must be at most %d", *v.Validation.Max)
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+	case "select":
+		valid := false
+		for _, option := range v.Options {
+			if option.Value == value {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("must be one of the listed options")
+		}
+	}
+
+	if v.Validation == nil {
+		return nil
+	}
+	if v.Validation.MinLength != nil && len(value) < *v.Validation.MinLength {
+		return fmt.Errorf("must be at least %d characters", *v.Validation.MinLength)
+	}
+	if v.Validation.MaxLength != nil && len(value) > *v.Validation.MaxLength {
+		return fmt.Errorf("must be at most %d characters", *v.Validation.MaxLength)
+	}
+	if v.Validation.Pattern != nil {
+		pattern, err := regexp.Compile(*v.Validation.Pattern)
+		if err != nil {
+			return fmt.Errorf("has an invalid validation pattern")
+		}
+		if !pattern.MatchString(value) {
+			return fmt.Errorf("does not match the required format")
+		}
+	}
 	return nil
 }
 
@@ -273,9 +770,13 @@ func (t *Template) IncrementDownloadCount() {
 	t.UpdatedAt = time.Now()
 }
 
-// IsPopular returns true if the template is considered popular
+// popularInstalls is the number of distinct installers making a template popular
+const popularInstalls = 25
+
+// IsPopular returns true if the template is considered popular. Installs count
+// each installer once, so redeploying does not make a template popular
 func (t *Template) IsPopular() bool {
-	return t.DownloadCount >= 100 || (t.AvgRating >= 4.0 && t.TotalRatings >= 10)
+	return t.UniqueInstalls >= popularInstalls || (t.AvgRating >= 4.0 && t.TotalRatings >= 10)
 }
 
 // GetFullRepoPath returns the complete path to the template in the repository
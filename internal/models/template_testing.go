@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// TemplateTestStatus is the outcome of a template test
+type TemplateTestStatus string
+
+const (
+	TemplateTestRunning TemplateTestStatus = "running"
+	TemplateTestPassed  TemplateTestStatus = "passed" // Every service came up running and healthy
+	TemplateTestFailed  TemplateTestStatus = "failed"
+)
+
+// TemplateTestStackPrefix starts the stack names of template tests, keeping
+// them apart from deployments
+const TemplateTestStackPrefix = "tpltest-"
+
+// TemplateTest is a test deploy of one version of a template into a throwaway
+// stack, with generated values for its variables
+type TemplateTest struct {
+	ID          int64              `json:"id" db:"id"`
+	TemplateID  string             `json:"template_id" db:"template_id"`
+	Version     string             `json:"version" db:"version"`
+	StackName   string             `json:"stack_name" db:"stack_name"`
+	Status      TemplateTestStatus `json:"status" db:"status"`
+	Error       string             `json:"error,omitempty" db:"error"`
+	Logs        string             `json:"logs,omitempty" db:"logs"` // Tail of the stack's logs before teardown
+	DurationMs  int64              `json:"duration_ms" db:"duration_ms"`
+	RequestedBy string             `json:"requested_by,omitempty" db:"requested_by"`
+	StartedAt   time.Time          `json:"started_at" db:"started_at"`
+	FinishedAt  *time.Time         `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+var (
+	ErrTemplateTestRunning = fmt.Errorf("a test of this template is already running")
+)
+
+// Finish records the outcome of a test, failed when err is not nil
+func (t *TemplateTest) Finish(err error) {
+	now := time.Now()
+	t.FinishedAt = &now
+	t.DurationMs = now.Sub(t.StartedAt).Milliseconds()
+	t.Status = TemplateTestPassed
+	if err != nil {
+		t.Status = TemplateTestFailed
+		t.Error = err.Error()
+	}
+}
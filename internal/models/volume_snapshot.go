@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// VolumeSnapshot is an archive of a single stack volume, taken on demand as a
+// quick safety net before a risky change. Unlike a backup it holds nothing but
+// the volume data
+type VolumeSnapshot struct {
+	ID           string    `json:"id" db:"id"`
+	DeploymentID string    `json:"deployment_id" db:"deployment_id"`
+	VolumeName   string    `json:"volume_name" db:"volume_name"`
+	Note         string    `json:"note" db:"note"`
+	SizeBytes    int64     `json:"size_bytes" db:"size_bytes"`
+	StoragePath  string    `json:"-" db:"storage_path"`
+	CreatedBy    string    `json:"created_by" db:"created_by"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// VolumeSnapshotRequest takes a snapshot of a volume
+type VolumeSnapshotRequest struct {
+	Note string `json:"note"`
+}
+
+// VolumeCloneRequest copies a volume into a new volume
+type VolumeCloneRequest struct {
+	Name string `json:"name"` // Name of the new volume
+}
+
+// maxSnapshotNoteLength bounds the note of a snapshot
+const maxSnapshotNoteLength = 500
+
+// volumeNamePattern matches the volume names Docker accepts
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,254}$`)
+
+var (
+	ErrVolumeSnapshotNotFound = fmt.Errorf("volume snapshot not found")
+	ErrSnapshotNoteTooLong    = fmt.Errorf("note must be at most %d characters", maxSnapshotNoteLength)
+	ErrInvalidVolumeName      = fmt.Errorf("volume name must start with a letter or digit and contain only letters, digits, '_', '.' and '-'")
+)
+
+// Validate validates a snapshot request
+func (r *VolumeSnapshotRequest) Validate() error {
+	if len(r.Note) > maxSnapshotNoteLength {
+		return ErrSnapshotNoteTooLong
+	}
+	return nil
+}
+
+// Validate validates a clone request
+func (r *VolumeCloneRequest) Validate() error {
+	if !volumeNamePattern.MatchString(r.Name) {
+		return ErrInvalidVolumeName
+	}
+	return nil
+}
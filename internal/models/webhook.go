@@ -0,0 +1,299 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WebhookEvent is a lifecycle event that can be delivered to webhooks
+type WebhookEvent string
+
+const (
+	WebhookEventDeploymentCreated   WebhookEvent = "deployment.created"
+	WebhookEventDeploymentFailed    WebhookEvent = "deployment.failed"
+	WebhookEventStackUnhealthy      WebhookEvent = "stack.unhealthy"
+	WebhookEventStackRestartLoop    WebhookEvent = "stack.restart_loop"
+	WebhookEventBackupCompleted     WebhookEvent = "backup.completed"
+	WebhookEventTemplateUpdated     WebhookEvent = "template.updated"
+	WebhookEventCertificateExpiring WebhookEvent = "certificate.expiring"
+	WebhookEventCertificateFailed   WebhookEvent = "certificate.failed"
+	WebhookEventCronJobFailed       WebhookEvent = "cronjob.failed"
+	WebhookEventProbeDown           WebhookEvent = "probe.down"
+	WebhookEventProbeRecovered      WebhookEvent = "probe.recovered"
+	WebhookEventStorageThreshold    WebhookEvent = "storage.threshold"
+	WebhookEventPing                WebhookEvent = "ping"
+	WebhookEventDigest              WebhookEvent = "digest" // Groups simultaneous events of one webhook
+)
+
+// WebhookEvents lists the events webhooks can subscribe to
+var WebhookEvents = []WebhookEvent{
+	WebhookEventDeploymentCreated,
+	WebhookEventDeploymentFailed,
+	WebhookEventStackUnhealthy,
+	WebhookEventStackRestartLoop,
+	WebhookEventBackupCompleted,
+	WebhookEventTemplateUpdated,
+	WebhookEventCertificateExpiring,
+	WebhookEventCertificateFailed,
+	WebhookEventCronJobFailed,
+	WebhookEventProbeDown,
+	WebhookEventProbeRecovered,
+	WebhookEventStorageThreshold,
+}
+
+// webhookAlerts are the events reporting a problem. Alerts of the same subject are
+// de-duplicated, and recovery events resolve them
+var webhookAlerts = map[WebhookEvent]bool{
+	WebhookEventDeploymentFailed:    true,
+	WebhookEventStackUnhealthy:      true,
+	WebhookEventStackRestartLoop:    true,
+	WebhookEventCertificateExpiring: true,
+	WebhookEventCertificateFailed:   true,
+	WebhookEventCronJobFailed:       true,
+	WebhookEventProbeDown:           true,
+	WebhookEventStorageThreshold:    true,
+}
+
+// webhookRecoveries maps recovery events to the alert they resolve
+var webhookRecoveries = map[WebhookEvent]WebhookEvent{
+	WebhookEventProbeRecovered: WebhookEventProbeDown,
+}
+
+// IsAlert reports whether the event reports a problem
+func (e WebhookEvent) IsAlert() bool {
+	return webhookAlerts[e]
+}
+
+// Recovers returns the alert the event resolves, if it is a recovery event
+func (e WebhookEvent) Recovers() (WebhookEvent, bool) {
+	alert, ok := webhookRecoveries[e]
+	return alert, ok
+}
+
+// CanRecover reports whether the problem of an alert may resolve itself: a
+// container becoming healthy again, or a probe answering again. Such alerts are
+// held for the flap window before they are sent
+func (e WebhookEvent) CanRecover() bool {
+	return e == WebhookEventStackUnhealthy || e == WebhookEventProbeDown
+}
+
+// WebhookDeliveryStatus represents the state of a webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// Webhook is a user-configured URL that receives signed event notifications
+type Webhook struct {
+	ID          int            `json:"id" db:"id"`
+	URL         string         `json:"url" db:"url"`
+	Secret      string         `json:"secret,omitempty" db:"secret"` // HMAC-SHA256 signing key
+	Events      []WebhookEvent `json:"events" db:"events"`
+	Enabled     bool           `json:"enabled" db:"enabled"`
+	QuietHours  *QuietHours    `json:"quiet_hours,omitempty" db:"quiet_hours"`
+	GroupAlerts bool           `json:"group_alerts" db:"group_alerts"` // Send events due together as one digest
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+}
+
+// QuietHours is a daily window during which the deliveries of a webhook are held,
+// to be sent when it ends. A window whose start is after its end spans midnight
+type QuietHours struct {
+	Start    string `json:"start"`              // HH:MM
+	End      string `json:"end"`                // HH:MM
+	Timezone string `json:"timezone,omitempty"` // IANA name; UTC unless set
+}
+
+// WebhookDelivery is one event queued for, or delivered to, a webhook
+type WebhookDelivery struct {
+	ID             int64                 `json:"id" db:"id"`
+	WebhookID      int                   `json:"webhook_id" db:"webhook_id"`
+	Event          WebhookEvent          `json:"event" db:"event"`
+	Payload        json.RawMessage       `json:"payload" db:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                   `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at" db:"next_attempt_at"`
+	ResponseStatus int                   `json:"response_status" db:"response_status"`
+	Error          string                `json:"error,omitempty" db:"error"`
+	CreatedAt      time.Time             `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at" db:"delivered_at"`
+}
+
+// Webhook validation errors
+var (
+	ErrWebhookURLInvalid     = fmt.Errorf("webhook URL must be an absolute http or https URL")
+	ErrWebhookURLPrivate     = fmt.Errorf("webhook URL must not point to a private or loopback address")
+	ErrWebhookEventsRequired = fmt.Errorf("at least one webhook event is required")
+	ErrWebhookSecretTooShort = fmt.Errorf("webhook secret must be at least 16 characters")
+	ErrQuietHoursInvalid     = fmt.Errorf("quiet hours must have a distinct start and end as HH:MM")
+	ErrQuietHoursTimezone    = fmt.Errorf("quiet hours timezone is not a known IANA timezone")
+)
+
+// Validate validates the webhook configuration
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrWebhookURLInvalid
+	}
+	if !IsPublicHost(u.Hostname()) {
+		return ErrWebhookURLPrivate
+	}
+	if len(w.Events) == 0 {
+		return ErrWebhookEventsRequired
+	}
+	for _, event := range w.Events {
+		if !IsValidWebhookEvent(event) {
+			return fmt.Errorf("unknown webhook event %q", event)
+		}
+	}
+	if len(w.Secret) < 16 {
+		return ErrWebhookSecretTooShort
+	}
+	if w.QuietHours != nil {
+		return w.QuietHours.Validate()
+	}
+	return nil
+}
+
+// IsPublicHost reports whether a host name or address may be public. Names other
+// than localhost are accepted, as they are only resolved when connecting
+func IsPublicHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return IsPublicIP(ip)
+	}
+	return true
+}
+
+// IsPublicIP reports whether an address is neither private, loopback nor link-local
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast())
+}
+
+// Validate validates the quiet hours
+func (q *QuietHours) Validate() error {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return ErrQuietHoursInvalid
+	}
+	end, err := parseClock(q.End)
+	if err != nil || start == end {
+		return ErrQuietHoursInvalid
+	}
+	if _, err := time.LoadLocation(q.Timezone); err != nil {
+		return ErrQuietHoursTimezone
+	}
+	return nil
+}
+
+// Until returns the end of the quiet hours t falls in, and false when t is
+// outside them
+func (q *QuietHours) Until(t time.Time) (time.Time, bool) {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	inside := start <= minute && minute < end
+	if start > end {
+		inside = minute >= start || minute < end
+	}
+	if !inside {
+		return time.Time{}, false
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(local) {
+		until = time.Date(local.Year(), local.Month(), local.Day()+1, end/60, end%60, 0, 0, loc)
+	}
+	return until, true
+}
+
+// parseClock parses HH:MM into minutes after midnight
+func parseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// Subscribes reports whether the webhook receives event. Every webhook receives pings
+func (w *Webhook) Subscribes(event WebhookEvent) bool {
+	if event == WebhookEventPing {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalEvents converts events to JSON string for database storage
+func (w *Webhook) MarshalEvents() (string, error) {
+	data, err := json.Marshal(w.Events)
+	return string(data), err
+}
+
+// UnmarshalEvents converts JSON string from database to events
+func (w *Webhook) UnmarshalEvents(data string) error {
+	if data == "" {
+		w.Events = []WebhookEvent{}
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &w.Events)
+}
+
+// MarshalQuietHours converts quiet hours to JSON string for database storage;
+// none are stored as an empty string
+func (w *Webhook) MarshalQuietHours() (string, error) {
+	if w.QuietHours == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(w.QuietHours)
+	return string(data), err
+}
+
+// UnmarshalQuietHours converts JSON string from database to quiet hours
+func UnmarshalQuietHours(data string) *QuietHours {
+	if data == "" {
+		return nil
+	}
+	var quiet QuietHours
+	if err := json.Unmarshal([]byte(data), &quiet); err != nil {
+		return nil
+	}
+	return &quiet
+}
+
+// IsValidWebhookEvent reports whether event is one webhooks can subscribe to
+func IsValidWebhookEvent(event WebhookEvent) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
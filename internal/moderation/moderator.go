@@ -0,0 +1,286 @@
+package moderation
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://|\bwww\.`)
+
+// Moderator checks reviews and template descriptions against the content policy
+// and keeps the queue of those that break it
+type Moderator struct {
+	db     *sql.DB
+	policy config.ContentPolicyConfig
+	hold   bool           // Hide queued reviews until approved
+	banned *regexp.Regexp // nil without banned words
+}
+
+// NewModerator creates a new moderator for the marketplace content policy
+func NewModerator(db *sql.DB, cfg config.MarketplaceConfig) *Moderator {
+	m := &Moderator{db: db, policy: cfg.ContentPolicy, hold: cfg.ReviewModeration}
+
+	var words []string
+	for _, word := range cfg.ContentPolicy.BannedWords {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, regexp.QuoteMeta(word))
+		}
+	}
+	if len(words) > 0 {
+		m.banned = regexp.MustCompile(`(?i)\b(` + strings.Join(words, "|") + `)\b`)
+	}
+	return m
+}
+
+// Check returns the policy rules a text breaks, none when the policy is disabled
+func (m *Moderator) Check(contentType models.ModerationContentType, text string) []models.PolicyViolation {
+	if !m.policy.Enabled || text == "" {
+		return nil
+	}
+
+	var violations []models.PolicyViolation
+	if m.banned != nil {
+		seen := make(map[string]bool)
+		for _, word := range m.banned.FindAllString(text, -1) {
+			if word = strings.ToLower(word); !seen[word] {
+				seen[word] = true
+				violations = append(violations, models.PolicyViolation{Rule: "banned-word", Detail: word})
+			}
+		}
+	}
+
+	if links := len(linkPattern.FindAllString(text, -1)); m.policy.MaxLinks > 0 && links > m.policy.MaxLinks {
+		violations = append(violations, models.PolicyViolation{
+			Rule:   "too-many-links",
+			Detail: fmt.Sprintf("%d links, at most %d allowed", links, m.policy.MaxLinks),
+		})
+	}
+
+	maxLength := m.policy.MaxReviewLength
+	if contentType == models.ModerationTemplateDescription {
+		maxLength = m.policy.MaxDescriptionLength
+	}
+	if length := utf8.RuneCountInString(text); maxLength > 0 && length > maxLength {
+		violations = append(violations, models.PolicyViolation{
+			Rule:   "too-long",
+			Detail: fmt.Sprintf("%d characters, at most %d allowed", length, maxLength),
+		})
+	}
+
+	return violations
+}
+
+// ReviewStatus checks a submitted review, returning the moderation status to
+// store it with and the rules it breaks. A review breaking none is approved
+func (m *Moderator) ReviewStatus(review string) (models.ModerationStatus, []models.PolicyViolation) {
+	violations := m.Check(models.ModerationReview, review)
+	if len(violations) > 0 && m.hold {
+		return models.ModerationPending, violations
+	}
+	return models.ModerationApproved, violations
+}
+
+// Enqueue adds a text breaking the policy to the moderation queue. Text already
+// queued for the same template and author is not queued again, and its decision
+// is returned
+func (m *Moderator) Enqueue(contentType models.ModerationContentType, templateID, authorID, content string, violations []models.PolicyViolation) (models.ModerationStatus, error) {
+	violationsJSON, _ := json.Marshal(violations)
+	hash := contentHash(content)
+
+	_, err := m.db.Exec(`
+		INSERT INTO moderation_queue (content_type, template_id, author_id, content, content_hash, violations, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (content_type, template_id, author_id, content_hash) DO NOTHING`,
+		contentType, templateID, authorID, content, hash, string(violationsJSON), models.ModerationPending, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	var status models.ModerationStatus
+	err = m.db.QueryRow(`
+		SELECT status FROM moderation_queue
+		WHERE content_type = $1 AND template_id = $2 AND author_id = $3 AND content_hash = $4`,
+		contentType, templateID, authorID, hash).Scan(&status)
+	return status, err
+}
+
+// ScanTemplates checks the descriptions of all templates, run after a GitHub
+// sync. Descriptions are published while queued; one a moderator rejected
+// before is cleared again, since the sync restores it
+func (m *Moderator) ScanTemplates() {
+	if !m.policy.Enabled {
+		return
+	}
+
+	rows, err := m.db.Query("SELECT id, COALESCE(description, '') FROM templates WHERE description != ''")
+	if err != nil {
+		slog.Error("Failed to load template descriptions for moderation", "error", err)
+		return
+	}
+	type description struct{ templateID, text string }
+	var descriptions []description
+	for rows.Next() {
+		var d description
+		if err := rows.Scan(&d.templateID, &d.text); err == nil {
+			descriptions = append(descriptions, d)
+		}
+	}
+	rows.Close()
+
+	flagged := 0
+	for _, d := range descriptions {
+		violations := m.Check(models.ModerationTemplateDescription, d.text)
+		if len(violations) == 0 {
+			continue
+		}
+		flagged++
+
+		status, err := m.Enqueue(models.ModerationTemplateDescription, d.templateID, "", d.text, violations)
+		if err != nil {
+			slog.Error("Failed to queue template description for moderation", "template_id", d.templateID, "error", err)
+			continue
+		}
+		if status == models.ModerationRejected {
+			if err := clearDescription(m.db, d.templateID, d.text); err != nil {
+				slog.Error("Failed to clear rejected template description", "template_id", d.templateID, "error", err)
+			}
+		}
+	}
+
+	if flagged > 0 {
+		slog.Info("Template descriptions breaking the content policy", "count", flagged)
+	}
+}
+
+// List returns the queued items with a status, oldest first, or all items when
+// status is empty
+func (m *Moderator) List(status models.ModerationStatus, limit int) ([]models.ModerationItem, error) {
+	query := `
+		SELECT id, content_type, template_id, author_id, content, content_hash, violations, status,
+		       COALESCE(reviewed_by, ''), reviewed_at, created_at
+		FROM moderation_queue`
+	var args []interface{}
+	if status != "" {
+		query += " WHERE status = $1"
+		args = append(args, status)
+	}
+	query += fmt.Sprintf(" ORDER BY created_at LIMIT %d", limit)
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.ModerationItem{}
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+// Decide approves or rejects a pending item. Rejecting a review hides it and
+// rejecting a description clears it; approving a held review publishes it. The
+// review or description is only changed while it still has the queued text
+func (m *Moderator) Decide(id int, approve bool, moderatorID string) (*models.ModerationItem, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	item, err := scanItem(tx.QueryRow(`
+		SELECT id, content_type, template_id, author_id, content, content_hash, violations, status,
+		       COALESCE(reviewed_by, ''), reviewed_at, created_at
+		FROM moderation_queue WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, models.ErrModerationItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if item.Status != models.ModerationPending {
+		return nil, models.ErrModerationDecided
+	}
+
+	item.Status = models.ModerationRejected
+	if approve {
+		item.Status = models.ModerationApproved
+	}
+	now := time.Now()
+	item.ReviewedBy, item.ReviewedAt = moderatorID, &now
+
+	if _, err := tx.Exec("UPDATE moderation_queue SET status = $1, reviewed_by = $2, reviewed_at = $3 WHERE id = $4",
+		item.Status, moderatorID, now, id); err != nil {
+		return nil, err
+	}
+
+	switch item.ContentType {
+	case models.ModerationReview:
+		_, err = tx.Exec(`
+			UPDATE template_ratings SET moderation_status = $1
+			WHERE template_id = $2 AND user_id = $3 AND review = $4`,
+			item.Status, item.TemplateID, item.AuthorID, item.Content)
+	case models.ModerationTemplateDescription:
+		if !approve {
+			err = clearDescription(tx, item.TemplateID, item.Content)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return item, tx.Commit()
+}
+
+// execer is a database or a transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// clearDescription removes a rejected description from a template
+func clearDescription(db execer, templateID, text string) error {
+	_, err := db.Exec("UPDATE templates SET description = '' WHERE id = $1 AND description = $2", templateID, text)
+	return err
+}
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanItem(row rowScanner) (*models.ModerationItem, error) {
+	var item models.ModerationItem
+	var violationsJSON string
+	var reviewedAt sql.NullTime
+	if err := row.Scan(&item.ID, &item.ContentType, &item.TemplateID, &item.AuthorID, &item.Content, &item.ContentHash,
+		&violationsJSON, &item.Status, &item.ReviewedBy, &reviewedAt, &item.CreatedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(violationsJSON), &item.Violations)
+	if reviewedAt.Valid {
+		item.ReviewedAt = &reviewedAt.Time
+	}
+	return &item, nil
+}
+
+// contentHash identifies a text in the queue
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,319 @@
+package newt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"docker-deploy-app/internal/docker"
+	"docker-deploy-app/internal/models"
+)
+
+// logTail is the number of tunnel log lines inspected per status collection
+const logTail = "500"
+
+// StatusCollector reports tunnel status by inspecting the tunnel sidecar of a stack
+type StatusCollector struct {
+	db     *sql.DB
+	client *client.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewStatusCollector creates a new newt status collector
+func NewStatusCollector(db *sql.DB, dockerClient *client.Client) *StatusCollector {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &StatusCollector{
+		db:     db,
+		client: dockerClient,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start begins collecting the tunnel status of every stack with a newt sidecar
+// periodically, so state transitions are recorded without the status being polled
+func (sc *StatusCollector) Start(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	slog.Info("Starting tunnel status monitor", "interval", interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				sc.collectAll()
+			case <-sc.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops periodic status collection
+func (sc *StatusCollector) Stop() {
+	sc.cancel()
+}
+
+// collectAll collects the status of every deployment with a newt sidecar
+func (sc *StatusCollector) collectAll() {
+	rows, err := sc.db.Query("SELECT id, stack_name FROM deployments WHERE newt_injected = 1")
+	if err != nil {
+		slog.Error("Failed to load deployments for tunnel status", "error", err)
+		return
+	}
+
+	var deployments []models.Deployment
+	for rows.Next() {
+		var d models.Deployment
+		if err := rows.Scan(&d.ID, &d.StackName); err != nil {
+			slog.Error("Failed to load deployments for tunnel status", "error", err)
+			rows.Close()
+			return
+		}
+		deployments = append(deployments, d)
+	}
+	rows.Close()
+
+	for _, d := range deployments {
+		if sc.ctx.Err() != nil {
+			return
+		}
+		if _, err := sc.Collect(d.ID, d.StackName); err != nil {
+			slog.Warn("Failed to collect tunnel status", "stack", d.StackName, "error", err)
+		}
+	}
+}
+
+// Collect gathers the tunnel status of a stack and records state transitions
+func (sc *StatusCollector) Collect(deploymentID, stackName string) (*models.NewtStatus, error) {
+	ctx := context.Background()
+	status := &models.NewtStatus{
+		ServiceName: "newt",
+		Status:      "not_found",
+		Health:      "unknown",
+		UpdatedAt:   time.Now(),
+	}
+
+	container, err := sc.findContainer(ctx, stackName)
+	if err != nil {
+		return nil, err
+	}
+	if container == nil {
+		sc.recordTransition(deploymentID, status)
+		return status, nil
+	}
+
+	status.ContainerID = container.ID
+	status.ServiceName = container.Labels["com.docker.compose.service"]
+
+	inspect, err := sc.client.ContainerInspect(ctx, container.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect newt container: %w", err)
+	}
+	status.Status = inspect.State.Status
+	if inspect.State.Health != nil {
+		// The healthcheck tests the health file newt writes once the tunnel is up
+		status.Health = inspect.State.Health.Status
+	} else if inspect.State.Running {
+		status.Health = "running"
+	}
+
+	if inspect.State.Running {
+		probe := docker.ProbeFor(container.Labels["app.name"])
+		if err := sc.readLogs(ctx, container.ID, probe, status); err != nil {
+			status.LastError = err.Error()
+		}
+		sc.readTraffic(ctx, container.ID, status)
+	}
+
+	// Without a healthcheck, a connect log line since the last disconnect is the best signal
+	if inspect.State.Health != nil {
+		status.TunnelActive = inspect.State.Running && status.Health == "healthy"
+	} else {
+		status.TunnelActive = inspect.State.Running && status.ConnectedAt != nil
+	}
+
+	sc.recordTransition(deploymentID, status)
+	return status, nil
+}
+
+// GetEvents returns the recorded tunnel state transitions of a deployment
+func (sc *StatusCollector) GetEvents(deploymentID string, limit int) ([]models.NewtTunnelEvent, error) {
+	rows, err := sc.db.Query(`
+		SELECT id, deployment_id, previous_state, state, message, created_at
+		FROM newt_tunnel_events
+		WHERE deployment_id = $1
+		ORDER BY created_at DESC LIMIT $2`, deploymentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.NewtTunnelEvent
+	for rows.Next() {
+		var event models.NewtTunnelEvent
+		var previous, message sql.NullString
+		if err := rows.Scan(&event.ID, &event.DeploymentID, &previous, &event.State, &message, &event.CreatedAt); err != nil {
+			continue
+		}
+		event.PreviousState = previous.String
+		event.Message = message.String
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// findContainer returns the newt container of a stack, or nil if there is none
+func (sc *StatusCollector) findContainer(ctx context.Context, stackName string) (*types.Container, error) {
+	containers, err := sc.client.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", "com.docker.compose.project="+stackName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stack containers: %w", err)
+	}
+
+	for i, container := range containers {
+		if container.Labels["app.type"] == "tunnel" || container.Labels["com.docker.compose.service"] == "newt" {
+			return &containers[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// readLogs scans recent tunnel logs for connection, ping and error lines
+func (sc *StatusCollector) readLogs(ctx context.Context, containerID string, probe docker.TunnelProbe, status *models.NewtStatus) error {
+	reader, err := sc.client.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Tail:       logTail,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read newt logs: %w", err)
+	}
+	defer reader.Close()
+
+	// Newt runs without a TTY, so stdout and stderr are multiplexed
+	var output bytes.Buffer
+	if _, err := stdcopy.StdCopy(&output, &output, reader); err != nil {
+		return fmt.Errorf("failed to read newt logs: %w", err)
+	}
+
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		timestamp, message := splitLogLine(scanner.Text())
+		lower := strings.ToLower(message)
+
+		switch {
+		case strings.Contains(lower, "error") || strings.Contains(lower, "failed"):
+			status.ErrorCount++
+			status.LastError = message
+		case containsAny(lower, probe.DisconnectedMarkers):
+			status.ConnectedAt = nil
+		case containsAny(lower, probe.ConnectedMarkers):
+			status.ConnectedAt = timestamp
+		}
+
+		if strings.Contains(lower, "ping") || strings.Contains(lower, "pong") {
+			status.LastPing = timestamp
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readTraffic fills byte counters from the container network statistics
+func (sc *StatusCollector) readTraffic(ctx context.Context, containerID string, status *models.NewtStatus) {
+	stats, err := sc.client.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return
+	}
+	defer stats.Body.Close()
+
+	var containerStats types.StatsJSON
+	if err := json.NewDecoder(stats.Body).Decode(&containerStats); err != nil {
+		return
+	}
+
+	for _, network := range containerStats.Networks {
+		status.BytesIn += int64(network.RxBytes)
+		status.BytesOut += int64(network.TxBytes)
+	}
+}
+
+// recordTransition stores a tunnel event when the state differs from the last recorded one
+func (sc *StatusCollector) recordTransition(deploymentID string, status *models.NewtStatus) {
+	state := tunnelState(status)
+
+	var previous string
+	err := sc.db.QueryRow(`
+		SELECT state FROM newt_tunnel_events
+		WHERE deployment_id = $1
+		ORDER BY created_at DESC LIMIT 1`, deploymentID).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return
+	}
+	if previous == state {
+		return
+	}
+
+	sc.db.Exec(`
+		INSERT INTO newt_tunnel_events (deployment_id, previous_state, state, message, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		deploymentID, previous, state, status.LastError, time.Now())
+}
+
+// tunnelState reduces a status to the state tracked for transitions
+func tunnelState(status *models.NewtStatus) string {
+	switch {
+	case status.ContainerID == "":
+		return models.TunnelStateMissing
+	case status.TunnelActive:
+		return models.TunnelStateConnected
+	case status.Status == "running":
+		return models.TunnelStateDisconnected
+	default:
+		return models.TunnelStateStopped
+	}
+}
+
+// splitLogLine separates the Docker timestamp prefix from a log line
+func splitLogLine(line string) (*time.Time, string) {
+	parts := strings.SplitN(line, " ", 2)
+	if len(parts) != 2 {
+		return nil, line
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, line
+	}
+	return &timestamp, parts[1]
+}
+
+// containsAny returns true if s contains any of the markers
+func containsAny(s string, markers []string) bool {
+	for _, marker := range markers {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package newt
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"docker-deploy-app/internal/models"
+)
+
+const (
+	// tokenPath is the Pangolin endpoint Newt clients exchange their ID and secret at
+	tokenPath = "/api/v1/auth/newt/get-token"
+	// websocketPath is the Pangolin endpoint Newt keeps its control connection on
+	websocketPath = "/api/v1/ws"
+	// certExpiryWarningDays is how close to expiry a certificate is reported as an issue
+	certExpiryWarningDays = 14
+)
+
+// Validator checks Newt configurations against a live Pangolin endpoint
+type Validator struct {
+	client   *http.Client
+	insecure *http.Client
+}
+
+// NewValidator creates a new Newt validator
+func NewValidator(timeout time.Duration) *Validator {
+	return &Validator{
+		client: &http.Client{Timeout: timeout},
+		// Only used to describe a certificate that failed verification
+		insecure: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		},
+	}
+}
+
+// tokenResponse is the body Pangolin returns from the Newt token endpoint
+type tokenResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+	Data    struct {
+		Token string `json:"token"`
+	} `json:"data"`
+}
+
+// Validate tests reachability, TLS and authentication of a Newt configuration
+func (v *Validator) Validate(config *models.NewtConfig) *models.NewtValidationResult {
+	result := &models.NewtValidationResult{
+		Issues:   []string{},
+		Features: []string{},
+		TestedAt: time.Now(),
+	}
+
+	if err := config.Validate(); err != nil {
+		result.Issues = append(result.Issues, err.Error())
+		return result
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		result.Issues = append(result.Issues, "endpoint must be an absolute http(s) URL")
+		return result
+	}
+	if endpoint.Scheme == "http" {
+		result.Issues = append(result.Issues, "endpoint does not use TLS; the Newt secret is sent in clear text")
+	}
+
+	v.testEndpointConnection(endpoint, result)
+	if !result.Reachable {
+		return result
+	}
+	// The Newt ID and secret are never sent to a server whose certificate
+	// failed verification, as anyone could be presenting it
+	if result.TLS != nil && !result.TLS.Verified {
+		result.Issues = append(result.Issues, "authentication and feature discovery skipped: the certificate is not trusted")
+		return result
+	}
+
+	v.testAuthentication(endpoint, config, result)
+	if result.Authenticated {
+		v.discoverFeatures(endpoint, result)
+	}
+
+	result.Valid = result.Reachable && result.Authenticated && (result.TLS == nil || result.TLS.Verified)
+	return result
+}
+
+// testEndpointConnection checks that the endpoint answers HTTP and records its TLS certificate
+func (v *Validator) testEndpointConnection(endpoint *url.URL, result *models.NewtValidationResult) {
+	start := time.Now()
+	resp, err := v.client.Get(endpoint.String())
+	test := models.NewtConnectionTest{
+		TestType:     "endpoint",
+		ResponseTime: time.Since(start),
+		TestedAt:     time.Now(),
+	}
+
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	if err != nil && (errors.As(err, &certErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidCert)) {
+		// The server is up but its certificate is not trusted; fetch it for the report
+		if insecureResp, insecureErr := v.insecure.Get(endpoint.String()); insecureErr == nil {
+			result.TLS = describeTLS(insecureResp.TLS)
+			result.TLS.Error = err.Error()
+			insecureResp.Body.Close()
+			result.Reachable = true
+		}
+		result.Issues = append(result.Issues, fmt.Sprintf("TLS certificate verification failed: %v", err))
+		test.Message = "certificate verification failed"
+		result.Tests = append(result.Tests, test)
+		return
+	}
+
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("endpoint unreachable: %v", err))
+		test.Message = err.Error()
+		result.Tests = append(result.Tests, test)
+		return
+	}
+	defer resp.Body.Close()
+
+	result.Reachable = true
+	test.Success = true
+	test.Message = resp.Status
+	result.Tests = append(result.Tests, test)
+
+	if resp.TLS != nil {
+		result.TLS = describeTLS(resp.TLS)
+		result.TLS.Verified = true
+		if result.TLS.DaysRemaining < certExpiryWarningDays {
+			result.Issues = append(result.Issues, fmt.Sprintf("TLS certificate expires in %d days", result.TLS.DaysRemaining))
+		}
+	}
+}
+
+// testAuthentication exchanges the Newt ID and secret for a token
+func (v *Validator) testAuthentication(endpoint *url.URL, config *models.NewtConfig, result *models.NewtValidationResult) {
+	body, _ := json.Marshal(map[string]string{
+		"newtId": config.NewtID,
+		"secret": config.Secret,
+	})
+
+	start := time.Now()
+	resp, err := v.client.Post(endpoint.String()+tokenPath, "application/json", bytes.NewReader(body))
+	test := models.NewtConnectionTest{
+		TestType:     "authentication",
+		ResponseTime: time.Since(start),
+		TestedAt:     time.Now(),
+	}
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("authentication request failed: %v", err))
+		test.Message = err.Error()
+		result.Tests = append(result.Tests, test)
+		return
+	}
+	defer resp.Body.Close()
+
+	if version := resp.Header.Get("X-Pangolin-Version"); version != "" {
+		result.Version = version
+	}
+
+	var token tokenResponse
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(data, &token); err != nil && resp.StatusCode == http.StatusOK {
+		result.Issues = append(result.Issues, "endpoint did not return a Pangolin API response")
+		test.Message = "unexpected response body"
+		result.Tests = append(result.Tests, test)
+		return
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK && token.Success && token.Data.Token != "":
+		result.Authenticated = true
+		test.Success = true
+		test.Message = "token issued"
+	case resp.StatusCode == http.StatusNotFound:
+		result.Issues = append(result.Issues, "endpoint does not expose the Newt API; is this a Pangolin server?")
+		test.Message = resp.Status
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || !token.Success:
+		result.Issues = append(result.Issues, "Newt ID or secret was rejected")
+		test.Message = token.Message
+		if test.Message == "" {
+			test.Message = resp.Status
+		}
+	default:
+		result.Issues = append(result.Issues, fmt.Sprintf("authentication returned %s", resp.Status))
+		test.Message = resp.Status
+	}
+
+	result.Tests = append(result.Tests, test)
+}
+
+// discoverFeatures records the capabilities the endpoint advertises
+func (v *Validator) discoverFeatures(endpoint *url.URL, result *models.NewtValidationResult) {
+	result.Features = append(result.Features, "newt-auth")
+	if result.TLS != nil {
+		result.Features = append(result.Features, "tls")
+	}
+
+	// A plain GET to the websocket endpoint is rejected with 400 (not 404) when it exists
+	if resp, err := v.client.Get(endpoint.String() + websocketPath); err == nil {
+		if resp.StatusCode != http.StatusNotFound {
+			result.Features = append(result.Features, "websocket")
+		}
+		resp.Body.Close()
+	}
+}
+
+// describeTLS summarizes the negotiated TLS connection and leaf certificate
+func describeTLS(state *tls.ConnectionState) *models.NewtTLSInfo {
+	info := &models.NewtTLSInfo{}
+	if state == nil {
+		return info
+	}
+
+	info.Version = tlsVersionName(state.Version)
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		info.Subject = cert.Subject.CommonName
+		info.Issuer = cert.Issuer.CommonName
+		info.DNSNames = cert.DNSNames
+		info.NotAfter = cert.NotAfter
+		info.DaysRemaining = int(time.Until(cert.NotAfter).Hours() / 24)
+	}
+	return info
+}
+
+// tlsVersionName returns a readable TLS protocol version
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04x", version)
+	}
+}
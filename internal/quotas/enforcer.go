@@ -0,0 +1,333 @@
+package quotas
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+)
+
+const megabyte = 1024 * 1024
+
+// ExceededError rejects creating a deployment or backup because a quota limit
+// is reached
+type ExceededError struct {
+	Scope    models.QuotaScope
+	ScopeID  string
+	Resource string // deployments, memory or backup storage
+	Used     int64
+	Limit    int64
+}
+
+func (e *ExceededError) Error() string {
+	if e.Resource == "deployments" {
+		return fmt.Sprintf("quota exceeded: %s %s has %d of %d deployments allowed", e.Scope, e.ScopeID, e.Used, e.Limit)
+	}
+	return fmt.Sprintf("quota exceeded: %s %s uses %dMB of %dMB %s allowed", e.Scope, e.ScopeID, e.Used/megabyte, e.Limit/megabyte, e.Resource)
+}
+
+// Enforcer measures what projects and users consume and rejects new deployments
+// and backups once a limit is reached. Limits set for a project or user replace
+// the configured defaults one by one
+type Enforcer struct {
+	db     *sql.DB
+	client *client.Client
+	config config.QuotaConfig
+}
+
+// NewEnforcer creates a new quota enforcer
+func NewEnforcer(db *sql.DB, dockerClient *client.Client, cfg config.QuotaConfig) *Enforcer {
+	return &Enforcer{db: db, client: dockerClient, config: cfg}
+}
+
+// Enabled reports whether quotas are enforced
+func (e *Enforcer) Enabled() bool {
+	return e.config.Enabled
+}
+
+// Limits returns the limits of a project or user and whether any were set for it
+func (e *Enforcer) Limits(scope models.QuotaScope, id string) (models.QuotaLimits, bool, *time.Time, error) {
+	defaults := e.config.Project
+	if scope == models.QuotaScopeUser {
+		defaults = e.config.User
+	}
+	limits := models.QuotaLimits{
+		MaxDeployments:     defaults.MaxDeployments,
+		MaxMemoryMB:        defaults.MaxMemoryMB,
+		MaxBackupStorageMB: defaults.MaxBackupStorageMB,
+	}
+
+	var maxDeployments, maxMemory, maxBackupStorage sql.NullInt64
+	var updatedAt time.Time
+	err := e.db.QueryRow(`
+		SELECT max_deployments, max_memory_mb, max_backup_storage_mb, updated_at
+		FROM quotas WHERE scope = $1 AND scope_id = $2`, scope, id).Scan(
+		&maxDeployments, &maxMemory, &maxBackupStorage, &updatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return limits, false, nil, nil
+	}
+	if err != nil {
+		return limits, false, nil, err
+	}
+
+	if maxDeployments.Valid {
+		limits.MaxDeployments = int(maxDeployments.Int64)
+	}
+	if maxMemory.Valid {
+		limits.MaxMemoryMB = int(maxMemory.Int64)
+	}
+	if maxBackupStorage.Valid {
+		limits.MaxBackupStorageMB = int(maxBackupStorage.Int64)
+	}
+	return limits, true, &updatedAt, nil
+}
+
+// SetLimits sets the limits of a project or user. Limits left out keep the
+// configured default
+func (e *Enforcer) SetLimits(scope models.QuotaScope, id string, update *models.QuotaUpdate) error {
+	_, err := e.db.Exec(`
+		INSERT INTO quotas (scope, scope_id, max_deployments, max_memory_mb, max_backup_storage_mb, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (scope, scope_id) DO UPDATE SET
+			max_deployments = excluded.max_deployments,
+			max_memory_mb = excluded.max_memory_mb,
+			max_backup_storage_mb = excluded.max_backup_storage_mb,
+			updated_at = excluded.updated_at`,
+		scope, id, nullableLimit(update.MaxDeployments), nullableLimit(update.MaxMemoryMB),
+		nullableLimit(update.MaxBackupStorageMB), time.Now(),
+	)
+	return err
+}
+
+// ResetLimits returns a project or user to the configured defaults
+func (e *Enforcer) ResetLimits(scope models.QuotaScope, id string) error {
+	_, err := e.db.Exec("DELETE FROM quotas WHERE scope = $1 AND scope_id = $2", scope, id)
+	return err
+}
+
+// Usage reports what a project or user consumes against its limits
+func (e *Enforcer) Usage(ctx context.Context, scope models.QuotaScope, id string) (*models.QuotaUsage, error) {
+	limits, custom, updatedAt, err := e.Limits(scope, id)
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, err := e.countDeployments(scope, id)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := e.memoryUsage(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+	backupStorage, err := e.backupStorage(scope, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.QuotaUsage{
+		Scope:         scope,
+		ScopeID:       id,
+		Enforced:      e.config.Enabled,
+		Custom:        custom,
+		Deployments:   models.NewQuotaResource(int64(deployments), int64(limits.MaxDeployments)),
+		Memory:        models.NewQuotaResource(memory, int64(limits.MaxMemoryMB)*megabyte),
+		BackupStorage: models.NewQuotaResource(backupStorage, int64(limits.MaxBackupStorageMB)*megabyte),
+		UpdatedAt:     updatedAt,
+	}, nil
+}
+
+// CheckDeployment returns an *ExceededError when the project, or the user
+// creating it, may not have another deployment. userID is empty without
+// authentication
+func (e *Enforcer) CheckDeployment(ctx context.Context, projectID, userID string) error {
+	if !e.config.Enabled {
+		return nil
+	}
+
+	for _, scope := range scopesOf(projectID, userID) {
+		usage, err := e.Usage(ctx, scope.scope, scope.id)
+		if err != nil {
+			return fmt.Errorf("failed to check quota: %w", err)
+		}
+		if usage.Deployments.Reached {
+			return exceeded(scope, "deployments", usage.Deployments)
+		}
+		if usage.Memory.Reached {
+			return exceeded(scope, "memory", usage.Memory)
+		}
+	}
+	return nil
+}
+
+// CheckBackup returns an *ExceededError when the projects of the deployments, or
+// the user creating it, may not store another backup
+func (e *Enforcer) CheckBackup(ctx context.Context, deploymentIDs []string, userID string) error {
+	if !e.config.Enabled {
+		return nil
+	}
+
+	projects := make(map[string]bool)
+	for _, deploymentID := range deploymentIDs {
+		var projectID string
+		err := e.db.QueryRow("SELECT COALESCE(project_id, 'global') FROM deployments WHERE id = $1", deploymentID).Scan(&projectID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check quota: %w", err)
+		}
+		projects[projectID] = true
+	}
+
+	scopes := scopesOf("", userID)
+	for projectID := range projects {
+		scopes = append(scopes, scopeRef{models.QuotaScopeProject, projectID})
+	}
+	for _, scope := range scopes {
+		limits, _, _, err := e.Limits(scope.scope, scope.id)
+		if err != nil {
+			return fmt.Errorf("failed to check quota: %w", err)
+		}
+		if limits.MaxBackupStorageMB == 0 {
+			continue
+		}
+		used, err := e.backupStorage(scope.scope, scope.id)
+		if err != nil {
+			return fmt.Errorf("failed to check quota: %w", err)
+		}
+		resource := models.NewQuotaResource(used, int64(limits.MaxBackupStorageMB)*megabyte)
+		if resource.Reached {
+			return exceeded(scope, "backup storage", resource)
+		}
+	}
+	return nil
+}
+
+// countDeployments counts the deployments of a project or created by a user
+func (e *Enforcer) countDeployments(scope models.QuotaScope, id string) (int, error) {
+	var count int
+	err := e.db.QueryRow("SELECT COUNT(*) FROM deployments WHERE "+scopeCondition(scope, "")+" = $1", id).Scan(&count)
+	return count, err
+}
+
+// memoryUsage sums the memory limits of the running containers of a project's or
+// user's stacks. Containers without a limit count for nothing
+func (e *Enforcer) memoryUsage(ctx context.Context, scope models.QuotaScope, id string) (int64, error) {
+	rows, err := e.db.Query("SELECT stack_name FROM deployments WHERE "+scopeCondition(scope, "")+" = $1", id)
+	if err != nil {
+		return 0, err
+	}
+	stacks := make(map[string]bool)
+	for rows.Next() {
+		var stackName string
+		if err := rows.Scan(&stackName); err == nil {
+			stacks[stackName] = true
+		}
+	}
+	rows.Close()
+	if len(stacks) == 0 {
+		return 0, nil
+	}
+
+	var total int64
+	for _, label := range []string{"com.docker.compose.project", "com.docker.stack.namespace"} {
+		containers, err := e.client.ContainerList(ctx, types.ContainerListOptions{
+			Filters: filters.NewArgs(filters.Arg("label", label)),
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to list containers: %w", err)
+		}
+		for _, c := range containers {
+			if !stacks[c.Labels[label]] {
+				continue
+			}
+			info, err := e.client.ContainerInspect(ctx, c.ID)
+			if err != nil || info.HostConfig == nil {
+				continue
+			}
+			total += info.HostConfig.Memory
+		}
+	}
+	return total, nil
+}
+
+// backupStorage sums the archive sizes of the completed backups of a project's or user's
+// deployments. A backup of several projects counts against each of them
+func (e *Enforcer) backupStorage(scope models.QuotaScope, id string) (int64, error) {
+	rows, err := e.db.Query(`
+		SELECT b.storage_path
+		FROM backups b
+		WHERE b.status = 'completed'
+		  AND EXISTS (
+			SELECT 1 FROM json_each(b.deployment_ids) j
+			JOIN deployments d ON d.id = j.value
+			WHERE `+scopeCondition(scope, "d.")+` = $1
+		  )`, id)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	// Measure the archives on disk rather than trusting the recorded sizes; an
+	// archive that no longer exists takes no storage
+	var total int64
+	for rows.Next() {
+		var storagePath sql.NullString
+		if err := rows.Scan(&storagePath); err != nil {
+			return 0, err
+		}
+		if !storagePath.Valid || storagePath.String == "" {
+			continue
+		}
+		if info, err := os.Stat(storagePath.String); err == nil {
+			total += info.Size()
+		}
+	}
+	return total, rows.Err()
+}
+
+// scopeCondition is the column of deployments a scope's ID is compared with
+func scopeCondition(scope models.QuotaScope, prefix string) string {
+	if scope == models.QuotaScopeUser {
+		return prefix + "created_by"
+	}
+	return "COALESCE(" + prefix + "project_id, 'global')"
+}
+
+type scopeRef struct {
+	scope models.QuotaScope
+	id    string
+}
+
+// scopesOf returns the quotas an action counts against; users are only known
+// with authentication
+func scopesOf(projectID, userID string) []scopeRef {
+	var scopes []scopeRef
+	if projectID != "" {
+		scopes = append(scopes, scopeRef{models.QuotaScopeProject, projectID})
+	}
+	if userID != "" {
+		scopes = append(scopes, scopeRef{models.QuotaScopeUser, userID})
+	}
+	return scopes
+}
+
+func exceeded(scope scopeRef, resource string, r models.QuotaResource) *ExceededError {
+	return &ExceededError{Scope: scope.scope, ScopeID: scope.id, Resource: resource, Used: r.Used, Limit: r.Limit}
+}
+
+func nullableLimit(limit *int) interface{} {
+	if limit == nil {
+		return nil
+	}
+	return *limit
+}
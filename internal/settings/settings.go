@@ -0,0 +1,330 @@
+package settings
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"docker-deploy-app/internal/config"
+)
+
+// keyPrefix prefixes the system_settings keys of the stored overrides
+const keyPrefix = "settings."
+
+var (
+	ErrUnknownSection  = fmt.Errorf("unknown settings section")
+	ErrInvalidSettings = fmt.Errorf("invalid settings")
+)
+
+// sections are the configuration sections safe to change at runtime, by name
+var sections = map[string]func(c *config.Config) interface{}{
+	"marketplace":      func(c *config.Config) interface{} { return &c.Marketplace },
+	"backup_schedules": func(c *config.Config) interface{} { return &c.Backup.Schedules },
+	"newt":             func(c *config.Config) interface{} { return &c.Newt },
+	"webhooks":         func(c *config.Config) interface{} { return &c.Webhooks },
+}
+
+// Section is the effective value of a runtime-editable section and the keys set
+// in the database over the file and environment configuration
+type Section struct {
+	Values     map[string]interface{} `json:"values"`
+	Overridden map[string]interface{} `json:"overridden"`
+}
+
+// Store keeps the settings edited through the API in the database and layers them
+// over the configuration loaded from the file and environment
+type Store struct {
+	db        *sql.DB
+	config    *config.Config // The configuration the server runs with
+	base      *config.Config // The sections as loaded, before overrides
+	overrides map[string]map[string]interface{}
+	hooks     []func(*config.Config)
+	mu        sync.Mutex
+}
+
+// NewStore creates a settings store for the configuration the server runs with
+func NewStore(db *sql.DB, cfg *config.Config) *Store {
+	base := *cfg
+	return &Store{
+		db:        db,
+		config:    cfg,
+		base:      &base,
+		overrides: make(map[string]map[string]interface{}),
+	}
+}
+
+// Names returns the names of the editable sections
+func Names() []string {
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load reads the stored overrides and applies them. Call it before components
+// copy settings out of the configuration
+func (s *Store) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows, err := s.db.Query("SELECT key, value FROM system_settings WHERE key LIKE $1", keyPrefix+"%")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(key, keyPrefix)
+		if _, ok := sections[name]; !ok {
+			continue
+		}
+		var override map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &override); err != nil {
+			return fmt.Errorf("invalid stored settings %s: %w", name, err)
+		}
+		s.overrides[name] = override
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	candidate, err := s.layer(s.overrides)
+	if err != nil {
+		return fmt.Errorf("stored settings: %w", err)
+	}
+	if err := candidate.Validate(); err != nil {
+		return fmt.Errorf("stored settings: %w", err)
+	}
+	s.apply(candidate)
+	return nil
+}
+
+// OnChange registers a hook run after settings change, for components that copied
+// a setting when they were created
+func (s *Store) OnChange(hook func(*config.Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook)
+}
+
+// Get returns every editable section
+func (s *Store) Get() (map[string]Section, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string]Section, len(sections))
+	for name, section := range sections {
+		values, err := toMap(section(s.config))
+		if err != nil {
+			return nil, err
+		}
+		overridden := s.overrides[name]
+		if overridden == nil {
+			overridden = map[string]interface{}{}
+		}
+		result[name] = Section{Values: values, Overridden: overridden}
+	}
+	return result, nil
+}
+
+// Update merges changes into the stored overrides of one or more sections, keyed
+// by section name, and applies them. Nothing changes unless the resulting
+// configuration is valid
+func (s *Store) Update(changes map[string]map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	overrides := make(map[string]map[string]interface{}, len(s.overrides))
+	for name, override := range s.overrides {
+		overrides[name] = override
+	}
+	for name, change := range changes {
+		if _, ok := sections[name]; !ok {
+			return fmt.Errorf("%w %q, expected one of %s", ErrUnknownSection, name, strings.Join(Names(), ", "))
+		}
+		overrides[name] = merge(overrides[name], change)
+	}
+
+	candidate, err := s.layer(overrides)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+	}
+	if err := candidate.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for name := range changes {
+		value, err := json.Marshal(overrides[name])
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO system_settings (key, value, updated_at) VALUES ($1, $2, $3)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+			keyPrefix+name, string(value), time.Now()); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.overrides = overrides
+	s.apply(candidate)
+	s.notify()
+	return nil
+}
+
+// Reset removes the stored overrides of a section, restoring its file and
+// environment configuration
+func (s *Store) Reset(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := sections[name]; !ok {
+		return fmt.Errorf("%w %q, expected one of %s", ErrUnknownSection, name, strings.Join(Names(), ", "))
+	}
+	if _, err := s.db.Exec("DELETE FROM system_settings WHERE key = $1", keyPrefix+name); err != nil {
+		return err
+	}
+
+	delete(s.overrides, name)
+	candidate, err := s.layer(s.overrides)
+	if err != nil {
+		return err
+	}
+	s.apply(candidate)
+	s.notify()
+	return nil
+}
+
+// Rebase takes the marketplace section of cfg as its new base, after the
+// configuration file was reloaded, and applies the overrides over it again. It is
+// the only editable section a reload replaces
+func (s *Store) Rebase(cfg *config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fresh, err := clone(&cfg.Marketplace)
+	if err != nil {
+		return err
+	}
+	set(&s.base.Marketplace, fresh)
+
+	candidate, err := s.layer(s.overrides)
+	if err != nil {
+		return err
+	}
+	s.apply(candidate)
+	return nil
+}
+
+// layer returns a copy of the base configuration with overrides decoded over it
+func (s *Store) layer(overrides map[string]map[string]interface{}) (*config.Config, error) {
+	candidate := *s.config
+	for name, section := range sections {
+		// Decode onto a fresh copy, so slices of the base are never written
+		fresh, err := clone(section(s.base))
+		if err != nil {
+			return nil, err
+		}
+		if override := overrides[name]; len(override) > 0 {
+			data, err := yaml.Marshal(override)
+			if err != nil {
+				return nil, err
+			}
+			decoder := yaml.NewDecoder(bytes.NewReader(data))
+			decoder.KnownFields(true)
+			if err := decoder.Decode(fresh); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		set(section(&candidate), fresh)
+	}
+	return &candidate, nil
+}
+
+// apply replaces the editable sections of the running configuration. Handlers read
+// them on every request, so they are updated in place
+func (s *Store) apply(candidate *config.Config) {
+	for _, section := range sections {
+		set(section(s.config), section(candidate))
+	}
+}
+
+func (s *Store) notify() {
+	for _, hook := range s.hooks {
+		hook(s.config)
+	}
+}
+
+// clone deep copies a section through its YAML form
+func clone(section interface{}) (interface{}, error) {
+	data, err := yaml.Marshal(section)
+	if err != nil {
+		return nil, err
+	}
+	fresh := reflect.New(reflect.TypeOf(section).Elem()).Interface()
+	if err := yaml.Unmarshal(data, fresh); err != nil {
+		return nil, err
+	}
+	return fresh, nil
+}
+
+// set copies the section src points to into the section dst points to
+func set(dst, src interface{}) {
+	reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(src).Elem())
+}
+
+// toMap returns a section keyed by its YAML names, as the API shows it
+func toMap(section interface{}) (map[string]interface{}, error) {
+	data, err := yaml.Marshal(section)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// merge returns the override with change merged in. Nested objects are merged
+// key by key; a null value removes the key, restoring its configured value
+func merge(override, change map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(override)+len(change))
+	for key, value := range override {
+		merged[key] = value
+	}
+	for key, value := range change {
+		switch v := value.(type) {
+		case nil:
+			delete(merged, key)
+		case map[string]interface{}:
+			existing, _ := merged[key].(map[string]interface{})
+			merged[key] = merge(existing, v)
+		default:
+			merged[key] = value
+		}
+	}
+	return merged
+}
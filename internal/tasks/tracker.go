@@ -0,0 +1,166 @@
+package tasks
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"docker-deploy-app/internal/models"
+)
+
+// Tracker records the progress of background operations in the tasks table.
+// Tracking is best effort: failures are logged and never abort the operation
+type Tracker struct {
+	db *sql.DB
+}
+
+// ListOptions filters a task listing
+type ListOptions struct {
+	Type       string
+	State      string
+	ResourceID string
+	Limit      int
+	Offset     int
+}
+
+// NewTracker creates a new task tracker
+func NewTracker(db *sql.DB) *Tracker {
+	return &Tracker{db: db}
+}
+
+// Start records a running task and returns its ID
+func (t *Tracker) Start(taskType models.TaskType, resourceID, message string) string {
+	id := fmt.Sprintf("task_%d", time.Now().UnixNano())
+	now := time.Now()
+
+	_, err := t.db.Exec(`
+		INSERT INTO tasks (id, type, state, progress, message, resource_id, created_at, started_at)
+		VALUES ($1, $2, $3, 0, $4, $5, $6, $7)`,
+		id, taskType, models.TaskStateRunning, message, resourceID, now, now,
+	)
+	if err != nil {
+		slog.Error("Failed to record task", "type", taskType, "resource_id", resourceID, "error", err)
+	}
+	return id
+}
+
+// Progress updates the completion percentage and message of a running task
+func (t *Tracker) Progress(id string, progress int, message string) {
+	if progress < 0 {
+		progress = 0
+	} else if progress > 100 {
+		progress = 100
+	}
+
+	_, err := t.db.Exec(`
+		UPDATE tasks SET progress = $1, message = $2
+		WHERE id = $3 AND state = $4`,
+		progress, message, id, models.TaskStateRunning,
+	)
+	if err != nil {
+		slog.Error("Failed to update task progress", "task_id", id, "error", err)
+	}
+}
+
+// Finish marks a task succeeded, or failed when err is not nil. A failed task keeps
+// the progress it reached
+func (t *Tracker) Finish(id string, err error) {
+	query := `UPDATE tasks SET state = $1, message = $2, error = $3, finished_at = $4, progress = 100 WHERE id = $5`
+	state, message, errMsg := models.TaskStateSucceeded, "Completed", ""
+	if err != nil {
+		query = `UPDATE tasks SET state = $1, message = $2, error = $3, finished_at = $4 WHERE id = $5`
+		state, message, errMsg = models.TaskStateFailed, "Failed", err.Error()
+	}
+
+	if _, dbErr := t.db.Exec(query, state, message, errMsg, time.Now(), id); dbErr != nil {
+		slog.Error("Failed to finish task", "task_id", id, "error", dbErr)
+	}
+}
+
+// Get returns a task by ID, or sql.ErrNoRows when it does not exist
+func (t *Tracker) Get(id string) (*models.Task, error) {
+	row := t.db.QueryRow(`
+		SELECT id, type, state, progress, message, error, resource_id, created_at, started_at, finished_at
+		FROM tasks WHERE id = $1`, id)
+	return scanTask(row)
+}
+
+// List returns tasks newest first
+func (t *Tracker) List(opts ListOptions) ([]*models.Task, error) {
+	query := `
+		SELECT id, type, state, progress, message, error, resource_id, created_at, started_at, finished_at
+		FROM tasks WHERE 1=1`
+	args := []interface{}{}
+	argCount := 0
+
+	for column, value := range map[string]string{"type": opts.Type, "state": opts.State, "resource_id": opts.ResourceID} {
+		if value == "" {
+			continue
+		}
+		argCount++
+		query += fmt.Sprintf(" AND %s = $%d", column, argCount)
+		args = append(args, value)
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = 50
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argCount+1, argCount+2)
+	args = append(args, opts.Limit, opts.Offset)
+
+	rows, err := t.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []*models.Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// FailInterrupted marks tasks left running by a previous process as failed
+func (t *Tracker) FailInterrupted() (int64, error) {
+	result, err := t.db.Exec(`
+		UPDATE tasks SET state = $1, error = $2, finished_at = $3
+		WHERE state IN ($4, $5)`,
+		models.TaskStateFailed, "interrupted by server restart", time.Now(),
+		models.TaskStatePending, models.TaskStateRunning,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTask(s scanner) (*models.Task, error) {
+	var task models.Task
+	var message, errMsg, resourceID sql.NullString
+	var startedAt, finishedAt sql.NullTime
+
+	if err := s.Scan(&task.ID, &task.Type, &task.State, &task.Progress, &message, &errMsg,
+		&resourceID, &task.CreatedAt, &startedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+
+	task.Message, task.Error, task.ResourceID = message.String, errMsg.String, resourceID.String
+	if startedAt.Valid {
+		task.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		task.FinishedAt = &finishedAt.Time
+	}
+	return &task, nil
+}
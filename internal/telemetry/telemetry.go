@@ -0,0 +1,213 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"time"
+
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+)
+
+// Settings keys in system_settings
+const (
+	instanceIDKey = "telemetry_instance_id"
+	lastSentKey   = "telemetry_last_sent"
+)
+
+// Reporter periodically sends an anonymous usage report when telemetry is enabled.
+// When disabled nothing is sent and no instance ID is created
+type Reporter struct {
+	db         *sql.DB
+	config     config.TelemetryConfig
+	httpClient *http.Client
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+// NewReporter creates a new telemetry reporter
+func NewReporter(db *sql.DB, cfg config.TelemetryConfig) *Reporter {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Reporter{
+		db:         db,
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Enabled reports whether reports are sent
+func (tr *Reporter) Enabled() bool {
+	return tr.config.Enabled && tr.config.Endpoint != "" && tr.config.Interval > 0
+}
+
+// Start begins periodic reporting. The first report is sent once a full interval
+// has passed since the last one, so restarts do not send extra reports
+func (tr *Reporter) Start() {
+	if !tr.Enabled() {
+		return
+	}
+
+	interval := time.Duration(tr.config.Interval) * time.Second
+	slog.Info("Starting anonymous telemetry", "endpoint", tr.config.Endpoint, "interval", interval)
+	go func() {
+		wait := time.Duration(0)
+		if lastSent, ok := tr.lastSent(); ok {
+			wait = time.Until(lastSent.Add(interval))
+		}
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+				if err := tr.Send(); err != nil {
+					slog.Warn("Failed to send telemetry", "error", err)
+				}
+				timer.Reset(interval)
+			case <-tr.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops periodic reporting
+func (tr *Reporter) Stop() {
+	tr.cancel()
+}
+
+// Collect builds the report exactly as it would be sent. The instance ID is only
+// assigned while telemetry is enabled
+func (tr *Reporter) Collect() (*models.TelemetryReport, error) {
+	report := &models.TelemetryReport{
+		Version:           tr.setting("app_version"),
+		OS:                runtime.GOOS,
+		Arch:              runtime.GOARCH,
+		GoVersion:         runtime.Version(),
+		DeploymentsByMode: make(map[string]int),
+		GeneratedAt:       time.Now().UTC(),
+	}
+	if tr.Enabled() {
+		id, err := tr.instanceID()
+		if err != nil {
+			return nil, err
+		}
+		report.InstanceID = id
+	}
+
+	err := tr.db.QueryRow(`
+		SELECT COUNT(DISTINCT template_id), COUNT(*), COALESCE(SUM(CASE WHEN newt_injected THEN 1 ELSE 0 END), 0)
+		FROM deployments`).Scan(&report.TemplatesDeployed, &report.Deployments, &report.NewtDeployments)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tr.db.Query("SELECT deploy_mode, COUNT(*) FROM deployments GROUP BY deploy_mode")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var mode sql.NullString
+		var count int
+		if err := rows.Scan(&mode, &count); err != nil {
+			return nil, err
+		}
+		if !mode.Valid || mode.String == "" {
+			mode.String = string(models.DeployModeCompose)
+		}
+		report.DeploymentsByMode[mode.String] += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tr.db.QueryRow("SELECT COUNT(*) FROM backups").Scan(&report.Backups); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// Send collects and posts a report to the configured endpoint
+func (tr *Reporter) Send() error {
+	report, err := tr.Collect()
+	if err != nil {
+		return fmt.Errorf("failed to collect report: %w", err)
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(tr.ctx, http.MethodPost, tr.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "docker-deploy-app/"+report.Version)
+
+	resp, err := tr.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+
+	tr.saveSetting(lastSentKey, time.Now().UTC().Format(time.RFC3339))
+	return nil
+}
+
+// instanceID returns the random ID of this instance, creating it on first use
+func (tr *Reporter) instanceID() (string, error) {
+	if id := tr.setting(instanceIDKey); id != "" {
+		return id, nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	if err := tr.saveSetting(instanceIDKey, id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// lastSent returns when the last report was sent
+func (tr *Reporter) lastSent() (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, tr.setting(lastSentKey))
+	return t, err == nil
+}
+
+// setting returns a system setting, or an empty string when it is not set
+func (tr *Reporter) setting(key string) string {
+	var value sql.NullString
+	tr.db.QueryRow("SELECT value FROM system_settings WHERE key = $1", key).Scan(&value)
+	return value.String
+}
+
+// saveSetting stores a system setting
+func (tr *Reporter) saveSetting(key, value string) error {
+	_, err := tr.db.Exec(`
+		INSERT INTO system_settings (key, value, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, value, time.Now())
+	return err
+}
@@ -0,0 +1,414 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"docker-deploy-app/internal/config"
+	"docker-deploy-app/internal/models"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the body, keyed by the webhook secret
+	SignatureHeader = "X-Docker-Deploy-Signature-256"
+	EventHeader     = "X-Docker-Deploy-Event"
+	DeliveryHeader  = "X-Docker-Deploy-Delivery"
+
+	// baseRetryDelay doubles after every failed attempt, up to maxRetryDelay
+	baseRetryDelay = 30 * time.Second
+	maxRetryDelay  = time.Hour
+	// batchSize bounds the deliveries sent per run
+	batchSize = 50
+	// idlePollInterval is how often a disabled dispatcher checks whether it was enabled
+	idlePollInterval = time.Minute
+)
+
+// Dispatcher sends pending webhook deliveries, retrying failures with exponential
+// backoff. Deliveries are held during the quiet hours of their webhook, alerts
+// that may recover are held for the flap window and duplicate alerts are
+// dropped. Webhooks grouping alerts receive the deliveries due together as one
+// digest
+type Dispatcher struct {
+	db         *sql.DB
+	config     config.WebhooksConfig
+	httpClient *http.Client
+	ctx        context.Context
+	cancel     context.CancelFunc
+	mu         sync.Mutex
+}
+
+// pendingDelivery is a due delivery joined with its webhook
+type pendingDelivery struct {
+	id          int64
+	event       models.WebhookEvent
+	payload     string
+	attempts    int
+	dedupKey    string
+	createdAt   time.Time
+	webhookID   int
+	url         string
+	secret      string
+	quietHours  *models.QuietHours
+	groupAlerts bool
+}
+
+// NewDispatcher creates a new webhook dispatcher
+func NewDispatcher(db *sql.DB, cfg config.WebhooksConfig) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Dispatcher{
+		db:         db,
+		config:     cfg,
+		httpClient: newHTTPClient(cfg),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Start begins sending deliveries periodically. The loop runs even while webhooks
+// are disabled, so enabling them through the settings API takes effect without a restart
+func (d *Dispatcher) Start() {
+	cfg := d.settings()
+	if cfg.Enabled {
+		slog.Info("Starting webhook dispatcher", "interval", pollInterval(cfg))
+	}
+
+	go func() {
+		interval := pollInterval(cfg)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				cfg := d.settings()
+				if next := pollInterval(cfg); next != interval {
+					interval = next
+					ticker.Reset(interval)
+				}
+				if !cfg.Enabled {
+					continue
+				}
+				if err := d.DispatchDue(); err != nil {
+					slog.Error("Webhook dispatch failed", "error", err)
+				}
+			case <-d.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Configure replaces the dispatcher settings, after they were changed at runtime.
+// A changed poll interval applies from the next tick
+func (d *Dispatcher) Configure(cfg config.WebhooksConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.config = cfg
+	d.httpClient = newHTTPClient(cfg)
+}
+
+// newHTTPClient creates the client deliveries are sent with. It refuses private and
+// loopback addresses, so a webhook cannot make the server probe its own network
+// even through a host name that resolves to one
+func newHTTPClient(cfg config.WebhooksConfig) *http.Client {
+	return &http.Client{
+		Timeout: time.Duration(cfg.Timeout) * time.Second,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 10 * time.Second,
+				Control: func(network, address string, _ syscall.RawConn) error {
+					host, _, err := net.SplitHostPort(address)
+					if err != nil {
+						return err
+					}
+					if ip := net.ParseIP(host); ip == nil || !models.IsPublicIP(ip) {
+						return fmt.Errorf("webhook host %s is not a public address", host)
+					}
+					return nil
+				},
+			}).DialContext,
+		},
+	}
+}
+
+func (d *Dispatcher) settings() config.WebhooksConfig {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.config
+}
+
+func (d *Dispatcher) client() *http.Client {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.httpClient
+}
+
+// pollInterval returns how often the dispatcher loop runs with cfg
+func pollInterval(cfg config.WebhooksConfig) time.Duration {
+	interval := time.Duration(cfg.PollInterval) * time.Second
+	if !cfg.Enabled || interval <= 0 {
+		return idlePollInterval
+	}
+	return interval
+}
+
+// Stop stops sending deliveries
+func (d *Dispatcher) Stop() {
+	d.cancel()
+}
+
+// DispatchDue sends every pending delivery whose next attempt is due
+func (d *Dispatcher) DispatchDue() error {
+	rows, err := d.db.QueryContext(d.ctx, `
+		SELECT wd.id, wd.event, wd.payload, wd.attempts, COALESCE(wd.dedup_key, ''), wd.created_at,
+		       w.id, w.url, w.secret, COALESCE(w.quiet_hours, ''), COALESCE(w.group_alerts, FALSE)
+		FROM webhook_deliveries wd
+		JOIN webhooks w ON wd.webhook_id = w.id
+		WHERE wd.status = $1 AND wd.next_attempt_at <= $2 AND w.enabled = TRUE
+		ORDER BY wd.next_attempt_at
+		LIMIT $3`,
+		models.WebhookDeliveryPending, time.Now(), batchSize,
+	)
+	if err != nil {
+		return err
+	}
+
+	var due []pendingDelivery
+	for rows.Next() {
+		var p pendingDelivery
+		var quietHours string
+		if err := rows.Scan(&p.id, &p.event, &p.payload, &p.attempts, &p.dedupKey, &p.createdAt,
+			&p.webhookID, &p.url, &p.secret, &quietHours, &p.groupAlerts); err != nil {
+			rows.Close()
+			return err
+		}
+		p.quietHours = models.UnmarshalQuietHours(quietHours)
+		due = append(due, p)
+	}
+	rows.Close()
+
+	now := time.Now()
+	var order []int
+	groups := make(map[int][]pendingDelivery)
+	for _, p := range due {
+		if d.ctx.Err() != nil {
+			return nil
+		}
+		if d.hold(p, now) {
+			continue
+		}
+		if !p.groupAlerts || p.event == models.WebhookEventPing {
+			d.deliver(p)
+			continue
+		}
+		if _, ok := groups[p.webhookID]; !ok {
+			order = append(order, p.webhookID)
+		}
+		groups[p.webhookID] = append(groups[p.webhookID], p)
+	}
+
+	for _, webhookID := range order {
+		if d.ctx.Err() != nil {
+			return nil
+		}
+		if group := groups[webhookID]; len(group) == 1 {
+			d.deliver(group[0])
+		} else {
+			d.deliverGroup(group)
+		}
+	}
+	return nil
+}
+
+// hold postpones a delivery during the quiet hours of its webhook, or while its
+// alert may still recover, and drops duplicate alerts. It reports whether the
+// delivery must not be sent now
+func (d *Dispatcher) hold(p pendingDelivery, now time.Time) bool {
+	if p.event == models.WebhookEventPing {
+		return false
+	}
+	if p.quietHours != nil {
+		if until, ok := p.quietHours.Until(now); ok {
+			d.postpone(p, until)
+			return true
+		}
+	}
+	if p.attempts > 0 || p.dedupKey == "" {
+		return false
+	}
+
+	cfg := d.settings()
+	if p.event.CanRecover() && cfg.FlapWindow > 0 {
+		if until := p.createdAt.Add(time.Duration(cfg.FlapWindow) * time.Second); now.Before(until) {
+			d.postpone(p, until)
+			return true
+		}
+	}
+	if cfg.DedupWindow > 0 && d.duplicate(p, now.Add(-time.Duration(cfg.DedupWindow)*time.Second)) {
+		if _, err := d.db.Exec("DELETE FROM webhook_deliveries WHERE id = $1", p.id); err != nil {
+			slog.Error("Failed to drop duplicate webhook delivery", "delivery_id", p.id, "error", err)
+			return false
+		}
+		slog.Info("Dropped duplicate webhook alert", "delivery_id", p.id, "event", p.event, "subject", p.dedupKey)
+		return true
+	}
+	return false
+}
+
+// postpone moves the next attempt of a delivery to until
+func (d *Dispatcher) postpone(p pendingDelivery, until time.Time) {
+	if _, err := d.db.Exec("UPDATE webhook_deliveries SET next_attempt_at = $1 WHERE id = $2", until, p.id); err != nil {
+		slog.Error("Failed to postpone webhook delivery", "delivery_id", p.id, "error", err)
+	}
+}
+
+// duplicate reports whether the latest earlier delivery of the same subject to
+// the webhook, created since, is the same alert. A recovery in between makes
+// the alert new again
+func (d *Dispatcher) duplicate(p pendingDelivery, since time.Time) bool {
+	var previous models.WebhookEvent
+	err := d.db.QueryRow(`
+		SELECT event FROM webhook_deliveries
+		WHERE webhook_id = $1 AND dedup_key = $2 AND id < $3 AND created_at >= $4
+		ORDER BY id DESC LIMIT 1`,
+		p.webhookID, p.dedupKey, p.id, since,
+	).Scan(&previous)
+	return err == nil && previous == p.event
+}
+
+// deliver makes one attempt and records its outcome
+func (d *Dispatcher) deliver(p pendingDelivery) {
+	statusCode, err := d.send(p)
+	d.record(p, statusCode, err)
+}
+
+// deliverGroup sends deliveries due together to one webhook as a digest event
+// carrying their payloads, and records its outcome on each
+func (d *Dispatcher) deliverGroup(group []pendingDelivery) {
+	ids := make([]int64, 0, len(group))
+	events := make([]json.RawMessage, 0, len(group))
+	for _, p := range group {
+		ids = append(ids, p.id)
+		events = append(events, json.RawMessage(p.payload))
+	}
+	payload, err := json.Marshal(Envelope{
+		ID:        newEventID(),
+		Event:     models.WebhookEventDigest,
+		Timestamp: time.Now().UTC(),
+		Data: map[string]interface{}{
+			"delivery_ids": ids,
+			"events":       events,
+		},
+	})
+	if err != nil {
+		for _, p := range group {
+			d.deliver(p)
+		}
+		return
+	}
+
+	digest := group[0]
+	digest.event = models.WebhookEventDigest
+	digest.payload = string(payload)
+	statusCode, err := d.send(digest)
+	for _, p := range group {
+		d.record(p, statusCode, err)
+	}
+}
+
+// record stores the outcome of an attempt, scheduling a retry after a failure
+func (d *Dispatcher) record(p pendingDelivery, statusCode int, err error) {
+	attempts := p.attempts + 1
+
+	if err == nil {
+		_, dbErr := d.db.Exec(`
+			UPDATE webhook_deliveries
+			SET status = $1, attempts = $2, response_status = $3, error = '', delivered_at = $4, next_attempt_at = NULL
+			WHERE id = $5`,
+			models.WebhookDeliveryDelivered, attempts, statusCode, time.Now(), p.id,
+		)
+		if dbErr != nil {
+			slog.Error("Failed to record webhook delivery", "delivery_id", p.id, "error", dbErr)
+		}
+		return
+	}
+
+	status := models.WebhookDeliveryPending
+	var nextAttempt interface{} = time.Now().Add(retryDelay(attempts))
+	if attempts >= d.settings().MaxAttempts {
+		status, nextAttempt = models.WebhookDeliveryFailed, nil
+	}
+
+	slog.Warn("Webhook delivery failed", "delivery_id", p.id, "event", p.event, "attempt", attempts,
+		"status", statusCode, "error", err)
+
+	_, dbErr := d.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = $2, response_status = $3, error = $4, next_attempt_at = $5
+		WHERE id = $6`,
+		status, attempts, statusCode, err.Error(), nextAttempt, p.id,
+	)
+	if dbErr != nil {
+		slog.Error("Failed to record webhook delivery", "delivery_id", p.id, "error", dbErr)
+	}
+}
+
+// send posts the payload and returns the response status. Any non-2xx status is an error
+func (d *Dispatcher) send(p pendingDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, p.url, bytes.NewReader([]byte(p.payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "docker-deploy-webhooks/1.0")
+	req.Header.Set(EventHeader, string(p.event))
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(p.id, 10))
+	req.Header.Set(SignatureHeader, Sign(p.secret, []byte(p.payload)))
+
+	resp, err := d.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value of a payload: sha256= followed by the
+// hex encoded HMAC-SHA256 of the body keyed by the webhook secret
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay returns the wait before the next attempt after attempts failures
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,122 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/client"
+	"docker-deploy-app/internal/models"
+)
+
+// HealthWatcher publishes stack.unhealthy when a container of a managed stack fails
+// its health check, and resolves the alert when the container is healthy again
+type HealthWatcher struct {
+	db        *sql.DB
+	client    *client.Client
+	publisher *Publisher
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewHealthWatcher creates a new container health watcher
+func NewHealthWatcher(db *sql.DB, dockerClient *client.Client) *HealthWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &HealthWatcher{
+		db:        db,
+		client:    dockerClient,
+		publisher: NewPublisher(db),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start begins watching Docker health events
+func (hw *HealthWatcher) Start() {
+	go func() {
+		for hw.ctx.Err() == nil {
+			hw.watch()
+
+			select {
+			case <-time.After(5 * time.Second): // Reconnect delay
+			case <-hw.ctx.Done():
+			}
+		}
+	}()
+}
+
+// Stop stops watching
+func (hw *HealthWatcher) Stop() {
+	hw.cancel()
+}
+
+// watch consumes health events until the event stream fails
+func (hw *HealthWatcher) watch() {
+	eventsCh, errCh := hw.client.Events(hw.ctx, types.EventsOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("event", "health_status"),
+		),
+	})
+
+	for {
+		select {
+		case event := <-eventsCh:
+			switch event.Action {
+			case "health_status: unhealthy":
+				hw.handleHealth(event, false)
+			case "health_status: healthy":
+				hw.handleHealth(event, true)
+			}
+		case err := <-errCh:
+			if err != nil && hw.ctx.Err() == nil {
+				slog.Error("Docker events error", "error", err)
+			}
+			return
+		case <-hw.ctx.Done():
+			return
+		}
+	}
+}
+
+// handleHealth publishes the event when the container belongs to a deployment.
+// A container healthy again drops its alert if it was not sent yet
+func (hw *HealthWatcher) handleHealth(event events.Message, healthy bool) {
+	attributes := event.Actor.Attributes
+	stackName := attributes["com.docker.compose.project"]
+	if stackName == "" {
+		stackName = attributes["com.docker.stack.namespace"]
+	}
+	if stackName == "" {
+		return
+	}
+
+	var deploymentID string
+	err := hw.db.QueryRow("SELECT id FROM deployments WHERE stack_name = $1", stackName).Scan(&deploymentID)
+	if err != nil {
+		return // Not managed by this instance
+	}
+
+	service := attributes["com.docker.compose.service"]
+	if service == "" {
+		service = attributes["com.docker.swarm.service.name"]
+	}
+
+	data := map[string]interface{}{
+		"deployment_id": deploymentID,
+		"stack_name":    stackName,
+		"service":       service,
+		"container_id":  event.Actor.ID,
+		"container":     attributes["name"],
+	}
+	if healthy {
+		hw.publisher.Resolve(models.WebhookEventStackUnhealthy, data)
+		return
+	}
+	hw.publisher.Publish(models.WebhookEventStackUnhealthy, data)
+}
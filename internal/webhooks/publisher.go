@@ -0,0 +1,242 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"docker-deploy-app/internal/bus"
+	"docker-deploy-app/internal/models"
+)
+
+// Envelope is the JSON body delivered for every event
+type Envelope struct {
+	ID        string              `json:"id"` // Unique per event, shared by its deliveries
+	Event     models.WebhookEvent `json:"event"`
+	Timestamp time.Time           `json:"timestamp"`
+	Data      interface{}         `json:"data"`
+}
+
+// Publisher queues lifecycle events in the webhook outbox. Deliveries are written in
+// the same database as the state change and sent later by a Dispatcher, so events
+// survive restarts and slow receivers never block the caller. Each event is also
+// sent on the bus as a notification, for listeners in any replica
+type Publisher struct {
+	db            *sql.DB
+	notifications bus.Bus
+}
+
+// NewPublisher creates a new webhook event publisher
+func NewPublisher(db *sql.DB) *Publisher {
+	return &Publisher{db: db, notifications: bus.Default()}
+}
+
+// Publish queues a delivery of event to every enabled webhook subscribed to it.
+// A recovery event resolves the alert it follows: webhooks still holding that
+// alert get neither. Publishing is best effort: failures are logged and never
+// abort the caller
+func (p *Publisher) Publish(event models.WebhookEvent, data interface{}) {
+	p.notify(event, data)
+
+	key := dedupKey(event, data)
+	var resolved map[int]bool
+	if alert, ok := event.Recovers(); ok && key != "" {
+		resolved = p.resolve(alert, key)
+	}
+
+	hooks, err := LoadWebhooks(p.db, true)
+	if err != nil {
+		slog.Error("Failed to load webhooks", "event", event, "error", err)
+		return
+	}
+
+	var subscribers []*models.Webhook
+	for _, hook := range hooks {
+		if hook.Subscribes(event) && !resolved[hook.ID] {
+			subscribers = append(subscribers, hook)
+		}
+	}
+	if len(subscribers) == 0 {
+		return
+	}
+
+	if _, err := p.enqueue(subscribers, event, data, key); err != nil {
+		slog.Error("Failed to queue webhook deliveries", "event", event, "error", err)
+	}
+}
+
+// Resolve drops the deliveries of an alert that were not attempted yet, when
+// its problem went away without a recovery event of its own, such as a
+// container becoming healthy again
+func (p *Publisher) Resolve(alert models.WebhookEvent, data interface{}) {
+	if key := dedupKey(alert, data); key != "" {
+		p.resolve(alert, key)
+	}
+}
+
+// resolve drops the pending, never attempted deliveries of an alert and returns
+// the webhooks they were for
+func (p *Publisher) resolve(alert models.WebhookEvent, key string) map[int]bool {
+	rows, err := p.db.Query(`
+		SELECT id, webhook_id FROM webhook_deliveries
+		WHERE event = $1 AND dedup_key = $2 AND status = $3 AND attempts = 0`,
+		alert, key, models.WebhookDeliveryPending,
+	)
+	if err != nil {
+		slog.Error("Failed to load held webhook deliveries", "event", alert, "error", err)
+		return nil
+	}
+	ids := []int64{}
+	resolved := make(map[int]bool)
+	for rows.Next() {
+		var id int64
+		var webhookID int
+		if rows.Scan(&id, &webhookID) == nil {
+			ids = append(ids, id)
+			resolved[webhookID] = true
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if _, err := p.db.Exec("DELETE FROM webhook_deliveries WHERE id = $1 AND attempts = 0", id); err != nil {
+			slog.Error("Failed to drop resolved webhook delivery", "delivery_id", id, "error", err)
+		}
+	}
+	if len(ids) > 0 {
+		slog.Info("Dropped webhook alert resolved before it was sent", "event", alert, "subject", key, "deliveries", len(ids))
+	}
+	return resolved
+}
+
+// notify sends an event on the bus. Nothing is queued, so listeners that are not
+// subscribed at the time miss it
+func (p *Publisher) notify(event models.WebhookEvent, data interface{}) {
+	payload, err := json.Marshal(Envelope{
+		ID:        newEventID(),
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return
+	}
+	if err := p.notifications.Publish(bus.TopicNotifications, payload); err != nil {
+		slog.Warn("Failed to publish notification", "event", event, "error", err)
+	}
+}
+
+// enqueue writes one pending delivery per webhook and returns their IDs
+func (p *Publisher) enqueue(hooks []*models.Webhook, event models.WebhookEvent, data interface{}, key string) ([]int64, error) {
+	payload, err := json.Marshal(Envelope{
+		ID:        newEventID(),
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ids := make([]int64, 0, len(hooks))
+	for _, hook := range hooks {
+		result, err := p.db.Exec(`
+			INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt_at, dedup_key, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			hook.ID, event, string(payload), models.WebhookDeliveryPending, now, key, now,
+		)
+		if err != nil {
+			return ids, err
+		}
+		if id, err := result.LastInsertId(); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// Ping queues a ping event to a single webhook, regardless of its subscriptions
+func (p *Publisher) Ping(hook *models.Webhook) (int64, error) {
+	ids, err := p.enqueue([]*models.Webhook{hook}, models.WebhookEventPing, map[string]interface{}{
+		"webhook_id": hook.ID,
+		"events":     hook.Events,
+	}, "")
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// LoadWebhooks returns the configured webhooks
+func LoadWebhooks(db *sql.DB, enabledOnly bool) ([]*models.Webhook, error) {
+	query := "SELECT id, url, secret, events, enabled, COALESCE(quiet_hours, ''), COALESCE(group_alerts, FALSE), created_at FROM webhooks"
+	if enabledOnly {
+		query += " WHERE enabled = TRUE"
+	}
+	query += " ORDER BY id"
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []*models.Webhook{}
+	for rows.Next() {
+		var hook models.Webhook
+		var events, quietHours string
+		if err := rows.Scan(&hook.ID, &hook.URL, &hook.Secret, &events, &hook.Enabled, &quietHours, &hook.GroupAlerts, &hook.CreatedAt); err != nil {
+			return nil, err
+		}
+		hook.UnmarshalEvents(events)
+		hook.QuietHours = models.UnmarshalQuietHours(quietHours)
+		hooks = append(hooks, &hook)
+	}
+	return hooks, rows.Err()
+}
+
+// dedupKey returns the subject of an alert or recovery event: the alert it is
+// about and the deployment, service, probe, cron job or certificate named in its
+// data. Other events, and alerts naming none of these, have no key
+func dedupKey(event models.WebhookEvent, data interface{}) string {
+	alert, ok := event.Recovers()
+	if !ok {
+		if !event.IsAlert() {
+			return ""
+		}
+		alert = event
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+	var fields map[string]interface{}
+	if json.Unmarshal(encoded, &fields) != nil {
+		return ""
+	}
+
+	var subject []string
+	for _, name := range []string{"deployment_id", "service", "probe_id", "job_id", "certificate_id"} {
+		if value, ok := fields[name]; ok && value != nil && value != "" {
+			subject = append(subject, fmt.Sprintf("%s=%v", name, value))
+		}
+	}
+	if len(subject) == 0 {
+		return ""
+	}
+	return string(alert) + ":" + strings.Join(subject, ",")
+}
+
+// newEventID returns a random event identifier
+func newEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,10 @@
+// Package web holds the frontend, embedded in the server binary so it is served
+// wherever the binary runs
+package web
+
+import "embed"
+
+// Files is the frontend, rooted at this directory
+//
+//go:embed index.html favicon.ico manifest.json sw.js images src styles
+var Files embed.FS